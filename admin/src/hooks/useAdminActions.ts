@@ -11,6 +11,7 @@ export function useAdminActions() {
     applyUpdate: () => emit('applyUpdate'),
     acknowledgeUpdate: () => emit('acknowledgeUpdate'),
     getInstalled: () => emit('getInstalled'),
+    setPluginEnabled: (name: string, enabled: boolean) => emit('setPluginEnabled', { name, enabled }),
     getStats: () => emit('getStats'),
     requestPads: (opts: {
       offset: number
@@ -24,6 +25,7 @@ export function useAdminActions() {
     cleanupPadRevisions: (padName: string) => emit('cleanupPadRevisions', padName),
     sendBroadcast: (message: string, sticky: boolean) => emit('shout', { message, sticky }),
     saveSettings: (settings: string) => emit('saveSettings', settings),
+    applySettings: (settings?: string) => emit('applySettings', settings),
     restartServer: () => emit('restartServer'),
     getConnections: () => emit('getConnections'),
     getSystemInfo: () => emit('getSystemInfo'),
@@ -31,6 +33,15 @@ export function useAdminActions() {
     searchPadContent: (query: string, limit?: number) => emit('searchPadContent', { query, limit: limit || 20 }),
     getPadContent: (padName: string) => emit('getPadContent', padName),
     bulkDeletePads: (padNames: string[]) => emit('bulkDeletePads', { padNames }),
+    sheetToPad: (opts: {
+      padName: string
+      targetPadName: string
+      sheet?: string
+      range?: string
+      delimiter?: string
+    }) => emit('sheetToPad', opts),
+    padToSheet: (padName: string, targetPadName: string, delimiter?: string) =>
+      emit('padToSheet', { padName, targetPadName, delimiter }),
     refreshAll: () => {
       emit('checkUpdates')
       emit('getUpdateStatus')
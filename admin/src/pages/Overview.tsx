@@ -141,15 +141,30 @@ export function OverviewPage() {
                     <span className="rounded-full border border-gray-200 dark:border-gray-700 px-2 py-0.5 text-xs text-gray-500 dark:text-gray-400">
                       {plugin.version}
                     </span>
-                    <span
-                      className={`rounded-full px-2 py-0.5 text-xs font-medium ${
-                        plugin.enabled
-                          ? 'border border-green-200 dark:border-green-800 text-green-700 dark:text-green-400'
-                          : 'border border-gray-200 dark:border-gray-700 text-gray-400 dark:text-gray-500'
-                      }`}
-                    >
-                      {plugin.enabled ? 'enabled' : 'disabled'}
-                    </span>
+                    {plugin.runtime === 'wasm' ? (
+                      <button
+                        type="button"
+                        title={plugin.error ?? (plugin.enabled ? 'Disable' : 'Enable')}
+                        onClick={() => actions.setPluginEnabled(plugin.name, !plugin.enabled)}
+                        className={`rounded-full px-2 py-0.5 text-xs font-medium transition-colors ${
+                          plugin.enabled
+                            ? 'border border-green-200 dark:border-green-800 text-green-700 dark:text-green-400 hover:bg-green-50 dark:hover:bg-green-950'
+                            : 'border border-gray-200 dark:border-gray-700 text-gray-400 dark:text-gray-500 hover:bg-gray-100 dark:hover:bg-gray-800'
+                        }`}
+                      >
+                        {plugin.enabled ? 'enabled' : 'disabled'}
+                      </button>
+                    ) : (
+                      <span
+                        className={`rounded-full px-2 py-0.5 text-xs font-medium ${
+                          plugin.enabled
+                            ? 'border border-green-200 dark:border-green-800 text-green-700 dark:text-green-400'
+                            : 'border border-gray-200 dark:border-gray-700 text-gray-400 dark:text-gray-500'
+                        }`}
+                      >
+                        {plugin.enabled ? 'enabled' : 'disabled'}
+                      </span>
+                    )}
                   </div>
                 </div>
               ))
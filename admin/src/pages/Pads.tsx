@@ -9,6 +9,7 @@ import {
   RotateCcw,
   X,
   Eye,
+  Table,
 } from 'lucide-react'
 import { useAdminStore } from '@/store'
 import { useAdminActions } from '@/hooks/useAdminActions'
@@ -27,6 +28,14 @@ export function PadsPage() {
   const [selected, setSelected] = useState<Set<string>>(new Set())
   const [confirmBulkDelete, setConfirmBulkDelete] = useState(false)
 
+  // Conversion state
+  const [convertPad, setConvertPad] = useState<string | null>(null)
+  const [convertDirection, setConvertDirection] = useState<'padToSheet' | 'sheetToPad'>('padToSheet')
+  const [convertTarget, setConvertTarget] = useState('')
+  const [convertSheet, setConvertSheet] = useState('')
+  const [convertRange, setConvertRange] = useState('')
+  const [convertDelimiter, setConvertDelimiter] = useState('')
+
   // Preview state
   const [previewPad, setPreviewPad] = useState<string | null>(null)
 
@@ -105,6 +114,33 @@ export function PadsPage() {
     setTimeout(() => doRequest(), 500)
   }
 
+  const openConvert = (padName: string) => {
+    setConvertPad(padName)
+    setConvertTarget('')
+    setConvertSheet('')
+    setConvertRange('')
+    setConvertDelimiter('')
+  }
+
+  const handleConvert = () => {
+    const target = convertTarget.trim()
+    if (!convertPad || !target) return
+    const delimiter = convertDelimiter || undefined
+    if (convertDirection === 'padToSheet') {
+      actions.padToSheet(convertPad, target, delimiter)
+    } else {
+      actions.sheetToPad({
+        padName: convertPad,
+        targetPadName: target,
+        sheet: convertSheet.trim() || undefined,
+        range: convertRange.trim() || undefined,
+        delimiter,
+      })
+    }
+    setConvertPad(null)
+    setTimeout(() => doRequest(), 500)
+  }
+
   // -- Bulk Actions --
   const toggleSelect = (padName: string) => {
     setSelected((prev) => {
@@ -411,6 +447,14 @@ export function PadsPage() {
                         >
                           <ExternalLink className="h-4 w-4" strokeWidth={1.5} />
                         </button>
+                        <button
+                          type="button"
+                          onClick={() => openConvert(pad.padName)}
+                          title="Convert between pad and sheet"
+                          className="rounded-md p-1.5 text-gray-400 transition-colors hover:bg-gray-100 dark:hover:bg-gray-800 hover:text-black dark:hover:text-white"
+                        >
+                          <Table className="h-4 w-4" strokeWidth={1.5} />
+                        </button>
                         {confirmClean === pad.padName ? (
                           <div className="flex items-center gap-1">
                             <button
@@ -545,6 +589,89 @@ export function PadsPage() {
         </div>
       )}
 
+      {/* Convert Modal */}
+      {convertPad && (
+        <div className="fixed inset-0 z-50 flex items-center justify-center bg-black/50">
+          <div className="w-full max-w-md rounded-lg border border-gray-200 dark:border-gray-800 bg-white dark:bg-gray-900 p-6">
+            <div className="mb-4 flex items-center justify-between">
+              <h3 className="text-lg font-semibold text-black dark:text-white truncate">
+                Convert {convertPad}
+              </h3>
+              <button
+                type="button"
+                onClick={() => setConvertPad(null)}
+                className="rounded-md p-1 text-gray-400 transition-colors hover:text-black dark:hover:text-white"
+              >
+                <X className="h-5 w-5" strokeWidth={1.5} />
+              </button>
+            </div>
+            <div className="space-y-3">
+              <select
+                value={convertDirection}
+                onChange={(e) => setConvertDirection(e.target.value as 'padToSheet' | 'sheetToPad')}
+                className="w-full rounded-lg border border-gray-200 dark:border-gray-800 bg-white dark:bg-gray-900 px-3 py-2 text-sm text-black dark:text-white placeholder-gray-400 dark:placeholder-gray-500 transition-colors focus:border-black dark:focus:border-white focus:outline-none"
+              >
+                <option value="padToSheet">Text pad to new sheet</option>
+                <option value="sheetToPad">Sheet range to text pad</option>
+              </select>
+              <input
+                type="text"
+                placeholder={convertDirection === 'padToSheet' ? 'New sheet name' : 'Target pad name'}
+                value={convertTarget}
+                onChange={(e) => setConvertTarget(e.target.value)}
+                onKeyDown={(e) => e.key === 'Enter' && handleConvert()}
+                autoFocus
+                className="w-full rounded-lg border border-gray-200 dark:border-gray-800 bg-white dark:bg-gray-900 px-3 py-2 text-sm text-black dark:text-white placeholder-gray-400 dark:placeholder-gray-500 transition-colors focus:border-black dark:focus:border-white focus:outline-none"
+              />
+              {convertDirection === 'sheetToPad' && (
+                <div className="flex gap-2">
+                  <input
+                    type="text"
+                    placeholder="Worksheet (default first)"
+                    value={convertSheet}
+                    onChange={(e) => setConvertSheet(e.target.value)}
+                    className="w-full rounded-lg border border-gray-200 dark:border-gray-800 bg-white dark:bg-gray-900 px-3 py-2 text-sm text-black dark:text-white placeholder-gray-400 dark:placeholder-gray-500 transition-colors focus:border-black dark:focus:border-white focus:outline-none"
+                  />
+                  <input
+                    type="text"
+                    placeholder="Range, e.g. A1:D20"
+                    value={convertRange}
+                    onChange={(e) => setConvertRange(e.target.value)}
+                    className="w-full rounded-lg border border-gray-200 dark:border-gray-800 bg-white dark:bg-gray-900 px-3 py-2 text-sm text-black dark:text-white placeholder-gray-400 dark:placeholder-gray-500 transition-colors focus:border-black dark:focus:border-white focus:outline-none"
+                  />
+                </div>
+              )}
+              <select
+                value={convertDelimiter}
+                onChange={(e) => setConvertDelimiter(e.target.value)}
+                className="w-full rounded-lg border border-gray-200 dark:border-gray-800 bg-white dark:bg-gray-900 px-3 py-2 text-sm text-black dark:text-white placeholder-gray-400 dark:placeholder-gray-500 transition-colors focus:border-black dark:focus:border-white focus:outline-none"
+              >
+                <option value="">{convertDirection === 'padToSheet' ? 'Detect delimiter' : 'Delimiter: |'}</option>
+                <option value=",">Delimiter: ,</option>
+                <option value=";">Delimiter: ;</option>
+                {convertDirection === 'padToSheet' && <option value="|">Delimiter: |</option>}
+              </select>
+            </div>
+            <div className="mt-4 flex justify-end gap-2">
+              <button
+                type="button"
+                onClick={() => setConvertPad(null)}
+                className="rounded-lg border border-gray-200 dark:border-gray-700 px-4 py-2 text-sm font-medium text-gray-600 dark:text-gray-300 transition-colors hover:border-black dark:hover:border-white hover:text-black dark:hover:text-white"
+              >
+                Cancel
+              </button>
+              <button
+                type="button"
+                onClick={handleConvert}
+                className="rounded-lg bg-black px-4 py-2 text-sm font-medium text-white transition-colors hover:bg-gray-800"
+              >
+                Convert
+              </button>
+            </div>
+          </div>
+        </div>
+      )}
+
       {/* Preview Slide-over Panel */}
       {previewPad && (
         <>
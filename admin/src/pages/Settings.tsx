@@ -1,5 +1,5 @@
 import { useEffect, useMemo, useState, useCallback } from 'react'
-import { RefreshCw, Save, RotateCcw, Code } from 'lucide-react'
+import { RefreshCw, Save, RotateCcw, Code, Check } from 'lucide-react'
 import { useAdminStore } from '@/store'
 import { useAdminActions } from '@/hooks/useAdminActions'
 
@@ -129,7 +129,6 @@ export function SettingsPage() {
 
   const handleSave = () => {
     actions.saveSettings(store.settings)
-    store.setToast({ kind: 'success', message: 'Settings saved.' })
   }
 
   const renderField = (f: FieldDef) => {
@@ -293,6 +292,9 @@ export function SettingsPage() {
           <button type="button" onClick={handleSave} className="flex items-center gap-1.5 rounded-lg bg-black px-3 py-1.5 text-sm font-medium text-white transition-colors hover:bg-gray-800">
             <Save className="h-3.5 w-3.5" strokeWidth={1.5} /> Save
           </button>
+          <button type="button" onClick={() => actions.applySettings(store.settings)} title="Save and apply the settings that do not need a restart" className="flex items-center gap-1.5 rounded-lg border border-gray-200 dark:border-gray-700 px-3 py-1.5 text-sm font-medium text-gray-600 dark:text-gray-300 transition-colors hover:border-black dark:hover:border-white hover:text-black dark:hover:text-white">
+            <Check className="h-3.5 w-3.5" strokeWidth={1.5} /> Apply
+          </button>
           <button type="button" onClick={() => { actions.restartServer(); store.setToast({ kind: 'success', message: 'Restart signal sent.' }) }} className="flex items-center gap-1.5 rounded-lg border border-gray-200 dark:border-gray-700 px-3 py-1.5 text-sm font-medium text-gray-600 dark:text-gray-300 transition-colors hover:border-black dark:hover:border-white hover:text-black dark:hover:text-white">
             <RotateCcw className="h-3.5 w-3.5" strokeWidth={1.5} /> Restart
           </button>
@@ -286,6 +286,38 @@ export function AdminProvider({ children }: { children: ReactNode }) {
             : { kind: 'error', message: payload?.error ?? 'Failed to start update' },
         })
         break
+      case 'results:setPluginEnabled':
+        dispatch({
+          type: 'SET_TOAST',
+          payload: payload?.success
+            ? { kind: 'success', message: 'Plugin updated.' }
+            : { kind: 'error', message: payload?.error ?? 'Failed to update plugin' },
+        })
+        break
+      case 'results:saveSettings':
+        dispatch({
+          type: 'SET_TOAST',
+          payload: payload?.success
+            ? { kind: 'success', message: 'Settings saved.' }
+            : { kind: 'error', message: payload?.error ?? 'Failed to save settings' },
+        })
+        break
+      case 'results:applySettings': {
+        const applied: string[] = payload?.applied ?? []
+        const restartRequired: string[] = payload?.restartRequired ?? []
+        let toast: Toast
+        if (payload?.error) {
+          toast = { kind: 'error', message: payload.error }
+        } else if (restartRequired.length > 0) {
+          toast = { kind: 'info', message: `Restart required for: ${restartRequired.join(', ')}` }
+        } else if (applied.length > 0) {
+          toast = { kind: 'success', message: `Applied: ${applied.join(', ')}` }
+        } else {
+          toast = { kind: 'info', message: 'No settings changed.' }
+        }
+        dispatch({ type: 'SET_TOAST', payload: toast })
+        break
+      }
       case 'results:acknowledgeUpdate':
         dispatch({
           type: 'SET_TOAST',
@@ -376,6 +408,19 @@ export function AdminProvider({ children }: { children: ReactNode }) {
         dispatch({ type: 'SET_LAST_UPDATED', payload: new Date() })
         break
       }
+      case 'results:sheetToPad':
+      case 'results:padToSheet': {
+        const failed = typeof payload?.error === 'string'
+        dispatch({
+          type: 'SET_TOAST',
+          payload: {
+            kind: failed ? 'error' : 'success',
+            message: failed ? `Conversion failed: ${payload.error}` : (payload?.success ?? 'Converted successfully'),
+          },
+        })
+        dispatch({ type: 'SET_LAST_UPDATED', payload: new Date() })
+        break
+      }
       default:
         console.warn('Unhandled admin event:', event, payload)
     }
@@ -67,7 +67,7 @@ func CreateAuthor(initStore *lib.InitStore, authorManager *author.Manager) fiber
 			return c.Status(400).JSON(errors.NewInvalidParamError(err.Error()))
 		}
 
-		createdAuthor, err := authorManager.CreateAuthor(&dto.Name)
+		createdAuthor, err := authorManager.WithContext(c.Context()).CreateAuthor(&dto.Name)
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
@@ -100,14 +100,14 @@ func CreateAuthorIfNotExistsFor(initStore *lib.InitStore, authorManager *author.
 		}
 
 		// Get or create author by token (mapper)
-		existingAuthor, err := authorManager.GetAuthor4Token(request.AuthorMapper)
+		existingAuthor, err := authorManager.WithContext(c.Context()).GetAuthor4Token(request.AuthorMapper)
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
 
 		// Update name if provided
 		if request.Name != "" {
-			authorManager.SetAuthorName(existingAuthor.Id, request.Name)
+			authorManager.WithContext(c.Context()).SetAuthorName(existingAuthor.Id, request.Name)
 		}
 
 		return c.JSON(CreateDtoResponse{
@@ -135,7 +135,7 @@ func GetAuthorName(initStore *lib.InitStore, authorManager *author.Manager) fibe
 			return c.Status(400).JSON(errors.NewInvalidParamError("authorId is required"))
 		}
 
-		foundAuthor, err := authorManager.GetAuthor(authorId)
+		foundAuthor, err := authorManager.WithContext(c.Context()).GetAuthor(authorId)
 		if foundAuthor == nil || err != nil {
 			return c.Status(404).JSON(errors.AuthorNotFoundError)
 		}
@@ -170,7 +170,7 @@ func GetAuthor(initStore *lib.InitStore, authorManager *author.Manager) fiber.Ha
 		if authorId == "" {
 			return c.Status(400).JSON(errors.NewInvalidParamError("authorId is required"))
 		}
-		var foundAuthor, err = authorManager.GetAuthor(authorId)
+		var foundAuthor, err = authorManager.WithContext(c.Context()).GetAuthor(authorId)
 		if foundAuthor == nil {
 			return c.Status(404).JSON(errors.AuthorNotFoundError)
 		}
@@ -202,7 +202,7 @@ func GetAuthorPads(initStore *lib.InitStore, authorManager *author.Manager) fibe
 		if authorId == "" {
 			return c.Status(400).JSON(errors.NewInvalidParamError("authorId is required"))
 		}
-		var foundAuthor, err = authorManager.GetAuthor(authorId)
+		var foundAuthor, err = authorManager.WithContext(c.Context()).GetAuthor(authorId)
 		if foundAuthor == nil {
 			return c.Status(404).JSON(errors.AuthorNotFoundError)
 		}
@@ -211,7 +211,7 @@ func GetAuthorPads(initStore *lib.InitStore, authorManager *author.Manager) fibe
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
 
-		padsOfAuthor, err := authorManager.GetPadsOfAuthor(authorId)
+		padsOfAuthor, err := authorManager.WithContext(c.Context()).GetPadsOfAuthor(authorId)
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
@@ -240,7 +240,7 @@ func AnonymizeAuthor(initStore *lib.InitStore, authorManager *author.Manager) fi
 			return c.Status(400).JSON(errors.NewInvalidParamError("authorId is required"))
 		}
 
-		if err := authorManager.AnonymizeAuthor(authorId); err != nil {
+		if err := authorManager.WithContext(c.Context()).AnonymizeAuthor(authorId); err != nil {
 			if err.Error() == db.AuthorNotFoundError {
 				return c.Status(404).JSON(errors.AuthorNotFoundError)
 			}
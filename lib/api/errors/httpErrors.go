@@ -15,6 +15,21 @@ var RevisionHigherThanHeadError = Error{
 	Error:   400,
 }
 
+var InvalidTimestampError = Error{
+	Message: "Invalid timestamp, expected RFC 3339",
+	Error:   400,
+}
+
+var RevisionAndTimestampError = Error{
+	Message: "Only one of rev and at can be given",
+	Error:   400,
+}
+
+var NoRevisionAtError = Error{
+	Message: "Pad has no revision at that time",
+	Error:   404,
+}
+
 var InvalidRequestError = Error{
 	Message: "Invalid request",
 	Error:   400,
@@ -95,3 +110,83 @@ var InvalidParameterError = Error{
 	Message: "Invalid parameter provided",
 	Error:   422,
 }
+
+var NothingToUndoError = Error{
+	Message: "Nothing to undo",
+	Error:   400,
+}
+
+var NothingToRedoError = Error{
+	Message: "Nothing to redo",
+	Error:   400,
+}
+
+var SheetNotFoundError = Error{
+	Message: "Sheet not found",
+	Error:   404,
+}
+
+var SheetHistoryUnavailableError = Error{
+	Message: "Sheet history is not available for this revision",
+	Error:   404,
+}
+
+var WorksheetNotFoundError = Error{
+	Message: "Worksheet not found",
+	Error:   404,
+}
+
+var InvalidRangeError = Error{
+	Message: "Invalid range, expected A1 notation such as A1:D20",
+	Error:   400,
+}
+
+var RangeTooLargeError = Error{
+	Message: "Range has too many cells",
+	Error:   400,
+}
+
+var WrongDocumentTypeError = Error{
+	Message: "Pad has the wrong document type for this conversion",
+	Error:   400,
+}
+
+var NothingToConvertError = Error{
+	Message: "Nothing to convert",
+	Error:   400,
+}
+
+var NotAForkError = Error{
+	Message: "Pad is not a fork",
+	Error:   400,
+}
+
+var ForkOntoSourceError = Error{
+	Message: "A pad cannot be forked onto itself or a pad it was forked from",
+	Error:   400,
+}
+
+var NothingToMergeError = Error{
+	Message: "The fork has no changes to merge",
+	Error:   400,
+}
+
+var MergeConflictError = Error{
+	Message: "The fork conflicts with its source",
+	Error:   409,
+}
+
+var ChatMessageNotFoundError = Error{
+	Message: "Chat message not found",
+	Error:   404,
+}
+
+var ChatMessageDeletedError = Error{
+	Message: "Chat message is deleted",
+	Error:   409,
+}
+
+var ChatNotAuthorError = Error{
+	Message: "Only the author can edit a chat message",
+	Error:   403,
+}
@@ -1,55 +1,37 @@
 package io
 
 import (
-	"slices"
-
 	"github.com/ether/etherpad-go/lib/io"
 	"github.com/ether/etherpad-go/lib/pad"
-	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
 )
 
 // GetExport godoc
 // @Summary Export a pad
-// @Description Exports the content of a pad to various formats (pdf, word, txt, html, open, etherpad, markdown)
+// @Description Exports the content of a pad to the built-in formats (pdf, word, txt, html, open, etherpad, markdown) or a format registered by a plugin
 // @Tags Export
 // @Produce octet-stream
 // @Param pad path string true "Pad ID"
 // @Param rev path string false "Revision number"
-// @Param type path string true "Export type (pdf, word, txt, html, open, etherpad, markdown)"
+// @Param at query string false "RFC 3339 timestamp, exports the last revision at or before it"
+// @Param type path string true "Export type (pdf, word, txt, html, open, etherpad, markdown or a plugin format)"
 // @Success 200 {file} binary "Exported file"
 // @Failure 400 {string} string "Invalid export type"
 // @Failure 401 {string} string "Unauthorized"
 // @Failure 404 {string} string "Pad not found"
 // @Failure 500 {string} string "Internal server error"
-// @Failure 503 {string} string "Export not available"
 // @Router /p/{pad}/export/{type} [get]
 // @Router /p/{pad}/{rev}/export/{type} [get]
-func GetExport(ctx fiber.Ctx, exportHandler *io.ExportEtherpad, settings *settings.Settings, logger *zap.SugaredLogger, padManager *pad.Manager, readOnlyManager *pad.ReadOnlyManager, securityManager *pad.SecurityManager) error {
+func GetExport(ctx fiber.Ctx, exportHandler *io.ExportEtherpad, formats *io.FormatRegistry, logger *zap.SugaredLogger, padManager *pad.Manager, readOnlyManager *pad.ReadOnlyManager, securityManager *pad.SecurityManager) error {
 	padId := ctx.Params("pad")
 	rev := ctx.Params("rev")
 	exportType := ctx.Params("type")
-	typesToExport := map[string]string{
-		"pdf":      "pdf",
-		"word":     "docx",
-		"txt":      "txt",
-		"html":     "html",
-		"open":     "odt",
-		"etherpad": "etherpad",
-		"markdown": "md",
-	}
-	// All formats are now supported internally, no external tools needed
-	var externalTypes []string
-
-	if _, ok := typesToExport[exportType]; !ok {
+	format, ok := formats.Export(exportType)
+	if !ok {
 		return ctx.Status(400).SendString("Invalid export type")
 	}
 
-	if slices.Contains(externalTypes, exportType) {
-		logger.Warnf("Export to %s requested but exporting is disabled in settings", exportType)
-		return ctx.Status(503).SendString("Exporting to " + exportType + " is not available")
-	}
 	ctx.Response().Header.Set("Access-Control-Allow-Origin", "*")
 
 	if securityManager.HasPadAccess(ctx) {
@@ -73,7 +55,7 @@ func GetExport(ctx fiber.Ctx, exportHandler *io.ExportEtherpad, settings *settin
 
 		logger.Infof("Exporting pad %s revision %s to %s", padId, rev, exportType)
 
-		return exportHandler.DoExport(ctx, padId, readOnlyId, typesToExport[exportType])
+		return exportHandler.DoExport(ctx, padId, readOnlyId, format)
 	}
 
 	return ctx.Status(401).SendString("Unauthorized to access this pad")
@@ -1,13 +1,17 @@
 package io
 
 import (
+	"context"
 	stdio "io"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ether/etherpad-go/lib/hooks"
 	"github.com/ether/etherpad-go/lib/hooks/events"
 	"github.com/ether/etherpad-go/lib/io"
+	"github.com/ether/etherpad-go/lib/metrics"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
 	"github.com/ether/etherpad-go/lib/pad"
 	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/ether/etherpad-go/lib/ws"
@@ -41,26 +45,27 @@ type ImportData struct {
 	DirectDatabaseAccess bool `json:"directDatabaseAccess" example:"true"`
 }
 
-// Known file extensions that can be imported
-var knownFileEndings = []string{".txt", ".html", ".htm", ".etherpad", ".docx", ".doc", ".odt", ".rtf", ".pdf"}
-
 // ImportHandler handles pad import operations
 type ImportHandler struct {
 	padManager      *pad.Manager
 	securityManager *pad.SecurityManager
 	padHandler      *ws.PadMessageHandler
 	importer        *io.Importer
+	formats         *io.FormatRegistry
 	settings        *settings.Settings
 	logger          *zap.SugaredLogger
 	hooks           *hooks.Hook
 }
 
-// NewImportHandler creates a new ImportHandler
+// NewImportHandler creates a new ImportHandler. padHandler may be nil when
+// no server is running (e.g. the CLI importing into the database directly).
+// Files are dispatched on their extension to the import formats in formats.
 func NewImportHandler(
 	padManager *pad.Manager,
 	securityManager *pad.SecurityManager,
 	padHandler *ws.PadMessageHandler,
 	importer *io.Importer,
+	formats *io.FormatRegistry,
 	settings *settings.Settings,
 	logger *zap.SugaredLogger,
 	hooks *hooks.Hook,
@@ -70,6 +75,7 @@ func NewImportHandler(
 		securityManager: securityManager,
 		padHandler:      padHandler,
 		importer:        importer,
+		formats:         formats,
 		settings:        settings,
 		logger:          logger,
 		hooks:           hooks,
@@ -78,7 +84,7 @@ func NewImportHandler(
 
 // ImportPad godoc
 // @Summary Import a file into a pad
-// @Description Imports the content of a file into an existing or new pad. Supported formats: txt, html, htm, etherpad, docx, doc, odt, rtf, pdf
+// @Description Imports the content of a file into an existing or new pad. Built-in formats: txt, html, htm, etherpad, docx, doc, odt, rtf, md, markdown, pdf. Plugins may register more.
 // @Tags Import
 // @Accept multipart/form-data
 // @Produce json
@@ -94,7 +100,7 @@ func (h *ImportHandler) ImportPad(ctx fiber.Ctx) error {
 	padId := ctx.Params("pad")
 
 	// Check access
-	grantedAccess, err := h.securityManager.CheckAccess(&padId, nil, &tokenCookie, nil)
+	grantedAccess, err := h.securityManager.CheckAccessContext(ctx.Context(), &padId, nil, &tokenCookie, nil)
 	if err != nil {
 		return ctx.Status(500).JSON(ImportResponse{
 			Code:    2,
@@ -146,9 +152,6 @@ func (h *ImportHandler) doImport(ctx fiber.Ctx, padId string, authorId string) (
 		return false, &ImportError{Status: "maxFileSize"}
 	}
 
-	// Get file extension
-	fileEnding := strings.ToLower(filepath.Ext(fileHeader.Filename))
-
 	// Open the file
 	file, err := fileHeader.Open()
 	if err != nil {
@@ -167,60 +170,110 @@ func (h *ImportHandler) doImport(ctx fiber.Ctx, padId string, authorId string) (
 		return false, &ImportError{Status: "uploadFailed", Message: "could not read file"}
 	}
 
+	return h.ImportFileContext(ctx.Context(), padId, authorId, fileHeader.Filename, content)
+}
+
+// ImportFile imports content into padId, dispatching on the extension of
+// fileName. It is shared by the upload route and the CLI. The returned bool
+// reports whether the import wrote to the database directly.
+func (h *ImportHandler) ImportFile(padId string, authorId string, fileName string, content []byte) (bool, *ImportError) {
+	return h.ImportFileContext(context.Background(), padId, authorId, fileName, content)
+}
+
+// ImportFileContext is ImportFile with the import hook traced as a child of
+// the span in ctx.
+func (h *ImportHandler) ImportFileContext(ctx context.Context, padId string, authorId string, fileName string, content []byte) (bool, *ImportError) {
+	started := time.Now()
+	format, directDB, importErr := h.importFile(ctx, padId, authorId, strings.ToLower(filepath.Ext(fileName)), content)
+	result := "ok"
+	if importErr != nil {
+		result = "error"
+	}
+	metrics.Since(metrics.ImportDuration.WithLabelValues(format, result), started)
+	return directDB, importErr
+}
+
+// importFile performs ImportFile and returns the format label it used:
+// "plugin" when a plugin handled the file, metrics.Other for rejected
+// extensions and the extension without the dot otherwise.
+func (h *ImportHandler) importFile(ctx context.Context, padId string, authorId string, fileEnding string, content []byte) (string, bool, *ImportError) {
+
 	// Fire import hook before the built-in extension dispatch.
 	// A plugin may handle unknown or custom formats entirely; if handled, skip
 	// the built-in importer.
 	if h.hooks != nil {
 		importCtx := &events.ImportContext{FileEnding: fileEnding, PadId: padId, AuthorId: authorId, Content: content}
-		h.hooks.ExecuteImportHooks(importCtx)
+		h.hooks.ExecuteHooksContext(ctx, hooks.ImportString, importCtx)
 		if importCtx.Handled() {
 			if html, ok := importCtx.HTML(); ok {
-				return h.importHTML(padId, authorId, html)
+				directDB, err := h.importHTML(padId, authorId, html)
+				return "plugin", directDB, err
 			}
 			if text, ok := importCtx.Text(); ok {
-				return h.importText(padId, authorId, text)
+				directDB, err := h.importText(padId, authorId, text)
+				return "plugin", directDB, err
 			}
-			return false, nil
+			return "plugin", false, nil
 		}
 	}
 
-	// Check if file extension is known (built-in formats only).
-	fileEndingKnown := false
-	for _, ending := range knownFileEndings {
-		if fileEnding == ending {
-			fileEndingKnown = true
-			break
-		}
+	format, ok := h.formats.Import(fileEnding)
+	if !ok && h.settings.AllowUnknownFileEnds {
+		// Treat unknown file as .txt
+		fileEnding = ".txt"
+		format, ok = h.formats.Import(fileEnding)
+	}
+	if !ok {
+		h.logger.Warnf("Import failed: unknown file type %s", fileEnding)
+		return metrics.Other, false, &ImportError{Status: "uploadFailed", Message: "unknown file type"}
 	}
 
-	if !fileEndingKnown {
-		if h.settings.AllowUnknownFileEnds {
-			// Treat unknown file as .txt
-			fileEnding = ".txt"
-		} else {
-			h.logger.Warnf("Import failed: unknown file type %s", fileEnding)
-			return false, &ImportError{Status: "uploadFailed", Message: "unknown file type"}
-		}
+	label := strings.TrimPrefix(fileEnding, ".")
+	doc, err := format.Import(content)
+	if err != nil {
+		h.logger.Warnf("Import failed: could not read %s file: %v", format.Name, err)
+		return label, false, &ImportError{Status: "importFailed", Message: err.Error()}
 	}
+	directDB, importErr := h.importDocument(padId, authorId, doc)
+	return label, directDB, importErr
+}
 
-	// Handle different file types
-	switch fileEnding {
-	case ".etherpad":
-		return h.importEtherpad(padId, authorId, content)
-	case ".html", ".htm":
-		return h.importHTML(padId, authorId, string(content))
-	case ".txt":
-		return h.importText(padId, authorId, string(content))
-	case ".docx", ".doc":
-		return h.importDocx(padId, authorId, content)
-	case ".odt":
-		return h.importOdt(padId, authorId, content)
-	case ".rtf":
-		return h.importRtf(padId, authorId, content)
-	case ".pdf":
-		return h.importPdf(padId, authorId, content)
-	default:
-		return false, &ImportError{Status: "uploadFailed", Message: "unsupported file type"}
+// importDocument writes what an import format produced to the pad, see
+// io.ImportedDocument for the precedence of its fields.
+func (h *ImportHandler) importDocument(padId string, authorId string, doc *io.ImportedDocument) (bool, *ImportError) {
+	switch {
+	case doc == nil:
+		return false, &ImportError{Status: "importFailed", Message: "empty document"}
+	case doc.Etherpad != nil:
+		if doc.Text != nil && h.padHasData(padId, authorId) {
+			h.logger.Info("Pad already has content, importing the text of the Etherpad data")
+			return h.importText(padId, authorId, *doc.Text)
+		}
+		return h.importEtherpad(padId, authorId, doc.Etherpad)
+	case doc.AText != nil || doc.Changeset != "":
+		return h.importAText(padId, authorId, doc)
+	case doc.Paragraphs != nil:
+		return h.importRich(padId, authorId, doc.Paragraphs)
+	case doc.HTML != nil:
+		return h.importHTML(padId, authorId, *doc.HTML)
+	case doc.Text != nil:
+		return h.importText(padId, authorId, *doc.Text)
+	}
+	return false, &ImportError{Status: "importFailed", Message: "empty document"}
+}
+
+// padHasData reports whether padId has too many revisions for a direct
+// database import.
+func (h *ImportHandler) padHasData(padId string, authorId string) bool {
+	newText := "\n"
+	retrievedPad, err := h.padManager.GetPad(padId, &newText, &authorId)
+	return err == nil && retrievedPad.Head >= 10
+}
+
+// updatePadClients pushes the imported content to connected editors.
+func (h *ImportHandler) updatePadClients(retrievedPad *padModel.Pad) {
+	if h.padHandler != nil {
+		h.padHandler.UpdatePadClients(retrievedPad)
 	}
 }
 
@@ -257,7 +310,7 @@ func (h *ImportHandler) importEtherpad(padId string, authorId string, content []
 		h.logger.Warnf("Import succeeded but could not reload pad: %v", err)
 	} else {
 		// Notify connected clients
-		h.padHandler.UpdatePadClients(retrievedPad)
+		h.updatePadClients(retrievedPad)
 	}
 
 	return true, nil
@@ -287,7 +340,7 @@ func (h *ImportHandler) importHTML(padId string, authorId string, content string
 	}
 
 	// Notify connected clients
-	h.padHandler.UpdatePadClients(retrievedPad)
+	h.updatePadClients(retrievedPad)
 
 	return false, nil
 }
@@ -331,82 +384,73 @@ func (h *ImportHandler) importText(padId string, authorId string, content string
 	}
 
 	// Notify connected clients to reload
-	h.padHandler.UpdatePadClients(retrievedPad)
+	h.updatePadClients(retrievedPad)
 
 	return false, nil
 }
 
-// importDocx imports a DOCX file
-func (h *ImportHandler) importDocx(padId string, authorId string, content []byte) (bool, *ImportError) {
-	text, err := h.importer.ExtractTextFromDocx(content)
+// importRich replaces the pad content with formatted paragraphs
+func (h *ImportHandler) importRich(padId string, authorId string, paragraphs []io.RichParagraph) (bool, *ImportError) {
+	h.padManager.UnloadPad(padId)
+
+	newText := "\n"
+	retrievedPad, err := h.padManager.GetPad(padId, &newText, &authorId)
 	if err != nil {
-		h.logger.Warnf("Import failed: could not extract text from DOCX: %v", err)
-		return false, &ImportError{Status: "importFailed", Message: "could not read DOCX file"}
+		h.logger.Warnf("Import failed: could not get pad: %v", err)
+		return false, &ImportError{Status: "internalError", Message: "could not get pad"}
 	}
 
-	return h.importText(padId, authorId, text)
-}
+	if err := io.SetPadRich(retrievedPad, paragraphs, authorId); err != nil {
+		h.logger.Warnf("Import failed: could not set formatted text: %v", err)
+		return false, &ImportError{Status: "importFailed", Message: err.Error()}
+	}
 
-// importOdt imports an ODT file
-func (h *ImportHandler) importOdt(padId string, authorId string, content []byte) (bool, *ImportError) {
-	text, err := h.importer.ExtractTextFromOdt(content)
+	// Unload and reload pad to ensure fresh state
+	h.padManager.UnloadPad(padId)
+	retrievedPad, err = h.padManager.GetPad(padId, &newText, &authorId)
 	if err != nil {
-		h.logger.Warnf("Import failed: could not extract text from ODT: %v", err)
-		return false, &ImportError{Status: "importFailed", Message: "could not read ODT file"}
+		h.logger.Warnf("Import failed: could not reload pad: %v", err)
+		return false, &ImportError{Status: "internalError", Message: "could not reload pad"}
 	}
 
-	return h.importText(padId, authorId, text)
+	h.updatePadClients(retrievedPad)
+
+	return false, nil
 }
 
-// importRtf imports an RTF file
-func (h *ImportHandler) importRtf(padId string, authorId string, content []byte) (bool, *ImportError) {
-	text, err := h.importer.ExtractTextFromRtf(content)
+// importAText replaces the pad content with the AText of doc, or applies
+// its changeset
+func (h *ImportHandler) importAText(padId string, authorId string, doc *io.ImportedDocument) (bool, *ImportError) {
+	h.padManager.UnloadPad(padId)
+
+	newText := "\n"
+	retrievedPad, err := h.padManager.GetPad(padId, &newText, &authorId)
 	if err != nil {
-		h.logger.Warnf("Import failed: could not extract text from RTF: %v", err)
-		return false, &ImportError{Status: "importFailed", Message: "could not read RTF file"}
+		h.logger.Warnf("Import failed: could not get pad: %v", err)
+		return false, &ImportError{Status: "internalError", Message: "could not get pad"}
 	}
 
-	return h.importText(padId, authorId, text)
-}
-
-// importPdf imports a PDF file
-// First tries to extract embedded Etherpad JSON data (similar to ZUGFeRD format)
-// Falls back to text extraction if no embedded data is found
-func (h *ImportHandler) importPdf(padId string, authorId string, content []byte) (bool, *ImportError) {
-	// Try to extract embedded Etherpad JSON first
-	etherpadJson, err := h.importer.ExtractEtherpadFromPdf(content)
-	if err == nil && etherpadJson != nil {
-		h.logger.Info("Found embedded Etherpad JSON in PDF, attempting lossless import")
-
-		// Check if pad already has content
-		newText := "\n"
-		retrievedPad, err := h.padManager.GetPad(padId, &newText, &authorId)
-		if err != nil {
-			h.logger.Warnf("Could not get pad: %v", err)
-			// Fall through to text extraction
-		} else if retrievedPad.Head < 10 {
-			// Pad is empty enough, try raw import
-			return h.importEtherpad(padId, authorId, etherpadJson)
-		} else {
-			// Pad already has content, extract text from Etherpad JSON and import as text
-			h.logger.Info("Pad already has content, extracting text from Etherpad JSON")
-			text, err := h.importer.ExtractTextFromEtherpadJson(etherpadJson)
-			if err == nil && text != "" {
-				return h.importText(padId, authorId, text)
-			}
-			h.logger.Warnf("Could not extract text from Etherpad JSON: %v", err)
-		}
+	if doc.AText != nil {
+		err = h.importer.SetPadAText(retrievedPad, *doc.AText, doc.Pool, authorId)
+	} else {
+		err = h.importer.ApplyPadChangeset(retrievedPad, doc.Changeset, doc.Pool, authorId)
+	}
+	if err != nil {
+		h.logger.Warnf("Import failed: could not apply document: %v", err)
+		return false, &ImportError{Status: "importFailed", Message: err.Error()}
 	}
 
-	// Fall back to text extraction from PDF
-	h.logger.Info("Falling back to text extraction from PDF")
-	text, err := h.importer.ExtractTextFromPdf(content)
+	// Unload and reload pad to ensure fresh state
+	h.padManager.UnloadPad(padId)
+	retrievedPad, err = h.padManager.GetPad(padId, &newText, &authorId)
 	if err != nil {
-		h.logger.Warnf("Import failed: could not extract text from PDF: %v", err)
-		return false, &ImportError{Status: "importFailed", Message: "could not read PDF file"}
+		h.logger.Warnf("Import failed: could not reload pad: %v", err)
+		return false, &ImportError{Status: "internalError", Message: "could not reload pad"}
 	}
 
-	return h.importText(padId, authorId, text)
+	h.updatePadClients(retrievedPad)
+
+	return false, nil
 }
 
 // isValidText checks if the content is valid text (no binary/control characters except newlines/tabs)
@@ -430,7 +474,7 @@ func isValidText(content string) bool {
 func ImportPad(ctx fiber.Ctx, securityManager *pad.SecurityManager) error {
 	tokenCookie := ctx.Cookies("token")
 	padId := ctx.Params("pad")
-	grantedAccess, err := securityManager.CheckAccess(&padId, nil, &tokenCookie, nil)
+	grantedAccess, err := securityManager.CheckAccessContext(ctx.Context(), &padId, nil, &tokenCookie, nil)
 	if err != nil {
 		return ctx.Status(500).JSON(ImportResponse{
 			Code:    2,
@@ -9,21 +9,28 @@ import (
 func Init(store *lib.InitStore) {
 	exportEtherpad := io.NewExportEtherpad(store.Hooks, store.PadManager, store.Store, store.Logger, store.UiAssets)
 	importer := io.NewImporter(store.PadManager, store.AuthorManager, store.Store, store.Logger, store.Hooks)
+	formats := store.Formats
+	if formats == nil {
+		formats = io.NewFormatRegistry()
+		exportEtherpad.RegisterFormats(formats)
+		importer.RegisterFormats(formats)
+	}
 	importHandler := NewImportHandler(
 		store.PadManager,
 		store.SecurityManager,
 		store.Handler,
 		importer,
+		formats,
 		store.RetrievedSettings,
 		store.Logger,
 		store.Hooks,
 	)
 
 	store.C.Get("/p/:pad/:rev/export/:type", func(ctx fiber.Ctx) error {
-		return GetExport(ctx, exportEtherpad, store.RetrievedSettings, store.Logger, store.PadManager, store.ReadOnlyManager, store.SecurityManager)
+		return GetExport(ctx, exportEtherpad, formats, store.Logger, store.PadManager, store.ReadOnlyManager, store.SecurityManager)
 	})
 	store.C.Get("/p/:pad/export/:type", func(ctx fiber.Ctx) error {
-		return GetExport(ctx, exportEtherpad, store.RetrievedSettings, store.Logger, store.PadManager, store.ReadOnlyManager, store.SecurityManager)
+		return GetExport(ctx, exportEtherpad, formats, store.Logger, store.PadManager, store.ReadOnlyManager, store.SecurityManager)
 	})
 
 	store.C.Post("/p/:pad/import", importHandler.ImportPad)
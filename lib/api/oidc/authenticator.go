@@ -70,20 +70,7 @@ func NewAuthenticator(retrievedSettings *settings.Settings, persistence db.DataS
 
 	}
 
-	for username, user := range retrievedSettings.Users {
-		if user.Password == nil || *user.Password == "" {
-			continue
-		}
-		isAdmin := false
-		if user.IsAdmin != nil {
-			isAdmin = *user.IsAdmin
-		}
-		store.Users[username] = MemoryUserRelation{
-			Username: username,
-			Password: *user.Password,
-			Admin:    isAdmin,
-		}
-	}
+	store.SetUsers(retrievedSettings.UserAccounts())
 
 	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
 	privateKey, err := loadOrCreatePrivateKey(persistence, privateKey)
@@ -150,6 +137,11 @@ func (a *Authenticator) rebuildProvider() {
 	a.mu.Unlock()
 }
 
+// ReloadUsers replaces the users that can log in with the users setting.
+func (a *Authenticator) ReloadUsers(users map[string]settings.User) {
+	a.store.SetUsers(users)
+}
+
 // currentProvider returns the active OAuth2 provider. Capture it once per
 // request so a concurrent rotation cannot swap the provider mid-handler.
 func (a *Authenticator) currentProvider() fosite.OAuth2Provider {
@@ -408,7 +400,7 @@ func (a *Authenticator) AuthEndpoint(rw http.ResponseWriter, req *http.Request,
 	password := req.PostFormValue("password")
 	clientId := ar.GetClient().GetID()
 
-	user, ok := a.store.Users[username]
+	user, ok := a.store.getUser(username)
 	if !ok || user.Password != password {
 		time.Sleep(500 * time.Millisecond)
 		rw.WriteHeader(http.StatusOK)
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	db2 "github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/go-jose/go-jose/v3"
 	"github.com/google/uuid"
 	"github.com/ory/fosite"
@@ -302,6 +303,36 @@ func (s *MemoryStore) DeleteRefreshTokenSession(_ context.Context, signature str
 	return s.saveSnapshot()
 }
 
+// SetUsers replaces the users with those of the users setting that have a
+// password.
+func (s *MemoryStore) SetUsers(users map[string]settings.User) {
+	relations := make(map[string]MemoryUserRelation, len(users))
+	for username, user := range users {
+		if user.Password == nil || *user.Password == "" {
+			continue
+		}
+		isAdmin := false
+		if user.IsAdmin != nil {
+			isAdmin = *user.IsAdmin
+		}
+		relations[username] = MemoryUserRelation{
+			Username: username,
+			Password: *user.Password,
+			Admin:    isAdmin,
+		}
+	}
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+	s.Users = relations
+}
+
+func (s *MemoryStore) getUser(name string) (MemoryUserRelation, bool) {
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+	user, ok := s.Users[name]
+	return user, ok
+}
+
 func (s *MemoryStore) Authenticate(_ context.Context, name string, secret string) (subject string, err error) {
 	s.usersMutex.RLock()
 	defer s.usersMutex.RUnlock()
@@ -23,9 +23,79 @@ func TestPadApi(t *testing.T) {
 			Name: "Test Get Of AttribPool On Existing Pad",
 			Test: testGetOfAttribPoolOnExistingPad,
 		},
+		testutils.TestRunConfig{
+			Name: "Test Undo Without Edits",
+			Test: testUndoWithoutEdits,
+		},
+		testutils.TestRunConfig{
+			Name: "Test Merge Of A Pad That Is No Fork",
+			Test: testMergeOfPadThatIsNoFork,
+		},
+		testutils.TestRunConfig{
+			Name: "Test Get Text At A Point In Time",
+			Test: testGetTextAt,
+		},
 	)
 }
 
+func testGetTextAt(t *testing.T, tsStore testutils.TestDataStore) {
+	var padText = "hallo"
+	testStore := tsStore.ToInitStore()
+	if _, err := tsStore.PadManager.GetPad("123", &padText, nil); err != nil {
+		t.Errorf("Error creating pad")
+	}
+
+	Init(testStore)
+	cases := map[string]int{
+		"at=yesterday":                   400,
+		"at=2000-01-01T00:00:00Z":        404,
+		"at=2100-01-01T00:00:00Z":        200,
+		"at=2100-01-01T00:00:00Z&rev=0":  400,
+		"at=2100-01-01T00:00:00%2B01:00": 200,
+	}
+	for query, status := range cases {
+		resp, _ := testStore.C.Test(httptest.NewRequest("GET", "/pads/123/text?"+query, nil))
+		if resp.StatusCode != status {
+			t.Errorf("Expected status code %d for %s, got %v", status, query, resp.StatusCode)
+		}
+	}
+}
+
+func testMergeOfPadThatIsNoFork(t *testing.T, tsStore testutils.TestDataStore) {
+	var padText = "hallo"
+	testStore := tsStore.ToInitStore()
+	if _, err := tsStore.PadManager.GetPad("123", &padText, nil); err != nil {
+		t.Errorf("Error creating pad")
+	}
+
+	Init(testStore)
+	resp, _ := testStore.C.Test(httptest.NewRequest("GET", "/pads/123/merge", nil))
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected status code 400, got %v", resp.StatusCode)
+	}
+}
+
+func testUndoWithoutEdits(t *testing.T, tsStore testutils.TestDataStore) {
+	var padText = "hallo"
+	testStore := tsStore.ToInitStore()
+	if _, err := tsStore.PadManager.GetPad("123", &padText, nil); err != nil {
+		t.Errorf("Error creating pad")
+	}
+
+	Init(testStore)
+	resp, _ := testStore.C.Test(httptest.NewRequest("POST", "/pads/123/undo", nil))
+	if resp.StatusCode != 400 {
+		t.Errorf("Expected status code 400 without authorId, got %v", resp.StatusCode)
+	}
+
+	for _, action := range []string{"undo", "redo"} {
+		resp, _ = testStore.C.Test(httptest.NewRequest("POST", "/pads/123/"+action+"?authorId=a.nobody", nil))
+		if resp.StatusCode != 400 {
+			t.Errorf("Expected status code 400 for %s of an author without edits, got %v", action, resp.StatusCode)
+		}
+	}
+}
+
 func testGetOnText(t *testing.T, tsStore testutils.TestDataStore) {
 	testStore := tsStore.ToInitStore()
 	Init(testStore)
@@ -0,0 +1,289 @@
+package pad
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ether/etherpad-go/lib"
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	utils2 "github.com/ether/etherpad-go/lib/api/utils"
+	db2 "github.com/ether/etherpad-go/lib/models/db"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/gofiber/fiber/v3"
+)
+
+// EditChatMessageRequest represents the request to edit a chat message
+type EditChatMessageRequest struct {
+	Text     string `json:"text"`
+	AuthorID string `json:"authorID"`
+}
+
+// ChatMessageEditResponse represents an earlier text of a chat message
+type ChatMessageEditResponse struct {
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+	Time    int64  `json:"time"`
+}
+
+// ChatMessageEditsResponse represents the edit history of a chat message
+type ChatMessageEditsResponse struct {
+	Edits []ChatMessageEditResponse `json:"edits"`
+}
+
+// ChatMentionsResponse represents the unread mentions of an author
+type ChatMentionsResponse struct {
+	Unread int `json:"unread"`
+}
+
+// MarkChatReadRequest represents the request to mark the chat as read
+type MarkChatReadRequest struct {
+	AuthorID string `json:"authorID"`
+	Head     int    `json:"head"`
+}
+
+// EditChatMessage godoc
+// @Summary Edit a chat message
+// @Description Replaces the text of a chat message on behalf of its author, keeping the old text in its edit history
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param head path int true "Chat message number"
+// @Param request body EditChatMessageRequest true "New text and the author"
+// @Success 200 {object} ChatMessageResponse
+// @Failure 400 {object} errors.Error
+// @Failure 403 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 409 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/chat/{head} [patch]
+func EditChatMessage(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request EditChatMessageRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if request.Text == "" {
+			return c.Status(400).JSON(errors2.NewInvalidParamError("text is required"))
+		}
+		if request.AuthorID == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("authorID"))
+		}
+		head, err := utils.CheckValidRev(c.Params("head"))
+		if err != nil {
+			return c.Status(400).JSON(errors2.NewInvalidParamError("head"))
+		}
+		pad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId"), true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		before, err := pad.GetChatMessageContext(c.Context(), *head)
+		if err != nil {
+			return chatError(c, err)
+		}
+		msg, err := pad.EditChatMessageContext(c.Context(), *head, request.AuthorID, time.Now().UnixMilli(), request.Text)
+		if err != nil {
+			return chatError(c, err)
+		}
+		initStore.Handler.BroadcastChatMessageUpdate(pad.Id, msg)
+		initStore.Handler.SendChatMentions(pad, append(before.Mentions, msg.Mentions...))
+		return c.JSON(chatMessageResponse(*msg))
+	}
+}
+
+// DeleteChatMessage godoc
+// @Summary Delete a chat message
+// @Description Deletes a chat message as a moderator. It keeps its place in the chat, so replies stay intact, but loses its text and edit history
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param head path int true "Chat message number"
+// @Success 200 {object} ChatMessageResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 409 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/chat/{head} [delete]
+func DeleteChatMessage(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		head, err := utils.CheckValidRev(c.Params("head"))
+		if err != nil {
+			return c.Status(400).JSON(errors2.NewInvalidParamError("head"))
+		}
+		pad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId"), true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		before, err := pad.GetChatMessageContext(c.Context(), *head)
+		if err != nil {
+			return chatError(c, err)
+		}
+		msg, err := pad.DeleteChatMessageContext(c.Context(), *head, nil, true, time.Now().UnixMilli())
+		if err != nil {
+			return chatError(c, err)
+		}
+		initStore.Handler.BroadcastChatMessageUpdate(pad.Id, msg)
+		initStore.Handler.SendChatMentions(pad, before.Mentions)
+		return c.JSON(chatMessageResponse(*msg))
+	}
+}
+
+// GetChatMessageEdits godoc
+// @Summary Get the edit history of a chat message
+// @Description Returns the earlier texts of a chat message, oldest first
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param head path int true "Chat message number"
+// @Success 200 {object} ChatMessageEditsResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/chat/{head}/edits [get]
+func GetChatMessageEdits(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		head, err := utils.CheckValidRev(c.Params("head"))
+		if err != nil {
+			return c.Status(400).JSON(errors2.NewInvalidParamError("head"))
+		}
+		pad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId"), true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		edits, err := pad.GetChatMessageEditsContext(c.Context(), *head)
+		if err != nil {
+			return chatError(c, err)
+		}
+		response := ChatMessageEditsResponse{Edits: make([]ChatMessageEditResponse, 0)}
+		if edits != nil {
+			for _, edit := range *edits {
+				response.Edits = append(response.Edits, ChatMessageEditResponse{
+					Version: edit.Version,
+					Text:    edit.Message,
+					Time:    edit.Time,
+				})
+			}
+		}
+		return c.JSON(response)
+	}
+}
+
+// GetChatMentions godoc
+// @Summary Get the unread chat mentions of an author
+// @Description Returns how many chat messages mention the author that the author has not read yet
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param authorID query string true "Author ID"
+// @Success 200 {object} ChatMentionsResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/chat/mentions [get]
+func GetChatMentions(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		authorId := c.Query("authorID")
+		if authorId == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("authorID"))
+		}
+		pad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId"), true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		unread, err := pad.UnreadChatMentionsContext(c.Context(), authorId)
+		if err != nil {
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		return c.JSON(ChatMentionsResponse{Unread: unread})
+	}
+}
+
+// MarkChatRead godoc
+// @Summary Mark the chat as read
+// @Description Records that an author has read the chat up to a message, clearing the mentions up to there
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param request body MarkChatReadRequest true "Author and the last read message"
+// @Success 200 {object} ChatMentionsResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/chat/read [post]
+func MarkChatRead(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var request MarkChatReadRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if request.AuthorID == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("authorID"))
+		}
+		if request.Head < 0 {
+			return c.Status(400).JSON(errors2.NewInvalidParamError("head"))
+		}
+		pad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId"), true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		if err := pad.MarkChatReadContext(c.Context(), request.AuthorID, request.Head); err != nil {
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		unread, err := pad.UnreadChatMentionsContext(c.Context(), request.AuthorID)
+		if err != nil {
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		initStore.Handler.SendChatMentions(pad, []string{request.AuthorID})
+		return c.JSON(ChatMentionsResponse{Unread: unread})
+	}
+}
+
+// chatError answers a refused chat message change.
+func chatError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, padModel.ErrChatMessageNotFound):
+		return c.Status(404).JSON(errors2.ChatMessageNotFoundError)
+	case errors.Is(err, padModel.ErrChatMessageDeleted):
+		return c.Status(409).JSON(errors2.ChatMessageDeletedError)
+	case errors.Is(err, padModel.ErrChatNotAuthor):
+		return c.Status(403).JSON(errors2.ChatNotAuthorError)
+	case errors.Is(err, padModel.ErrChatTextEmpty):
+		return c.Status(400).JSON(errors2.NewInvalidParamError("text is required"))
+	default:
+		return c.Status(500).JSON(errors2.InternalServerError)
+	}
+}
+
+func chatMessageResponse(msg db2.ChatMessageDBWithDisplayName) ChatMessageResponse {
+	response := ChatMessageResponse{
+		Head:      msg.Head,
+		Text:      msg.Message,
+		ReplyTo:   msg.ReplyTo,
+		Mentions:  msg.Mentions,
+		EditedAt:  msg.EditedAt,
+		Deleted:   msg.DeletedAt != nil,
+		DeletedBy: msg.DeletedBy,
+	}
+	if msg.DisplayName != nil {
+		response.UserName = *msg.DisplayName
+	}
+	if msg.AuthorId != nil {
+		response.AuthorID = *msg.AuthorId
+	}
+	if msg.Time != nil {
+		response.Time = *msg.Time
+	}
+	return response
+}
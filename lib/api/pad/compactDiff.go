@@ -46,7 +46,7 @@ func CompactPad(initStore *lib.InitStore) fiber.Handler {
 			return c.Status(400).JSON(errors2.InvalidRequestError)
 		}
 
-		foundPad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		foundPad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -95,7 +95,7 @@ func CreateDiffHTML(initStore *lib.InitStore) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		padId := c.Params("padId")
 
-		foundPad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		foundPad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -1,11 +1,14 @@
 package pad
 
 import (
+	"context"
 	"strings"
 
 	"github.com/ether/etherpad-go/lib"
 	errors2 "github.com/ether/etherpad-go/lib/api/errors"
 	utils2 "github.com/ether/etherpad-go/lib/api/utils"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
 	"github.com/ether/etherpad-go/lib/hooks/events"
 	db2 "github.com/ether/etherpad-go/lib/models/db"
 	padModel "github.com/ether/etherpad-go/lib/models/pad"
@@ -22,7 +25,7 @@ type handlerError struct {
 // force semantics of the original Etherpad API: reject when the destination
 // already exists unless force is set, in which case the destination pad is
 // removed first.
-func prepareCopyDestination(initStore *lib.InitStore, destinationID string, force bool) *handlerError {
+func prepareCopyDestination(ctx context.Context, initStore *lib.InitStore, destinationID string, force bool) *handlerError {
 	if destinationID == "" {
 		return &handlerError{400, errors2.NewMissingParamError("destinationID")}
 	}
@@ -30,7 +33,7 @@ func prepareCopyDestination(initStore *lib.InitStore, destinationID string, forc
 		return &handlerError{400, errors2.NewInvalidParamError("destinationID is not a valid pad ID")}
 	}
 
-	exists, err := initStore.PadManager.DoesPadExist(destinationID)
+	exists, err := initStore.PadManager.DoesPadExistContext(ctx, destinationID)
 	if err != nil {
 		return &handlerError{500, errors2.InternalServerError}
 	}
@@ -38,7 +41,7 @@ func prepareCopyDestination(initStore *lib.InitStore, destinationID string, forc
 		if !force {
 			return &handlerError{409, errors2.PadAlreadyExistsError}
 		}
-		if err := initStore.PadManager.RemovePad(destinationID); err != nil {
+		if err := initStore.PadManager.RemovePadContext(ctx, destinationID); err != nil {
 			return &handlerError{500, errors2.InternalServerError}
 		}
 	}
@@ -46,10 +49,12 @@ func prepareCopyDestination(initStore *lib.InitStore, destinationID string, forc
 }
 
 // copyPadRecords copies the pad record, all revisions and the chat history of
-// sourceID to destinationID using the DataStore primitives. The destination
-// must not exist (callers go through prepareCopyDestination first).
-func copyPadRecords(initStore *lib.InitStore, sourceID string, destinationID string) error {
-	sourceDB, err := initStore.Store.GetPad(sourceID)
+// sourceID to destinationID using the DataStore primitives, traced as children
+// of the span in ctx. The destination must not exist (callers go through
+// prepareCopyDestination first).
+func copyPadRecords(ctx context.Context, initStore *lib.InitStore, sourceID string, destinationID string) error {
+	store := db.WithContext(initStore.Store, ctx)
+	sourceDB, err := store.GetPad(sourceID)
 	if err != nil {
 		return err
 	}
@@ -59,13 +64,13 @@ func copyPadRecords(initStore *lib.InitStore, sourceID string, destinationID str
 	// Read-only IDs must stay unique per pad; the destination gets its own on demand.
 	destinationDB.ReadOnlyId = nil
 
-	if err := initStore.Store.CreatePad(destinationID, destinationDB); err != nil {
+	if err := store.CreatePad(destinationID, destinationDB); err != nil {
 		return err
 	}
 
 	// Copy the full revision history
 	if sourceDB.Head >= 0 {
-		revisions, err := initStore.Store.GetRevisions(sourceID, 0, sourceDB.Head)
+		revisions, err := store.GetRevisions(sourceID, 0, sourceDB.Head)
 		if err != nil {
 			return err
 		}
@@ -74,7 +79,7 @@ func copyPadRecords(initStore *lib.InitStore, sourceID string, destinationID str
 			if rev.Pool != nil {
 				pool = *rev.Pool
 			}
-			if err := initStore.Store.SaveRevision(
+			if err := store.SaveRevision(
 				destinationID, rev.RevNum, rev.Changeset, rev.AText, pool, rev.AuthorId, rev.Timestamp,
 			); err != nil {
 				return err
@@ -84,7 +89,7 @@ func copyPadRecords(initStore *lib.InitStore, sourceID string, destinationID str
 
 	// Copy the chat history
 	if sourceDB.ChatHead >= 0 {
-		messages, err := initStore.Store.GetChatsOfPad(sourceID, 0, sourceDB.ChatHead)
+		messages, err := store.GetChatsOfPad(sourceID, 0, sourceDB.ChatHead)
 		if err != nil {
 			return err
 		}
@@ -94,9 +99,7 @@ func copyPadRecords(initStore *lib.InitStore, sourceID string, destinationID str
 				if msg.Time != nil {
 					timestamp = *msg.Time
 				}
-				if err := initStore.Store.SaveChatMessage(
-					destinationID, msg.Head, msg.AuthorId, timestamp, msg.Message,
-				); err != nil {
+				if err := copyChatMessage(store, sourceID, destinationID, msg, timestamp); err != nil {
 					return err
 				}
 			}
@@ -108,15 +111,56 @@ func copyPadRecords(initStore *lib.InitStore, sourceID string, destinationID str
 	return nil
 }
 
+// copyChatMessage writes msg of the pad sourceID to the pad destinationID
+// with its replies, mentions and deletion. Its edits are replayed, so the
+// copy has the same edit history.
+func copyChatMessage(store db.DataStore, sourceID, destinationID string, msg db2.ChatMessageDBWithDisplayName, timestamp int64) error {
+	edits, err := store.GetChatMessageEdits(sourceID, msg.Head)
+	if err != nil {
+		return err
+	}
+	texts := []string{msg.Message}
+	if edits != nil && len(*edits) > 0 {
+		texts = texts[:0]
+		for _, edit := range *edits {
+			texts = append(texts, edit.Message)
+		}
+		texts = append(texts, msg.Message)
+	}
+	if err := store.SaveChatMessage(destinationID, msg.Head, msg.AuthorId, timestamp, texts[0]); err != nil {
+		return err
+	}
+	for i, text := range texts[1:] {
+		if err := store.EditChatMessage(destinationID, msg.Head, text, (*edits)[i].Time); err != nil {
+			return err
+		}
+	}
+	if msg.ReplyTo != nil {
+		if err := store.SetChatReplyTo(destinationID, msg.Head, *msg.ReplyTo); err != nil {
+			return err
+		}
+	}
+	if len(msg.Mentions) > 0 {
+		if err := store.SaveChatMentions(destinationID, msg.Head, msg.Mentions); err != nil {
+			return err
+		}
+	}
+	if msg.DeletedAt != nil {
+		return store.DeleteChatMessage(destinationID, msg.Head, msg.DeletedBy, *msg.DeletedAt)
+	}
+	return nil
+}
+
 // firePadCopy notifies plugins that a pad was copied, mirroring the original
 // Etherpad padCopy hook which is fired with the source and destination pads.
-func firePadCopy(initStore *lib.InitStore, srcPad *padModel.Pad, dstId string) {
-	dstPad, err := initStore.PadManager.GetPad(dstId, nil, nil)
+// The hook is traced as a child of the span in ctx.
+func firePadCopy(ctx context.Context, initStore *lib.InitStore, srcPad *padModel.Pad, dstId string) {
+	dstPad, err := initStore.PadManager.GetPadContext(ctx, dstId, nil, nil)
 	if err != nil {
 		initStore.Logger.Errorf("padCopy hook: failed to load destination pad %s: %v", dstId, err)
 		return
 	}
-	initStore.Hooks.ExecutePadCopyHooks(&events.PadCopyContext{
+	initStore.Hooks.ExecuteHooksContext(ctx, hooks.PadCopyString, &events.PadCopyContext{
 		SrcPad: srcPad,
 		DstPad: dstPad,
 		SrcId:  srcPad.Id,
@@ -154,21 +198,21 @@ func CopyPad(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Verify source pad exists
-		srcPad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		srcPad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
-		if hErr := prepareCopyDestination(initStore, request.DestinationID, request.Force); hErr != nil {
+		if hErr := prepareCopyDestination(c.Context(), initStore, request.DestinationID, request.Force); hErr != nil {
 			return c.Status(hErr.status).JSON(hErr.body)
 		}
 
-		if err := copyPadRecords(initStore, padId, request.DestinationID); err != nil {
+		if err := copyPadRecords(c.Context(), initStore, padId, request.DestinationID); err != nil {
 			initStore.Logger.Errorf("Error copying pad %s to %s: %v", padId, request.DestinationID, err)
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
 
-		firePadCopy(initStore, srcPad, request.DestinationID)
+		firePadCopy(c.Context(), initStore, srcPad, request.DestinationID)
 
 		return c.JSON(PadIDResponse{
 			PadID: request.DestinationID,
@@ -200,12 +244,12 @@ func CopyPadWithoutHistory(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the source pad
-		sourcePad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		sourcePad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
-		if hErr := prepareCopyDestination(initStore, request.DestinationID, request.Force); hErr != nil {
+		if hErr := prepareCopyDestination(c.Context(), initStore, request.DestinationID, request.Force); hErr != nil {
 			return c.Status(hErr.status).JSON(hErr.body)
 		}
 
@@ -218,11 +262,11 @@ func CopyPadWithoutHistory(initStore *lib.InitStore) fiber.Handler {
 			authorId = &request.AuthorId
 		}
 
-		if _, err := initStore.PadManager.GetPad(request.DestinationID, &text, authorId); err != nil {
+		if _, err := initStore.PadManager.GetPadContext(c.Context(), request.DestinationID, &text, authorId); err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
 
-		firePadCopy(initStore, sourcePad, request.DestinationID)
+		firePadCopy(c.Context(), initStore, sourcePad, request.DestinationID)
 
 		return c.JSON(PadIDResponse{
 			PadID: request.DestinationID,
@@ -254,24 +298,24 @@ func MovePad(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Verify source pad exists
-		srcPad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		srcPad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
-		if hErr := prepareCopyDestination(initStore, request.DestinationID, request.Force); hErr != nil {
+		if hErr := prepareCopyDestination(c.Context(), initStore, request.DestinationID, request.Force); hErr != nil {
 			return c.Status(hErr.status).JSON(hErr.body)
 		}
 
-		if err := copyPadRecords(initStore, padId, request.DestinationID); err != nil {
+		if err := copyPadRecords(c.Context(), initStore, padId, request.DestinationID); err != nil {
 			initStore.Logger.Errorf("Error copying pad %s to %s: %v", padId, request.DestinationID, err)
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
 
-		firePadCopy(initStore, srcPad, request.DestinationID)
+		firePadCopy(c.Context(), initStore, srcPad, request.DestinationID)
 
 		// Remove the source pad after a successful copy (fires padRemove)
-		if err := initStore.PadManager.RemovePad(padId); err != nil {
+		if err := initStore.PadManager.RemovePadContext(c.Context(), padId); err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
 
@@ -301,7 +345,7 @@ func GetPublicStatus(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -339,7 +383,7 @@ func SetPublicStatus(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
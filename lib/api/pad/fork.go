@@ -0,0 +1,235 @@
+package pad
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/ether/etherpad-go/lib"
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	utils2 "github.com/ether/etherpad-go/lib/api/utils"
+	io2 "github.com/ether/etherpad-go/lib/io"
+	db2 "github.com/ether/etherpad-go/lib/models/db"
+	"github.com/ether/etherpad-go/lib/pad"
+	"github.com/gofiber/fiber/v3"
+)
+
+// ForkPadRequest represents the request to fork a pad
+type ForkPadRequest struct {
+	DestinationID string `json:"destinationID"`
+	Force         bool   `json:"force"`
+}
+
+// MergeRequest represents the request to merge a fork into its source
+type MergeRequest struct {
+	AuthorId string `json:"authorId"`
+	Force    bool   `json:"force"`
+}
+
+// MergePreviewResponse represents the changes a merge would make to the source
+type MergePreviewResponse struct {
+	Fork      db2.PadFork    `json:"fork"`
+	HTML      string         `json:"html"`
+	Authors   []string       `json:"authors"`
+	Conflicts []pad.Conflict `json:"conflicts"`
+}
+
+// MergeResponse represents the revision of the source a merge wrote
+type MergeResponse struct {
+	Rev       int            `json:"rev"`
+	Conflicts []pad.Conflict `json:"conflicts"`
+}
+
+// MergeConflictResponse represents a merge refused because of conflicts
+type MergeConflictResponse struct {
+	errors2.Error
+	Conflicts []pad.Conflict `json:"conflicts"`
+}
+
+// ForkPad godoc
+// @Summary Fork a pad
+// @Description Copies a pad including its history to a new pad that remembers the source and the revision it was forked at, so it can be merged back. Fails if the destination exists unless force is set, and if it is the source or a pad the source was forked from.
+// @Tags Pads
+// @Accept json
+// @Produce json
+// @Param padId path string true "Source Pad ID"
+// @Param request body ForkPadRequest true "Destination ID and force flag"
+// @Success 200 {object} PadIDResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 409 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/fork [post]
+func ForkPad(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request ForkPadRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+
+		srcPad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		// Replacing the source or a pad it was forked from would delete the
+		// source or make the forks a cycle.
+		chain, err := initStore.PadManager.ForkChain(srcPad)
+		if err != nil {
+			initStore.Logger.Errorf("Error reading the sources of pad %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		if slices.Contains(chain, request.DestinationID) {
+			return c.Status(400).JSON(errors2.ForkOntoSourceError)
+		}
+
+		if hErr := prepareCopyDestination(c.Context(), initStore, request.DestinationID, request.Force); hErr != nil {
+			return c.Status(hErr.status).JSON(hErr.body)
+		}
+
+		// The fork keeps the source id, and c.Params is only valid during
+		// the request.
+		sourceId := strings.Clone(padId)
+		if err := initStore.Handler.ForkPad(sourceId, request.DestinationID, func() error {
+			return copyPadRecords(c.Context(), initStore, sourceId, request.DestinationID)
+		}); err != nil {
+			initStore.Logger.Errorf("Error forking pad %s to %s: %v", padId, request.DestinationID, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+
+		firePadCopy(c.Context(), initStore, srcPad, request.DestinationID)
+
+		return c.JSON(PadIDResponse{
+			PadID: request.DestinationID,
+		})
+	}
+}
+
+// PreviewMerge godoc
+// @Summary Preview merging a fork
+// @Description Returns the changes merging the fork would make to its source as diff HTML, and the regions both changed
+// @Tags Pads
+// @Accept json
+// @Produce json
+// @Param padId path string true "Fork Pad ID"
+// @Param authorId query string false "Author ID the deletions are attributed to"
+// @Success 200 {object} MergePreviewResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/merge [get]
+func PreviewMerge(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		merge, status, body := prepareMerge(c.Context(), initStore, padId)
+		if merge == nil {
+			return c.Status(status).JSON(body)
+		}
+
+		diffAText, authors, err := merge.Preview(c.Query("authorId"))
+		if err != nil {
+			initStore.Logger.Errorf("Error creating merge preview for pad %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+
+		// Rendered like diffHTML, from a shallow copy of the source carrying
+		// the diff atext.
+		padWithDiff := *merge.Source
+		padWithDiff.AText = *diffAText
+		authorColors := buildAuthorColors(&merge.Source.Pool, initStore.AuthorManager)
+		exporter := io2.NewExportHtml(initStore.PadManager, initStore.AuthorManager, initStore.Hooks)
+		html, err := exporter.GetPadHTML(&padWithDiff, nil, authorColors)
+		if err != nil {
+			initStore.Logger.Errorf("Error rendering merge preview for pad %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+
+		return c.JSON(MergePreviewResponse{
+			Fork:      *merge.Fork.Fork,
+			HTML:      html,
+			Authors:   authors,
+			Conflicts: merge.Conflicts,
+		})
+	}
+}
+
+// MergeFork godoc
+// @Summary Merge a fork into its source
+// @Description Rebases the changes of the fork since it was forked or last merged onto its source and applies them as a single revision. Conflicting merges are refused unless force is set.
+// @Tags Pads
+// @Accept json
+// @Produce json
+// @Param padId path string true "Fork Pad ID"
+// @Param request body MergeRequest true "Author ID and force flag"
+// @Success 200 {object} MergeResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 409 {object} MergeConflictResponse
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/merge [post]
+func MergeFork(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request MergeRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if request.AuthorId == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("authorId"))
+		}
+
+		fork, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		merge, rev, err := initStore.Handler.MergeFork(fork, request.AuthorId, request.Force)
+		var conflictErr *pad.MergeConflictError
+		switch {
+		case errors.Is(err, pad.ErrNotAFork):
+			return c.Status(400).JSON(errors2.NotAForkError)
+		case errors.Is(err, pad.ErrForkSourceMissing):
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		case errors.As(err, &conflictErr):
+			return c.Status(409).JSON(MergeConflictResponse{
+				Error:     errors2.MergeConflictError,
+				Conflicts: conflictErr.Conflicts,
+			})
+		case errors.Is(err, pad.ErrNothingToMerge):
+			return c.Status(400).JSON(errors2.NothingToMergeError)
+		case err != nil:
+			initStore.Logger.Errorf("Error merging pad %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+
+		return c.JSON(MergeResponse{
+			Rev:       rev,
+			Conflicts: merge.Conflicts,
+		})
+	}
+}
+
+// prepareMerge prepares merging the fork padId, or returns the status and
+// body of the error response.
+func prepareMerge(ctx context.Context, initStore *lib.InitStore, padId string) (*pad.Merge, int, errors2.Error) {
+	fork, err := utils2.GetPadSafeContext(ctx, padId, true, nil, nil, initStore.PadManager)
+	if err != nil {
+		return nil, 404, errors2.PadNotFoundError
+	}
+	merge, err := initStore.PadManager.PrepareMerge(fork)
+	switch {
+	case errors.Is(err, pad.ErrNotAFork):
+		return nil, 400, errors2.NotAForkError
+	case errors.Is(err, pad.ErrForkSourceMissing):
+		return nil, 404, errors2.PadNotFoundError
+	case err != nil:
+		initStore.Logger.Errorf("Error preparing merge of pad %s: %v", padId, err)
+		return nil, 500, errors2.InternalServerError
+	}
+	return merge, 0, errors2.Error{}
+}
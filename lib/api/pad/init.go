@@ -27,12 +27,13 @@ type SetTextRequest struct {
 
 // GetPadText godoc
 // @Summary Get pad text
-// @Description Returns the current text of a pad, optionally for a specific revision
+// @Description Returns the current text of a pad, optionally for a specific revision or the last revision at or before a point in time
 // @Tags Pads
 // @Accept json
 // @Produce json
 // @Param padId path string true "Pad ID"
 // @Param rev query string false "Revision number"
+// @Param at query string false "RFC 3339 timestamp, selects the last revision at or before it"
 // @Success 200 {object} TextResponse
 // @Failure 400 {object} errors.Error
 // @Failure 404 {object} errors.Error
@@ -41,23 +42,16 @@ type SetTextRequest struct {
 // @Router /admin/api/pads/{padId}/text [get]
 func GetPadText(initStore *lib.InitStore) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		foundPad, err := utils2.GetPadSafe(c.Params("padId", ""), true, nil, nil, initStore.PadManager)
+		foundPad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId", ""), true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
-		head := foundPad.Head
-
-		optRev := c.Query("rev")
-		if optRev != "" {
-			revNum, err := utils.CheckValidRev(optRev)
-			if err != nil {
-				return c.Status(400).JSON(errors2.InvalidRevisionError)
-			}
-			if *revNum > head {
-				return c.Status(400).JSON(errors2.RevisionHigherThanHeadError)
-			}
-
+		revNum, revErr := resolveRevision(foundPad, c.Query("rev"), c.Query("at"))
+		if revErr != nil {
+			return c.Status(revErr.Error).JSON(revErr)
+		}
+		if revNum != nil {
 			foundText := foundPad.GetInternalRevisionAText(*revNum)
 			if foundText == nil {
 				return c.Status(500).JSON(errors2.InternalApiError)
@@ -91,7 +85,7 @@ func GetPadText(initStore *lib.InitStore) fiber.Handler {
 func GetAttributePool(initStore *lib.InitStore) fiber.Handler {
 	return func(ctx fiber.Ctx) error {
 		var padIdToFind = ctx.Params("padId")
-		var padFound, err = utils2.GetPadSafe(padIdToFind, true, nil, nil, initStore.PadManager)
+		var padFound, err = utils2.GetPadSafeContext(ctx.Context(), padIdToFind, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return ctx.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -125,7 +119,7 @@ func GetRevisionChangeset(initStore *lib.InitStore) fiber.Handler {
 			return ctx.Status(400).JSON(errors2.InvalidRevisionError)
 		}
 
-		var foundPad, errorForPad2 = utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		var foundPad, errorForPad2 = utils2.GetPadSafeContext(ctx.Context(), padId, true, nil, nil, initStore.PadManager)
 		if errorForPad2 != nil {
 			return ctx.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -168,7 +162,7 @@ func SetPadText(initStore *lib.InitStore) fiber.Handler {
 			return ctx.Status(400).JSON(errors2.InvalidRequestError)
 		}
 
-		var retrievedPad, errPadSafe = utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		var retrievedPad, errPadSafe = utils2.GetPadSafeContext(ctx.Context(), padId, true, nil, nil, initStore.PadManager)
 		if errPadSafe != nil {
 			return ctx.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -210,6 +204,8 @@ func Init(initStore *lib.InitStore) {
 
 	// Pad operations
 	initStore.PrivateAPI.Post("/pads/:padId/restoreRevision", RestoreRevision(initStore))
+	initStore.PrivateAPI.Post("/pads/:padId/undo", UndoAuthor(initStore))
+	initStore.PrivateAPI.Post("/pads/:padId/redo", RedoAuthor(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/compact", CompactPad(initStore))
 	initStore.PrivateAPI.Get("/pads/:padId/diffHTML", CreateDiffHTML(initStore))
 	initStore.PrivateAPI.Get("/pads/:padId/readOnlyID", GetReadOnlyID(initStore))
@@ -220,6 +216,23 @@ func Init(initStore *lib.InitStore) {
 	initStore.PrivateAPI.Get("/pads/:padId/html", GetHTML(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/html", SetHTML(initStore))
 
+	// Sheet history
+	initStore.PrivateAPI.Get("/sheets/:padId/revisions", ListSheetRevisions(initStore))
+	initStore.PrivateAPI.Get("/sheets/:padId/revisions/:rev", GetSheetRevision(initStore))
+	initStore.PrivateAPI.Get("/sheets/:padId/diff", DiffSheetRevisions(initStore))
+	initStore.PrivateAPI.Post("/sheets/:padId/restore", RestoreSheetRevision(initStore))
+
+	// Sheet cells and worksheets
+	initStore.PrivateAPI.Post("/sheets/:padId", CreateSheetDocument(initStore))
+	initStore.PrivateAPI.Get("/sheets/:padId/sheets", ListWorksheets(initStore))
+	initStore.PrivateAPI.Post("/sheets/:padId/sheets", CreateWorksheet(initStore))
+	initStore.PrivateAPI.Get("/sheets/:padId/sheets/:sheetId/range/:range", GetSheetRange(initStore))
+	initStore.PrivateAPI.Post("/sheets/:padId/ops", SubmitSheetOps(initStore))
+
+	// Conversion between sheets and text pads
+	initStore.PrivateAPI.Post("/sheets/:padId/toPad", SheetToPad(initStore))
+	initStore.PrivateAPI.Post("/pads/:padId/toSheet", PadToSheet(initStore))
+
 	// Users in pad
 	initStore.PrivateAPI.Get("/pads/:padId/users", GetPadUsers(initStore))
 	initStore.PrivateAPI.Get("/pads/:padId/usersCount", GetPadUsersCount(initStore))
@@ -232,11 +245,19 @@ func Init(initStore *lib.InitStore) {
 	// Chat
 	initStore.PrivateAPI.Get("/pads/:padId/chatHistory", GetChatHistory(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/chat", AppendChatMessage(initStore))
+	initStore.PrivateAPI.Get("/pads/:padId/chat/mentions", GetChatMentions(initStore))
+	initStore.PrivateAPI.Post("/pads/:padId/chat/read", MarkChatRead(initStore))
+	initStore.PrivateAPI.Patch("/pads/:padId/chat/:head", EditChatMessage(initStore))
+	initStore.PrivateAPI.Delete("/pads/:padId/chat/:head", DeleteChatMessage(initStore))
+	initStore.PrivateAPI.Get("/pads/:padId/chat/:head/edits", GetChatMessageEdits(initStore))
 
 	// Copy/move and public status
 	initStore.PrivateAPI.Post("/pads/:padId/copy", CopyPad(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/copyWithoutHistory", CopyPadWithoutHistory(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/move", MovePad(initStore))
+	initStore.PrivateAPI.Post("/pads/:padId/fork", ForkPad(initStore))
+	initStore.PrivateAPI.Get("/pads/:padId/merge", PreviewMerge(initStore))
+	initStore.PrivateAPI.Post("/pads/:padId/merge", MergeFork(initStore))
 	initStore.PrivateAPI.Get("/pads/:padId/publicStatus", GetPublicStatus(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/sendClientsMessage", SendClientsMessage(initStore))
 	initStore.PrivateAPI.Post("/pads/:padId/publicStatus", SetPublicStatus(initStore))
@@ -36,7 +36,7 @@ func SendClientsMessage(initStore *lib.InitStore) fiber.Handler {
 			return c.Status(400).JSON(errors2.NewMissingParamError("msg"))
 		}
 
-		if _, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager); err != nil {
+		if _, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager); err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
@@ -9,6 +9,7 @@ import (
 	utils2 "github.com/ether/etherpad-go/lib/api/utils"
 	"github.com/ether/etherpad-go/lib/apool"
 	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/db"
 	io2 "github.com/ether/etherpad-go/lib/io"
 	padModel "github.com/ether/etherpad-go/lib/models/pad"
 	"github.com/ether/etherpad-go/lib/utils"
@@ -110,7 +111,7 @@ func RestoreRevision(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -193,7 +194,7 @@ func GetReadOnlyID(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Verify pad exists
-		_, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		_, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -250,7 +251,7 @@ func ListAuthorsOfPad(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -298,7 +299,7 @@ func GetChatHead(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -325,7 +326,7 @@ func GetRevisionsCount(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -352,7 +353,7 @@ func GetLastEdited(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -385,13 +386,13 @@ func DeletePad(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Verify pad exists
-		_, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		_, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
 		// Delete the pad using PadManager
-		err = initStore.PadManager.RemovePad(padId)
+		err = initStore.PadManager.RemovePadContext(c.Context(), padId)
 		if err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
@@ -408,6 +409,7 @@ func DeletePad(initStore *lib.InitStore) fiber.Handler {
 // @Produce json
 // @Param padId path string true "Pad ID"
 // @Param rev query string false "Revision number"
+// @Param at query string false "RFC 3339 timestamp, selects the last revision at or before it"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} errors.Error
 // @Failure 404 {object} errors.Error
@@ -419,22 +421,14 @@ func GetHTML(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
-		revStr := c.Query("rev")
-		var rev *int
-		if revStr != "" {
-			revNum, err := utils.CheckValidRev(revStr)
-			if err != nil {
-				return c.Status(400).JSON(errors2.InvalidRevisionError)
-			}
-			if *revNum > pad.Head {
-				return c.Status(400).JSON(errors2.RevisionHigherThanHeadError)
-			}
-			rev = revNum
+		rev, revErr := resolveRevision(pad, c.Query("rev"), c.Query("at"))
+		if revErr != nil {
+			return c.Status(revErr.Error).JSON(revErr)
 		}
 
 		// Render the full HTML document through the real exporter so that
@@ -502,7 +496,7 @@ func SetHTML(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -521,10 +515,16 @@ func SetHTML(initStore *lib.InitStore) fiber.Handler {
 
 // ChatMessageResponse represents a chat message in the response
 type ChatMessageResponse struct {
-	Text     string `json:"text"`
-	AuthorID string `json:"authorID"`
-	Time     int64  `json:"time"`
-	UserName string `json:"userName"`
+	Head      int      `json:"head"`
+	Text      string   `json:"text"`
+	AuthorID  string   `json:"authorID"`
+	Time      int64    `json:"time"`
+	UserName  string   `json:"userName"`
+	ReplyTo   *int     `json:"replyTo,omitempty"`
+	Mentions  []string `json:"mentions,omitempty"`
+	EditedAt  *int64   `json:"editedAt,omitempty"`
+	Deleted   bool     `json:"deleted,omitempty"`
+	DeletedBy *string  `json:"deletedBy,omitempty"`
 }
 
 // ChatHistoryResponse represents the response with chat history
@@ -551,7 +551,7 @@ func GetChatHistory(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -591,7 +591,7 @@ func GetChatHistory(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get chat messages
-		messages, err := pad.GetChatMessages(start, end)
+		messages, err := pad.GetChatMessagesContext(c.Context(), start, end)
 		if err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
@@ -600,24 +600,7 @@ func GetChatHistory(initStore *lib.InitStore) fiber.Handler {
 		responseMessages := make([]ChatMessageResponse, 0)
 		if messages != nil {
 			for _, msg := range *messages {
-				userName := ""
-				if msg.DisplayName != nil {
-					userName = *msg.DisplayName
-				}
-				authorID := ""
-				if msg.AuthorId != nil {
-					authorID = *msg.AuthorId
-				}
-				var timestamp int64 = 0
-				if msg.Time != nil {
-					timestamp = *msg.Time
-				}
-				responseMessages = append(responseMessages, ChatMessageResponse{
-					Text:     msg.Message,
-					AuthorID: authorID,
-					Time:     timestamp,
-					UserName: userName,
-				})
+				responseMessages = append(responseMessages, chatMessageResponse(msg))
 			}
 		}
 
@@ -632,11 +615,12 @@ type AppendChatMessageRequest struct {
 	Text     string `json:"text"`
 	AuthorID string `json:"authorID"`
 	Time     int64  `json:"time"`
+	ReplyTo  *int   `json:"replyTo,omitempty"`
 }
 
 // AppendChatMessage godoc
 // @Summary Append a chat message
-// @Description Creates a chat message for the pad
+// @Description Creates a chat message for the pad, optionally as a reply to another message
 // @Tags Chat
 // @Accept json
 // @Produce json
@@ -666,16 +650,17 @@ func AppendChatMessage(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
 
 		// Append chat message
-		_, err = pad.AppendChatMessage(&request.AuthorID, request.Time, request.Text)
+		msg, err := pad.PostChatMessageContext(c.Context(), &request.AuthorID, request.Time, request.Text, request.ReplyTo)
 		if err != nil {
-			return c.Status(500).JSON(errors2.InternalServerError)
+			return chatError(c, err)
 		}
+		initStore.Handler.SendChatMentions(pad, msg.Mentions)
 
 		return c.SendStatus(200)
 	}
@@ -702,7 +687,7 @@ func GetSavedRevisionsCount(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -734,7 +719,7 @@ func ListSavedRevisions(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -778,7 +763,7 @@ func SaveRevision(initStore *lib.InitStore) fiber.Handler {
 		c.Bind().Body(&request)
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -789,7 +774,7 @@ func SaveRevision(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Create author for API call
-		apiAuthor, err := initStore.AuthorManager.CreateAuthor(nil)
+		apiAuthor, err := initStore.AuthorManager.WithContext(c.Context()).CreateAuthor(nil)
 		if err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
@@ -839,7 +824,7 @@ func CreatePad(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Check if pad already exists
-		exists, err := initStore.PadManager.DoesPadExist(padId)
+		exists, err := initStore.PadManager.DoesPadExistContext(c.Context(), padId)
 		if err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
@@ -857,7 +842,7 @@ func CreatePad(initStore *lib.InitStore) fiber.Handler {
 			authorPtr = &request.AuthorId
 		}
 
-		_, err = initStore.PadManager.GetPad(padId, textPtr, authorPtr)
+		_, err = initStore.PadManager.GetPadContext(c.Context(), padId, textPtr, authorPtr)
 		if err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
@@ -899,7 +884,7 @@ func AppendText(initStore *lib.InitStore) fiber.Handler {
 		}
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -952,7 +937,7 @@ func GetRevisionChangesetOptional(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Get the pad
-		pad, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		pad, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -1004,7 +989,7 @@ type AllPadsResponse struct {
 // @Router /admin/api/pads [get]
 func ListAllPads(initStore *lib.InitStore) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		pads, err := initStore.Store.GetPadIds()
+		pads, err := db.WithContext(initStore.Store, c.Context()).GetPadIds()
 		if err != nil {
 			return c.Status(500).JSON(errors2.InternalServerError)
 		}
@@ -1050,7 +1035,7 @@ func GetPadUsers(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Verify pad exists
-		_, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		_, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
@@ -1058,10 +1043,11 @@ func GetPadUsers(initStore *lib.InitStore) fiber.Handler {
 		// Get users from session store
 		users := initStore.Handler.GetPadUsers(padId)
 
+		authorManager := initStore.AuthorManager.WithContext(c.Context())
 		padUsers := make([]PadUser, 0)
 		for _, user := range users {
 			// Get author info for color and name
-			authorInfo, err := initStore.AuthorManager.GetAuthor(user.AuthorId)
+			authorInfo, err := authorManager.GetAuthor(user.AuthorId)
 			colorId := ""
 			name := ""
 			if err == nil && authorInfo != nil {
@@ -1099,7 +1085,7 @@ func GetPadUsersCount(initStore *lib.InitStore) fiber.Handler {
 		padId := c.Params("padId")
 
 		// Verify pad exists
-		_, err := utils2.GetPadSafe(padId, true, nil, nil, initStore.PadManager)
+		_, err := utils2.GetPadSafeContext(c.Context(), padId, true, nil, nil, initStore.PadManager)
 		if err != nil {
 			return c.Status(404).JSON(errors2.PadNotFoundError)
 		}
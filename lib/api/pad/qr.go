@@ -20,13 +20,13 @@ func HandlePadQr(c fiber.Ctx, store *lib.InitStore) error {
 	isReadOnlyRoute := store.ReadOnlyManager.IsReadOnlyID(&rawPadID)
 
 	if isReadOnlyRoute {
-		padID, err := store.ReadOnlyManager.GetPadId(rawPadID)
+		padID, err := store.ReadOnlyManager.WithContext(c.Context()).GetPadId(rawPadID)
 		if err != nil || padID == nil {
 			return c.SendStatus(fiber.StatusNotFound)
 		}
 		targetPadID = *padID
 	} else {
-		exists, err := store.PadManager.DoesPadExist(rawPadID)
+		exists, err := store.PadManager.DoesPadExistContext(c.Context(), rawPadID)
 		if err != nil || exists == nil || !*exists {
 			return c.SendStatus(fiber.StatusNotFound)
 		}
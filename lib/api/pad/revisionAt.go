@@ -0,0 +1,44 @@
+package pad
+
+import (
+	"errors"
+
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/utils"
+)
+
+// resolveRevision returns the revision selected by the rev or at query
+// parameter, or nil for the head. at is an RFC 3339 timestamp and selects the
+// last revision made at or before it. The returned error carries its HTTP
+// status in Error.
+func resolveRevision(p *padModel.Pad, rev string, at string) (*int, *errors2.Error) {
+	if rev != "" && at != "" {
+		return nil, &errors2.RevisionAndTimestampError
+	}
+	if rev != "" {
+		revNum, err := utils.CheckValidRev(rev)
+		if err != nil {
+			return nil, &errors2.InvalidRevisionError
+		}
+		if *revNum > p.Head {
+			return nil, &errors2.RevisionHigherThanHeadError
+		}
+		return revNum, nil
+	}
+	if at != "" {
+		atTime, err := utils.ParseRevisionTime(at)
+		if err != nil {
+			return nil, &errors2.InvalidTimestampError
+		}
+		revNum, err := p.GetRevisionAt(*atTime)
+		if errors.Is(err, padModel.ErrNoRevisionAt) {
+			return nil, &errors2.NoRevisionAtError
+		}
+		if err != nil {
+			return nil, &errors2.InternalServerError
+		}
+		return &revNum, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,354 @@
+package pad
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ether/etherpad-go/lib"
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/sheetdoc"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxRangeCells caps the number of cells one range read returns.
+const MaxRangeCells = 100000
+
+// SheetInfo represents one worksheet of a sheet document
+type SheetInfo struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SheetListResponse represents the worksheets of a sheet document
+type SheetListResponse struct {
+	Head   int         `json:"head"`
+	Sheets []SheetInfo `json:"sheets"`
+}
+
+// CreateSheetDocumentRequest represents the request to create a sheet document
+type CreateSheetDocumentRequest struct {
+	AuthorId string `json:"authorId"`
+}
+
+// CreateWorksheetRequest represents the request to add a worksheet
+type CreateWorksheetRequest struct {
+	Name     string `json:"name"`
+	Index    *int   `json:"index"`
+	AuthorId string `json:"authorId"`
+}
+
+// CreateWorksheetResponse represents the added worksheet and the new head
+type CreateWorksheetResponse struct {
+	Id  string `json:"id"`
+	Rev int    `json:"rev"`
+}
+
+// SheetRangeCell represents one cell of a range read. Value is the computed
+// value of a formula as last calculated by a client, else the raw content.
+type SheetRangeCell struct {
+	Raw       string            `json:"raw"`
+	Value     string            `json:"value"`
+	ValueType string            `json:"valueType,omitempty"`
+	Props     map[string]string `json:"props,omitempty"`
+}
+
+// SheetRangeResponse represents the cells of a range, row by row
+type SheetRangeResponse struct {
+	Sheet string             `json:"sheet"`
+	Range string             `json:"range"`
+	Head  int                `json:"head"`
+	Rows  [][]SheetRangeCell `json:"rows"`
+}
+
+// SheetOpsRequest represents a batch of sheet operations. The ops are
+// composed one after the other against baseRev, which defaults to the head.
+type SheetOpsRequest struct {
+	BaseRev  *int       `json:"baseRev"`
+	AuthorId string     `json:"authorId"`
+	Ops      []sheet.Op `json:"ops"`
+}
+
+// SheetOpsResponse represents the applied (rebased) operations and the new head
+type SheetOpsResponse struct {
+	Rev int        `json:"rev"`
+	Ops []sheet.Op `json:"ops"`
+}
+
+// CreateSheetDocument godoc
+// @Summary Create a sheet
+// @Description Creates a new spreadsheet pad with a single empty worksheet
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param request body CreateSheetDocumentRequest false "Author ID"
+// @Success 200 {object} SheetListResponse
+// @Failure 400 {object} errors.Error
+// @Failure 409 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId} [post]
+func CreateSheetDocument(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request CreateSheetDocumentRequest
+		// Body is optional
+		c.Bind().Body(&request)
+
+		if !initStore.PadManager.IsValidPadId(padId) {
+			return c.Status(400).JSON(errors2.NewInvalidParamError("padID"))
+		}
+		exists, err := initStore.PadManager.DoesPadExistContext(c.Context(), padId)
+		if err != nil {
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		if exists != nil && *exists {
+			return c.Status(409).JSON(errors2.NewInvalidParamError("pad already exists"))
+		}
+
+		var authorPtr *string
+		if request.AuthorId != "" {
+			authorPtr = &request.AuthorId
+		}
+		if _, err := initStore.PadManager.GetTypedPad(padId, "sheet", authorPtr); err != nil {
+			initStore.Logger.Errorf("Error creating sheet %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		snap, head, err := initStore.Handler.SheetManager().Snapshot(padId)
+		if err != nil {
+			initStore.Logger.Errorf("Error creating sheet %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		return c.JSON(sheetList(sheet.WorkbookFromSnapshot(snap), head))
+	}
+}
+
+// ListWorksheets godoc
+// @Summary List the worksheets of a sheet
+// @Description Returns the id and name of every worksheet of a spreadsheet pad, in tab order
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Success 200 {object} SheetListResponse
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/sheets [get]
+func ListWorksheets(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		wb, head, errResp := currentWorkbook(initStore, padId)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(sheetList(wb, head))
+	}
+}
+
+// CreateWorksheet godoc
+// @Summary Add a worksheet
+// @Description Adds an empty worksheet to a spreadsheet pad, at index or after the last one. Connected clients update live.
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param request body CreateWorksheetRequest false "Name, index and author ID"
+// @Success 200 {object} CreateWorksheetResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/sheets [post]
+func CreateWorksheet(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request CreateWorksheetRequest
+		// Body is optional
+		c.Bind().Body(&request)
+
+		wb, head, errResp := currentWorkbook(initStore, padId)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		index := len(wb.Sheets)
+		if request.Index != nil {
+			if *request.Index < 0 {
+				return c.Status(400).JSON(errors2.NewInvalidParamError("index"))
+			}
+			index = *request.Index
+		}
+		name := request.Name
+		if name == "" {
+			name = "Sheet" + strconv.Itoa(len(wb.Sheets)+1)
+		}
+		op := sheet.Op{Type: sheet.OpAddSheet, Sheet: "s-" + utils.RandomString(8), Name: name, Index: index}
+
+		_, rev, err := initStore.Handler.SubmitSheetOps(padId, []sheet.Op{op}, head, request.AuthorId)
+		if errResp := sheetOpsError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error adding a worksheet to sheet %s: %v", padId, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(CreateWorksheetResponse{Id: op.Sheet, Rev: rev})
+	}
+}
+
+// GetSheetRange godoc
+// @Summary Read a range of a worksheet
+// @Description Returns the raw content, computed value and style of every cell of an A1 range (e.g. A1:D20) of a worksheet, row by row. The worksheet is given by id or name.
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param sheetId path string true "Worksheet ID or name"
+// @Param range path string true "A1 range, e.g. A1:D20"
+// @Success 200 {object} SheetRangeResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/sheets/{sheetId}/range/{range} [get]
+func GetSheetRange(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		r0, c0, r1, c1, ok := sheet.ParseRangeName(c.Params("range"))
+		if !ok {
+			return c.Status(400).JSON(errors2.InvalidRangeError)
+		}
+		if sheet.RangeExceeds(r0, c0, r1, c1, MaxRangeCells) {
+			return c.Status(400).JSON(errors2.RangeTooLargeError)
+		}
+		wb, head, errResp := currentWorkbook(initStore, padId)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		s := worksheet(wb, c.Params("sheetId"))
+		if s == nil {
+			return c.Status(404).JSON(errors2.WorksheetNotFoundError)
+		}
+
+		rows := make([][]SheetRangeCell, 0, r1-r0+1)
+		for r := r0; r <= r1; r++ {
+			row := make([]SheetRangeCell, 0, c1-c0+1)
+			for col := c0; col <= c1; col++ {
+				row = append(row, rangeCell(wb, s.GetCell(sheet.CellRef{Row: r, Col: col})))
+			}
+			rows = append(rows, row)
+		}
+		return c.JSON(SheetRangeResponse{
+			Sheet: s.Id,
+			Range: sheet.RangeName(r0, c0, r1, c1),
+			Head:  head,
+			Rows:  rows,
+		})
+	}
+}
+
+// SubmitSheetOps godoc
+// @Summary Apply operations to a sheet
+// @Description Applies a batch of sheet operations (setCell, clearRange, insertRows, addSheet, ...) composed one after the other against baseRev. Ops are rebased past concurrent edits like a client's, checked against protected ranges and data validations as a whole, and broadcast to connected clients.
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param request body SheetOpsRequest true "Operations, base revision and author ID"
+// @Success 200 {object} SheetOpsResponse
+// @Failure 400 {object} errors.Error
+// @Failure 403 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/ops [post]
+func SubmitSheetOps(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request SheetOpsRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if len(request.Ops) == 0 {
+			return c.Status(400).JSON(errors2.NewMissingParamError("ops"))
+		}
+		head, errResp := sheetHead(initStore, padId)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		baseRev := head
+		if request.BaseRev != nil {
+			baseRev = *request.BaseRev
+		}
+
+		ops, rev, err := initStore.Handler.SubmitSheetOps(padId, request.Ops, baseRev, request.AuthorId)
+		if errResp := sheetOpsError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error applying ops to sheet %s: %v", padId, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(SheetOpsResponse{Rev: rev, Ops: ops})
+	}
+}
+
+// currentWorkbook returns the workbook and head of an existing sheet.
+func currentWorkbook(initStore *lib.InitStore, padId string) (*sheet.Workbook, int, *errors2.Error) {
+	if _, errResp := sheetHead(initStore, padId); errResp != nil {
+		return nil, 0, errResp
+	}
+	snap, head, err := initStore.Handler.SheetManager().Snapshot(padId)
+	if err != nil {
+		return nil, 0, &errors2.InternalServerError
+	}
+	return sheet.WorkbookFromSnapshot(snap), head, nil
+}
+
+func sheetList(wb *sheet.Workbook, head int) SheetListResponse {
+	sheets := make([]SheetInfo, 0, len(wb.Sheets))
+	for _, s := range wb.Sheets {
+		sheets = append(sheets, SheetInfo{Id: s.Id, Name: s.Name})
+	}
+	return SheetListResponse{Head: head, Sheets: sheets}
+}
+
+// worksheet finds a worksheet by id, else by name.
+func worksheet(wb *sheet.Workbook, idOrName string) *sheet.Sheet {
+	if s := wb.SheetByID(idOrName); s != nil {
+		return s
+	}
+	for _, s := range wb.Sheets {
+		if s.Name == idOrName {
+			return s
+		}
+	}
+	return nil
+}
+
+func rangeCell(wb *sheet.Workbook, cell sheet.Cell) SheetRangeCell {
+	out := SheetRangeCell{Raw: cell.Raw, Value: cell.Raw}
+	if cell.Kind() == sheet.KindFormula {
+		out.Value, out.ValueType = cell.Value, cell.ValueType
+	}
+	if style, ok := wb.Styles.Get(cell.StyleId); ok && len(style.Props) > 0 {
+		out.Props = style.Props
+	}
+	return out
+}
+
+// sheetOpsError maps the errors of a sheet op batch to API errors.
+func sheetOpsError(err error) *errors2.Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sheetdoc.ErrRevisionOutOfRange):
+		return &errors2.RevisionHigherThanHeadError
+	case errors.Is(err, sheet.ErrProtected):
+		return &errors2.Error{Message: err.Error(), Error: 403}
+	case errors.Is(err, sheet.ErrInvalidValue), errors.Is(err, sheetdoc.ErrInvalidOp):
+		return &errors2.Error{Message: err.Error(), Error: 400}
+	default:
+		return &errors2.InternalServerError
+	}
+}
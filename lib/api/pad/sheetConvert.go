@@ -0,0 +1,148 @@
+package pad
+
+import (
+	"errors"
+
+	"github.com/ether/etherpad-go/lib"
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	"github.com/ether/etherpad-go/lib/sheettext"
+	"github.com/ether/etherpad-go/lib/ws"
+	"github.com/gofiber/fiber/v3"
+)
+
+// SheetToPadRequest represents the request to copy a sheet range into a text pad
+type SheetToPadRequest struct {
+	TargetPadId string `json:"targetPadId"`
+	Sheet       string `json:"sheet"`
+	Range       string `json:"range"`
+	Delimiter   string `json:"delimiter"`
+	AuthorId    string `json:"authorId"`
+}
+
+// SheetToPadResponse represents the text pad written and its new head
+type SheetToPadResponse struct {
+	PadId string `json:"padId"`
+	Rev   int    `json:"rev"`
+}
+
+// PadToSheetRequest represents the request to turn a text pad into a new sheet
+type PadToSheetRequest struct {
+	TargetPadId string `json:"targetPadId"`
+	Delimiter   string `json:"delimiter"`
+	AuthorId    string `json:"authorId"`
+}
+
+// SheetToPad godoc
+// @Summary Copy a sheet range into a text pad
+// @Description Writes a range of a worksheet (by id or name, default the first; range in A1 notation, default every used cell) into a text pad as one line per row, the cells separated by " | " or the given delimiter. The target pad is created when missing, otherwise its text is replaced. Bold, italic, underline, strikethrough and a row's common alignment carry over.
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Sheet pad ID"
+// @Param request body SheetToPadRequest true "Target pad, worksheet, range, delimiter and author ID"
+// @Success 200 {object} SheetToPadResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/toPad [post]
+func SheetToPad(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request SheetToPadRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if request.TargetPadId == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("targetPadId"))
+		}
+		opts := ws.SheetToPadOptions{Worksheet: request.Sheet, Range: request.Range}
+		if request.Delimiter != "" {
+			delim, err := sheettext.ParseDelimiter(request.Delimiter)
+			if err != nil {
+				return c.Status(400).JSON(errors2.NewInvalidParamError("delimiter"))
+			}
+			opts.Delimiter = delim
+		}
+
+		target, err := initStore.Handler.SheetToPad(padId, request.TargetPadId, opts, request.AuthorId)
+		if errResp := convertError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error converting sheet %s to pad %s: %v", padId, request.TargetPadId, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(SheetToPadResponse{PadId: target.Id, Rev: target.Head})
+	}
+}
+
+// PadToSheet godoc
+// @Summary Turn a text pad into a new sheet
+// @Description Creates a spreadsheet pad from a text pad. HTML tables in the pad text become one worksheet each, otherwise every line is a row of cells split at the delimiter (detected among , ; tab | when not given; quoted like CSV). Numbers and dates are recognized like in the CSV import; bold, italic, underline, strikethrough and alignment carry over.
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Text pad ID"
+// @Param request body PadToSheetRequest true "Target pad, delimiter and author ID"
+// @Success 200 {object} SheetListResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 409 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/toSheet [post]
+func PadToSheet(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request PadToSheetRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if request.TargetPadId == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("targetPadId"))
+		}
+		var delim rune
+		if request.Delimiter != "" {
+			var err error
+			if delim, err = sheettext.ParseDelimiter(request.Delimiter); err != nil {
+				return c.Status(400).JSON(errors2.NewInvalidParamError("delimiter"))
+			}
+		}
+
+		wb, err := initStore.Handler.PadToSheet(padId, request.TargetPadId, delim, request.AuthorId)
+		if errResp := convertError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error converting pad %s to sheet %s: %v", padId, request.TargetPadId, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(sheetList(wb, 0))
+	}
+}
+
+// convertError maps the errors of a sheet/pad conversion to API errors.
+func convertError(err error) *errors2.Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ws.ErrPadNotFound):
+		return &errors2.PadNotFoundError
+	case errors.Is(err, ws.ErrInvalidPadId):
+		resp := errors2.NewInvalidParamError("targetPadId")
+		return &resp
+	case errors.Is(err, ws.ErrPadExists):
+		return &errors2.PadAlreadyExistsError
+	case errors.Is(err, ws.ErrWrongDocumentType):
+		return &errors2.WrongDocumentTypeError
+	case errors.Is(err, ws.ErrWorksheetNotFound):
+		return &errors2.WorksheetNotFoundError
+	case errors.Is(err, ws.ErrInvalidRange):
+		return &errors2.InvalidRangeError
+	case errors.Is(err, ws.ErrRangeTooLarge):
+		return &errors2.RangeTooLargeError
+	case errors.Is(err, sheettext.ErrEmpty), errors.Is(err, sheettext.ErrNoTable):
+		return &errors2.NothingToConvertError
+	default:
+		return &errors2.InternalServerError
+	}
+}
@@ -0,0 +1,234 @@
+package pad
+
+import (
+	"errors"
+
+	"github.com/ether/etherpad-go/lib"
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/sheetdoc"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/gofiber/fiber/v3"
+)
+
+// SheetRevisionsResponse represents the revisions of a sheet
+type SheetRevisionsResponse struct {
+	Head      int                 `json:"head"`
+	Revisions []sheetdoc.Revision `json:"revisions"`
+}
+
+// SheetDiffResponse represents the cell changes between two sheet revisions
+type SheetDiffResponse struct {
+	StartRev int               `json:"startRev"`
+	EndRev   int               `json:"endRev"`
+	Sheets   []sheet.SheetDiff `json:"sheets"`
+}
+
+// SheetRestoreResponse represents the head after a sheet restore
+type SheetRestoreResponse struct {
+	Rev int `json:"rev"`
+}
+
+// ListSheetRevisions godoc
+// @Summary List the revisions of a sheet
+// @Description Returns author, timestamp and operation type of each revision of a spreadsheet pad between startRev and endRev
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param startRev query int false "Start revision number (defaults to 1)"
+// @Param endRev query int false "End revision number (defaults to the head revision)"
+// @Success 200 {object} SheetRevisionsResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/revisions [get]
+func ListSheetRevisions(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		head, errResp := sheetHead(initStore, padId)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		from, errResp := optionalRev(c.Query("startRev"), 1)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		to, errResp := optionalRev(c.Query("endRev"), head)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+
+		revisions, err := initStore.Handler.SheetManager().Revisions(padId, from, to)
+		if err != nil {
+			initStore.Logger.Errorf("Error listing revisions of sheet %s: %v", padId, err)
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+		return c.JSON(SheetRevisionsResponse{Head: head, Revisions: revisions})
+	}
+}
+
+// GetSheetRevision godoc
+// @Summary Get a sheet at a revision
+// @Description Returns the workbook snapshot of a spreadsheet pad as it was at the given revision
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param rev path int true "Revision number"
+// @Success 200 {object} sheet.WorkbookSnapshot
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/revisions/{rev} [get]
+func GetSheetRevision(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		rev, err := utils.CheckValidRev(c.Params("rev"))
+		if err != nil {
+			return c.Status(400).JSON(errors2.InvalidRevisionError)
+		}
+		if _, errResp := sheetHead(initStore, padId); errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+
+		wb, err := initStore.Handler.SheetManager().WorkbookAt(padId, *rev)
+		if errResp := sheetHistoryError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error materializing sheet %s at revision %d: %v", padId, *rev, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(wb.Snapshot())
+	}
+}
+
+// DiffSheetRevisions godoc
+// @Summary Diff two sheet revisions
+// @Description Returns the cells whose content or style differ between startRev and endRev of a spreadsheet pad, plus added, removed and renamed sheets
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param startRev query int true "Start revision number"
+// @Param endRev query int false "End revision number (defaults to the head revision)"
+// @Success 200 {object} SheetDiffResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/diff [get]
+func DiffSheetRevisions(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		head, errResp := sheetHead(initStore, padId)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		if c.Query("startRev") == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("startRev"))
+		}
+		from, errResp := optionalRev(c.Query("startRev"), 0)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		to, errResp := optionalRev(c.Query("endRev"), head)
+		if errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+
+		diff, err := initStore.Handler.SheetManager().Diff(padId, from, to)
+		if errResp := sheetHistoryError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error diffing sheet %s: %v", padId, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(SheetDiffResponse{StartRev: from, EndRev: to, Sheets: diff})
+	}
+}
+
+// RestoreSheetRevision godoc
+// @Summary Restore a sheet revision
+// @Description Brings a spreadsheet pad back to its state at a past revision. The difference is applied as new revisions, so history is kept and connected clients update live.
+// @Tags Sheets
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param request body RestoreRevisionRequest true "Revision and Author ID"
+// @Success 200 {object} SheetRestoreResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/sheets/{padId}/restore [post]
+func RestoreSheetRevision(initStore *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		padId := c.Params("padId")
+		var request RestoreRevisionRequest
+		if err := c.Bind().Body(&request); err != nil {
+			return c.Status(400).JSON(errors2.InvalidRequestError)
+		}
+		if request.Rev < 0 {
+			return c.Status(400).JSON(errors2.InvalidRevisionError)
+		}
+		if _, errResp := sheetHead(initStore, padId); errResp != nil {
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+
+		rev, err := initStore.Handler.RestoreSheet(padId, request.Rev, request.AuthorId)
+		if errResp := sheetHistoryError(err); errResp != nil {
+			if errResp.Error == 500 {
+				initStore.Logger.Errorf("Error restoring sheet %s to revision %d: %v", padId, request.Rev, err)
+			}
+			return c.Status(errResp.Error).JSON(errResp)
+		}
+		return c.JSON(SheetRestoreResponse{Rev: rev})
+	}
+}
+
+// sheetHead returns the head revision of an existing sheet. The sheet manager
+// creates sheets on first access, so existence is checked first.
+func sheetHead(initStore *lib.InitStore, padId string) (int, *errors2.Error) {
+	manager := initStore.Handler.SheetManager()
+	exists, err := manager.Exists(padId)
+	if err != nil {
+		return 0, &errors2.InternalServerError
+	}
+	if !exists {
+		return 0, &errors2.SheetNotFoundError
+	}
+	_, head, err := manager.Snapshot(padId)
+	if err != nil {
+		return 0, &errors2.InternalServerError
+	}
+	return head, nil
+}
+
+// optionalRev parses a revision query parameter, returning def when it is empty.
+func optionalRev(value string, def int) (int, *errors2.Error) {
+	if value == "" {
+		return def, nil
+	}
+	rev, err := utils.CheckValidRev(value)
+	if err != nil {
+		return 0, &errors2.InvalidRevisionError
+	}
+	return *rev, nil
+}
+
+// sheetHistoryError maps the errors of the sheet history methods to API errors.
+func sheetHistoryError(err error) *errors2.Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sheetdoc.ErrRevisionOutOfRange):
+		return &errors2.RevisionHigherThanHeadError
+	case errors.Is(err, sheetdoc.ErrHistoryUnavailable):
+		return &errors2.SheetHistoryUnavailableError
+	default:
+		return &errors2.InternalServerError
+	}
+}
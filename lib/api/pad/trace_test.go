@@ -0,0 +1,57 @@
+package pad
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/pad"
+	"github.com/ether/etherpad-go/lib/tracing"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestGetPadTextTracesDatabaseCallsBelowTheRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	createdHooks := hooks.NewHook()
+	store := db.NewTracedDataStore(db.NewMemoryDataStore(), "memory")
+	padManager := pad.NewManager(store, &createdHooks)
+	padText := "hallo"
+	_, err := padManager.GetPad("traced", &padText, nil)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(tracing.Middleware())
+	Init(&lib.InitStore{C: app, PrivateAPI: app, Store: store, PadManager: padManager, Hooks: &createdHooks})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/pads/traced/text", nil))
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	var server sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindServer {
+			server = span
+		}
+	}
+	require.NotNil(t, server, "no server span recorded")
+	var exists sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "db.DoesPadExist" {
+			exists = span
+		}
+	}
+	require.NotNil(t, exists, "DoesPadExist of the handler was not traced")
+	assert.Equal(t, server.SpanContext().SpanID(), exists.Parent().SpanID())
+}
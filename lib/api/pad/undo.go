@@ -0,0 +1,78 @@
+package pad
+
+import (
+	"errors"
+
+	"github.com/ether/etherpad-go/lib"
+	errors2 "github.com/ether/etherpad-go/lib/api/errors"
+	utils2 "github.com/ether/etherpad-go/lib/api/utils"
+	"github.com/ether/etherpad-go/lib/pad"
+	"github.com/gofiber/fiber/v3"
+)
+
+// UndoResponse represents the revision written by an undo or redo
+type UndoResponse struct {
+	Rev int `json:"rev"`
+}
+
+// UndoAuthor godoc
+// @Summary Undo the last edit of an author
+// @Description Reverts the most recent edit of the author that is not undone yet, keeping the edits of other authors made since
+// @Tags Pads
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param authorId query string true "Author ID"
+// @Success 200 {object} UndoResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/undo [post]
+func UndoAuthor(initStore *lib.InitStore) fiber.Handler {
+	return undoHandler(initStore, false)
+}
+
+// RedoAuthor godoc
+// @Summary Redo the last undo of an author
+// @Description Reverts the most recent undo of the author, as long as the author has not edited the pad since
+// @Tags Pads
+// @Accept json
+// @Produce json
+// @Param padId path string true "Pad ID"
+// @Param authorId query string true "Author ID"
+// @Success 200 {object} UndoResponse
+// @Failure 400 {object} errors.Error
+// @Failure 404 {object} errors.Error
+// @Failure 500 {object} errors.Error
+// @Security BearerAuth
+// @Router /admin/api/pads/{padId}/redo [post]
+func RedoAuthor(initStore *lib.InitStore) fiber.Handler {
+	return undoHandler(initStore, true)
+}
+
+func undoHandler(initStore *lib.InitStore, redo bool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		authorId := c.Query("authorId")
+		if authorId == "" {
+			return c.Status(400).JSON(errors2.NewMissingParamError("authorId"))
+		}
+
+		retrievedPad, err := utils2.GetPadSafeContext(c.Context(), c.Params("padId"), true, nil, nil, initStore.PadManager)
+		if err != nil {
+			return c.Status(404).JSON(errors2.PadNotFoundError)
+		}
+
+		rev, err := initStore.Handler.UndoAuthorEdit(retrievedPad, authorId, redo)
+		switch {
+		case errors.Is(err, pad.ErrNothingToUndo):
+			return c.Status(400).JSON(errors2.NothingToUndoError)
+		case errors.Is(err, pad.ErrNothingToRedo):
+			return c.Status(400).JSON(errors2.NothingToRedoError)
+		case err != nil:
+			return c.Status(500).JSON(errors2.InternalServerError)
+		}
+
+		return c.JSON(UndoResponse{Rev: rev})
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/ether/etherpad-go/lib"
 	"github.com/ether/etherpad-go/lib/api/errors"
+	"github.com/ether/etherpad-go/lib/db"
 	"github.com/ether/etherpad-go/lib/pad"
 	"github.com/gofiber/fiber/v3"
 )
@@ -91,14 +92,14 @@ func CreateSession(store *lib.InitStore, sessions *pad.SessionManager) fiber.Han
 			return c.Status(400).JSON(errors.NewInvalidParamError("validUntil is in the past"))
 		}
 
-		if _, err := store.Store.GetGroup(request.GroupID); err != nil {
+		if _, err := db.WithContext(store.Store, c.Context()).GetGroup(request.GroupID); err != nil {
 			return c.Status(404).JSON(errors.NewInvalidParamError("group does not exist"))
 		}
-		if _, err := store.Store.GetAuthor(request.AuthorID); err != nil {
+		if _, err := db.WithContext(store.Store, c.Context()).GetAuthor(request.AuthorID); err != nil {
 			return c.Status(404).JSON(errors.NewInvalidParamError("author does not exist"))
 		}
 
-		sessionId, err := sessions.CreateSession(request.GroupID, request.AuthorID, request.ValidUntil)
+		sessionId, err := sessions.WithContext(c.Context()).CreateSession(request.GroupID, request.AuthorID, request.ValidUntil)
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
@@ -120,7 +121,7 @@ func CreateSession(store *lib.InitStore, sessions *pad.SessionManager) fiber.Han
 // @Router /admin/api/sessions/{sessionId} [get]
 func GetSessionInfo(sessions *pad.SessionManager) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		info, err := sessions.GetSessionInfo(c.Params("sessionId"))
+		info, err := sessions.WithContext(c.Context()).GetSessionInfo(c.Params("sessionId"))
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
@@ -144,7 +145,7 @@ func GetSessionInfo(sessions *pad.SessionManager) fiber.Handler {
 // @Router /admin/api/sessions/{sessionId} [delete]
 func DeleteSession(sessions *pad.SessionManager) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		deleted, err := sessions.DeleteSession(c.Params("sessionId"))
+		deleted, err := sessions.WithContext(c.Context()).DeleteSession(c.Params("sessionId"))
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
@@ -169,10 +170,10 @@ func DeleteSession(sessions *pad.SessionManager) fiber.Handler {
 func ListSessionsOfGroup(store *lib.InitStore, sessions *pad.SessionManager) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		groupId := c.Params("groupId")
-		if _, err := store.Store.GetGroup(groupId); err != nil {
+		if _, err := db.WithContext(store.Store, c.Context()).GetGroup(groupId); err != nil {
 			return c.Status(404).JSON(errors.NewInvalidParamError("group does not exist"))
 		}
-		found, err := sessions.ListSessionsOfGroup(groupId)
+		found, err := sessions.WithContext(c.Context()).ListSessionsOfGroup(groupId)
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
@@ -194,10 +195,10 @@ func ListSessionsOfGroup(store *lib.InitStore, sessions *pad.SessionManager) fib
 func ListSessionsOfAuthor(store *lib.InitStore, sessions *pad.SessionManager) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		authorId := c.Params("authorId")
-		if _, err := store.Store.GetAuthor(authorId); err != nil {
+		if _, err := db.WithContext(store.Store, c.Context()).GetAuthor(authorId); err != nil {
 			return c.Status(404).JSON(errors.NewInvalidParamError("author does not exist"))
 		}
-		found, err := sessions.ListSessionsOfAuthor(authorId)
+		found, err := sessions.WithContext(c.Context()).ListSessionsOfAuthor(authorId)
 		if err != nil {
 			return c.Status(500).JSON(errors.InternalServerError)
 		}
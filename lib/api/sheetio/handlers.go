@@ -1,8 +1,15 @@
 package sheetio
 
 import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/ether/etherpad-go/lib"
+	"github.com/ether/etherpad-go/lib/ods"
 	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/sheetcsv"
 	"github.com/ether/etherpad-go/lib/xlsx"
 	"github.com/gofiber/fiber/v3"
 )
@@ -10,7 +17,7 @@ import (
 // checkGrant authorizes the request for the pad, returning the author id.
 func checkGrant(c fiber.Ctx, store *lib.InitStore, padId string) (string, error) {
 	token := c.Cookies("token")
-	granted, err := store.SecurityManager.CheckAccess(&padId, nil, &token, nil)
+	granted, err := store.SecurityManager.CheckAccessContext(c.Context(), &padId, nil, &token, nil)
 	if err != nil {
 		return "", fiber.NewError(fiber.StatusInternalServerError, "internalError")
 	}
@@ -20,8 +27,12 @@ func checkGrant(c fiber.Ctx, store *lib.InitStore, padId string) (string, error)
 	return granted.AuthorId, nil
 }
 
-// ImportSheet handles POST /s/:pad/import (multipart "file"), replacing the
-// sheet's workbook and notifying connected clients to reload.
+// ImportSheet handles POST /s/:pad/import (multipart "file"). The format
+// follows the file extension: .xlsx and .ods replace the whole workbook (and
+// the comments, by the notes of an .xlsx), .csv/.tsv/.txt replace the cells
+// of one sheet (form field "sheet", name or id, default the first; created
+// when missing) with optional "delimiter" and "encoding" fields overriding
+// detection. Connected clients are told to reload.
 func ImportSheet(store *lib.InitStore) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		padId := c.Params("pad")
@@ -42,37 +53,168 @@ func ImportSheet(store *lib.InitStore) fiber.Handler {
 		}
 		defer file.Close()
 
-		snap, err := xlsx.Import(file)
-		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid xlsx: "+err.Error())
+		// replace builds the new workbook and comments from the current ones.
+		var replace func(wb *sheet.Workbook, threads []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error)
+		switch ext := strings.ToLower(filepath.Ext(fileHeader.Filename)); ext {
+		case ".csv", ".tsv", ".txt":
+			opts := sheetcsv.ImportOptions{Encoding: c.FormValue("encoding")}
+			if ext == ".tsv" {
+				opts.Delimiter = '\t'
+			}
+			if d := c.FormValue("delimiter"); d != "" {
+				if opts.Delimiter, err = parseDelimiter(d); err != nil {
+					return err
+				}
+			}
+			// The cells of one sheet change, so the import works on the
+			// current workbook.
+			sheetName := c.FormValue("sheet")
+			replace = func(wb *sheet.Workbook, threads []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error) {
+				if _, err := sheetcsv.Import(file, wb, sheetName, opts); err != nil {
+					return nil, nil, fiber.NewError(fiber.StatusBadRequest, "invalid csv: "+err.Error())
+				}
+				return wb, threads, nil
+			}
+		case ".ods":
+			snap, err := ods.Import(file)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid ods: "+err.Error())
+			}
+			replace = func(*sheet.Workbook, []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error) {
+				return sheet.WorkbookFromSnapshot(snap), nil, nil
+			}
+		default:
+			snap, notes, err := xlsx.ImportWithComments(file)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid xlsx: "+err.Error())
+			}
+			replace = func(*sheet.Workbook, []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error) {
+				return sheet.WorkbookFromSnapshot(snap), notes, nil
+			}
 		}
-		wb := sheet.WorkbookFromSnapshot(snap)
-		if err := store.Handler.SheetManager().SetWorkbook(padId, wb); err != nil {
+		if err := store.Handler.ReplaceSheet(padId, replace); err != nil {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				return fiberErr
+			}
 			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
-		store.Handler.BroadcastSheetReload(padId)
 		return c.JSON(fiber.Map{"code": 0, "message": "ok"})
 	}
 }
 
-// ExportSheet handles GET /s/:pad/export.xlsx.
+// parseDelimiter accepts a single character, or "tab" / "\t" for a tab.
+func parseDelimiter(d string) (rune, error) {
+	if d == "tab" || d == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(d)
+	if size != len(d) || r == utf8.RuneError {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid delimiter")
+	}
+	return r, nil
+}
+
+// loadWorkbook authorizes the request and returns the pad's current workbook.
+func loadWorkbook(c fiber.Ctx, store *lib.InitStore) (*sheet.Workbook, error) {
+	padId := c.Params("pad")
+	if _, err := checkGrant(c, store, padId); err != nil {
+		return nil, err
+	}
+	snap, _, err := store.Handler.SheetManager().Snapshot(padId)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "sheet not found")
+	}
+	return sheet.WorkbookFromSnapshot(snap), nil
+}
+
+// ExportSheet handles GET /s/:pad/export.xlsx, comments included as notes.
 func ExportSheet(store *lib.InitStore) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		padId := c.Params("pad")
 		if _, err := checkGrant(c, store, padId); err != nil {
 			return err
 		}
-
-		snap, _, err := store.Handler.SheetManager().Snapshot(padId)
+		snap, threads, _, err := store.Handler.SheetManager().State(padId)
 		if err != nil {
 			return fiber.NewError(fiber.StatusNotFound, "sheet not found")
 		}
-		data, err := xlsx.Export(sheet.WorkbookFromSnapshot(snap))
+		data, err := xlsx.ExportWithComments(sheet.WorkbookFromSnapshot(snap), threads)
 		if err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
 		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+padId+`.xlsx"`)
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+c.Params("pad")+`.xlsx"`)
+		return c.Send(data)
+	}
+}
+
+// ExportODS handles GET /s/:pad/export.ods.
+func ExportODS(store *lib.InitStore) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		wb, err := loadWorkbook(c, store)
+		if err != nil {
+			return err
+		}
+		data, err := ods.Export(wb)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		c.Set(fiber.HeaderContentType, "application/vnd.oasis.opendocument.spreadsheet")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+c.Params("pad")+`.ods"`)
 		return c.Send(data)
 	}
 }
+
+// ExportDelimited handles GET /s/:pad/export.csv and /export.tsv: one sheet
+// (query "sheet", name or id, default the first) with computed values, or
+// the raw formulas when "formulas" is true.
+func ExportDelimited(store *lib.InitStore, delimiter rune, ext, contentType string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		wb, err := loadWorkbook(c, store)
+		if err != nil {
+			return err
+		}
+		sh := findSheet(wb, c.Query("sheet"))
+		if sh == nil {
+			return fiber.NewError(fiber.StatusNotFound, "sheet not found")
+		}
+		data, err := sheetcsv.Export(sh, wb.Styles, sheetcsv.ExportOptions{
+			Delimiter: delimiter,
+			Formulas:  fiber.Query[bool](c, "formulas"),
+		})
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		c.Set(fiber.HeaderContentType, contentType)
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+c.Params("pad")+"-"+sanitizeFilename(sh.Name)+ext+`"`)
+		return c.Send(data)
+	}
+}
+
+// findSheet picks a sheet by name, then by id; "" selects the first sheet.
+func findSheet(wb *sheet.Workbook, name string) *sheet.Sheet {
+	if name == "" {
+		if len(wb.Sheets) == 0 {
+			return nil
+		}
+		return wb.Sheets[0]
+	}
+	for _, s := range wb.Sheets {
+		if s.Name == name {
+			return s
+		}
+	}
+	return wb.SheetByID(name)
+}
+
+// sanitizeFilename keeps a sheet name safe inside a quoted
+// Content-Disposition filename.
+func sanitizeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '"' || r == '\\' || r == '/' || r > 0x7e {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -2,8 +2,11 @@ package sheetio
 
 import "github.com/ether/etherpad-go/lib"
 
-// Init registers the spreadsheet xlsx import/export routes.
+// Init registers the spreadsheet import/export routes (xlsx, ods, csv, tsv).
 func Init(store *lib.InitStore) {
 	store.C.Post("/s/:pad/import", ImportSheet(store))
 	store.C.Get("/s/:pad/export.xlsx", ExportSheet(store))
+	store.C.Get("/s/:pad/export.ods", ExportODS(store))
+	store.C.Get("/s/:pad/export.csv", ExportDelimited(store, ',', ".csv", "text/csv; charset=utf-8"))
+	store.C.Get("/s/:pad/export.tsv", ExportDelimited(store, '\t', ".tsv", "text/tab-separated-values; charset=utf-8"))
 }
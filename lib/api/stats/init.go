@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/ether/etherpad-go/lib"
+	"github.com/ether/etherpad-go/lib/metrics"
 	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/gofiber/fiber/v3/middleware/adaptor"
 	"github.com/prometheus/client_golang/prometheus"
@@ -74,6 +75,7 @@ func Init(store *lib.InitStore) {
 			etherpadActivePads,
 			etherpadTotalUsers,
 		)
+		reg.MustRegister(metrics.Collectors()...)
 		handler := promhttp.HandlerFor(
 			reg,
 			promhttp.HandlerOpts{},
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"errors"
 
 	pad2 "github.com/ether/etherpad-go/lib/models/pad"
@@ -8,12 +9,18 @@ import (
 )
 
 func GetPadSafe(padID string, shouldExist bool, text *string, authorId *string, padManagerToUse *pad.Manager) (*pad2.Pad, error) {
+	return GetPadSafeContext(context.Background(), padID, shouldExist, text, authorId, padManagerToUse)
+}
+
+// GetPadSafeContext is GetPadSafe with the database calls traced as children
+// of the span in ctx.
+func GetPadSafeContext(ctx context.Context, padID string, shouldExist bool, text *string, authorId *string, padManagerToUse *pad.Manager) (*pad2.Pad, error) {
 
 	if !padManagerToUse.IsValidPadId(padID) {
 		return nil, errors.New("padID is not valid")
 	}
 
-	var exists, err = padManagerToUse.DoesPadExist(padID)
+	var exists, err = padManagerToUse.DoesPadExistContext(ctx, padID)
 	if err != nil {
 		return nil, err
 	}
@@ -26,5 +33,5 @@ func GetPadSafe(padID string, shouldExist bool, text *string, authorId *string,
 		return nil, errors.New("padID already exists")
 	}
 
-	return padManagerToUse.GetPad(padID, text, authorId)
+	return padManagerToUse.GetPadContext(ctx, padID, text, authorId)
 }
@@ -1,6 +1,7 @@
 package author
 
 import (
+	"context"
 	"errors"
 	"math/rand"
 	"time"
@@ -19,6 +20,12 @@ func NewManager(db db.DataStore) *Manager {
 	}
 }
 
+// WithContext returns a view of the manager whose database calls are traced
+// as children of the span in ctx.
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{Db: db.WithContext(m.Db, ctx)}
+}
+
 // Author represents an Etherpad author
 // @Description An author who can collaborate on pads
 type Author struct {
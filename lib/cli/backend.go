@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// backend executes the non-interactive commands either against a running
+// server (remoteBackend) or directly against the configured DataStore
+// (localBackend).
+type backend interface {
+	GetText(padId string, rev *int) (string, error)
+	SetText(padId, text, authorId string) error
+	AppendText(padId, text, authorId string) error
+	Export(padId string, format exportFormat, rev *int) ([]byte, error)
+	Import(padId, fileName string, content []byte, authorId string) error
+	ListPads() ([]string, error)
+	DeletePad(padId string) error
+	AnonymizeAuthor(authorId string) error
+	CreateGroup() (string, error)
+	Close() error
+}
+
+var errPadNotFound = errors.New("pad not found")
+
+// exportFormat maps a --format value to the export type of the HTTP route and
+// of io.ExportEtherpad.Render.
+type exportFormat struct {
+	Name   string
+	Route  string
+	Render string
+	Binary bool
+}
+
+var exportFormats = map[string]exportFormat{
+	"txt":      {Name: "txt", Route: "txt", Render: "txt"},
+	"html":     {Name: "html", Route: "html", Render: "html"},
+	"markdown": {Name: "markdown", Route: "markdown", Render: "md"},
+	"etherpad": {Name: "etherpad", Route: "etherpad", Render: "etherpad"},
+	"pdf":      {Name: "pdf", Route: "pdf", Render: "pdf", Binary: true},
+	"docx":     {Name: "docx", Route: "word", Render: "docx", Binary: true},
+	"odt":      {Name: "odt", Route: "open", Render: "odt", Binary: true},
+}
+
+var exportFormatAliases = map[string]string{
+	"md":   "markdown",
+	"text": "txt",
+	"word": "docx",
+	"open": "odt",
+}
+
+func lookupExportFormat(name string) (exportFormat, error) {
+	name = strings.ToLower(name)
+	if alias, ok := exportFormatAliases[name]; ok {
+		name = alias
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		names := make([]string, 0, len(exportFormats))
+		for n := range exportFormats {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return exportFormat{}, fmt.Errorf("unknown export format %q (one of %s)", name, strings.Join(names, ", "))
+	}
+	return format, nil
+}
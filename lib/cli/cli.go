@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"embed"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -565,7 +566,11 @@ func (p *Pad) OnNewContents(callback func(atext apool.AText)) {
 	})
 }
 
-func RunFromCLI(logger *zap.SugaredLogger, args []string) {
+func RunFromCLI(logger *zap.SugaredLogger, args []string, uiAssets embed.FS) {
+	if isCommand(args) {
+		os.Exit(RunCommand(logger, args, uiAssets))
+	}
+
 	host, appendStr, err := parseCLIArgs(args)
 	if err != nil {
 		return
@@ -0,0 +1,469 @@
+package cli
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ether/etherpad-go/lib/client"
+	"go.uber.org/zap"
+)
+
+// commandOptions are the flags shared by all non-interactive commands.
+type commandOptions struct {
+	server  string
+	token   string
+	session string
+	json    bool
+}
+
+// commandEnv carries what a command needs besides its arguments. newBackend
+// is replaceable so tests can run commands against a fake backend.
+type commandEnv struct {
+	stdin      io.Reader
+	stdout     io.Writer
+	logger     *zap.SugaredLogger
+	opts       commandOptions
+	newBackend func(opts commandOptions) (backend, error)
+	watch      func(ctx context.Context, env *commandEnv, padId string) error
+}
+
+type command struct {
+	usage string
+	help  string
+	// minArgs and maxArgs bound the number of positional arguments.
+	minArgs, maxArgs int
+	run              func(env *commandEnv, fs *flag.FlagSet, args []string) error
+	flags            func(fs *flag.FlagSet)
+}
+
+var commands = map[string]map[string]command{
+	"pad": {
+		"get-text": {minArgs: 1, maxArgs: 1, usage: "pad get-text <padId> [-rev N]", help: "Print the text of a pad", run: runGetText, flags: revFlag},
+		"set-text": {minArgs: 1, maxArgs: 2, usage: "pad set-text <padId> [text|-]", help: "Replace the text of a pad (reads stdin without text or with -)", run: runSetText, flags: authorFlag},
+		"append":   {minArgs: 1, maxArgs: 2, usage: "pad append <padId> [text|-]", help: "Append text to a pad (reads stdin without text or with -)", run: runAppend, flags: authorFlag},
+		"export":   {minArgs: 1, maxArgs: 1, usage: "pad export <padId> -format txt|html|markdown|etherpad|pdf|docx|odt [-rev N] [-o file]", help: "Export a pad", run: runExport, flags: exportFlags},
+		"import":   {minArgs: 2, maxArgs: 2, usage: "pad import <padId> <file>", help: "Import a file into a pad, the format follows the file extension", run: runImport, flags: authorFlag},
+		"watch":    {minArgs: 1, maxArgs: 1, usage: "pad watch <padId>", help: "Stream changes of a pad to stdout until interrupted (needs -server)", run: runWatch},
+		"list":     {usage: "pad list", help: "List all pads", run: runList},
+		"delete":   {minArgs: 1, maxArgs: 1, usage: "pad delete <padId>", help: "Delete a pad", run: runDelete},
+	},
+	"author": {
+		"anonymize": {minArgs: 1, maxArgs: 1, usage: "author anonymize <authorId>", help: "Erase the identity of an author", run: runAnonymize},
+	},
+	"group": {
+		"create": {usage: "group create", help: "Create a group and print its id", run: runCreateGroup},
+	},
+}
+
+// isCommand reports whether args start with a non-interactive command group.
+func isCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	_, ok := commands[args[0]]
+	return ok || args[0] == "help"
+}
+
+// runCommand parses and executes a non-interactive command.
+func runCommand(env *commandEnv, args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		printCommandUsage(env.stdout)
+		return nil
+	}
+	group, ok := commands[args[0]]
+	if !ok || len(args) < 2 {
+		return fmt.Errorf("unknown command %q, see etherpad cli help", strings.Join(args, " "))
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see etherpad cli help", args[0]+" "+args[1])
+	}
+
+	fs := flag.NewFlagSet(args[0]+" "+args[1], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&env.opts.server, "server", os.Getenv("ETHERPAD_SERVER"), "Base url of a running server (default: work on the configured database)")
+	fs.StringVar(&env.opts.token, "token", os.Getenv("ETHERPAD_TOKEN"), "Bearer token for the admin API")
+	fs.StringVar(&env.opts.session, "session", "", "Integrator session id (pad watch on group pads)")
+	fs.BoolVar(&env.opts.json, "json", false, "Print JSON")
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	positional, err := parseInterspersed(fs, args[2:])
+	if err != nil {
+		return fmt.Errorf("%s: %w (usage: %s)", fs.Name(), err, cmd.usage)
+	}
+	if len(positional) < cmd.minArgs || len(positional) > cmd.maxArgs {
+		return fmt.Errorf("%s: wrong number of arguments (usage: %s)", fs.Name(), cmd.usage)
+	}
+	return cmd.run(env, fs, positional)
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments; the flag package stops at the first positional one.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func printCommandUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: etherpad cli <command> [flags]")
+	fmt.Fprintln(w, "       etherpad cli [-host] <pad url> [-append text]   (interactive session)")
+	fmt.Fprintln(w, "Commands:")
+	for _, groupName := range []string{"pad", "author", "group"} {
+		group := commands[groupName]
+		names := make([]string, 0, len(group))
+		for name := range group {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %-95s %s\n", group[name].usage, group[name].help)
+		}
+	}
+	fmt.Fprintln(w, "Flags for all commands:")
+	fmt.Fprintln(w, "  -server url   run against a server's REST API (env ETHERPAD_SERVER); without it the configured database is used directly")
+	fmt.Fprintln(w, "  -token token  bearer token for the admin API (env ETHERPAD_TOKEN)")
+	fmt.Fprintln(w, "  -json         print JSON")
+}
+
+func revFlag(fs *flag.FlagSet) {
+	fs.String("rev", "", "Revision number")
+}
+
+func authorFlag(fs *flag.FlagSet) {
+	fs.String("author", "", "Author id the change is attributed to")
+}
+
+func exportFlags(fs *flag.FlagSet) {
+	revFlag(fs)
+	fs.String("format", "txt", "Export format")
+	fs.String("o", "", "Write to file instead of stdout")
+}
+
+func flagValue(fs *flag.FlagSet, name string) string {
+	if f := fs.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+func parseRev(fs *flag.FlagSet) (*int, error) {
+	value := flagValue(fs, "rev")
+	if value == "" {
+		return nil, nil
+	}
+	rev, err := strconv.Atoi(value)
+	if err != nil || rev < 0 {
+		return nil, fmt.Errorf("invalid revision %q", value)
+	}
+	return &rev, nil
+}
+
+// withBackend opens the backend, runs fn and closes the backend again.
+func withBackend(env *commandEnv, fn func(b backend) error) error {
+	b, err := env.newBackend(env.opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := b.Close(); err != nil {
+			env.logger.Debugf("closing backend: %v", err)
+		}
+	}()
+	return fn(b)
+}
+
+// output prints value as JSON in JSON mode and text otherwise.
+func output(env *commandEnv, value any, text string) error {
+	if env.opts.json {
+		enc := json.NewEncoder(env.stdout)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(value)
+	}
+	if text == "" {
+		return nil
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	_, err := io.WriteString(env.stdout, text)
+	return err
+}
+
+// textArg returns the text argument of set-text/append, reading stdin when it
+// is omitted or "-".
+func textArg(env *commandEnv, args []string) (string, error) {
+	if len(args) > 1 && args[1] != "-" {
+		return args[1], nil
+	}
+	data, err := io.ReadAll(env.stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type padResult struct {
+	PadId string `json:"padId"`
+	Ok    bool   `json:"ok"`
+}
+
+func runGetText(env *commandEnv, fs *flag.FlagSet, args []string) error {
+	rev, err := parseRev(fs)
+	if err != nil {
+		return err
+	}
+	return withBackend(env, func(b backend) error {
+		text, err := b.GetText(args[0], rev)
+		if err != nil {
+			return err
+		}
+		if env.opts.json {
+			return output(env, struct {
+				PadId string `json:"padId"`
+				Rev   *int   `json:"rev,omitempty"`
+				Text  string `json:"text"`
+			}{args[0], rev, text}, "")
+		}
+		_, err = io.WriteString(env.stdout, text)
+		return err
+	})
+}
+
+func runSetText(env *commandEnv, fs *flag.FlagSet, args []string) error {
+	text, err := textArg(env, args)
+	if err != nil {
+		return err
+	}
+	return withBackend(env, func(b backend) error {
+		if err := b.SetText(args[0], text, flagValue(fs, "author")); err != nil {
+			return err
+		}
+		return output(env, padResult{PadId: args[0], Ok: true}, "")
+	})
+}
+
+func runAppend(env *commandEnv, fs *flag.FlagSet, args []string) error {
+	text, err := textArg(env, args)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		return errors.New("nothing to append")
+	}
+	return withBackend(env, func(b backend) error {
+		if err := b.AppendText(args[0], text, flagValue(fs, "author")); err != nil {
+			return err
+		}
+		return output(env, padResult{PadId: args[0], Ok: true}, "")
+	})
+}
+
+func runExport(env *commandEnv, fs *flag.FlagSet, args []string) error {
+	format, err := lookupExportFormat(flagValue(fs, "format"))
+	if err != nil {
+		return err
+	}
+	rev, err := parseRev(fs)
+	if err != nil {
+		return err
+	}
+	outFile := flagValue(fs, "o")
+	return withBackend(env, func(b backend) error {
+		content, err := b.Export(args[0], format, rev)
+		if err != nil {
+			return err
+		}
+		if outFile != "" {
+			if err := os.WriteFile(outFile, content, 0o644); err != nil {
+				return err
+			}
+			return output(env, struct {
+				PadId  string `json:"padId"`
+				Format string `json:"format"`
+				File   string `json:"file"`
+				Bytes  int    `json:"bytes"`
+			}{args[0], format.Name, outFile, len(content)}, "")
+		}
+		if env.opts.json {
+			result := struct {
+				PadId         string `json:"padId"`
+				Format        string `json:"format"`
+				Content       string `json:"content,omitempty"`
+				ContentBase64 []byte `json:"contentBase64,omitempty"`
+			}{PadId: args[0], Format: format.Name}
+			if format.Binary {
+				result.ContentBase64 = content
+			} else {
+				result.Content = string(content)
+			}
+			return output(env, result, "")
+		}
+		_, err = env.stdout.Write(content)
+		return err
+	})
+}
+
+func runImport(env *commandEnv, fs *flag.FlagSet, args []string) error {
+	content, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+	return withBackend(env, func(b backend) error {
+		if err := b.Import(args[0], filepath.Base(args[1]), content, flagValue(fs, "author")); err != nil {
+			return err
+		}
+		return output(env, padResult{PadId: args[0], Ok: true}, "")
+	})
+}
+
+func runList(env *commandEnv, _ *flag.FlagSet, args []string) error {
+	return withBackend(env, func(b backend) error {
+		pads, err := b.ListPads()
+		if err != nil {
+			return err
+		}
+		if pads == nil {
+			pads = []string{}
+		}
+		sort.Strings(pads)
+		return output(env, struct {
+			PadIds []string `json:"padIds"`
+		}{pads}, strings.Join(pads, "\n"))
+	})
+}
+
+func runDelete(env *commandEnv, _ *flag.FlagSet, args []string) error {
+	return withBackend(env, func(b backend) error {
+		if err := b.DeletePad(args[0]); err != nil {
+			return err
+		}
+		return output(env, padResult{PadId: args[0], Ok: true}, "")
+	})
+}
+
+func runAnonymize(env *commandEnv, _ *flag.FlagSet, args []string) error {
+	return withBackend(env, func(b backend) error {
+		if err := b.AnonymizeAuthor(args[0]); err != nil {
+			return err
+		}
+		return output(env, struct {
+			AuthorId   string `json:"authorId"`
+			Anonymized bool   `json:"anonymized"`
+		}{args[0], true}, "")
+	})
+}
+
+func runCreateGroup(env *commandEnv, _ *flag.FlagSet, args []string) error {
+	return withBackend(env, func(b backend) error {
+		groupId, err := b.CreateGroup()
+		if err != nil {
+			return err
+		}
+		return output(env, struct {
+			GroupId string `json:"groupId"`
+		}{groupId}, groupId)
+	})
+}
+
+func runWatch(env *commandEnv, _ *flag.FlagSet, args []string) error {
+	if env.opts.server == "" {
+		return errors.New("pad watch follows live edits and needs -server")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return env.watch(ctx, env, args[0])
+}
+
+// watchChange is one line of pad watch output in JSON mode.
+type watchChange struct {
+	PadId     string `json:"padId"`
+	Rev       int    `json:"rev"`
+	Author    string `json:"author"`
+	Changeset string `json:"changeset"`
+	Text      string `json:"text"`
+	Time      int64  `json:"time"`
+}
+
+// watchPad follows a pad with a read-only client and prints every revision:
+// a JSON object per line in JSON mode, "rev<TAB>author<TAB>changeset"
+// otherwise.
+func watchPad(ctx context.Context, env *commandEnv, padId string) error {
+	c, err := client.New(strings.TrimSuffix(env.opts.server, "/")+"/p/"+padId, client.Options{
+		SessionID: env.opts.session,
+		ReadOnly:  true,
+		Reconnect: true,
+		Logger:    env.logger,
+	})
+	if err != nil {
+		return err
+	}
+	c.OnChange(func(ev client.ChangeEvent) {
+		if env.opts.json {
+			_ = output(env, watchChange{
+				PadId: padId, Rev: ev.Rev, Author: ev.Author,
+				Changeset: ev.Changeset, Text: ev.Text, Time: ev.Time.UnixMilli(),
+			}, "")
+			return
+		}
+		fmt.Fprintf(env.stdout, "%d\t%s\t%s\n", ev.Rev, ev.Author, ev.Changeset)
+	})
+	if err := c.Connect(ctx); err != nil {
+		return err
+	}
+	defer c.Close()
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// RunCommand executes a non-interactive command and returns the process exit
+// code. Errors go to stderr, or to stdout as {"error": ...} in JSON mode.
+func RunCommand(logger *zap.SugaredLogger, args []string, uiAssets embed.FS) int {
+	env := &commandEnv{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		logger: logger,
+		newBackend: func(opts commandOptions) (backend, error) {
+			if opts.server != "" {
+				return newRemoteBackend(opts.server, opts.token)
+			}
+			return newLocalBackend(logger, uiAssets)
+		},
+		watch: watchPad,
+	}
+	if err := runCommand(env, args); err != nil {
+		if env.opts.json {
+			_ = output(env, struct {
+				Error string `json:"error"`
+			}{err.Error()}, "")
+		} else {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type fakeBackend struct {
+	pads     map[string]string
+	calls    []string
+	imported []byte
+	closed   bool
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{pads: map[string]string{"test": "hello\n"}}
+}
+
+func (f *fakeBackend) GetText(padId string, _ *int) (string, error) {
+	f.calls = append(f.calls, "get "+padId)
+	text, ok := f.pads[padId]
+	if !ok {
+		return "", errPadNotFound
+	}
+	return text, nil
+}
+
+func (f *fakeBackend) SetText(padId, text, authorId string) error {
+	f.calls = append(f.calls, "set "+padId+" "+authorId)
+	f.pads[padId] = text
+	return nil
+}
+
+func (f *fakeBackend) AppendText(padId, text, _ string) error {
+	f.calls = append(f.calls, "append "+padId)
+	f.pads[padId] = strings.TrimSuffix(f.pads[padId], "\n") + text + "\n"
+	return nil
+}
+
+func (f *fakeBackend) Export(padId string, format exportFormat, _ *int) ([]byte, error) {
+	f.calls = append(f.calls, "export "+padId+" "+format.Name)
+	return []byte("exported " + format.Name), nil
+}
+
+func (f *fakeBackend) Import(padId, fileName string, content []byte, _ string) error {
+	f.calls = append(f.calls, "import "+padId+" "+fileName)
+	f.imported = content
+	return nil
+}
+
+func (f *fakeBackend) ListPads() ([]string, error) {
+	return []string{"b", "a"}, nil
+}
+
+func (f *fakeBackend) DeletePad(padId string) error {
+	if _, ok := f.pads[padId]; !ok {
+		return errPadNotFound
+	}
+	delete(f.pads, padId)
+	return nil
+}
+
+func (f *fakeBackend) AnonymizeAuthor(authorId string) error {
+	f.calls = append(f.calls, "anonymize "+authorId)
+	return nil
+}
+
+func (f *fakeBackend) CreateGroup() (string, error) {
+	return "g.1234", nil
+}
+
+func (f *fakeBackend) Close() error {
+	f.closed = true
+	return nil
+}
+
+func runWithFake(t *testing.T, fake *fakeBackend, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	env := &commandEnv{
+		stdin:      strings.NewReader(stdin),
+		stdout:     &out,
+		logger:     zap.NewNop().Sugar(),
+		newBackend: func(commandOptions) (backend, error) { return fake, nil },
+	}
+	err := runCommand(env, args)
+	return out.String(), err
+}
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		wantOut   string
+		wantCalls []string
+		wantErr   bool
+	}{
+		{name: "get text", args: []string{"pad", "get-text", "test"}, wantOut: "hello\n", wantCalls: []string{"get test"}},
+		{name: "get text json", args: []string{"pad", "get-text", "-json", "test"}, wantOut: `{"padId":"test","text":"hello\n"}` + "\n"},
+		{name: "get text with rev", args: []string{"pad", "get-text", "test", "-rev", "3", "-json"}, wantOut: `{"padId":"test","rev":3,"text":"hello\n"}` + "\n"},
+		{name: "get text invalid rev", args: []string{"pad", "get-text", "test", "-rev", "x"}, wantErr: true},
+		{name: "get text missing pad", args: []string{"pad", "get-text", "nope"}, wantErr: true},
+		{name: "set text with author", args: []string{"pad", "set-text", "test", "new", "-author", "a.1"}, wantCalls: []string{"set test a.1"}},
+		{name: "set text from stdin", args: []string{"pad", "set-text", "test", "-"}, stdin: "piped", wantCalls: []string{"set test "}},
+		{name: "append json", args: []string{"pad", "append", "test", " more", "-json"}, wantOut: `{"padId":"test","ok":true}` + "\n"},
+		{name: "append nothing", args: []string{"pad", "append", "test"}, wantErr: true},
+		{name: "export alias", args: []string{"pad", "export", "test", "-format", "md"}, wantOut: "exported markdown", wantCalls: []string{"export test markdown"}},
+		{name: "export unknown format", args: []string{"pad", "export", "test", "-format", "xls"}, wantErr: true},
+		{name: "export binary json", args: []string{"pad", "export", "test", "-format", "pdf", "-json"}, wantOut: `{"padId":"test","format":"pdf","contentBase64":"ZXhwb3J0ZWQgcGRm"}` + "\n"},
+		{name: "list sorted", args: []string{"pad", "list"}, wantOut: "a\nb\n"},
+		{name: "list json", args: []string{"pad", "list", "-json"}, wantOut: `{"padIds":["a","b"]}` + "\n"},
+		{name: "delete missing", args: []string{"pad", "delete", "nope"}, wantErr: true},
+		{name: "anonymize", args: []string{"author", "anonymize", "a.1", "-json"}, wantOut: `{"authorId":"a.1","anonymized":true}` + "\n", wantCalls: []string{"anonymize a.1"}},
+		{name: "group create", args: []string{"group", "create"}, wantOut: "g.1234\n"},
+		{name: "watch needs server", args: []string{"pad", "watch", "test"}, wantErr: true},
+		{name: "too many arguments", args: []string{"pad", "delete", "a", "b"}, wantErr: true},
+		{name: "unknown subcommand", args: []string{"pad", "rename", "a"}, wantErr: true},
+		{name: "unknown flag", args: []string{"pad", "list", "-bogus"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeBackend()
+			out, err := runWithFake(t, fake, tt.stdin, tt.args...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got output %q", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantOut != "" && out != tt.wantOut {
+				t.Errorf("output = %q, want %q", out, tt.wantOut)
+			}
+			for i, call := range tt.wantCalls {
+				if i >= len(fake.calls) || fake.calls[i] != call {
+					t.Errorf("calls = %q, want %q", fake.calls, tt.wantCalls)
+					break
+				}
+			}
+			if !fake.closed {
+				t.Error("backend was not closed")
+			}
+		})
+	}
+}
+
+func TestRunCommandSetTextReadsStdin(t *testing.T) {
+	fake := newFakeBackend()
+	if _, err := runWithFake(t, fake, "from stdin\n", "pad", "set-text", "test"); err != nil {
+		t.Fatalf("set-text: %v", err)
+	}
+	if fake.pads["test"] != "from stdin\n" {
+		t.Fatalf("text = %q", fake.pads["test"])
+	}
+}
+
+func TestRunCommandImportAndExportToFile(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "notes.html")
+	if err := os.WriteFile(in, []byte("<p>hi</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeBackend()
+	if _, err := runWithFake(t, fake, "", "pad", "import", "test", in); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if fake.calls[0] != "import test notes.html" || string(fake.imported) != "<p>hi</p>" {
+		t.Fatalf("import call %q with %q", fake.calls, fake.imported)
+	}
+
+	out := filepath.Join(dir, "out.docx")
+	stdout, err := runWithFake(t, fake, "", "pad", "export", "test", "-format", "word", "-o", out, "-json")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var result struct {
+		Format string `json:"format"`
+		Bytes  int    `json:"bytes"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil || result.Format != "docx" || result.Bytes != len("exported docx") {
+		t.Fatalf("export result %q (%v)", stdout, err)
+	}
+	if written, _ := os.ReadFile(out); string(written) != "exported docx" {
+		t.Fatalf("written file %q", written)
+	}
+}
+
+func TestRemoteBackend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/api/") && r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /admin/api/pads/test/text":
+			_, _ = w.Write([]byte(`{"text":"hello\n"}`))
+		case "GET /admin/api/pads/missing/text":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Pad not found","error":404}`))
+		case "POST /admin/api/pads/test/appendText":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"text":"more","authorId":"a.1"}` {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case "GET /admin/api/pads":
+			_, _ = w.Write([]byte(`{"padIDs":["a","b"]}`))
+		case "POST /admin/api/groups":
+			_, _ = w.Write([]byte(`{"groupID":"g.42"}`))
+		case "POST /admin/api/author/a.1/anonymize":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Author not found","error":404}`))
+		case "GET /p/test/2/export/word":
+			_, _ = w.Write([]byte("docx bytes"))
+		case "POST /p/test/import":
+			file, header, err := r.FormFile("file")
+			if err != nil || header.Filename != "a.txt" {
+				_, _ = w.Write([]byte(`{"code":1,"message":"uploadFailed"}`))
+				return
+			}
+			_ = file.Close()
+			if _, err := r.Cookie("token"); err != nil {
+				_, _ = w.Write([]byte(`{"code":1,"message":"accessDenied"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"code":0,"message":"ok"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	b, err := newRemoteBackend(srv.URL+"/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, err := b.GetText("test", nil); err != nil || text != "hello\n" {
+		t.Fatalf("GetText = %q, %v", text, err)
+	}
+	if _, err := b.GetText("missing", nil); !errors.Is(err, errPadNotFound) {
+		t.Fatalf("GetText missing = %v", err)
+	}
+	if err := b.AppendText("test", "more", "a.1"); err != nil {
+		t.Fatalf("AppendText: %v", err)
+	}
+	if pads, err := b.ListPads(); err != nil || len(pads) != 2 {
+		t.Fatalf("ListPads = %v, %v", pads, err)
+	}
+	if groupId, err := b.CreateGroup(); err != nil || groupId != "g.42" {
+		t.Fatalf("CreateGroup = %q, %v", groupId, err)
+	}
+	if err := b.AnonymizeAuthor("a.1"); err == nil || !strings.Contains(err.Error(), "Author not found") {
+		t.Fatalf("AnonymizeAuthor = %v", err)
+	}
+	rev := 2
+	docx, _ := lookupExportFormat("docx")
+	if content, err := b.Export("test", docx, &rev); err != nil || string(content) != "docx bytes" {
+		t.Fatalf("Export = %q, %v", content, err)
+	}
+	if err := b.Import("test", "a.txt", []byte("hi"), ""); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if err := b.Import("test", "a.txt", []byte("hi"), "a.1"); err == nil || !strings.Contains(err.Error(), "-author") {
+		t.Fatalf("Import with author = %v", err)
+	}
+
+	unauthorized, _ := newRemoteBackend(srv.URL, "wrong")
+	if _, err := unauthorized.ListPads(); err == nil {
+		t.Fatal("expected an error without a valid token")
+	}
+	if _, err := newRemoteBackend("localhost:9001", ""); err == nil {
+		t.Fatal("expected an error for a server url without scheme")
+	}
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"embed"
+	"errors"
+	"strings"
+
+	apiio "github.com/ether/etherpad-go/lib/api/io"
+	apiutils "github.com/ether/etherpad-go/lib/api/utils"
+	"github.com/ether/etherpad-go/lib/author"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/io"
+	"github.com/ether/etherpad-go/lib/pad"
+	settings2 "github.com/ether/etherpad-go/lib/settings"
+	"github.com/ether/etherpad-go/lib/utils"
+	"go.uber.org/zap"
+)
+
+// localBackend works on the DataStore configured in settings.json. It is
+// meant for a stopped server: connected editors are not notified and a
+// running server keeps serving its cached pads.
+type localBackend struct {
+	store         db.DataStore
+	padManager    *pad.Manager
+	authorManager *author.Manager
+	exporter      *io.ExportEtherpad
+	importer      *apiio.ImportHandler
+}
+
+func newLocalBackend(logger *zap.SugaredLogger, uiAssets embed.FS) (*localBackend, error) {
+	settings2.InitSettings(logger)
+	settings := settings2.Displayed
+	dataStore, err := utils.GetDB(settings, logger)
+	if err != nil {
+		return nil, err
+	}
+	retrievedHooks := hooks.NewHook()
+	padManager := pad.NewManager(dataStore, &retrievedHooks)
+	authorManager := author.NewManager(dataStore)
+	importer := io.NewImporter(padManager, authorManager, dataStore, logger, &retrievedHooks)
+	formats := io.NewFormatRegistry()
+	importer.RegisterFormats(formats)
+	return &localBackend{
+		store:         dataStore,
+		padManager:    padManager,
+		authorManager: authorManager,
+		exporter:      io.NewExportEtherpad(&retrievedHooks, padManager, dataStore, logger, uiAssets),
+		importer:      apiio.NewImportHandler(padManager, nil, nil, importer, formats, &settings, logger, &retrievedHooks),
+	}, nil
+}
+
+func (l *localBackend) GetText(padId string, rev *int) (string, error) {
+	foundPad, err := apiutils.GetPadSafe(padId, true, nil, nil, l.padManager)
+	if err != nil {
+		return "", errPadNotFound
+	}
+	if rev != nil {
+		if *rev > foundPad.Head {
+			return "", errors.New("rev is higher than the current head revision")
+		}
+		atext := foundPad.GetInternalRevisionAText(*rev)
+		if atext == nil {
+			return "", errors.New("revision not found")
+		}
+		return atext.Text, nil
+	}
+	text, err := pad.GetTxtFromAText(foundPad, foundPad.AText)
+	if err != nil {
+		return "", err
+	}
+	return *text, nil
+}
+
+func optionalAuthor(authorId string) *string {
+	if authorId == "" {
+		return nil
+	}
+	return &authorId
+}
+
+func (l *localBackend) SetText(padId, text, authorId string) error {
+	foundPad, err := apiutils.GetPadSafe(padId, true, nil, nil, l.padManager)
+	if err != nil {
+		return errPadNotFound
+	}
+	return foundPad.SetText(text, optionalAuthor(authorId))
+}
+
+func (l *localBackend) AppendText(padId, text, authorId string) error {
+	foundPad, err := apiutils.GetPadSafe(padId, true, nil, nil, l.padManager)
+	if err != nil {
+		return errPadNotFound
+	}
+	// Same semantics as the appendText API: insert before the final newline.
+	current := strings.TrimSuffix(foundPad.Text(), "\n")
+	return foundPad.SetText(current+text, optionalAuthor(authorId))
+}
+
+func (l *localBackend) Export(padId string, format exportFormat, rev *int) ([]byte, error) {
+	exists, err := l.padManager.DoesPadExist(padId)
+	if err != nil {
+		return nil, err
+	}
+	if !*exists {
+		return nil, errPadNotFound
+	}
+	content, _, err := l.exporter.Render(padId, nil, format.Render, rev)
+	return content, err
+}
+
+func (l *localBackend) Import(padId, fileName string, content []byte, authorId string) error {
+	if !l.padManager.IsValidPadId(padId) {
+		return errors.New("invalid pad id")
+	}
+	if _, importErr := l.importer.ImportFile(padId, authorId, fileName, content); importErr != nil {
+		return importErr
+	}
+	return nil
+}
+
+func (l *localBackend) ListPads() ([]string, error) {
+	pads, err := l.store.GetPadIds()
+	if err != nil {
+		return nil, err
+	}
+	if pads == nil {
+		return []string{}, nil
+	}
+	return *pads, nil
+}
+
+func (l *localBackend) DeletePad(padId string) error {
+	if _, err := apiutils.GetPadSafe(padId, true, nil, nil, l.padManager); err != nil {
+		return errPadNotFound
+	}
+	return l.padManager.RemovePad(padId)
+}
+
+func (l *localBackend) AnonymizeAuthor(authorId string) error {
+	return l.authorManager.AnonymizeAuthor(authorId)
+}
+
+func (l *localBackend) CreateGroup() (string, error) {
+	groupId := "g." + utils.RandomString(16)
+	if err := l.store.SaveGroup(groupId); err != nil {
+		return "", err
+	}
+	return groupId, nil
+}
+
+func (l *localBackend) Close() error {
+	return l.store.Close()
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/utils"
+)
+
+// remoteBackend talks to the admin REST API (/admin/api) of a running server
+// with a bearer token. Export and import use the public pad routes.
+type remoteBackend struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newRemoteBackend(server, token string) (*remoteBackend, error) {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server url %q", server)
+	}
+	return &remoteBackend{
+		baseURL: strings.TrimSuffix(server, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// apiError is the JSON error body of the admin API (api/errors.Error).
+type apiError struct {
+	Message string `json:"message"`
+	Error   int    `json:"error"`
+}
+
+func (r *remoteBackend) do(method, path string, body io.Reader, contentType string) ([]byte, error) {
+	req, err := http.NewRequest(method, r.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/admin/api/pads/") {
+			return nil, errPadNotFound
+		}
+		var apiErr apiError
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("%s %s: %s (%d)", method, path, apiErr.Message, resp.StatusCode)
+		}
+		msg := strings.TrimSpace(string(data))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, path, msg)
+	}
+	return data, nil
+}
+
+func (r *remoteBackend) doJSON(method, path string, in any, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+	data, err := r.do(method, path, body, contentType)
+	if err != nil || out == nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func padPath(padId string) string {
+	return "/admin/api/pads/" + url.PathEscape(padId)
+}
+
+func (r *remoteBackend) GetText(padId string, rev *int) (string, error) {
+	path := padPath(padId) + "/text"
+	if rev != nil {
+		path += "?rev=" + strconv.Itoa(*rev)
+	}
+	var resp struct {
+		Text string `json:"text"`
+	}
+	if err := r.doJSON(http.MethodGet, path, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+type textRequest struct {
+	Text     string `json:"text"`
+	AuthorId string `json:"authorId,omitempty"`
+}
+
+func (r *remoteBackend) SetText(padId, text, authorId string) error {
+	return r.doJSON(http.MethodPost, padPath(padId)+"/text", textRequest{Text: text, AuthorId: authorId}, nil)
+}
+
+func (r *remoteBackend) AppendText(padId, text, authorId string) error {
+	return r.doJSON(http.MethodPost, padPath(padId)+"/appendText", textRequest{Text: text, AuthorId: authorId}, nil)
+}
+
+func (r *remoteBackend) Export(padId string, format exportFormat, rev *int) ([]byte, error) {
+	path := "/p/" + url.PathEscape(padId)
+	if rev != nil {
+		path += "/" + strconv.Itoa(*rev)
+	}
+	return r.do(http.MethodGet, path+"/export/"+format.Route, nil, "")
+}
+
+func (r *remoteBackend) Import(padId, fileName string, content []byte, authorId string) error {
+	// The public import route attributes the import to the author of the
+	// token cookie and has no way to name another author.
+	if authorId != "" {
+		return errors.New("import: -author is not supported with -server")
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(content); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.baseURL+"/p/"+url.PathEscape(padId)+"/import", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	// The import route authorizes like the pad page: by author token cookie.
+	req.AddCookie(&http.Cookie{Name: "token", Value: "t." + utils.RandomString(20)})
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("import: unexpected response (%s)", resp.Status)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("import: %s", result.Message)
+	}
+	return nil
+}
+
+func (r *remoteBackend) ListPads() ([]string, error) {
+	var resp struct {
+		PadIDs []string `json:"padIDs"`
+	}
+	if err := r.doJSON(http.MethodGet, "/admin/api/pads", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.PadIDs, nil
+}
+
+func (r *remoteBackend) DeletePad(padId string) error {
+	return r.doJSON(http.MethodDelete, padPath(padId), nil, nil)
+}
+
+func (r *remoteBackend) AnonymizeAuthor(authorId string) error {
+	return r.doJSON(http.MethodPost, "/admin/api/author/"+url.PathEscape(authorId)+"/anonymize", nil, nil)
+}
+
+func (r *remoteBackend) CreateGroup() (string, error) {
+	var resp struct {
+		GroupID string `json:"groupID"`
+	}
+	if err := r.doJSON(http.MethodPost, "/admin/api/groups", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.GroupID, nil
+}
+
+func (r *remoteBackend) Close() error {
+	return nil
+}
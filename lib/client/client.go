@@ -0,0 +1,664 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrClosed is returned by operations on a client that has been closed.
+	ErrClosed = errors.New("client: closed")
+	// ErrNotConnected is returned when the initial document state has not
+	// been received yet.
+	ErrNotConnected = errors.New("client: not connected")
+	// ErrReadOnly is returned for edits on a read-only connection.
+	ErrReadOnly = errors.New("client: read-only")
+	// ErrWrongDocumentType is returned for text edits on a sheet connection
+	// and vice versa.
+	ErrWrongDocumentType = errors.New("client: wrong document type")
+)
+
+// AccessError is returned by Connect when the server denied access to the pad
+// (the accessStatus frame, e.g. "deny" or "needAuth").
+type AccessError struct {
+	Status string
+}
+
+func (e *AccessError) Error() string {
+	return "client: access denied: " + e.Status
+}
+
+// DisconnectError reports a server-initiated disconnect such as "userdup",
+// "deleted", "rejected" or "badChangeset".
+type DisconnectError struct {
+	Reason string
+}
+
+func (e *DisconnectError) Error() string {
+	return "client: disconnected by server: " + e.Reason
+}
+
+// Options configures a Client. The zero value connects anonymously with a
+// fresh author token and no reconnect.
+type Options struct {
+	// Token is the author token ("t.<random>") that identifies the author
+	// across connections. A random token is generated when empty; reuse
+	// Client.Token() to reconnect as the same author later.
+	Token string
+	// SessionID is an integrator session created with the createSession API.
+	// It is sent as the sessionID cookie on the websocket handshake, which is
+	// required for group pads.
+	SessionID string
+	// ReadOnly makes the client refuse local edits even when the server
+	// grants write access. Connecting to a read-only pad id ("r.…") is always
+	// read-only.
+	ReadOnly bool
+	// Name and ColorId ("#rrggbb") are announced to the other users.
+	Name    string
+	ColorId string
+	// Header is sent with the websocket handshake (e.g. Authorization for
+	// servers that require basic auth).
+	Header http.Header
+	// Dialer overrides websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+	// Reconnect re-establishes dropped connections and catches up with the
+	// revisions missed in the meantime.
+	Reconnect bool
+	// ReconnectBackoff is the initial delay between reconnect attempts
+	// (default 500ms); it doubles up to MaxReconnectBackoff (default 30s).
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+	// MaxReconnectAttempts bounds consecutive failed attempts; 0 is unlimited.
+	MaxReconnectAttempts int
+	// Logger receives debug output; a no-op logger is used when nil.
+	Logger *zap.SugaredLogger
+}
+
+// Client is a connection to one pad or sheet document.
+type Client struct {
+	opts      Options
+	logger    *zap.SugaredLogger
+	wsURL     string
+	padId     string
+	component string // "pad" or "sheet"
+
+	connMu  sync.Mutex
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	// mu guards the document model below.
+	mu          sync.Mutex
+	connected   bool
+	reconnectOK bool
+	userId      string
+	readOnly    bool
+	users       map[string]User
+	text        textState
+	sheet       sheetState
+	closing     bool
+	terminalErr error
+
+	hmu      sync.RWMutex
+	handlers handlers
+	events   chan func(h *handlers)
+
+	ready       chan error
+	readyOnce   sync.Once
+	dispatching bool
+	stop        chan struct{}
+	stopOnce    sync.Once
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// Dial creates a client for padURL and connects it. Handlers registered after
+// Dial returns miss the connect event; use New and Connect when that matters.
+func Dial(ctx context.Context, padURL string, opts Options) (*Client, error) {
+	c, err := New(padURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// New creates an unconnected client. padURL is the browser URL of the
+// document, e.g. http://127.0.0.1:9001/p/test or https://host/prefix/s/budget.
+func New(padURL string, opts Options) (*Client, error) {
+	wsURL, padId, component, err := parsePadURL(padURL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Token == "" {
+		opts.Token = "t." + utils.RandomString(20)
+	}
+	if opts.ReconnectBackoff <= 0 {
+		opts.ReconnectBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxReconnectBackoff <= 0 {
+		opts.MaxReconnectBackoff = 30 * time.Second
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return &Client{
+		opts:      opts,
+		logger:    logger,
+		wsURL:     wsURL,
+		padId:     padId,
+		component: component,
+		users:     map[string]User{},
+		events:    make(chan func(h *handlers), 256),
+		ready:     make(chan error, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// parsePadURL splits a pad URL into the socket.io endpoint, the pad id and the
+// document component. Any path prefix before /p/ or /s/ is kept so clients
+// work behind reverse proxies that mount Etherpad under a sub path.
+func parsePadURL(padURL string) (string, string, string, error) {
+	u, err := url.Parse(padURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("client: invalid pad url: %w", err)
+	}
+	var scheme string
+	switch u.Scheme {
+	case "http", "ws":
+		scheme = "ws"
+	case "https", "wss":
+		scheme = "wss"
+	default:
+		return "", "", "", fmt.Errorf("client: unsupported scheme %q", u.Scheme)
+	}
+	component := "pad"
+	idx := strings.LastIndex(u.Path, "/p/")
+	if sIdx := strings.LastIndex(u.Path, "/s/"); sIdx > idx {
+		idx = sIdx
+		component = "sheet"
+	}
+	if idx == -1 {
+		return "", "", "", fmt.Errorf("client: %q is not a /p/ or /s/ url", padURL)
+	}
+	padId, err := url.PathUnescape(strings.TrimSuffix(u.Path[idx+3:], "/"))
+	if err != nil || padId == "" || strings.Contains(padId, "/") {
+		return "", "", "", fmt.Errorf("client: %q has no pad id", padURL)
+	}
+	wsURL := fmt.Sprintf("%s://%s%s/socket.io/", scheme, u.Host, u.Path[:idx])
+	return wsURL, padId, component, nil
+}
+
+// Connect opens the websocket, sends CLIENT_READY and waits until the initial
+// document state arrived or ctx is done.
+func (c *Client) Connect(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	c.setConn(conn)
+	c.mu.Lock()
+	c.dispatching = true
+	c.mu.Unlock()
+	go c.dispatch()
+	go c.run(conn)
+	if err := c.sendClientReady(false); err != nil {
+		c.Close()
+		return err
+	}
+	select {
+	case err := <-c.ready:
+		if err != nil {
+			c.Close()
+		}
+		return err
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+}
+
+// Close disconnects the client. Pending local edits that were not accepted
+// yet are lost.
+func (c *Client) Close() {
+	c.mu.Lock()
+	c.closing = true
+	c.mu.Unlock()
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	} else {
+		c.finish(ErrClosed)
+	}
+}
+
+// Done is closed once the client stopped for good (Close, a terminal server
+// disconnect, or reconnect attempts exhausted).
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the reason the client stopped, or nil while it is running.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.terminalErr
+}
+
+// PadId returns the pad id the client is connected to.
+func (c *Client) PadId() string {
+	return c.padId
+}
+
+// Token returns the author token used for CLIENT_READY.
+func (c *Client) Token() string {
+	return c.opts.Token
+}
+
+// UserId returns the author id the server assigned to this client.
+func (c *Client) UserId() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userId
+}
+
+// ReadOnly reports whether local edits are refused.
+func (c *Client) ReadOnly() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readOnly || c.opts.ReadOnly
+}
+
+// Users returns the other users currently known to be on the pad.
+func (c *Client) Users() []User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]User, 0, len(c.users))
+	for _, u := range c.users {
+		out = append(out, u)
+	}
+	return out
+}
+
+// SetUserInfo changes the name and color shown to the other users.
+func (c *Client) SetUserInfo(name, colorId string) error {
+	c.mu.Lock()
+	userId := c.userId
+	c.mu.Unlock()
+	data := userInfoUpdateData{Type: "USERINFO_UPDATE"}
+	data.UserInfo.UserId = userId
+	if name != "" {
+		data.UserInfo.Name = &name
+	}
+	if colorId != "" {
+		data.UserInfo.ColorId = &colorId
+	}
+	return c.send(c.collab(data))
+}
+
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := c.opts.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	header := http.Header{}
+	for k, v := range c.opts.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	if c.opts.SessionID != "" {
+		header.Add("Cookie", (&http.Cookie{Name: "sessionID", Value: c.opts.SessionID}).String())
+	}
+	conn, resp, err := dialer.DialContext(ctx, c.wsURL, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("client: dial %s: %w (status %s)", c.wsURL, err, resp.Status)
+		}
+		return nil, fmt.Errorf("client: dial %s: %w", c.wsURL, err)
+	}
+	return conn, nil
+}
+
+func (c *Client) setConn(conn *websocket.Conn) {
+	c.connMu.Lock()
+	c.conn = conn
+	c.connMu.Unlock()
+}
+
+func (c *Client) sendClientReady(reconnect bool) error {
+	ready := map[string]any{
+		"component": c.component,
+		"type":      "CLIENT_READY",
+		"padId":     c.padId,
+		"token":     c.opts.Token,
+		"userInfo": map[string]any{
+			"name":    nilIfEmpty(c.opts.Name),
+			"colorId": nilIfEmpty(c.opts.ColorId),
+		},
+	}
+	if reconnect {
+		c.mu.Lock()
+		rev := c.text.rev
+		c.mu.Unlock()
+		ready["reconnect"] = true
+		ready["client_rev"] = rev
+	}
+	return c.send(outgoing{Event: "message", Data: ready})
+}
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// collab wraps a COLLABROOM payload into a client frame.
+func (c *Client) collab(data any) outgoing {
+	return outgoing{Event: "message", Data: collabEnvelope{Component: c.component, Type: "COLLABROOM", Data: data}}
+}
+
+func (c *Client) send(msg outgoing) error {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return conn.WriteMessage(websocket.TextMessage, encoded)
+}
+
+// run owns the connection lifecycle: it reads until the connection drops and
+// then either reconnects or stops the client.
+func (c *Client) run(conn *websocket.Conn) {
+	for {
+		err := c.readLoop(conn)
+
+		c.mu.Lock()
+		closing := c.closing
+		terminal := c.terminalErr
+		everConnected := c.reconnectOK
+		c.connected = false
+		c.mu.Unlock()
+
+		if closing {
+			c.finish(ErrClosed)
+			return
+		}
+		if terminal != nil {
+			c.finish(terminal)
+			return
+		}
+		if !c.opts.Reconnect || !everConnected {
+			c.finish(err)
+			return
+		}
+		c.emit(func(h *handlers) {
+			for _, fn := range h.disconnect {
+				fn(DisconnectEvent{Err: err, Reconnecting: true})
+			}
+		})
+
+		conn = c.redial()
+		if conn == nil {
+			c.finish(err)
+			return
+		}
+	}
+}
+
+// redial reconnects with exponential backoff and re-sends CLIENT_READY in
+// reconnect mode. It returns nil when the client was closed meanwhile or the
+// attempts are exhausted.
+func (c *Client) redial() *websocket.Conn {
+	backoff := c.opts.ReconnectBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-c.stop:
+			return nil
+		}
+		c.mu.Lock()
+		closing := c.closing
+		c.mu.Unlock()
+		if closing {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conn, err := c.dial(ctx)
+		cancel()
+		if err == nil {
+			c.setConn(conn)
+			// Text pads catch up revision by revision (CLIENT_RECONNECT);
+			// sheets reload the snapshot like the browser editor does.
+			if err = c.sendClientReady(c.component == "pad"); err == nil {
+				return conn
+			}
+			_ = conn.Close()
+		}
+		c.logger.Warnf("reconnect attempt %d to %s failed: %v", attempt, c.wsURL, err)
+		if c.opts.MaxReconnectAttempts > 0 && attempt >= c.opts.MaxReconnectAttempts {
+			return nil
+		}
+		backoff = min(backoff*2, c.opts.MaxReconnectBackoff)
+	}
+}
+
+// finish stops the client for good and reports why.
+func (c *Client) finish(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		if c.terminalErr == nil {
+			c.terminalErr = err
+		}
+		dispatching := c.dispatching
+		c.mu.Unlock()
+		c.signalReady(err)
+		if !dispatching {
+			close(c.done)
+			return
+		}
+		c.emit(func(h *handlers) {
+			for _, fn := range h.disconnect {
+				fn(DisconnectEvent{Err: err, Reconnecting: false})
+			}
+		})
+		// Let the dispatcher drain queued events before shutting it down.
+		c.emit(func(*handlers) { close(c.done) })
+	})
+}
+
+func (c *Client) signalReady(err error) {
+	c.readyOnce.Do(func() {
+		c.ready <- err
+	})
+}
+
+func (c *Client) readLoop(conn *websocket.Conn) error {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var frame []json.RawMessage
+		if err := json.Unmarshal(message, &frame); err != nil || len(frame) != 2 {
+			continue
+		}
+		var event string
+		if err := json.Unmarshal(frame[0], &event); err != nil || event != "message" {
+			continue
+		}
+		var msg serverMessage
+		if err := json.Unmarshal(frame[1], &msg); err != nil {
+			c.logger.Warnf("undecodable server message: %v", err)
+			continue
+		}
+		if err := c.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle processes one server message. A returned error drops the
+// connection (and triggers a reconnect when enabled).
+func (c *Client) handle(msg serverMessage) error {
+	switch {
+	case msg.AccessStatus != "":
+		return c.fail(&AccessError{Status: msg.AccessStatus})
+	case msg.Disconnect != "":
+		return c.fail(&DisconnectError{Reason: msg.Disconnect})
+	}
+	switch msg.Type {
+	case "CLIENT_VARS":
+		var vars clientVars
+		if err := json.Unmarshal(msg.Data, &vars); err != nil {
+			return c.fail(fmt.Errorf("client: bad CLIENT_VARS: %w", err))
+		}
+		return c.handleClientVars(vars)
+	case "SHEET_VARS":
+		var vars sheetVars
+		if err := json.Unmarshal(msg.Data, &vars); err != nil {
+			return c.fail(fmt.Errorf("client: bad SHEET_VARS: %w", err))
+		}
+		return c.handleSheetVars(vars)
+	case "COLLABROOM":
+		var room collabRoom
+		if err := json.Unmarshal(msg.Data, &room); err != nil {
+			c.logger.Warnf("undecodable COLLABROOM message: %v", err)
+			return nil
+		}
+		return c.handleCollabRoom(room)
+	}
+	return nil
+}
+
+// fail records a terminal error and drops the connection.
+func (c *Client) fail(err error) error {
+	c.mu.Lock()
+	c.terminalErr = err
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Client) handleCollabRoom(room collabRoom) error {
+	switch room.Type {
+	case "NEW_CHANGES":
+		return c.handleNewChanges(room)
+	case "ACCEPT_COMMIT":
+		return c.handleAcceptCommit(room.NewRev)
+	case "CLIENT_RECONNECT":
+		return c.handleClientReconnect(room)
+	case "NEW_SHEET_OP":
+		return c.handleNewSheetOp(room)
+	case "ACCEPT_SHEET_OP":
+		return c.handleAcceptSheetOp(room.NewRev)
+	case "SHEET_RELOAD":
+		return c.sendClientReady(false)
+	case "USER_NEWINFO":
+		c.handleUserNewInfo(room)
+	case "USER_LEAVE":
+		c.handleUserLeave(room)
+	case "CHAT_MESSAGE":
+		c.handleChat(room)
+	}
+	return nil
+}
+
+func (c *Client) handleUserNewInfo(room collabRoom) {
+	info := room.UserInfo
+	c.mu.Lock()
+	if info.UserId == "" || info.UserId == c.userId {
+		c.mu.Unlock()
+		return
+	}
+	u := User{Id: info.UserId, ColorId: info.ColorId}
+	if info.Name != nil {
+		u.Name = *info.Name
+	}
+	_, known := c.users[u.Id]
+	c.users[u.Id] = u
+	c.mu.Unlock()
+	if known {
+		return
+	}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.userJoin {
+			fn(UserJoinEvent{User: u})
+		}
+	})
+}
+
+func (c *Client) handleUserLeave(room collabRoom) {
+	c.mu.Lock()
+	u, known := c.users[room.UserInfo.UserId]
+	delete(c.users, room.UserInfo.UserId)
+	c.mu.Unlock()
+	if !known {
+		u = User{Id: room.UserInfo.UserId, ColorId: room.UserInfo.ColorId}
+	}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.userLeave {
+			fn(UserLeaveEvent{User: u})
+		}
+	})
+}
+
+func (c *Client) markConnected(userId string, readOnly bool) (first bool) {
+	c.userId = userId
+	c.readOnly = readOnly
+	first = !c.reconnectOK
+	c.reconnectOK = true
+	c.connected = true
+	return first
+}
+
+func (c *Client) emitConnected(first bool, rev int) {
+	if first {
+		c.signalReady(nil)
+		c.emit(func(h *handlers) {
+			for _, fn := range h.connect {
+				fn()
+			}
+		})
+		return
+	}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.reconnect {
+			fn(ReconnectEvent{Rev: rev})
+		}
+	})
+}
+
+// wirePool decodes a pool sent by the server into a usable APool.
+func wirePool(p apool.APool) *apool.APool {
+	pool := apool.NewAPool()
+	return pool.FromJsonable(p)
+}
@@ -0,0 +1,463 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/gorilla/websocket"
+)
+
+func TestParsePadURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wsURL     string
+		padId     string
+		component string
+		wantErr   bool
+	}{
+		{name: "http pad", url: "http://localhost:9001/p/test", wsURL: "ws://localhost:9001/socket.io/", padId: "test", component: "pad"},
+		{name: "https sheet", url: "https://example.com/s/budget", wsURL: "wss://example.com/socket.io/", padId: "budget", component: "sheet"},
+		{name: "prefix kept", url: "https://example.com/pads/p/a%20b/", wsURL: "wss://example.com/pads/socket.io/", padId: "a b", component: "pad"},
+		{name: "ws scheme", url: "ws://localhost/p/x", wsURL: "ws://localhost/socket.io/", padId: "x", component: "pad"},
+		{name: "unsupported scheme", url: "ftp://localhost/p/x", wantErr: true},
+		{name: "no pad path", url: "http://localhost/admin", wantErr: true},
+		{name: "empty pad id", url: "http://localhost/p/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wsURL, padId, component, err := parsePadURL(tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tt.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if wsURL != tt.wsURL || padId != tt.padId || component != tt.component {
+				t.Fatalf("got (%q, %q, %q), want (%q, %q, %q)", wsURL, padId, component, tt.wsURL, tt.padId, tt.component)
+			}
+		})
+	}
+}
+
+// fakeServer speaks just enough of the socket.io wire protocol to drive a
+// client. Every frame a client sends is delivered on received.
+type fakeServer struct {
+	t        *testing.T
+	srv      *httptest.Server
+	conns    chan *websocket.Conn
+	received chan map[string]any
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	fs := &fakeServer{
+		t:        t,
+		conns:    make(chan *websocket.Conn, 4),
+		received: make(chan map[string]any, 64),
+	}
+	upgrader := websocket.Upgrader{}
+	fs.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		fs.conns <- conn
+		for {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame struct {
+				Data map[string]any `json:"data"`
+			}
+			if err := json.Unmarshal(raw, &frame); err == nil {
+				fs.received <- frame.Data
+			}
+		}
+	}))
+	t.Cleanup(fs.srv.Close)
+	return fs
+}
+
+func (fs *fakeServer) url(path string) string {
+	return fs.srv.URL + path
+}
+
+func (fs *fakeServer) conn() *websocket.Conn {
+	fs.t.Helper()
+	select {
+	case c := <-fs.conns:
+		return c
+	case <-time.After(5 * time.Second):
+		fs.t.Fatal("no websocket connection")
+		return nil
+	}
+}
+
+func (fs *fakeServer) next(msgType string) map[string]any {
+	fs.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-fs.received:
+			if m["type"] == msgType {
+				return m
+			}
+			if data, ok := m["data"].(map[string]any); ok && data["type"] == msgType {
+				return data
+			}
+		case <-timeout:
+			fs.t.Fatalf("no %s message received", msgType)
+			return nil
+		}
+	}
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg any) {
+	t.Helper()
+	if err := conn.WriteJSON([]any{"message", msg}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func sendCollab(t *testing.T, conn *websocket.Conn, data map[string]any) {
+	t.Helper()
+	send(t, conn, map[string]any{"type": "COLLABROOM", "data": data})
+}
+
+func clientVarsMessage(text string, rev int) map[string]any {
+	pool := apool.NewAPool()
+	return map[string]any{
+		"type": "CLIENT_VARS",
+		"data": map[string]any{
+			"userId":   "a.self",
+			"padId":    "test",
+			"readonly": false,
+			"collab_client_vars": map[string]any{
+				"initialAttributedText": apool.AText{Text: text, Attribs: "|1+" + utils.NumToString(len(text))},
+				"apool":                 pool.ToJsonable(),
+				"rev":                   rev,
+			},
+		},
+	}
+}
+
+func waitFor[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		var zero T
+		return zero
+	}
+}
+
+func connectPad(t *testing.T, fs *fakeServer, opts Options, setup func(c *Client)) (*Client, *websocket.Conn) {
+	t.Helper()
+	c, err := New(fs.url("/p/test"), opts)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if setup != nil {
+		setup(c)
+	}
+	result := make(chan error, 1)
+	go func() { result <- c.Connect(context.Background()) }()
+	conn := fs.conn()
+	ready := fs.next("CLIENT_READY")
+	if ready["padId"] != "test" || ready["token"] != c.Token() {
+		t.Fatalf("unexpected CLIENT_READY: %v", ready)
+	}
+	send(t, conn, clientVarsMessage("hello\n", 0))
+	if err := waitFor(t, result); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c, conn
+}
+
+func TestClientRebasesLocalEditsOverRemoteChanges(t *testing.T) {
+	fs := newFakeServer(t)
+	changes := make(chan ChangeEvent, 4)
+	commits := make(chan CommitEvent, 4)
+	c, conn := connectPad(t, fs, Options{}, func(c *Client) {
+		c.OnChange(func(ev ChangeEvent) { changes <- ev })
+		c.OnCommit(func(ev CommitEvent) { commits <- ev })
+	})
+
+	if err := c.Append(" world"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if c.Text() != "hello world\n" {
+		t.Fatalf("local text = %q", c.Text())
+	}
+	userChanges := fs.next("USER_CHANGES")
+	if userChanges["baseRev"] != float64(0) {
+		t.Fatalf("baseRev = %v", userChanges["baseRev"])
+	}
+
+	// Another author inserted "X" at the start before our commit arrived.
+	remotePool := apool.NewAPool()
+	remoteAttribs := ""
+	remote, err := changeset.MakeSplice("hello\n", 0, 0, "X", &remoteAttribs, &remotePool)
+	if err != nil {
+		t.Fatalf("splice: %v", err)
+	}
+	sendCollab(t, conn, map[string]any{
+		"type": "NEW_CHANGES", "newRev": 1, "changeset": remote,
+		"apool": remotePool.ToJsonable(), "author": "a.other", "currentTime": 1000,
+	})
+	ev := waitFor(t, changes)
+	if ev.Rev != 1 || ev.Author != "a.other" || ev.Text != "Xhello world\n" {
+		t.Fatalf("unexpected change event: %+v", ev)
+	}
+
+	sendCollab(t, conn, map[string]any{"type": "ACCEPT_COMMIT", "newRev": 2})
+	if commit := waitFor(t, commits); commit.Rev != 2 {
+		t.Fatalf("commit rev = %d", commit.Rev)
+	}
+	if c.Rev() != 2 || c.Pending() {
+		t.Fatalf("rev = %d, pending = %v", c.Rev(), c.Pending())
+	}
+	if c.Text() != "Xhello world\n" {
+		t.Fatalf("text = %q", c.Text())
+	}
+}
+
+func TestClientQueuesEditsWhileCommitInFlight(t *testing.T) {
+	fs := newFakeServer(t)
+	c, conn := connectPad(t, fs, Options{}, nil)
+
+	if err := c.Insert(0, "a"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	fs.next("USER_CHANGES")
+	if err := c.Insert(1, "b", apool.Attribute{Key: "bold", Value: "true"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	sendCollab(t, conn, map[string]any{"type": "ACCEPT_COMMIT", "newRev": 1})
+	second := fs.next("USER_CHANGES")
+	if second["baseRev"] != float64(1) {
+		t.Fatalf("queued changeset sent against baseRev %v", second["baseRev"])
+	}
+	gotPool := apool.NewAPool()
+	raw, _ := json.Marshal(second["apool"])
+	var jsonPool apool.APool
+	if err := json.Unmarshal(raw, &jsonPool); err != nil {
+		t.Fatalf("pool: %v", err)
+	}
+	gotPool.FromJsonable(jsonPool)
+	var hasBold, hasAuthor bool
+	for _, attr := range gotPool.NumToAttrib {
+		hasBold = hasBold || (attr.Key == "bold" && attr.Value == "true")
+		hasAuthor = hasAuthor || (attr.Key == "author" && attr.Value == "a.self")
+	}
+	if !hasBold || !hasAuthor {
+		t.Fatalf("wire pool misses attributes: %+v", gotPool.NumToAttrib)
+	}
+	if c.Text() != "abhello\n" {
+		t.Fatalf("text = %q", c.Text())
+	}
+}
+
+func TestClientSpliceValidation(t *testing.T) {
+	fs := newFakeServer(t)
+	c, _ := connectPad(t, fs, Options{}, nil)
+
+	tests := []struct {
+		name  string
+		start int
+		ndel  int
+	}{
+		{name: "negative start", start: -1},
+		{name: "negative delete", ndel: -1},
+		{name: "out of bounds", start: 3, ndel: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Splice(tt.start, tt.ndel, "x"); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+	if err := c.SubmitSheetOp(sheet.Op{Type: sheet.OpSetCell, Sheet: "s1", Raw: new(string)}); err != ErrWrongDocumentType {
+		t.Fatalf("sheet op on pad: %v", err)
+	}
+}
+
+func TestClientReadOnlyRejectsEdits(t *testing.T) {
+	fs := newFakeServer(t)
+	c, _ := connectPad(t, fs, Options{ReadOnly: true}, nil)
+	if err := c.Append("x"); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestClientChatAndPresence(t *testing.T) {
+	fs := newFakeServer(t)
+	chats := make(chan ChatEvent, 4)
+	joins := make(chan UserJoinEvent, 4)
+	leaves := make(chan UserLeaveEvent, 4)
+	c, conn := connectPad(t, fs, Options{}, func(c *Client) {
+		c.OnChat(func(ev ChatEvent) { chats <- ev })
+		c.OnUserJoin(func(ev UserJoinEvent) { joins <- ev })
+		c.OnUserLeave(func(ev UserLeaveEvent) { leaves <- ev })
+	})
+
+	if err := c.SendChat("hi"); err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	chat := fs.next("CHAT_MESSAGE")
+	if msg := chat["message"].(map[string]any); msg["text"] != "hi" {
+		t.Fatalf("chat payload: %v", chat)
+	}
+	sendCollab(t, conn, map[string]any{"type": "CHAT_MESSAGE", "message": map[string]any{
+		"text": "hey", "time": 5000, "authorId": "a.other", "displayName": "Other",
+	}})
+	if ev := waitFor(t, chats); ev.AuthorId != "a.other" || ev.DisplayName != "Other" || ev.Text != "hey" {
+		t.Fatalf("chat event: %+v", ev)
+	}
+
+	// Our own USER_NEWINFO is ignored, updates for known users do not re-join.
+	sendCollab(t, conn, map[string]any{"type": "USER_NEWINFO", "userInfo": map[string]any{"userId": "a.self", "colorId": "#000"}})
+	sendCollab(t, conn, map[string]any{"type": "USER_NEWINFO", "userInfo": map[string]any{"userId": "a.other", "name": "Other", "colorId": "#fff"}})
+	sendCollab(t, conn, map[string]any{"type": "USER_NEWINFO", "userInfo": map[string]any{"userId": "a.other", "name": "Renamed", "colorId": "#fff"}})
+	if ev := waitFor(t, joins); ev.User.Id != "a.other" || ev.User.Name != "Other" {
+		t.Fatalf("join event: %+v", ev)
+	}
+	sendCollab(t, conn, map[string]any{"type": "USER_LEAVE", "userInfo": map[string]any{"userId": "a.other"}})
+	if ev := waitFor(t, leaves); ev.User.Name != "Renamed" {
+		t.Fatalf("leave event: %+v", ev)
+	}
+	select {
+	case ev := <-joins:
+		t.Fatalf("unexpected join: %+v", ev)
+	default:
+	}
+	if len(c.Users()) != 0 {
+		t.Fatalf("users = %v", c.Users())
+	}
+}
+
+func TestClientAccessDenied(t *testing.T) {
+	fs := newFakeServer(t)
+	c, err := New(fs.url("/p/test"), Options{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	result := make(chan error, 1)
+	go func() { result <- c.Connect(context.Background()) }()
+	conn := fs.conn()
+	fs.next("CLIENT_READY")
+	send(t, conn, map[string]any{"accessStatus": "deny"})
+	err = waitFor(t, result)
+	var accessErr *AccessError
+	if !errors.As(err, &accessErr) || accessErr.Status != "deny" {
+		t.Fatalf("expected access error, got %v", err)
+	}
+	waitFor(t, c.Done())
+}
+
+func TestClientReconnectResendsUnacknowledgedChanges(t *testing.T) {
+	fs := newFakeServer(t)
+	reconnects := make(chan ReconnectEvent, 1)
+	disconnects := make(chan DisconnectEvent, 4)
+	c, conn := connectPad(t, fs, Options{Reconnect: true, ReconnectBackoff: 10 * time.Millisecond}, func(c *Client) {
+		c.OnReconnect(func(ev ReconnectEvent) { reconnects <- ev })
+		c.OnDisconnect(func(ev DisconnectEvent) { disconnects <- ev })
+	})
+
+	if err := c.Append("!"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	fs.next("USER_CHANGES")
+	_ = conn.Close()
+	if ev := waitFor(t, disconnects); !ev.Reconnecting {
+		t.Fatalf("disconnect event: %+v", ev)
+	}
+
+	conn = fs.conn()
+	ready := fs.next("CLIENT_READY")
+	if ready["reconnect"] != true || ready["client_rev"] != float64(0) {
+		t.Fatalf("reconnect CLIENT_READY: %v", ready)
+	}
+	// The commit never made it: the server is still at rev 0.
+	sendCollab(t, conn, map[string]any{"type": "CLIENT_RECONNECT", "noChanges": true, "newRev": 0, "headRev": 0})
+	if ev := waitFor(t, reconnects); ev.Rev != 0 {
+		t.Fatalf("reconnect rev = %d", ev.Rev)
+	}
+	resent := fs.next("USER_CHANGES")
+	if resent["baseRev"] != float64(0) {
+		t.Fatalf("resent baseRev = %v", resent["baseRev"])
+	}
+	if c.Text() != "hello!\n" {
+		t.Fatalf("text = %q", c.Text())
+	}
+}
+
+func TestClientSheetOps(t *testing.T) {
+	fs := newFakeServer(t)
+	ops := make(chan SheetOpEvent, 4)
+	c, err := New(fs.url("/s/budget"), Options{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	c.OnSheetOp(func(ev SheetOpEvent) { ops <- ev })
+	result := make(chan error, 1)
+	go func() { result <- c.Connect(context.Background()) }()
+	conn := fs.conn()
+	if ready := fs.next("CLIENT_READY"); ready["component"] != "sheet" {
+		t.Fatalf("CLIENT_READY: %v", ready)
+	}
+	wb := sheet.NewWorkbook()
+	wb.AddSheet("s1", "Sheet1")
+	send(t, conn, map[string]any{"type": "SHEET_VARS", "data": map[string]any{
+		"snapshot": wb.Snapshot(), "head": 3, "userId": "a.self",
+	}})
+	if err := waitFor(t, result); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(c.Close)
+
+	raw := "42"
+	if err := c.SubmitSheetOp(sheet.Op{Type: sheet.OpSetCell, Sheet: "s1", Row: 2, Col: 0, Raw: &raw}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	submitted := fs.next("SHEET_OP")
+	if submitted["baseRev"] != float64(3) {
+		t.Fatalf("SHEET_OP baseRev = %v", submitted["baseRev"])
+	}
+
+	// A concurrent row insert above shifts the pending op down.
+	remote, _ := json.Marshal(sheet.Op{Type: sheet.OpInsertRows, Sheet: "s1", Index: 0, Count: 1})
+	sendCollab(t, conn, map[string]any{"type": "NEW_SHEET_OP", "newRev": 4, "op": json.RawMessage(remote), "author": "a.other"})
+	if ev := waitFor(t, ops); ev.Local || ev.Rev != 4 {
+		t.Fatalf("remote op event: %+v", ev)
+	}
+	sendCollab(t, conn, map[string]any{"type": "ACCEPT_SHEET_OP", "newRev": 5})
+	ev := waitFor(t, ops)
+	if !ev.Local || ev.Rev != 5 || ev.Op.Row != 3 {
+		t.Fatalf("local op event: %+v", ev)
+	}
+	if got := c.Workbook().SheetByID("s1").GetCell(sheet.CellRef{Row: 3, Col: 0}).Raw; got != "42" {
+		t.Fatalf("cell = %q", got)
+	}
+	if c.Rev() != 5 || c.Pending() {
+		t.Fatalf("rev = %d, pending = %v", c.Rev(), c.Pending())
+	}
+}
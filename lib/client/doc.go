@@ -0,0 +1,39 @@
+// Package client is a headless Go client for the Etherpad collaborative
+// protocol. It speaks the same websocket messages as the browser editor
+// (CLIENT_READY, USER_CHANGES, NEW_CHANGES, ACCEPT_COMMIT, CHAT_MESSAGE,
+// SHEET_OP, ...) and keeps a live model of the document so bots and
+// integrations can read and edit pads without a browser.
+//
+// A text pad is opened with Dial (or New followed by Connect when handlers
+// must be registered before the initial state arrives):
+//
+//	c, err := client.New("http://127.0.0.1:9001/p/standup", client.Options{
+//		Name:      "notes-bot",
+//		Reconnect: true,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	c.OnChat(func(ev client.ChatEvent) {
+//		if ev.Text == "!summary" {
+//			_ = c.SendChat(summarize(c.Text()))
+//		}
+//	})
+//	if err := c.Connect(ctx); err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	_ = c.Append("Meeting started\n", apool.Attribute{Key: "bold", Value: "true"})
+//
+// Local edits are applied to the model immediately and sent to the server
+// one changeset at a time, exactly like the browser: while a commit is in
+// flight further edits are composed into a single outgoing changeset, and
+// incoming changes from other authors are rebased over both with
+// changeset.Follow. When Options.Reconnect is set a dropped connection is
+// re-established with CLIENT_READY{reconnect: true} and the revisions missed
+// in the meantime are replayed before pending local edits are resent.
+//
+// Spreadsheet documents (/s/:pad) are opened the same way; the client then
+// keeps a sheet.Workbook instead of an AText and edits are submitted with
+// SubmitSheetOp.
+package client
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"time"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// User is a participant of the pad as announced by USER_NEWINFO.
+type User struct {
+	Id      string
+	Name    string
+	ColorId string
+}
+
+// ChangeEvent is emitted after a revision from another author (or a
+// server-side correction) has been applied to the local model.
+type ChangeEvent struct {
+	Rev       int
+	Author    string
+	Changeset string    // rebased onto the local model, relative to the client's pool
+	Text      string    // document text after the change, including pending local edits
+	Time      time.Time // server time of the revision
+	Received  time.Time // when the revision arrived at this client
+}
+
+// CommitEvent is emitted when the server accepted a local changeset. Latency
+// is the time between sending USER_CHANGES and receiving ACCEPT_COMMIT.
+type CommitEvent struct {
+	Rev     int
+	Sent    time.Time
+	Latency time.Duration
+}
+
+// ChatEvent is a chat message broadcast to the pad, including the client's own.
+type ChatEvent struct {
+	AuthorId    string
+	DisplayName string
+	Text        string
+	Time        time.Time
+}
+
+// UserJoinEvent is emitted the first time another user is announced.
+type UserJoinEvent struct {
+	User User
+}
+
+// UserLeaveEvent is emitted when another user disconnects from the pad.
+type UserLeaveEvent struct {
+	User User
+}
+
+// SheetOpEvent is emitted after a sheet op has been applied to the local
+// workbook. Local is true for the client's own ops once the server accepted
+// them; Sent and Latency are only set for those.
+type SheetOpEvent struct {
+	Op       sheet.Op
+	Rev      int
+	Author   string
+	Local    bool
+	Received time.Time
+	Sent     time.Time
+	Latency  time.Duration
+}
+
+// DisconnectEvent is emitted when the websocket closes. Reconnecting reports
+// whether the client will try to re-establish the connection.
+type DisconnectEvent struct {
+	Err          error
+	Reconnecting bool
+}
+
+// ReconnectEvent is emitted once a reconnect finished catching up with the
+// server head revision.
+type ReconnectEvent struct {
+	Rev int
+}
+
+type handlers struct {
+	connect    []func()
+	change     []func(ChangeEvent)
+	commit     []func(CommitEvent)
+	chat       []func(ChatEvent)
+	userJoin   []func(UserJoinEvent)
+	userLeave  []func(UserLeaveEvent)
+	sheetOp    []func(SheetOpEvent)
+	sheetReset []func()
+	disconnect []func(DisconnectEvent)
+	reconnect  []func(ReconnectEvent)
+}
+
+// OnConnect registers a handler called once the initial document state
+// (CLIENT_VARS or SHEET_VARS) has been loaded.
+func (c *Client) OnConnect(fn func()) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.connect = append(c.handlers.connect, fn)
+}
+
+// OnChange registers a handler for changes made by other authors.
+func (c *Client) OnChange(fn func(ChangeEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.change = append(c.handlers.change, fn)
+}
+
+// OnCommit registers a handler for accepted local changesets.
+func (c *Client) OnCommit(fn func(CommitEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.commit = append(c.handlers.commit, fn)
+}
+
+// OnChat registers a handler for chat messages.
+func (c *Client) OnChat(fn func(ChatEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.chat = append(c.handlers.chat, fn)
+}
+
+// OnUserJoin registers a handler for users joining the pad.
+func (c *Client) OnUserJoin(fn func(UserJoinEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.userJoin = append(c.handlers.userJoin, fn)
+}
+
+// OnUserLeave registers a handler for users leaving the pad.
+func (c *Client) OnUserLeave(fn func(UserLeaveEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.userLeave = append(c.handlers.userLeave, fn)
+}
+
+// OnSheetOp registers a handler for ops applied to the local workbook.
+func (c *Client) OnSheetOp(fn func(SheetOpEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.sheetOp = append(c.handlers.sheetOp, fn)
+}
+
+// OnSheetReset registers a handler called when the server replaced the whole
+// workbook (e.g. after an xlsx import) and the client reloaded it.
+func (c *Client) OnSheetReset(fn func()) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.sheetReset = append(c.handlers.sheetReset, fn)
+}
+
+// OnDisconnect registers a handler for closed connections.
+func (c *Client) OnDisconnect(fn func(DisconnectEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.disconnect = append(c.handlers.disconnect, fn)
+}
+
+// OnReconnect registers a handler for completed reconnects.
+func (c *Client) OnReconnect(fn func(ReconnectEvent)) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers.reconnect = append(c.handlers.reconnect, fn)
+}
+
+// emit queues fn for the dispatch goroutine. Handlers run one at a time in
+// the order the underlying messages arrived, never under the model lock, so
+// they may call back into the client.
+func (c *Client) emit(fn func(h *handlers)) {
+	select {
+	case c.events <- fn:
+	case <-c.done:
+	}
+}
+
+func (c *Client) dispatch() {
+	for {
+		select {
+		case fn := <-c.events:
+			c.hmu.RLock()
+			h := c.handlers
+			c.hmu.RUnlock()
+			fn(&h)
+		case <-c.done:
+			return
+		}
+	}
+}
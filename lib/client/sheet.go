@@ -0,0 +1,182 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// sheetState is the live model of a sheet document. confirmed is the
+// workbook at rev as ordered by the server; pending holds local ops not yet
+// accepted, pending[0] being in flight when inFlight is set. The visible
+// workbook is confirmed with the pending ops applied on top.
+type sheetState struct {
+	confirmed *sheet.Workbook
+	rev       int
+	pending   []sheet.Op
+	inFlight  bool
+	sentAt    time.Time
+}
+
+// Workbook returns a copy of the current workbook including pending local
+// ops. It returns nil before the sheet has loaded.
+func (c *Client) Workbook() *sheet.Workbook {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sheet.confirmed == nil {
+		return nil
+	}
+	wb := c.sheet.confirmed.Clone()
+	for _, op := range c.sheet.pending {
+		if err := wb.Apply(op); err != nil {
+			c.logger.Debugf("pending sheet op no longer applies: %v", err)
+		}
+	}
+	return wb
+}
+
+// SubmitSheetOp validates op, applies it to the local workbook and sends it
+// to the server. Ops are sent one at a time; ops submitted while one is in
+// flight are rebased over concurrent remote ops before they are sent.
+func (c *Client) SubmitSheetOp(op sheet.Op) error {
+	if err := op.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.checkWritable("sheet"); err != nil {
+		return err
+	}
+	c.sheet.pending = append(c.sheet.pending, op)
+	c.flushSheet()
+	return nil
+}
+
+// flushSheet sends the next pending op when nothing is in flight. Called with
+// c.mu held.
+func (c *Client) flushSheet() {
+	if c.sheet.inFlight || len(c.sheet.pending) == 0 || !c.connected {
+		return
+	}
+	op := c.sheet.pending[0]
+	op.BaseRev = c.sheet.rev
+	encoded, err := json.Marshal(op)
+	if err != nil {
+		c.logger.Warnf("marshal sheet op: %v", err)
+		c.sheet.pending = c.sheet.pending[1:]
+		return
+	}
+	c.sheet.inFlight = true
+	c.sheet.sentAt = time.Now()
+	if err := c.send(c.collab(sheetOpData{Type: "SHEET_OP", Op: encoded, BaseRev: op.BaseRev})); err != nil {
+		c.logger.Debugf("SHEET_OP not sent: %v", err)
+	}
+}
+
+// handleSheetVars (re)initializes the workbook. After a reconnect or a
+// SHEET_RELOAD the snapshot replaces the local state like in the browser
+// editor: an op that was in flight may or may not have been applied and is
+// dropped, while ops that were never sent are resubmitted on the new state.
+func (c *Client) handleSheetVars(vars sheetVars) error {
+	var snap sheet.WorkbookSnapshot
+	if err := json.Unmarshal(vars.Snapshot, &snap); err != nil {
+		return c.fail(fmt.Errorf("client: bad sheet snapshot: %w", err))
+	}
+	c.mu.Lock()
+	queued := c.sheet.pending
+	if c.sheet.inFlight && len(queued) > 0 {
+		queued = queued[1:]
+	}
+	reset := c.sheet.confirmed != nil
+	c.sheet = sheetState{
+		confirmed: sheet.WorkbookFromSnapshot(snap),
+		rev:       vars.Head,
+		pending:   append([]sheet.Op(nil), queued...),
+	}
+	first := c.markConnected(vars.UserId, vars.ReadOnly)
+	c.flushSheet()
+	rev := c.sheet.rev
+	c.mu.Unlock()
+	if reset {
+		c.emit(func(h *handlers) {
+			for _, fn := range h.sheetReset {
+				fn()
+			}
+		})
+	}
+	c.emitConnected(first, rev)
+	return nil
+}
+
+func (c *Client) handleNewSheetOp(room collabRoom) error {
+	var op sheet.Op
+	if err := json.Unmarshal(room.Op, &op); err != nil {
+		c.logger.Warnf("bad NEW_SHEET_OP: %v", err)
+		return nil
+	}
+	c.mu.Lock()
+	if c.sheet.confirmed == nil || room.NewRev <= c.sheet.rev {
+		c.mu.Unlock()
+		return nil
+	}
+	if room.NewRev != c.sheet.rev+1 {
+		c.mu.Unlock()
+		return fmt.Errorf("client: missed sheet ops (local %d, server %d)", c.sheet.rev, room.NewRev)
+	}
+	if err := c.sheet.confirmed.Apply(op); err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("client: cannot apply sheet op %d: %w", room.NewRev, err)
+	}
+	c.sheet.rev = room.NewRev
+	// The server orders this op before every pending local op, so rebase
+	// them exactly as sheet.Document.Submit will.
+	for i := range c.sheet.pending {
+		c.sheet.pending[i] = sheet.Transform(c.sheet.pending[i], op)
+	}
+	c.mu.Unlock()
+	ev := SheetOpEvent{Op: op, Rev: room.NewRev, Author: room.Author, Received: time.Now()}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.sheetOp {
+			fn(ev)
+		}
+	})
+	return nil
+}
+
+func (c *Client) handleAcceptSheetOp(newRev int) error {
+	c.mu.Lock()
+	if !c.sheet.inFlight || len(c.sheet.pending) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	op := c.sheet.pending[0]
+	op.BaseRev = c.sheet.rev
+	c.sheet.pending = c.sheet.pending[1:]
+	c.sheet.inFlight = false
+	if err := c.sheet.confirmed.Apply(op); err != nil {
+		c.logger.Warnf("accepted sheet op does not apply locally: %v", err)
+	}
+	c.sheet.rev = newRev
+	author := c.userId
+	sent := c.sheet.sentAt
+	c.flushSheet()
+	c.mu.Unlock()
+	ev := SheetOpEvent{
+		Op:       op,
+		Rev:      newRev,
+		Author:   author,
+		Local:    true,
+		Received: now,
+		Sent:     sent,
+		Latency:  now.Sub(sent),
+	}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.sheetOp {
+			fn(ev)
+		}
+	})
+	return nil
+}
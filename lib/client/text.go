@@ -0,0 +1,455 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/utils"
+)
+
+// textState is the live model of a text pad. atext always includes the local
+// edits that are in flight or still queued, exactly like the browser editor's
+// document; rev is the last server revision the model is based on.
+type textState struct {
+	atext apool.AText
+	pool  apool.APool
+	rev   int
+	// inFlight was sent as USER_CHANGES and awaits ACCEPT_COMMIT; outgoing
+	// collects edits made meanwhile, composed into one changeset.
+	inFlight *string
+	outgoing *string
+	// sentAt is when inFlight was (last) sent.
+	sentAt time.Time
+	// catchingUp is set while CLIENT_RECONNECT replays missed revisions.
+	catchingUp bool
+}
+
+// Text returns the current document text including pending local edits.
+func (c *Client) Text() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.text.atext.Text
+}
+
+// AText returns a copy of the current attributed text. Attribute numbers
+// refer to AttributePool.
+func (c *Client) AText() apool.AText {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return changeset.CloneAText(c.text.atext)
+}
+
+// AttributePool returns a copy of the client's attribute pool.
+func (c *Client) AttributePool() apool.APool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.text.pool.Clone()
+}
+
+// Rev returns the last server revision the local model is based on.
+func (c *Client) Rev() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.component == "sheet" {
+		return c.sheet.rev
+	}
+	return c.text.rev
+}
+
+// Pending reports whether local edits have not been accepted by the server
+// yet.
+func (c *Client) Pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.text.inFlight != nil || c.text.outgoing != nil || len(c.sheet.pending) > 0
+}
+
+// Splice replaces ndel characters at start (both in runes) with text. The
+// inserted text carries the given attributes plus the client's author
+// attribute. As with the HTTP API the document always keeps its trailing
+// newline.
+func (c *Client) Splice(start, ndel int, text string, attribs ...apool.Attribute) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.checkWritable("pad"); err != nil {
+		return err
+	}
+	if start < 0 {
+		return errors.New("client: start index must not be negative")
+	}
+	if ndel < 0 {
+		return errors.New("client: characters to delete must be non-negative")
+	}
+	orig := c.text.atext.Text
+	origLen := utf8.RuneCountInString(orig)
+	if start+ndel > origLen {
+		return errors.New("client: splice out of bounds")
+	}
+	willEndWithNewLine := start+ndel < origLen || strings.HasSuffix(text, "\n") ||
+		(text == "" && start > 0 && utils.RuneSlice(orig, start-1, start) == "\n")
+	if !willEndWithNewLine {
+		text += "\n"
+	}
+	if ndel == 0 && text == "" {
+		return nil
+	}
+	attribStr := ""
+	if text != "" {
+		attribStr = c.encodeAttribs(attribs, true)
+	}
+	cs, err := changeset.MakeSplice(orig, start, ndel, text, &attribStr, &c.text.pool)
+	if err != nil {
+		return err
+	}
+	return c.applyLocal(cs)
+}
+
+// Insert inserts text at pos.
+func (c *Client) Insert(pos int, text string, attribs ...apool.Attribute) error {
+	return c.Splice(pos, 0, text, attribs...)
+}
+
+// Delete removes n characters starting at start.
+func (c *Client) Delete(start, n int) error {
+	return c.Splice(start, n, "")
+}
+
+// Append adds text at the end of the document, before the trailing newline
+// (the same position the appendText HTTP API uses).
+func (c *Client) Append(text string, attribs ...apool.Attribute) error {
+	c.mu.Lock()
+	end := utf8.RuneCountInString(c.text.atext.Text) - 1
+	c.mu.Unlock()
+	return c.Splice(max(end, 0), 0, text, attribs...)
+}
+
+// SetText replaces the whole document.
+func (c *Client) SetText(text string, attribs ...apool.Attribute) error {
+	c.mu.Lock()
+	n := utf8.RuneCountInString(c.text.atext.Text)
+	c.mu.Unlock()
+	return c.Splice(0, n, text, attribs...)
+}
+
+// Format applies attributes to the characters in [start, end). An attribute
+// with an empty value removes that key, e.g. {Key: "bold", Value: ""}.
+func (c *Client) Format(start, end int, attribs ...apool.Attribute) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.checkWritable("pad"); err != nil {
+		return err
+	}
+	text := c.text.atext.Text
+	if start < 0 || end < start || end > utf8.RuneCountInString(text) {
+		return errors.New("client: format range out of bounds")
+	}
+	if start == end || len(attribs) == 0 {
+		return nil
+	}
+	b := newOpBuilder(utf8.RuneCountInString(text))
+	b.keepText(utils.RuneSlice(text, 0, start), "")
+	b.keepText(utils.RuneSlice(text, start, end), c.encodeAttribs(attribs, false))
+	return c.applyLocal(b.toString())
+}
+
+// SendChat posts a chat message to the pad.
+func (c *Client) SendChat(text string) error {
+	c.mu.Lock()
+	connected := c.connected
+	userId := c.userId
+	c.mu.Unlock()
+	if !connected {
+		return ErrNotConnected
+	}
+	msg := chatMessageData{Type: "CHAT_MESSAGE"}
+	msg.Message.Text = text
+	msg.Message.Time = time.Now().UnixMilli()
+	msg.Message.AuthorId = userId
+	return c.send(c.collab(msg))
+}
+
+func (c *Client) checkWritable(component string) error {
+	if c.component != component {
+		return ErrWrongDocumentType
+	}
+	if !c.reconnectOK {
+		return ErrNotConnected
+	}
+	if c.readOnly || c.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// encodeAttribs interns attribs into the client pool and returns the
+// attribute string. Inserts always carry the client's author: the server
+// rejects foreign authors on '+' ops, so a caller-supplied author is dropped.
+func (c *Client) encodeAttribs(attribs []apool.Attribute, insert bool) string {
+	entries := make([]apool.Attribute, 0, len(attribs)+1)
+	if insert {
+		entries = append(entries, apool.Attribute{Key: "author", Value: c.userId})
+	}
+	for _, a := range attribs {
+		if a.Key == "author" {
+			continue
+		}
+		entries = append(entries, a)
+	}
+	return changeset.NewAttributeMap(&c.text.pool).Update(entries, &insert).String()
+}
+
+// applyLocal applies a locally created changeset to the model and queues it
+// for the server. Called with c.mu held.
+func (c *Client) applyLocal(cs string) error {
+	next, err := changeset.ApplyToAText(cs, c.text.atext, c.text.pool)
+	if err != nil {
+		return err
+	}
+	if c.text.outgoing != nil {
+		composed, err := changeset.Compose(*c.text.outgoing, cs, &c.text.pool)
+		if err != nil {
+			return err
+		}
+		cs = *composed
+	}
+	c.text.atext = *next
+	c.text.outgoing = &cs
+	return c.flushText()
+}
+
+// flushText sends the outgoing changeset when nothing is in flight. Called
+// with c.mu held.
+func (c *Client) flushText() error {
+	if c.text.inFlight != nil || c.text.outgoing == nil || !c.connected || c.text.catchingUp {
+		return nil
+	}
+	c.text.inFlight, c.text.outgoing = c.text.outgoing, nil
+	c.text.sentAt = time.Now()
+	wire := changeset.PrepareForWire(*c.text.inFlight, c.text.pool)
+	msg := userChangesData{
+		Type:      "USER_CHANGES",
+		BaseRev:   c.text.rev,
+		Changeset: wire.Translated,
+		Apool:     wire.Pool.ToJsonable(),
+	}
+	if err := c.send(c.collab(msg)); err != nil {
+		// The connection is gone; the reconnect path resends it.
+		c.logger.Debugf("USER_CHANGES not sent: %v", err)
+	}
+	return nil
+}
+
+func (c *Client) handleClientVars(vars clientVars) error {
+	pool := wirePool(vars.CollabClientVars.Apool)
+	c.mu.Lock()
+	c.text = textState{
+		atext: vars.CollabClientVars.InitialAttributedText,
+		pool:  *pool,
+		rev:   vars.CollabClientVars.Rev,
+	}
+	first := c.markConnected(vars.UserId, vars.ReadOnly)
+	rev := c.text.rev
+	c.mu.Unlock()
+	c.emitConnected(first, rev)
+	return nil
+}
+
+// handleNewChanges applies a revision by another author. Revisions we cannot
+// apply in order mean the model is out of sync; the connection is dropped so
+// the reconnect path replays the gap.
+func (c *Client) handleNewChanges(room collabRoom) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if room.NewRev <= c.text.rev {
+		return nil
+	}
+	if room.NewRev != c.text.rev+1 {
+		return fmt.Errorf("client: missed revisions (local %d, server %d)", c.text.rev, room.NewRev)
+	}
+	return c.applyServerChange(room)
+}
+
+// applyServerChange rebases a server revision over the pending local edits
+// and applies it. Called with c.mu held.
+func (c *Client) applyServerChange(room collabRoom) error {
+	server := changeset.MoveOpsToNewPool(room.Changeset, wirePool(room.APool), &c.text.pool)
+	var err error
+	if c.text.inFlight != nil {
+		if server, err = c.transformPending(&c.text.inFlight, server); err != nil {
+			return err
+		}
+	}
+	if c.text.outgoing != nil {
+		if server, err = c.transformPending(&c.text.outgoing, server); err != nil {
+			return err
+		}
+	}
+	next, err := changeset.ApplyToAText(server, c.text.atext, c.text.pool)
+	if err != nil {
+		return fmt.Errorf("client: cannot apply revision %d: %w", room.NewRev, err)
+	}
+	c.text.atext = *next
+	c.text.rev = room.NewRev
+	ev := ChangeEvent{
+		Rev:       room.NewRev,
+		Author:    room.Author,
+		Changeset: server,
+		Text:      next.Text,
+		Time:      time.UnixMilli(room.CurrentTime),
+		Received:  time.Now(),
+	}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.change {
+			fn(ev)
+		}
+	})
+	return nil
+}
+
+// transformPending rebases the pending local changeset over server and the
+// server changeset over the pending one, returning the rebased server change.
+// This mirrors the server, which follows incoming changes over the revisions
+// it already has with reverseInsertOrder=false.
+func (c *Client) transformPending(pending **string, server string) (string, error) {
+	local, err := changeset.Follow(server, **pending, false, &c.text.pool)
+	if err != nil {
+		return "", err
+	}
+	rebased, err := changeset.Follow(**pending, server, true, &c.text.pool)
+	if err != nil {
+		return "", err
+	}
+	*pending = local
+	return *rebased, nil
+}
+
+func (c *Client) handleAcceptCommit(newRev int) error {
+	c.mu.Lock()
+	if c.text.inFlight == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	ev := CommitEvent{Rev: newRev, Sent: c.text.sentAt, Latency: now.Sub(c.text.sentAt)}
+	c.text.inFlight = nil
+	if newRev > c.text.rev {
+		c.text.rev = newRev
+	}
+	err := c.flushText()
+	c.mu.Unlock()
+	c.emit(func(h *handlers) {
+		for _, fn := range h.commit {
+			fn(ev)
+		}
+	})
+	return err
+}
+
+// handleClientReconnect processes one revision replayed after a reconnect.
+// A revision authored by this client while a changeset was in flight is that
+// changeset's acknowledgement; once the head is reached the in-flight
+// changeset (if it never made it) is folded back into the outgoing queue and
+// resent.
+func (c *Client) handleClientReconnect(room collabRoom) error {
+	c.mu.Lock()
+	c.text.catchingUp = true
+	if !room.NoChanges && room.NewRev > c.text.rev {
+		if room.NewRev != c.text.rev+1 {
+			c.mu.Unlock()
+			return fmt.Errorf("client: reconnect replay out of order (local %d, server %d)", c.text.rev, room.NewRev)
+		}
+		if room.Author == c.userId && c.text.inFlight != nil {
+			c.text.inFlight = nil
+			c.text.rev = room.NewRev
+		} else if err := c.applyServerChange(room); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
+	if !room.NoChanges && room.NewRev < room.HeadRev {
+		c.mu.Unlock()
+		return nil
+	}
+	c.text.catchingUp = false
+	if c.text.inFlight != nil {
+		pending := *c.text.inFlight
+		if c.text.outgoing != nil {
+			composed, err := changeset.Compose(pending, *c.text.outgoing, &c.text.pool)
+			if err != nil {
+				c.mu.Unlock()
+				return err
+			}
+			pending = *composed
+		}
+		c.text.inFlight, c.text.outgoing = nil, &pending
+	}
+	c.connected = true
+	err := c.flushText()
+	rev := c.text.rev
+	c.mu.Unlock()
+	c.emitConnected(false, rev)
+	return err
+}
+
+func (c *Client) handleChat(room collabRoom) {
+	m := room.Message
+	ev := ChatEvent{Text: m.Text}
+	switch {
+	case m.AuthorId != nil:
+		ev.AuthorId = *m.AuthorId
+	case m.UserId != nil:
+		ev.AuthorId = *m.UserId
+	}
+	switch {
+	case m.DisplayName != nil:
+		ev.DisplayName = *m.DisplayName
+	case m.UserName != nil:
+		ev.DisplayName = *m.UserName
+	}
+	if m.Time != nil {
+		ev.Time = time.UnixMilli(*m.Time)
+	}
+	c.emit(func(h *handlers) {
+		for _, fn := range h.chat {
+			fn(ev)
+		}
+	})
+}
+
+// opBuilder assembles a changeset from already encoded attribute strings,
+// which changeset.Builder cannot take from outside its package (see the
+// equivalent helper in paddiff).
+type opBuilder struct {
+	oldLen   int
+	assem    *changeset.SmartOpAssembler
+	charBank changeset.StringAssembler
+}
+
+func newOpBuilder(oldLen int) *opBuilder {
+	return &opBuilder{
+		oldLen:   oldLen,
+		assem:    changeset.NewSmartOpAssembler(),
+		charBank: changeset.NewStringAssembler(),
+	}
+}
+
+func (b *opBuilder) keepText(text string, attribs string) {
+	if text == "" {
+		return
+	}
+	for _, op := range changeset.OpsFromText("=", text, nil, nil) {
+		op.Attribs = attribs
+		b.assem.Append(op)
+	}
+}
+
+func (b *opBuilder) toString() string {
+	b.assem.EndDocument()
+	newLen := b.oldLen + b.assem.LengthChange()
+	return changeset.Pack(b.oldLen, newLen, b.assem.String(), b.charBank.String())
+}
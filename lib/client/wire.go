@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/ether/etherpad-go/lib/apool"
+)
+
+// serverMessage is the payload of a ["message", {...}] frame sent by the
+// server. Disconnect and AccessStatus frames carry no type.
+type serverMessage struct {
+	Type         string          `json:"type"`
+	Data         json.RawMessage `json:"data"`
+	Disconnect   string          `json:"disconnect"`
+	AccessStatus string          `json:"accessStatus"`
+}
+
+// clientVars is the subset of CLIENT_VARS the client needs. It is decoded
+// locally instead of via models/clientVars.ClientVars so plugin extras merged
+// into the payload by clientVars hooks cannot break decoding.
+type clientVars struct {
+	UserId            string `json:"userId"`
+	PadId             string `json:"padId"`
+	ReadOnly          bool   `json:"readonly"`
+	ChatHead          int    `json:"chatHead"`
+	NumConnectedUsers int    `json:"numConnectedUsers"`
+	CollabClientVars  struct {
+		InitialAttributedText apool.AText `json:"initialAttributedText"`
+		Apool                 apool.APool `json:"apool"`
+		Rev                   int         `json:"rev"`
+		Time                  int64       `json:"time"`
+	} `json:"collab_client_vars"`
+}
+
+// collabRoom is the union of the COLLABROOM sub-messages the client handles.
+type collabRoom struct {
+	Type string `json:"type"`
+
+	// NEW_CHANGES, CLIENT_RECONNECT, ACCEPT_COMMIT, NEW_SHEET_OP, ACCEPT_SHEET_OP
+	NewRev      int             `json:"newRev"`
+	HeadRev     int             `json:"headRev"`
+	Changeset   string          `json:"changeset"`
+	APool       apool.APool     `json:"apool"`
+	Author      string          `json:"author"`
+	CurrentTime int64           `json:"currentTime"`
+	NoChanges   bool            `json:"noChanges"`
+	Op          json.RawMessage `json:"op"`
+
+	// USER_NEWINFO, USER_LEAVE
+	UserInfo struct {
+		UserId  string  `json:"userId"`
+		Name    *string `json:"name"`
+		ColorId string  `json:"colorId"`
+	} `json:"userInfo"`
+
+	// CHAT_MESSAGE
+	Message struct {
+		Text        string  `json:"text"`
+		Time        *int64  `json:"time"`
+		UserId      *string `json:"userId"`
+		AuthorId    *string `json:"authorId"`
+		UserName    *string `json:"userName"`
+		DisplayName *string `json:"displayName"`
+	} `json:"message"`
+}
+
+// sheetVars mirrors ws.SheetVarsData.
+type sheetVars struct {
+	Snapshot  json.RawMessage `json:"snapshot"`
+	Head      int             `json:"head"`
+	UserId    string          `json:"userId"`
+	UserColor string          `json:"userColor"`
+	ReadOnly  bool            `json:"readonly"`
+}
+
+// outgoing wraps a client->server frame: {"event":"message","data":{...}}.
+type outgoing struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+type collabEnvelope struct {
+	Component string `json:"component"`
+	Type      string `json:"type"`
+	Data      any    `json:"data"`
+}
+
+type userChangesData struct {
+	Type      string      `json:"type"`
+	BaseRev   int         `json:"baseRev"`
+	Changeset string      `json:"changeset"`
+	Apool     apool.APool `json:"apool"`
+}
+
+type chatMessageData struct {
+	Type    string `json:"type"`
+	Message struct {
+		Text     string `json:"text"`
+		Time     int64  `json:"time"`
+		AuthorId string `json:"authorId,omitempty"`
+	} `json:"message"`
+}
+
+type sheetOpData struct {
+	Type    string          `json:"type"`
+	Op      json.RawMessage `json:"op"`
+	BaseRev int             `json:"baseRev"`
+}
+
+type userInfoUpdateData struct {
+	Type     string `json:"type"`
+	UserInfo struct {
+		UserId  string  `json:"userId"`
+		Name    *string `json:"name"`
+		ColorId *string `json:"colorId"`
+	} `json:"userInfo"`
+}
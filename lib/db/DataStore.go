@@ -34,6 +34,9 @@ type PadMethods interface {
 	GetRevision(padId string, rev int) (*db.PadSingleRevision, error)
 	RemoveRevisionsOfPad(padId string) error
 	GetRevisions(padId string, startRev int, endRev int) (*[]db.PadSingleRevision, error)
+	// GetRevisionAtTimestamp returns the last revision of the pad saved at or
+	// before timestamp, or nil when the pad has none that early.
+	GetRevisionAtTimestamp(padId string, timestamp int64) (*int, error)
 	GetPad(padID string) (*db.PadDB, error)
 	GetReadonlyPad(padId string) (*string, error)
 	SetReadOnlyId(padId string, readOnlyId string) error
@@ -77,7 +80,27 @@ type ChatMethods interface {
 	GetAuthorIdsOfPadChats(id string) (*[]string, error)
 	// ClearChatAuthorship nulls the authorship of all chat messages posted by
 	// the given author while preserving the messages themselves (GDPR erasure).
+	// Mentions of the author and their read state go as well.
 	ClearChatAuthorship(authorId string) error
+	// SetChatReplyTo marks the message at head as an answer to the one at
+	// replyTo.
+	SetChatReplyTo(padId string, head int, replyTo int) error
+	// EditChatMessage replaces the text of a message that is not deleted and
+	// keeps the replaced text as the next version of its edit history.
+	EditChatMessage(padId string, head int, text string, timestamp int64) error
+	// GetChatMessageEdits returns the earlier texts of a message, oldest first.
+	GetChatMessageEdits(padId string, head int) (*[]db.ChatMessageEditDB, error)
+	// DeleteChatMessage soft-deletes a message: the row stays so that replies
+	// keep their parent, but its text, edit history and mentions are dropped.
+	DeleteChatMessage(padId string, head int, deletedBy *string, timestamp int64) error
+	// SaveChatMentions replaces the authors a message mentions.
+	SaveChatMentions(padId string, head int, authorIds []string) error
+	// SaveChatReadHead records that the author has read the chat of the pad up
+	// to head. The read head never moves back.
+	SaveChatReadHead(padId string, authorId string, head int) error
+	// CountUnreadChatMentions counts the messages after the author's read head
+	// that mention the author and are not deleted.
+	CountUnreadChatMentions(padId string, authorId string) (int, error)
 }
 
 type ServerMethods interface {
@@ -143,6 +166,19 @@ type SheetMethods interface {
 	SaveSheetOp(padId string, rev int, op string, authorId *string, timestamp int64) error
 	GetSheetOps(padId string, startRev int, endRev int) (*[]db.SheetOpDB, error)
 	RemoveSheetOps(padId string) error
+	// SaveSheetSnapshot stores (or replaces) the workbook snapshot at rev.
+	SaveSheetSnapshot(padId string, rev int, snapshot string) error
+	// GetSheetSnapshotBefore returns the snapshot with the highest revision at
+	// or below rev.
+	GetSheetSnapshotBefore(padId string, rev int) (*db.SheetSnapshotDB, error)
+	RemoveSheetSnapshots(padId string) error
+	// SaveSheetComment stores (or replaces) one comment thread.
+	SaveSheetComment(padId string, threadId string, thread string) error
+	// GetSheetComments returns every comment thread of the sheet, ordered by
+	// thread id.
+	GetSheetComments(padId string) (*[]db.SheetCommentDB, error)
+	RemoveSheetComment(padId string, threadId string) error
+	RemoveSheetComments(padId string) error
 }
 
 type DataStore interface {
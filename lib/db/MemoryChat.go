@@ -0,0 +1,91 @@
+package db
+
+import (
+	"slices"
+
+	"github.com/ether/etherpad-go/lib/models/db"
+)
+
+func (m *MemoryDataStore) SetChatReplyTo(padId string, head int, replyTo int) error {
+	key := calcChatMessageKey(padId, head)
+	if msg, ok := m.chatPads[key]; ok {
+		msg.ReplyTo = &replyTo
+		m.chatPads[key] = msg
+	}
+	return nil
+}
+
+func (m *MemoryDataStore) EditChatMessage(padId string, head int, text string, timestamp int64) error {
+	key := calcChatMessageKey(padId, head)
+	msg, ok := m.chatPads[key]
+	if !ok || msg.DeletedAt != nil {
+		return nil
+	}
+	m.chatEdits[key] = append(m.chatEdits[key], db.ChatMessageEditDB{
+		PadId:   padId,
+		Head:    head,
+		Version: len(m.chatEdits[key]) + 1,
+		Message: msg.Message,
+		Time:    timestamp,
+	})
+	msg.Message = text
+	msg.EditedAt = &timestamp
+	m.chatPads[key] = msg
+	return nil
+}
+
+func (m *MemoryDataStore) GetChatMessageEdits(padId string, head int) (*[]db.ChatMessageEditDB, error) {
+	out := slices.Clone(m.chatEdits[calcChatMessageKey(padId, head)])
+	if out == nil {
+		out = make([]db.ChatMessageEditDB, 0)
+	}
+	return &out, nil
+}
+
+func (m *MemoryDataStore) DeleteChatMessage(padId string, head int, deletedBy *string, timestamp int64) error {
+	key := calcChatMessageKey(padId, head)
+	msg, ok := m.chatPads[key]
+	if !ok || msg.DeletedAt != nil {
+		return nil
+	}
+	msg.Message = ""
+	msg.DeletedAt = &timestamp
+	msg.DeletedBy = deletedBy
+	msg.Mentions = nil
+	m.chatPads[key] = msg
+	delete(m.chatEdits, key)
+	return nil
+}
+
+func (m *MemoryDataStore) SaveChatMentions(padId string, head int, authorIds []string) error {
+	key := calcChatMessageKey(padId, head)
+	if msg, ok := m.chatPads[key]; ok {
+		msg.Mentions = slices.Compact(slices.Sorted(slices.Values(authorIds)))
+		m.chatPads[key] = msg
+	}
+	return nil
+}
+
+func (m *MemoryDataStore) SaveChatReadHead(padId string, authorId string, head int) error {
+	if m.chatReads[padId] == nil {
+		m.chatReads[padId] = make(map[string]int)
+	}
+	if read, ok := m.chatReads[padId][authorId]; !ok || head > read {
+		m.chatReads[padId][authorId] = head
+	}
+	return nil
+}
+
+func (m *MemoryDataStore) CountUnreadChatMentions(padId string, authorId string) (int, error) {
+	read, ok := m.chatReads[padId][authorId]
+	if !ok {
+		read = -1
+	}
+	count := 0
+	for _, msg := range m.chatPads {
+		if msg.PadId == padId && msg.Head > read && msg.DeletedAt == nil && slices.Contains(msg.Mentions, authorId) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -18,6 +18,8 @@ type MemoryDataStore struct {
 	padRevisions  map[string]map[int]db.PadSingleRevision
 	authorStore   map[string]db.AuthorDB
 	chatPads      map[string]db.ChatMessageDB
+	chatEdits     map[string][]db.ChatMessageEditDB
+	chatReads     map[string]map[string]int
 	sessionStore  map[string]session2.Session
 	groupStore    map[string]string
 	serverVersion *db.ServerVersion
@@ -25,6 +27,8 @@ type MemoryDataStore struct {
 	secretParams  map[string]memorySecretRow
 	sheetStore    map[string]db.SheetDB
 	sheetOps      map[string]map[int]db.SheetOpDB
+	sheetSnaps    map[string]map[int]db.SheetSnapshotDB
+	sheetComments map[string]map[string]db.SheetCommentDB
 
 	// oidc
 	accessTokens           map[string]fosite.Requester
@@ -374,6 +378,21 @@ func (m *MemoryDataStore) GetRevision(padId string, rev int) (*db.PadSingleRevis
 	}, nil
 }
 
+func (m *MemoryDataStore) GetRevisionAtTimestamp(padId string, timestamp int64) (*int, error) {
+	var found *int
+	var foundTimestamp int64
+	for rev, revision := range m.padRevisions[padId] {
+		if revision.Timestamp > timestamp {
+			continue
+		}
+		if found == nil || revision.Timestamp > foundTimestamp || (revision.Timestamp == foundTimestamp && rev > *found) {
+			found = &rev
+			foundTimestamp = revision.Timestamp
+		}
+	}
+	return found, nil
+}
+
 func (m *MemoryDataStore) GetRevisions(padId string, startRev int, endRev int) (*[]db.PadSingleRevision, error) {
 	_, ok := m.padStore[padId]
 	if !ok {
@@ -468,6 +487,7 @@ func (m *MemoryDataStore) GetChatsOfPad(
 					displayName = authorFromDB.Name
 				}
 			}
+			chatMessage.Mentions = slices.Clone(chatMessage.Mentions)
 			chatMessages = append(chatMessages, db.ChatMessageDBWithDisplayName{
 				ChatMessageDB: chatMessage,
 				DisplayName:   displayName,
@@ -501,8 +521,15 @@ func (m *MemoryDataStore) ClearChatAuthorship(authorId string) error {
 	for k, chatMessage := range m.chatPads {
 		if chatMessage.AuthorId != nil && *chatMessage.AuthorId == authorId {
 			chatMessage.AuthorId = nil
-			m.chatPads[k] = chatMessage
 		}
+		if chatMessage.DeletedBy != nil && *chatMessage.DeletedBy == authorId {
+			chatMessage.DeletedBy = nil
+		}
+		chatMessage.Mentions = slices.DeleteFunc(chatMessage.Mentions, func(id string) bool { return id == authorId })
+		m.chatPads[k] = chatMessage
+	}
+	for _, reads := range m.chatReads {
+		delete(reads, authorId)
 	}
 	return nil
 }
@@ -513,6 +540,12 @@ func (m *MemoryDataStore) RemoveChat(padId string) error {
 			delete(m.chatPads, k)
 		}
 	}
+	for k := range m.chatEdits {
+		if strings.HasPrefix(k, padId+":") {
+			delete(m.chatEdits, k)
+		}
+	}
+	delete(m.chatReads, padId)
 	return nil
 }
 
@@ -919,12 +952,16 @@ func NewMemoryDataStore() *MemoryDataStore {
 		padRevisions:           make(map[string]map[int]db.PadSingleRevision),
 		authorStore:            make(map[string]db.AuthorDB),
 		chatPads:               make(map[string]db.ChatMessageDB),
+		chatEdits:              make(map[string][]db.ChatMessageEditDB),
+		chatReads:              make(map[string]map[string]int),
 		sessionStore:           make(map[string]session2.Session),
 		groupStore:             make(map[string]string),
 		oidcStorage:            make(map[string]string),
 		secretParams:           make(map[string]memorySecretRow),
 		sheetStore:             make(map[string]db.SheetDB),
 		sheetOps:               make(map[string]map[int]db.SheetOpDB),
+		sheetSnaps:             make(map[string]map[int]db.SheetSnapshotDB),
+		sheetComments:          make(map[string]map[string]db.SheetCommentDB),
 		accessTokens:           make(map[string]fosite.Requester),
 		accessTokenRequestIDs:  make(map[string]string),
 		refreshTokens:          make(map[string]db.StoreRefreshToken),
@@ -2,6 +2,8 @@ package db
 
 import (
 	"errors"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/ether/etherpad-go/lib/models/db"
@@ -33,6 +35,8 @@ func (m *MemoryDataStore) DoesSheetExist(padId string) (*bool, error) {
 func (m *MemoryDataStore) RemoveSheet(padId string) error {
 	delete(m.sheetStore, padId)
 	delete(m.sheetOps, padId)
+	delete(m.sheetSnaps, padId)
+	delete(m.sheetComments, padId)
 	return nil
 }
 
@@ -61,3 +65,55 @@ func (m *MemoryDataStore) GetSheetOps(padId string, startRev int, endRev int) (*
 	}
 	return &out, nil
 }
+
+func (m *MemoryDataStore) SaveSheetSnapshot(padId string, rev int, snapshot string) error {
+	if m.sheetSnaps[padId] == nil {
+		m.sheetSnaps[padId] = make(map[int]db.SheetSnapshotDB)
+	}
+	m.sheetSnaps[padId][rev] = db.SheetSnapshotDB{PadId: padId, Rev: rev, Snapshot: snapshot}
+	return nil
+}
+
+func (m *MemoryDataStore) GetSheetSnapshotBefore(padId string, rev int) (*db.SheetSnapshotDB, error) {
+	var found *db.SheetSnapshotDB
+	for r, snap := range m.sheetSnaps[padId] {
+		if r <= rev && (found == nil || r > found.Rev) {
+			found = &snap
+		}
+	}
+	if found == nil {
+		return nil, errors.New(SheetSnapshotNotFoundError)
+	}
+	return found, nil
+}
+
+func (m *MemoryDataStore) RemoveSheetSnapshots(padId string) error {
+	delete(m.sheetSnaps, padId)
+	return nil
+}
+
+func (m *MemoryDataStore) SaveSheetComment(padId string, threadId string, thread string) error {
+	if m.sheetComments[padId] == nil {
+		m.sheetComments[padId] = make(map[string]db.SheetCommentDB)
+	}
+	m.sheetComments[padId][threadId] = db.SheetCommentDB{PadId: padId, ThreadId: threadId, Thread: thread}
+	return nil
+}
+
+func (m *MemoryDataStore) GetSheetComments(padId string) (*[]db.SheetCommentDB, error) {
+	out := make([]db.SheetCommentDB, 0, len(m.sheetComments[padId]))
+	for _, id := range slices.Sorted(maps.Keys(m.sheetComments[padId])) {
+		out = append(out, m.sheetComments[padId][id])
+	}
+	return &out, nil
+}
+
+func (m *MemoryDataStore) RemoveSheetComment(padId string, threadId string) error {
+	delete(m.sheetComments[padId], threadId)
+	return nil
+}
+
+func (m *MemoryDataStore) RemoveSheetComments(padId string) error {
+	delete(m.sheetComments, padId)
+	return nil
+}
@@ -64,12 +64,17 @@ func (d MysqlDB) CreatePad(padID string, padDB db.PadDB) error {
 		return fmt.Errorf("error marshaling pool: %w", err)
 	}
 
+	fork, err := forkToDB(padDB.Fork)
+	if err != nil {
+		return err
+	}
+
 	resultedSQL, args, err := mysql.
 		Insert("pad").
 		Columns("id", "head", "saved_revisions", "readonly_id", "pool", "chat_head",
-			"public_status", "document_type", "atext_text", "atext_attribs").
+			"public_status", "document_type", "fork", "atext_text", "atext_attribs").
 		Values(padID, padDB.Head, string(savedRevisions), padDB.ReadOnlyId, string(pool),
-			padDB.ChatHead, padDB.PublicStatus, padDB.DocumentType, padDB.ATextText, padDB.ATextAttribs).
+			padDB.ChatHead, padDB.PublicStatus, padDB.DocumentType, fork, padDB.ATextText, padDB.ATextAttribs).
 		Suffix(`ON DUPLICATE KEY UPDATE
 			head = VALUES(head),
 			saved_revisions = VALUES(saved_revisions),
@@ -78,6 +83,7 @@ func (d MysqlDB) CreatePad(padID string, padDB db.PadDB) error {
 			chat_head = VALUES(chat_head),
 			public_status = VALUES(public_status),
 			document_type = VALUES(document_type),
+			fork = VALUES(fork),
 			atext_text = VALUES(atext_text),
 			atext_attribs = VALUES(atext_attribs)`).
 		ToSql()
@@ -93,7 +99,7 @@ func (d MysqlDB) CreatePad(padID string, padDB db.PadDB) error {
 func (d MysqlDB) GetPad(padID string) (*db.PadDB, error) {
 	resultedSQL, args, err := mysql.
 		Select("id", "head", "saved_revisions", "readonly_id", "pool", "chat_head",
-			"public_status", "document_type", "atext_text", "atext_attribs", "created_at", "updated_at").
+			"public_status", "document_type", "fork", "atext_text", "atext_attribs", "created_at", "updated_at").
 		From("pad").
 		Where(sq.Eq{"id": padID}).
 		ToSql()
@@ -598,6 +604,30 @@ func (d MysqlDB) GetRevision(padId string, rev int) (*db.PadSingleRevision, erro
 	return &revisionDB, nil
 }
 
+func (d MysqlDB) GetRevisionAtTimestamp(padId string, timestamp int64) (*int, error) {
+	resultedSQL, args, err := mysql.
+		Select("rev").
+		From("padRev").
+		Where(sq.Eq{"id": padId}).
+		Where(sq.LtOrEq{"timestamp": timestamp}).
+		OrderBy("timestamp DESC", "rev DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rev int
+	err = d.sqlDB.QueryRow(resultedSQL, args...).Scan(&rev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning revision: %w", err)
+	}
+	return &rev, nil
+}
+
 func (d MysqlDB) GetRevisions(padId string, startRev int, endRev int) (*[]db.PadSingleRevision, error) {
 	padExists, err := d.DoesPadExist(padId)
 	if err != nil {
@@ -708,7 +738,8 @@ func (d MysqlDB) GetChatsOfPad(
 	end int,
 ) (*[]db.ChatMessageDBWithDisplayName, error) {
 	resultedSQL, args, err := mysql.
-		Select("pc.padId", "pc.padHead", "pc.chatText", "pc.authorId", "pc.timestamp", "ga.name").
+		Select("pc.padId", "pc.padHead", "pc.chatText", "pc.authorId", "pc.timestamp", "ga.name",
+			"pc.reply_to", "pc.edited_at", "pc.deleted_at", "pc.deleted_by").
 		From("padChat pc").
 		LeftJoin("globalAuthor ga ON ga.id = pc.authorId").
 		Where(sq.Eq{"pc.padId": padId}).
@@ -733,13 +764,36 @@ func (d MysqlDB) GetChatsOfPad(
 		if err := query.Scan(
 			&chatMessage.PadId, &chatMessage.Head, &chatMessage.Message,
 			&chatMessage.AuthorId, &chatMessage.Time, &chatMessage.DisplayName,
+			&chatMessage.ReplyTo, &chatMessage.EditedAt, &chatMessage.DeletedAt, &chatMessage.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
 		chatMessages = append(chatMessages, chatMessage)
 	}
+	if err := query.Err(); err != nil {
+		return nil, err
+	}
 
-	return &chatMessages, query.Err()
+	mentionSQL, args, err := mysql.
+		Select("head", "author_id").
+		From("pad_chat_mention").
+		Where(sq.Eq{"id": padId}).
+		Where(sq.GtOrEq{"head": start}).
+		Where(sq.LtOrEq{"head": end}).
+		OrderBy("head ASC", "author_id ASC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := d.sqlDB.Query(mentionSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer mentions.Close()
+	if err := addChatMentions(chatMessages, mentions); err != nil {
+		return nil, err
+	}
+	return &chatMessages, nil
 }
 
 func (d MysqlDB) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
@@ -773,31 +827,41 @@ func (d MysqlDB) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
 }
 
 func (d MysqlDB) ClearChatAuthorship(authorId string) error {
-	resultedSQL, args, err := mysql.
-		Update("padChat").
-		Set("authorId", nil).
-		Where(sq.Eq{"authorId": authorId}).
-		ToSql()
-
-	if err != nil {
-		return err
+	statements := []sq.Sqlizer{
+		mysql.Update("padChat").Set("authorId", nil).Where(sq.Eq{"authorId": authorId}),
+		mysql.Update("padChat").Set("deleted_by", nil).Where(sq.Eq{"deleted_by": authorId}),
+		mysql.Delete("pad_chat_mention").Where(sq.Eq{"author_id": authorId}),
+		mysql.Delete("pad_chat_read").Where(sq.Eq{"author_id": authorId}),
+	}
+	for _, statement := range statements {
+		resultedSQL, args, err := statement.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := d.sqlDB.Exec(resultedSQL, args...); err != nil {
+			return err
+		}
 	}
-
-	_, err = d.sqlDB.Exec(resultedSQL, args...)
-	return err
+	return nil
 }
 
 func (d MysqlDB) RemoveChat(padId string) error {
-	resultedSQL, args, err := mysql.
-		Delete("padChat").
-		Where(sq.Eq{"padId": padId}).
-		ToSql()
-
-	if err != nil {
-		return err
+	statements := []sq.Sqlizer{
+		mysql.Delete("padChat").Where(sq.Eq{"padId": padId}),
+		mysql.Delete("pad_chat_edit").Where(sq.Eq{"id": padId}),
+		mysql.Delete("pad_chat_mention").Where(sq.Eq{"id": padId}),
+		mysql.Delete("pad_chat_read").Where(sq.Eq{"id": padId}),
+	}
+	for _, statement := range statements {
+		resultedSQL, args, err := statement.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := d.sqlDB.Exec(resultedSQL, args...); err != nil {
+			return err
+		}
 	}
-	_, err = d.sqlDB.Exec(resultedSQL, args...)
-	return err
+	return nil
 }
 
 // ============== GROUP METHODS ==============
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+
+	"github.com/ether/etherpad-go/lib/models/db"
+)
+
+func (d PostgresDB) SetChatReplyTo(padId string, head int, replyTo int) error {
+	_, err := d.pool.Exec(context.Background(),
+		`UPDATE "padchat" SET reply_to = $3 WHERE "padid" = $1 AND "padhead" = $2`,
+		padId, head, replyTo)
+	return err
+}
+
+func (d PostgresDB) EditChatMessage(padId string, head int, text string, timestamp int64) error {
+	ctx := context.Background()
+	// Keep the text being replaced as the next version first.
+	if _, err := d.pool.Exec(ctx,
+		`INSERT INTO pad_chat_edit (id, head, version, chat_text, edited_at)
+         SELECT "padid", "padhead",
+                (SELECT COALESCE(MAX(version), 0) + 1 FROM pad_chat_edit WHERE id = $1 AND head = $2),
+                "chattext", $3
+         FROM "padchat" WHERE "padid" = $1 AND "padhead" = $2 AND deleted_at IS NULL`,
+		padId, head, timestamp); err != nil {
+		return err
+	}
+	_, err := d.pool.Exec(ctx,
+		`UPDATE "padchat" SET "chattext" = $3, edited_at = $4
+         WHERE "padid" = $1 AND "padhead" = $2 AND deleted_at IS NULL`,
+		padId, head, text, timestamp)
+	return err
+}
+
+func (d PostgresDB) GetChatMessageEdits(padId string, head int) (*[]db.ChatMessageEditDB, error) {
+	rows, err := d.pool.Query(context.Background(),
+		`SELECT id, head, version, chat_text, edited_at FROM pad_chat_edit
+         WHERE id = $1 AND head = $2 ORDER BY version ASC`, padId, head)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]db.ChatMessageEditDB, 0)
+	for rows.Next() {
+		var e db.ChatMessageEditDB
+		if err := rows.Scan(&e.PadId, &e.Head, &e.Version, &e.Message, &e.Time); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return &out, rows.Err()
+}
+
+func (d PostgresDB) DeleteChatMessage(padId string, head int, deletedBy *string, timestamp int64) error {
+	ctx := context.Background()
+	if _, err := d.pool.Exec(ctx,
+		`UPDATE "padchat" SET "chattext" = '', deleted_at = $3, deleted_by = $4
+         WHERE "padid" = $1 AND "padhead" = $2 AND deleted_at IS NULL`,
+		padId, head, timestamp, deletedBy); err != nil {
+		return err
+	}
+	for _, query := range []string{
+		`DELETE FROM pad_chat_edit WHERE id = $1 AND head = $2`,
+		`DELETE FROM pad_chat_mention WHERE id = $1 AND head = $2`,
+	} {
+		if _, err := d.pool.Exec(ctx, query, padId, head); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d PostgresDB) SaveChatMentions(padId string, head int, authorIds []string) error {
+	ctx := context.Background()
+	if _, err := d.pool.Exec(ctx, `DELETE FROM pad_chat_mention WHERE id = $1 AND head = $2`, padId, head); err != nil {
+		return err
+	}
+	for _, authorId := range authorIds {
+		if _, err := d.pool.Exec(ctx,
+			`INSERT INTO pad_chat_mention (id, head, author_id) VALUES ($1, $2, $3)
+             ON CONFLICT (id, head, author_id) DO NOTHING`,
+			padId, head, authorId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d PostgresDB) SaveChatReadHead(padId string, authorId string, head int) error {
+	_, err := d.pool.Exec(context.Background(),
+		`INSERT INTO pad_chat_read (id, author_id, read_head) VALUES ($1, $2, $3)
+         ON CONFLICT (id, author_id) DO UPDATE SET read_head = GREATEST(pad_chat_read.read_head, EXCLUDED.read_head)`,
+		padId, authorId, head)
+	return err
+}
+
+func (d PostgresDB) CountUnreadChatMentions(padId string, authorId string) (int, error) {
+	var count int
+	err := d.pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM pad_chat_mention m
+         JOIN "padchat" c ON c."padid" = m.id AND c."padhead" = m.head
+         WHERE m.id = $1 AND m.author_id = $2 AND c.deleted_at IS NULL
+           AND m.head > COALESCE((SELECT read_head FROM pad_chat_read WHERE id = $1 AND author_id = $2), -1)`,
+		padId, authorId).Scan(&count)
+	return count, err
+}
@@ -44,10 +44,15 @@ func (d PostgresDB) CreatePad(padID string, padDB db.PadDB) error {
 		return fmt.Errorf("error marshaling pool: %w", err)
 	}
 
+	fork, err := forkToDB(padDB.Fork)
+	if err != nil {
+		return err
+	}
+
 	_, err = d.pool.Exec(ctx,
 		`INSERT INTO pad (id, head, saved_revisions, readonly_id, pool, chat_head,
-                          public_status, document_type, atext_text, atext_attribs, created_at, updated_at)
-         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+                          public_status, document_type, fork, atext_text, atext_attribs, created_at, updated_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
          ON CONFLICT (id) DO UPDATE SET
              head = EXCLUDED.head,
              saved_revisions = EXCLUDED.saved_revisions,
@@ -56,11 +61,12 @@ func (d PostgresDB) CreatePad(padID string, padDB db.PadDB) error {
              chat_head = EXCLUDED.chat_head,
              public_status = EXCLUDED.public_status,
              document_type = EXCLUDED.document_type,
+             fork = EXCLUDED.fork,
              atext_text = EXCLUDED.atext_text,
              atext_attribs = EXCLUDED.atext_attribs,
              updated_at = NOW()`,
 		padID, padDB.Head, savedRevisions, padDB.ReadOnlyId, pool,
-		padDB.ChatHead, padDB.PublicStatus, padDB.DocumentType, padDB.ATextText, padDB.ATextAttribs)
+		padDB.ChatHead, padDB.PublicStatus, padDB.DocumentType, fork, padDB.ATextText, padDB.ATextAttribs)
 	return err
 }
 
@@ -69,7 +75,7 @@ func (d PostgresDB) GetPad(padID string) (*db.PadDB, error) {
 
 	padDB, err := ReadToPadDB(d.pool.QueryRow(ctx,
 		`SELECT id, head, saved_revisions, readonly_id, pool, chat_head,
-                public_status, document_type, atext_text, atext_attribs, created_at, updated_at
+                public_status, document_type, fork, atext_text, atext_attribs, created_at, updated_at
          FROM pad WHERE id = $1`,
 		padID))
 	if err != nil {
@@ -461,6 +467,24 @@ func (d PostgresDB) GetRevision(padId string, rev int) (*db.PadSingleRevision, e
 	return &revision, nil
 }
 
+func (d PostgresDB) GetRevisionAtTimestamp(padId string, timestamp int64) (*int, error) {
+	ctx := context.Background()
+
+	var rev int
+	err := d.pool.QueryRow(ctx,
+		`SELECT rev FROM "padrev"
+         WHERE id = $1 AND timestamp <= $2
+         ORDER BY timestamp DESC, rev DESC LIMIT 1`,
+		padId, timestamp).Scan(&rev)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning revision: %w", err)
+	}
+	return &rev, nil
+}
+
 func (d PostgresDB) GetRevisions(
 	padId string,
 	startRev int,
@@ -558,7 +582,8 @@ func (d PostgresDB) GetChatsOfPad(
 
 	rows, err := d.pool.Query(ctx,
 		`SELECT pc."padid", pc."padhead", pc."chattext", 
-                pc."authorid", pc.timestamp, ga.name
+                pc."authorid", pc.timestamp, ga.name,
+                pc.reply_to, pc.edited_at, pc.deleted_at, pc.deleted_by
          FROM "padchat" pc
          LEFT JOIN "globalauthor" ga ON ga.id = pc."authorid"
          WHERE pc."padid" = $1 AND pc."padhead" >= $2 AND pc."padhead" <= $3
@@ -575,13 +600,29 @@ func (d PostgresDB) GetChatsOfPad(
 		if err := rows.Scan(
 			&msg.PadId, &msg.Head, &msg.Message,
 			&msg.AuthorId, &msg.Time, &msg.DisplayName,
+			&msg.ReplyTo, &msg.EditedAt, &msg.DeletedAt, &msg.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
 		chatMessages = append(chatMessages, msg)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return &chatMessages, rows.Err()
+	mentions, err := d.pool.Query(ctx,
+		`SELECT head, author_id FROM pad_chat_mention
+         WHERE id = $1 AND head >= $2 AND head <= $3
+         ORDER BY head ASC, author_id ASC`,
+		padId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer mentions.Close()
+	if err := addChatMentions(chatMessages, mentions); err != nil {
+		return nil, err
+	}
+	return &chatMessages, nil
 }
 
 func (d PostgresDB) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
@@ -608,16 +649,32 @@ func (d PostgresDB) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
 
 func (d PostgresDB) ClearChatAuthorship(authorId string) error {
 	ctx := context.Background()
-	_, err := d.pool.Exec(ctx,
+	for _, query := range []string{
 		`UPDATE "padchat" SET "authorid" = NULL WHERE "authorid" = $1`,
-		authorId)
-	return err
+		`UPDATE "padchat" SET deleted_by = NULL WHERE deleted_by = $1`,
+		`DELETE FROM pad_chat_mention WHERE author_id = $1`,
+		`DELETE FROM pad_chat_read WHERE author_id = $1`,
+	} {
+		if _, err := d.pool.Exec(ctx, query, authorId); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (d PostgresDB) RemoveChat(padId string) error {
 	ctx := context.Background()
-	_, err := d.pool.Exec(ctx, `DELETE FROM "padchat" WHERE "padid" = $1`, padId)
-	return err
+	for _, query := range []string{
+		`DELETE FROM "padchat" WHERE "padid" = $1`,
+		`DELETE FROM pad_chat_edit WHERE id = $1`,
+		`DELETE FROM pad_chat_mention WHERE id = $1`,
+		`DELETE FROM pad_chat_read WHERE id = $1`,
+	} {
+		if _, err := d.pool.Exec(ctx, query, padId); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // ============== GROUP METHODS ==============
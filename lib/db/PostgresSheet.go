@@ -78,3 +78,69 @@ func (d PostgresDB) GetSheetOps(padId string, startRev int, endRev int) (*[]db.S
 	}
 	return &out, rows.Err()
 }
+
+func (d PostgresDB) SaveSheetSnapshot(padId string, rev int, snapshot string) error {
+	_, err := d.pool.Exec(context.Background(),
+		`INSERT INTO sheet_snapshot (id, rev, snapshot, created_at)
+         VALUES ($1, $2, $3, NOW())
+         ON CONFLICT (id, rev) DO UPDATE SET snapshot = EXCLUDED.snapshot`,
+		padId, rev, snapshot)
+	return err
+}
+
+func (d PostgresDB) GetSheetSnapshotBefore(padId string, rev int) (*db.SheetSnapshotDB, error) {
+	var s db.SheetSnapshotDB
+	err := d.pool.QueryRow(context.Background(),
+		`SELECT id, rev, snapshot FROM sheet_snapshot
+         WHERE id = $1 AND rev <= $2 ORDER BY rev DESC LIMIT 1`, padId, rev).
+		Scan(&s.PadId, &s.Rev, &s.Snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.New(SheetSnapshotNotFoundError)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d PostgresDB) RemoveSheetSnapshots(padId string) error {
+	_, err := d.pool.Exec(context.Background(), `DELETE FROM sheet_snapshot WHERE id = $1`, padId)
+	return err
+}
+
+func (d PostgresDB) SaveSheetComment(padId string, threadId string, thread string) error {
+	_, err := d.pool.Exec(context.Background(),
+		`INSERT INTO sheet_comment (id, thread_id, thread, created_at)
+         VALUES ($1, $2, $3, NOW())
+         ON CONFLICT (id, thread_id) DO UPDATE SET thread = EXCLUDED.thread`,
+		padId, threadId, thread)
+	return err
+}
+
+func (d PostgresDB) GetSheetComments(padId string) (*[]db.SheetCommentDB, error) {
+	rows, err := d.pool.Query(context.Background(),
+		`SELECT id, thread_id, thread FROM sheet_comment WHERE id = $1 ORDER BY thread_id ASC`, padId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]db.SheetCommentDB, 0)
+	for rows.Next() {
+		var c db.SheetCommentDB
+		if err := rows.Scan(&c.PadId, &c.ThreadId, &c.Thread); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return &out, rows.Err()
+}
+
+func (d PostgresDB) RemoveSheetComment(padId string, threadId string) error {
+	_, err := d.pool.Exec(context.Background(), `DELETE FROM sheet_comment WHERE id = $1 AND thread_id = $2`, padId, threadId)
+	return err
+}
+
+func (d PostgresDB) RemoveSheetComments(padId string) error {
+	_, err := d.pool.Exec(context.Background(), `DELETE FROM sheet_comment WHERE id = $1`, padId)
+	return err
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ether/etherpad-go/lib/models/db"
+)
+
+func (d SQLiteDB) SetChatReplyTo(padId string, head int, replyTo int) error {
+	q, args, err := sq.Update("padChat").
+		Set("reply_to", replyTo).
+		Where(sq.Eq{"padId": padId, "padHead": head}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) EditChatMessage(padId string, head int, text string, timestamp int64) error {
+	// Keep the text being replaced as the next version first.
+	if _, err := d.sqlDB.Exec(`INSERT INTO pad_chat_edit (id, head, version, chat_text, edited_at)
+		SELECT padId, padHead,
+			(SELECT COALESCE(MAX(version), 0) + 1 FROM pad_chat_edit WHERE id = ? AND head = ?),
+			chatText, ?
+		FROM padChat WHERE padId = ? AND padHead = ? AND deleted_at IS NULL`,
+		padId, head, timestamp, padId, head); err != nil {
+		return err
+	}
+	q, args, err := sq.Update("padChat").
+		Set("chatText", text).
+		Set("edited_at", timestamp).
+		Where(sq.Eq{"padId": padId, "padHead": head, "deleted_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) GetChatMessageEdits(padId string, head int) (*[]db.ChatMessageEditDB, error) {
+	q, args, err := sq.Select("id", "head", "version", "chat_text", "edited_at").
+		From("pad_chat_edit").
+		Where(sq.Eq{"id": padId, "head": head}).
+		OrderBy("version ASC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.sqlDB.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]db.ChatMessageEditDB, 0)
+	for rows.Next() {
+		var e db.ChatMessageEditDB
+		if err := rows.Scan(&e.PadId, &e.Head, &e.Version, &e.Message, &e.Time); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return &out, rows.Err()
+}
+
+func (d SQLiteDB) DeleteChatMessage(padId string, head int, deletedBy *string, timestamp int64) error {
+	statements := []sq.Sqlizer{
+		sq.Update("padChat").
+			Set("chatText", "").
+			Set("deleted_at", timestamp).
+			Set("deleted_by", deletedBy).
+			Where(sq.Eq{"padId": padId, "padHead": head, "deleted_at": nil}),
+		sq.Delete("pad_chat_edit").Where(sq.Eq{"id": padId, "head": head}),
+		sq.Delete("pad_chat_mention").Where(sq.Eq{"id": padId, "head": head}),
+	}
+	for _, statement := range statements {
+		q, args, err := statement.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := d.sqlDB.Exec(q, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d SQLiteDB) SaveChatMentions(padId string, head int, authorIds []string) error {
+	q, args, err := sq.Delete("pad_chat_mention").Where(sq.Eq{"id": padId, "head": head}).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := d.sqlDB.Exec(q, args...); err != nil {
+		return err
+	}
+	if len(authorIds) == 0 {
+		return nil
+	}
+	insert := sq.Insert("pad_chat_mention").Columns("id", "head", "author_id")
+	for _, authorId := range authorIds {
+		insert = insert.Values(padId, head, authorId)
+	}
+	q, args, err = insert.Suffix("ON CONFLICT(id, head, author_id) DO NOTHING").ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) SaveChatReadHead(padId string, authorId string, head int) error {
+	q, args, err := sq.Insert("pad_chat_read").
+		Columns("id", "author_id", "read_head").
+		Values(padId, authorId, head).
+		Suffix("ON CONFLICT(id, author_id) DO UPDATE SET read_head = MAX(read_head, excluded.read_head)").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) CountUnreadChatMentions(padId string, authorId string) (int, error) {
+	var count int
+	err := d.sqlDB.QueryRow(`SELECT COUNT(*) FROM pad_chat_mention m
+		JOIN padChat c ON c.padId = m.id AND c.padHead = m.head
+		WHERE m.id = ? AND m.author_id = ? AND c.deleted_at IS NULL
+			AND m.head > COALESCE((SELECT read_head FROM pad_chat_read WHERE id = ? AND author_id = ?), -1)`,
+		padId, authorId, padId, authorId).Scan(&count)
+	return count, err
+}
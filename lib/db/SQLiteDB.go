@@ -37,12 +37,17 @@ func (d SQLiteDB) CreatePad(padID string, padDB db.PadDB) error {
 		return fmt.Errorf("error marshaling pool: %w", err)
 	}
 
+	fork, err := forkToDB(padDB.Fork)
+	if err != nil {
+		return err
+	}
+
 	resultedSQL, args, err := sq.
 		Insert("pad").
 		Columns("id", "head", "saved_revisions", "readonly_id", "pool", "chat_head",
-			"public_status", "document_type", "atext_text", "atext_attribs").
+			"public_status", "document_type", "fork", "atext_text", "atext_attribs").
 		Values(padID, padDB.Head, string(savedRevisions), padDB.ReadOnlyId, string(pool),
-			padDB.ChatHead, padDB.PublicStatus, padDB.DocumentType, padDB.ATextText, padDB.ATextAttribs).
+			padDB.ChatHead, padDB.PublicStatus, padDB.DocumentType, fork, padDB.ATextText, padDB.ATextAttribs).
 		Suffix(`ON CONFLICT(id) DO UPDATE SET
 			head = excluded.head,
 			saved_revisions = excluded.saved_revisions,
@@ -51,6 +56,7 @@ func (d SQLiteDB) CreatePad(padID string, padDB db.PadDB) error {
 			chat_head = excluded.chat_head,
 			public_status = excluded.public_status,
 			document_type = excluded.document_type,
+			fork = excluded.fork,
 			atext_text = excluded.atext_text,
 			atext_attribs = excluded.atext_attribs,
 			updated_at = CURRENT_TIMESTAMP`).
@@ -67,7 +73,7 @@ func (d SQLiteDB) CreatePad(padID string, padDB db.PadDB) error {
 func (d SQLiteDB) GetPad(padID string) (*db.PadDB, error) {
 	resultedSQL, args, err := sq.
 		Select("id", "head", "saved_revisions", "readonly_id", "pool", "chat_head",
-			"public_status", "document_type", "atext_text", "atext_attribs", "created_at", "updated_at").
+			"public_status", "document_type", "fork", "atext_text", "atext_attribs", "created_at", "updated_at").
 		From("pad").
 		Where(sq.Eq{"id": padID}).
 		ToSql()
@@ -614,6 +620,30 @@ func (d SQLiteDB) GetRevision(padId string, rev int) (*db.PadSingleRevision, err
 	return &revisionDB, nil
 }
 
+func (d SQLiteDB) GetRevisionAtTimestamp(padId string, timestamp int64) (*int, error) {
+	resultedSQL, args, err := sq.
+		Select("rev").
+		From("padRev").
+		Where(sq.Eq{"id": padId}).
+		Where(sq.LtOrEq{"timestamp": timestamp}).
+		OrderBy("timestamp DESC", "rev DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rev int
+	err = d.sqlDB.QueryRow(resultedSQL, args...).Scan(&rev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning revision: %w", err)
+	}
+	return &rev, nil
+}
+
 func (d SQLiteDB) GetRevisions(padId string, startRev int, endRev int) (*[]db.PadSingleRevision, error) {
 	padExists, err := d.DoesPadExist(padId)
 	if err != nil {
@@ -724,7 +754,8 @@ func (d SQLiteDB) GetChatsOfPad(
 	end int,
 ) (*[]db.ChatMessageDBWithDisplayName, error) {
 	resultedSQL, args, err := sq.
-		Select("pc.padId", "pc.padHead", "pc.chatText", "pc.authorId", "pc.created_at", "ga.name").
+		Select("pc.padId", "pc.padHead", "pc.chatText", "pc.authorId", "pc.created_at", "ga.name",
+			"pc.reply_to", "pc.edited_at", "pc.deleted_at", "pc.deleted_by").
 		From("padChat pc").
 		LeftJoin("globalAuthor ga ON ga.id = pc.authorId").
 		Where(sq.Eq{"pc.padId": padId}).
@@ -749,13 +780,36 @@ func (d SQLiteDB) GetChatsOfPad(
 		if err := query.Scan(
 			&chatMessage.PadId, &chatMessage.Head, &chatMessage.Message,
 			&chatMessage.AuthorId, &chatMessage.Time, &chatMessage.DisplayName,
+			&chatMessage.ReplyTo, &chatMessage.EditedAt, &chatMessage.DeletedAt, &chatMessage.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
 		chatMessages = append(chatMessages, chatMessage)
 	}
+	if err := query.Err(); err != nil {
+		return nil, err
+	}
 
-	return &chatMessages, query.Err()
+	mentionSQL, args, err := sq.
+		Select("head", "author_id").
+		From("pad_chat_mention").
+		Where(sq.Eq{"id": padId}).
+		Where(sq.GtOrEq{"head": start}).
+		Where(sq.LtOrEq{"head": end}).
+		OrderBy("head ASC", "author_id ASC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := d.sqlDB.Query(mentionSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer mentions.Close()
+	if err := addChatMentions(chatMessages, mentions); err != nil {
+		return nil, err
+	}
+	return &chatMessages, nil
 }
 
 func (d SQLiteDB) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
@@ -789,31 +843,41 @@ func (d SQLiteDB) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
 }
 
 func (d SQLiteDB) ClearChatAuthorship(authorId string) error {
-	resultedSQL, args, err := sq.
-		Update("padChat").
-		Set("authorId", nil).
-		Where(sq.Eq{"authorId": authorId}).
-		ToSql()
-
-	if err != nil {
-		return err
+	statements := []sq.Sqlizer{
+		sq.Update("padChat").Set("authorId", nil).Where(sq.Eq{"authorId": authorId}),
+		sq.Update("padChat").Set("deleted_by", nil).Where(sq.Eq{"deleted_by": authorId}),
+		sq.Delete("pad_chat_mention").Where(sq.Eq{"author_id": authorId}),
+		sq.Delete("pad_chat_read").Where(sq.Eq{"author_id": authorId}),
+	}
+	for _, statement := range statements {
+		resultedSQL, args, err := statement.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := d.sqlDB.Exec(resultedSQL, args...); err != nil {
+			return err
+		}
 	}
-
-	_, err = d.sqlDB.Exec(resultedSQL, args...)
-	return err
+	return nil
 }
 
 func (d SQLiteDB) RemoveChat(padId string) error {
-	resultedSQL, args, err := sq.
-		Delete("padChat").
-		Where(sq.Eq{"padId": padId}).
-		ToSql()
-
-	if err != nil {
-		return err
+	statements := []sq.Sqlizer{
+		sq.Delete("padChat").Where(sq.Eq{"padId": padId}),
+		sq.Delete("pad_chat_edit").Where(sq.Eq{"id": padId}),
+		sq.Delete("pad_chat_mention").Where(sq.Eq{"id": padId}),
+		sq.Delete("pad_chat_read").Where(sq.Eq{"id": padId}),
+	}
+	for _, statement := range statements {
+		resultedSQL, args, err := statement.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := d.sqlDB.Exec(resultedSQL, args...); err != nil {
+			return err
+		}
 	}
-	_, err = d.sqlDB.Exec(resultedSQL, args...)
-	return err
+	return nil
 }
 
 // ============== GROUP METHODS ==============
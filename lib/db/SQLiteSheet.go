@@ -117,3 +117,103 @@ func (d SQLiteDB) GetSheetOps(padId string, startRev int, endRev int) (*[]db.She
 	}
 	return &out, rows.Err()
 }
+
+func (d SQLiteDB) SaveSheetSnapshot(padId string, rev int, snapshot string) error {
+	q, args, err := sq.Insert("sheet_snapshot").
+		Columns("id", "rev", "snapshot").
+		Values(padId, rev, snapshot).
+		Suffix("ON CONFLICT(id, rev) DO UPDATE SET snapshot = excluded.snapshot").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) GetSheetSnapshotBefore(padId string, rev int) (*db.SheetSnapshotDB, error) {
+	q, args, err := sq.Select("id", "rev", "snapshot").
+		From("sheet_snapshot").
+		Where(sq.Eq{"id": padId}).
+		Where(sq.LtOrEq{"rev": rev}).
+		OrderBy("rev DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var s db.SheetSnapshotDB
+	err = d.sqlDB.QueryRow(q, args...).Scan(&s.PadId, &s.Rev, &s.Snapshot)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New(SheetSnapshotNotFoundError)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d SQLiteDB) RemoveSheetSnapshots(padId string) error {
+	q, args, err := sq.Delete("sheet_snapshot").Where(sq.Eq{"id": padId}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) SaveSheetComment(padId string, threadId string, thread string) error {
+	q, args, err := sq.Insert("sheet_comment").
+		Columns("id", "thread_id", "thread").
+		Values(padId, threadId, thread).
+		Suffix("ON CONFLICT(id, thread_id) DO UPDATE SET thread = excluded.thread").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) GetSheetComments(padId string) (*[]db.SheetCommentDB, error) {
+	q, args, err := sq.Select("id", "thread_id", "thread").
+		From("sheet_comment").
+		Where(sq.Eq{"id": padId}).
+		OrderBy("thread_id ASC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.sqlDB.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]db.SheetCommentDB, 0)
+	for rows.Next() {
+		var c db.SheetCommentDB
+		if err := rows.Scan(&c.PadId, &c.ThreadId, &c.Thread); err != nil {
+			return nil, fmt.Errorf("scan sheet_comment: %w", err)
+		}
+		out = append(out, c)
+	}
+	return &out, rows.Err()
+}
+
+func (d SQLiteDB) RemoveSheetComment(padId string, threadId string) error {
+	q, args, err := sq.Delete("sheet_comment").Where(sq.Eq{"id": padId, "thread_id": threadId}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
+
+func (d SQLiteDB) RemoveSheetComments(padId string) error {
+	q, args, err := sq.Delete("sheet_comment").Where(sq.Eq{"id": padId}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = d.sqlDB.Exec(q, args...)
+	return err
+}
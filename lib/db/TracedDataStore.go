@@ -0,0 +1,675 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/metrics"
+	"github.com/ether/etherpad-go/lib/models/db"
+	session2 "github.com/ether/etherpad-go/lib/models/session"
+	"github.com/ether/etherpad-go/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedDataStore wraps a DataStore and records the latency metric for every
+// call. Calls made through a store bound with WithContext to a context holding
+// a span are also traced, as client spans below it.
+type TracedDataStore struct {
+	inner   DataStore
+	backend string
+	ctx     context.Context
+}
+
+// NewTracedDataStore wraps inner; backend is reported as db.system.name
+// (e.g. "postgres").
+func NewTracedDataStore(inner DataStore, backend string) *TracedDataStore {
+	return &TracedDataStore{inner: inner, backend: backend, ctx: context.Background()}
+}
+
+// WithContext returns a view of the store whose spans are children of ctx.
+func (t *TracedDataStore) WithContext(ctx context.Context) DataStore {
+	return &TracedDataStore{inner: t.inner, backend: t.backend, ctx: ctx}
+}
+
+// WithContext binds ctx to store if it supports tracing and returns store
+// unchanged otherwise.
+func WithContext(store DataStore, ctx context.Context) DataStore {
+	if traced, ok := store.(*TracedDataStore); ok && ctx != nil {
+		return traced.WithContext(ctx)
+	}
+	return store
+}
+
+func (t *TracedDataStore) start(operation string, padId string) dbCall {
+	call := dbCall{backend: t.backend, operation: operation, started: time.Now()}
+	// Without a parent span each call would start a trace of its own.
+	if !trace.SpanContextFromContext(t.ctx).IsValid() {
+		return call
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system.name", t.backend),
+		attribute.String("db.operation.name", operation),
+	}
+	if padId != "" {
+		attrs = append(attrs, attribute.String("etherpad.pad_id", padId))
+	}
+	_, call.span = tracing.Tracer().Start(t.ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	return call
+}
+
+// dbCall is one DataStore call in flight. span is nil when the call is not
+// traced.
+type dbCall struct {
+	span      trace.Span
+	backend   string
+	operation string
+	started   time.Time
+}
+
+// end finishes the span and records the call latency.
+func (c dbCall) end(err error) {
+	metrics.Since(metrics.DBCallDuration.WithLabelValues(c.backend, c.operation), c.started)
+	if err != nil {
+		metrics.DBCallErrors.WithLabelValues(c.backend, c.operation).Inc()
+	}
+	if c.span != nil {
+		tracing.End(c.span, err)
+	}
+}
+
+var _ DataStore = (*TracedDataStore)(nil)
+
+func (t *TracedDataStore) Close() error {
+	return t.inner.Close()
+}
+
+func (t *TracedDataStore) DoesPadExist(padID string) (*bool, error) {
+	call := t.start("DoesPadExist", padID)
+	result, err := t.inner.DoesPadExist(padID)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemovePad(padID string) error {
+	call := t.start("RemovePad", padID)
+	err := t.inner.RemovePad(padID)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CreatePad(padID string, padDB db.PadDB) error {
+	call := t.start("CreatePad", padID)
+	err := t.inner.CreatePad(padID, padDB)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetPadIds() (*[]string, error) {
+	call := t.start("GetPadIds", "")
+	result, err := t.inner.GetPadIds()
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SaveRevision(padId string, rev int, changeset string, text db.AText, pool db.RevPool, authorId *string, timestamp int64) error {
+	call := t.start("SaveRevision", padId)
+	err := t.inner.SaveRevision(padId, rev, changeset, text, pool, authorId, timestamp)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetRevision(padId string, rev int) (*db.PadSingleRevision, error) {
+	call := t.start("GetRevision", padId)
+	result, err := t.inner.GetRevision(padId, rev)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemoveRevisionsOfPad(padId string) error {
+	call := t.start("RemoveRevisionsOfPad", padId)
+	err := t.inner.RemoveRevisionsOfPad(padId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetRevisions(padId string, startRev int, endRev int) (*[]db.PadSingleRevision, error) {
+	call := t.start("GetRevisions", padId)
+	result, err := t.inner.GetRevisions(padId, startRev, endRev)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetRevisionAtTimestamp(padId string, timestamp int64) (*int, error) {
+	call := t.start("GetRevisionAtTimestamp", padId)
+	result, err := t.inner.GetRevisionAtTimestamp(padId, timestamp)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetPad(padID string) (*db.PadDB, error) {
+	call := t.start("GetPad", padID)
+	result, err := t.inner.GetPad(padID)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetReadonlyPad(padId string) (*string, error) {
+	call := t.start("GetReadonlyPad", padId)
+	result, err := t.inner.GetReadonlyPad(padId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SetReadOnlyId(padId string, readOnlyId string) error {
+	call := t.start("SetReadOnlyId", padId)
+	err := t.inner.SetReadOnlyId(padId, readOnlyId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetPadByReadOnlyId(id string) (*string, error) {
+	call := t.start("GetPadByReadOnlyId", "")
+	result, err := t.inner.GetPadByReadOnlyId(id)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SaveChatHeadOfPad(padId string, head int) error {
+	call := t.start("SaveChatHeadOfPad", padId)
+	err := t.inner.SaveChatHeadOfPad(padId, head)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) QueryPad(offset int, limit int, sortBy string, ascending bool, pattern string) (*db.PadDBSearchResult, error) {
+	call := t.start("QueryPad", "")
+	result, err := t.inner.QueryPad(offset, limit, sortBy, ascending, pattern)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetAuthor(author string) (*db.AuthorDB, error) {
+	call := t.start("GetAuthor", "")
+	result, err := t.inner.GetAuthor(author)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetPadIdsOfAuthor(authorId string) (*[]string, error) {
+	call := t.start("GetPadIdsOfAuthor", "")
+	result, err := t.inner.GetPadIdsOfAuthor(authorId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetAuthorByToken(token string) (*string, error) {
+	call := t.start("GetAuthorByToken", "")
+	result, err := t.inner.GetAuthorByToken(token)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SetAuthorByToken(token string, author string) error {
+	call := t.start("SetAuthorByToken", "")
+	err := t.inner.SetAuthorByToken(token, author)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveAuthor(author db.AuthorDB) error {
+	call := t.start("SaveAuthor", "")
+	err := t.inner.SaveAuthor(author)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveAuthorName(authorId string, authorName string) error {
+	call := t.start("SaveAuthorName", "")
+	err := t.inner.SaveAuthorName(authorId, authorName)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveAuthorColor(authorId string, authorColor string) error {
+	call := t.start("SaveAuthorColor", "")
+	err := t.inner.SaveAuthorColor(authorId, authorColor)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetAuthors(ids []string) (*[]db.AuthorDB, error) {
+	call := t.start("GetAuthors", "")
+	result, err := t.inner.GetAuthors(ids)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemoveTokenOfAuthor(authorId string) error {
+	call := t.start("RemoveTokenOfAuthor", "")
+	err := t.inner.RemoveTokenOfAuthor(authorId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetSessionById(sessionID string) (*session2.Session, error) {
+	call := t.start("GetSessionById", "")
+	result, err := t.inner.GetSessionById(sessionID)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SetSessionById(sessionID string, session session2.Session) error {
+	call := t.start("SetSessionById", "")
+	err := t.inner.SetSessionById(sessionID, session)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) RemoveSessionById(sessionID string) error {
+	call := t.start("RemoveSessionById", "")
+	err := t.inner.RemoveSessionById(sessionID)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetGroup(groupId string) (*string, error) {
+	call := t.start("GetGroup", "")
+	result, err := t.inner.GetGroup(groupId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetGroups() (*[]string, error) {
+	call := t.start("GetGroups", "")
+	result, err := t.inner.GetGroups()
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SaveGroup(groupId string) error {
+	call := t.start("SaveGroup", "")
+	err := t.inner.SaveGroup(groupId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) RemoveGroup(groupId string) error {
+	call := t.start("RemoveGroup", "")
+	err := t.inner.RemoveGroup(groupId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) RemoveChat(padId string) error {
+	call := t.start("RemoveChat", padId)
+	err := t.inner.RemoveChat(padId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveChatMessage(padId string, head int, authorId *string, timestamp int64, text string) error {
+	call := t.start("SaveChatMessage", padId)
+	err := t.inner.SaveChatMessage(padId, head, authorId, timestamp, text)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetChatsOfPad(padId string, start int, end int) (*[]db.ChatMessageDBWithDisplayName, error) {
+	call := t.start("GetChatsOfPad", padId)
+	result, err := t.inner.GetChatsOfPad(padId, start, end)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetAuthorIdsOfPadChats(id string) (*[]string, error) {
+	call := t.start("GetAuthorIdsOfPadChats", "")
+	result, err := t.inner.GetAuthorIdsOfPadChats(id)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) ClearChatAuthorship(authorId string) error {
+	call := t.start("ClearChatAuthorship", "")
+	err := t.inner.ClearChatAuthorship(authorId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SetChatReplyTo(padId string, head int, replyTo int) error {
+	call := t.start("SetChatReplyTo", padId)
+	err := t.inner.SetChatReplyTo(padId, head, replyTo)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) EditChatMessage(padId string, head int, text string, timestamp int64) error {
+	call := t.start("EditChatMessage", padId)
+	err := t.inner.EditChatMessage(padId, head, text, timestamp)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetChatMessageEdits(padId string, head int) (*[]db.ChatMessageEditDB, error) {
+	call := t.start("GetChatMessageEdits", padId)
+	result, err := t.inner.GetChatMessageEdits(padId, head)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DeleteChatMessage(padId string, head int, deletedBy *string, timestamp int64) error {
+	call := t.start("DeleteChatMessage", padId)
+	err := t.inner.DeleteChatMessage(padId, head, deletedBy, timestamp)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveChatMentions(padId string, head int, authorIds []string) error {
+	call := t.start("SaveChatMentions", padId)
+	err := t.inner.SaveChatMentions(padId, head, authorIds)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveChatReadHead(padId string, authorId string, head int) error {
+	call := t.start("SaveChatReadHead", padId)
+	err := t.inner.SaveChatReadHead(padId, authorId, head)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CountUnreadChatMentions(padId string, authorId string) (int, error) {
+	call := t.start("CountUnreadChatMentions", padId)
+	result, err := t.inner.CountUnreadChatMentions(padId, authorId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) GetServerVersion() (*db.ServerVersion, error) {
+	call := t.start("GetServerVersion", "")
+	result, err := t.inner.GetServerVersion()
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SaveServerVersion(version string) error {
+	call := t.start("SaveServerVersion", "")
+	err := t.inner.SaveServerVersion(version)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetOIDCStorageValue(key string) (*string, error) {
+	call := t.start("GetOIDCStorageValue", "")
+	result, err := t.inner.GetOIDCStorageValue(key)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) SetOIDCStorageValue(key string, payload string) error {
+	call := t.start("SetOIDCStorageValue", "")
+	err := t.inner.SetOIDCStorageValue(key, payload)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) DeleteOIDCStorageValue(key string) error {
+	call := t.start("DeleteOIDCStorageValue", "")
+	err := t.inner.DeleteOIDCStorageValue(key)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CreateAccessToken(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData string, requestedAt, expiresAt time.Time) error {
+	call := t.start("CreateAccessToken", "")
+	err := t.inner.CreateAccessToken(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData, requestedAt, expiresAt)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetAccessToken(signature string) (*OAuthTokenRow, error) {
+	call := t.start("GetAccessToken", "")
+	result, err := t.inner.GetAccessToken(signature)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DeleteAccessToken(signature string) error {
+	call := t.start("DeleteAccessToken", "")
+	err := t.inner.DeleteAccessToken(signature)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) DeleteAccessTokensByRequestID(requestID string) error {
+	call := t.start("DeleteAccessTokensByRequestID", "")
+	err := t.inner.DeleteAccessTokensByRequestID(requestID)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CreateRefreshToken(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData string, active bool, accessTokenSignature string, requestedAt, expiresAt time.Time) error {
+	call := t.start("CreateRefreshToken", "")
+	err := t.inner.CreateRefreshToken(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData, active, accessTokenSignature, requestedAt, expiresAt)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetRefreshToken(signature string) (*OAuthRefreshTokenRow, error) {
+	call := t.start("GetRefreshToken", "")
+	result, err := t.inner.GetRefreshToken(signature)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DeleteRefreshToken(signature string) error {
+	call := t.start("DeleteRefreshToken", "")
+	err := t.inner.DeleteRefreshToken(signature)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) RevokeRefreshToken(signature string) error {
+	call := t.start("RevokeRefreshToken", "")
+	err := t.inner.RevokeRefreshToken(signature)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) RevokeRefreshTokensByRequestID(requestID string) error {
+	call := t.start("RevokeRefreshTokensByRequestID", "")
+	err := t.inner.RevokeRefreshTokensByRequestID(requestID)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CreateAuthCode(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData string, requestedAt, expiresAt time.Time) error {
+	call := t.start("CreateAuthCode", "")
+	err := t.inner.CreateAuthCode(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData, requestedAt, expiresAt)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetAuthCode(signature string) (*OAuthTokenRow, error) {
+	call := t.start("GetAuthCode", "")
+	result, err := t.inner.GetAuthCode(signature)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) InvalidateAuthCode(signature string) error {
+	call := t.start("InvalidateAuthCode", "")
+	err := t.inner.InvalidateAuthCode(signature)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CreatePKCE(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData string, requestedAt, expiresAt time.Time) error {
+	call := t.start("CreatePKCE", "")
+	err := t.inner.CreatePKCE(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData, requestedAt, expiresAt)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetPKCE(signature string) (*OAuthTokenRow, error) {
+	call := t.start("GetPKCE", "")
+	result, err := t.inner.GetPKCE(signature)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DeletePKCE(signature string) error {
+	call := t.start("DeletePKCE", "")
+	err := t.inner.DeletePKCE(signature)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) CreateOIDCSession(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData string, requestedAt, expiresAt time.Time) error {
+	call := t.start("CreateOIDCSession", "")
+	err := t.inner.CreateOIDCSession(signature, clientID, requestID, scopes, grantedScopes, formData, sessionData, requestedAt, expiresAt)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetOIDCSession(signature string) (*OAuthTokenRow, error) {
+	call := t.start("GetOIDCSession", "")
+	result, err := t.inner.GetOIDCSession(signature)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DeleteOIDCSession(signature string) error {
+	call := t.start("DeleteOIDCSession", "")
+	err := t.inner.DeleteOIDCSession(signature)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveSecretParams(id string, prefix string, payload string) error {
+	call := t.start("SaveSecretParams", "")
+	err := t.inner.SaveSecretParams(id, prefix, payload)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) ListSecretParams(prefix string) (map[string]string, error) {
+	call := t.start("ListSecretParams", "")
+	result, err := t.inner.ListSecretParams(prefix)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DeleteSecretParams(id string) error {
+	call := t.start("DeleteSecretParams", "")
+	err := t.inner.DeleteSecretParams(id)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveSheet(padId string, head int, snapshot string) error {
+	call := t.start("SaveSheet", padId)
+	err := t.inner.SaveSheet(padId, head, snapshot)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetSheet(padId string) (*db.SheetDB, error) {
+	call := t.start("GetSheet", padId)
+	result, err := t.inner.GetSheet(padId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) DoesSheetExist(padId string) (*bool, error) {
+	call := t.start("DoesSheetExist", padId)
+	result, err := t.inner.DoesSheetExist(padId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemoveSheet(padId string) error {
+	call := t.start("RemoveSheet", padId)
+	err := t.inner.RemoveSheet(padId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveSheetOp(padId string, rev int, op string, authorId *string, timestamp int64) error {
+	call := t.start("SaveSheetOp", padId)
+	err := t.inner.SaveSheetOp(padId, rev, op, authorId, timestamp)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetSheetOps(padId string, startRev int, endRev int) (*[]db.SheetOpDB, error) {
+	call := t.start("GetSheetOps", padId)
+	result, err := t.inner.GetSheetOps(padId, startRev, endRev)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemoveSheetOps(padId string) error {
+	call := t.start("RemoveSheetOps", padId)
+	err := t.inner.RemoveSheetOps(padId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveSheetSnapshot(padId string, rev int, snapshot string) error {
+	call := t.start("SaveSheetSnapshot", padId)
+	err := t.inner.SaveSheetSnapshot(padId, rev, snapshot)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetSheetSnapshotBefore(padId string, rev int) (*db.SheetSnapshotDB, error) {
+	call := t.start("GetSheetSnapshotBefore", padId)
+	result, err := t.inner.GetSheetSnapshotBefore(padId, rev)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemoveSheetSnapshots(padId string) error {
+	call := t.start("RemoveSheetSnapshots", padId)
+	err := t.inner.RemoveSheetSnapshots(padId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) SaveSheetComment(padId string, threadId string, thread string) error {
+	call := t.start("SaveSheetComment", padId)
+	err := t.inner.SaveSheetComment(padId, threadId, thread)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) GetSheetComments(padId string) (*[]db.SheetCommentDB, error) {
+	call := t.start("GetSheetComments", padId)
+	result, err := t.inner.GetSheetComments(padId)
+	call.end(err)
+	return result, err
+}
+
+func (t *TracedDataStore) RemoveSheetComment(padId string, threadId string) error {
+	call := t.start("RemoveSheetComment", padId)
+	err := t.inner.RemoveSheetComment(padId, threadId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) RemoveSheetComments(padId string) error {
+	call := t.start("RemoveSheetComments", padId)
+	err := t.inner.RemoveSheetComments(padId)
+	call.end(err)
+	return err
+}
+
+func (t *TracedDataStore) Ping() error {
+	call := t.start("Ping", "")
+	err := t.inner.Ping()
+	call.end(err)
+	return err
+}
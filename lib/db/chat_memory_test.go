@@ -0,0 +1,44 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemoryChatEditDeleteAndMentions(t *testing.T) {
+	m := NewMemoryDataStore()
+	for head, text := range []string{"hi @bob", "reply"} {
+		_ = m.SaveChatMessage("p1", head, nil, int64(head), text)
+	}
+	_ = m.SetChatReplyTo("p1", 1, 0)
+	_ = m.SaveChatMentions("p1", 0, []string{"a.carol", "a.bob"})
+	_ = m.EditChatMessage("p1", 0, "hi @bob!", 50)
+
+	msgs, _ := m.GetChatsOfPad("p1", 0, 1)
+	first := (*msgs)[0]
+	if first.Message != "hi @bob!" || *first.EditedAt != 50 || !reflect.DeepEqual(first.Mentions, []string{"a.bob", "a.carol"}) {
+		t.Fatalf("unexpected first message %+v", first)
+	}
+	if r := (*msgs)[1].ReplyTo; r == nil || *r != 0 {
+		t.Fatalf("reply not stored: %v", r)
+	}
+	if edits, _ := m.GetChatMessageEdits("p1", 0); len(*edits) != 1 || (*edits)[0].Message != "hi @bob" {
+		t.Fatalf("edits %+v", edits)
+	}
+	if n, _ := m.CountUnreadChatMentions("p1", "a.bob"); n != 1 {
+		t.Fatalf("unread = %d", n)
+	}
+	_ = m.SaveChatReadHead("p1", "a.bob", 0)
+	if n, _ := m.CountUnreadChatMentions("p1", "a.bob"); n != 0 {
+		t.Fatalf("unread after reading = %d", n)
+	}
+
+	_ = m.DeleteChatMessage("p1", 0, nil, 60)
+	msgs, _ = m.GetChatsOfPad("p1", 0, 0)
+	if d := (*msgs)[0]; d.Message != "" || d.DeletedAt == nil || d.Mentions != nil {
+		t.Fatalf("unexpected deleted message %+v", d)
+	}
+	if edits, _ := m.GetChatMessageEdits("p1", 0); len(*edits) != 0 {
+		t.Fatalf("edits survived deletion: %+v", edits)
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteChatEditDeleteAndMentions(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	if err := store.CreatePad("p1", dbmodelPadDB("p1", "pad")); err != nil {
+		t.Fatalf("CreatePad: %v", err)
+	}
+	for head, text := range []string{"hi @bob", "reply", "other"} {
+		if err := store.SaveChatMessage("p1", head, nil, int64(head), text); err != nil {
+			t.Fatalf("SaveChatMessage: %v", err)
+		}
+	}
+	if err := store.SetChatReplyTo("p1", 1, 0); err != nil {
+		t.Fatalf("SetChatReplyTo: %v", err)
+	}
+	if err := store.SaveChatMentions("p1", 0, []string{"a.bob", "a.carol"}); err != nil {
+		t.Fatalf("SaveChatMentions: %v", err)
+	}
+	if err := store.SaveChatMentions("p1", 2, []string{"a.bob"}); err != nil {
+		t.Fatalf("SaveChatMentions: %v", err)
+	}
+	for _, text := range []string{"hi @bob!", "hi @bob!!"} {
+		if err := store.EditChatMessage("p1", 0, text, 50); err != nil {
+			t.Fatalf("EditChatMessage: %v", err)
+		}
+	}
+
+	msgs, err := store.GetChatsOfPad("p1", 0, 2)
+	if err != nil || len(*msgs) != 3 {
+		t.Fatalf("GetChatsOfPad: %v %v", msgs, err)
+	}
+	first := (*msgs)[0]
+	if first.Message != "hi @bob!!" || first.EditedAt == nil || *first.EditedAt != 50 ||
+		!reflect.DeepEqual(first.Mentions, []string{"a.bob", "a.carol"}) {
+		t.Fatalf("unexpected first message %+v", first)
+	}
+	if r := (*msgs)[1].ReplyTo; r == nil || *r != 0 {
+		t.Fatalf("reply_to not stored: %v", r)
+	}
+	edits, err := store.GetChatMessageEdits("p1", 0)
+	if err != nil || len(*edits) != 2 || (*edits)[0].Message != "hi @bob" || (*edits)[1].Version != 2 {
+		t.Fatalf("GetChatMessageEdits: %+v %v", edits, err)
+	}
+
+	if n, _ := store.CountUnreadChatMentions("p1", "a.bob"); n != 2 {
+		t.Fatalf("unread mentions of bob = %d", n)
+	}
+	_ = store.SaveChatReadHead("p1", "a.bob", 1)
+	_ = store.SaveChatReadHead("p1", "a.bob", 0) // never moves back
+	if n, _ := store.CountUnreadChatMentions("p1", "a.bob"); n != 1 {
+		t.Fatalf("unread mentions of bob after reading = %d", n)
+	}
+
+	deleter := "a.mod"
+	if err := store.DeleteChatMessage("p1", 2, &deleter, 60); err != nil {
+		t.Fatalf("DeleteChatMessage: %v", err)
+	}
+	if n, _ := store.CountUnreadChatMentions("p1", "a.bob"); n != 0 {
+		t.Fatalf("a deleted message still counts as a mention: %d", n)
+	}
+	// Editing a deleted message does nothing.
+	_ = store.EditChatMessage("p1", 2, "back", 70)
+	msgs, _ = store.GetChatsOfPad("p1", 2, 2)
+	deleted := (*msgs)[0]
+	if deleted.Message != "" || deleted.DeletedAt == nil || *deleted.DeletedBy != deleter || len(deleted.Mentions) != 0 {
+		t.Fatalf("unexpected deleted message %+v", deleted)
+	}
+
+	if err := store.RemoveChat("p1"); err != nil {
+		t.Fatalf("RemoveChat: %v", err)
+	}
+	if edits, _ := store.GetChatMessageEdits("p1", 0); len(*edits) != 0 {
+		t.Fatalf("edits survived RemoveChat: %+v", edits)
+	}
+}
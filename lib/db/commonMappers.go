@@ -25,12 +25,18 @@ func ReadToAuthorDB(reader Reader) (*db.AuthorDB, error) {
 func ReadToPadDB(reader Reader) (*db.PadDB, error) {
 	var padDB db.PadDB
 	var savedRevisions, pool []byte
+	var fork *string
 
 	if err := reader.Scan(&padDB.ID, &padDB.Head, &savedRevisions, &padDB.ReadOnlyId, &pool,
-		&padDB.ChatHead, &padDB.PublicStatus, &padDB.DocumentType, &padDB.ATextText, &padDB.ATextAttribs,
+		&padDB.ChatHead, &padDB.PublicStatus, &padDB.DocumentType, &fork, &padDB.ATextText, &padDB.ATextAttribs,
 		&padDB.CreatedAt, &padDB.UpdatedAt); err != nil {
 		return nil, err
 	}
+	if fork != nil {
+		if err := json.Unmarshal([]byte(*fork), &padDB.Fork); err != nil {
+			return nil, fmt.Errorf("error unmarshaling fork: %w", err)
+		}
+	}
 	if err := json.Unmarshal(savedRevisions, &padDB.SavedRevisions); err != nil {
 		return nil, fmt.Errorf("error unmarshaling saved revisions: %w", err)
 	}
@@ -39,3 +45,43 @@ func ReadToPadDB(reader Reader) (*db.PadDB, error) {
 	}
 	return &padDB, nil
 }
+
+// forkToDB encodes the fork column, which is NULL for pads that are no fork.
+func forkToDB(fork *db.PadFork) (*string, error) {
+	if fork == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(fork)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling fork: %w", err)
+	}
+	value := string(encoded)
+	return &value, nil
+}
+
+// mentionRows are the (head, author id) rows of pad_chat_mention, from
+// database/sql or pgx.
+type mentionRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// addChatMentions sets the Mentions of messages, ordered by head, from rows.
+func addChatMentions(messages []db.ChatMessageDBWithDisplayName, rows mentionRows) error {
+	byHead := make(map[int]*db.ChatMessageDBWithDisplayName, len(messages))
+	for i := range messages {
+		byHead[messages[i].Head] = &messages[i]
+	}
+	for rows.Next() {
+		var head int
+		var authorId string
+		if err := rows.Scan(&head, &authorId); err != nil {
+			return err
+		}
+		if msg, ok := byHead[head]; ok {
+			msg.Mentions = append(msg.Mentions, authorId)
+		}
+	}
+	return rows.Err()
+}
@@ -6,3 +6,4 @@ const PadReadOnlyIdNotFoundError = "pad read only id not found"
 const AuthorNotFoundError = "author not found"
 const SessionNotFoundError = "session not found"
 const SheetDoesNotExistError = "sheet does not exist"
+const SheetSnapshotNotFoundError = "sheet snapshot not found"
@@ -16,6 +16,11 @@ func GetMigrations() []Migration {
 		migration007DocumentType(),
 		migration008Sheets(),
 		migration009AuthorTokenBackfill(),
+		migration010PadFork(),
+		migration011RevisionTimestampIndex(),
+		migration012SheetSnapshots(),
+		migration013SheetComments(),
+		migration014ChatThreads(),
 	}
 }
 
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"database/sql"
+)
+
+func migration010PadFork() Migration {
+	return Migration{
+		Version:     10,
+		Description: "Add fork column to pad",
+		Up: func(db *sql.DB, dialect Dialect) error {
+			var query string
+			switch dialect {
+			case DialectMySQL:
+				query = `ALTER TABLE pad ADD COLUMN fork TEXT NULL`
+			case DialectPostgres:
+				query = `ALTER TABLE pad ADD COLUMN IF NOT EXISTS fork TEXT`
+			default:
+				query = `ALTER TABLE pad ADD COLUMN fork TEXT`
+			}
+			if _, err := db.Exec(query); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import "database/sql"
+
+// migration011RevisionTimestampIndex indexes the revisions of a pad by their
+// timestamp, which point-in-time lookups (?at=) search by.
+func migration011RevisionTimestampIndex() Migration {
+	return Migration{
+		Version:     11,
+		Description: "Index pad revisions by timestamp",
+		Up: func(db *sql.DB, dialect Dialect) error {
+			switch dialect {
+			case DialectMySQL:
+				// MySQL/MariaDB does not support CREATE INDEX IF NOT EXISTS.
+				var count int
+				if err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics
+					WHERE table_schema = DATABASE() AND table_name = 'padRev' AND index_name = 'idx_padRev_timestamp'`).Scan(&count); err != nil {
+					return err
+				}
+				if count > 0 {
+					return nil
+				}
+				_, err := db.Exec(`CREATE INDEX idx_padRev_timestamp ON padRev (id, timestamp)`)
+				return err
+			default:
+				_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_padRev_timestamp ON padRev (id, timestamp)`)
+				return err
+			}
+		},
+	}
+}
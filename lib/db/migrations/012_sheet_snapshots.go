@@ -0,0 +1,37 @@
+package migrations
+
+import "database/sql"
+
+// migration012SheetSnapshots stores periodic workbook snapshots of a sheet so
+// any revision can be rebuilt by replaying sheet_op from the nearest one.
+func migration012SheetSnapshots() Migration {
+	return Migration{
+		Version:     12,
+		Description: "Create sheet_snapshot table",
+		Up: func(db *sql.DB, dialect Dialect) error {
+			var query string
+			switch dialect {
+			case DialectMySQL:
+				query = `CREATE TABLE IF NOT EXISTS sheet_snapshot (
+					id VARCHAR(255) NOT NULL,
+					rev INT NOT NULL,
+					snapshot LONGTEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (id, rev),
+					FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+				)`
+			default: // Postgres, SQLite
+				query = `CREATE TABLE IF NOT EXISTS sheet_snapshot (
+					id TEXT NOT NULL,
+					rev INTEGER NOT NULL,
+					snapshot TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (id, rev),
+					FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+				)`
+			}
+			_, err := db.Exec(query)
+			return err
+		},
+	}
+}
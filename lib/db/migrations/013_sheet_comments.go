@@ -0,0 +1,38 @@
+package migrations
+
+import "database/sql"
+
+// migration013SheetComments stores the comment threads of a sheet, one row
+// per thread. They live beside the op-log rather than in it: a comment is not
+// a workbook edit and never takes part in history or restore.
+func migration013SheetComments() Migration {
+	return Migration{
+		Version:     13,
+		Description: "Create sheet_comment table",
+		Up: func(db *sql.DB, dialect Dialect) error {
+			var query string
+			switch dialect {
+			case DialectMySQL:
+				query = `CREATE TABLE IF NOT EXISTS sheet_comment (
+					id VARCHAR(255) NOT NULL,
+					thread_id VARCHAR(64) NOT NULL,
+					thread LONGTEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (id, thread_id),
+					FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+				)`
+			default: // Postgres, SQLite
+				query = `CREATE TABLE IF NOT EXISTS sheet_comment (
+					id TEXT NOT NULL,
+					thread_id TEXT NOT NULL,
+					thread TEXT,
+					created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (id, thread_id),
+					FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+				)`
+			}
+			_, err := db.Exec(query)
+			return err
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package migrations
+
+import "database/sql"
+
+// migration014ChatThreads adds replies, edits, soft deletion and mentions to
+// the pad chat. Earlier texts of edited messages go to pad_chat_edit, the
+// authors a message mentions to pad_chat_mention, and how far each author has
+// read the chat of a pad to pad_chat_read.
+func migration014ChatThreads() Migration {
+	return Migration{
+		Version:     14,
+		Description: "Add chat replies, edits, deletion and mentions",
+		Up: func(db *sql.DB, dialect Dialect) error {
+			var queries []string
+			switch dialect {
+			case DialectMySQL:
+				queries = []string{
+					`ALTER TABLE padChat
+						ADD COLUMN reply_to INT NULL,
+						ADD COLUMN edited_at BIGINT NULL,
+						ADD COLUMN deleted_at BIGINT NULL,
+						ADD COLUMN deleted_by VARCHAR(255) NULL`,
+					`CREATE TABLE IF NOT EXISTS pad_chat_edit (
+						id VARCHAR(255) NOT NULL,
+						head INT NOT NULL,
+						version INT NOT NULL,
+						chat_text TEXT NOT NULL,
+						edited_at BIGINT NOT NULL,
+						PRIMARY KEY (id, head, version),
+						FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+					)`,
+					`CREATE TABLE IF NOT EXISTS pad_chat_mention (
+						id VARCHAR(255) NOT NULL,
+						head INT NOT NULL,
+						author_id VARCHAR(255) NOT NULL,
+						PRIMARY KEY (id, head, author_id),
+						FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+					)`,
+					`CREATE TABLE IF NOT EXISTS pad_chat_read (
+						id VARCHAR(255) NOT NULL,
+						author_id VARCHAR(255) NOT NULL,
+						read_head INT NOT NULL,
+						PRIMARY KEY (id, author_id),
+						FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+					)`,
+				}
+			case DialectPostgres:
+				queries = []string{
+					`ALTER TABLE padChat
+						ADD COLUMN IF NOT EXISTS reply_to INTEGER,
+						ADD COLUMN IF NOT EXISTS edited_at BIGINT,
+						ADD COLUMN IF NOT EXISTS deleted_at BIGINT,
+						ADD COLUMN IF NOT EXISTS deleted_by TEXT`,
+				}
+			default: // SQLite adds one column per statement
+				queries = []string{
+					`ALTER TABLE padChat ADD COLUMN reply_to INTEGER`,
+					`ALTER TABLE padChat ADD COLUMN edited_at BIGINT`,
+					`ALTER TABLE padChat ADD COLUMN deleted_at BIGINT`,
+					`ALTER TABLE padChat ADD COLUMN deleted_by TEXT`,
+				}
+			}
+			if dialect != DialectMySQL {
+				queries = append(queries,
+					`CREATE TABLE IF NOT EXISTS pad_chat_edit (
+						id TEXT NOT NULL,
+						head INTEGER NOT NULL,
+						version INTEGER NOT NULL,
+						chat_text TEXT NOT NULL,
+						edited_at BIGINT NOT NULL,
+						PRIMARY KEY (id, head, version),
+						FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+					)`,
+					`CREATE TABLE IF NOT EXISTS pad_chat_mention (
+						id TEXT NOT NULL,
+						head INTEGER NOT NULL,
+						author_id TEXT NOT NULL,
+						PRIMARY KEY (id, head, author_id),
+						FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+					)`,
+					`CREATE TABLE IF NOT EXISTS pad_chat_read (
+						id TEXT NOT NULL,
+						author_id TEXT NOT NULL,
+						read_head INTEGER NOT NULL,
+						PRIMARY KEY (id, author_id),
+						FOREIGN KEY (id) REFERENCES pad(id) ON DELETE CASCADE
+					)`,
+				)
+			}
+			for _, query := range queries {
+				if _, err := db.Exec(query); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
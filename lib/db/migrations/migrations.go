@@ -3,6 +3,7 @@ package migrations
 import (
 	"database/sql"
 	"fmt"
+	"os"
 	"sort"
 	"time"
 )
@@ -60,7 +61,8 @@ func (m *MigrationManager) Run() error {
 	// Execute pending migrations
 	for _, migration := range m.migrations {
 		if migration.Version > currentVersion {
-			fmt.Printf("Running migration %d: %s\n", migration.Version, migration.Description)
+			// stderr keeps stdout clean for CLI commands that print JSON.
+			fmt.Fprintf(os.Stderr, "Running migration %d: %s\n", migration.Version, migration.Description)
 			if err := migration.Up(m.db, m.dialect); err != nil {
 				return fmt.Errorf("migration %d failed: %w", migration.Version, err)
 			}
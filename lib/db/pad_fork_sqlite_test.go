@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+
+	dbmodel "github.com/ether/etherpad-go/lib/models/db"
+)
+
+func TestSQLitePadForkPersists(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	forked := dbmodelPadDB("draft", "text")
+	forked.Fork = &dbmodel.PadFork{Source: "main", SourceRev: 4, Rev: 4}
+	if err := store.CreatePad("draft", forked); err != nil {
+		t.Fatalf("CreatePad: %v", err)
+	}
+	got, err := store.GetPad("draft")
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	if got.Fork == nil || *got.Fork != *forked.Fork {
+		t.Fatalf("expected fork %+v, got %+v", forked.Fork, got.Fork)
+	}
+
+	if err := store.CreatePad("main", dbmodelPadDB("main", "text")); err != nil {
+		t.Fatalf("CreatePad: %v", err)
+	}
+	got, err = store.GetPad("main")
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	if got.Fork != nil {
+		t.Fatalf("expected no fork, got %+v", got.Fork)
+	}
+}
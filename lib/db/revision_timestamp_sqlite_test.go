@@ -0,0 +1,38 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	dbmodel "github.com/ether/etherpad-go/lib/models/db"
+)
+
+func TestSQLiteGetRevisionAtTimestampUsesIndex(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	if err := store.CreatePad("dated", dbmodelPadDB("dated", "text")); err != nil {
+		t.Fatalf("CreatePad: %v", err)
+	}
+	for rev, timestamp := range []int64{1000, 2000, 3000} {
+		if err := store.SaveRevision("dated", rev, "cs", dbmodel.AText{}, dbmodel.RevPool{NumToAttrib: map[string][]string{}}, nil, timestamp); err != nil {
+			t.Fatalf("SaveRevision: %v", err)
+		}
+	}
+
+	rev, err := store.GetRevisionAtTimestamp("dated", 2500)
+	if err != nil || rev == nil || *rev != 1 {
+		t.Fatalf("GetRevisionAtTimestamp(2500) = %v, %v", rev, err)
+	}
+	if rev, err := store.GetRevisionAtTimestamp("dated", 999); err != nil || rev != nil {
+		t.Fatalf("GetRevisionAtTimestamp(999) = %v, %v", rev, err)
+	}
+
+	var detail string
+	var id, parent, notUsed int
+	row := store.sqlDB.QueryRow(`EXPLAIN QUERY PLAN SELECT rev FROM padRev WHERE id = ? AND timestamp <= ? ORDER BY timestamp DESC, rev DESC LIMIT 1`, "dated", 2500)
+	if err := row.Scan(&id, &parent, &notUsed, &detail); err != nil {
+		t.Fatalf("EXPLAIN: %v", err)
+	}
+	if !strings.Contains(detail, "idx_padRev_timestamp") {
+		t.Fatalf("query plan %q does not use the timestamp index", detail)
+	}
+}
@@ -36,3 +36,43 @@ func TestMemorySheetOps(t *testing.T) {
 		t.Fatalf("expected revs 2,3 got %+v", *ops)
 	}
 }
+
+func TestMemorySheetSnapshots(t *testing.T) {
+	m := NewMemoryDataStore()
+	_ = m.SaveSheetSnapshot("p1", 0, "a")
+	_ = m.SaveSheetSnapshot("p1", 100, "b")
+	got, err := m.GetSheetSnapshotBefore("p1", 99)
+	if err != nil {
+		t.Fatalf("GetSheetSnapshotBefore: %v", err)
+	}
+	if got.Rev != 0 || got.Snapshot != "a" {
+		t.Fatalf("expected rev 0, got %+v", got)
+	}
+	if got, _ := m.GetSheetSnapshotBefore("p1", 150); got == nil || got.Rev != 100 {
+		t.Fatalf("expected rev 100, got %+v", got)
+	}
+	if _, err := m.GetSheetSnapshotBefore("p2", 5); err == nil {
+		t.Fatal("expected an error for a sheet without snapshots")
+	}
+}
+
+func TestMemorySheetComments(t *testing.T) {
+	m := NewMemoryDataStore()
+	_ = m.SaveSheetComment("p1", "t.b", "b")
+	_ = m.SaveSheetComment("p1", "t.a", "a")
+	got, err := m.GetSheetComments("p1")
+	if err != nil {
+		t.Fatalf("GetSheetComments: %v", err)
+	}
+	if len(*got) != 2 || (*got)[0].ThreadId != "t.a" || (*got)[1].Thread != "b" {
+		t.Fatalf("expected threads ordered by id, got %+v", *got)
+	}
+	_ = m.RemoveSheetComment("p1", "t.a")
+	if got, _ := m.GetSheetComments("p1"); len(*got) != 1 {
+		t.Fatalf("expected 1 thread left, got %+v", *got)
+	}
+	_ = m.RemoveSheet("p1")
+	if got, _ := m.GetSheetComments("p1"); len(*got) != 0 {
+		t.Fatalf("RemoveSheet must drop the comments, got %+v", *got)
+	}
+}
@@ -55,3 +55,67 @@ func TestSQLiteSheetCascadeOnPadDelete(t *testing.T) {
 		t.Fatal("sheet should be cascade-deleted with its pad")
 	}
 }
+
+func TestSQLiteSheetSnapshots(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	if err := store.CreatePad("p3", dbmodelPadDB("p3", "sheet")); err != nil {
+		t.Fatalf("CreatePad: %v", err)
+	}
+	for _, rev := range []int{0, 100, 200} {
+		if err := store.SaveSheetSnapshot("p3", rev, "{}"); err != nil {
+			t.Fatalf("SaveSheetSnapshot: %v", err)
+		}
+	}
+	// upsert
+	if err := store.SaveSheetSnapshot("p3", 100, `{"sheets":[]}`); err != nil {
+		t.Fatalf("SaveSheetSnapshot upsert: %v", err)
+	}
+	got, err := store.GetSheetSnapshotBefore("p3", 199)
+	if err != nil {
+		t.Fatalf("GetSheetSnapshotBefore: %v", err)
+	}
+	if got.Rev != 100 || got.Snapshot != `{"sheets":[]}` {
+		t.Fatalf("unexpected: %+v", got)
+	}
+	if err := store.RemoveSheetSnapshots("p3"); err != nil {
+		t.Fatalf("RemoveSheetSnapshots: %v", err)
+	}
+	if _, err := store.GetSheetSnapshotBefore("p3", 200); err == nil || err.Error() != SheetSnapshotNotFoundError {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSQLiteSheetComments(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	if err := store.CreatePad("p4", dbmodelPadDB("p4", "sheet")); err != nil {
+		t.Fatalf("CreatePad: %v", err)
+	}
+	for _, id := range []string{"t.b", "t.a"} {
+		if err := store.SaveSheetComment("p4", id, "{}"); err != nil {
+			t.Fatalf("SaveSheetComment: %v", err)
+		}
+	}
+	// upsert
+	if err := store.SaveSheetComment("p4", "t.b", `{"id":"t.b"}`); err != nil {
+		t.Fatalf("SaveSheetComment upsert: %v", err)
+	}
+	got, err := store.GetSheetComments("p4")
+	if err != nil {
+		t.Fatalf("GetSheetComments: %v", err)
+	}
+	if len(*got) != 2 || (*got)[0].ThreadId != "t.a" || (*got)[1].Thread != `{"id":"t.b"}` {
+		t.Fatalf("unexpected: %+v", *got)
+	}
+	if err := store.RemoveSheetComment("p4", "t.a"); err != nil {
+		t.Fatalf("RemoveSheetComment: %v", err)
+	}
+	if got, _ := store.GetSheetComments("p4"); len(*got) != 1 {
+		t.Fatalf("expected 1 thread left, got %+v", *got)
+	}
+	if err := store.RemoveSheetComments("p4"); err != nil {
+		t.Fatalf("RemoveSheetComments: %v", err)
+	}
+	if got, _ := store.GetSheetComments("p4"); len(*got) != 0 {
+		t.Fatalf("expected no threads, got %+v", *got)
+	}
+}
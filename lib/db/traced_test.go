@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedDataStoreSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	store := NewTracedDataStore(NewMemoryDataStore(), "memory")
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	if _, err := WithContext(store, ctx).DoesPadExist("p1"); err != nil {
+		t.Fatalf("DoesPadExist: %v", err)
+	}
+	parent.End()
+	if _, err := store.GetPadIds(); err != nil {
+		t.Fatalf("GetPadIds: %v", err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	bound, ok := spans["db.DoesPadExist"]
+	if !ok {
+		t.Fatal("missing db.DoesPadExist span")
+	}
+	if bound.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("bound call is not a child of the context span")
+	}
+	if _, ok := spans["db.GetPadIds"]; ok {
+		t.Error("a call without a parent span should not be traced")
+	}
+	if len(spans) != 2 {
+		t.Errorf("expected the parent and one db span, got %d spans", len(spans))
+	}
+}
+
+func TestWithContextLeavesPlainStores(t *testing.T) {
+	store := NewMemoryDataStore()
+	if got := WithContext(store, context.Background()); got != DataStore(store) {
+		t.Error("WithContext should return untraced stores unchanged")
+	}
+}
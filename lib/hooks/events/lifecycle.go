@@ -1,7 +1,8 @@
 package events
 
 // LoadSettingsContext is passed to loadSettings hooks once settings are loaded
-// and plugins have registered (server startup). Settings is exposed as any to
+// and plugins have registered (server startup), and again whenever changed
+// settings were applied at runtime. Settings is exposed as any to
 // avoid an events->settings import cycle; plugins type-assert to *settings.Settings.
 type LoadSettingsContext struct {
 	Settings any
@@ -1,10 +1,15 @@
 package hooks
 
 import (
+	"context"
 	"slices"
+	"sync"
 
 	"github.com/ether/etherpad-go/lib/hooks/events"
+	"github.com/ether/etherpad-go/lib/tracing"
 	"github.com/gofiber/utils/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type hookEntry struct {
@@ -12,12 +17,17 @@ type hookEntry struct {
 	fn func(ctx any)
 }
 
+// Hook is the server-side hook registry. Callbacks may be added and removed
+// while hooks execute (runtime-loaded plugins do so), so the entry slices are
+// replaced rather than modified in place.
 type Hook struct {
+	mu    *sync.RWMutex
 	hooks map[string][]hookEntry
 }
 
 func NewHook() Hook {
 	return Hook{
+		mu:    &sync.RWMutex{},
 		hooks: make(map[string][]hookEntry),
 	}
 }
@@ -464,22 +474,51 @@ func (h *Hook) ExecuteShutdownHooks(ctx *events.ShutdownContext) {
 
 func (h *Hook) EnqueueHook(key string, ctx func(ctx any)) string {
 	var uuid = utils.UUID()
-	h.hooks[key] = append(h.hooks[key], hookEntry{id: uuid, fn: ctx})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks[key] = append(slices.Clip(h.hooks[key]), hookEntry{id: uuid, fn: ctx})
 	return uuid
 }
 
 func (h *Hook) DequeueHook(key, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	entries := h.hooks[key]
 	for i, e := range entries {
 		if e.id == id {
-			h.hooks[key] = slices.Delete(entries, i, i+1)
+			h.hooks[key] = slices.Delete(slices.Clone(entries), i, i+1)
 			return
 		}
 	}
 }
 
+// ExecuteHooks runs the callbacks of key in the order they were added. They
+// are not traced, see ExecuteHooksContext.
 func (h *Hook) ExecuteHooks(key string, ctx any) {
-	for _, e := range h.hooks[key] {
+	h.ExecuteHooksContext(context.Background(), key, ctx)
+}
+
+// ExecuteHooksContext runs the callbacks of key like ExecuteHooks and traces
+// them as one span, a child of the span in traceCtx. Without a span in
+// traceCtx they are not traced, as each run would start a trace of its own.
+func (h *Hook) ExecuteHooksContext(traceCtx context.Context, key string, ctx any) {
+	h.mu.RLock()
+	entries := h.hooks[key]
+	h.mu.RUnlock()
+	if len(entries) == 0 {
+		return
+	}
+	if !trace.SpanContextFromContext(traceCtx).IsValid() {
+		for _, e := range entries {
+			e.fn(ctx)
+		}
+		return
+	}
+	_, span := tracing.Start(traceCtx, "hook "+key,
+		attribute.String("etherpad.hook.name", key),
+		attribute.Int("etherpad.hook.callbacks", len(entries)))
+	defer span.End()
+	for _, e := range entries {
 		e.fn(ctx)
 	}
 }
@@ -1,9 +1,14 @@
 package hooks
 
 import (
+	"context"
 	"testing"
 
 	"github.com/ether/etherpad-go/lib/hooks/events"
+	"github.com/ether/etherpad-go/lib/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestExecuteHooksRunsInRegistrationOrder(t *testing.T) {
@@ -398,3 +403,55 @@ func TestImportBareHandle(t *testing.T) {
 		t.Fatal("did not expect text to be set")
 	}
 }
+
+func TestEnqueueAndDequeueWhileExecuting(t *testing.T) {
+	h := NewHook()
+	h.EnqueueHook("k", func(ctx any) {})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			id := h.EnqueueHook("k", func(ctx any) {})
+			h.DequeueHook("k", id)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		h.ExecuteHooks("k", nil)
+	}
+	<-done
+
+	calls := 0
+	h.EnqueueHook("k", func(ctx any) { calls++ })
+	h.ExecuteHooks("k", nil)
+	if calls != 1 {
+		t.Fatalf("expected one call, got %d", calls)
+	}
+}
+
+func TestExecuteHooksTracedOnlyBelowASpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	h := NewHook()
+	calls := 0
+	h.EnqueueHook("k", func(ctx any) { calls++ })
+
+	h.ExecuteHooks("k", nil)
+	h.ExecuteHooksContext(context.Background(), "k", nil)
+	if n := len(recorder.Ended()); n != 0 {
+		t.Fatalf("hooks without a parent span started %d root spans", n)
+	}
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	h.ExecuteHooksContext(ctx, "k", nil)
+	parent.End()
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "hook k" || spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected one hook span below the parent, got %v", spans)
+	}
+}
@@ -32,4 +32,7 @@ type InitStore struct {
 	SecurityManager   *pad2.SecurityManager
 	AuthorManager     *author.Manager
 	Importer          *io.Importer
+	// Formats holds the export and import formats. Init of the io API
+	// registers the built-in ones in a new registry when it is nil.
+	Formats *io.FormatRegistry
 }
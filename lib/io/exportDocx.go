@@ -312,6 +312,16 @@ func (e *ExportDocx) parseLineSegments(text string, aline string, padPool *apool
 					aline = *newAline
 				}
 			}
+
+			// Other line attributes such as headings also sit on a marker
+			if attribs.Get("lmkr") != nil && len(text) > 0 && text[0] == '*' {
+				text = text[1:]
+				newAline, err := changeset.Subattribution(aline, 1, nil)
+				if err != nil {
+					return para, err
+				}
+				aline = *newAline
+			}
 		}
 	}
 
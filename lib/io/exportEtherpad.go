@@ -2,13 +2,17 @@ package io
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/ether/etherpad-go/lib/author"
 	"github.com/ether/etherpad-go/lib/db"
 	"github.com/ether/etherpad-go/lib/hooks"
 	"github.com/ether/etherpad-go/lib/hooks/events"
+	"github.com/ether/etherpad-go/lib/metrics"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
 	"github.com/ether/etherpad-go/lib/pad"
 	"github.com/ether/etherpad-go/lib/utils"
 	"github.com/gofiber/fiber/v3"
@@ -170,7 +174,35 @@ func (e *ExportEtherpad) GetPadRaw(padId string, readOnlyId *string) (*EtherpadE
 	return export, nil
 }
 
-func (e *ExportEtherpad) DoExport(ctx fiber.Ctx, id string, readOnlyId *string, fileExportType string) error {
+// RegisterFormats registers the built-in export formats in r.
+func (e *ExportEtherpad) RegisterFormats(r *FormatRegistry) {
+	builtins := []struct{ name, extension, contentType string }{
+		{"etherpad", "etherpad", ""},
+		{"html", "html", "text/html; charset=utf-8"},
+		{"txt", "txt", "text/plain; charset=utf-8"},
+		{"word", "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"pdf", "pdf", "application/pdf"},
+		{"open", "odt", "application/vnd.oasis.opendocument.text"},
+		{"markdown", "md", "text/markdown; charset=utf-8"},
+	}
+	for _, builtin := range builtins {
+		extension := builtin.extension
+		_ = r.RegisterExport(ExportFormat{
+			Name:        builtin.name,
+			Extension:   extension,
+			ContentType: builtin.contentType,
+			Export: func(padId string, readOnlyId *string, rev *int) ([]byte, error) {
+				content, _, err := e.render(padId, readOnlyId, extension, rev)
+				return content, err
+			},
+			builtin: true,
+		})
+	}
+}
+
+// DoExport sends pad id, or the revision in the rev route parameter, as a
+// download in format.
+func (e *ExportEtherpad) DoExport(ctx fiber.Ctx, id string, readOnlyId *string, format ExportFormat) error {
 	fileName := id
 	if readOnlyId != nil {
 		fileName = *readOnlyId
@@ -184,14 +216,14 @@ func (e *ExportEtherpad) DoExport(ctx fiber.Ctx, id string, readOnlyId *string,
 	fileNameCtx := &events.ExportFileNameContext{
 		PadId:      id,
 		ReadOnlyId: readOnlyIdStr,
-		ExportType: fileExportType,
+		ExportType: format.Extension,
 	}
-	e.hooks.ExecuteExportFileNameHooks(fileNameCtx)
+	e.hooks.ExecuteHooksContext(ctx.Context(), hooks.ExportFileNameString, fileNameCtx)
 	if fileNameCtx.FileName() != "" {
 		fileName = fileNameCtx.FileName()
 	}
 
-	ctx.Attachment(fileName + "." + fileExportType)
+	ctx.Attachment(fileName + "." + format.Extension)
 	optRev := ctx.Params("rev")
 	var optRevNum *int = nil
 	if optRev != "" {
@@ -202,69 +234,111 @@ func (e *ExportEtherpad) DoExport(ctx fiber.Ctx, id string, readOnlyId *string,
 		optRevNum = actualRev
 
 	}
+	if at := ctx.Query("at"); at != "" {
+		if optRevNum != nil {
+			return ctx.Status(400).SendString("Only one of rev and at can be given")
+		}
+		atTime, err := utils.ParseRevisionTime(at)
+		if err != nil {
+			return ctx.Status(400).SendString(err.Error())
+		}
+		retrievedPad, err := e.PadManager.GetPad(id, nil, nil)
+		if err != nil {
+			return ctx.Status(500).SendString(err.Error())
+		}
+		rev, err := retrievedPad.GetRevisionAt(*atTime)
+		if errors.Is(err, padModel.ErrNoRevisionAt) {
+			return ctx.Status(404).SendString(err.Error())
+		}
+		if err != nil {
+			return ctx.Status(500).SendString(err.Error())
+		}
+		optRevNum = &rev
+	}
 
+	started := time.Now()
+	content, err := format.Export(id, readOnlyId, optRevNum)
+	metrics.Since(metrics.ExportDuration.WithLabelValues(format.Extension, metrics.Result(err)), started)
+	if errors.Is(err, ErrUnsupportedExportType) {
+		return ctx.Status(400).SendString("Not Implemented")
+	}
+	if err != nil {
+		e.logger.Warnf("Failed to get %s document for id: %s with cause %s", format.Extension, id, err.Error())
+		return ctx.Status(500).SendString(err.Error())
+	}
+	if format.ContentType != "" {
+		ctx.Set("Content-Type", format.ContentType)
+	}
+	return ctx.Send(content)
+}
+
+// ErrUnsupportedExportType is returned by Render for unknown export types.
+var ErrUnsupportedExportType = errors.New("unsupported export type")
+
+// Render produces the export of a pad in the given format together with its
+// content type. It is used by the HTTP export route and by the CLI, which
+// exports straight from the database.
+func (e *ExportEtherpad) Render(id string, readOnlyId *string, fileExportType string, optRevNum *int) ([]byte, string, error) {
+	started := time.Now()
+	content, contentType, err := e.render(id, readOnlyId, fileExportType, optRevNum)
+	if !errors.Is(err, ErrUnsupportedExportType) {
+		metrics.Since(metrics.ExportDuration.WithLabelValues(fileExportType, metrics.Result(err)), started)
+	}
+	return content, contentType, err
+}
+
+func (e *ExportEtherpad) render(id string, readOnlyId *string, fileExportType string, optRevNum *int) ([]byte, string, error) {
 	switch fileExportType {
 	case "etherpad":
 		exportedPad, err := e.GetPadRaw(id, readOnlyId)
 		if err != nil {
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
 		marshalledPad, err := exportedPad.MarshalJSON()
 		if err != nil {
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
-		return ctx.Send(marshalledPad)
+		return marshalledPad, "", nil
 	case "txt":
 		textString, err := e.exportTxt.GetPadTxtDocument(id, optRevNum)
 		if err != nil {
-			e.logger.Warnf("Failed to get txt document for id: %s with cause %s", id, err.Error())
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
-		return ctx.SendString(*textString)
+		return []byte(*textString), "text/plain; charset=utf-8", nil
 	case "pdf":
-		ctx.Set("Content-Type", "application/pdf")
 		pdfBytes, err := e.exportPDF.GetPadPdfDocument(id, optRevNum)
 		if err != nil {
-			e.logger.Warnf("Failed to get pdf document for id: %s with cause %s", id, err.Error())
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
-		return ctx.Send(pdfBytes)
+		return pdfBytes, "application/pdf", nil
 	case "doc", "docx", "word":
-		ctx.Set("Content-Type", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
 		docxBytes, err := e.exportDocx.GetPadDocxDocument(id, optRevNum)
 		if err != nil {
-			e.logger.Warnf("Failed to get docx document for id: %s with cause %s", id, err.Error())
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
-		return ctx.Send(docxBytes)
+		return docxBytes, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", nil
 	case "odt", "open":
-		ctx.Set("Content-Type", "application/vnd.oasis.opendocument.text")
 		odtBytes, err := e.exportOdt.GetPadOdtDocument(id, optRevNum)
 		if err != nil {
-			e.logger.Warnf("Failed to get odt document for id: %s with cause %s", id, err.Error())
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
-		return ctx.Send(odtBytes)
+		return odtBytes, "application/vnd.oasis.opendocument.text", nil
 	case "html":
-		ctx.Set("Content-Type", "text/html; charset=utf-8")
 		htmlContent, err := e.exportHtml.GetPadHTMLDocument(id, optRevNum, readOnlyId)
 		if err != nil {
-			e.logger.Warnf("Failed to get html document for id: %s with cause %s", id, err.Error())
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
 		// exportHTMLSend hook: plugins may replace the full HTML document.
 		sendCtx := &events.ExportHTMLSendContext{PadId: id, HTML: &htmlContent}
 		e.hooks.ExecuteExportHTMLSendHooks(sendCtx)
-		return ctx.SendString(htmlContent)
+		return []byte(htmlContent), "text/html; charset=utf-8", nil
 	case "markdown", "md":
-		ctx.Set("Content-Type", "text/markdown; charset=utf-8")
 		markdownContent, err := e.exportMarkdown.GetPadMarkdownDocument(id, optRevNum)
 		if err != nil {
-			e.logger.Warnf("Failed to get markdown document for id: %s with cause %s", id, err.Error())
-			return ctx.Status(500).SendString(err.Error())
+			return nil, "", err
 		}
-		return ctx.SendString(*markdownContent)
+		return []byte(*markdownContent), "text/markdown; charset=utf-8", nil
 	default:
-		return ctx.Status(400).SendString("Not Implemented")
+		return nil, "", ErrUnsupportedExportType
 	}
 }
@@ -0,0 +1,215 @@
+package io
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ether/etherpad-go/lib/apool"
+)
+
+// ExportFunc renders padId at revision rev, or at the head revision when rev
+// is nil. readOnlyId is set when the pad was requested by its read-only id.
+type ExportFunc func(padId string, readOnlyId *string, rev *int) ([]byte, error)
+
+// ExportFormat is a format pads can be exported to through
+// /p/:pad/export/:name.
+type ExportFormat struct {
+	// Name is the export type in the URL and in availableExports.
+	Name string
+	// Extension is the file extension of the download, without the dot. It
+	// defaults to Name.
+	Extension string
+	// ContentType of the download, derived from Extension when empty.
+	ContentType string
+	// Label and Icon describe the link in the export menu. Label defaults to
+	// Name; the built-in formats leave both empty as the pad template has
+	// translated links for them. Icon is a buttonicon class such as
+	// "buttonicon-file-code".
+	Label  string
+	Icon   string
+	Export ExportFunc
+
+	builtin bool
+}
+
+// ImportedDocument is the content an import format produced from a file. The
+// first field set in the order Etherpad, AText, Changeset, Paragraphs, HTML,
+// Text is imported.
+type ImportedDocument struct {
+	// Etherpad is an .etherpad export written to the database as is. It is
+	// only accepted for pads with fewer than 10 revisions; Text is imported
+	// instead for other pads when set.
+	Etherpad []byte
+	// AText replaces the content of the pad. Its attribute numbers refer to
+	// Pool.
+	AText *apool.AText
+	// Changeset is applied to the current content of the pad. Its attribute
+	// numbers refer to Pool.
+	Changeset string
+	Pool      *apool.APool
+	// Paragraphs replace the content of the pad with formatted text.
+	Paragraphs []RichParagraph
+	HTML       *string
+	Text       *string
+}
+
+// ImportFunc converts an uploaded file into a document.
+type ImportFunc func(content []byte) (*ImportedDocument, error)
+
+// ImportFormat is a file format that can be imported into a pad.
+type ImportFormat struct {
+	Name string
+	// Extensions are the file endings handled, lower case with the dot.
+	Extensions []string
+	Import     ImportFunc
+}
+
+// FormatRegistry holds the export and import formats. The built-in formats
+// are registered by ExportEtherpad.RegisterFormats and
+// Importer.RegisterFormats, plugins add theirs through the EpPluginStore.
+// Registering a name or file extension that is already taken replaces the
+// previous format, so a plugin can override a built-in one.
+type FormatRegistry struct {
+	mu      sync.RWMutex
+	exports []*ExportFormat
+	imports []*ImportFormat
+}
+
+func NewFormatRegistry() *FormatRegistry {
+	return &FormatRegistry{}
+}
+
+// RegisterExport adds or replaces an export format.
+func (r *FormatRegistry) RegisterExport(format ExportFormat) error {
+	if format.Name == "" || format.Export == nil {
+		return errors.New("export format needs a name and an export function")
+	}
+	if format.Extension == "" {
+		format.Extension = format.Name
+	}
+	format.Extension = strings.TrimPrefix(format.Extension, ".")
+	if format.Label == "" && !format.builtin {
+		format.Label = format.Name
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i := slices.IndexFunc(r.exports, func(f *ExportFormat) bool { return f.Name == format.Name }); i >= 0 {
+		// An override of a built-in format stays subject to availableExports.
+		format.builtin = format.builtin || r.exports[i].builtin
+		r.exports[i] = &format
+	} else {
+		r.exports = append(r.exports, &format)
+	}
+	return nil
+}
+
+// RegisterImport adds an import format. Extensions it lists are removed from
+// formats registered before.
+func (r *FormatRegistry) RegisterImport(format ImportFormat) error {
+	if format.Name == "" || format.Import == nil || len(format.Extensions) == 0 {
+		return errors.New("import format needs a name, file extensions and an import function")
+	}
+	extensions := make([]string, len(format.Extensions))
+	for i, extension := range format.Extensions {
+		extension = strings.ToLower(extension)
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		extensions[i] = extension
+	}
+	format.Extensions = extensions
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	imports := r.imports[:0:0]
+	for _, existing := range r.imports {
+		if existing.Name == format.Name {
+			continue
+		}
+		remaining := slices.DeleteFunc(slices.Clone(existing.Extensions), func(e string) bool {
+			return slices.Contains(extensions, e)
+		})
+		if len(remaining) == 0 {
+			continue
+		}
+		if len(remaining) != len(existing.Extensions) {
+			trimmed := *existing
+			trimmed.Extensions = remaining
+			existing = &trimmed
+		}
+		imports = append(imports, existing)
+	}
+	r.imports = append(imports, &format)
+	return nil
+}
+
+// Export returns the export format called name.
+func (r *FormatRegistry) Export(name string) (ExportFormat, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, format := range r.exports {
+		if format.Name == name {
+			return *format, true
+		}
+	}
+	return ExportFormat{}, false
+}
+
+// Import returns the import format handling the file ending extension, given
+// with the dot.
+func (r *FormatRegistry) Import(extension string) (ImportFormat, bool) {
+	extension = strings.ToLower(extension)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, format := range r.imports {
+		if slices.Contains(format.Extensions, extension) {
+			return *format, true
+		}
+	}
+	return ImportFormat{}, false
+}
+
+// Exports lists the export formats in registration order.
+func (r *FormatRegistry) Exports() []ExportFormat {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	formats := make([]ExportFormat, len(r.exports))
+	for i, format := range r.exports {
+		formats[i] = *format
+	}
+	return formats
+}
+
+// Imports lists the import formats in registration order.
+func (r *FormatRegistry) Imports() []ImportFormat {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	formats := make([]ImportFormat, len(r.imports))
+	for i, format := range r.imports {
+		formats[i] = *format
+	}
+	return formats
+}
+
+// AvailableExports returns the export formats offered to clients: the
+// built-in formats listed in the availableExports setting, in that order,
+// followed by the formats registered by plugins.
+func (r *FormatRegistry) AvailableExports(configured []string) []ExportFormat {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	available := make([]ExportFormat, 0, len(r.exports))
+	for _, name := range configured {
+		for _, format := range r.exports {
+			if format.Name == name && format.builtin {
+				available = append(available, *format)
+			}
+		}
+	}
+	for _, format := range r.exports {
+		if !format.builtin {
+			available = append(available, *format)
+		}
+	}
+	return available
+}
@@ -0,0 +1,127 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportOf(content string) ExportFunc {
+	return func(string, *string, *int) ([]byte, error) { return []byte(content), nil }
+}
+
+func importOf(text string) ImportFunc {
+	return func([]byte) (*ImportedDocument, error) { return &ImportedDocument{Text: &text}, nil }
+}
+
+func names(formats []ExportFormat) []string {
+	result := make([]string, len(formats))
+	for i, format := range formats {
+		result[i] = format.Name
+	}
+	return result
+}
+
+func TestFormatRegistry_Exports(t *testing.T) {
+	r := NewFormatRegistry()
+	(&ExportEtherpad{}).RegisterFormats(r)
+	require.Error(t, r.RegisterExport(ExportFormat{Name: "nothing"}))
+	require.NoError(t, r.RegisterExport(ExportFormat{Name: "csv", Extension: ".csv", Export: exportOf("a,b")}))
+
+	word, ok := r.Export("word")
+	require.True(t, ok)
+	assert.Equal(t, "docx", word.Extension)
+	assert.Empty(t, word.Label)
+
+	csv, ok := r.Export("csv")
+	require.True(t, ok)
+	assert.Equal(t, "csv", csv.Extension)
+	assert.Equal(t, "csv", csv.Label)
+
+	_, ok = r.Export("unknown")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"txt", "pdf", "csv"}, names(r.AvailableExports([]string{"txt", "pdf", "csv"})))
+
+	// A plugin replacing a built-in format keeps its place and its setting.
+	require.NoError(t, r.RegisterExport(ExportFormat{Name: "pdf", Extension: "pdf", Export: exportOf("%PDF")}))
+	assert.Equal(t, []string{"etherpad", "html", "txt", "word", "pdf", "open", "markdown", "csv"}, names(r.Exports()))
+	assert.Equal(t, []string{"txt", "csv"}, names(r.AvailableExports([]string{"txt"})))
+	pdf, _ := r.Export("pdf")
+	content, err := pdf.Export("pad", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF", string(content))
+}
+
+func TestFormatRegistry_Imports(t *testing.T) {
+	r := NewFormatRegistry()
+	(&Importer{}).RegisterFormats(r)
+	require.Error(t, r.RegisterImport(ImportFormat{Name: "none", Import: importOf("")}))
+
+	format, ok := r.Import(".MD")
+	require.True(t, ok)
+	assert.Equal(t, "markdown", format.Name)
+	_, ok = r.Import(".csv")
+	assert.False(t, ok)
+
+	// Taking over one extension leaves the others with the built-in format.
+	require.NoError(t, r.RegisterImport(ImportFormat{Name: "commonmark", Extensions: []string{"md", ".csv"}, Import: importOf("x")}))
+	format, _ = r.Import(".md")
+	assert.Equal(t, "commonmark", format.Name)
+	assert.Equal(t, []string{".md", ".csv"}, format.Extensions)
+	format, _ = r.Import(".markdown")
+	assert.Equal(t, "markdown", format.Name)
+	assert.Equal(t, []string{".markdown"}, format.Extensions)
+
+	doc, err := format.Import([]byte("# Title\n"))
+	require.NoError(t, err)
+	require.Len(t, doc.Paragraphs, 1)
+	assert.Equal(t, "h1", doc.Paragraphs[0].Heading)
+}
+
+func newFormatsTestPad(t *testing.T, text string) *padModel.Pad {
+	t.Helper()
+	hook := hooks.NewHook()
+	pad := padModel.NewPad("formats", db.NewMemoryDataStore(), &hook)
+	require.NoError(t, pad.Init(&text, nil, nil))
+	return &pad
+}
+
+func TestSetPadAText(t *testing.T) {
+	pad := newFormatsTestPad(t, "old content\n")
+
+	pool := apool.NewAPool()
+	bold := pool.PutAttrib(apool.Attribute{Key: "bold", Value: "true"}, nil)
+	atext := apool.AText{Text: "hi there\n", Attribs: "*" + utils.NumToString(bold) + "+2|1+7"}
+
+	require.NoError(t, (&Importer{}).SetPadAText(pad, atext, &pool, "a.import"))
+	assert.Equal(t, "hi there\n", pad.Text())
+	ops, err := changeset.DeserializeOps(pad.AText.Attribs)
+	require.NoError(t, err)
+	first := changeset.FromString((*ops)[0].Attribs, &pad.Pool)
+	assert.Equal(t, "true", *first.Get("bold"))
+	assert.Equal(t, 2, (*ops)[0].Chars)
+
+	err = (&Importer{}).SetPadAText(pad, apool.AText{Text: "no newline", Attribs: "+a"}, &pool, "a.import")
+	assert.Error(t, err)
+	err = (&Importer{}).SetPadAText(pad, apool.AText{Text: "short\n", Attribs: "+2"}, &pool, "a.import")
+	assert.Error(t, err)
+}
+
+func TestApplyPadChangeset(t *testing.T) {
+	pad := newFormatsTestPad(t, "hello")
+
+	cs, err := changeset.MakeSplice(pad.Text(), 5, 0, " world", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, (&Importer{}).ApplyPadChangeset(pad, cs, nil, ""))
+	assert.Equal(t, "hello world\n", pad.Text())
+
+	assert.Error(t, (&Importer{}).ApplyPadChangeset(pad, cs, nil, ""), "changeset against an outdated text")
+}
@@ -0,0 +1,527 @@
+package io
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// docxParaProps are the paragraph properties used by the import.
+type docxParaProps struct {
+	style      string
+	align      string
+	numId      string
+	ilvl       int
+	hasNumPr   bool
+	indentLeft int
+}
+
+type docxStyle struct {
+	name    string
+	basedOn string
+	run     runProps
+	para    docxParaProps
+}
+
+type docxLevel struct {
+	format string
+	start  int
+}
+
+// docxNumbering resolves w:numId/w:ilvl pairs from numbering.xml.
+type docxNumbering struct {
+	abstract  map[string]map[int]docxLevel
+	instances map[string]string
+	overrides map[string]map[int]int
+}
+
+func (n docxNumbering) level(numId string, ilvl int) docxLevel {
+	lvl, ok := n.abstract[n.instances[numId]][ilvl]
+	if !ok {
+		lvl = docxLevel{format: "decimal", start: 1}
+	}
+	if start, ok := n.overrides[numId][ilvl]; ok {
+		lvl.start = start
+	}
+	return lvl
+}
+
+// docxImport holds the parts of a DOCX package needed to map
+// word/document.xml onto pad paragraphs.
+type docxImport struct {
+	styles    map[string]docxStyle
+	numbering docxNumbering
+	links     map[string]string
+}
+
+// ParseDocx converts a DOCX document into pad paragraphs, keeping character
+// formatting (bold, italic, underline, strikethrough, color, size, font),
+// headings, lists with their numbering, alignment and hyperlinks. Paragraph
+// styles contribute headings, lists and alignment but not the look of their
+// text, which the pad's own heading and default styles provide. Hyperlinks
+// become their text followed by the target in parentheses.
+func (i *Importer) ParseDocx(content []byte) ([]RichParagraph, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCX file: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+	read := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	document, err := read("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, errors.New("document.xml not found in DOCX")
+	}
+	imp := docxImport{
+		styles:    map[string]docxStyle{},
+		numbering: docxNumbering{abstract: map[string]map[int]docxLevel{}, instances: map[string]string{}, overrides: map[string]map[int]int{}},
+		links:     map[string]string{},
+	}
+	if data, err := read("word/styles.xml"); err == nil && data != nil {
+		imp.parseStyles(data)
+	}
+	if data, err := read("word/numbering.xml"); err == nil && data != nil {
+		imp.parseNumbering(data)
+	}
+	if data, err := read("word/_rels/document.xml.rels"); err == nil && data != nil {
+		imp.parseRels(data)
+	}
+	return imp.parseDocument(document)
+}
+
+func xmlAttr(se xml.StartElement, local string) (string, bool) {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// docxToggle reads an on/off property such as <w:b/> or <w:b w:val="0"/>.
+func docxToggle(se xml.StartElement) *bool {
+	val, ok := xmlAttr(se, "val")
+	on := !ok || !(val == "0" || val == "false" || val == "off" || val == "none")
+	return &on
+}
+
+// applyRunProp updates props from one child element of w:rPr.
+func applyRunProp(props *runProps, se xml.StartElement) {
+	switch se.Name.Local {
+	case "b":
+		props.bold = docxToggle(se)
+	case "i":
+		props.italic = docxToggle(se)
+	case "u":
+		props.underline = docxToggle(se)
+	case "strike", "dstrike":
+		props.strike = docxToggle(se)
+	case "color":
+		props.color, _ = xmlAttr(se, "val")
+	case "sz":
+		if v, ok := xmlAttr(se, "val"); ok {
+			props.halfPoints, _ = strconv.Atoi(v)
+		}
+	case "rFonts":
+		if v, ok := xmlAttr(se, "ascii"); ok {
+			props.font = v
+		} else if v, ok := xmlAttr(se, "hAnsi"); ok {
+			props.font = v
+		}
+	}
+}
+
+// applyParaProp updates props from one element inside w:pPr.
+func applyParaProp(props *docxParaProps, se xml.StartElement) {
+	val, _ := xmlAttr(se, "val")
+	switch se.Name.Local {
+	case "pStyle":
+		props.style = val
+	case "jc":
+		props.align = val
+	case "numPr":
+		props.hasNumPr = true
+	case "numId":
+		props.numId = val
+	case "ilvl":
+		props.ilvl, _ = strconv.Atoi(val)
+	case "ind":
+		left, ok := xmlAttr(se, "left")
+		if !ok {
+			left, _ = xmlAttr(se, "start")
+		}
+		props.indentLeft, _ = strconv.Atoi(left)
+	}
+}
+
+func (d *docxImport) parseStyles(data []byte) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var current *docxStyle
+	var id string
+	inRPr, inPPr := false, false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "style":
+				id, _ = xmlAttr(t, "styleId")
+				current = &docxStyle{}
+			case current == nil:
+			case t.Name.Local == "name":
+				current.name, _ = xmlAttr(t, "val")
+			case t.Name.Local == "basedOn":
+				current.basedOn, _ = xmlAttr(t, "val")
+			case t.Name.Local == "rPr":
+				inRPr = true
+			case t.Name.Local == "pPr":
+				inPPr = true
+			case inRPr:
+				applyRunProp(&current.run, t)
+			case inPPr:
+				applyParaProp(&current.para, t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "style":
+				if current != nil {
+					d.styles[id] = *current
+				}
+				current = nil
+			case "rPr":
+				inRPr = false
+			case "pPr":
+				inPPr = false
+			}
+		}
+	}
+}
+
+func (d *docxImport) parseNumbering(data []byte) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var abstractId, numId string
+	ilvl := -1
+	inNum := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			val, _ := xmlAttr(t, "val")
+			switch t.Name.Local {
+			case "abstractNum":
+				abstractId, _ = xmlAttr(t, "abstractNumId")
+				d.numbering.abstract[abstractId] = map[int]docxLevel{}
+			case "num":
+				numId, _ = xmlAttr(t, "numId")
+				inNum = true
+			case "abstractNumId":
+				if inNum {
+					d.numbering.instances[numId] = val
+				}
+			case "lvl", "lvlOverride":
+				v, _ := xmlAttr(t, "ilvl")
+				ilvl, _ = strconv.Atoi(v)
+				// A level outside any abstractNum has nothing to belong to.
+				if lvls, ok := d.numbering.abstract[abstractId]; ok && !inNum {
+					lvls[ilvl] = docxLevel{format: "decimal", start: 1}
+				}
+			case "start":
+				if lvls, ok := d.numbering.abstract[abstractId]; ok && !inNum && ilvl >= 0 {
+					lvl := lvls[ilvl]
+					lvl.start, _ = strconv.Atoi(val)
+					lvls[ilvl] = lvl
+				}
+			case "startOverride":
+				if inNum {
+					if d.numbering.overrides[numId] == nil {
+						d.numbering.overrides[numId] = map[int]int{}
+					}
+					d.numbering.overrides[numId][ilvl], _ = strconv.Atoi(val)
+				}
+			case "numFmt":
+				if lvls, ok := d.numbering.abstract[abstractId]; ok && !inNum && ilvl >= 0 {
+					lvl := lvls[ilvl]
+					lvl.format = val
+					lvls[ilvl] = lvl
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "abstractNum":
+				abstractId = ""
+			case "num":
+				inNum = false
+			case "lvl", "lvlOverride":
+				ilvl = -1
+			}
+		}
+	}
+}
+
+func (d *docxImport) parseRels(data []byte) {
+	var rels struct {
+		Relationships []struct {
+			Id     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if xml.Unmarshal(data, &rels) != nil {
+		return
+	}
+	for _, r := range rels.Relationships {
+		if strings.HasSuffix(r.Type, "/hyperlink") {
+			d.links[r.Id] = r.Target
+		}
+	}
+}
+
+// styleChain returns the style with id followed by the styles it is based
+// on.
+func (d *docxImport) styleChain(id string) []docxStyle {
+	var chain []docxStyle
+	seen := map[string]bool{}
+	for id != "" && !seen[id] {
+		seen[id] = true
+		style, ok := d.styles[id]
+		if !ok {
+			break
+		}
+		chain = append(chain, style)
+		id = style.basedOn
+	}
+	return chain
+}
+
+func (d *docxImport) characterStyle(id string) runProps {
+	var props runProps
+	for _, style := range d.styleChain(id) {
+		props = props.over(style.run)
+	}
+	return props
+}
+
+var docxAlign = map[string]string{
+	"left":       "left",
+	"start":      "left",
+	"center":     "center",
+	"right":      "right",
+	"end":        "right",
+	"both":       "justify",
+	"distribute": "justify",
+}
+
+// resolveParagraph fills in the line attributes of para from its
+// properties and paragraph style. counters tracks the number of the next
+// item per list instance and level.
+func (d *docxImport) resolveParagraph(props docxParaProps, para *RichParagraph, counters map[string]map[int]int) {
+	for _, style := range d.styleChain(props.style) {
+		if para.Heading == "" {
+			para.Heading = headingForStyleName(style.name)
+		}
+		if props.align == "" {
+			props.align = style.para.align
+		}
+		if !props.hasNumPr && style.para.hasNumPr {
+			props.hasNumPr, props.numId, props.ilvl = true, style.para.numId, style.para.ilvl
+		}
+		if props.indentLeft == 0 {
+			props.indentLeft = style.para.indentLeft
+		}
+	}
+	if para.Heading == "" {
+		para.Heading = headingForStyleName(props.style)
+	}
+	para.Align = docxAlign[props.align]
+
+	switch {
+	case para.Heading != "":
+	case props.hasNumPr && props.numId != "" && props.numId != "0":
+		lvl := d.numbering.level(props.numId, props.ilvl)
+		level := props.ilvl + 1
+		switch lvl.format {
+		case "bullet":
+			para.List = "bullet" + strconv.Itoa(level)
+		case "none":
+			para.List = "indent" + strconv.Itoa(level)
+		default:
+			para.List = "number" + strconv.Itoa(level)
+			levels := counters[props.numId]
+			if levels == nil {
+				levels = map[int]int{}
+				counters[props.numId] = levels
+			}
+			next, ok := levels[props.ilvl]
+			if !ok {
+				next = lvl.start
+			}
+			para.Start = next
+			levels[props.ilvl] = next + 1
+			// A new parent item restarts the numbering of deeper levels.
+			for deeper := range levels {
+				if deeper > props.ilvl {
+					delete(levels, deeper)
+				}
+			}
+		}
+	case props.indentLeft >= 360:
+		para.List = "indent" + strconv.Itoa(max(1, (props.indentLeft+360)/720))
+	}
+}
+
+func (d *docxImport) parseDocument(data []byte) ([]RichParagraph, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var paragraphs []RichParagraph
+	counters := map[string]map[int]int{}
+
+	var (
+		para           *RichParagraph
+		paraProps      docxParaProps
+		run            runProps
+		inPPr, inRPr   bool
+		inText         bool
+		linkTarget     string
+		inLink         bool
+		linkText       strings.Builder
+		skipDepth      int
+		paraStack      []*RichParagraph
+		paraPropsStack []docxParaProps
+		runStyle       runProps
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid document.xml: %w", err)
+		}
+		if skipDepth > 0 {
+			switch tok.(type) {
+			case xml.StartElement:
+				skipDepth++
+			case xml.EndElement:
+				skipDepth--
+			}
+			continue
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "Fallback" || t.Name.Local == "instrText" || t.Name.Local == "delText":
+				// Alternate renderings of content already seen, field codes
+				// and deleted text.
+				skipDepth = 1
+			case t.Name.Local == "p":
+				if para != nil {
+					paraStack = append(paraStack, para)
+					paraPropsStack = append(paraPropsStack, paraProps)
+				}
+				para = &RichParagraph{}
+				paraProps = docxParaProps{}
+			case para == nil:
+			case t.Name.Local == "pPr":
+				inPPr = true
+			case inPPr && t.Name.Local == "rPr":
+				// Paragraph mark properties; they do not format any text.
+				skipDepth = 1
+			case inPPr:
+				applyParaProp(&paraProps, t)
+			case t.Name.Local == "hyperlink":
+				inLink = true
+				linkText.Reset()
+				id, _ := xmlAttr(t, "id")
+				linkTarget = d.links[id]
+			case t.Name.Local == "r":
+				run, runStyle = runProps{}, runProps{}
+			case t.Name.Local == "rPr":
+				inRPr = true
+			case inRPr && t.Name.Local == "rStyle":
+				// The editor links URLs itself, so the look of Word's
+				// Hyperlink character style is not copied.
+				if id, _ := xmlAttr(t, "val"); !inLink {
+					runStyle = d.characterStyle(id)
+				}
+			case inRPr:
+				applyRunProp(&run, t)
+			case t.Name.Local == "t":
+				inText = true
+			case t.Name.Local == "tab":
+				d.addText(para, "\t", run.over(runStyle), inLink, &linkText)
+			case t.Name.Local == "br" || t.Name.Local == "cr":
+				d.addText(para, "\n", runProps{}, inLink, &linkText)
+			case t.Name.Local == "noBreakHyphen":
+				d.addText(para, "-", run.over(runStyle), inLink, &linkText)
+			}
+		case xml.CharData:
+			if inText && para != nil {
+				d.addText(para, string(t), run.over(runStyle), inLink, &linkText)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "pPr":
+				inPPr = false
+			case "rPr":
+				inRPr = false
+			case "hyperlink":
+				// Pads have no link attribute; the editor links URLs found
+				// in the text. So a link whose text does not show its
+				// target keeps the target after it.
+				if linkTarget != "" && !strings.Contains(linkText.String(), linkTarget) && para != nil {
+					para.addRun(" ("+linkTarget+")", map[string]string{})
+				}
+				inLink, linkTarget = false, ""
+			case "p":
+				if para == nil {
+					continue
+				}
+				d.resolveParagraph(paraProps, para, counters)
+				paragraphs = append(paragraphs, *para)
+				para = nil
+				if n := len(paraStack); n > 0 {
+					para, paraProps = paraStack[n-1], paraPropsStack[n-1]
+					paraStack, paraPropsStack = paraStack[:n-1], paraPropsStack[:n-1]
+				}
+			}
+		}
+	}
+	return paragraphs, nil
+}
+
+func (d *docxImport) addText(para *RichParagraph, text string, props runProps, inLink bool, linkText *strings.Builder) {
+	if inLink {
+		linkText.WriteString(text)
+	}
+	para.addRun(text, props.attrs())
+}
@@ -0,0 +1,282 @@
+package io
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildDocx(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseDocx_InvalidFile(t *testing.T) {
+	importer := &Importer{}
+
+	_, err := importer.ParseDocx([]byte("not a zip file"))
+	assert.Error(t, err)
+}
+
+func TestParseDocx_RoundTripExport(t *testing.T) {
+	exported, err := (&ExportDocx{}).generateDocx([]docxParagraph{
+		{heading: "Heading1", segments: []docxTextSegment{{text: "Title"}}},
+		{heading: "Heading3", alignment: "center", segments: []docxTextSegment{{text: "Section"}}},
+		{segments: []docxTextSegment{{text: "plain "}, {text: "bold", bold: true}, {text: " "}, {text: "both", italic: true, underline: true}, {text: " gone", strikethrough: true}}},
+		{listType: "bullet", listLevel: 1, segments: []docxTextSegment{{text: "bullet"}}},
+		{listType: "bullet", listLevel: 2, segments: []docxTextSegment{{text: "nested"}}},
+		{listType: "number", listLevel: 1, segments: []docxTextSegment{{text: "one"}}},
+		{listType: "number", listLevel: 2, segments: []docxTextSegment{{text: "one.a"}}},
+		{listType: "number", listLevel: 1, segments: []docxTextSegment{{text: "two"}}},
+		{listType: "number", listLevel: 2, segments: []docxTextSegment{{text: "two.a"}}},
+		{alignment: "justify", segments: []docxTextSegment{{text: "justified", authorColor: "#ffc7c7"}}},
+	})
+	require.NoError(t, err)
+
+	paragraphs, err := (&Importer{}).ParseDocx(exported)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h1", Runs: []RichRun{{Text: "Title", Attrs: none}}},
+		{Heading: "h3", Align: "center", Runs: []RichRun{{Text: "Section", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "plain ", Attrs: none},
+			{Text: "bold", Attrs: map[string]string{"bold": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "both", Attrs: map[string]string{"italic": "true", "underline": "true"}},
+			{Text: " gone", Attrs: map[string]string{"strikethrough": "true"}},
+		}},
+		{List: "bullet1", Runs: []RichRun{{Text: "bullet", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "nested", Attrs: none}}},
+		{List: "number1", Start: 1, Runs: []RichRun{{Text: "one", Attrs: none}}},
+		{List: "number2", Start: 1, Runs: []RichRun{{Text: "one.a", Attrs: none}}},
+		{List: "number1", Start: 2, Runs: []RichRun{{Text: "two", Attrs: none}}},
+		{List: "number2", Start: 1, Runs: []RichRun{{Text: "two.a", Attrs: none}}},
+		{Align: "justify", Runs: []RichRun{{Text: "justified", Attrs: none}}},
+	}, paragraphs)
+}
+
+func TestParseDocx_StylesNumberingAndLinks(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	docx := buildDocx(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document ` + ns + `><w:body>
+<w:p><w:pPr><w:pStyle w:val="Quote"/></w:pPr><w:r><w:t>quoted</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="ListParagraph"/><w:numPr><w:ilvl w:val="0"/><w:numId w:val="7"/></w:numPr></w:pPr><w:r><w:t>fifth</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="7"/></w:numPr></w:pPr><w:r><w:t>sixth</w:t></w:r></w:p>
+<w:p><w:pPr><w:ind w:left="1440"/></w:pPr><w:r><w:t>indented</w:t></w:r></w:p>
+<w:p>
+  <w:r><w:rPr><w:rStyle w:val="Strong"/></w:rPr><w:t>strong</w:t></w:r>
+  <w:r><w:rPr><w:rStyle w:val="Strong"/><w:b w:val="0"/><w:color w:val="E01010"/><w:sz w:val="28"/><w:rFonts w:ascii="Courier New"/></w:rPr><w:t xml:space="preserve"> red</w:t></w:r>
+  <w:r><w:rPr><w:u w:val="none"/></w:rPr><w:t xml:space="preserve"> </w:t><w:tab/><w:t>x</w:t><w:br/><w:t>y</w:t></w:r>
+</w:p>
+<w:p><w:r><w:t xml:space="preserve">see </w:t></w:r><w:hyperlink r:id="rId9"><w:r><w:rPr><w:rStyle w:val="Hyperlink"/></w:rPr><w:t>our site</w:t></w:r></w:hyperlink></w:p>
+<w:p><w:hyperlink r:id="rId9"><w:r><w:t>https://example.com/</w:t></w:r></w:hyperlink></w:p>
+<w:p><w:r><w:instrText>HYPERLINK "x"</w:instrText><w:delText>removed</w:delText><w:t>kept</w:t></w:r></w:p>
+</w:body></w:document>`,
+		"word/styles.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:styles ` + ns + `>
+<w:style w:type="paragraph" w:styleId="Centered"><w:name w:val="Centered"/><w:pPr><w:jc w:val="center"/></w:pPr><w:rPr><w:b/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Centered"/></w:style>
+<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:pPr><w:ind w:left="720"/></w:pPr></w:style>
+<w:style w:type="character" w:styleId="Strong"><w:name w:val="Strong"/><w:rPr><w:b/></w:rPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style>
+</w:styles>`,
+		"word/numbering.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:numbering ` + ns + `>
+<w:abstractNum w:abstractNumId="3"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="upperRoman"/></w:lvl></w:abstractNum>
+<w:num w:numId="7"><w:abstractNumId w:val="3"/><w:lvlOverride w:ilvl="0"><w:startOverride w:val="5"/></w:lvlOverride></w:num>
+</w:numbering>`,
+		"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId9" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/" TargetMode="External"/>
+</Relationships>`,
+	})
+
+	paragraphs, err := (&Importer{}).ParseDocx(docx)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Align: "center", Runs: []RichRun{{Text: "quoted", Attrs: none}}},
+		{List: "number1", Start: 5, Runs: []RichRun{{Text: "fifth", Attrs: none}}},
+		{List: "number1", Start: 6, Runs: []RichRun{{Text: "sixth", Attrs: none}}},
+		{List: "indent2", Runs: []RichRun{{Text: "indented", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "strong", Attrs: map[string]string{"bold": "true"}},
+			{Text: " red", Attrs: map[string]string{"color": "red", "font-size": "14px", "font-family": "courier"}},
+			{Text: " \tx\ny", Attrs: none},
+		}},
+		{Runs: []RichRun{{Text: "see our site (https://example.com/)", Attrs: none}}},
+		{Runs: []RichRun{{Text: "https://example.com/", Attrs: none}}},
+		{Runs: []RichRun{{Text: "kept", Attrs: none}}},
+	}, paragraphs)
+}
+
+// Pads have no link attribute, so a hyperlink keeps its target as text the
+// editor links itself.
+func TestParseDocx_HyperlinkTargetsBecomeText(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	docx := buildDocx(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document ` + ns + `><w:body>
+<w:p><w:hyperlink r:id="rId1"><w:r><w:rPr><w:b/></w:rPr><w:t>docs</w:t></w:r></w:hyperlink><w:r><w:t xml:space="preserve"> here</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">at </w:t></w:r><w:hyperlink r:id="rId1"><w:r><w:t>https://example.com/docs</w:t></w:r></w:hyperlink></w:p>
+<w:p><w:hyperlink w:anchor="_Toc1"><w:r><w:t>Chapter 1</w:t></w:r></w:hyperlink></w:p>
+</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/docs" TargetMode="External"/>
+</Relationships>`,
+	})
+
+	paragraphs, err := (&Importer{}).ParseDocx(docx)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Runs: []RichRun{
+			{Text: "docs", Attrs: map[string]string{"bold": "true"}},
+			{Text: " (https://example.com/docs) here", Attrs: none},
+		}},
+		{Runs: []RichRun{{Text: "at https://example.com/docs", Attrs: none}}},
+		{Runs: []RichRun{{Text: "Chapter 1", Attrs: none}}},
+	}, paragraphs)
+}
+
+func TestParseDocx_LevelOutsideAbstractNum(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	docx := buildDocx(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document ` + ns + `><w:body>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>item</w:t></w:r></w:p>
+</w:body></w:document>`,
+		"word/numbering.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:numbering ` + ns + `>
+<w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl>
+<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>
+<w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl>
+<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+</w:numbering>`,
+	})
+
+	paragraphs, err := (&Importer{}).ParseDocx(docx)
+	require.NoError(t, err)
+	assert.Equal(t, []RichParagraph{{List: "bullet1", Runs: []RichRun{{Text: "item", Attrs: map[string]string{}}}}}, paragraphs)
+}
+
+func TestSetPadRich_RoundTripExport(t *testing.T) {
+	hook := hooks.NewHook()
+	pad := padModel.NewPad("richimport", db.NewMemoryDataStore(), &hook)
+	text := "old content\n"
+	require.NoError(t, pad.Init(&text, nil, nil))
+
+	err := SetPadRich(&pad, []RichParagraph{
+		{Heading: "h2", Runs: []RichRun{{Text: "Heading"}}},
+		{Runs: []RichRun{{Text: "a "}, {Text: "b", Attrs: map[string]string{"bold": "true", "color": "red"}}}},
+		{List: "number1", Start: 3, Runs: []RichRun{{Text: "item"}}},
+	}, "a.rich")
+	require.NoError(t, err)
+	assert.Equal(t, "*Heading\na b\n*item\n", pad.Text())
+
+	alines, err := changeset.SplitAttributionLines(pad.AText.Attribs, pad.AText.Text)
+	require.NoError(t, err)
+	lines := changeset.SplitTextLines(pad.AText.Text)
+	require.Len(t, alines, 3)
+
+	heading := changeset.FromString(mustFirstOp(t, alines[0]).Attribs, &pad.Pool)
+	assert.Equal(t, "h2", *heading.Get("heading"))
+	number := changeset.FromString(mustFirstOp(t, alines[2]).Attribs, &pad.Pool)
+	assert.Equal(t, "number1", *number.Get("list"))
+	assert.Equal(t, "3", *number.Get("start"))
+
+	export := &ExportDocx{}
+	strip := func(line string) string { return line[:len(line)-1] }
+	para, err := export.parseLineSegments(strip(lines[0]), alines[0], &pad.Pool, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []docxTextSegment{{text: "Heading"}}, para.segments)
+
+	para, err = export.parseLineSegments(strip(lines[1]), alines[1], &pad.Pool, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []docxTextSegment{{text: "a "}, {text: "b", bold: true}}, para.segments)
+
+	para, err = export.parseLineSegments(strip(lines[2]), alines[2], &pad.Pool, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "number", para.listType)
+	assert.Equal(t, []docxTextSegment{{text: "item"}}, para.segments)
+}
+
+func TestReadPadRich_RoundTrip(t *testing.T) {
+	hook := hooks.NewHook()
+	pad := padModel.NewPad("richread", db.NewMemoryDataStore(), &hook)
+	text := "old content\n"
+	require.NoError(t, pad.Init(&text, nil, nil))
+
+	want := []RichParagraph{
+		{Heading: "h2", Align: "center", Runs: []RichRun{{Text: "Heading"}}},
+		{Runs: []RichRun{{Text: "a | "}, {Text: "b", Attrs: map[string]string{"bold": "true", "italic": "true"}}}},
+		{},
+		{List: "number1", Start: 3, Runs: []RichRun{{Text: "item"}}},
+	}
+	require.NoError(t, SetPadRich(&pad, want, "a.rich"))
+
+	got, err := ReadPadRich(pad.AText, &pad.Pool)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func mustFirstOp(t *testing.T, aline string) changeset.Op {
+	t.Helper()
+	ops, err := changeset.DeserializeOps(aline)
+	require.NoError(t, err)
+	require.NotEmpty(t, *ops)
+	return (*ops)[0]
+}
+
+func TestNearestFontColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FF0000", "red"},
+		{"#0563C1", "blue"},
+		{"#f90", "orange"},
+		{"000000", ""},
+		{"auto", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, nearestFontColor(tt.in), tt.in)
+	}
+}
+
+func TestMatchFontFamily(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Arial", "arial"},
+		{"Times New Roman", "times-new-roman"},
+		{"'Courier New'", "courier"},
+		{"Comic Sans MS", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchFontFamily(tt.in), tt.in)
+	}
+}
@@ -0,0 +1,133 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+)
+
+// RegisterFormats registers the built-in import formats in r.
+func (i *Importer) RegisterFormats(r *FormatRegistry) {
+	rich := func(label string, parse func([]byte) ([]RichParagraph, error)) ImportFunc {
+		return func(content []byte) (*ImportedDocument, error) {
+			paragraphs, err := parse(content)
+			if err != nil {
+				i.logger.Warnf("Could not read %s: %v", label, err)
+				return nil, fmt.Errorf("could not read %s file", label)
+			}
+			return &ImportedDocument{Paragraphs: paragraphs}, nil
+		}
+	}
+	builtins := []ImportFormat{
+		{Name: "etherpad", Extensions: []string{".etherpad"}, Import: func(content []byte) (*ImportedDocument, error) {
+			return &ImportedDocument{Etherpad: content}, nil
+		}},
+		{Name: "html", Extensions: []string{".html", ".htm"}, Import: func(content []byte) (*ImportedDocument, error) {
+			text := string(content)
+			return &ImportedDocument{HTML: &text}, nil
+		}},
+		{Name: "txt", Extensions: []string{".txt"}, Import: func(content []byte) (*ImportedDocument, error) {
+			text := string(content)
+			return &ImportedDocument{Text: &text}, nil
+		}},
+		{Name: "docx", Extensions: []string{".docx", ".doc"}, Import: rich("DOCX", i.ParseDocx)},
+		{Name: "odt", Extensions: []string{".odt"}, Import: rich("ODT", i.ParseOdt)},
+		{Name: "rtf", Extensions: []string{".rtf"}, Import: rich("RTF", i.ParseRtf)},
+		{Name: "markdown", Extensions: []string{".md", ".markdown"}, Import: rich("Markdown", func(content []byte) ([]RichParagraph, error) {
+			return i.ParseMarkdown(string(content))
+		})},
+		{Name: "pdf", Extensions: []string{".pdf"}, Import: i.importPdf},
+	}
+	for _, format := range builtins {
+		_ = r.RegisterImport(format)
+	}
+}
+
+// importPdf prefers the Etherpad JSON the PDF export embeds, similar to
+// ZUGFeRD, and falls back to the text of the PDF.
+func (i *Importer) importPdf(content []byte) (*ImportedDocument, error) {
+	etherpadJson, err := i.ExtractEtherpadFromPdf(content)
+	if err == nil && etherpadJson != nil {
+		i.logger.Info("Found embedded Etherpad JSON in PDF, attempting lossless import")
+		doc := &ImportedDocument{Etherpad: etherpadJson}
+		// Pads with content cannot take the raw import, they get the text.
+		if text, err := i.ExtractTextFromEtherpadJson(etherpadJson); err == nil && text != "" {
+			doc.Text = &text
+			return doc, nil
+		} else if err != nil {
+			i.logger.Warnf("Could not extract text from Etherpad JSON: %v", err)
+		}
+		if text, err := i.ExtractTextFromPdf(content); err == nil {
+			doc.Text = &text
+		}
+		return doc, nil
+	}
+
+	i.logger.Info("Falling back to text extraction from PDF")
+	text, err := i.ExtractTextFromPdf(content)
+	if err != nil {
+		i.logger.Warnf("Could not extract text from PDF: %v", err)
+		return nil, errors.New("could not read PDF file")
+	}
+	return &ImportedDocument{Text: &text}, nil
+}
+
+// SetPadAText replaces the content of pad with atext in a single revision.
+// The attribute numbers of atext refer to pool.
+func (i *Importer) SetPadAText(pad *padModel.Pad, atext apool.AText, pool *apool.APool, authorId string) error {
+	if !strings.HasSuffix(atext.Text, "\n") {
+		return errors.New("text must end with a newline")
+	}
+	ops, err := changeset.DeserializeOps(atext.Attribs)
+	if err != nil {
+		return err
+	}
+	newLen := utf8.RuneCountInString(atext.Text)
+	orig := pad.Text()
+	oldLen := utf8.RuneCountInString(orig)
+
+	assem := changeset.NewSmartOpAssembler()
+	assem.Append(changeset.Op{OpCode: "-", Chars: oldLen, Lines: strings.Count(orig, "\n")})
+	chars := 0
+	for _, op := range *ops {
+		if op.OpCode != "+" {
+			return errors.New("attributes must only contain insert operations")
+		}
+		chars += op.Chars
+		assem.Append(op)
+	}
+	if chars != newLen {
+		return fmt.Errorf("attributes cover %d characters, the text has %d", chars, newLen)
+	}
+	assem.EndDocument()
+	return i.ApplyPadChangeset(pad, changeset.Pack(oldLen, newLen, assem.String(), atext.Text), pool, authorId)
+}
+
+// ApplyPadChangeset appends cs, a changeset against the current text of pad
+// whose attribute numbers refer to pool, as a new revision. A nil pool means
+// the changeset uses the pad's own pool.
+func (i *Importer) ApplyPadChangeset(pad *padModel.Pad, cs string, pool *apool.APool, authorId string) error {
+	if authorId == "" {
+		authorId = padModel.SystemAuthorId
+	}
+	if _, err := changeset.CheckRep(cs); err != nil {
+		return fmt.Errorf("invalid changeset: %w", err)
+	}
+	oldLen, err := changeset.OldLen(cs)
+	if err != nil {
+		return err
+	}
+	if textLen := utf8.RuneCountInString(pad.Text()); *oldLen != textLen {
+		return fmt.Errorf("changeset applies to a text of %d characters, the pad has %d", *oldLen, textLen)
+	}
+	if pool != nil {
+		cs = changeset.MoveOpsToNewPool(cs, pool, &pad.Pool)
+	}
+	_, err = pad.AppendRevision(cs, &authorId)
+	return err
+}
@@ -0,0 +1,309 @@
+package io
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	htmlSpace     = regexp.MustCompile(`[ \t\n\r\f]+`)
+	htmlFontSize  = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(?:px|pt)?$`)
+	htmlBlocks    = map[string]bool{"p": true, "div": true, "blockquote": true, "tr": true, "dt": true, "dd": true, "section": true, "article": true, "header": true, "footer": true}
+	htmlSkipped   = map[string]bool{"head": true, "script": true, "style": true, "template": true, "title": true}
+	htmlAlignings = map[string]string{"left": "left", "start": "left", "center": "center", "right": "right", "end": "right", "justify": "justify"}
+)
+
+// htmlList is an open <ul> or <ol> of the HTML import.
+type htmlList struct {
+	kind string
+	next int
+}
+
+// htmlImport walks an HTML document and collects the pad lines. A line ends
+// at a block element or a <br>.
+type htmlImport struct {
+	out   []RichParagraph
+	para  RichParagraph
+	lists []htmlList
+	// space is set when the line ends with collapsed whitespace, which is
+	// dropped before more text and at the end of the line.
+	space bool
+	// closed is set after a block ended a line, so that the <br> the HTML
+	// export writes after headings and aligned lines does not add another.
+	closed bool
+}
+
+// ParseHTML converts HTML into pad paragraphs: emphasis, headings, nested
+// ordered and unordered lists, alignment and the font color, size and family
+// the HTML export writes. Whitespace collapses as in a browser, &nbsp; is
+// kept. Inline code becomes monospace text and <pre> lines "code" headings.
+func (i *Importer) ParseHTML(content string) ([]RichParagraph, error) {
+	if !utf8.ValidString(content) {
+		return nil, errors.New("HTML is not valid UTF-8")
+	}
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	h := &htmlImport{}
+	h.walk(doc, map[string]string{})
+	if len(h.para.Runs) > 0 {
+		h.flush()
+	}
+	return h.out, nil
+}
+
+func (h *htmlImport) walk(n *html.Node, attrs map[string]string) {
+	switch n.Type {
+	case html.TextNode:
+		h.text(n.Data, attrs)
+		return
+	case html.DocumentNode:
+		h.children(n, attrs)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if htmlSkipped[n.Data] {
+		return
+	}
+	attrs = htmlRunAttrs(n, attrs)
+
+	switch n.Data {
+	case "br":
+		if h.closed {
+			h.closed = false
+			return
+		}
+		h.flush()
+	case "ul", "ol":
+		list := htmlList{kind: "bullet"}
+		if n.Data == "ol" {
+			list.kind = "number"
+			list.next = 1
+			if start, err := strconv.Atoi(htmlAttr(n, "start")); err == nil {
+				list.next = start
+			}
+		} else if strings.Contains(htmlAttr(n, "class"), "indent") {
+			list.kind = "indent"
+		}
+		h.startBlock(RichParagraph{})
+		h.lists = append(h.lists, list)
+		h.children(n, attrs)
+		h.lists = h.lists[:len(h.lists)-1]
+		h.endBlock()
+	case "li":
+		var para RichParagraph
+		if len(h.lists) > 0 {
+			top := &h.lists[len(h.lists)-1]
+			para.List = top.kind + strconv.Itoa(len(h.lists))
+			if top.kind == "number" {
+				para.Start = top.next
+				top.next++
+			}
+		}
+		para.Align = htmlAlign(n)
+		h.startBlock(para)
+		h.children(n, attrs)
+		h.endBlock()
+	case "h1", "h2", "h3", "h4", "h5", "h6", "pre":
+		para := RichParagraph{Heading: "code", Align: htmlAlign(n)}
+		if n.Data != "pre" {
+			para.Heading = mdHeadingLevel[n.Data[1]-'0']
+		}
+		h.startBlock(para)
+		h.children(n, attrs)
+		h.endBlock()
+	default:
+		if !htmlBlocks[n.Data] {
+			h.children(n, attrs)
+			return
+		}
+		h.startBlock(RichParagraph{Align: htmlAlign(n)})
+		h.children(n, attrs)
+		h.endBlock()
+	}
+}
+
+func (h *htmlImport) children(n *html.Node, attrs map[string]string) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		h.walk(c, attrs)
+	}
+}
+
+// text adds a text node to the current line, collapsing whitespace.
+func (h *htmlImport) text(data string, attrs map[string]string) {
+	data = htmlSpace.ReplaceAllString(data, " ")
+	if h.space || len(h.para.Runs) == 0 {
+		data = strings.TrimPrefix(data, " ")
+	}
+	if data == "" {
+		return
+	}
+	h.para.addRun(strings.ReplaceAll(data, "\u00a0", " "), attrs)
+	h.space = strings.HasSuffix(data, " ")
+	h.closed = false
+}
+
+// startBlock ends the current line if it has text and gives the next line
+// the line attributes set in para. A <p> inside an <li> keeps the list.
+func (h *htmlImport) startBlock(para RichParagraph) {
+	if len(h.para.Runs) > 0 {
+		h.flush()
+	}
+	if para.List != "" {
+		h.para.List, h.para.Start = para.List, para.Start
+	}
+	if para.Heading != "" {
+		h.para.Heading = para.Heading
+	}
+	if para.Align != "" {
+		h.para.Align = para.Align
+	}
+}
+
+func (h *htmlImport) endBlock() {
+	if len(h.para.Runs) == 0 {
+		h.para = RichParagraph{}
+		return
+	}
+	h.flush()
+	h.closed = true
+}
+
+func (h *htmlImport) flush() {
+	if n := len(h.para.Runs); h.space && n > 0 {
+		last := &h.para.Runs[n-1]
+		if last.Text = strings.TrimSuffix(last.Text, " "); last.Text == "" {
+			h.para.Runs = h.para.Runs[:n-1]
+		}
+	}
+	h.out = append(h.out, h.para)
+	h.para = RichParagraph{}
+	h.space = false
+	h.closed = false
+}
+
+// htmlRunAttrs returns attrs with the character attributes of n added: the
+// emphasis tags, inline code, the style attribute and the data-* attributes
+// or "color:red" classes of the HTML export.
+func htmlRunAttrs(n *html.Node, attrs map[string]string) map[string]string {
+	set := func(key, value string) {
+		if value == "" || attrs[key] == value {
+			return
+		}
+		copied := make(map[string]string, len(attrs)+1)
+		for k, v := range attrs {
+			copied[k] = v
+		}
+		copied[key] = value
+		attrs = copied
+	}
+	if attr, ok := mdHTMLAttrs[n.Data]; ok {
+		set(attr, "true")
+	}
+	if n.Data == "code" {
+		set("font-family", "monospace")
+	}
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "data-color":
+			set("color", htmlColor(a.Val))
+		case "data-font-size":
+			set("font-size", htmlSize(a.Val))
+		case "data-font-family":
+			set("font-family", matchFontFamily(a.Val))
+		case "class":
+			for _, class := range strings.Fields(a.Val) {
+				key, value, _ := strings.Cut(class, ":")
+				switch key {
+				case "color":
+					set("color", htmlColor(value))
+				case "font-size":
+					set("font-size", htmlSize(value))
+				}
+			}
+		case "style":
+			for _, decl := range strings.Split(a.Val, ";") {
+				key, value, _ := strings.Cut(decl, ":")
+				value = strings.ToLower(strings.TrimSpace(value))
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "font-weight":
+					if weight, _ := strconv.Atoi(value); value == "bold" || value == "bolder" || weight >= 600 {
+						set("bold", "true")
+					}
+				case "font-style":
+					if value == "italic" || value == "oblique" {
+						set("italic", "true")
+					}
+				case "text-decoration", "text-decoration-line":
+					if strings.Contains(value, "underline") {
+						set("underline", "true")
+					}
+					if strings.Contains(value, "line-through") {
+						set("strikethrough", "true")
+					}
+				case "color":
+					set("color", htmlColor(value))
+				case "font-size":
+					set("font-size", htmlSize(value))
+				case "font-family":
+					family, _, _ := strings.Cut(value, ",")
+					set("font-family", matchFontFamily(family))
+				}
+			}
+		}
+	}
+	return attrs
+}
+
+// htmlColor maps an ep_font_color name or a hex color to an ep_font_color
+// value.
+func htmlColor(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, c := range fontColors {
+		if value == c.name {
+			if value == "black" {
+				return ""
+			}
+			return value
+		}
+	}
+	return nearestFontColor(value)
+}
+
+// htmlSize maps a size in px or pt to an ep_font_size value.
+func htmlSize(value string) string {
+	m := htmlFontSize.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return ""
+	}
+	size, _ := strconv.ParseFloat(m[1], 64)
+	return nearestFontSize(size)
+}
+
+// htmlAlign returns the ep_align value of a block element.
+func htmlAlign(n *html.Node) string {
+	for _, decl := range strings.Split(htmlAttr(n, "style"), ";") {
+		key, value, _ := strings.Cut(decl, ":")
+		if strings.EqualFold(strings.TrimSpace(key), "text-align") {
+			return htmlAlignings[strings.ToLower(strings.TrimSpace(value))]
+		}
+	}
+	return htmlAlignings[strings.ToLower(htmlAttr(n, "align"))]
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
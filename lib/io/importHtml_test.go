@@ -0,0 +1,146 @@
+package io
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/hooks/events"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHTML_InvalidUTF8(t *testing.T) {
+	_, err := (&Importer{}).ParseHTML("<p>\xff\xfe</p>")
+	assert.Error(t, err)
+}
+
+func TestParseHTML_RoundTripExport(t *testing.T) {
+	hook := hooks.NewHook()
+	// Mirrors ep_heading, which wraps heading lines for the HTML export.
+	hook.EnqueueHook("getLineHTMLForExport", func(ctx any) {
+		event := ctx.(*events.LineHtmlForExportContext)
+		ops, err := changeset.DeserializeOps(*event.AttribLine)
+		if err != nil || len(*ops) == 0 {
+			return
+		}
+		heading := changeset.FromString((*ops)[0].Attribs, event.Apool).Get("heading")
+		if heading == nil {
+			return
+		}
+		content := strings.Replace(*event.LineContent, "*", "", 1)
+		*event.LineContent = "<" + *heading + ">" + content + "</" + *heading + ">"
+	})
+	pad := padModel.NewPad("html", db.NewMemoryDataStore(), &hook)
+	text := "\n"
+	require.NoError(t, pad.Init(&text, nil, nil))
+
+	bold := map[string]string{"bold": "true"}
+	none := map[string]string{}
+	original := []RichParagraph{
+		{Heading: "h1", Runs: []RichRun{{Text: "Title", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "plain ", Attrs: none},
+			{Text: "bold", Attrs: bold},
+			{Text: " ", Attrs: none},
+			{Text: "both", Attrs: map[string]string{"bold": "true", "italic": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "under", Attrs: map[string]string{"underline": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "gone", Attrs: map[string]string{"strikethrough": "true"}},
+		}},
+		{Runs: []RichRun{{Text: "two  spaces & <tags>", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "first", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "nested", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "second", Attrs: none}}},
+		{Runs: []RichRun{{Text: "between", Attrs: none}}},
+		{List: "number1", Start: 3, Runs: []RichRun{{Text: "three", Attrs: none}}},
+		{List: "number1", Start: 4, Runs: []RichRun{{Text: "four", Attrs: none}}},
+		{Runs: []RichRun{{Text: "after", Attrs: none}}},
+	}
+	require.NoError(t, SetPadRich(&pad, original, "a.html"))
+
+	exported, err := (&ExportHtml{Hooks: &hook}).getHTMLFromAtext(pad.Id, &pad.Pool, pad.AText, nil)
+	require.NoError(t, err)
+	paragraphs, err := (&Importer{}).ParseHTML(exported)
+	require.NoError(t, err)
+	assert.Equal(t, original, paragraphs, exported)
+}
+
+func TestParseHTML_Blocks(t *testing.T) {
+	content := `<html><head><title>ignored</title><style>p{}</style></head><body>
+		<h2 style="text-align: center">Centered</h2>
+		<h5>deep</h5>
+		<p align="right">  right
+			aligned  </p>
+		<div>one<br>two<br><br>four</div>
+		<pre>code line</pre>
+		<ul class="indent"><li>indented</li></ul>
+		<ol><li>one</li><li><p>two</p><ul><li>inner</li></ul></li></ol>
+		<script>alert(1)</script>
+		tail</body></html>`
+
+	paragraphs, err := (&Importer{}).ParseHTML(content)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h2", Align: "center", Runs: []RichRun{{Text: "Centered", Attrs: none}}},
+		{Heading: "h4", Runs: []RichRun{{Text: "deep", Attrs: none}}},
+		{Align: "right", Runs: []RichRun{{Text: "right aligned", Attrs: none}}},
+		{Runs: []RichRun{{Text: "one", Attrs: none}}},
+		{Runs: []RichRun{{Text: "two", Attrs: none}}},
+		{},
+		{Runs: []RichRun{{Text: "four", Attrs: none}}},
+		{Heading: "code", Runs: []RichRun{{Text: "code line", Attrs: none}}},
+		{List: "indent1", Runs: []RichRun{{Text: "indented", Attrs: none}}},
+		{List: "number1", Start: 1, Runs: []RichRun{{Text: "one", Attrs: none}}},
+		{List: "number1", Start: 2, Runs: []RichRun{{Text: "two", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "inner", Attrs: none}}},
+		{Runs: []RichRun{{Text: "tail", Attrs: none}}},
+	}, paragraphs)
+}
+
+func TestParseHTML_Inline(t *testing.T) {
+	content := `<p><span class="color:red">red</span> <span data-font-size="13px">sized</span>` +
+		` <span style="font-weight: 700; font-family: 'Courier New', monospace; color: #0000ee">styled</span>` +
+		` <em>a<b>b</b></em>&nbsp;&nbsp;<code>x := 1</code></p>`
+
+	paragraphs, err := (&Importer{}).ParseHTML(content)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{{Runs: []RichRun{
+		{Text: "red", Attrs: map[string]string{"color": "red"}},
+		{Text: " ", Attrs: none},
+		{Text: "sized", Attrs: map[string]string{"font-size": "13px"}},
+		{Text: " ", Attrs: none},
+		{Text: "styled", Attrs: map[string]string{"bold": "true", "font-family": "courier", "color": "blue"}},
+		{Text: " ", Attrs: none},
+		{Text: "a", Attrs: map[string]string{"italic": "true"}},
+		{Text: "b", Attrs: map[string]string{"italic": "true", "bold": "true"}},
+		{Text: "  ", Attrs: none},
+		{Text: "x := 1", Attrs: map[string]string{"font-family": "monospace"}},
+	}}}, paragraphs)
+}
+
+func TestSetPadHTML_KeepsFormatting(t *testing.T) {
+	hook := hooks.NewHook()
+	pad := padModel.NewPad("sethtml", db.NewMemoryDataStore(), &hook)
+	text := "old\n"
+	require.NoError(t, pad.Init(&text, nil, nil))
+
+	require.NoError(t, (&Importer{}).SetPadHTML(&pad, "<h1>Title</h1><p>some <b>bold</b></p><ul><li>item</li></ul>", "a.html"))
+
+	assert.Equal(t, "*Title\nsome bold\n*item\n", pad.Text())
+	paragraphs, err := ReadPadRich(pad.AText, &pad.Pool)
+	require.NoError(t, err)
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h1", Runs: []RichRun{{Text: "Title"}}},
+		{Runs: []RichRun{{Text: "some "}, {Text: "bold", Attrs: map[string]string{"bold": "true"}}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "item"}}},
+	}, paragraphs)
+}
@@ -0,0 +1,651 @@
+package io
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	mdFence        = regexp.MustCompile("^ *(`{3,}|~{3,})")
+	mdATXHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdSetextH1     = regexp.MustCompile(`^ {0,3}=+[ \t]*$`)
+	mdSetextH2     = regexp.MustCompile(`^ {0,3}-+[ \t]*$`)
+	mdThematic     = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdListItem     = regexp.MustCompile(`^( *)([-+*]|\d{1,9}[.)])(?:([ \t]+)(.*))?$`)
+	mdTaskMarker   = regexp.MustCompile(`^\[([ xX])\][ \t]+`)
+	mdBlockQuote   = regexp.MustCompile(`^ {0,3}> ?`)
+	mdReference    = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*<?([^\s>]+)>?(?:[ \t]+(?:"[^"]*"|'[^']*'|\([^)]*\)))?[ \t]*$`)
+	mdAutolink     = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*|[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*)>`)
+	mdInlineHTML   = regexp.MustCompile(`^<(/?)([a-zA-Z]+)\s*/?>`)
+	mdEntity       = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	mdEscapable    = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	mdHTMLAttrs    = map[string]string{"b": "bold", "strong": "bold", "i": "italic", "em": "italic", "u": "underline", "ins": "underline", "s": "strikethrough", "del": "strikethrough", "strike": "strikethrough"}
+	mdHeadingLevel = []string{"", "h1", "h2", "h3", "h4", "h4", "h4"}
+)
+
+// mdListLevel is an open list of the block parser.
+type mdListLevel struct {
+	indent, contentCol int
+	ordered            bool
+	next               int
+}
+
+// mdParser splits Markdown into blocks. Paragraph lines are collected in
+// pending and converted to runs when the block ends.
+type mdParser struct {
+	refs    map[string]string
+	out     []RichParagraph
+	lists   []mdListLevel
+	pending []string
+	para    RichParagraph
+	open    bool
+	// blank is set after an empty line, which ends the paragraph but not the
+	// list.
+	blank bool
+}
+
+// ParseMarkdown converts CommonMark/GFM into pad paragraphs: ATX and setext
+// headings, emphasis, strikethrough, ordered, unordered and nested lists,
+// task lists, links and code. Code spans become monospace text and fenced
+// code blocks "code" headings. Task list items are bullets starting with ☐
+// or ☑, as pads have no checkbox attribute.
+//
+// Lines are not joined to a list item lazily: ExportMarkdown writes a
+// paragraph directly after the last item, and list levels are indented by
+// four spaces, which is read as nesting rather than as a code block.
+func (i *Importer) ParseMarkdown(content string) ([]RichParagraph, error) {
+	if !utf8.ValidString(content) {
+		return nil, errors.New("markdown is not valid UTF-8")
+	}
+	content = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(content)
+	lines := strings.Split(content, "\n")
+
+	p := &mdParser{refs: map[string]string{}}
+	lines = p.collectReferences(lines)
+	for n := 0; n < len(lines); n++ {
+		n = p.line(lines, n)
+	}
+	p.flush()
+	return p.out, nil
+}
+
+// collectReferences removes link reference definitions outside code blocks
+// and remembers their targets.
+func (p *mdParser) collectReferences(lines []string) []string {
+	var kept []string
+	fence := ""
+	for _, line := range lines {
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(line) == m[1] {
+				fence = ""
+			}
+		}
+		if fence == "" {
+			if m := mdReference.FindStringSubmatch(line); m != nil {
+				label := normalizeMarkdownLabel(m[1])
+				if _, ok := p.refs[label]; !ok {
+					p.refs[label] = unescapeMarkdown(m[2])
+				}
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+func normalizeMarkdownLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func expandIndent(line string) string {
+	var sb strings.Builder
+	col := 0
+	for idx, r := range line {
+		switch r {
+		case ' ':
+			sb.WriteByte(' ')
+			col++
+		case '\t':
+			n := 4 - col%4
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+		default:
+			sb.WriteString(line[idx:])
+			return sb.String()
+		}
+	}
+	return sb.String()
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// line handles lines[n] and returns the index of the last line it consumed.
+func (p *mdParser) line(lines []string, n int) int {
+	line := expandIndent(lines[n])
+	for mdBlockQuote.MatchString(line) {
+		line = expandIndent(mdBlockQuote.ReplaceAllString(line, ""))
+	}
+
+	if strings.TrimSpace(line) == "" {
+		p.flush()
+		p.blank = true
+		return n
+	}
+	indent := leadingSpaces(line)
+
+	if m := mdFence.FindStringSubmatch(line); m != nil {
+		p.flush()
+		p.lists = nil
+		fence := m[1]
+		for n++; n < len(lines); n++ {
+			code := expandIndent(lines[n])
+			if c := mdFence.FindStringSubmatch(code); c != nil && c[1][0] == fence[0] && len(c[1]) >= len(fence) && strings.TrimSpace(code) == c[1] {
+				break
+			}
+			code = code[min(indent, leadingSpaces(code)):]
+			para := RichParagraph{Heading: "code"}
+			para.addRun(code, map[string]string{})
+			p.out = append(p.out, para)
+		}
+		p.blank = false
+		return n
+	}
+
+	if m := mdATXHeading.FindStringSubmatch(line); m != nil {
+		p.flush()
+		p.lists = nil
+		p.startParagraph(RichParagraph{Heading: mdHeadingLevel[len(m[1])]}, m[2])
+		p.flush()
+		return n
+	}
+
+	if p.open && len(p.lists) == 0 && !p.blank {
+		if mdSetextH1.MatchString(line) {
+			p.para.Heading = "h1"
+			p.flush()
+			return n
+		}
+		if mdSetextH2.MatchString(line) {
+			p.para.Heading = "h2"
+			p.flush()
+			return n
+		}
+	}
+
+	if mdThematic.MatchString(line) {
+		p.flush()
+		p.lists = nil
+		p.blank = false
+		return n
+	}
+
+	if m := mdListItem.FindStringSubmatch(line); m != nil && (m[3] != "" || m[4] == "") {
+		p.listItem(m)
+		return n
+	}
+
+	// Continuation of a list item: indented past the item marker.
+	if len(p.lists) > 0 && indent >= p.lists[len(p.lists)-1].contentCol {
+		if p.open && !p.blank {
+			p.pending = append(p.pending, line)
+			return n
+		}
+		p.flush()
+		p.startParagraph(RichParagraph{List: "indent" + strconv.Itoa(len(p.lists))}, line)
+		return n
+	}
+
+	if len(p.lists) > 0 {
+		p.flush()
+		p.lists = nil
+	}
+	if p.open && !p.blank {
+		p.pending = append(p.pending, line)
+		return n
+	}
+	p.flush()
+	p.startParagraph(RichParagraph{}, line)
+	return n
+}
+
+// listItem starts a list item paragraph; m is a match of mdListItem.
+func (p *mdParser) listItem(m []string) {
+	p.flush()
+	indent, marker := len(m[1]), m[2]
+	spacing := len(m[3])
+	if spacing > 4 || spacing == 0 {
+		spacing = 1
+	}
+	contentCol := indent + len(marker) + spacing
+	ordered := marker[len(marker)-1] == '.' || marker[len(marker)-1] == ')'
+
+	for len(p.lists) > 0 && indent < p.lists[len(p.lists)-1].contentCol {
+		top := p.lists[len(p.lists)-1]
+		if indent >= top.indent {
+			// A sibling: keep the level, restarting it if the type changes.
+			p.lists = p.lists[:len(p.lists)-1]
+			if top.ordered == ordered {
+				top.indent, top.contentCol = indent, contentCol
+				p.lists = append(p.lists, top)
+				p.addListItem(m[4])
+				return
+			}
+			break
+		}
+		p.lists = p.lists[:len(p.lists)-1]
+	}
+	level := mdListLevel{indent: indent, contentCol: contentCol, ordered: ordered}
+	if ordered {
+		level.next, _ = strconv.Atoi(marker[:len(marker)-1])
+	}
+	p.lists = append(p.lists, level)
+	p.addListItem(m[4])
+}
+
+func (p *mdParser) addListItem(text string) {
+	top := &p.lists[len(p.lists)-1]
+	depth := strconv.Itoa(len(p.lists))
+	para := RichParagraph{List: "bullet" + depth}
+	if top.ordered {
+		para.List = "number" + depth
+		para.Start = top.next
+		top.next++
+	} else if task := mdTaskMarker.FindStringSubmatch(text); task != nil {
+		text = mdTaskMarker.ReplaceAllString(text, "")
+		if task[1] == " " {
+			text = "☐ " + text
+		} else {
+			text = "☑ " + text
+		}
+	}
+	p.startParagraph(para, text)
+}
+
+func (p *mdParser) startParagraph(para RichParagraph, text string) {
+	p.para = para
+	p.pending = []string{text}
+	p.open = true
+	p.blank = false
+}
+
+// flush converts the pending paragraph lines into runs. Lines ending in two
+// spaces or a backslash are hard breaks, other line ends become spaces.
+func (p *mdParser) flush() {
+	if !p.open {
+		return
+	}
+	var sb strings.Builder
+	for idx, line := range p.pending {
+		line = strings.TrimLeft(line, " \t")
+		if idx == len(p.pending)-1 {
+			sb.WriteString(strings.TrimRight(line, " \t"))
+			break
+		}
+		switch {
+		case strings.HasSuffix(line, "\\"):
+			sb.WriteString(strings.TrimSuffix(line, "\\"))
+			sb.WriteString("\n")
+		case strings.HasSuffix(line, "  "):
+			sb.WriteString(strings.TrimRight(line, " \t"))
+			sb.WriteString("\n")
+		default:
+			sb.WriteString(strings.TrimRight(line, " \t"))
+			sb.WriteString(" ")
+		}
+	}
+	for _, node := range p.inline(sb.String()) {
+		attrs := map[string]string{}
+		for key, value := range node.attrs {
+			attrs[key] = value
+		}
+		p.para.addRun(node.text, attrs)
+	}
+	p.out = append(p.out, p.para)
+	p.para, p.pending, p.open = RichParagraph{}, nil, false
+}
+
+// mdNode is a piece of inline text. Delimiter nodes hold the still
+// unmatched characters of an emphasis run; what remains of them after
+// matching is literal text.
+type mdNode struct {
+	text  string
+	attrs map[string]string
+	delim *mdDelim
+}
+
+type mdDelim struct {
+	// char is '*', '_', '~', 'u' for the "[]" underline of ExportMarkdown, or
+	// 'h' for an inline HTML tag.
+	char     byte
+	count    int
+	canOpen  bool
+	canClose bool
+	// attr is the attribute of an HTML tag.
+	attr string
+	// both is set for runs that can open and close.
+	both bool
+}
+
+func isMarkdownPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// inline parses the inline content of a block.
+func (p *mdParser) inline(text string) []mdNode {
+	var nodes []mdNode
+	var literal strings.Builder
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			nodes = append(nodes, mdNode{text: literal.String(), attrs: map[string]string{}})
+			literal.Reset()
+		}
+	}
+	runeBefore := func(pos int) rune {
+		if pos == 0 {
+			return ' '
+		}
+		r, _ := utf8.DecodeLastRuneInString(text[:pos])
+		return r
+	}
+	runeAfter := func(pos int) rune {
+		if pos >= len(text) {
+			return ' '
+		}
+		r, _ := utf8.DecodeRuneInString(text[pos:])
+		return r
+	}
+
+	for pos := 0; pos < len(text); {
+		ch := text[pos]
+		switch {
+		case ch == '\\' && pos+1 < len(text) && strings.IndexByte(mdEscapable, text[pos+1]) >= 0:
+			literal.WriteByte(text[pos+1])
+			pos += 2
+			continue
+		case ch == '`':
+			run := len(text[pos:]) - len(strings.TrimLeft(text[pos:], "`"))
+			if end := findCodeSpanEnd(text, pos+run, run); end >= 0 {
+				code := strings.ReplaceAll(text[pos+run:end], "\n", " ")
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+					code = code[1 : len(code)-1]
+				}
+				flushLiteral()
+				nodes = append(nodes, mdNode{text: code, attrs: map[string]string{"font-family": "monospace"}})
+				pos = end + run
+				continue
+			}
+			literal.WriteString(text[pos : pos+run])
+			pos += run
+			continue
+		case ch == '<':
+			if m := mdAutolink.FindStringSubmatch(text[pos:]); m != nil {
+				literal.WriteString(m[1])
+				pos += len(m[0])
+				continue
+			}
+			if m := mdInlineHTML.FindStringSubmatch(text[pos:]); m != nil {
+				tag := strings.ToLower(m[2])
+				if tag == "br" {
+					literal.WriteString("\n")
+					pos += len(m[0])
+					continue
+				}
+				if attr, ok := mdHTMLAttrs[tag]; ok {
+					flushLiteral()
+					closing := m[1] == "/"
+					nodes = append(nodes, mdNode{text: m[0], attrs: map[string]string{}, delim: &mdDelim{char: 'h', count: 1, canOpen: !closing, canClose: closing, attr: attr}})
+					pos += len(m[0])
+					continue
+				}
+			}
+		case ch == '&':
+			if m := mdEntity.FindString(text[pos:]); m != "" {
+				literal.WriteString(html.UnescapeString(m))
+				pos += len(m)
+				continue
+			}
+		case ch == '!' && pos+1 < len(text) && text[pos+1] == '[':
+			if label, _, end, ok := p.parseLink(text, pos+1); ok {
+				literal.WriteString(label)
+				pos = end
+				continue
+			}
+		case ch == '[':
+			if strings.HasPrefix(text[pos:], "[]") && !strings.HasPrefix(text[pos:], "[](") && !strings.HasPrefix(text[pos:], "[][") {
+				flushLiteral()
+				nodes = append(nodes, mdNode{text: "[]", attrs: map[string]string{}, delim: &mdDelim{char: 'u', count: 1, canOpen: true, canClose: true}})
+				pos += 2
+				continue
+			}
+			if label, target, end, ok := p.parseLink(text, pos); ok {
+				flushLiteral()
+				inner := p.inline(label)
+				nodes = append(nodes, inner...)
+				var plain strings.Builder
+				for _, node := range inner {
+					plain.WriteString(node.text)
+				}
+				if target != "" && !strings.Contains(plain.String(), target) {
+					nodes = append(nodes, mdNode{text: " (" + target + ")", attrs: map[string]string{}})
+				}
+				pos = end
+				continue
+			}
+		case ch == '*' || ch == '_' || ch == '~':
+			run := len(text[pos:]) - len(strings.TrimLeft(text[pos:], string(ch)))
+			before, after := runeBefore(pos), runeAfter(pos+run)
+			left := !unicode.IsSpace(after) && (!isMarkdownPunct(after) || unicode.IsSpace(before) || isMarkdownPunct(before))
+			right := !unicode.IsSpace(before) && (!isMarkdownPunct(before) || unicode.IsSpace(after) || isMarkdownPunct(after))
+			d := &mdDelim{char: ch, count: run, canOpen: left, canClose: right}
+			if ch == '_' {
+				d.canOpen = left && (!right || isMarkdownPunct(before))
+				d.canClose = right && (!left || isMarkdownPunct(after))
+			}
+			if ch == '~' && run > 2 {
+				d.canOpen, d.canClose = false, false
+			}
+			d.both = d.canOpen && d.canClose
+			flushLiteral()
+			nodes = append(nodes, mdNode{text: text[pos : pos+run], attrs: map[string]string{}, delim: d})
+			pos += run
+			continue
+		}
+		literal.WriteByte(ch)
+		pos++
+	}
+	flushLiteral()
+	processEmphasis(nodes)
+	return nodes
+}
+
+// findCodeSpanEnd returns the start of the backtick run of length run that
+// closes a code span opened before pos, or -1.
+func findCodeSpanEnd(text string, pos, run int) int {
+	for pos < len(text) {
+		idx := strings.IndexByte(text[pos:], '`')
+		if idx < 0 {
+			return -1
+		}
+		start := pos + idx
+		end := start
+		for end < len(text) && text[end] == '`' {
+			end++
+		}
+		if end-start == run {
+			return start
+		}
+		pos = end
+	}
+	return -1
+}
+
+// parseLink parses an inline link "[label](target)", a full reference link
+// "[label][ref]" or a shortcut reference "[label]" starting at the "[" at
+// pos. It returns the label, the target and the end of the link.
+func (p *mdParser) parseLink(text string, pos int) (string, string, int, bool) {
+	depth := 0
+	labelEnd := -1
+	for idx := pos; idx < len(text); idx++ {
+		switch text[idx] {
+		case '\\':
+			idx++
+		case '`':
+			// Brackets inside code spans do not count.
+			run := len(text[idx:]) - len(strings.TrimLeft(text[idx:], "`"))
+			if end := findCodeSpanEnd(text, idx+run, run); end >= 0 {
+				idx = end + run - 1
+			} else {
+				idx += run - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			labelEnd = idx
+			break
+		}
+	}
+	if labelEnd < 0 {
+		return "", "", 0, false
+	}
+	label := text[pos+1 : labelEnd]
+	rest := text[labelEnd+1:]
+
+	if strings.HasPrefix(rest, "(") {
+		closing := matchingParen(rest)
+		if closing > 0 {
+			dest := strings.TrimSpace(rest[1:closing])
+			if strings.HasPrefix(dest, "<") {
+				if end := strings.IndexByte(dest, '>'); end > 0 {
+					dest = dest[1:end]
+				}
+			} else if fields := strings.Fields(dest); len(fields) > 0 {
+				dest = fields[0]
+			}
+			return label, unescapeMarkdown(dest), labelEnd + 1 + closing + 1, true
+		}
+	}
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.IndexByte(rest, ']'); end > 0 {
+			ref := rest[1:end]
+			if ref == "" {
+				ref = label
+			}
+			if target, ok := p.refs[normalizeMarkdownLabel(ref)]; ok {
+				return label, target, labelEnd + 1 + end + 1, true
+			}
+		}
+	}
+	if target, ok := p.refs[normalizeMarkdownLabel(label)]; ok {
+		return label, target, labelEnd + 1, true
+	}
+	return "", "", 0, false
+}
+
+// matchingParen returns the index of the ")" closing the "(" at s[0].
+func matchingParen(s string) int {
+	depth := 0
+	for idx := 0; idx < len(s); idx++ {
+		switch s[idx] {
+		case '\\':
+			idx++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+	return -1
+}
+
+func unescapeMarkdown(s string) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] == '\\' && idx+1 < len(s) && strings.IndexByte(mdEscapable, s[idx+1]) >= 0 {
+			idx++
+		}
+		sb.WriteByte(s[idx])
+	}
+	return html.UnescapeString(sb.String())
+}
+
+// processEmphasis matches delimiter runs as described by CommonMark and the
+// GFM strikethrough extension, adding the attribute of each pair to the
+// nodes between opener and closer.
+func processEmphasis(nodes []mdNode) {
+	for closer := 0; closer < len(nodes); closer++ {
+		for {
+			cd := nodes[closer].delim
+			if cd == nil || !cd.canClose || cd.count == 0 {
+				break
+			}
+			opener := -1
+			for idx := closer - 1; idx >= 0; idx-- {
+				od := nodes[idx].delim
+				if od == nil || !od.canOpen || od.count == 0 || od.char != cd.char {
+					continue
+				}
+				if cd.char == '~' && od.count != cd.count {
+					continue
+				}
+				if cd.char == 'h' && od.attr != cd.attr {
+					continue
+				}
+				// The "rule of 3" for runs that can both open and close.
+				if (cd.char == '*' || cd.char == '_') && (od.both || cd.both) &&
+					(od.count+cd.count)%3 == 0 && !(od.count%3 == 0 && cd.count%3 == 0) {
+					continue
+				}
+				opener = idx
+				break
+			}
+			if opener < 0 {
+				break
+			}
+
+			od := nodes[opener].delim
+			use, attr := 1, "italic"
+			switch cd.char {
+			case '~':
+				use, attr = cd.count, "strikethrough"
+			case 'u':
+				attr = "underline"
+			case 'h':
+				attr = cd.attr
+			default:
+				if od.count >= 2 && cd.count >= 2 {
+					use, attr = 2, "bold"
+				}
+			}
+			for idx := opener + 1; idx < closer; idx++ {
+				nodes[idx].attrs[attr] = "true"
+				// Delimiters inside the pair can no longer match.
+				if d := nodes[idx].delim; d != nil && d.count > 0 {
+					d.canOpen, d.canClose = false, false
+				}
+			}
+			od.count -= use
+			cd.count -= use
+			nodes[opener].text = nodes[opener].text[:len(nodes[opener].text)-use]
+			nodes[closer].text = nodes[closer].text[use:]
+			if cd.char == 'h' || cd.char == 'u' {
+				nodes[opener].text, nodes[closer].text = "", ""
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/hooks/events"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMarkdown_InvalidUTF8(t *testing.T) {
+	_, err := (&Importer{}).ParseMarkdown("\xff\xfe")
+	assert.Error(t, err)
+}
+
+func TestParseMarkdown_RoundTripExport(t *testing.T) {
+	hook := hooks.NewHook()
+	// Mirrors ep_heading, which sets the heading for the Markdown export.
+	hook.EnqueueHook("getLineMarkdownForExport", func(ctx any) {
+		event := ctx.(*events.LineMarkdownForExportContext)
+		ops, err := changeset.DeserializeOps(*event.AttribLine)
+		if err != nil || len(*ops) == 0 {
+			return
+		}
+		event.Heading = changeset.FromString((*ops)[0].Attribs, event.Apool).Get("heading")
+	})
+	pad := padModel.NewPad("markdown", db.NewMemoryDataStore(), &hook)
+	text := "\n"
+	require.NoError(t, pad.Init(&text, nil, nil))
+
+	bold := map[string]string{"bold": "true"}
+	none := map[string]string{}
+	original := []RichParagraph{
+		{Heading: "h1", Runs: []RichRun{{Text: "Title", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "plain ", Attrs: none},
+			{Text: "bold", Attrs: bold},
+			{Text: " ", Attrs: none},
+			{Text: "both", Attrs: map[string]string{"bold": "true", "italic": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "under", Attrs: map[string]string{"underline": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "gone", Attrs: map[string]string{"strikethrough": "true"}},
+		}},
+		{Heading: "h3", Runs: []RichRun{{Text: "Lists", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "first", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "nested", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "second", Attrs: none}}},
+		{List: "number1", Start: 1, Runs: []RichRun{{Text: "one", Attrs: none}}},
+		{List: "number1", Start: 2, Runs: []RichRun{{Text: "two", Attrs: none}}},
+		{Runs: []RichRun{{Text: "see https://example.com/ for snake_case & more", Attrs: none}}},
+	}
+	require.NoError(t, SetPadRich(&pad, original, "a.markdown"))
+
+	exported := (&ExportMarkdown{Hooks: &hook}).getMarkdownFromAtext(&pad, pad.AText, pad.Id)
+	paragraphs, err := (&Importer{}).ParseMarkdown(exported)
+	require.NoError(t, err)
+	assert.Equal(t, original, paragraphs, exported)
+}
+
+func TestParseMarkdown_Blocks(t *testing.T) {
+	markdown := "Setext title\n" +
+		"============\n" +
+		"\n" +
+		"## ATX heading ##\n" +
+		"##### deep\n" +
+		"\n" +
+		"A soft\n" +
+		"break and a hard  \n" +
+		"break\\\n" +
+		"end\n" +
+		"\n" +
+		"> quoted *text*\n" +
+		"\n" +
+		"***\n" +
+		"\n" +
+		"```go\n" +
+		"if a < b {\n" +
+		"    return\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"3. three\n" +
+		"4. four\n" +
+		"   continued\n" +
+		"   - [ ] todo\n" +
+		"   - [x] done\n" +
+		"\n" +
+		"     next paragraph\n" +
+		"- other list\n" +
+		"after\n"
+
+	paragraphs, err := (&Importer{}).ParseMarkdown(markdown)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h1", Runs: []RichRun{{Text: "Setext title", Attrs: none}}},
+		{Heading: "h2", Runs: []RichRun{{Text: "ATX heading", Attrs: none}}},
+		{Heading: "h4", Runs: []RichRun{{Text: "deep", Attrs: none}}},
+		{Runs: []RichRun{{Text: "A soft break and a hard\nbreak\nend", Attrs: none}}},
+		{Runs: []RichRun{{Text: "quoted ", Attrs: none}, {Text: "text", Attrs: map[string]string{"italic": "true"}}}},
+		{Heading: "code", Runs: []RichRun{{Text: "if a < b {", Attrs: none}}},
+		{Heading: "code", Runs: []RichRun{{Text: "    return", Attrs: none}}},
+		{Heading: "code", Runs: []RichRun{{Text: "}", Attrs: none}}},
+		{List: "number1", Start: 3, Runs: []RichRun{{Text: "three", Attrs: none}}},
+		{List: "number1", Start: 4, Runs: []RichRun{{Text: "four continued", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "☐ todo", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "☑ done", Attrs: none}}},
+		{List: "indent2", Runs: []RichRun{{Text: "next paragraph", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "other list", Attrs: none}}},
+		{Runs: []RichRun{{Text: "after", Attrs: none}}},
+	}, paragraphs)
+}
+
+func TestParseMarkdown_Inline(t *testing.T) {
+	markdown := "Use `a*b*` and ***both*** or __strong__ _em_ snake_case_name ~~del~~ ~one~.\n" +
+		"\n" +
+		"[Etherpad](https://etherpad.org \"title\") [ref link][ep] [ep] <https://example.com> ![alt](x.png)\n" +
+		"\n" +
+		"\\*not em\\* &copy; <u>html</u> <del>old</del> 2 * 3 * 4\n" +
+		"\n" +
+		"[ep]: https://etherpad.org/docs\n"
+
+	paragraphs, err := (&Importer{}).ParseMarkdown(markdown)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Runs: []RichRun{
+			{Text: "Use ", Attrs: none},
+			{Text: "a*b*", Attrs: map[string]string{"font-family": "monospace"}},
+			{Text: " and ", Attrs: none},
+			{Text: "both", Attrs: map[string]string{"bold": "true", "italic": "true"}},
+			{Text: " or ", Attrs: none},
+			{Text: "strong", Attrs: map[string]string{"bold": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "em", Attrs: map[string]string{"italic": "true"}},
+			{Text: " snake_case_name ", Attrs: none},
+			{Text: "del", Attrs: map[string]string{"strikethrough": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "one", Attrs: map[string]string{"strikethrough": "true"}},
+			{Text: ".", Attrs: none},
+		}},
+		{Runs: []RichRun{{Text: "Etherpad (https://etherpad.org) ref link (https://etherpad.org/docs) ep (https://etherpad.org/docs) https://example.com alt", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "*not em* © ", Attrs: none},
+			{Text: "html", Attrs: map[string]string{"underline": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "old", Attrs: map[string]string{"strikethrough": "true"}},
+			{Text: " 2 * 3 * 4", Attrs: none},
+		}},
+	}, paragraphs)
+}
@@ -0,0 +1,509 @@
+package io
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// odtStyle is a style:style of content.xml or styles.xml.
+type odtStyle struct {
+	name        string
+	displayName string
+	parent      string
+	// automatic styles are the ad-hoc formatting of a single paragraph or
+	// span, common styles are the named ones such as "Heading 1".
+	automatic bool
+	run       runProps
+	align     string
+	listStyle string
+}
+
+type odtListLevel struct {
+	// kind is bullet, number or none.
+	kind  string
+	start int
+}
+
+// odtImport holds the styles needed to map content.xml onto pad paragraphs.
+type odtImport struct {
+	styles    map[string]odtStyle
+	lists     map[string]map[int]odtListLevel
+	fontFaces map[string]string
+}
+
+// odtList is an open text:list element.
+type odtList struct {
+	style string
+	// itemUsed is set once the current list item produced its numbered
+	// paragraph; further paragraphs of the item are indented continuations.
+	itemUsed bool
+	header   bool
+	start    int
+}
+
+// ParseOdt converts an ODT document into pad paragraphs. Automatic and
+// common styles of content.xml and styles.xml are resolved into character
+// formatting, headings, nested lists, alignment and colors.
+func (i *Importer) ParseOdt(content []byte) ([]RichParagraph, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ODT file: %w", err)
+	}
+	read := func(name string) ([]byte, error) {
+		for _, f := range reader.File {
+			if f.Name != name {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		return nil, nil
+	}
+
+	contentXML, err := read("content.xml")
+	if err != nil {
+		return nil, err
+	}
+	if contentXML == nil {
+		return nil, errors.New("no content.xml found in ODT")
+	}
+	imp := odtImport{
+		styles:    map[string]odtStyle{},
+		lists:     map[string]map[int]odtListLevel{},
+		fontFaces: map[string]string{},
+	}
+	if data, err := read("styles.xml"); err == nil && data != nil {
+		if err := imp.parseStyles(data); err != nil {
+			return nil, fmt.Errorf("invalid styles.xml: %w", err)
+		}
+	}
+	if err := imp.parseStyles(contentXML); err != nil {
+		return nil, fmt.Errorf("invalid content.xml: %w", err)
+	}
+	return imp.parseContent(contentXML)
+}
+
+func odtStyleKey(family, name string) string {
+	return family + ":" + name
+}
+
+// parseStyles collects font faces, styles and list styles. It is run on
+// styles.xml and then content.xml, whose automatic styles may refer to the
+// common styles of the former.
+func (o *odtImport) parseStyles(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		current     *odtStyle
+		family      string
+		listName    string
+		inAutomatic bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "automatic-styles":
+				inAutomatic = true
+			case "font-face":
+				name, _ := xmlAttr(t, "name")
+				if fam, ok := xmlAttr(t, "font-family"); ok {
+					o.fontFaces[name] = fam
+				}
+			case "style":
+				if t.Name.Space != "" && !strings.HasSuffix(t.Name.Space, ":style:1.0") {
+					continue
+				}
+				current = &odtStyle{automatic: inAutomatic}
+				current.name, _ = xmlAttr(t, "name")
+				family, _ = xmlAttr(t, "family")
+				current.displayName, _ = xmlAttr(t, "display-name")
+				current.parent, _ = xmlAttr(t, "parent-style-name")
+				current.listStyle, _ = xmlAttr(t, "list-style-name")
+			case "text-properties":
+				if current != nil {
+					o.applyTextProps(&current.run, t)
+				}
+			case "paragraph-properties":
+				if current != nil {
+					current.align, _ = xmlAttr(t, "text-align")
+				}
+			case "list-style":
+				listName, _ = xmlAttr(t, "name")
+				o.lists[listName] = map[int]odtListLevel{}
+			case "list-level-style-bullet", "list-level-style-image", "list-level-style-number":
+				if listName == "" {
+					continue
+				}
+				levelAttr, _ := xmlAttr(t, "level")
+				level, _ := strconv.Atoi(levelAttr)
+				lvl := odtListLevel{kind: "bullet", start: 1}
+				if t.Name.Local == "list-level-style-number" {
+					lvl.kind = "number"
+					if format, _ := xmlAttr(t, "num-format"); format == "" {
+						lvl.kind = "none"
+					}
+					if v, ok := xmlAttr(t, "start-value"); ok {
+						lvl.start, _ = strconv.Atoi(v)
+					}
+				}
+				o.lists[listName][level] = lvl
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "automatic-styles":
+				inAutomatic = false
+			case "style":
+				if current != nil {
+					o.styles[odtStyleKey(family, current.name)] = *current
+				}
+				current = nil
+			case "list-style":
+				listName = ""
+			}
+		}
+	}
+}
+
+func (o *odtImport) applyTextProps(props *runProps, se xml.StartElement) {
+	on, off := true, false
+	for _, a := range se.Attr {
+		switch a.Name.Local {
+		case "font-weight":
+			weight, err := strconv.Atoi(a.Value)
+			if a.Value == "bold" || (err == nil && weight >= 600) {
+				props.bold = &on
+			} else {
+				props.bold = &off
+			}
+		case "font-style":
+			if a.Value == "italic" || a.Value == "oblique" {
+				props.italic = &on
+			} else {
+				props.italic = &off
+			}
+		case "text-underline-style":
+			if a.Value == "none" {
+				props.underline = &off
+			} else {
+				props.underline = &on
+			}
+		case "text-line-through-style":
+			if a.Value == "none" {
+				props.strike = &off
+			} else {
+				props.strike = &on
+			}
+		case "color":
+			props.color = a.Value
+		case "font-size":
+			if pt, err := strconv.ParseFloat(strings.TrimSuffix(a.Value, "pt"), 64); err == nil && strings.HasSuffix(a.Value, "pt") {
+				props.halfPoints = int(pt * 2)
+			}
+		case "font-name":
+			if props.font == "" {
+				props.font = a.Value
+				if fam, ok := o.fontFaces[a.Value]; ok {
+					props.font = fam
+				}
+			}
+		case "font-family":
+			props.font = a.Value
+		}
+	}
+}
+
+// styleChain returns the style followed by its ancestors.
+func (o *odtImport) styleChain(family, name string) []odtStyle {
+	var chain []odtStyle
+	seen := map[string]bool{}
+	for name != "" && !seen[name] {
+		seen[name] = true
+		style, ok := o.styles[odtStyleKey(family, name)]
+		if !ok {
+			break
+		}
+		chain = append(chain, style)
+		name = style.parent
+	}
+	return chain
+}
+
+// textStyle resolves the character formatting of a text:span style.
+func (o *odtImport) textStyle(name string) runProps {
+	var props runProps
+	for _, style := range o.styleChain("text", name) {
+		props = props.over(style.run)
+	}
+	return props
+}
+
+// paragraphRun resolves the character formatting a paragraph style gives to
+// its text. Only automatic styles count: the bold and size of common
+// heading styles are implied by the heading attribute.
+func (o *odtImport) paragraphRun(name string) runProps {
+	var props runProps
+	for _, style := range o.styleChain("paragraph", name) {
+		if style.automatic {
+			props = props.over(style.run)
+		}
+	}
+	return props
+}
+
+var odtAlign = map[string]string{
+	"start":   "left",
+	"left":    "left",
+	"center":  "center",
+	"end":     "right",
+	"right":   "right",
+	"justify": "justify",
+}
+
+// odtSkipped are elements whose content is not part of the document text.
+var odtSkipped = map[string]bool{
+	"note":            true,
+	"annotation":      true,
+	"tracked-changes": true,
+	"sequence-decls":  true,
+	"forms":           true,
+}
+
+func (o *odtImport) parseContent(data []byte) ([]RichParagraph, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		paragraphs []RichParagraph
+		para       *RichParagraph
+		paraStyle  string
+		paraStack  []*RichParagraph
+		styleStack []string
+		runStack   []runProps
+		lists      []*odtList
+		counters   = map[int]int{}
+		inBody     bool
+		skipDepth  int
+		linkTarget string
+		linkText   strings.Builder
+		inLink     bool
+	)
+	top := func() runProps {
+		if len(runStack) == 0 {
+			return runProps{}
+		}
+		return runStack[len(runStack)-1]
+	}
+	addText := func(text string, props runProps) {
+		if para == nil {
+			return
+		}
+		if inLink {
+			linkText.WriteString(text)
+		}
+		para.addRun(text, props.attrs())
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid content.xml: %w", err)
+		}
+		if skipDepth > 0 {
+			switch tok.(type) {
+			case xml.StartElement:
+				skipDepth++
+			case xml.EndElement:
+				skipDepth--
+			}
+			continue
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "body":
+				inBody = true
+			case !inBody:
+			case odtSkipped[t.Name.Local]:
+				skipDepth = 1
+			case t.Name.Local == "list":
+				list := &odtList{}
+				list.style, _ = xmlAttr(t, "style-name")
+				if list.style == "" && len(lists) > 0 {
+					list.style = lists[len(lists)-1].style
+				}
+				if len(lists) == 0 {
+					_, continued := xmlAttr(t, "continue-list")
+					if v, _ := xmlAttr(t, "continue-numbering"); v != "true" && !continued {
+						counters = map[int]int{}
+					}
+				}
+				lists = append(lists, list)
+			case t.Name.Local == "list-item" || t.Name.Local == "list-header":
+				if n := len(lists); n > 0 {
+					list := lists[n-1]
+					list.itemUsed = false
+					list.header = t.Name.Local == "list-header"
+					list.start = 0
+					if v, ok := xmlAttr(t, "start-value"); ok {
+						list.start, _ = strconv.Atoi(v)
+					}
+				}
+			case t.Name.Local == "p" || t.Name.Local == "h":
+				if para != nil {
+					paraStack = append(paraStack, para)
+					styleStack = append(styleStack, paraStyle)
+				}
+				para = &RichParagraph{}
+				paraStyle, _ = xmlAttr(t, "style-name")
+				if t.Name.Local == "h" {
+					level := 1
+					if v, ok := xmlAttr(t, "outline-level"); ok {
+						level, _ = strconv.Atoi(v)
+					}
+					para.Heading = headingForOutlineLevel(max(level, 1))
+				}
+				runStack = append(runStack, o.paragraphRun(paraStyle))
+			case para == nil:
+			case t.Name.Local == "span":
+				name, _ := xmlAttr(t, "style-name")
+				runStack = append(runStack, o.textStyle(name).over(top()))
+			case t.Name.Local == "a":
+				inLink = true
+				linkText.Reset()
+				linkTarget, _ = xmlAttr(t, "href")
+			case t.Name.Local == "s":
+				count := 1
+				if v, ok := xmlAttr(t, "c"); ok {
+					count, _ = strconv.Atoi(v)
+				}
+				addText(strings.Repeat(" ", max(count, 1)), top())
+			case t.Name.Local == "tab":
+				addText("\t", top())
+			case t.Name.Local == "line-break":
+				addText("\n", runProps{})
+			}
+		case xml.CharData:
+			if para != nil {
+				addText(strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(string(t)), top())
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "body":
+				inBody = false
+			case "list":
+				if n := len(lists); n > 0 {
+					lists = lists[:n-1]
+				}
+			case "span":
+				if para != nil && len(runStack) > 1 {
+					runStack = runStack[:len(runStack)-1]
+				}
+			case "a":
+				if inLink && linkTarget != "" && para != nil && !strings.Contains(linkText.String(), linkTarget) {
+					para.addRun(" ("+linkTarget+")", map[string]string{})
+				}
+				inLink, linkTarget = false, ""
+			case "p", "h":
+				if para == nil {
+					continue
+				}
+				o.resolveParagraph(paraStyle, para, lists, counters)
+				paragraphs = append(paragraphs, *para)
+				para = nil
+				runStack = runStack[:len(runStack)-1]
+				if n := len(paraStack); n > 0 {
+					para, paraStyle = paraStack[n-1], styleStack[n-1]
+					paraStack, styleStack = paraStack[:n-1], styleStack[:n-1]
+				}
+			}
+		}
+	}
+	return paragraphs, nil
+}
+
+// resolveParagraph fills in the heading, alignment and list attributes of
+// para. counters holds the next number per level of the current top-level
+// list.
+func (o *odtImport) resolveParagraph(styleName string, para *RichParagraph, lists []*odtList, counters map[int]int) {
+	chain := o.styleChain("paragraph", styleName)
+	for _, style := range chain {
+		name := style.displayName
+		if name == "" {
+			name = strings.ReplaceAll(style.name, "_20_", " ")
+		}
+		if para.Heading == "" {
+			para.Heading = headingForStyleName(name)
+		}
+	}
+	listStyle := ""
+	for _, style := range chain {
+		// Like their font, the alignment of heading styles such as a centered
+		// Title is implied by the heading attribute.
+		if para.Align == "" && (style.automatic || para.Heading == "") {
+			para.Align = odtAlign[style.align]
+		}
+		if listStyle == "" {
+			listStyle = style.listStyle
+		}
+	}
+	if para.Heading != "" || len(lists) == 0 {
+		return
+	}
+
+	list := lists[len(lists)-1]
+	level := len(lists)
+	if list.style == "" {
+		list.style = listStyle
+	}
+	if list.itemUsed || list.header {
+		para.List = "indent" + strconv.Itoa(level)
+		return
+	}
+	list.itemUsed = true
+
+	lvl, ok := o.lists[list.style][level]
+	if !ok {
+		lvl = odtListLevel{kind: "bullet", start: 1}
+	}
+	switch lvl.kind {
+	case "none":
+		para.List = "indent" + strconv.Itoa(level)
+	case "number":
+		para.List = "number" + strconv.Itoa(level)
+		next, ok := counters[level]
+		if !ok {
+			next = lvl.start
+		}
+		if list.start > 0 {
+			next = list.start
+		}
+		para.Start = next
+		counters[level] = next + 1
+		// A new parent item restarts the numbering of deeper levels.
+		for deeper := range counters {
+			if deeper > level {
+				delete(counters, deeper)
+			}
+		}
+	default:
+		para.List = "bullet" + strconv.Itoa(level)
+	}
+}
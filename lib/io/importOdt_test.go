@@ -0,0 +1,119 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOdt_InvalidFile(t *testing.T) {
+	importer := &Importer{}
+
+	_, err := importer.ParseOdt([]byte("not a zip file"))
+	assert.Error(t, err)
+}
+
+func TestParseOdt_RoundTripExport(t *testing.T) {
+	exported, err := (&ExportOdt{}).generateOdt([]odtParagraph{
+		{heading: "h1", segments: []odtTextSegment{{text: "Title"}}},
+		{heading: "h3", segments: []odtTextSegment{{text: "Section"}}},
+		{segments: []odtTextSegment{{text: "plain "}, {text: "bold", bold: true}, {text: " "}, {text: "both", bold: true, italic: true}, {text: " under", underline: true}, {text: " gone", strikethrough: true}}},
+		{listType: "bullet", listLevel: 1, segments: []odtTextSegment{{text: "first"}}},
+		{listType: "bullet", listLevel: 1, segments: []odtTextSegment{{text: "second"}}},
+		{listType: "number", listLevel: 1, segments: []odtTextSegment{{text: "one"}}},
+		{listType: "number", listLevel: 1, segments: []odtTextSegment{{text: "two"}}},
+		{alignment: "center", segments: []odtTextSegment{{text: "centered"}}},
+		{alignment: "right", segments: []odtTextSegment{{text: "right", authorColor: "#ffc7c7"}}},
+	})
+	require.NoError(t, err)
+
+	paragraphs, err := (&Importer{}).ParseOdt(exported)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h1", Runs: []RichRun{{Text: "Title", Attrs: none}}},
+		{Heading: "h3", Runs: []RichRun{{Text: "Section", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "plain ", Attrs: none},
+			{Text: "bold", Attrs: map[string]string{"bold": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "both", Attrs: map[string]string{"bold": "true", "italic": "true"}},
+			{Text: " under", Attrs: map[string]string{"underline": "true"}},
+			{Text: " gone", Attrs: map[string]string{"strikethrough": "true"}},
+		}},
+		{List: "bullet1", Runs: []RichRun{{Text: "first", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "second", Attrs: none}}},
+		{List: "number1", Start: 1, Runs: []RichRun{{Text: "one", Attrs: none}}},
+		{List: "number1", Start: 2, Runs: []RichRun{{Text: "two", Attrs: none}}},
+		{Align: "center", Runs: []RichRun{{Text: "centered", Attrs: none}}},
+		{Align: "right", Runs: []RichRun{{Text: "right", Attrs: none}}},
+	}, paragraphs)
+}
+
+func TestParseOdt_AutomaticStylesAndNestedLists(t *testing.T) {
+	const ns = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" xmlns:xlink="http://www.w3.org/1999/xlink"`
+	odt := buildDocx(t, map[string]string{
+		"styles.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-styles ` + ns + `><office:styles>
+<style:style style:name="Heading_20_2" style:display-name="Heading 2" style:family="paragraph"><style:text-properties fo:font-weight="bold" fo:font-size="16pt"/></style:style>
+<style:style style:name="Emphasis" style:family="text"><style:text-properties fo:font-style="italic"/></style:style>
+</office:styles></office:document-styles>`,
+		"content.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content ` + ns + `>
+<office:font-face-decls><style:font-face style:name="Liberation Mono" svg:font-family="'Liberation Mono'" xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"/></office:font-face-decls>
+<office:automatic-styles>
+<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Heading_20_2"><style:paragraph-properties fo:text-align="end"/></style:style>
+<style:style style:name="P2" style:family="paragraph"><style:text-properties fo:font-weight="bold"/></style:style>
+<style:style style:name="T1" style:family="text" style:parent-style-name="Emphasis"><style:text-properties fo:color="#0000ee" fo:font-size="20pt" style:font-name="Liberation Mono"/></style:style>
+<style:style style:name="T2" style:family="text"><style:text-properties style:text-underline-style="none" fo:font-weight="normal"/></style:style>
+<text:list-style style:name="L1">
+  <text:list-level-style-number text:level="1" style:num-format="1" text:start-value="3"/>
+  <text:list-level-style-bullet text:level="2" text:bullet-char="•"/>
+  <text:list-level-style-number text:level="3" style:num-format=""/>
+</text:list-style>
+</office:automatic-styles>
+<office:body><office:text>
+<text:sequence-decls><text:sequence-decl text:name="Figure"/></text:sequence-decls>
+<text:p text:style-name="P1">Heading by style</text:p>
+<text:p text:style-name="P2">all bold <text:span text:style-name="T2">but this</text:span></text:p>
+<text:p>a<text:s text:c="3"/>b<text:tab/>c<text:line-break/>d <text:span text:style-name="T1">styled</text:span></text:p>
+<text:list text:style-name="L1">
+  <text:list-item><text:p>three</text:p>
+    <text:list>
+      <text:list-item><text:p>dot</text:p><text:p>more</text:p></text:list-item>
+      <text:list-item><text:list><text:list-item><text:p>plain</text:p></text:list-item></text:list></text:list-item>
+    </text:list>
+  </text:list-item>
+  <text:list-item><text:p>four</text:p></text:list-item>
+  <text:list-item text:start-value="9"><text:p>nine</text:p></text:list-item>
+</text:list>
+<text:list text:style-name="L1"><text:list-item><text:p>restart</text:p></text:list-item></text:list>
+<text:list text:style-name="L1" text:continue-numbering="true"><text:list-item><text:p>continued</text:p></text:list-item></text:list>
+<text:p>see <text:a xlink:href="https://example.com/">our site</text:a><text:note><text:note-body><text:p>footnote</text:p></text:note-body></text:note></text:p>
+</office:text></office:body></office:document-content>`,
+	})
+
+	paragraphs, err := (&Importer{}).ParseOdt(odt)
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h3", Align: "right", Runs: []RichRun{{Text: "Heading by style", Attrs: none}}},
+		{Runs: []RichRun{{Text: "all bold ", Attrs: map[string]string{"bold": "true"}}, {Text: "but this", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "a   b\tc\nd ", Attrs: none},
+			{Text: "styled", Attrs: map[string]string{"italic": "true", "color": "blue", "font-size": "20px", "font-family": "monospace"}},
+		}},
+		{List: "number1", Start: 3, Runs: []RichRun{{Text: "three", Attrs: none}}},
+		{List: "bullet2", Runs: []RichRun{{Text: "dot", Attrs: none}}},
+		{List: "indent2", Runs: []RichRun{{Text: "more", Attrs: none}}},
+		{List: "indent3", Runs: []RichRun{{Text: "plain", Attrs: none}}},
+		{List: "number1", Start: 4, Runs: []RichRun{{Text: "four", Attrs: none}}},
+		{List: "number1", Start: 9, Runs: []RichRun{{Text: "nine", Attrs: none}}},
+		{List: "number1", Start: 3, Runs: []RichRun{{Text: "restart", Attrs: none}}},
+		{List: "number1", Start: 4, Runs: []RichRun{{Text: "continued", Attrs: none}}},
+		{Runs: []RichRun{{Text: "see our site (https://example.com/)", Attrs: none}}},
+	}, paragraphs)
+}
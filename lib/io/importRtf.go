@@ -0,0 +1,659 @@
+package io
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfGroup is the state RTF saves on "{" and restores on "}".
+type rtfGroup struct {
+	bold, italic, underline, strike bool
+	// font and color index the font and color tables, -1 if unset.
+	font, color int
+	// halfPoints is the \fs font size, 0 if unset.
+	halfPoints int
+	// uc is the number of fallback characters following a \u escape.
+	uc int
+	// dest is the destination text is routed to: "" for the document,
+	// "skip" for ignored destinations, or a table being read.
+	dest string
+}
+
+// rtfPara holds the paragraph properties, which \pard resets.
+type rtfPara struct {
+	align       string
+	style       int
+	ls, ilvl    int
+	outline     int
+	indent      int
+	pnKind      string
+	pnLevel     int
+	pnStart     int
+	hasOutline  bool
+	cellPending bool
+}
+
+type rtfField struct {
+	depth int
+	inst  strings.Builder
+	text  strings.Builder
+}
+
+// rtfParser converts RTF into pad paragraphs.
+type rtfParser struct {
+	src []byte
+	pos int
+
+	group  rtfGroup
+	stack  []rtfGroup
+	para   rtfPara
+	cur    RichParagraph
+	result []RichParagraph
+
+	codepage *charmap.Charmap
+	// skip counts fallback characters still to drop after \u.
+	skip int
+	// starred is set by \* until the next control word.
+	starred bool
+	// highSurrogate is the first half of a \u surrogate pair.
+	highSurrogate rune
+
+	fonts       map[int]string
+	fontNum     int
+	fontName    strings.Builder
+	colors      []string
+	color       [3]int
+	colorSet    bool
+	styleNames  map[int]string
+	styleNum    int
+	styleName   strings.Builder
+	listLevels  map[int][]string
+	listStarts  map[int][]int
+	pendingKind []string
+	pendingFrom []int
+	overrides   map[int]int
+	lastListId  int
+	fields      []*rtfField
+
+	// counters hold the next number per list instance and level.
+	counters   map[int]map[int]int
+	pnCounters map[int]int
+}
+
+// rtfSkipped are destinations whose text is not part of the document.
+var rtfSkipped = map[string]bool{
+	"info": true, "pict": true, "object": true, "header": true, "headerl": true, "headerr": true,
+	"headerf": true, "footer": true, "footerl": true, "footerr": true, "footerf": true,
+	"footnote": true, "annotation": true, "xe": true, "tc": true, "txe": true, "listtext": true,
+	"pntext": true, "themedata": true, "colorschememapping": true, "latentstyles": true,
+	"datastore": true, "generator": true, "rsidtbl": true, "xmlnstbl": true, "mmathPr": true,
+	"shpinst": true, "nonshppict": true, "filetbl": true, "revtbl": true, "bkmkstart": true,
+	"bkmkend": true, "template": true, "userprops": true,
+}
+
+// rtfSymbols are control words that stand for a single character.
+var rtfSymbols = map[string]string{
+	"emdash":    "—",
+	"endash":    "–",
+	"bullet":    "•",
+	"lquote":    "‘",
+	"rquote":    "’",
+	"ldblquote": "“",
+	"rdblquote": "”",
+	"emspace":   " ",
+	"enspace":   " ",
+	"qmspace":   " ",
+	"tab":       "\t",
+	"line":      "\n",
+}
+
+var rtfCodepages = map[int]*charmap.Charmap{
+	437:   charmap.CodePage437,
+	850:   charmap.CodePage850,
+	852:   charmap.CodePage852,
+	866:   charmap.CodePage866,
+	874:   charmap.Windows874,
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	10000: charmap.Macintosh,
+}
+
+// ParseRtf converts an RTF document into pad paragraphs, keeping bold,
+// italic, underline, strikethrough, colors, font sizes and families,
+// alignment, headings from the stylesheet, lists (\ls and legacy \pn) and
+// hyperlink fields. \u Unicode escapes and \' code page escapes are
+// decoded.
+func (i *Importer) ParseRtf(content []byte) ([]RichParagraph, error) {
+	if !strings.HasPrefix(string(content), "{\\rtf") {
+		return nil, errors.New("invalid RTF file")
+	}
+	p := &rtfParser{
+		src:        content,
+		group:      rtfGroup{font: -1, color: -1, uc: 1},
+		codepage:   charmap.Windows1252,
+		fonts:      map[int]string{},
+		styleNames: map[int]string{},
+		listLevels: map[int][]string{},
+		listStarts: map[int][]int{},
+		overrides:  map[int]int{},
+		counters:   map[int]map[int]int{},
+		pnCounters: map[int]int{},
+	}
+	p.parse()
+	if len(p.cur.Runs) > 0 {
+		p.endParagraph()
+	}
+	return p.result, nil
+}
+
+func (p *rtfParser) parse() {
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		switch ch {
+		case '{':
+			p.pos++
+			p.stack = append(p.stack, p.group)
+			p.skip = 0
+		case '}':
+			p.pos++
+			p.closeGroup()
+		case '\\':
+			p.pos++
+			p.control()
+		case '\r', '\n':
+			p.pos++
+		default:
+			start := p.pos
+			for p.pos < len(p.src) && !strings.ContainsRune("{}\\\r\n", rune(p.src[p.pos])) {
+				p.pos++
+			}
+			p.text(p.decode(p.src[start:p.pos]))
+		}
+	}
+}
+
+func (p *rtfParser) closeGroup() {
+	switch p.group.dest {
+	case "stylesheet":
+		p.flushStyle()
+	case "fonttbl":
+		p.flushFont()
+	}
+	if n := len(p.fields); n > 0 && p.fields[n-1].depth == len(p.stack) {
+		p.endField(p.fields[n-1])
+		p.fields = p.fields[:n-1]
+	}
+	if n := len(p.stack); n > 0 {
+		p.group = p.stack[n-1]
+		p.stack = p.stack[:n-1]
+	}
+	p.skip = 0
+}
+
+func (p *rtfParser) decode(raw []byte) string {
+	var sb strings.Builder
+	for _, b := range raw {
+		if b < 0x80 {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteRune(p.codepage.DecodeByte(b))
+		}
+	}
+	return sb.String()
+}
+
+// control reads the control word or symbol after a backslash.
+func (p *rtfParser) control() {
+	if p.pos >= len(p.src) {
+		return
+	}
+	ch := p.src[p.pos]
+	if !isASCIILetter(ch) {
+		p.pos++
+		switch ch {
+		case '\'':
+			if p.pos+2 <= len(p.src) {
+				if b, err := strconv.ParseUint(string(p.src[p.pos:p.pos+2]), 16, 8); err == nil {
+					p.text(p.decode([]byte{byte(b)}))
+				}
+				p.pos += 2
+			}
+		case '*':
+			p.starred = true
+		case '~':
+			p.text(" ")
+		case '_':
+			p.text("-")
+		case '-':
+		case '\r', '\n':
+			p.word("par", 0, false)
+		default:
+			p.text(string(ch))
+		}
+		return
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isASCIILetter(p.src[p.pos]) {
+		p.pos++
+	}
+	name := string(p.src[start:p.pos])
+	numStart := p.pos
+	if p.pos < len(p.src) && p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	param, err := strconv.Atoi(string(p.src[numStart:p.pos]))
+	hasParam := err == nil
+	if p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+
+	if p.skip > 0 {
+		p.skip--
+		return
+	}
+	p.word(name, param, hasParam)
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// word handles one control word.
+func (p *rtfParser) word(name string, param int, hasParam bool) {
+	starred := p.starred
+	p.starred = false
+	on := !hasParam || param != 0
+
+	if p.group.dest == "skip" {
+		return
+	}
+	if rtfSkipped[name] {
+		p.group.dest = "skip"
+		return
+	}
+
+	switch name {
+	case "fonttbl", "colortbl", "stylesheet", "listtable", "listoverridetable":
+		p.group.dest = name
+		return
+	case "fldinst":
+		p.group.dest = name
+		return
+	case "pn":
+		p.group.dest = name
+		p.para.pnKind, p.para.pnLevel = "number", 1
+		return
+	case "field":
+		p.fields = append(p.fields, &rtfField{depth: len(p.stack)})
+		return
+	case "fldrslt":
+		p.group.dest = ""
+		return
+	}
+
+	switch p.group.dest {
+	case "fonttbl":
+		if name == "f" {
+			p.flushFont()
+			p.fontNum = param
+		} else if starred {
+			p.group.dest = "skip"
+		}
+		return
+	case "colortbl":
+		switch name {
+		case "red":
+			p.color[0], p.colorSet = param, true
+		case "green":
+			p.color[1], p.colorSet = param, true
+		case "blue":
+			p.color[2], p.colorSet = param, true
+		}
+		return
+	case "stylesheet":
+		switch name {
+		case "s", "cs", "ds", "ts":
+			p.flushStyle()
+			p.styleNum = param
+			if name != "s" {
+				p.styleNum = -1
+			}
+		default:
+			if starred {
+				p.group.dest = "skip"
+			}
+		}
+		return
+	case "listtable":
+		switch name {
+		case "list":
+			p.pendingKind, p.pendingFrom = nil, nil
+		case "listlevel":
+			p.pendingKind = append(p.pendingKind, "number")
+			p.pendingFrom = append(p.pendingFrom, 1)
+		case "levelnfc", "levelnfcn":
+			if n := len(p.pendingKind); n > 0 {
+				switch param {
+				case 23:
+					p.pendingKind[n-1] = "bullet"
+				case 255:
+					p.pendingKind[n-1] = "none"
+				default:
+					p.pendingKind[n-1] = "number"
+				}
+			}
+		case "levelstartat":
+			if n := len(p.pendingFrom); n > 0 {
+				p.pendingFrom[n-1] = param
+			}
+		case "listid":
+			p.listLevels[param], p.listStarts[param] = p.pendingKind, p.pendingFrom
+		}
+		return
+	case "listoverridetable":
+		switch name {
+		case "listid":
+			p.lastListId = param
+		case "ls":
+			p.overrides[param] = p.lastListId
+		}
+		return
+	case "pn":
+		switch {
+		case name == "pnlvlblt":
+			p.para.pnKind, p.para.pnLevel = "bullet", 1
+		case name == "pnlvlbody":
+			p.para.pnKind, p.para.pnLevel = "number", 1
+		case name == "pnlvlcont":
+			p.para.pnKind = "indent"
+		case name == "pnlvl":
+			p.para.pnLevel = max(param, 1)
+		case name == "pnstart":
+			p.para.pnStart = param
+		case starred:
+			p.group.dest = "skip"
+		}
+		return
+	case "fldinst":
+		return
+	}
+
+	if starred {
+		// Unknown optional destination.
+		p.group.dest = "skip"
+		return
+	}
+
+	if sym, ok := rtfSymbols[name]; ok {
+		p.text(sym)
+		return
+	}
+	switch name {
+	case "ansicpg":
+		if cm, ok := rtfCodepages[param]; ok {
+			p.codepage = cm
+		}
+	case "pc":
+		p.codepage = charmap.CodePage437
+	case "pca":
+		p.codepage = charmap.CodePage850
+	case "mac":
+		p.codepage = charmap.Macintosh
+	case "uc":
+		p.group.uc = param
+	case "u":
+		if param < 0 {
+			param += 65536
+		}
+		p.unicode(rune(param))
+		p.skip = p.group.uc
+	case "par", "sect":
+		p.endParagraph()
+	case "row":
+		p.endParagraph()
+	case "cell":
+		p.para.cellPending = true
+	case "pard":
+		p.para = rtfPara{}
+	case "plain":
+		p.group.bold, p.group.italic, p.group.underline, p.group.strike = false, false, false, false
+		p.group.font, p.group.color, p.group.halfPoints = -1, -1, 0
+	case "b":
+		p.group.bold = on
+	case "i":
+		p.group.italic = on
+	case "ul", "uld", "uldb", "uldash", "uldashd", "uldashdd", "ulhwave", "ulldash", "ulth", "ulthd",
+		"ulthdash", "ulthdashd", "ulthdashdd", "ulthldash", "ululdbwave", "ulw", "ulwave":
+		p.group.underline = on
+	case "ulnone":
+		p.group.underline = false
+	case "strike", "striked":
+		p.group.strike = on
+	case "f":
+		p.group.font = param
+	case "fs":
+		p.group.halfPoints = param
+	case "cf":
+		p.group.color = param
+	case "ql":
+		p.para.align = "left"
+	case "qc":
+		p.para.align = "center"
+	case "qr":
+		p.para.align = "right"
+	case "qj", "qd":
+		p.para.align = "justify"
+	case "s":
+		p.para.style = param
+	case "ls":
+		p.para.ls = param
+	case "ilvl":
+		p.para.ilvl = param
+	case "outlinelevel":
+		p.para.outline, p.para.hasOutline = param, true
+	case "li":
+		p.para.indent = param
+	}
+}
+
+// unicode emits a \u character, joining UTF-16 surrogate pairs.
+func (p *rtfParser) unicode(r rune) {
+	high := p.highSurrogate
+	p.highSurrogate = 0
+	switch {
+	case r >= 0xD800 && r <= 0xDBFF:
+		p.highSurrogate = r
+	case r >= 0xDC00 && r <= 0xDFFF:
+		if high != 0 {
+			p.text(string((high-0xD800)<<10 + (r - 0xDC00) + 0x10000))
+		}
+	default:
+		p.text(string(r))
+	}
+}
+
+// text routes text to the current destination.
+func (p *rtfParser) text(s string) {
+	if p.skip > 0 {
+		runes := []rune(s)
+		drop := min(p.skip, len(runes))
+		p.skip -= drop
+		s = string(runes[drop:])
+	}
+	if s == "" {
+		return
+	}
+	if n := len(p.fields); n > 0 && p.group.dest == "fldinst" {
+		p.fields[n-1].inst.WriteString(s)
+		return
+	}
+	switch p.group.dest {
+	case "":
+	case "fonttbl":
+		p.fontName.WriteString(s)
+		if strings.Contains(s, ";") {
+			p.flushFont()
+		}
+		return
+	case "colortbl":
+		for range strings.Count(s, ";") {
+			hex := ""
+			if p.colorSet {
+				hex = strings.ToUpper(strconv.FormatInt(int64(p.color[0]<<16|p.color[1]<<8|p.color[2]), 16))
+				hex = strings.Repeat("0", 6-len(hex)) + hex
+			}
+			p.colors = append(p.colors, hex)
+			p.color, p.colorSet = [3]int{}, false
+		}
+		return
+	case "stylesheet":
+		p.styleName.WriteString(s)
+		if strings.Contains(s, ";") {
+			p.flushStyle()
+		}
+		return
+	default:
+		return
+	}
+
+	if p.para.cellPending {
+		p.para.cellPending = false
+		p.cur.addRun("\t", map[string]string{})
+	}
+	if n := len(p.fields); n > 0 {
+		p.fields[n-1].text.WriteString(s)
+	}
+	p.cur.addRun(s, p.runProps().attrs())
+}
+
+func (p *rtfParser) runProps() runProps {
+	bold, italic, underline, strike := p.group.bold, p.group.italic, p.group.underline, p.group.strike
+	props := runProps{bold: &bold, italic: &italic, underline: &underline, strike: &strike}
+	if p.group.color >= 0 && p.group.color < len(p.colors) {
+		props.color = p.colors[p.group.color]
+	}
+	if p.group.font >= 0 {
+		props.font = p.fonts[p.group.font]
+	}
+	// \fs24 is the RTF default size and is left to the pad default.
+	if p.group.halfPoints != 24 {
+		props.halfPoints = p.group.halfPoints
+	}
+	return props
+}
+
+func (p *rtfParser) flushFont() {
+	name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(p.fontName.String()), ";"))
+	if name != "" {
+		p.fonts[p.fontNum] = name
+	}
+	p.fontName.Reset()
+}
+
+func (p *rtfParser) flushStyle() {
+	name := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(p.styleName.String()), ";"))
+	if name != "" && p.styleNum >= 0 {
+		p.styleNames[p.styleNum] = name
+	}
+	p.styleName.Reset()
+}
+
+func (p *rtfParser) endField(field *rtfField) {
+	inst := strings.Fields(field.inst.String())
+	if len(inst) < 2 || inst[0] != "HYPERLINK" {
+		return
+	}
+	// The target follows the field name; switches such as \l come later.
+	target := strings.Trim(inst[1], `"`)
+	if target != "" && !strings.HasPrefix(target, "\\") && !strings.Contains(field.text.String(), target) {
+		p.cur.addRun(" ("+target+")", map[string]string{})
+	}
+}
+
+// endParagraph resolves the paragraph properties and starts a new line.
+func (p *rtfParser) endParagraph() {
+	para := p.cur
+	para.Align = p.para.align
+	para.Heading = headingForStyleName(p.styleNames[p.para.style])
+	if para.Heading == "" && p.para.hasOutline && p.para.outline < 9 {
+		para.Heading = headingForOutlineLevel(p.para.outline + 1)
+	}
+
+	pnNumber := false
+	switch {
+	case para.Heading != "":
+	case p.para.ls > 0:
+		listId := p.overrides[p.para.ls]
+		level := p.para.ilvl + 1
+		kind, start := "number", 1
+		if kinds := p.listLevels[listId]; p.para.ilvl < len(kinds) {
+			kind, start = kinds[p.para.ilvl], p.listStarts[listId][p.para.ilvl]
+		}
+		switch kind {
+		case "bullet":
+			para.List = "bullet" + strconv.Itoa(level)
+		case "none":
+			para.List = "indent" + strconv.Itoa(level)
+		default:
+			para.List = "number" + strconv.Itoa(level)
+			levels := p.counters[p.para.ls]
+			if levels == nil {
+				levels = map[int]int{}
+				p.counters[p.para.ls] = levels
+			}
+			next, ok := levels[p.para.ilvl]
+			if !ok {
+				next = start
+			}
+			para.Start = next
+			levels[p.para.ilvl] = next + 1
+			// A new parent item restarts the numbering of deeper levels.
+			for deeper := range levels {
+				if deeper > p.para.ilvl {
+					delete(levels, deeper)
+				}
+			}
+		}
+	case p.para.pnKind != "":
+		level := strconv.Itoa(max(p.para.pnLevel, 1))
+		switch p.para.pnKind {
+		case "bullet":
+			para.List = "bullet" + level
+		case "indent":
+			para.List = "indent" + level
+		default:
+			para.List = "number" + level
+			pnNumber = true
+			next, ok := p.pnCounters[p.para.pnLevel]
+			if !ok {
+				next = max(p.para.pnStart, 1)
+			}
+			para.Start = next
+			p.pnCounters[p.para.pnLevel] = next + 1
+		}
+	case p.para.indent >= 360:
+		para.List = "indent" + strconv.Itoa(max(1, (p.para.indent+360)/720))
+	}
+	if !pnNumber {
+		// Legacy numbering continues only over consecutive paragraphs.
+		clear(p.pnCounters)
+	}
+
+	p.result = append(p.result, para)
+	p.cur = RichParagraph{}
+	p.para.cellPending = false
+}
@@ -0,0 +1,101 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRtf_InvalidFile(t *testing.T) {
+	importer := &Importer{}
+
+	_, err := importer.ParseRtf([]byte("not rtf"))
+	assert.Error(t, err)
+}
+
+func TestParseRtf_Formatting(t *testing.T) {
+	rtf := `{\rtf1\ansi\ansicpg1252\deff0
+{\fonttbl{\f0\fswiss Arial;}{\f1\fmodern\fcharset0 Courier New{\*\falt Courier};}}
+{\colortbl;\red255\green0\blue0;\red0\green0\blue0;}
+{\stylesheet{\s0 Normal;}{\s1\ql\outlinelevel0\b\fs32 heading 1;}{\s15 Title;}{\*\cs10 Default Paragraph Font;}}
+{\*\generator Writer;}{\info{\title Ignored}}
+{\header {\pard header text\par}}
+\pard\plain\s15\qc Doc title\par
+\pard\plain\s1 Intro\par
+\pard\plain Plain {\b bold} {\i\ul both}{\ulnone  }{\strike gone\strike0  back}\par
+\pard\qj {\cf1 red} {\cf2 black} {\f1\fs28 code} {\fs24 default}\par
+\pard\qr caf\'e9 \u8364? {\uc2\u8212\'97\'97} and \u-10179?\u-8704?\par
+\pard line\line break\tab tab\~nbsp\emdash\par
+\pard {\field{\*\fldinst HYPERLINK "https://example.com/"}{\fldrslt {\ul link}}} and {\field{\*\fldinst {HYPERLINK "https://example.com/"}}{\fldrslt https://example.com/}}\par
+\pard\intbl a\cell b\cell\row
+\pard\li1440 indented\par
+\pard last}`
+
+	paragraphs, err := (&Importer{}).ParseRtf([]byte(rtf))
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{Heading: "h1", Align: "center", Runs: []RichRun{{Text: "Doc title", Attrs: none}}},
+		{Heading: "h2", Runs: []RichRun{{Text: "Intro", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "Plain ", Attrs: none},
+			{Text: "bold", Attrs: map[string]string{"bold": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "both", Attrs: map[string]string{"italic": "true", "underline": "true"}},
+			{Text: " ", Attrs: none},
+			{Text: "gone", Attrs: map[string]string{"strikethrough": "true"}},
+			{Text: " back", Attrs: none},
+		}},
+		{Align: "justify", Runs: []RichRun{
+			{Text: "red", Attrs: map[string]string{"color": "red"}},
+			{Text: " black ", Attrs: none},
+			{Text: "code", Attrs: map[string]string{"font-family": "courier", "font-size": "14px"}},
+			{Text: " default", Attrs: none},
+		}},
+		{Align: "right", Runs: []RichRun{{Text: "café € — and 😀", Attrs: none}}},
+		{Runs: []RichRun{{Text: "line\nbreak\ttab nbsp—", Attrs: none}}},
+		{Runs: []RichRun{
+			{Text: "link", Attrs: map[string]string{"underline": "true"}},
+			{Text: " (https://example.com/) and https://example.com/", Attrs: none},
+		}},
+		{Runs: []RichRun{{Text: "a\tb", Attrs: none}}},
+		{List: "indent2", Runs: []RichRun{{Text: "indented", Attrs: none}}},
+		{Runs: []RichRun{{Text: "last", Attrs: none}}},
+	}, paragraphs)
+}
+
+func TestParseRtf_Lists(t *testing.T) {
+	rtf := `{\rtf1\ansi
+{\*\listtable
+{\list\listtemplateid1{\listlevel\levelnfc23\levelstartat1{\leveltext\'01\u-3913 ?;}{\levelnumbers;}}{\listlevel\levelnfc0\levelstartat1{\leveltext\'02\'01.;}{\levelnumbers\'01;}}\listid100}
+{\list\listtemplateid2{\listlevel\levelnfc0\levelstartat4{\leveltext\'02\'00.;}{\levelnumbers\'01;}}\listid200}}
+{\*\listoverridetable{\listoverride\listid100\listoverridecount0\ls1}{\listoverride\listid200\listoverridecount0\ls2}}
+\pard\ls1\ilvl0 {\listtext\'b7\tab}dot\par
+\pard\ls1\ilvl1 {\listtext 1.\tab}sub one\par
+\pard\ls1\ilvl1 {\listtext 2.\tab}sub two\par
+\pard\ls2 {\listtext 4.\tab}four\par
+\pard\ls2 {\listtext 5.\tab}five\par
+\pard{\*\pn\pnlvlblt{\pntxtb\'b7}}{\pntext\'b7\tab}legacy bullet\par
+\pard{\*\pn\pnlvlbody\pnstart7{\pntxta .}}{\pntext 7.\tab}seven\par
+\pard{\*\pn\pnlvlbody\pnstart7{\pntxta .}}{\pntext 8.\tab}eight\par
+\pard after\par
+}`
+
+	paragraphs, err := (&Importer{}).ParseRtf([]byte(rtf))
+	require.NoError(t, err)
+
+	none := map[string]string{}
+	assert.Equal(t, []RichParagraph{
+		{List: "bullet1", Runs: []RichRun{{Text: "dot", Attrs: none}}},
+		{List: "number2", Start: 1, Runs: []RichRun{{Text: "sub one", Attrs: none}}},
+		{List: "number2", Start: 2, Runs: []RichRun{{Text: "sub two", Attrs: none}}},
+		{List: "number1", Start: 4, Runs: []RichRun{{Text: "four", Attrs: none}}},
+		{List: "number1", Start: 5, Runs: []RichRun{{Text: "five", Attrs: none}}},
+		{List: "bullet1", Runs: []RichRun{{Text: "legacy bullet", Attrs: none}}},
+		{List: "number1", Start: 7, Runs: []RichRun{{Text: "seven", Attrs: none}}},
+		{List: "number1", Start: 8, Runs: []RichRun{{Text: "eight", Attrs: none}}},
+		{Runs: []RichRun{{Text: "after", Attrs: none}}},
+	}, paragraphs)
+}
@@ -249,24 +249,14 @@ func (i *Importer) SetPadRaw(padId string, content []byte, authorId string) erro
 	return nil
 }
 
-// SetPadHTML imports HTML content into a pad
-// Note: This currently imports only the text content. Full formatting support
-// would require complex changeset generation which is error-prone.
-// For full formatting preservation, export/import via .etherpad format is recommended.
+// SetPadHTML imports HTML content into a pad through SetPadRich, so the
+// formatting ParseHTML understands survives like in the other rich imports.
 func (i *Importer) SetPadHTML(pad *padModel.Pad, htmlContent string, authorId string) error {
-	// Parse HTML and extract text
-	text, err := i.htmlToText(htmlContent)
+	paragraphs, err := i.ParseHTML(htmlContent)
 	if err != nil {
 		return err
 	}
-
-	// If no content, just set empty
-	if text == "" || text == "\n" {
-		return pad.SetText("\n", &authorId)
-	}
-
-	// Set the plain text
-	return pad.SetText(text, &authorId)
+	return SetPadRich(pad, paragraphs, authorId)
 }
 
 // applyAttributeToRange applies an attribute to a range of text in the pad
@@ -0,0 +1,372 @@
+package io
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	padLib "github.com/ether/etherpad-go/lib/pad"
+	"github.com/ether/etherpad-go/lib/utils"
+)
+
+// RichRun is a piece of text sharing the same character attributes, e.g.
+// {"bold": "true", "color": "red"}.
+type RichRun struct {
+	Text  string
+	Attrs map[string]string
+}
+
+// RichParagraph is one pad line of a formatting-preserving import: its runs
+// plus the line attributes Etherpad keeps on the line marker.
+type RichParagraph struct {
+	Runs []RichRun
+	// List is the list attribute, e.g. "bullet1", "number2" or "indent1".
+	List string
+	// Start is the number of the item in a numbered list, 0 if unset.
+	Start int
+	// Heading is the ep_heading tag: h1..h4 or code.
+	Heading string
+	// Align is the ep_align value: left, center, justify or right.
+	Align string
+}
+
+// Text returns the plain text of the paragraph.
+func (p RichParagraph) Text() string {
+	var sb strings.Builder
+	for _, run := range p.Runs {
+		sb.WriteString(run.Text)
+	}
+	return sb.String()
+}
+
+func (p RichParagraph) lineAttrs() map[string]string {
+	attrs := make(map[string]string)
+	if p.List != "" {
+		attrs["list"] = p.List
+		if p.Start > 0 {
+			attrs["start"] = strconv.Itoa(p.Start)
+		}
+	}
+	if p.Heading != "" {
+		attrs["heading"] = p.Heading
+	}
+	if p.Align != "" {
+		attrs["align"] = p.Align
+	}
+	return attrs
+}
+
+// addRun appends text to the paragraph, merging it into the last run when
+// the attributes are equal.
+func (p *RichParagraph) addRun(text string, attrs map[string]string) {
+	if text == "" {
+		return
+	}
+	if n := len(p.Runs); n > 0 && sameAttrs(p.Runs[n-1].Attrs, attrs) {
+		p.Runs[n-1].Text += text
+		return
+	}
+	p.Runs = append(p.Runs, RichRun{Text: text, Attrs: attrs})
+}
+
+func sameAttrs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// runProps are the character properties of an imported run. nil means "not
+// set here", so style chains can be layered.
+type runProps struct {
+	bold, italic, underline, strike *bool
+	color, font                     string
+	halfPoints                      int
+}
+
+// over layers p on top of base.
+func (p runProps) over(base runProps) runProps {
+	if p.bold == nil {
+		p.bold = base.bold
+	}
+	if p.italic == nil {
+		p.italic = base.italic
+	}
+	if p.underline == nil {
+		p.underline = base.underline
+	}
+	if p.strike == nil {
+		p.strike = base.strike
+	}
+	if p.color == "" {
+		p.color = base.color
+	}
+	if p.font == "" {
+		p.font = base.font
+	}
+	if p.halfPoints == 0 {
+		p.halfPoints = base.halfPoints
+	}
+	return p
+}
+
+func (p runProps) attrs() map[string]string {
+	attrs := make(map[string]string)
+	for key, flag := range map[string]*bool{"bold": p.bold, "italic": p.italic, "underline": p.underline, "strikethrough": p.strike} {
+		if flag != nil && *flag {
+			attrs[key] = "true"
+		}
+	}
+	if p.color != "" && p.color != "auto" {
+		if c := nearestFontColor(p.color); c != "" {
+			attrs["color"] = c
+		}
+	}
+	if p.halfPoints > 0 {
+		attrs["font-size"] = nearestFontSize(float64(p.halfPoints) / 2)
+	}
+	if f := matchFontFamily(p.font); f != "" {
+		attrs["font-family"] = f
+	}
+	return attrs
+}
+
+// SetPadRich replaces the content of pad with paragraphs in a single
+// revision. Line attributes are stored on a "*" line marker like the editor
+// does, so lists, headings and alignment behave as if typed. A "\n" inside a
+// run starts a new line without line attributes.
+func SetPadRich(pad *padModel.Pad, paragraphs []RichParagraph, authorId string) error {
+	if authorId == "" {
+		authorId = padModel.SystemAuthorId
+	}
+	orig := pad.Text()
+	oldLen := utf8.RuneCountInString(orig)
+
+	assem := changeset.NewSmartOpAssembler()
+	assem.Append(changeset.Op{OpCode: "-", Chars: oldLen, Lines: strings.Count(orig, "\n")})
+
+	var bank strings.Builder
+	insert := func(text string, attrs map[string]string) {
+		text = *padModel.CleanText(text)
+		if text == "" {
+			return
+		}
+		attribMap := changeset.NewAttributeMap(&pad.Pool)
+		attribMap.Set("author", authorId)
+		for k, v := range attrs {
+			if v != "" {
+				attribMap.Set(k, v)
+			}
+		}
+		attribs := attribMap.String()
+		if last := utils.RuneLastIndex(text, "\n"); last >= 0 {
+			assem.Append(changeset.Op{OpCode: "+", Chars: last + 1, Lines: strings.Count(text, "\n"), Attribs: attribs})
+			if rest := utf8.RuneCountInString(text) - last - 1; rest > 0 {
+				assem.Append(changeset.Op{OpCode: "+", Chars: rest, Attribs: attribs})
+			}
+		} else {
+			assem.Append(changeset.Op{OpCode: "+", Chars: utf8.RuneCountInString(text), Attribs: attribs})
+		}
+		bank.WriteString(text)
+	}
+
+	for _, para := range paragraphs {
+		if line := para.lineAttrs(); len(line) > 0 {
+			line["lmkr"] = "1"
+			line["insertorder"] = "first"
+			insert("*", line)
+		}
+		for _, run := range para.Runs {
+			insert(run.Text, run.Attrs)
+		}
+		insert("\n", nil)
+	}
+	if len(paragraphs) == 0 {
+		insert("\n", nil)
+	}
+	assem.EndDocument()
+
+	cs := changeset.Pack(oldLen, utf8.RuneCountInString(bank.String()), assem.String(), bank.String())
+	_, err := pad.AppendRevision(cs, &authorId)
+	return err
+}
+
+// ReadPadRich is the inverse of SetPadRich: it splits atext into one
+// paragraph per line, taking the line attributes off the "*" line marker.
+// Runs keep their character attributes except the author.
+func ReadPadRich(atext apool.AText, pool *apool.APool) ([]RichParagraph, error) {
+	lines := padLib.SplitRemoveLastRune(atext.Text)
+	alines, err := changeset.SplitAttributionLines(atext.Attribs, atext.Text)
+	if err != nil {
+		return nil, err
+	}
+	paragraphs := make([]RichParagraph, 0, len(lines))
+	for n, line := range lines {
+		var para RichParagraph
+		if n >= len(alines) {
+			para.addRun(line, nil)
+			paragraphs = append(paragraphs, para)
+			continue
+		}
+		ops, err := changeset.DeserializeOps(alines[n])
+		if err != nil {
+			return nil, err
+		}
+		text := []rune(line)
+		pos := 0
+		for k, op := range *ops {
+			attribs := changeset.FromString(op.Attribs, pool).Iter()
+			chars := op.Chars
+			if op.Lines > 0 {
+				chars--
+			}
+			chars = min(chars, len(text)-pos)
+			if k == 0 && attribs["lmkr"] != "" && pos < len(text) && text[pos] == '*' {
+				para.List = attribs["list"]
+				para.Start, _ = strconv.Atoi(attribs["start"])
+				para.Heading = attribs["heading"]
+				para.Align = attribs["align"]
+				pos++
+				chars--
+			}
+			if chars <= 0 {
+				continue
+			}
+			var runAttrs map[string]string
+			for key, value := range attribs {
+				if key == "author" || value == "" {
+					continue
+				}
+				if runAttrs == nil {
+					runAttrs = make(map[string]string)
+				}
+				runAttrs[key] = value
+			}
+			para.addRun(string(text[pos:pos+chars]), runAttrs)
+			pos += chars
+		}
+		if pos < len(text) {
+			para.addRun(string(text[pos:]), nil)
+		}
+		paragraphs = append(paragraphs, para)
+	}
+	return paragraphs, nil
+}
+
+// headingForStyleName maps a word processor paragraph style name onto an
+// ep_heading tag. It inverts the DOCX and ODT exports, which write pad h1 as
+// Title and hN as heading N-1.
+func headingForStyleName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "title" {
+		return "h1"
+	}
+	if level, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil && strings.HasPrefix(name, "heading ") && level > 0 {
+		return headingForOutlineLevel(level)
+	}
+	return ""
+}
+
+// headingForOutlineLevel maps a document outline level (1 = top heading
+// below the title) onto an ep_heading tag.
+func headingForOutlineLevel(level int) string {
+	return "h" + strconv.Itoa(min(level+1, 4))
+}
+
+// fontColors are the colors offered by ep_font_color.
+var fontColors = []struct {
+	name    string
+	r, g, b float64
+}{
+	{"black", 0, 0, 0},
+	{"red", 255, 0, 0},
+	{"green", 0, 128, 0},
+	{"blue", 0, 0, 255},
+	{"yellow", 255, 255, 0},
+	{"orange", 255, 165, 0},
+}
+
+// nearestFontColor maps a "#rrggbb" or "rrggbb" color to the closest
+// ep_font_color value. Black is the editor default and yields "".
+func nearestFontColor(hex string) string {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return ""
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ""
+	}
+	r, g, b := float64(rgb>>16&0xff), float64(rgb>>8&0xff), float64(rgb&0xff)
+	best, bestDist := "", math.MaxFloat64
+	for _, c := range fontColors {
+		dist := (r-c.r)*(r-c.r) + (g-c.g)*(g-c.g) + (b-c.b)*(b-c.b)
+		if dist < bestDist {
+			best, bestDist = c.name, dist
+		}
+	}
+	if best == "black" {
+		return ""
+	}
+	return best
+}
+
+// fontSizes are the sizes offered by ep_font_size.
+var fontSizes = []float64{8, 9, 10, 11, 12, 13, 14, 16, 18, 20, 24, 28, 36, 48, 60}
+
+// nearestFontSize maps a size in points to the closest ep_font_size value.
+func nearestFontSize(points float64) string {
+	if points <= 0 {
+		return ""
+	}
+	best := fontSizes[0]
+	for _, s := range fontSizes {
+		if math.Abs(s-points) < math.Abs(best-points) {
+			best = s
+		}
+	}
+	return strconv.Itoa(int(best)) + "px"
+}
+
+// fontFamilies are the families offered by ep_font_family; fontFamilyAliases
+// maps common document fonts onto them.
+var (
+	fontFamilies      = []string{"arial", "avant-garde", "bookman", "calibri", "courier", "garamond", "helvetica", "monospace", "palatino", "times-new-roman"}
+	fontFamilyAliases = map[string]string{
+		"courier-new":            "courier",
+		"times":                  "times-new-roman",
+		"liberation-serif":       "times-new-roman",
+		"liberation-sans":        "arial",
+		"liberation-mono":        "monospace",
+		"consolas":               "monospace",
+		"menlo":                  "monospace",
+		"itc-avant-garde-gothic": "avant-garde",
+		"palatino-linotype":      "palatino",
+		"book-antiqua":           "palatino",
+		"bookman-old-style":      "bookman",
+	}
+)
+
+// matchFontFamily maps a font name to an ep_font_family value, or "".
+func matchFontFamily(name string) string {
+	key := strings.ToLower(strings.TrimSpace(strings.Trim(name, `"'`)))
+	key = strings.Join(strings.Fields(key), "-")
+	for _, f := range fontFamilies {
+		if key == f {
+			return f
+		}
+	}
+	return fontFamilyAliases[key]
+}
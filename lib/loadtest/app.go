@@ -1,10 +1,14 @@
 package loadtest
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +23,9 @@ import (
 )
 
 func RunFromCLI(logger *zap.SugaredLogger, args []string) {
+	if len(args) > 0 && args[0] == "scenario" {
+		os.Exit(RunScenarioFromCLI(logger, args[1:]))
+	}
 	host, authors, lurkers, duration, untilFail, err := parseRunArgs(args)
 	if err != nil {
 		return
@@ -43,6 +50,111 @@ func parseRunArgs(args []string) (string, int, int, int, bool, error) {
 	return *host, *authors, *lurkers, *duration, *untilFail, err
 }
 
+type scenarioArgs struct {
+	file     string
+	host     string
+	jsonPath string
+	csvPath  string
+}
+
+func parseScenarioArgs(args []string) (scenarioArgs, error) {
+	var parsed scenarioArgs
+	fs := flag.NewFlagSet("loadtest scenario", flag.ContinueOnError)
+	fs.StringVar(&parsed.host, "host", "", "The host to test (overrides the server of the scenario)")
+	fs.StringVar(&parsed.jsonPath, "json", "", "Write the JSON report to this file (- for stdout)")
+	fs.StringVar(&parsed.csvPath, "csv", "", "Write the CSV report to this file (- for stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: etherpad-go loadtest scenario [flags] <scenario.json>")
+		fs.PrintDefaults()
+	}
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		parsed.file = args[0]
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return parsed, err
+	}
+	if parsed.file == "" && fs.NArg() > 0 {
+		parsed.file = fs.Arg(0)
+	}
+	if parsed.file == "" {
+		return parsed, errors.New("missing scenario file")
+	}
+	return parsed, nil
+}
+
+// RunScenarioFromCLI runs a scenario file and returns the process exit code:
+// 0 when all thresholds held, 1 when they did not and 2 on usage errors.
+func RunScenarioFromCLI(logger *zap.SugaredLogger, args []string) int {
+	parsed, err := parseScenarioArgs(args)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 2
+	}
+	sc, err := LoadScenario(parsed.file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	host := parsed.host
+	if host == "" {
+		host = sc.Server
+	}
+	if host == "" {
+		host = "http://127.0.0.1:9001"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := RunScenario(ctx, logger, sc, host)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, out := range []struct {
+		path  string
+		write func(w io.Writer) error
+	}{{parsed.jsonPath, report.WriteJSON}, {parsed.csvPath, report.WriteCSV}} {
+		if err := writeReport(out.path, out.write); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	accept := report.Latencies[MetricCommitToAccept]
+	broadcast := report.Latencies[MetricCommitToNewChanges]
+	logger.Infof("Scenario %q finished: %d commits (p95 %.1fms), %d changes received (p95 %.1fms), %d errors",
+		report.Scenario, report.Counters.CommitsAccepted, accept.P95Ms, report.Counters.ChangesReceived, broadcast.P95Ms, report.ErrorCount)
+	for _, v := range report.Violations {
+		fmt.Fprintln(os.Stderr, "threshold exceeded:", v)
+	}
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
+
+func writeReport(path string, write func(w io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func RunMultiFromCLI(logger *zap.SugaredLogger, args []string) {
 	host, maxPads, err := parseMultiRunArgs(args)
 	if err != nil {
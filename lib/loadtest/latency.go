@@ -0,0 +1,209 @@
+package loadtest
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency metrics reported by scenario runs.
+const (
+	// commitToAccept: USER_CHANGES sent until ACCEPT_COMMIT arrived.
+	MetricCommitToAccept = "commitToAccept"
+	// commitToNewChanges: USER_CHANGES sent until NEW_CHANGES for that
+	// revision arrived at another client of the pad, once per receiver.
+	MetricCommitToNewChanges = "commitToNewChanges"
+	// sheetOpToAccept: SHEET_OP sent until ACCEPT_SHEET_OP arrived.
+	MetricSheetOpToAccept = "sheetOpToAccept"
+	// sheetOpToNewOp: SHEET_OP sent until NEW_SHEET_OP arrived at another
+	// client of the sheet.
+	MetricSheetOpToNewOp = "sheetOpToNewOp"
+)
+
+var latencyMetrics = []string{
+	MetricCommitToAccept,
+	MetricCommitToNewChanges,
+	MetricSheetOpToAccept,
+	MetricSheetOpToNewOp,
+}
+
+func isLatencyMetric(name string) bool {
+	for _, m := range latencyMetrics {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// histogramBounds are the upper bounds of the report histogram buckets; the
+// last bucket is unbounded.
+var histogramBounds = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// latencyRecorder keeps every sample so percentiles are exact.
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func (l *latencyRecorder) add(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	l.mu.Lock()
+	l.samples = append(l.samples, d)
+	l.mu.Unlock()
+}
+
+// Bucket is one histogram bucket: the number of samples at most Le (and
+// above the previous bucket). The last bucket has Le "+Inf".
+type Bucket struct {
+	Le    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+// LatencySummary holds percentiles in milliseconds plus a histogram.
+type LatencySummary struct {
+	Count     int      `json:"count"`
+	MinMs     float64  `json:"minMs"`
+	MeanMs    float64  `json:"meanMs"`
+	P50Ms     float64  `json:"p50Ms"`
+	P90Ms     float64  `json:"p90Ms"`
+	P95Ms     float64  `json:"p95Ms"`
+	P99Ms     float64  `json:"p99Ms"`
+	MaxMs     float64  `json:"maxMs"`
+	Histogram []Bucket `json:"histogram"`
+}
+
+func (l *latencyRecorder) summary() LatencySummary {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return summarize(sorted)
+}
+
+func summarize(sorted []time.Duration) LatencySummary {
+	s := LatencySummary{Count: len(sorted), Histogram: make([]Bucket, 0, len(histogramBounds)+1)}
+	counts := make([]int, len(histogramBounds)+1)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+		counts[sort.Search(len(histogramBounds), func(i int) bool { return histogramBounds[i] >= d })]++
+	}
+	for i, c := range counts {
+		le := "+Inf"
+		if i < len(histogramBounds) {
+			le = histogramBounds[i].String()
+		}
+		s.Histogram = append(s.Histogram, Bucket{Le: le, Count: c})
+	}
+	if len(sorted) == 0 {
+		return s
+	}
+	s.MinMs = millis(sorted[0])
+	s.MaxMs = millis(sorted[len(sorted)-1])
+	s.MeanMs = millis(total / time.Duration(len(sorted)))
+	s.P50Ms = millis(percentile(sorted, 50))
+	s.P90Ms = millis(percentile(sorted, 90))
+	s.P95Ms = millis(percentile(sorted, 95))
+	s.P99Ms = millis(percentile(sorted, 99))
+	return s
+}
+
+// percentile uses the nearest-rank method on sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func millis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// revKey identifies one revision of one document.
+type revKey struct {
+	doc string
+	rev int
+}
+
+type revRecord struct {
+	sent     time.Time
+	received []time.Time
+	created  time.Time
+}
+
+// revTracker matches revisions committed by one simulated client with their
+// arrival at the others. ACCEPT_COMMIT and the broadcast race, so arrivals
+// seen before the commit are parked until its send time is known.
+type revTracker struct {
+	mu       sync.Mutex
+	records  map[revKey]*revRecord
+	recorder *latencyRecorder
+	maxAge   time.Duration
+	pruned   time.Time
+}
+
+func newRevTracker(recorder *latencyRecorder, maxAge time.Duration) *revTracker {
+	return &revTracker{records: map[revKey]*revRecord{}, recorder: recorder, maxAge: maxAge}
+}
+
+func (t *revTracker) record(key revKey, now time.Time) *revRecord {
+	rec, ok := t.records[key]
+	if !ok {
+		rec = &revRecord{created: now}
+		t.records[key] = rec
+	}
+	if now.Sub(t.pruned) > t.maxAge {
+		for k, r := range t.records {
+			if now.Sub(r.created) > t.maxAge {
+				delete(t.records, k)
+			}
+		}
+		t.pruned = now
+	}
+	return rec
+}
+
+func (t *revTracker) committed(key revKey, sent time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec := t.record(key, time.Now())
+	rec.sent = sent
+	for _, at := range rec.received {
+		t.recorder.add(at.Sub(sent))
+	}
+	rec.received = nil
+}
+
+func (t *revTracker) received(key revKey, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec := t.record(key, time.Now())
+	if rec.sent.IsZero() {
+		rec.received = append(rec.received, at)
+		return
+	}
+	t.recorder.add(at.Sub(rec.sent))
+}
@@ -0,0 +1,152 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Counters are the totals of a scenario run.
+type Counters struct {
+	Joins            int64 `json:"joins"`
+	Leaves           int64 `json:"leaves"`
+	Reconnects       int64 `json:"reconnects"`
+	PeakConnected    int64 `json:"peakConnected"`
+	Keystrokes       int64 `json:"keystrokes"`
+	Pastes           int64 `json:"pastes"`
+	CommitsAccepted  int64 `json:"commitsAccepted"`
+	ChangesReceived  int64 `json:"changesReceived"`
+	ChatsSent        int64 `json:"chatsSent"`
+	ChatsReceived    int64 `json:"chatsReceived"`
+	SheetOpsSent     int64 `json:"sheetOpsSent"`
+	SheetOpsAccepted int64 `json:"sheetOpsAccepted"`
+	SheetOpsReceived int64 `json:"sheetOpsReceived"`
+}
+
+// Report is the machine readable result of a scenario run.
+type Report struct {
+	Scenario        string                    `json:"scenario"`
+	Server          string                    `json:"server"`
+	StartedAt       time.Time                 `json:"startedAt"`
+	DurationSeconds float64                   `json:"durationSeconds"`
+	Pads            int                       `json:"pads"`
+	Sheets          int                       `json:"sheets"`
+	Clients         int                       `json:"clients"`
+	Counters        Counters                  `json:"counters"`
+	Latencies       map[string]LatencySummary `json:"latencies"`
+	Errors          map[string]int64          `json:"errors"`
+	ErrorCount      int64                     `json:"errorCount"`
+	Passed          bool                      `json:"passed"`
+	Violations      []string                  `json:"violations,omitempty"`
+}
+
+// CheckThresholds records every threshold the report exceeds and sets
+// Passed accordingly.
+func (r *Report) CheckThresholds(t Thresholds) {
+	r.Violations = nil
+	if t.MaxErrors != nil && r.ErrorCount > *t.MaxErrors {
+		r.Violations = append(r.Violations, fmt.Sprintf("errors: %d > %d", r.ErrorCount, *t.MaxErrors))
+	}
+	metrics := make([]string, 0, len(t.Latency))
+	for metric := range t.Latency {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	for _, metric := range metrics {
+		limit := t.Latency[metric]
+		summary := r.Latencies[metric]
+		checks := []struct {
+			name  string
+			limit Duration
+			value float64
+		}{
+			{"p50", limit.P50, summary.P50Ms},
+			{"p95", limit.P95, summary.P95Ms},
+			{"p99", limit.P99, summary.P99Ms},
+			{"max", limit.Max, summary.MaxMs},
+		}
+		for _, c := range checks {
+			if c.limit > 0 && c.value > millis(time.Duration(c.limit)) {
+				r.Violations = append(r.Violations, fmt.Sprintf("%s %s: %.3fms > %s", metric, c.name, c.value, time.Duration(c.limit)))
+			}
+		}
+	}
+	r.Passed = len(r.Violations) == 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report as metric,value rows with dotted metric names
+// (e.g. latency.commitToAccept.p95_ms) so runs of two releases can be joined
+// on the first column.
+func (r *Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	row := func(name string, value string) {
+		_ = out.Write([]string{name, value})
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	row("metric", "value")
+	row("scenario", r.Scenario)
+	row("durationSeconds", num(r.DurationSeconds))
+	row("clients", strconv.Itoa(r.Clients))
+	counters := []struct {
+		name  string
+		value int64
+	}{
+		{"joins", r.Counters.Joins},
+		{"leaves", r.Counters.Leaves},
+		{"reconnects", r.Counters.Reconnects},
+		{"peakConnected", r.Counters.PeakConnected},
+		{"keystrokes", r.Counters.Keystrokes},
+		{"pastes", r.Counters.Pastes},
+		{"commitsAccepted", r.Counters.CommitsAccepted},
+		{"changesReceived", r.Counters.ChangesReceived},
+		{"chatsSent", r.Counters.ChatsSent},
+		{"chatsReceived", r.Counters.ChatsReceived},
+		{"sheetOpsSent", r.Counters.SheetOpsSent},
+		{"sheetOpsAccepted", r.Counters.SheetOpsAccepted},
+		{"sheetOpsReceived", r.Counters.SheetOpsReceived},
+	}
+	for _, c := range counters {
+		row("counters."+c.name, strconv.FormatInt(c.value, 10))
+	}
+	for _, metric := range latencyMetrics {
+		s, ok := r.Latencies[metric]
+		if !ok {
+			continue
+		}
+		prefix := "latency." + metric + "."
+		row(prefix+"count", strconv.Itoa(s.Count))
+		row(prefix+"min_ms", num(s.MinMs))
+		row(prefix+"mean_ms", num(s.MeanMs))
+		row(prefix+"p50_ms", num(s.P50Ms))
+		row(prefix+"p90_ms", num(s.P90Ms))
+		row(prefix+"p95_ms", num(s.P95Ms))
+		row(prefix+"p99_ms", num(s.P99Ms))
+		row(prefix+"max_ms", num(s.MaxMs))
+		for _, b := range s.Histogram {
+			row(prefix+"le_"+b.Le, strconv.Itoa(b.Count))
+		}
+	}
+	classes := make([]string, 0, len(r.Errors))
+	for class := range r.Errors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	row("errors.total", strconv.FormatInt(r.ErrorCount, 10))
+	for _, class := range classes {
+		row("errors."+class, strconv.FormatInt(r.Errors[class], 10))
+	}
+	row("passed", strconv.FormatBool(r.Passed))
+	out.Flush()
+	return out.Error()
+}
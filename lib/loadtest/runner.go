@@ -0,0 +1,481 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/client"
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/utils"
+	"go.uber.org/zap"
+)
+
+// Error classes of a scenario run. Server disconnects are reported as
+// serverDisconnect:<reason>.
+const (
+	ErrClassConnectFailed  = "connectFailed"
+	ErrClassConnectTimeout = "connectTimeout"
+	ErrClassAccessDenied   = "accessDenied"
+	ErrClassConnectionLost = "connectionLost"
+	ErrClassOutOfSync      = "outOfSync"
+	ErrClassCommitTimeout  = "commitTimeout"
+	ErrClassNotConnected   = "notConnected"
+	ErrClassEditFailed     = "editFailed"
+)
+
+// classifyError maps an error from the client SDK to a stable error class.
+func classifyError(err error) string {
+	var accessErr *client.AccessError
+	var disconnectErr *client.DisconnectError
+	switch {
+	case errors.As(err, &accessErr):
+		return ErrClassAccessDenied
+	case errors.As(err, &disconnectErr):
+		return "serverDisconnect:" + disconnectErr.Reason
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrClassConnectTimeout
+	case errors.Is(err, client.ErrNotConnected):
+		return ErrClassNotConnected
+	case strings.Contains(err.Error(), "missed revisions"),
+		strings.Contains(err.Error(), "missed sheet ops"),
+		strings.Contains(err.Error(), "replay out of order"):
+		return ErrClassOutOfSync
+	default:
+		return ErrClassConnectionLost
+	}
+}
+
+// scenarioRun holds the shared state of all simulated users of one run.
+type scenarioRun struct {
+	scenario      *Scenario
+	server        string
+	logger        *zap.SugaredLogger
+	commitTimeout time.Duration
+
+	joins, leaves, reconnects        atomic.Int64
+	connected, peakConnected         atomic.Int64
+	keystrokes, pastes               atomic.Int64
+	commitsAccepted, changesReceived atomic.Int64
+	chatsSent, chatsReceived         atomic.Int64
+	sheetOpsSent, sheetOpsAccepted   atomic.Int64
+	sheetOpsReceived                 atomic.Int64
+
+	errorsMu sync.Mutex
+	errors   map[string]int64
+
+	latencies                     map[string]*latencyRecorder
+	textRevisions, sheetRevisions *revTracker
+}
+
+func (r *scenarioRun) fail(class string) {
+	r.errorsMu.Lock()
+	r.errors[class]++
+	r.errorsMu.Unlock()
+}
+
+// RunScenario runs sc against server (the base URL of an Etherpad instance)
+// until the scenario duration is over or ctx is cancelled, and returns the
+// report with the scenario thresholds applied.
+func RunScenario(ctx context.Context, logger *zap.SugaredLogger, sc *Scenario, server string) (*Report, error) {
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server url %q", server)
+	}
+	r := &scenarioRun{
+		scenario:      sc,
+		server:        strings.TrimSuffix(server, "/"),
+		logger:        logger,
+		commitTimeout: time.Duration(sc.CommitTimeout),
+		errors:        map[string]int64{},
+		latencies:     map[string]*latencyRecorder{},
+	}
+	for _, m := range latencyMetrics {
+		r.latencies[m] = &latencyRecorder{}
+	}
+	r.textRevisions = newRevTracker(r.latencies[MetricCommitToNewChanges], 2*r.commitTimeout)
+	r.sheetRevisions = newRevTracker(r.latencies[MetricSheetOpToNewOp], 2*r.commitTimeout)
+
+	runId := utils.RandomString(6)
+	documents := map[string][]string{}
+	for i := 0; i < sc.Pads; i++ {
+		documents[DocumentPad] = append(documents[DocumentPad], fmt.Sprintf("%s/p/%s%s-%d", r.server, sc.PadPrefix, runId, i))
+	}
+	for i := 0; i < sc.Sheets; i++ {
+		documents[DocumentSheet] = append(documents[DocumentSheet], fmt.Sprintf("%s/s/%s%s-sheet-%d", r.server, sc.PadPrefix, runId, i))
+	}
+
+	total := 0
+	for _, p := range sc.Clients {
+		total += p.Count
+	}
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(sc.Duration))
+	defer cancel()
+	started := time.Now()
+	logger.Infof("Running scenario %q against %s: %d clients on %d pads and %d sheets for %s",
+		sc.Name, r.server, total, sc.Pads, sc.Sheets, time.Duration(sc.Duration))
+
+	var wg sync.WaitGroup
+	n := 0
+	assigned := map[string]int{}
+	for _, p := range sc.Clients {
+		for i := 0; i < p.Count; i++ {
+			docs := documents[p.Document]
+			docURL := docs[assigned[p.Document]%len(docs)]
+			assigned[p.Document]++
+			delay := time.Duration(sc.RampUp) * time.Duration(n) / time.Duration(total)
+			name := fmt.Sprintf("%s-%d", p.Name, i+1)
+			n++
+			wg.Add(1)
+			go func(p ClientProfile) {
+				defer wg.Done()
+				r.runUser(runCtx, p, name, docURL, delay)
+			}(p)
+		}
+	}
+	wg.Wait()
+
+	report := r.report(started, total)
+	report.CheckThresholds(sc.Thresholds)
+	return report, nil
+}
+
+// runUser keeps one simulated user busy for the whole run, joining again
+// after its session ended or its connection failed.
+func (r *scenarioRun) runUser(ctx context.Context, p ClientProfile, name, docURL string, delay time.Duration) {
+	if !sleepCtx(ctx, delay) {
+		return
+	}
+	for {
+		r.session(ctx, p, name, docURL)
+		pause := time.Duration(p.Pause)
+		if pause <= 0 {
+			pause = time.Second
+		}
+		if !sleepCtx(ctx, pause) {
+			return
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// session connects a fresh user and performs the profile's actions until
+// the session or the run ends or the connection is lost for good.
+func (r *scenarioRun) session(ctx context.Context, p ClientProfile, name, docURL string) {
+	c, err := client.New(docURL, client.Options{
+		Name:                 name,
+		Reconnect:            true,
+		MaxReconnectAttempts: 5,
+		Logger:               r.logger.Desugar().WithOptions(zap.IncreaseLevel(zap.ErrorLevel)).Sugar(),
+	})
+	if err != nil {
+		r.fail(ErrClassConnectFailed)
+		return
+	}
+	docKey := docURL
+	var lastCommit atomic.Int64
+	c.OnCommit(func(ev client.CommitEvent) {
+		r.commitsAccepted.Add(1)
+		lastCommit.Store(time.Now().UnixNano())
+		r.latencies[MetricCommitToAccept].add(ev.Latency)
+		r.textRevisions.committed(revKey{docKey, ev.Rev}, ev.Sent)
+	})
+	c.OnChange(func(ev client.ChangeEvent) {
+		r.changesReceived.Add(1)
+		r.textRevisions.received(revKey{docKey, ev.Rev}, ev.Received)
+	})
+	c.OnChat(func(client.ChatEvent) {
+		r.chatsReceived.Add(1)
+	})
+	c.OnSheetOp(func(ev client.SheetOpEvent) {
+		if ev.Local {
+			r.sheetOpsAccepted.Add(1)
+			lastCommit.Store(time.Now().UnixNano())
+			r.latencies[MetricSheetOpToAccept].add(ev.Latency)
+			r.sheetRevisions.committed(revKey{docKey, ev.Rev}, ev.Sent)
+			return
+		}
+		r.sheetOpsReceived.Add(1)
+		r.sheetRevisions.received(revKey{docKey, ev.Rev}, ev.Received)
+	})
+	c.OnReconnect(func(client.ReconnectEvent) {
+		r.reconnects.Add(1)
+	})
+	c.OnDisconnect(func(ev client.DisconnectEvent) {
+		if ev.Err != nil && !errors.Is(ev.Err, client.ErrClosed) {
+			r.fail(classifyError(ev.Err))
+		}
+	})
+
+	connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	err = c.Connect(connectCtx)
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			class := classifyError(err)
+			if class == ErrClassConnectionLost {
+				class = ErrClassConnectFailed
+			}
+			r.fail(class)
+		}
+		return
+	}
+	defer c.Close()
+	r.joins.Add(1)
+	connected := r.connected.Add(1)
+	for {
+		peak := r.peakConnected.Load()
+		if connected <= peak || r.peakConnected.CompareAndSwap(peak, connected) {
+			break
+		}
+	}
+	defer func() {
+		r.connected.Add(-1)
+		r.leaves.Add(1)
+	}()
+
+	var sessionEnd <-chan time.Time
+	if p.Session > 0 {
+		t := time.NewTimer(time.Duration(p.Session))
+		defer t.Stop()
+		sessionEnd = t.C
+	}
+	u := &simulatedUser{run: r, client: c, profile: p}
+	u.schedule(time.Now())
+	watchdog := time.NewTicker(time.Second)
+	defer watchdog.Stop()
+	var pendingSince time.Time
+	for {
+		timer := time.NewTimer(time.Until(u.nextAction()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-sessionEnd:
+			timer.Stop()
+			return
+		case <-c.Done():
+			timer.Stop()
+			return
+		case now := <-watchdog.C:
+			timer.Stop()
+			if !c.Pending() {
+				pendingSince = time.Time{}
+				continue
+			}
+			if pendingSince.IsZero() {
+				pendingSince = now
+			}
+			if last := time.Unix(0, lastCommit.Load()); last.After(pendingSince) {
+				pendingSince = last
+			}
+			if now.Sub(pendingSince) > r.commitTimeout {
+				r.fail(ErrClassCommitTimeout)
+				pendingSince = now
+			}
+		case now := <-timer.C:
+			u.act(now)
+		}
+	}
+}
+
+// simulatedUser performs the actions of one profile. Each action has its own
+// next due time; typing is steady while pastes, chat messages and sheet ops
+// arrive at random (exponential) intervals around their rate.
+type simulatedUser struct {
+	run     *scenarioRun
+	client  *client.Client
+	profile ClientProfile
+	cursor  int
+
+	nextType, nextPaste, nextChat, nextSheetOp time.Time
+}
+
+// never is far enough in the future to disable an action.
+var never = time.Now().Add(100 * 365 * 24 * time.Hour)
+
+func perSecond(rate float64, random bool) time.Duration {
+	mean := float64(time.Second) / rate
+	if random {
+		return time.Duration(rand.ExpFloat64() * mean)
+	}
+	return time.Duration(mean)
+}
+
+func (u *simulatedUser) schedule(now time.Time) {
+	p := u.profile
+	u.nextType, u.nextPaste, u.nextChat, u.nextSheetOp = never, never, never, never
+	if p.TypingCharsPerSecond > 0 {
+		u.nextType = now.Add(perSecond(p.TypingCharsPerSecond, true))
+	}
+	if p.PastesPerMinute > 0 {
+		u.nextPaste = now.Add(perSecond(p.PastesPerMinute/60, true))
+	}
+	if p.ChatMessagesPerMinute > 0 {
+		u.nextChat = now.Add(perSecond(p.ChatMessagesPerMinute/60, true))
+	}
+	if p.SheetOpsPerSecond > 0 {
+		u.nextSheetOp = now.Add(perSecond(p.SheetOpsPerSecond, true))
+	}
+}
+
+func (u *simulatedUser) nextAction() time.Time {
+	next := u.nextType
+	for _, t := range []time.Time{u.nextPaste, u.nextChat, u.nextSheetOp} {
+		if t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+func (u *simulatedUser) act(now time.Time) {
+	p := u.profile
+	if !now.Before(u.nextType) {
+		u.nextType = now.Add(perSecond(p.TypingCharsPerSecond, false))
+		u.insert(randomKeystroke())
+		u.run.keystrokes.Add(1)
+	}
+	if !now.Before(u.nextPaste) {
+		u.nextPaste = now.Add(perSecond(p.PastesPerMinute/60, true))
+		u.insert(randomParagraph(p.PasteSize))
+		u.run.pastes.Add(1)
+	}
+	if !now.Before(u.nextChat) {
+		u.nextChat = now.Add(perSecond(p.ChatMessagesPerMinute/60, true))
+		u.check(u.client.SendChat("load test " + utils.RandomString(8)))
+		u.run.chatsSent.Add(1)
+	}
+	if !now.Before(u.nextSheetOp) {
+		u.nextSheetOp = now.Add(perSecond(p.SheetOpsPerSecond, true))
+		u.setRandomCell()
+	}
+}
+
+// insert types text at the user's cursor, which now and then jumps to a
+// random position like a user moving around the document.
+func (u *simulatedUser) insert(text string) {
+	length := utf8.RuneCountInString(u.client.Text())
+	if rand.Intn(20) == 0 {
+		u.cursor = rand.Intn(max(length, 1))
+	}
+	// Stay in front of the final newline.
+	u.cursor = min(u.cursor, max(length-1, 0))
+	if u.check(u.client.Insert(u.cursor, text)) {
+		u.cursor += utf8.RuneCountInString(text)
+	}
+}
+
+func (u *simulatedUser) setRandomCell() {
+	wb := u.client.Workbook()
+	if wb == nil || len(wb.Sheets) == 0 {
+		return
+	}
+	raw := utils.RandomString(6)
+	op := sheet.Op{
+		Type:  sheet.OpSetCell,
+		Sheet: wb.Sheets[0].Id,
+		Row:   rand.Intn(100),
+		Col:   rand.Intn(26),
+		Raw:   &raw,
+	}
+	if u.check(u.client.SubmitSheetOp(op)) {
+		u.run.sheetOpsSent.Add(1)
+	}
+}
+
+func (u *simulatedUser) check(err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, client.ErrNotConnected) {
+		u.run.fail(ErrClassNotConnected)
+	} else {
+		u.run.fail(ErrClassEditFailed)
+	}
+	return false
+}
+
+func randomKeystroke() string {
+	switch n := rand.Intn(60); {
+	case n == 0:
+		return "\n"
+	case n < 10:
+		return " "
+	default:
+		return string(rune('a' + rand.Intn(26)))
+	}
+}
+
+func randomParagraph(size int) string {
+	var b strings.Builder
+	for b.Len() < size {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strings.ToLower(utils.RandomString(1 + rand.Intn(8))))
+	}
+	return b.String()[:size] + "\n"
+}
+
+func (r *scenarioRun) report(started time.Time, clients int) *Report {
+	report := &Report{
+		Scenario:        r.scenario.Name,
+		Server:          r.server,
+		StartedAt:       started.UTC(),
+		DurationSeconds: time.Since(started).Round(time.Millisecond).Seconds(),
+		Pads:            r.scenario.Pads,
+		Sheets:          r.scenario.Sheets,
+		Clients:         clients,
+		Counters: Counters{
+			Joins:            r.joins.Load(),
+			Leaves:           r.leaves.Load(),
+			Reconnects:       r.reconnects.Load(),
+			PeakConnected:    r.peakConnected.Load(),
+			Keystrokes:       r.keystrokes.Load(),
+			Pastes:           r.pastes.Load(),
+			CommitsAccepted:  r.commitsAccepted.Load(),
+			ChangesReceived:  r.changesReceived.Load(),
+			ChatsSent:        r.chatsSent.Load(),
+			ChatsReceived:    r.chatsReceived.Load(),
+			SheetOpsSent:     r.sheetOpsSent.Load(),
+			SheetOpsAccepted: r.sheetOpsAccepted.Load(),
+			SheetOpsReceived: r.sheetOpsReceived.Load(),
+		},
+		Latencies: map[string]LatencySummary{},
+		Errors:    map[string]int64{},
+	}
+	for name, rec := range r.latencies {
+		report.Latencies[name] = rec.summary()
+	}
+	r.errorsMu.Lock()
+	for class, count := range r.errors {
+		report.Errors[class] = count
+		report.ErrorCount += count
+	}
+	r.errorsMu.Unlock()
+	return report
+}
@@ -0,0 +1,201 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration that reads "1m30s" style strings or plain
+// numbers of seconds from JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// Document kinds a client profile can work on.
+const (
+	DocumentPad   = "pad"
+	DocumentSheet = "sheet"
+)
+
+// ClientProfile describes a group of identical simulated users. A profile
+// without any rate only watches the document (a lurker).
+type ClientProfile struct {
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Document string `json:"document,omitempty"`
+	// Text pads: single keystrokes, pasted blocks and chat messages.
+	TypingCharsPerSecond  float64 `json:"typingCharsPerSecond,omitempty"`
+	PastesPerMinute       float64 `json:"pastesPerMinute,omitempty"`
+	PasteSize             int     `json:"pasteSize,omitempty"`
+	ChatMessagesPerMinute float64 `json:"chatMessagesPerMinute,omitempty"`
+	// Sheets: setCell ops on the first sheet.
+	SheetOpsPerSecond float64 `json:"sheetOpsPerSecond,omitempty"`
+	// Session makes users leave after this long and join again as a new
+	// user after Pause. Zero keeps them connected for the whole run.
+	Session Duration `json:"session,omitempty"`
+	Pause   Duration `json:"pause,omitempty"`
+}
+
+// LatencyThreshold caps percentiles of one latency metric.
+type LatencyThreshold struct {
+	P50 Duration `json:"p50,omitempty"`
+	P95 Duration `json:"p95,omitempty"`
+	P99 Duration `json:"p99,omitempty"`
+	Max Duration `json:"max,omitempty"`
+}
+
+// Thresholds turn a report into pass/fail, e.g. for CI.
+type Thresholds struct {
+	MaxErrors *int64                      `json:"maxErrors,omitempty"`
+	Latency   map[string]LatencyThreshold `json:"latency,omitempty"`
+}
+
+// Scenario is a load test described in a JSON file.
+type Scenario struct {
+	Name     string   `json:"name"`
+	Server   string   `json:"server,omitempty"`
+	Duration Duration `json:"duration"`
+	RampUp   Duration `json:"rampUp,omitempty"`
+	// Pads and Sheets are the number of documents the pad and sheet
+	// profiles are spread over.
+	Pads      int    `json:"pads,omitempty"`
+	Sheets    int    `json:"sheets,omitempty"`
+	PadPrefix string `json:"padPrefix,omitempty"`
+	// CommitTimeout is how long local edits may stay unacknowledged before
+	// it is counted as a commitTimeout error.
+	CommitTimeout Duration        `json:"commitTimeout,omitempty"`
+	Clients       []ClientProfile `json:"clients"`
+	Thresholds    Thresholds      `json:"thresholds,omitempty"`
+}
+
+// LoadScenario reads, defaults and validates a scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseScenario(data)
+}
+
+func parseScenario(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	sc.applyDefaults()
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+func (s *Scenario) applyDefaults() {
+	if s.Name == "" {
+		s.Name = "scenario"
+	}
+	if s.PadPrefix == "" {
+		s.PadPrefix = "loadtest-"
+	}
+	if s.CommitTimeout == 0 {
+		s.CommitTimeout = Duration(10 * time.Second)
+	}
+	for i := range s.Clients {
+		p := &s.Clients[i]
+		if p.Document == "" {
+			p.Document = DocumentPad
+		}
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("%s-%d", p.Document, i+1)
+		}
+		if p.PastesPerMinute > 0 && p.PasteSize == 0 {
+			p.PasteSize = 200
+		}
+		if p.Session > 0 && p.Pause == 0 {
+			p.Pause = Duration(time.Second)
+		}
+	}
+	if s.Pads == 0 && s.usesDocument(DocumentPad) {
+		s.Pads = 1
+	}
+	if s.Sheets == 0 && s.usesDocument(DocumentSheet) {
+		s.Sheets = 1
+	}
+}
+
+func (s *Scenario) usesDocument(kind string) bool {
+	for _, p := range s.Clients {
+		if p.Document == kind && p.Count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports the first problem of the scenario.
+func (s *Scenario) Validate() error {
+	if s.Duration <= 0 {
+		return errors.New("scenario: duration must be positive")
+	}
+	if s.RampUp < 0 || s.RampUp > s.Duration {
+		return errors.New("scenario: rampUp must be between 0 and duration")
+	}
+	if s.Pads < 0 || s.Sheets < 0 {
+		return errors.New("scenario: pads and sheets must not be negative")
+	}
+	if len(s.Clients) == 0 {
+		return errors.New("scenario: no clients")
+	}
+	for _, p := range s.Clients {
+		if p.Count <= 0 {
+			return fmt.Errorf("scenario: client %q needs a positive count", p.Name)
+		}
+		if p.TypingCharsPerSecond < 0 || p.PastesPerMinute < 0 || p.ChatMessagesPerMinute < 0 || p.SheetOpsPerSecond < 0 || p.PasteSize < 0 {
+			return fmt.Errorf("scenario: client %q has a negative rate", p.Name)
+		}
+		if p.Session < 0 || p.Pause < 0 {
+			return fmt.Errorf("scenario: client %q has a negative session or pause", p.Name)
+		}
+		switch p.Document {
+		case DocumentPad:
+			if p.SheetOpsPerSecond > 0 {
+				return fmt.Errorf("scenario: client %q works on pads but sets sheetOpsPerSecond", p.Name)
+			}
+		case DocumentSheet:
+			if p.TypingCharsPerSecond > 0 || p.PastesPerMinute > 0 || p.ChatMessagesPerMinute > 0 {
+				return fmt.Errorf("scenario: client %q works on sheets but sets text or chat rates", p.Name)
+			}
+		default:
+			return fmt.Errorf("scenario: client %q has unknown document %q", p.Name, p.Document)
+		}
+	}
+	for metric := range s.Thresholds.Latency {
+		if !isLatencyMetric(metric) {
+			return fmt.Errorf("scenario: unknown latency metric %q in thresholds", metric)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/client"
+)
+
+func TestParseScenario(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{name: "minimal", json: `{"duration":"1m","clients":[{"count":2,"typingCharsPerSecond":4}]}`},
+		{name: "seconds as number", json: `{"duration":30,"clients":[{"count":1}]}`},
+		{name: "no duration", json: `{"clients":[{"count":1}]}`, wantErr: "duration"},
+		{name: "bad duration", json: `{"duration":"soon","clients":[{"count":1}]}`, wantErr: "invalid"},
+		{name: "ramp up too long", json: `{"duration":"10s","rampUp":"20s","clients":[{"count":1}]}`, wantErr: "rampUp"},
+		{name: "no clients", json: `{"duration":"10s"}`, wantErr: "no clients"},
+		{name: "zero count", json: `{"duration":"10s","clients":[{"name":"x","count":0}]}`, wantErr: "positive count"},
+		{name: "negative rate", json: `{"duration":"10s","clients":[{"count":1,"pastesPerMinute":-1}]}`, wantErr: "negative rate"},
+		{name: "unknown document", json: `{"duration":"10s","clients":[{"count":1,"document":"slides"}]}`, wantErr: "unknown document"},
+		{name: "typing on sheet", json: `{"duration":"10s","clients":[{"count":1,"document":"sheet","typingCharsPerSecond":1}]}`, wantErr: "sheets"},
+		{name: "sheet ops on pad", json: `{"duration":"10s","clients":[{"count":1,"sheetOpsPerSecond":1}]}`, wantErr: "pads"},
+		{name: "unknown threshold", json: `{"duration":"10s","clients":[{"count":1}],"thresholds":{"latency":{"typing":{"p95":"1s"}}}}`, wantErr: "unknown latency metric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseScenario([]byte(tt.json))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseScenarioDefaults(t *testing.T) {
+	sc, err := parseScenario([]byte(`{
+		"duration": "1m",
+		"clients": [
+			{"count": 2, "pastesPerMinute": 2, "session": "10s"},
+			{"count": 1, "document": "sheet", "sheetOpsPerSecond": 1}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Pads != 1 || sc.Sheets != 1 {
+		t.Errorf("pads, sheets = %d, %d, want 1, 1", sc.Pads, sc.Sheets)
+	}
+	if sc.CommitTimeout != Duration(10*time.Second) || sc.PadPrefix != "loadtest-" {
+		t.Errorf("commitTimeout %v, padPrefix %q", time.Duration(sc.CommitTimeout), sc.PadPrefix)
+	}
+	p := sc.Clients[0]
+	if p.Document != DocumentPad || p.Name != "pad-1" || p.PasteSize != 200 || p.Pause != Duration(time.Second) {
+		t.Errorf("pad profile defaults = %+v", p)
+	}
+	if sc.Clients[1].Name != "sheet-2" {
+		t.Errorf("sheet profile name = %q", sc.Clients[1].Name)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var rec latencyRecorder
+	for i := 100; i >= 1; i-- {
+		rec.add(time.Duration(i) * time.Millisecond)
+	}
+	s := rec.summary()
+	if s.Count != 100 || s.MinMs != 1 || s.MaxMs != 100 || s.P50Ms != 50 || s.P90Ms != 90 || s.P95Ms != 95 || s.P99Ms != 99 {
+		t.Fatalf("summary = %+v", s)
+	}
+	if s.MeanMs != 50.5 {
+		t.Errorf("mean = %v, want 50.5", s.MeanMs)
+	}
+	total := 0
+	for _, b := range s.Histogram {
+		total += b.Count
+	}
+	if total != 100 || s.Histogram[0] != (Bucket{Le: "1ms", Count: 1}) || s.Histogram[len(s.Histogram)-1].Le != "+Inf" {
+		t.Errorf("histogram = %+v", s.Histogram)
+	}
+	if empty := (&latencyRecorder{}).summary(); empty.Count != 0 || empty.P99Ms != 0 {
+		t.Errorf("empty summary = %+v", empty)
+	}
+}
+
+func TestRevTracker(t *testing.T) {
+	var rec latencyRecorder
+	tracker := newRevTracker(&rec, time.Minute)
+	sent := time.Now()
+	key := revKey{"pad", 3}
+
+	// One receiver sees the broadcast before the author's ACCEPT_COMMIT.
+	tracker.received(key, sent.Add(5*time.Millisecond))
+	tracker.committed(key, sent)
+	tracker.received(key, sent.Add(7*time.Millisecond))
+	// Revisions by clients outside the run are never matched.
+	tracker.received(revKey{"pad", 4}, sent)
+
+	s := rec.summary()
+	if s.Count != 2 || s.MinMs != 5 || s.MaxMs != 7 {
+		t.Fatalf("summary = %+v", s)
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	maxErrors := int64(0)
+	report := &Report{
+		ErrorCount: 2,
+		Latencies:  map[string]LatencySummary{MetricCommitToAccept: {P95Ms: 120, P99Ms: 300}},
+	}
+	report.CheckThresholds(Thresholds{
+		MaxErrors: &maxErrors,
+		Latency: map[string]LatencyThreshold{
+			MetricCommitToAccept: {P95: Duration(100 * time.Millisecond), P99: Duration(time.Second)},
+		},
+	})
+	if report.Passed || len(report.Violations) != 2 {
+		t.Fatalf("violations = %q", report.Violations)
+	}
+	report.CheckThresholds(Thresholds{})
+	if !report.Passed || report.Violations != nil {
+		t.Fatalf("without thresholds: passed %v, violations %q", report.Passed, report.Violations)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	report := &Report{
+		Scenario:  "mixed",
+		Counters:  Counters{CommitsAccepted: 7},
+		Latencies: map[string]LatencySummary{MetricCommitToAccept: summarize([]time.Duration{2 * time.Millisecond})},
+		Errors:    map[string]int64{ErrClassConnectionLost: 1, "serverDisconnect:badChangeset": 2},
+		Passed:    true,
+	}
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"metric,value\n",
+		"counters.commitsAccepted,7\n",
+		"latency.commitToAccept.p95_ms,2\n",
+		"latency.commitToAccept.le_2ms,1\n",
+		"errors.connectionLost,1\nerrors.serverDisconnect:badChangeset,2\n",
+		"passed,true\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("csv misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sheetOpToAccept") {
+		t.Error("csv contains a metric missing from the report")
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&client.AccessError{Status: "deny"}, ErrClassAccessDenied},
+		{fmt.Errorf("wrapped: %w", &client.DisconnectError{Reason: "badChangeset"}), "serverDisconnect:badChangeset"},
+		{context.DeadlineExceeded, ErrClassConnectTimeout},
+		{client.ErrNotConnected, ErrClassNotConnected},
+		{errors.New("client: missed revisions (local 3, server 5)"), ErrClassOutOfSync},
+		{errors.New("websocket: close 1006 (abnormal closure)"), ErrClassConnectionLost},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseScenarioArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    scenarioArgs
+		wantErr bool
+	}{
+		{name: "file first", args: []string{"s.json", "-json", "r.json"}, want: scenarioArgs{file: "s.json", jsonPath: "r.json"}},
+		{name: "file last", args: []string{"-host", "http://h:9001", "-csv", "-", "s.json"}, want: scenarioArgs{file: "s.json", host: "http://h:9001", csvPath: "-"}},
+		{name: "missing file", args: []string{"-json", "r.json"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScenarioArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("parseScenarioArgs() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
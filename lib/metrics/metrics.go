@@ -0,0 +1,185 @@
+// Package metrics holds the Prometheus collectors of the collaboration
+// pipeline. Instrumented packages record into them unconditionally; they are
+// only exposed when enableMetrics registers them on the /metrics registry.
+//
+// Every label is bounded: values come from fixed sets in the code (message
+// types, reject reasons, DataStore methods, formats) and anything else is
+// reported as "other". Pad ids, authors and IPs are never used as labels.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "etherpad"
+
+// Other replaces label values outside the known set.
+const Other = "other"
+
+// Queue names for the per-pad serialization queues.
+const (
+	QueueText  = "text"
+	QueueSheet = "sheet"
+)
+
+var latencyBuckets = prometheus.ExponentialBuckets(0.0005, 2, 14)
+
+var (
+	ChangesetApplyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "changeset_apply_duration_seconds",
+		Help:      "Time from dequeuing USER_CHANGES until ACCEPT_COMMIT is sent",
+		Buckets:   latencyBuckets,
+	})
+	ChangesetQueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "changeset_queue_wait_seconds",
+		Help:      "Time USER_CHANGES spent in the per-pad queue",
+		Buckets:   latencyBuckets,
+	})
+	ChangesetsAccepted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changesets_accepted_total",
+		Help:      "Number of accepted USER_CHANGES",
+	})
+	ChangesetsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changesets_rejected_total",
+		Help:      "Number of USER_CHANGES rejected with badChangeset, by reason",
+	}, []string{"reason"})
+
+	QueueDepth = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pad_queue_depth",
+		Help:      "Tasks ahead of a task in its per-pad queue when it is enqueued",
+		Buckets:   []float64{0, 1, 2, 4, 8, 16, 32, 64, 128},
+	}, []string{"queue"})
+	QueuePending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pad_queue_pending",
+		Help:      "Tasks waiting in or being processed by the per-pad queues",
+	}, []string{"queue"})
+	QueueWorkers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pad_queue_workers",
+		Help:      "Number of per-pad queue goroutines",
+	}, []string{"queue"})
+
+	WSMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_messages_total",
+		Help:      "Websocket messages received, by message type",
+	}, []string{"type"})
+	WSConnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_connects_total",
+		Help:      "Websocket connections opened",
+	})
+	WSDisconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_disconnects_total",
+		Help:      "Websocket connections closed, by close reason",
+	}, []string{"reason"})
+	WSDisconnectRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_disconnect_requests_total",
+		Help:      "Disconnect messages sent by the server, by reason",
+	}, []string{"reason"})
+	RateLimitHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commit_rate_limit_hits_total",
+		Help:      "Commits dropped by commitRateLimiting",
+	})
+
+	ImportDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "import_duration_seconds",
+		Help:      "Pad import duration, by format and result",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"format", "result"})
+	ExportDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "export_duration_seconds",
+		Help:      "Pad export duration, by format and result",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"format", "result"})
+
+	DBCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_call_duration_seconds",
+		Help:      "DataStore call latency, by backend and method",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+	}, []string{"backend", "method"})
+	DBCallErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_call_errors_total",
+		Help:      "DataStore calls that returned an error, by backend and method",
+	}, []string{"backend", "method"})
+
+	SheetOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sheet_ops_total",
+		Help:      "Accepted SHEET_OPs, by op type",
+	}, []string{"type"})
+	SheetOpsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sheet_ops_rejected_total",
+		Help:      "SHEET_OPs that were not applied, by reason",
+	}, []string{"reason"})
+	SheetOpApplyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sheet_op_apply_duration_seconds",
+		Help:      "Time from dequeuing a SHEET_OP until ACCEPT_SHEET_OP is sent",
+		Buckets:   latencyBuckets,
+	})
+)
+
+// Collectors returns every collector of the package for registration.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ChangesetApplyDuration,
+		ChangesetQueueWait,
+		ChangesetsAccepted,
+		ChangesetsRejected,
+		QueueDepth,
+		QueuePending,
+		QueueWorkers,
+		WSMessages,
+		WSConnects,
+		WSDisconnects,
+		WSDisconnectRequests,
+		RateLimitHits,
+		ImportDuration,
+		ExportDuration,
+		DBCallDuration,
+		DBCallErrors,
+		SheetOps,
+		SheetOpsRejected,
+		SheetOpApplyDuration,
+	}
+}
+
+// Bounded returns value if it is one of allowed and Other otherwise.
+func Bounded(value string, allowed ...string) string {
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	return Other
+}
+
+// Result is the result label of an operation that may fail.
+func Result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// Since observes the seconds elapsed since start.
+func Since(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
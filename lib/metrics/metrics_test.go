@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectorsRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(prometheus.NewGoCollector()); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range Collectors() {
+		if err := reg.Register(c); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+}
+
+func TestBounded(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"txt", "txt"},
+		{"pdf", "pdf"},
+		{"../../etc/passwd", Other},
+		{"", Other},
+	}
+	for _, tt := range tests {
+		if got := Bounded(tt.value, "txt", "pdf"); got != tt.want {
+			t.Errorf("Bounded(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	if Result(nil) != "ok" || Result(errors.New("x")) != "error" {
+		t.Error("unexpected Result labels")
+	}
+}
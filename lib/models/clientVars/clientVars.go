@@ -103,6 +103,7 @@ type ClientVars struct {
 	AbiwordAvailable                   string                             `json:"abiwordAvailable"`
 	SOfficeAvailable                   string                             `json:"sofficeAvailable"`
 	AvailableExports                   []string                           `json:"availableExports"`
+	ExportFormats                      []ExportFormat                     `json:"exportFormats"`
 	Plugins                            RootPlugin                         `json:"plugins"`
 	Parts                              map[string]interface{}             `json:"parts"`
 	IndentationOnNewLine               bool                               `json:"indentationOnNewLine"`
@@ -114,3 +115,11 @@ type ClientVars struct {
 	// through padOptions. Upstream #7698.
 	EnablePluginPadOptions bool `json:"enablePluginPadOptions"`
 }
+
+// ExportFormat describes the export menu link of a format registered by a
+// plugin, for which the pad template has no link.
+type ExportFormat struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Icon  string `json:"icon"`
+}
@@ -6,9 +6,29 @@ type ChatMessageDB struct {
 	Message  string
 	Time     *int64
 	AuthorId *string
+	// ReplyTo is the head of the message this one answers.
+	ReplyTo *int
+	// EditedAt is when the text was last changed, DeletedAt when the message
+	// was deleted and DeletedBy who deleted it (nil for a moderator acting
+	// through the API). A deleted message keeps its place but no text.
+	EditedAt  *int64
+	DeletedAt *int64
+	DeletedBy *string
+	// Mentions are the authors the text mentions.
+	Mentions []string
 }
 
 type ChatMessageDBWithDisplayName struct {
 	ChatMessageDB
 	DisplayName *string
 }
+
+// ChatMessageEditDB is an earlier text of an edited chat message.
+type ChatMessageEditDB struct {
+	PadId   string
+	Head    int
+	Version int
+	Message string
+	// Time is when the text was replaced.
+	Time int64
+}
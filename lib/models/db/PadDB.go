@@ -36,12 +36,38 @@ type PadDB struct {
 	ChatHead       int             `json:"chatHead"`
 	PublicStatus   bool            `json:"publicStatus"`
 	DocumentType   string          `json:"documentType"`
+	Fork           *PadFork        `json:"fork"`
 	ATextText      string          `json:"atextText"`
 	ATextAttribs   string          `json:"atextAttribs"`
 	CreatedAt      time.Time       `json:"createdAt"`
 	UpdatedAt      *time.Time      `json:"updatedAt"`
 }
 
+// PadFork links a pad to the pad it was forked from. SourceRev and Rev are
+// the revisions of the source and of the fork with the same text, which the
+// next merge starts from. If Sync is set, the fork has not got the changes
+// of the source yet: the fork at Rev with Sync applied has the text of the
+// source at SourceRev.
+type PadFork struct {
+	Source    string `json:"source"`
+	SourceRev int    `json:"sourceRev"`
+	Rev       int    `json:"rev"`
+	// Sync is a changeset in the pool of the source.
+	Sync string `json:"sync,omitempty"`
+	// Merge is set while a merge is written to the source and the fork.
+	Merge *PadForkMerge `json:"merge,omitempty"`
+}
+
+// PadForkMerge is a merge being applied. SourceRev and Rev are the heads it
+// was prepared at; Changeset is appended to the source and Sync to the fork,
+// both in the pool of the source.
+type PadForkMerge struct {
+	SourceRev int    `json:"sourceRev"`
+	Rev       int    `json:"rev"`
+	Changeset string `json:"changeset"`
+	Sync      string `json:"sync"`
+}
+
 type SavedRevision struct {
 	RevNum    int
 	SavedBy   string
@@ -20,3 +20,19 @@ type SheetOpDB struct {
 	AuthorId  *string
 	Timestamp int64
 }
+
+// SheetSnapshotDB is a workbook snapshot of a sheet document at one revision,
+// the starting point for rebuilding later revisions from the op-log.
+type SheetSnapshotDB struct {
+	PadId    string
+	Rev      int
+	Snapshot string
+}
+
+// SheetCommentDB is one comment thread of a sheet document. Thread is a
+// marshaled sheet.CommentThread.
+type SheetCommentDB struct {
+	PadId    string
+	ThreadId string
+	Thread   string
+}
@@ -1,6 +1,7 @@
 package pad
 
 import (
+	"context"
 	"errors"
 	"math"
 	"regexp"
@@ -57,6 +58,9 @@ type Pad struct {
 	ReadonlyId     *string
 	UpdatedAt      *time.Time
 	CreatedAt      time.Time
+
+	// Fork is set on pads forked from another pad.
+	Fork *db2.PadFork
 }
 
 func NewPad(id string, db db.DataStore, hook *hooks.Hook) Pad {
@@ -239,12 +243,16 @@ func (p *Pad) Check() error {
 }
 
 func (p *Pad) AppendChatMessage(authorId *string, timestamp int64, text string) (*int, error) {
+	return p.appendChatMessage(p.db, authorId, timestamp, text)
+}
+
+func (p *Pad) appendChatMessage(store db.DataStore, authorId *string, timestamp int64, text string) (*int, error) {
 	p.ChatHead = p.ChatHead + 1
-	err := p.db.SaveChatMessage(p.Id, p.ChatHead, authorId, timestamp, text)
+	err := store.SaveChatMessage(p.Id, p.ChatHead, authorId, timestamp, text)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.db.SaveChatHeadOfPad(p.Id, p.ChatHead); err != nil {
+	if err := store.SaveChatHeadOfPad(p.Id, p.ChatHead); err != nil {
 		return nil, err
 	}
 
@@ -352,12 +360,19 @@ func CleanText(context string) *string {
 }
 
 func (p *Pad) Init(text *string, author *string, authorManager *author.Manager) error {
+	return p.InitContext(context.Background(), text, author, authorManager)
+}
+
+// InitContext is Init with the database calls traced as children of the span
+// in ctx.
+func (p *Pad) InitContext(ctx context.Context, text *string, author *string, authorManager *author.Manager) error {
 	p.authorManager = authorManager
+	store := db.WithContext(p.db, ctx)
 
-	var pad, err = p.db.GetPad(p.Id)
+	var pad, err = store.GetPad(p.Id)
 
 	if err == nil {
-		var _, err = p.db.GetRevision(p.Id, pad.Head)
+		var _, err = store.GetRevision(p.Id, pad.Head)
 		if err != nil {
 			return errors.New("pad data is corrupted: missing revision")
 		}
@@ -383,7 +398,7 @@ func (p *Pad) Init(text *string, author *string, authorManager *author.Manager)
 		}
 
 		var firstChangeset, _ = changeset.MakeSplice("\n", 0, 0, *text, nil, nil)
-		_, err := p.AppendRevision(firstChangeset, author)
+		_, err := p.AppendRevisionContext(ctx, firstChangeset, author)
 		if err != nil {
 			return err
 		}
@@ -480,7 +495,13 @@ func (p *Pad) SpliceText(start int, ndel int, ins string, authorId *string) erro
 }
 
 func (p *Pad) GetRevision(revNumber int) (*db2.PadSingleRevision, error) {
-	return p.db.GetRevision(p.Id, revNumber)
+	return p.GetRevisionContext(context.Background(), revNumber)
+}
+
+// GetRevisionContext is GetRevision with the database call traced as a child
+// of the span in ctx.
+func (p *Pad) GetRevisionContext(ctx context.Context, revNumber int) (*db2.PadSingleRevision, error) {
+	return db.WithContext(p.db, ctx).GetRevision(p.Id, revNumber)
 }
 
 func (p *Pad) GetRevisions(start int, end int) (*[]db2.PadSingleRevision, error) {
@@ -488,6 +509,10 @@ func (p *Pad) GetRevisions(start int, end int) (*[]db2.PadSingleRevision, error)
 }
 
 func (p *Pad) Save() error {
+	return p.save(p.db)
+}
+
+func (p *Pad) save(store db.DataStore) error {
 	savedRevisionsInDB := make([]db2.SavedRevision, 0)
 	for _, savedRevision := range p.SavedRevisions {
 		savedRevisionsInDB = append(savedRevisionsInDB, db2.SavedRevision{
@@ -501,7 +526,7 @@ func (p *Pad) Save() error {
 
 	updatedAt := time.Now()
 
-	return p.db.CreatePad(p.Id, db2.PadDB{
+	return store.CreatePad(p.Id, db2.PadDB{
 		SavedRevisions: savedRevisionsInDB,
 		Head:           p.Head,
 		ChatHead:       p.ChatHead,
@@ -510,6 +535,7 @@ func (p *Pad) Save() error {
 		ID:             p.Id,
 		PublicStatus:   p.PublicStatus,
 		DocumentType:   p.DocumentType,
+		Fork:           p.Fork,
 		UpdatedAt:      &updatedAt,
 		CreatedAt:      p.CreatedAt,
 		ReadOnlyId:     p.ReadonlyId,
@@ -546,11 +572,35 @@ func (p *Pad) GetRevisionDate(rev int) (*int64, error) {
 	return &revision.Timestamp, nil
 }
 
+// ErrNoRevisionAt is returned by GetRevisionAt for times before the first
+// revision of the pad.
+var ErrNoRevisionAt = errors.New("pad has no revision at that time")
+
+// GetRevisionAt returns the last revision made at or before at, looked up by
+// the timestamp index of the revisions.
+func (p *Pad) GetRevisionAt(at time.Time) (int, error) {
+	rev, err := p.db.GetRevisionAtTimestamp(p.Id, at.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	if rev == nil {
+		return 0, ErrNoRevisionAt
+	}
+	return *rev, nil
+}
+
 func (p *Pad) getPublicStatus() bool {
 	return p.PublicStatus
 }
 
 func (p *Pad) AppendRevision(cs string, authorId *string) (*int, error) {
+	return p.AppendRevisionContext(context.Background(), cs, authorId)
+}
+
+// AppendRevisionContext is AppendRevision with its database calls and the
+// padUpdate hook traced as children of the span in ctx.
+func (p *Pad) AppendRevisionContext(ctx context.Context, cs string, authorId *string) (*int, error) {
+	store := db.WithContext(p.db, ctx)
 	var newAText, err = changeset.ApplyToAText(cs, p.AText, p.Pool)
 
 	if err != nil {
@@ -575,16 +625,16 @@ func (p *Pad) AppendRevision(cs string, authorId *string) (*int, error) {
 	}
 
 	// Save pad
-	p.Save()
+	p.save(store)
 
 	// padRev.authorId has a foreign key on globalAuthor. The reserved system
 	// author (used for unattributed API/plugin writes, upstream #7773) is
 	// never created through the normal author flows, so make sure its row
 	// exists before saving a revision attributed to it.
 	if authorId != nil && *authorId == SystemAuthorId {
-		if _, getErr := p.db.GetAuthor(SystemAuthorId); getErr != nil {
+		if _, getErr := store.GetAuthor(SystemAuthorId); getErr != nil {
 			systemName := "System"
-			if saveErr := p.db.SaveAuthor(db2.AuthorDB{
+			if saveErr := store.SaveAuthor(db2.AuthorDB{
 				ID:        SystemAuthorId,
 				Name:      &systemName,
 				ColorId:   "#808080",
@@ -601,7 +651,7 @@ func (p *Pad) AppendRevision(cs string, authorId *string) (*int, error) {
 	poolToUse = p.Pool
 	atextToUse = p.AText
 
-	err = p.db.SaveRevision(p.Id, newRev, cs, atextToUse.ToDBAText(), poolToUse.ToRevDB(), authorId, time.Now().UnixNano()/int64(time.Millisecond))
+	err = store.SaveRevision(p.Id, newRev, cs, atextToUse.ToDBAText(), poolToUse.ToRevDB(), authorId, time.Now().UnixNano()/int64(time.Millisecond))
 
 	if err != nil {
 		return nil, errors.New("Error saving revision during append " + err.Error())
@@ -623,7 +673,7 @@ func (p *Pad) AppendRevision(cs string, authorId *string) (*int, error) {
 		if authorId != nil {
 			updateAuthor = *authorId
 		}
-		p.hook.ExecutePadUpdateHooks(&events.PadUpdateContext{
+		p.hook.ExecuteHooksContext(ctx, hooks.PadUpdateString, &events.PadUpdateContext{
 			Pad:       p,
 			PadId:     p.Id,
 			AuthorId:  updateAuthor,
@@ -651,7 +701,13 @@ func (p *Pad) GetAllChatters() (*[]string, error) {
 }
 
 func (p *Pad) GetChatMessages(start int, end int) (*[]db2.ChatMessageDBWithDisplayName, error) {
-	return p.db.GetChatsOfPad(p.Id, start, end)
+	return p.GetChatMessagesContext(context.Background(), start, end)
+}
+
+// GetChatMessagesContext is GetChatMessages with the database call traced as
+// a child of the span in ctx.
+func (p *Pad) GetChatMessagesContext(ctx context.Context, start int, end int) (*[]db2.ChatMessageDBWithDisplayName, error) {
+	return db.WithContext(p.db, ctx).GetChatsOfPad(p.Id, start, end)
 }
 
 func (p *Pad) AddSavedRevision(author string) error {
@@ -0,0 +1,260 @@
+package pad
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/db"
+	db2 "github.com/ether/etherpad-go/lib/models/db"
+)
+
+var (
+	ErrChatMessageNotFound = errors.New("chat message not found")
+	ErrChatMessageDeleted  = errors.New("chat message is deleted")
+	ErrChatTextEmpty       = errors.New("chat text is empty")
+	ErrChatNotAuthor       = errors.New("only the author can edit a chat message")
+	ErrChatNotAllowed      = errors.New("only the author or a moderator can delete a chat message")
+)
+
+// PostChatMessage appends a chat message like AppendChatMessage, as a reply
+// to the message at replyTo if set, and records the pad authors its text
+// mentions. It returns the stored message.
+func (p *Pad) PostChatMessage(authorId *string, timestamp int64, text string, replyTo *int) (*db2.ChatMessageDBWithDisplayName, error) {
+	return p.PostChatMessageContext(context.Background(), authorId, timestamp, text, replyTo)
+}
+
+// PostChatMessageContext is PostChatMessage with the database calls traced as
+// children of the span in ctx.
+func (p *Pad) PostChatMessageContext(ctx context.Context, authorId *string, timestamp int64, text string, replyTo *int) (*db2.ChatMessageDBWithDisplayName, error) {
+	store := db.WithContext(p.db, ctx)
+	if replyTo != nil {
+		parent, err := p.getChatMessage(store, *replyTo)
+		if err != nil {
+			return nil, err
+		}
+		if parent.DeletedAt != nil {
+			return nil, ErrChatMessageDeleted
+		}
+	}
+	head, err := p.appendChatMessage(store, authorId, timestamp, text)
+	if err != nil {
+		return nil, err
+	}
+	if replyTo != nil {
+		if err := store.SetChatReplyTo(p.Id, *head, *replyTo); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.saveChatMentions(store, *head, authorId, text); err != nil {
+		return nil, err
+	}
+	return p.getChatMessage(store, *head)
+}
+
+// EditChatMessage replaces the text of the message at head. Only its author
+// can edit it; the replaced text stays in the message's edit history.
+func (p *Pad) EditChatMessage(head int, authorId string, timestamp int64, text string) (*db2.ChatMessageDBWithDisplayName, error) {
+	return p.EditChatMessageContext(context.Background(), head, authorId, timestamp, text)
+}
+
+// EditChatMessageContext is EditChatMessage with the database calls traced as
+// children of the span in ctx.
+func (p *Pad) EditChatMessageContext(ctx context.Context, head int, authorId string, timestamp int64, text string) (*db2.ChatMessageDBWithDisplayName, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrChatTextEmpty
+	}
+	store := db.WithContext(p.db, ctx)
+	msg, err := p.getChatMessage(store, head)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, ErrChatMessageDeleted
+	}
+	if msg.AuthorId == nil || *msg.AuthorId != authorId {
+		return nil, ErrChatNotAuthor
+	}
+	if err := store.EditChatMessage(p.Id, head, text, timestamp); err != nil {
+		return nil, err
+	}
+	if err := p.saveChatMentions(store, head, &authorId, text); err != nil {
+		return nil, err
+	}
+	return p.getChatMessage(store, head)
+}
+
+// DeleteChatMessage soft-deletes the message at head: it stays in the chat
+// as a placeholder, so replies keep their parent, but loses its text, edit
+// history and mentions. Its author or a moderator can delete it; authorId
+// is nil for the server itself, which counts as a moderator.
+func (p *Pad) DeleteChatMessage(head int, authorId *string, moderator bool, timestamp int64) (*db2.ChatMessageDBWithDisplayName, error) {
+	return p.DeleteChatMessageContext(context.Background(), head, authorId, moderator, timestamp)
+}
+
+// DeleteChatMessageContext is DeleteChatMessage with the database calls
+// traced as children of the span in ctx.
+func (p *Pad) DeleteChatMessageContext(ctx context.Context, head int, authorId *string, moderator bool, timestamp int64) (*db2.ChatMessageDBWithDisplayName, error) {
+	store := db.WithContext(p.db, ctx)
+	msg, err := p.getChatMessage(store, head)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, ErrChatMessageDeleted
+	}
+	own := authorId != nil && msg.AuthorId != nil && *msg.AuthorId == *authorId
+	if !own && !moderator && authorId != nil {
+		return nil, ErrChatNotAllowed
+	}
+	if err := store.DeleteChatMessage(p.Id, head, authorId, timestamp); err != nil {
+		return nil, err
+	}
+	return p.getChatMessage(store, head)
+}
+
+// GetChatMessage returns the message at head.
+func (p *Pad) GetChatMessage(head int) (*db2.ChatMessageDBWithDisplayName, error) {
+	return p.GetChatMessageContext(context.Background(), head)
+}
+
+// GetChatMessageContext is GetChatMessage with the database call traced as a
+// child of the span in ctx.
+func (p *Pad) GetChatMessageContext(ctx context.Context, head int) (*db2.ChatMessageDBWithDisplayName, error) {
+	return p.getChatMessage(db.WithContext(p.db, ctx), head)
+}
+
+func (p *Pad) getChatMessage(store db.DataStore, head int) (*db2.ChatMessageDBWithDisplayName, error) {
+	if head < 0 || head > p.ChatHead {
+		return nil, ErrChatMessageNotFound
+	}
+	messages, err := store.GetChatsOfPad(p.Id, head, head)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil || len(*messages) == 0 {
+		return nil, ErrChatMessageNotFound
+	}
+	return &(*messages)[0], nil
+}
+
+// GetChatMessageEdits returns the earlier texts of the message at head,
+// oldest first.
+func (p *Pad) GetChatMessageEdits(head int) (*[]db2.ChatMessageEditDB, error) {
+	return p.GetChatMessageEditsContext(context.Background(), head)
+}
+
+// GetChatMessageEditsContext is GetChatMessageEdits with the database calls
+// traced as children of the span in ctx.
+func (p *Pad) GetChatMessageEditsContext(ctx context.Context, head int) (*[]db2.ChatMessageEditDB, error) {
+	store := db.WithContext(p.db, ctx)
+	if _, err := p.getChatMessage(store, head); err != nil {
+		return nil, err
+	}
+	return store.GetChatMessageEdits(p.Id, head)
+}
+
+// UnreadChatMentions counts the messages mentioning authorId that the author
+// has not read yet.
+func (p *Pad) UnreadChatMentions(authorId string) (int, error) {
+	return p.UnreadChatMentionsContext(context.Background(), authorId)
+}
+
+// UnreadChatMentionsContext is UnreadChatMentions with the database call
+// traced as a child of the span in ctx.
+func (p *Pad) UnreadChatMentionsContext(ctx context.Context, authorId string) (int, error) {
+	return db.WithContext(p.db, ctx).CountUnreadChatMentions(p.Id, authorId)
+}
+
+// MarkChatRead records that authorId has read the chat up to head.
+func (p *Pad) MarkChatRead(authorId string, head int) error {
+	return p.MarkChatReadContext(context.Background(), authorId, head)
+}
+
+// MarkChatReadContext is MarkChatRead with the database call traced as a
+// child of the span in ctx.
+func (p *Pad) MarkChatReadContext(ctx context.Context, authorId string, head int) error {
+	if head > p.ChatHead {
+		head = p.ChatHead
+	}
+	return db.WithContext(p.db, ctx).SaveChatReadHead(p.Id, authorId, head)
+}
+
+// saveChatMentions stores the pad authors text mentions, leaving out the
+// author of the text.
+func (p *Pad) saveChatMentions(store db.DataStore, head int, authorId *string, text string) error {
+	names, err := p.chatAuthorNames(store)
+	if err != nil {
+		return err
+	}
+	mentions := MentionedAuthors(text, names)
+	if authorId != nil {
+		mentions = slices.DeleteFunc(mentions, func(id string) bool { return id == *authorId })
+	}
+	return store.SaveChatMentions(p.Id, head, mentions)
+}
+
+// chatAuthorNames maps the authors of the pad text and chat to their names.
+func (p *Pad) chatAuthorNames(store db.DataStore) (map[string]string, error) {
+	ids := p.GetAllAuthors()
+	chatters, err := store.GetAuthorIdsOfPadChats(p.Id)
+	if err != nil {
+		return nil, err
+	}
+	if chatters != nil {
+		ids = append(ids, *chatters...)
+	}
+	names := make(map[string]string, len(ids))
+	for _, id := range ids {
+		if _, ok := names[id]; ok {
+			continue
+		}
+		names[id] = ""
+		if a, err := store.GetAuthor(id); err == nil && a.Name != nil {
+			names[id] = *a.Name
+		}
+	}
+	return names, nil
+}
+
+// MentionedAuthors returns the authors text mentions, in the order of their
+// first mention. A mention is "@" followed by an author's name, matched case
+// insensitively and taking the longest name that fits, or by an author id.
+func MentionedAuthors(text string, names map[string]string) []string {
+	var out []string
+	for i := strings.IndexByte(text, '@'); i >= 0; {
+		rest := text[i+1:]
+		best, bestLen := "", 0
+		// An "@" inside a word, like in an e-mail address, is no mention.
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		inWord := i > 0 && !endsWord(string(before))
+		for id, name := range names {
+			if inWord {
+				break
+			}
+			for _, n := range []string{id, name} {
+				if len(n) > bestLen && len(n) <= len(rest) && strings.EqualFold(rest[:len(n)], n) && endsWord(rest[len(n):]) {
+					best, bestLen = id, len(n)
+				}
+			}
+		}
+		if best != "" && !slices.Contains(out, best) {
+			out = append(out, best)
+		}
+		next := strings.IndexByte(rest[bestLen:], '@')
+		if next < 0 {
+			break
+		}
+		i += 1 + bestLen + next
+	}
+	return out
+}
+
+// endsWord reports whether a name followed by rest ends there.
+func endsWord(rest string) bool {
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
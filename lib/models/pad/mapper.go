@@ -15,6 +15,7 @@ func mapDBPadToModel(dbPad *db.PadDB, padToAssignTo *Pad) {
 	} else {
 		padToAssignTo.DocumentType = dbPad.DocumentType
 	}
+	padToAssignTo.Fork = dbPad.Fork
 	padToAssignTo.CreatedAt = dbPad.CreatedAt
 	padToAssignTo.UpdatedAt = dbPad.UpdatedAt
 
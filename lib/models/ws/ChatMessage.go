@@ -25,6 +25,10 @@ type ChatMessageSendEvent struct {
 	Time     *int64  `json:"time,omitempty"`
 	UserId   *string `json:"userId,omitempty"`
 	UserName *string `json:"userName"`
+	Head     *int    `json:"head,omitempty"`
+	ReplyTo  *int    `json:"replyTo,omitempty"`
+	// Mentions are the ids of the authors the text mentions.
+	Mentions []string `json:"mentions,omitempty"`
 }
 
 type ChatMessageData struct {
@@ -34,13 +38,68 @@ type ChatMessageData struct {
 	AuthorId    *string `json:"authorId,omitempty"`
 	DisplayName *string `json:"displayName,omitempty"`
 	UserName    *string `json:"userName,omitempty"`
+	// ReplyTo is the head of the message this one answers.
+	ReplyTo *int `json:"replyTo,omitempty"`
 }
 
 type ChatMessageSendData struct {
-	Text     string  `json:"text"`
-	Time     *int64  `json:"time,omitempty"`
-	UserId   *string `json:"userId,omitempty"`
-	UserName *string `json:"userName,omitempty"`
+	Text     string   `json:"text"`
+	Time     *int64   `json:"time,omitempty"`
+	UserId   *string  `json:"userId,omitempty"`
+	UserName *string  `json:"userName,omitempty"`
+	Head     *int     `json:"head,omitempty"`
+	ReplyTo  *int     `json:"replyTo,omitempty"`
+	Mentions []string `json:"mentions,omitempty"`
+	EditedAt *int64   `json:"editedAt,omitempty"`
+	// Deleted messages keep their place in the chat but have no text.
+	Deleted   bool    `json:"deleted,omitempty"`
+	DeletedBy *string `json:"deletedBy,omitempty"`
+}
+
+// ChatAction is an incoming CHAT_EDIT (replace the text of the message at
+// Head), CHAT_DELETE (delete it) or CHAT_READ (the chat was read up to Head).
+type ChatAction struct {
+	Event string `json:"event"`
+	Data  struct {
+		Type      string `json:"type"`
+		Component string `json:"component"`
+		Data      struct {
+			Type string `json:"type"`
+			Head int    `json:"head"`
+			Text string `json:"text"`
+		} `json:"data"`
+	}
+}
+
+// ChatMessageUpdate broadcasts a chat message that was edited or deleted.
+// Sent as ["message", ChatMessageUpdate].
+type ChatMessageUpdate struct {
+	Type string `json:"type"` // "COLLABROOM"
+	Data struct {
+		Type    string              `json:"type"` // "CHAT_MESSAGE_UPDATE"
+		Message ChatMessageSendData `json:"message"`
+	} `json:"data"`
+}
+
+// ChatMentions tells an author how many messages mentioning them they have
+// not read yet. Sent as ["message", ChatMentions].
+type ChatMentions struct {
+	Type string `json:"type"` // "COLLABROOM"
+	Data struct {
+		Type   string `json:"type"` // "CHAT_MENTIONS"
+		Unread int    `json:"unread"`
+	} `json:"data"`
+}
+
+// RejectChat tells the sender its CHAT_EDIT or CHAT_DELETE of the message at
+// Head was refused. Sent as ["message", RejectChat].
+type RejectChat struct {
+	Type string `json:"type"` // "COLLABROOM"
+	Data struct {
+		Type   string `json:"type"` // "REJECT_CHAT"
+		Head   int    `json:"head"`
+		Reason string `json:"reason"`
+	} `json:"data"`
 }
 
 func FromObject(original ChatMessageData) ChatMessageData {
@@ -8,6 +8,10 @@ type InstalledPluginDefinition struct {
 	BackendPath  string `json:"backendPath"`
 	Updatable    bool   `json:"updatable"`
 	Enabled      bool   `json:"enabled"`
+	// Runtime is "wasm" for WebAssembly plugins, which can be enabled and
+	// disabled without a restart, and empty for built-in plugins.
+	Runtime string `json:"runtime,omitempty"`
+	Error   string `json:"error,omitempty"`
 }
 
 type PluginSearchDefinition struct {
@@ -23,6 +23,25 @@ type PadCreateData struct {
 	PadName string `json:"padName"`
 }
 
+// SheetToPadData copies a range of the sheet PadName into the text pad
+// TargetPadName. Empty Sheet and Range mean the first worksheet and its used
+// cells.
+type SheetToPadData struct {
+	PadName       string `json:"padName"`
+	TargetPadName string `json:"targetPadName"`
+	Sheet         string `json:"sheet"`
+	Range         string `json:"range"`
+	Delimiter     string `json:"delimiter"`
+}
+
+// PadToSheetData turns the text pad PadName into the new sheet TargetPadName.
+// An empty Delimiter is detected.
+type PadToSheetData struct {
+	PadName       string `json:"padName"`
+	TargetPadName string `json:"targetPadName"`
+	Delimiter     string `json:"delimiter"`
+}
+
 type ErrorMessage struct {
 	Error string `json:"error"`
 }
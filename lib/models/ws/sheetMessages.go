@@ -4,7 +4,9 @@ import "encoding/json"
 
 // SheetOpIncoming is the client->server SHEET_OP message. Wire shape mirrors
 // UserChange: {"event":"message","data":{"component":"sheet","type":"COLLABROOM",
-// "data":{"type":"SHEET_OP","op":<sheet.Op>,"baseRev":N}}}.
+// "data":{"type":"SHEET_OP","op":<sheet.Op>,"baseRev":N}}}. Join marks an op
+// that belongs to the same user action as the sender's previous one, so both
+// are undone in one step.
 type SheetOpIncoming struct {
 	Event string `json:"event"`
 	Data  struct {
@@ -14,10 +16,41 @@ type SheetOpIncoming struct {
 			Type    string          `json:"type"` // "SHEET_OP"
 			Op      json.RawMessage `json:"op"`   // a sheet.Op
 			BaseRev int             `json:"baseRev"`
+			Join    bool            `json:"join,omitempty"`
 		} `json:"data"`
 	} `json:"data"`
 }
 
+// SheetUndoIncoming is the client->server SHEET_UNDO or SHEET_REDO frame: undo
+// or redo the last action of the session's author. The resulting ops reach
+// every client, the sender included, as NEW_SHEET_OP. Wire shape mirrors
+// SheetOpIncoming.
+type SheetUndoIncoming struct {
+	Event string `json:"event"`
+	Data  struct {
+		Component string `json:"component"` // "sheet"
+		Type      string `json:"type"`      // "COLLABROOM"
+		Data      struct {
+			Type string `json:"type"` // "SHEET_UNDO" or "SHEET_REDO"
+		} `json:"data"`
+	} `json:"data"`
+}
+
+// SheetUndoResult answers a SHEET_UNDO or SHEET_REDO with how many steps the
+// author can undo and redo now, and the reason when nothing was done. Sent as
+// ["message", SheetUndoResult].
+type SheetUndoResult struct {
+	Type string              `json:"type"` // "COLLABROOM"
+	Data SheetUndoResultData `json:"data"`
+}
+
+type SheetUndoResultData struct {
+	Type  string `json:"type"` // "SHEET_UNDO_RESULT"
+	Undo  int    `json:"undo"`
+	Redo  int    `json:"redo"`
+	Error string `json:"error,omitempty"`
+}
+
 // SheetVars is the server->client initial state message (the sheet analogue of
 // CLIENT_VARS). Sent as ["message", SheetVars].
 type SheetVars struct {
@@ -31,6 +64,7 @@ type SheetVarsData struct {
 	UserId    string          `json:"userId"`
 	UserColor string          `json:"userColor"`
 	ReadOnly  bool            `json:"readonly"`
+	Comments  json.RawMessage `json:"comments"` // a []sheet.CommentThread, anchored as of Head
 }
 
 // AcceptSheetOp acknowledges the sender's own op. Sent as ["message", AcceptSheetOp].
@@ -44,6 +78,19 @@ type AcceptSheetOpData struct {
 	NewRev int    `json:"newRev"`
 }
 
+// RejectSheetOp tells the sender its op was refused (e.g. a protected range
+// or a failed data validation), so it can drop the op and tell the user.
+// Sent as ["message", RejectSheetOp].
+type RejectSheetOp struct {
+	Type string            `json:"type"` // "COLLABROOM"
+	Data RejectSheetOpData `json:"data"`
+}
+
+type RejectSheetOpData struct {
+	Type   string `json:"type"` // "REJECT_SHEET_OP"
+	Reason string `json:"reason"`
+}
+
 // NewSheetOp broadcasts a rebased op to the other clients of a sheet.
 // Sent as ["message", NewSheetOp].
 type NewSheetOp struct {
@@ -101,3 +148,67 @@ type SheetPresenceData struct {
 	FocusRow int    `json:"focusRow"`
 	FocusCol int    `json:"focusCol"`
 }
+
+// SheetCommentIncoming is the client->server SHEET_COMMENT frame. Action "add"
+// posts Text to ThreadId, or when ThreadId is empty to the thread of the cell
+// Sheet/Row/Col as composed against BaseRev; "resolve" and "reopen" set the
+// state of ThreadId; "delete" removes its comment CommentId. Wire shape
+// mirrors SheetOpIncoming.
+type SheetCommentIncoming struct {
+	Event string `json:"event"`
+	Data  struct {
+		Component string `json:"component"` // "sheet"
+		Type      string `json:"type"`      // "COLLABROOM"
+		Data      struct {
+			Type      string `json:"type"` // "SHEET_COMMENT"
+			Action    string `json:"action"`
+			ThreadId  string `json:"threadId"`
+			CommentId string `json:"commentId"`
+			Sheet     string `json:"sheet"`
+			Row       int    `json:"row"`
+			Col       int    `json:"col"`
+			BaseRev   int    `json:"baseRev"`
+			Text      string `json:"text"`
+		} `json:"data"`
+	} `json:"data"`
+}
+
+// SheetComment broadcasts the new state of one comment thread to every client
+// of the sheet, the sender included. Deleted means the thread is gone; Rev is
+// the revision its anchor is valid at. Sent as ["message", SheetComment].
+type SheetComment struct {
+	Type string           `json:"type"` // "COLLABROOM"
+	Data SheetCommentData `json:"data"`
+}
+
+type SheetCommentData struct {
+	Type    string          `json:"type"`   // "SHEET_COMMENT"
+	Thread  json.RawMessage `json:"thread"` // a sheet.CommentThread
+	Deleted bool            `json:"deleted,omitempty"`
+	Rev     int             `json:"rev"`
+}
+
+// SheetComments replaces a reconnecting client's comment threads, anchored as
+// of Rev. Sent as ["message", SheetComments].
+type SheetComments struct {
+	Type string            `json:"type"` // "COLLABROOM"
+	Data SheetCommentsData `json:"data"`
+}
+
+type SheetCommentsData struct {
+	Type    string          `json:"type"`    // "SHEET_COMMENTS"
+	Threads json.RawMessage `json:"threads"` // a []sheet.CommentThread
+	Rev     int             `json:"rev"`
+}
+
+// RejectSheetComment tells the sender its SHEET_COMMENT was refused. Sent as
+// ["message", RejectSheetComment].
+type RejectSheetComment struct {
+	Type string                 `json:"type"` // "COLLABROOM"
+	Data RejectSheetCommentData `json:"data"`
+}
+
+type RejectSheetCommentData struct {
+	Type   string `json:"type"` // "REJECT_SHEET_COMMENT"
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,365 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+const mimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+const namespaces = ` xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+	` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+	` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+	` xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"` +
+	` xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0"` +
+	` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"` +
+	` xmlns:of="urn:oasis:names:tc:opendocument:xmlns:of:1.2"` +
+	` office:version="1.2"`
+
+const manifest = xml.Header + `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">` +
+	`<manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="` + mimeType + `"/>` +
+	`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>` +
+	`<manifest:file-entry manifest:full-path="settings.xml" manifest:media-type="text/xml"/>` +
+	`</manifest:manifest>`
+
+// decimalRe accepts the plain decimal notation xsd:double shares with the
+// client engine; ParseFloat alone would also take hex floats and "Inf".
+var decimalRe = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// serialEpoch is day 0 of spreadsheet date serials, as in the client.
+var serialEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Export renders the workbook as an .ods file. Formulas are written in
+// OpenFormula syntax with their cached value when the client reported one;
+// styles, merges, dimensions and freeze panes are kept.
+func Export(wb *sheet.Workbook) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// The mimetype entry must come first and be stored uncompressed so the
+	// format can be sniffed at a fixed offset.
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte(mimeType)); err != nil {
+		return nil, err
+	}
+	files := []struct{ name, body string }{
+		{"content.xml", content(wb)},
+		{"settings.xml", settings(wb)},
+		{"META-INF/manifest.xml", manifest},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// content renders content.xml: automatic styles, then one table per sheet.
+func content(wb *sheet.Workbook) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<office:document-content` + namespaces + `>`)
+
+	// Styles: ce<id> per used pool style (with N<id> for its numFmt),
+	// co<px>/ro<px> per distinct column width / row height.
+	styleIds := map[int]bool{}
+	widths, heights := map[int]bool{}, map[int]bool{}
+	for _, s := range wb.Sheets {
+		for _, c := range s.Cells {
+			if c.StyleId != 0 {
+				styleIds[c.StyleId] = true
+			}
+		}
+		for _, px := range s.ColWidths {
+			widths[px] = true
+		}
+		for _, px := range s.RowHeights {
+			heights[px] = true
+		}
+	}
+	valueTypes := map[int]string{}
+	b.WriteString(`<office:automatic-styles>`)
+	for _, px := range sortedKeys(widths) {
+		fmt.Fprintf(&b, `<style:style style:name="co%d" style:family="table-column"><style:table-column-properties style:column-width="%s"/></style:style>`, px, pxToLength(px))
+	}
+	for _, px := range sortedKeys(heights) {
+		fmt.Fprintf(&b, `<style:style style:name="ro%d" style:family="table-row"><style:table-row-properties style:row-height="%s" style:use-optimal-row-height="false"/></style:style>`, px, pxToLength(px))
+	}
+	for _, id := range sortedKeys(styleIds) {
+		st, ok := wb.Styles.Get(id)
+		if !ok {
+			continue
+		}
+		dataName := ""
+		if numFmt := st.Props["numFmt"]; numFmt != "" {
+			if x, vt := dataStyle(fmt.Sprintf("N%d", id), numFmt); x != "" {
+				b.WriteString(x)
+				dataName, valueTypes[id] = fmt.Sprintf("N%d", id), vt
+			}
+		}
+		fmt.Fprintf(&b, `<style:style style:name="ce%d" style:family="table-cell"`, id)
+		if dataName != "" {
+			b.WriteString(` style:data-style-name="` + dataName + `"`)
+		}
+		b.WriteString(`>`)
+		cs := propsToStyle(st.Props)
+		writeProps(&b, "style:table-cell-properties", cs.cell)
+		writeProps(&b, "style:paragraph-properties", cs.paragraph)
+		writeProps(&b, "style:text-properties", cs.text)
+		b.WriteString(`</style:style>`)
+	}
+	b.WriteString(`</office:automatic-styles><office:body><office:spreadsheet>`)
+
+	for _, s := range wb.Sheets {
+		writeTable(&b, wb.Styles, s, valueTypes)
+	}
+	b.WriteString(`</office:spreadsheet></office:body></office:document-content>`)
+	return b.String()
+}
+
+func writeProps(b *strings.Builder, element string, attrs [][2]string) {
+	if len(attrs) == 0 {
+		return
+	}
+	b.WriteString("<" + element)
+	for _, a := range attrs {
+		b.WriteString(" " + a[0] + `="` + escape(a[1]) + `"`)
+	}
+	b.WriteString("/>")
+}
+
+// element is one rendered table element; equal neighbours collapse into one
+// with a repeat count.
+type element struct {
+	name, attrs, inner string
+}
+
+func (e element) render(b *strings.Builder, repeatAttr string, n int) {
+	b.WriteString("<" + e.name + e.attrs)
+	if n > 1 {
+		fmt.Fprintf(b, ` %s="%d"`, repeatAttr, n)
+	}
+	if e.inner == "" {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">" + e.inner + "</" + e.name + ">")
+}
+
+func writeRuns(b *strings.Builder, elems []element, repeatAttr string) {
+	for i := 0; i < len(elems); {
+		j := i + 1
+		for j < len(elems) && elems[j] == elems[i] {
+			j++
+		}
+		elems[i].render(b, repeatAttr, j-i)
+		i = j
+	}
+}
+
+func writeTable(b *strings.Builder, styles *sheet.StylePool, s *sheet.Sheet, valueTypes map[int]string) {
+	rows, cols := 1, 1
+	for ref := range s.Cells {
+		rows, cols = max(rows, ref.Row+1), max(cols, ref.Col+1)
+	}
+	covered := map[sheet.CellRef]bool{}
+	for a, sp := range s.Merges {
+		rows, cols = max(rows, a.Row+sp.Rows), max(cols, a.Col+sp.Cols)
+		for r := a.Row; r < a.Row+sp.Rows; r++ {
+			for c := a.Col; c < a.Col+sp.Cols; c++ {
+				if r != a.Row || c != a.Col {
+					covered[sheet.CellRef{Row: r, Col: c}] = true
+				}
+			}
+		}
+	}
+	for c := range s.ColWidths {
+		cols = max(cols, c+1)
+	}
+	for r := range s.RowHeights {
+		rows = max(rows, r+1)
+	}
+
+	b.WriteString(`<table:table table:name="` + escape(s.Name) + `">`)
+	columns := make([]element, cols)
+	for c := range columns {
+		columns[c].name = "table:table-column"
+		if px, ok := s.ColWidths[c]; ok {
+			columns[c].attrs = fmt.Sprintf(` table:style-name="co%d"`, px)
+		}
+	}
+	writeRuns(b, columns, "table:number-columns-repeated")
+
+	rowElems := make([]element, rows)
+	for r := range rowElems {
+		cells := make([]element, cols)
+		for c := range cells {
+			ref := sheet.CellRef{Row: r, Col: c}
+			cells[c] = cellElement(styles, s.GetCell(ref), valueTypes, s.Merges[ref], covered[ref])
+		}
+		var inner strings.Builder
+		writeRuns(&inner, cells, "table:number-columns-repeated")
+		rowElems[r] = element{name: "table:table-row", inner: inner.String()}
+		if px, ok := s.RowHeights[r]; ok {
+			rowElems[r].attrs = fmt.Sprintf(` table:style-name="ro%d"`, px)
+		}
+	}
+	writeRuns(b, rowElems, "table:number-rows-repeated")
+	b.WriteString(`</table:table>`)
+}
+
+// cellElement renders one cell. Numeric content gets the value type its data
+// style demands, with the raw as the displayed text.
+func cellElement(styles *sheet.StylePool, c sheet.Cell, valueTypes map[int]string, span sheet.Span, covered bool) element {
+	e := element{name: "table:table-cell"}
+	if covered {
+		e.name = "table:covered-table-cell"
+	}
+	var attrs strings.Builder
+	if span.Rows > 0 {
+		fmt.Fprintf(&attrs, ` table:number-columns-spanned="%d" table:number-rows-spanned="%d"`, span.Cols, span.Rows)
+	}
+	if st, ok := styles.Get(c.StyleId); ok && c.StyleId != 0 && len(st.Props) > 0 {
+		fmt.Fprintf(&attrs, ` table:style-name="ce%d"`, c.StyleId)
+	}
+	text := c.Raw
+	if c.Kind() == sheet.KindFormula {
+		attrs.WriteString(` table:formula="` + escape(toOpenFormula(c.Raw)) + `"`)
+		text = c.Value
+	}
+	if text != "" {
+		valueType := valueTypes[c.StyleId]
+		num, err := strconv.ParseFloat(text, 64)
+		switch {
+		case err != nil || !decimalRe.MatchString(text) || isText(styles, c.StyleId):
+			attrs.WriteString(` office:value-type="string"`)
+		case valueType == "date":
+			attrs.WriteString(` office:value-type="date" office:date-value="` + serialToDate(num) + `"`)
+		case valueType == "currency":
+			attrs.WriteString(` office:value-type="currency" office:currency="USD" office:value="` + text + `"`)
+		case valueType == "percentage":
+			attrs.WriteString(` office:value-type="percentage" office:value="` + text + `"`)
+		default:
+			attrs.WriteString(` office:value-type="float" office:value="` + text + `"`)
+		}
+		var inner strings.Builder
+		for _, line := range strings.Split(text, "\n") {
+			inner.WriteString("<text:p>" + paragraph(line) + "</text:p>")
+		}
+		e.inner = inner.String()
+	}
+	e.attrs = attrs.String()
+	return e
+}
+
+// paragraph escapes one line of cell text. ODF collapses whitespace like
+// HTML, so tabs and runs of spaces (and lone spaces at either end) are
+// spelled out as elements.
+func paragraph(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '\t':
+			b.WriteString("<text:tab/>")
+			i++
+		case line[i] == ' ':
+			j := i
+			for j < len(line) && line[j] == ' ' {
+				j++
+			}
+			if n := j - i; n == 1 && i > 0 && j < len(line) {
+				b.WriteByte(' ')
+			} else {
+				fmt.Fprintf(&b, `<text:s text:c="%d"/>`, n)
+			}
+			i = j
+		default:
+			j := strings.IndexAny(line[i:], " \t")
+			if j < 0 {
+				j = len(line) - i
+			}
+			b.WriteString(escape(line[i : i+j]))
+			i += j
+		}
+	}
+	return b.String()
+}
+
+func isText(styles *sheet.StylePool, id int) bool {
+	st, _ := styles.Get(id)
+	return st.Props["numFmt"] == "text"
+}
+
+// serialToDate converts a spreadsheet date serial to an xsd date(Time).
+func serialToDate(serial float64) string {
+	t := serialEpoch.Add(time.Duration(math.Round(serial*86400)) * time.Second)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// settings renders settings.xml, which is where ODF keeps freeze panes.
+func settings(wb *sheet.Workbook) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<office:document-settings xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:config="urn:oasis:names:tc:opendocument:xmlns:config:1.0" office:version="1.2">`)
+	b.WriteString(`<office:settings><config:config-item-set config:name="ooo:view-settings"><config:config-item-map-indexed config:name="Views"><config:config-item-map-entry>`)
+	b.WriteString(`<config:config-item config:name="ViewId" config:type="string">view1</config:config-item><config:config-item-map-named config:name="Tables">`)
+	item := func(name, typ string, v int) {
+		fmt.Fprintf(&b, `<config:config-item config:name="%s" config:type="%s">%d</config:config-item>`, name, typ, v)
+	}
+	for _, s := range wb.Sheets {
+		if s.FrozenRows == 0 && s.FrozenCols == 0 {
+			continue
+		}
+		b.WriteString(`<config:config-item-map-entry config:name="` + escape(s.Name) + `">`)
+		// Mode 2 is a frozen split; positions count columns (horizontal)
+		// and rows (vertical).
+		item("HorizontalSplitMode", "short", 2*min(s.FrozenCols, 1))
+		item("VerticalSplitMode", "short", 2*min(s.FrozenRows, 1))
+		item("HorizontalSplitPosition", "int", s.FrozenCols)
+		item("VerticalSplitPosition", "int", s.FrozenRows)
+		item("ActiveSplitRange", "short", 2)
+		item("PositionRight", "int", s.FrozenCols)
+		item("PositionBottom", "int", s.FrozenRows)
+		b.WriteString(`</config:config-item-map-entry>`)
+	}
+	b.WriteString(`</config:config-item-map-named></config:config-item-map-entry></config:config-item-map-indexed></config:config-item-set></office:settings></office:document-settings>`)
+	return b.String()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
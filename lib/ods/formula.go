@@ -0,0 +1,161 @@
+package ods
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// OpenFormula spells references in brackets with a dot between sheet and
+// cell ([.A1], [Sheet2.A1:.B3], ['My Sheet'.A1]) and separates arguments with
+// ';'. The model uses the A1 notation of the client engine.
+
+// toOpenFormula converts a model formula ("=SUM(Sheet2!A1:B3,1)") to the
+// table:formula attribute value ("of:=SUM([Sheet2.A1:.B3];1)").
+func toOpenFormula(raw string) string {
+	converted := sheet.MapFormulaRefs(raw, func(sheetName, ref string) string {
+		prefix := ""
+		if sheetName != "" {
+			prefix = quoteODSName(sheetName)
+		}
+		start, end, isRange := strings.Cut(ref, ":")
+		if !isRange {
+			return "[" + prefix + "." + start + "]"
+		}
+		return "[" + prefix + "." + start + ":." + end + "]"
+	})
+	return "of:" + swapSeparators(converted, ',', ';')
+}
+
+// fromOpenFormula converts a table:formula value back to a model formula. The
+// namespace prefix ("of:", "oooc:", "msoxl:") is dropped; formulas in other
+// syntaxes are returned as is after it, which is what spreadsheets do too.
+func fromOpenFormula(formula string) string {
+	if i := strings.Index(formula, ":="); i >= 0 && !strings.ContainsAny(formula[:i], `"[(`) {
+		formula = formula[i+1:]
+	}
+	if !strings.HasPrefix(formula, "=") {
+		formula = "=" + formula
+	}
+	formula = swapSeparators(formula, ';', ',')
+	var out strings.Builder
+	for i, seg := range strings.Split(formula, `"`) {
+		if i > 0 {
+			out.WriteByte('"')
+		}
+		if i%2 == 1 {
+			out.WriteString(seg)
+			continue
+		}
+		out.WriteString(bracketRefRe.ReplaceAllStringFunc(seg, func(m string) string {
+			if ref, ok := parseBracketRef(m[1 : len(m)-1]); ok {
+				return ref
+			}
+			return m
+		}))
+	}
+	return out.String()
+}
+
+// bracketRefRe matches one bracketed reference. Sheet names with special
+// characters are single-quoted (a quote doubled inside), so ']' can only
+// appear within quotes.
+var bracketRefRe = regexp.MustCompile(`\[(?:'(?:[^']|'')*'|[^'\]])*\]`)
+
+// parseBracketRef converts the inside of [..] to A1 notation. Only the sheet
+// of the first part is kept: the model has no 3D ranges.
+func parseBracketRef(s string) (string, bool) {
+	parts := splitOutsideQuotes(s, ':')
+	if len(parts) > 2 {
+		return "", false
+	}
+	var out strings.Builder
+	for i, part := range parts {
+		dot := lastDotOutsideQuotes(part)
+		if dot < 0 {
+			return "", false
+		}
+		name := strings.TrimPrefix(part[:dot], "$")
+		cell := part[dot+1:]
+		if cell == "" || strings.Contains(cell, "'") {
+			return "", false
+		}
+		if i == 0 && name != "" {
+			if strings.HasPrefix(name, "'") {
+				name = strings.ReplaceAll(strings.Trim(name, "'"), "''", "'")
+			}
+			out.WriteString(sheet.QuoteSheetName(name))
+			out.WriteByte('!')
+		}
+		if i > 0 {
+			out.WriteByte(':')
+		}
+		out.WriteString(cell)
+	}
+	return out.String(), true
+}
+
+// quoteODSName quotes a sheet name unless it is a plain identifier.
+func quoteODSName(name string) string {
+	if plainNameRe.MatchString(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+var plainNameRe = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+
+// swapSeparators replaces from by to outside string literals, sheet names,
+// brackets and inline arrays.
+func swapSeparators(s string, from, to byte) string {
+	b := []byte(s)
+	inString, inName, depth := false, false, 0
+	for i, ch := range b {
+		switch {
+		case inString:
+			inString = ch != '"'
+		case inName:
+			inName = ch != '\''
+		case ch == '"':
+			inString = true
+		case ch == '\'':
+			inName = true
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth = max(depth-1, 0)
+		case ch == from && depth == 0:
+			b[i] = to
+		}
+	}
+	return string(b)
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	quoted, last := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func lastDotOutsideQuotes(s string) int {
+	quoted, dot := false, -1
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			quoted = !quoted
+		case s[i] == '.' && !quoted:
+			dot = i
+		}
+	}
+	return dot
+}
@@ -0,0 +1,494 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// The client grid is fixed at 200x52. Repeated rows/cells (ODF compresses
+// runs, and whole-column styles repeat to the last row) only expand within it.
+const (
+	maxRows = 200
+	maxCols = 52
+)
+
+// maxPartSize bounds each decompressed XML part, so a small upload cannot
+// expand without limit.
+const maxPartSize = 64 << 20
+
+// prefixes maps the namespaces the importer reads to their usual prefix, so
+// names can be matched as "table:table-cell" whatever prefix a file declares.
+var prefixes = map[string]string{
+	"urn:oasis:names:tc:opendocument:xmlns:office:1.0":            "office",
+	"urn:oasis:names:tc:opendocument:xmlns:style:1.0":             "style",
+	"urn:oasis:names:tc:opendocument:xmlns:text:1.0":              "text",
+	"urn:oasis:names:tc:opendocument:xmlns:table:1.0":             "table",
+	"urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0":         "number",
+	"urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0": "fo",
+	"urn:oasis:names:tc:opendocument:xmlns:config:1.0":            "config",
+}
+
+func qname(n xml.Name) string {
+	return prefixes[n.Space] + ":" + n.Local
+}
+
+func attrMap(attrs []xml.Attr) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[qname(a.Name)] = a.Value
+	}
+	return m
+}
+
+// odsStyle is a style:style element: its formatting attributes from all
+// property children, plus what it references.
+type odsStyle struct {
+	parent, dataStyle string
+	attrs             map[string]string
+}
+
+// importer accumulates styles across styles.xml and content.xml and builds
+// the workbook from the tables.
+type importer struct {
+	wb       *sheet.Workbook
+	styles   map[string]*odsStyle
+	numFmts  map[string]string
+	poolIds  map[string]int
+	sheet    *sheet.Sheet
+	row      int
+	colStyle []string // default cell style per column of the current table
+	pending  []pendingCell
+	rowSpec  struct{ repeat, height int }
+}
+
+// pendingCell is a parsed cell of the current row, placed once the row's own
+// repeat count is known. Without a style of its own, each column it repeats
+// into supplies its default cell style.
+type pendingCell struct {
+	col, repeat int
+	cell        sheet.Cell
+	styled      bool
+	span        sheet.Span
+}
+
+// Import parses an .ods into a WorkbookSnapshot. Sheet id == sheet name.
+// Formulas are converted from OpenFormula; cell styles (allowlisted props
+// only), column widths / row heights, merged ranges and freeze panes are
+// imported.
+func Import(r io.Reader) (sheet.WorkbookSnapshot, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return sheet.WorkbookSnapshot{}, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return sheet.WorkbookSnapshot{}, err
+	}
+	parts := map[string]*zip.File{}
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+	if parts["content.xml"] == nil {
+		return sheet.WorkbookSnapshot{}, errors.New("content.xml missing")
+	}
+
+	im := &importer{wb: sheet.NewWorkbook(), styles: map[string]*odsStyle{}, numFmts: map[string]string{}, poolIds: map[string]int{}}
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		if f := parts[name]; f != nil {
+			if err := readPart(f, im.handle); err != nil {
+				return sheet.WorkbookSnapshot{}, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	if f := parts["settings.xml"]; f != nil {
+		// Freeze panes only; a broken settings part doesn't spoil the data.
+		_ = readPart(f, im.settingsHandler())
+	}
+	return im.wb.Snapshot(), nil
+}
+
+// readPart streams the tokens of one XML part into handle.
+func readPart(f *zip.File, handle func(dec *xml.Decoder, tok xml.Token) error) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	dec := xml.NewDecoder(io.LimitReader(rc, maxPartSize))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(dec, tok); err != nil {
+			return err
+		}
+	}
+}
+
+func (im *importer) handle(dec *xml.Decoder, tok xml.Token) error {
+	start, ok := tok.(xml.StartElement)
+	if !ok {
+		if end, ok := tok.(xml.EndElement); ok {
+			switch qname(end.Name) {
+			case "table:table-row":
+				im.flushRow()
+			case "table:table":
+				im.sheet = nil
+			}
+		}
+		return nil
+	}
+	a := attrMap(start.Attr)
+	switch name := qname(start.Name); {
+	case name == "style:style":
+		return im.readStyle(dec, a)
+	case strings.HasPrefix(name, "number:") && strings.HasSuffix(name, "-style"):
+		return im.readDataStyle(dec, start.Name.Local, a)
+	case name == "table:table":
+		im.startTable(a["table:name"])
+	case im.sheet == nil:
+	case name == "table:table-column":
+		repeat := repeatCount(a["table:number-columns-repeated"])
+		width := 0
+		if st := im.styles[a["table:style-name"]]; st != nil {
+			width = lengthToPx(st.attrs["style:column-width"])
+		}
+		for i := 0; i < repeat && len(im.colStyle) < maxCols; i++ {
+			if width > 0 {
+				im.sheet.ColWidths[len(im.colStyle)] = width
+			}
+			im.colStyle = append(im.colStyle, a["table:default-cell-style-name"])
+		}
+	case name == "table:table-row":
+		im.pending = im.pending[:0]
+		im.rowSpec.repeat = repeatCount(a["table:number-rows-repeated"])
+		im.rowSpec.height = 0
+		if st := im.styles[a["table:style-name"]]; st != nil && st.attrs["style:use-optimal-row-height"] != "true" {
+			im.rowSpec.height = lengthToPx(st.attrs["style:row-height"])
+		}
+	case name == "table:table-cell" || name == "table:covered-table-cell":
+		return im.readCell(dec, start.Name, a)
+	}
+	return nil
+}
+
+func (im *importer) startTable(name string) {
+	id := name
+	for n := 2; im.wb.SheetByID(id) != nil; n++ {
+		id = fmt.Sprintf("%s-%d", name, n)
+	}
+	im.sheet = im.wb.AddSheet(id, name)
+	im.row = 0
+	im.colStyle = im.colStyle[:0]
+}
+
+// readStyle consumes a style:style element.
+func (im *importer) readStyle(dec *xml.Decoder, a map[string]string) error {
+	st := &odsStyle{parent: a["style:parent-style-name"], dataStyle: a["style:data-style-name"], attrs: map[string]string{}}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for k, v := range attrMap(t.Attr) {
+				st.attrs[k] = v
+			}
+		case xml.EndElement:
+			if qname(t.Name) == "style:style" {
+				im.styles[a["style:name"]] = st
+				return nil
+			}
+		}
+	}
+}
+
+// readDataStyle consumes a number:*-style element.
+func (im *importer) readDataStyle(dec *xml.Decoder, local string, a map[string]string) error {
+	decimals, grouping := -1, false
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if qname(t.Name) == "number:number" {
+				na := attrMap(t.Attr)
+				if d, err := strconv.Atoi(na["number:decimal-places"]); err == nil && d >= 0 {
+					decimals = d
+				}
+				grouping = na["number:grouping"] == "true"
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	im.numFmts[a["style:name"]] = dataStyleToNumFmt(local, decimals, grouping)
+	return nil
+}
+
+// poolId interns the props of a named cell style (following parent styles)
+// and returns its pool id, 0 when nothing is representable.
+func (im *importer) poolId(name string) int {
+	if name == "" {
+		return 0
+	}
+	if id, ok := im.poolIds[name]; ok {
+		return id
+	}
+	attrs, dataStyle := map[string]string{}, ""
+	// Parents first so the style's own attributes win; the depth bound
+	// guards against cycles.
+	var chain []*odsStyle
+	for n, depth := name, 0; n != "" && depth < 8; depth++ {
+		st := im.styles[n]
+		if st == nil {
+			break
+		}
+		chain = append(chain, st)
+		n = st.parent
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].attrs {
+			attrs[k] = v
+		}
+		if chain[i].dataStyle != "" {
+			dataStyle = chain[i].dataStyle
+		}
+	}
+	props := styleToProps(attrs)
+	if nf := im.numFmts[dataStyle]; nf != "" {
+		props["numFmt"] = nf
+	}
+	id := 0
+	if len(props) > 0 {
+		id = im.wb.Styles.Put(sheet.Style{Props: props})
+	}
+	im.poolIds[name] = id
+	return id
+}
+
+// readCell consumes a table cell and queues it for the current row.
+func (im *importer) readCell(dec *xml.Decoder, name xml.Name, a map[string]string) error {
+	text, err := cellText(dec, name)
+	if err != nil {
+		return err
+	}
+	col := 0
+	if n := len(im.pending); n > 0 {
+		col = im.pending[n-1].col + im.pending[n-1].repeat
+	}
+	pc := pendingCell{col: col, repeat: repeatCount(a["table:number-columns-repeated"])}
+
+	if styleName := a["table:style-name"]; styleName != "" {
+		pc.cell.StyleId, pc.styled = im.poolId(styleName), true
+	}
+	pc.cell.Raw = cellRaw(a, text)
+	rows, _ := strconv.Atoi(a["table:number-rows-spanned"])
+	cols, _ := strconv.Atoi(a["table:number-columns-spanned"])
+	if rows > 1 || cols > 1 {
+		pc.span = sheet.Span{Rows: max(rows, 1), Cols: max(cols, 1)}
+	}
+	im.pending = append(im.pending, pc)
+	return nil
+}
+
+// cellRaw derives the model raw from the value attributes of a cell.
+func cellRaw(a map[string]string, text string) string {
+	if f := a["table:formula"]; f != "" {
+		return fromOpenFormula(f)
+	}
+	switch a["office:value-type"] {
+	case "float", "percentage", "currency":
+		if v := a["office:value"]; v != "" {
+			return v
+		}
+	case "date":
+		if serial, ok := dateToSerial(a["office:date-value"]); ok {
+			return serial
+		}
+	case "boolean":
+		if a["office:boolean-value"] == "true" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return text
+}
+
+// cellText collects the paragraphs of a cell, skipping annotations.
+func cellText(dec *xml.Decoder, cell xml.Name) (string, error) {
+	var paras []string
+	var cur strings.Builder
+	inPara, skip := false, 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch qname(t.Name) {
+			case "office:annotation":
+				skip++
+			case "text:p", "text:h":
+				if skip == 0 {
+					inPara = true
+					cur.Reset()
+				}
+			case "text:s":
+				if skip == 0 && inPara {
+					n := repeatCount(attrMap(t.Attr)["text:c"])
+					cur.WriteString(strings.Repeat(" ", min(n, 1024)))
+				}
+			case "text:tab":
+				if skip == 0 && inPara {
+					cur.WriteByte('\t')
+				}
+			case "text:line-break":
+				if skip == 0 && inPara {
+					cur.WriteByte('\n')
+				}
+			}
+		case xml.CharData:
+			if skip == 0 && inPara {
+				cur.Write(t)
+			}
+		case xml.EndElement:
+			switch qname(t.Name) {
+			case "office:annotation":
+				skip--
+			case "text:p", "text:h":
+				if skip == 0 && inPara {
+					paras = append(paras, cur.String())
+					inPara = false
+				}
+			}
+			if t.Name == cell {
+				return strings.Join(paras, "\n"), nil
+			}
+		}
+	}
+}
+
+// flushRow places the queued cells of a row, repeated rows and cells
+// expanding within the grid only. Cells that carry nothing but a style are
+// dropped outside the grid.
+func (im *importer) flushRow() {
+	if im.sheet == nil {
+		return
+	}
+	for i := 0; i < im.rowSpec.repeat; i++ {
+		r := im.row + i
+		if i > 0 && r >= maxRows {
+			break
+		}
+		if im.rowSpec.height > 0 && r < maxRows {
+			im.sheet.RowHeights[r] = im.rowSpec.height
+		}
+		for _, pc := range im.pending {
+			for j := 0; j < pc.repeat; j++ {
+				c := pc.col + j
+				if j > 0 && c >= maxCols {
+					break
+				}
+				cell := pc.cell
+				if !pc.styled && c < len(im.colStyle) {
+					cell.StyleId = im.poolId(im.colStyle[c])
+				}
+				if cell.Raw == "" && (r >= maxRows || c >= maxCols) {
+					continue
+				}
+				ref := sheet.CellRef{Row: r, Col: c}
+				im.sheet.SetCell(ref, cell)
+				if pc.span.Rows > 0 {
+					im.sheet.Merges[ref] = pc.span
+				}
+			}
+		}
+	}
+	im.row += im.rowSpec.repeat
+	im.pending = im.pending[:0]
+}
+
+// settingsHandler reads freeze panes from the per-table view settings. The
+// model only supports freezing the first row/col.
+func (im *importer) settingsHandler() func(dec *xml.Decoder, tok xml.Token) error {
+	var table string
+	items := map[string]int{}
+	return func(dec *xml.Decoder, tok xml.Token) error {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			a := attrMap(t.Attr)
+			switch qname(t.Name) {
+			case "config:config-item-map-entry":
+				// Only table entries are named.
+				if n := a["config:name"]; n != "" {
+					table, items = n, map[string]int{}
+				}
+			case "config:config-item":
+				if table == "" {
+					return nil
+				}
+				var v string
+				if err := dec.DecodeElement(&v, &t); err != nil {
+					return err
+				}
+				n, _ := strconv.Atoi(strings.TrimSpace(v))
+				items[a["config:name"]] = n
+			}
+		case xml.EndElement:
+			if qname(t.Name) != "config:config-item-map-entry" || table == "" {
+				return nil
+			}
+			for _, s := range im.wb.Sheets {
+				if s.Name != table {
+					continue
+				}
+				if items["VerticalSplitMode"] == 2 && items["VerticalSplitPosition"] > 0 {
+					s.FrozenRows = 1
+				}
+				if items["HorizontalSplitMode"] == 2 && items["HorizontalSplitPosition"] > 0 {
+					s.FrozenCols = 1
+				}
+			}
+			table = ""
+		}
+		return nil
+	}
+}
+
+func repeatCount(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// dateToSerial converts an xsd date(Time) to a spreadsheet date serial.
+func dateToSerial(v string) (string, bool) {
+	for _, layout := range []string{time.DateOnly, "2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			days := t.Sub(serialEpoch).Hours() / 24
+			return strconv.FormatFloat(days, 'f', -1, 64), true
+		}
+	}
+	return "", false
+}
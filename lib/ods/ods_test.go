@@ -0,0 +1,190 @@
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func TestFormulaConversion(t *testing.T) {
+	cases := []struct{ model, ods string }{
+		{"=SUM(A1:A2)", "of:=SUM([.A1:.A2])"},
+		{"=IF(A1>0,1,2)", "of:=IF([.A1]>0;1;2)"},
+		{"=Sheet2!$B$3*2", "of:=[Sheet2.$B$3]*2"},
+		{"=SUM('My Sheet'!A1:B2)", "of:=SUM(['My Sheet'.A1:.B2])"},
+		{`=CONCAT("a,b",A1)`, `of:=CONCAT("a,b";[.A1])`},
+		{"=SUM(A:A)", "of:=SUM([.A:.A])"},
+	}
+	for _, tc := range cases {
+		if got := toOpenFormula(tc.model); got != tc.ods {
+			t.Fatalf("toOpenFormula(%q) = %q, want %q", tc.model, got, tc.ods)
+		}
+		if got := fromOpenFormula(tc.ods); got != tc.model {
+			t.Fatalf("fromOpenFormula(%q) = %q, want %q", tc.ods, got, tc.model)
+		}
+	}
+	// LibreOffice writes the sheet on both ends of a range, and "$" before
+	// absolute sheet names.
+	if got := fromOpenFormula("of:=SUM([$Sheet2.A1:$Sheet2.B2];['It''s'.C1])"); got != "=SUM(Sheet2!A1:B2,'It''s'!C1)" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestLengthToPx(t *testing.T) {
+	cases := map[string]int{"1in": 96, "2.54cm": 96, "72pt": 96, "25.4mm": 96, "100px": 100, "bogus": 0, "-1in": 0}
+	for in, want := range cases {
+		if got := lengthToPx(in); got != want {
+			t.Fatalf("lengthToPx(%q) = %d, want %d", in, got, want)
+		}
+	}
+	if got := lengthToPx(pxToLength(137)); got != 137 {
+		t.Fatalf("round trip = %d", got)
+	}
+}
+
+func TestParagraph(t *testing.T) {
+	if got := paragraph(" a  b\tc <d> "); got != `<text:s text:c="1"/>a<text:s text:c="2"/>b<text:tab/>c &lt;d&gt;<text:s text:c="1"/>` {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	s := wb.AddSheet("s1", "Data")
+	bold := wb.Styles.Put(sheet.Style{Props: map[string]string{"bold": "1", "bg": "#ff0", "align": "center", "border": "all", "fontFamily": "Arial", "fontSize": "14"}})
+	pct := wb.Styles.Put(sheet.Style{Props: map[string]string{"numFmt": "percent:1"}})
+	date := wb.Styles.Put(sheet.Style{Props: map[string]string{"numFmt": "date"}})
+	s.SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "Title  <1>", StyleId: bold})
+	s.SetCell(sheet.CellRef{Row: 1, Col: 0}, sheet.Cell{Raw: "0.125", StyleId: pct})
+	s.SetCell(sheet.CellRef{Row: 1, Col: 1}, sheet.Cell{Raw: "45352", StyleId: date})
+	s.SetCell(sheet.CellRef{Row: 2, Col: 0}, sheet.Cell{Raw: "=SUM(A2,Other!A1)", Value: "1.125"})
+	s.SetCell(sheet.CellRef{Row: 3, Col: 3}, sheet.Cell{Raw: "two\nlines"})
+	s.Merges[sheet.CellRef{Row: 0, Col: 0}] = sheet.Span{Rows: 1, Cols: 3}
+	s.ColWidths[1] = 150
+	s.RowHeights[3] = 40
+	s.FrozenRows, s.FrozenCols = 1, 1
+	wb.AddSheet("s2", "Other").SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "1"})
+
+	data, err := Export(wb)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Fatalf("mimetype must be the first, stored entry: %+v", first.FileHeader)
+	}
+
+	snap, err := Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	got := sheet.WorkbookFromSnapshot(snap)
+	if len(got.Sheets) != 2 || got.Sheets[0].Name != "Data" || got.Sheets[1].Name != "Other" {
+		t.Fatalf("sheets: %+v", snap.Sheets)
+	}
+	gs := got.Sheets[0]
+	props := func(ref sheet.CellRef) map[string]string {
+		st, _ := got.Styles.Get(gs.GetCell(ref).StyleId)
+		return st.Props
+	}
+	if c := gs.GetCell(sheet.CellRef{Row: 0, Col: 0}); c.Raw != "Title  <1>" {
+		t.Fatalf("A1 = %+v", c)
+	}
+	if p := props(sheet.CellRef{Row: 0, Col: 0}); len(p) != 6 || p["bg"] != "#ffff00" || p["align"] != "center" || p["fontSize"] != "14" {
+		t.Fatalf("A1 props = %v", p)
+	}
+	if c := gs.GetCell(sheet.CellRef{Row: 1, Col: 0}); c.Raw != "0.125" || props(sheet.CellRef{Row: 1, Col: 0})["numFmt"] != "percent:1" {
+		t.Fatalf("A2 = %+v", c)
+	}
+	if c := gs.GetCell(sheet.CellRef{Row: 1, Col: 1}); c.Raw != "45352" || props(sheet.CellRef{Row: 1, Col: 1})["numFmt"] != "date" {
+		t.Fatalf("B2 = %+v", c)
+	}
+	if c := gs.GetCell(sheet.CellRef{Row: 2, Col: 0}); c.Raw != "=SUM(A2,Other!A1)" {
+		t.Fatalf("A3 = %+v", c)
+	}
+	if c := gs.GetCell(sheet.CellRef{Row: 3, Col: 3}); c.Raw != "two\nlines" {
+		t.Fatalf("D4 = %+v", c)
+	}
+	if sp := gs.Merges[sheet.CellRef{Row: 0, Col: 0}]; sp != (sheet.Span{Rows: 1, Cols: 3}) || len(gs.Merges) != 1 {
+		t.Fatalf("merges = %v", gs.Merges)
+	}
+	if gs.ColWidths[1] != 150 || len(gs.ColWidths) != 1 || gs.RowHeights[3] != 40 || len(gs.RowHeights) != 1 {
+		t.Fatalf("dims = %v %v", gs.ColWidths, gs.RowHeights)
+	}
+	if gs.FrozenRows != 1 || gs.FrozenCols != 1 || got.Sheets[1].FrozenRows != 0 {
+		t.Fatal("freeze panes lost")
+	}
+}
+
+// TestImportRepeats mimics what office suites write: whole-column styles and
+// runs of empty rows repeated to the end of the sheet.
+func TestImportRepeats(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0">
+<office:automatic-styles>
+<style:style style:name="ce1" style:family="table-cell"><style:text-properties fo:font-style="italic"/></style:style>
+</office:automatic-styles>
+<office:body><office:spreadsheet><table:table table:name="S">
+<table:table-column table:default-cell-style-name="ce1"/><table:table-column table:number-columns-repeated="16383"/>
+<table:table-row><table:table-cell office:value-type="string"><text:p>x<text:s/>y</text:p><office:annotation><text:p>note</text:p></office:annotation></table:table-cell><table:table-cell table:number-columns-repeated="3" office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell><table:table-cell table:number-columns-repeated="16380"/></table:table-row>
+<table:table-row table:number-rows-repeated="1048575"><table:table-cell table:number-columns-repeated="16384"/></table:table-row>
+</table:table></office:spreadsheet></office:body></office:document-content>`
+	snap, err := Import(bytes.NewReader(zipOf(t, map[string]string{"content.xml": content})))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	var filled, styled int
+	for _, c := range snap.Sheets[0].Cells {
+		switch {
+		case c.Row == 0 && c.Col == 0:
+			if c.Raw != "x y" || c.StyleId == 0 {
+				t.Fatalf("A1 = %+v", c)
+			}
+		case c.Raw == "TRUE" && c.Row == 0 && c.StyleId == 0:
+			filled++
+		case c.Raw == "" && c.Col == 0 && c.StyleId != 0:
+			styled++
+		default:
+			t.Fatalf("unexpected cell %+v", c)
+		}
+	}
+	// The column style covers column A within the grid only.
+	if filled != 3 || styled != maxRows-1 {
+		t.Fatalf("filled %d, styled %d", filled, styled)
+	}
+}
+
+func TestImportRejectsGarbage(t *testing.T) {
+	if _, err := Import(strings.NewReader("not a zip")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := Import(bytes.NewReader(zipOf(t, map[string]string{"mimetype": mimeType}))); err == nil {
+		t.Fatal("expected an error for a missing content.xml")
+	}
+}
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,238 @@
+package ods
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// Mapping between the sheet model's allowlisted style props (see
+// sheet.ValidateProps) and ODF automatic styles / lengths.
+
+// ODF lengths carry a unit; the model stores CSS pixels (96 per inch).
+var pxPerUnit = map[string]float64{
+	"in": 96, "cm": 96 / 2.54, "mm": 96 / 25.4, "pt": 96.0 / 72, "pc": 16, "px": 1,
+}
+
+// pxToLength formats pixels as an ODF length in inches.
+func pxToLength(px int) string {
+	return strconv.FormatFloat(float64(px)/96, 'f', 4, 64) + "in"
+}
+
+// lengthToPx parses an ODF length ("2.258cm", "0.1776in", "12pt"), returning
+// 0 when it is malformed.
+func lengthToPx(length string) int {
+	for unit, factor := range pxPerUnit {
+		if num, ok := strings.CutSuffix(length, unit); ok {
+			v, err := strconv.ParseFloat(num, 64)
+			if err != nil || v < 0 {
+				return 0
+			}
+			return int(math.Round(v * factor))
+		}
+	}
+	return 0
+}
+
+// expandHex turns a model color ("#abc" or "#aabbcc") into the six-digit form
+// ODF requires.
+func expandHex(c string) string {
+	if len(c) == 4 {
+		return string([]byte{'#', c[1], c[1], c[2], c[2], c[3], c[3]})
+	}
+	return strings.ToLower(c)
+}
+
+// cellStyle is one table-cell automatic style split by the property elements
+// ODF puts them in, as attribute name/value pairs in a fixed order.
+type cellStyle struct {
+	cell, text, paragraph [][2]string
+}
+
+// propsToStyle maps model props to ODF formatting attributes. The numFmt is
+// written separately as a data style.
+func propsToStyle(props map[string]string) cellStyle {
+	var st cellStyle
+	if props["bold"] == "1" {
+		st.text = append(st.text, [2]string{"fo:font-weight", "bold"})
+	}
+	if props["italic"] == "1" {
+		st.text = append(st.text, [2]string{"fo:font-style", "italic"})
+	}
+	if props["underline"] == "1" {
+		st.text = append(st.text,
+			[2]string{"style:text-underline-style", "solid"},
+			[2]string{"style:text-underline-width", "auto"},
+			[2]string{"style:text-underline-color", "font-color"})
+	}
+	if props["strike"] == "1" {
+		st.text = append(st.text, [2]string{"style:text-line-through-style", "solid"})
+	}
+	if c := props["color"]; c != "" {
+		st.text = append(st.text, [2]string{"fo:color", expandHex(c)})
+	}
+	if fam := props["fontFamily"]; fam != "" {
+		st.text = append(st.text, [2]string{"fo:font-family", fam})
+	}
+	if sz := props["fontSize"]; sz != "" {
+		st.text = append(st.text, [2]string{"fo:font-size", sz + "pt"})
+	}
+	if bg := props["bg"]; bg != "" {
+		st.cell = append(st.cell, [2]string{"fo:background-color", expandHex(bg)})
+	}
+	if props["border"] == "all" {
+		st.cell = append(st.cell, [2]string{"fo:border", "0.06pt solid #000000"})
+	}
+	if v := props["valign"]; v != "" {
+		st.cell = append(st.cell, [2]string{"style:vertical-align", v})
+	}
+	if props["wrap"] == "1" {
+		st.cell = append(st.cell, [2]string{"fo:wrap-option", "wrap"})
+	}
+	if a := props["align"]; a != "" {
+		st.cell = append(st.cell, [2]string{"style:text-align-source", "fix"})
+		st.paragraph = append(st.paragraph, [2]string{"fo:text-align", map[string]string{"left": "start", "center": "center", "right": "end"}[a]})
+	}
+	return st
+}
+
+// styleToProps converts ODF formatting attributes (keyed "prefix:local") back
+// to model props. Only values the allowlist accepts are kept.
+func styleToProps(attrs map[string]string) map[string]string {
+	props := map[string]string{}
+	if w := attrs["fo:font-weight"]; w == "bold" || w == "700" || w == "800" || w == "900" {
+		props["bold"] = "1"
+	}
+	if s := attrs["fo:font-style"]; s == "italic" || s == "oblique" {
+		props["italic"] = "1"
+	}
+	if u := attrs["style:text-underline-style"]; u != "" && u != "none" {
+		props["underline"] = "1"
+	}
+	if s := attrs["style:text-line-through-style"]; s != "" && s != "none" {
+		props["strike"] = "1"
+	}
+	if c := normalizeHex(attrs["fo:color"]); c != "" {
+		props["color"] = c
+	}
+	if fam := attrs["fo:font-family"]; fam != "" {
+		props["fontFamily"] = strings.Trim(fam, `'"`)
+	} else if name := attrs["style:font-name"]; name != "" {
+		props["fontFamily"] = strings.Trim(name, `'"`)
+	}
+	if sz, ok := strings.CutSuffix(attrs["fo:font-size"], "pt"); ok {
+		if v, err := strconv.ParseFloat(sz, 64); err == nil {
+			props["fontSize"] = strconv.Itoa(int(math.Round(v)))
+		}
+	}
+	if c := normalizeHex(attrs["fo:background-color"]); c != "" {
+		props["bg"] = c
+	}
+	if b := attrs["fo:border"]; b != "" && b != "none" {
+		props["border"] = "all"
+	} else if hasAllBorders(attrs) {
+		props["border"] = "all"
+	}
+	if v := attrs["style:vertical-align"]; v == "top" || v == "middle" || v == "bottom" {
+		props["valign"] = v
+	}
+	if attrs["fo:wrap-option"] == "wrap" {
+		props["wrap"] = "1"
+	}
+	switch attrs["fo:text-align"] {
+	case "start", "left":
+		props["align"] = "left"
+	case "center":
+		props["align"] = "center"
+	case "end", "right":
+		props["align"] = "right"
+	}
+	// Final gate: uploaded files are untrusted and props become inline CSS on
+	// every viewer's DOM, so re-check against the same allowlist ops go through.
+	for k, v := range props {
+		if sheet.ValidateProps(map[string]string{k: v}) != nil {
+			delete(props, k)
+		}
+	}
+	return props
+}
+
+func hasAllBorders(attrs map[string]string) bool {
+	for _, side := range []string{"top", "bottom", "left", "right"} {
+		if b := attrs["fo:border-"+side]; b == "" || b == "none" {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeHex returns "#rrggbb" for a six-digit ODF color, or "" (ODF also
+// allows "transparent").
+func normalizeHex(c string) string {
+	if len(c) != 7 || c[0] != '#' {
+		return ""
+	}
+	for _, r := range c[1:] {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+			return ""
+		}
+	}
+	return strings.ToLower(c)
+}
+
+// dataStyle renders the number:*-style element for a numFmt, or "" for
+// general. ODF ties value types to data styles, so valueType reports the
+// office:value-type numeric cells in this style must use.
+func dataStyle(name, numFmt string) (xml, valueType string) {
+	kind, dec, hasDec := strings.Cut(numFmt, ":")
+	digits := ""
+	if hasDec {
+		digits = fmt.Sprintf(` number:decimal-places="%s" number:min-decimal-places="%s"`, dec, dec)
+	}
+	switch kind {
+	case "text":
+		return `<number:text-style style:name="` + name + `"><number:text-content/></number:text-style>`, ""
+	case "date":
+		return `<number:date-style style:name="` + name + `"><number:month/><number:text>/</number:text><number:day/><number:text>/</number:text><number:year number:style="long"/></number:date-style>`, "date"
+	case "number":
+		return `<number:number-style style:name="` + name + `"><number:number` + digits + ` number:min-integer-digits="1" number:grouping="true"/></number:number-style>`, "float"
+	case "currency":
+		if !hasDec {
+			digits = ` number:decimal-places="2" number:min-decimal-places="2"` // Intl currency default
+		}
+		return `<number:currency-style style:name="` + name + `"><number:currency-symbol number:language="en" number:country="US">$</number:currency-symbol><number:number` + digits + ` number:min-integer-digits="1" number:grouping="true"/></number:currency-style>`, "currency"
+	case "percent":
+		return `<number:percentage-style style:name="` + name + `"><number:number` + digits + ` number:min-integer-digits="1"/><number:text>%</number:text></number:percentage-style>`, "percentage"
+	}
+	return "", ""
+}
+
+// dataStyleToNumFmt classifies a number:*-style element back into the model
+// vocabulary from its local name and its number child: decimal places (-1
+// when unset) and thousands grouping. A plain number style without either is
+// how ODF spells general; "" means general / unrepresentable.
+func dataStyleToNumFmt(local string, decimals int, grouping bool) string {
+	suffix := ""
+	if decimals >= 0 {
+		suffix = fmt.Sprintf(":%d", min(decimals, 99))
+	}
+	switch local {
+	case "text-style":
+		return "text"
+	case "date-style":
+		return "date"
+	case "number-style":
+		if decimals < 0 && !grouping {
+			return ""
+		}
+		return "number" + suffix
+	case "currency-style":
+		return "currency" + suffix
+	case "percentage-style":
+		return "percent" + suffix
+	}
+	return ""
+}
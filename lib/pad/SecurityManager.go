@@ -1,6 +1,7 @@
 package pad
 
 import (
+	"context"
 	"errors"
 	"strings"
 
@@ -38,13 +39,19 @@ type GrantedAccess struct {
 }
 
 func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, token *string, userSettings *webaccess.SocketClientRequest) (*GrantedAccess, error) {
+	return s.CheckAccessContext(context.Background(), padId, sessionCookie, token, userSettings)
+}
+
+// CheckAccessContext is CheckAccess with the onAccessCheck and getAuthorId
+// hooks and the database calls traced as children of the span in ctx.
+func (s *SecurityManager) CheckAccessContext(ctx context.Context, padId *string, sessionCookie *string, token *string, userSettings *webaccess.SocketClientRequest) (*GrantedAccess, error) {
 	if padId == nil {
 		return nil, errors.New("padId is nil")
 	}
 	var canCreate = !settings.Displayed.EditOnly
 	if s.ReadOnlyManager.IsReadOnlyID(padId) {
 		canCreate = false
-		foundPadId, err := s.ReadOnlyManager.GetPadId(*padId)
+		foundPadId, err := s.ReadOnlyManager.WithContext(ctx).GetPadId(*padId)
 
 		if err != nil {
 			return nil, errors.New("padId not found")
@@ -62,14 +69,14 @@ func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, toke
 			cookie = *sessionCookie
 		}
 		accessCtx := &events.OnAccessCheckContext{PadId: *padId, Token: tok, SessionCookie: cookie}
-		s.hooks.ExecuteOnAccessCheckHooks(accessCtx)
+		s.hooks.ExecuteHooksContext(ctx, hooks.OnAccessCheckString, accessCtx)
 		if accessCtx.Denied() {
 			return nil, errors.New("access denied: onAccessCheck hook denied access")
 		}
 	}
 
 	if settings.Displayed.LoadTest {
-		authorId, err := s.resolveAuthorId(token, userSettings)
+		authorId, err := s.resolveAuthorId(ctx, token, userSettings)
 		if err != nil {
 			return nil, errors.New("access denied: invalid author token" + err.Error())
 		}
@@ -109,7 +116,7 @@ func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, toke
 		}
 	}
 
-	var padExists, err = s.PadManager.DoesPadExist(*padId)
+	var padExists, err = s.PadManager.DoesPadExistContext(ctx, *padId)
 	if err != nil {
 		println("An error occurred while checking pad existence:", err.Error())
 		return nil, errors.New("internal error while checking pad existence")
@@ -121,7 +128,7 @@ func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, toke
 
 	var splittedPadId = strings.Split(*padId, "$")[0]
 
-	var sessionAuthorID = s.SessionManager.findAuthorID(splittedPadId, sessionCookie)
+	var sessionAuthorID = s.SessionManager.WithContext(ctx).findAuthorID(splittedPadId, sessionCookie)
 
 	if settings.Displayed.RequireSession && sessionAuthorID == nil {
 		return nil, errors.New("access denied: HTTP API session is required")
@@ -131,7 +138,7 @@ func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, toke
 		return nil, errors.New("invalid author token")
 	}
 
-	authorId, err := s.resolveAuthorId(token, userSettings)
+	authorId, err := s.resolveAuthorId(ctx, token, userSettings)
 	if err != nil {
 		println("An error occurred while retrieving author from token:", err.Error())
 		return nil, errors.New("access denied: invalid author token")
@@ -150,7 +157,7 @@ func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, toke
 		return &grantedAccess, nil
 	}
 
-	var pad, _ = s.PadManager.GetPad(*padId, nil, nil)
+	var pad, _ = s.PadManager.GetPadContext(ctx, *padId, nil, nil)
 
 	if !pad.PublicStatus && sessionAuthorID == nil {
 		return nil, errors.New("must have an HTTP API session to access private group pads")
@@ -162,19 +169,19 @@ func (s *SecurityManager) CheckAccess(padId *string, sessionCookie *string, toke
 // resolveAuthorId resolves the author id for a token, first giving getAuthorId
 // hooks a chance to supply/override it (first non-empty wins), then falling back
 // to the database token->author mapping.
-func (s *SecurityManager) resolveAuthorId(token *string, userSettings *webaccess.SocketClientRequest) (string, error) {
+func (s *SecurityManager) resolveAuthorId(ctx context.Context, token *string, userSettings *webaccess.SocketClientRequest) (string, error) {
 	var tok string
 	if token != nil {
 		tok = *token
 	}
 	if s.hooks != nil {
 		idCtx := &events.GetAuthorIdContext{Token: tok, User: userSettings}
-		s.hooks.ExecuteGetAuthorIdHooks(idCtx)
+		s.hooks.ExecuteHooksContext(ctx, hooks.GetAuthorIdString, idCtx)
 		if idCtx.AuthorId() != "" {
 			return idCtx.AuthorId(), nil
 		}
 	}
-	retrievedAuthor, err := s.AuthorManager.GetAuthorId(tok)
+	retrievedAuthor, err := s.AuthorManager.WithContext(ctx).GetAuthorId(tok)
 	if err != nil {
 		return "", err
 	}
@@ -184,7 +191,7 @@ func (s *SecurityManager) resolveAuthorId(token *string, userSettings *webaccess
 func (s *SecurityManager) HasPadAccess(ctx fiber.Ctx) bool {
 	tokenCookie := ctx.Cookies("token")
 	padId := ctx.Params("pad")
-	accessStatus, err := s.CheckAccess(&padId, nil, &tokenCookie, nil)
+	accessStatus, err := s.CheckAccessContext(ctx.Context(), &padId, nil, &tokenCookie, nil)
 	if err != nil {
 		return false
 	}
@@ -1,6 +1,7 @@
 package pad
 
 import (
+	"context"
 	"encoding/json"
 	"regexp"
 	"slices"
@@ -42,6 +43,12 @@ func NewSessionManager(db db.DataStore) *SessionManager {
 	}
 }
 
+// WithContext returns a view of the manager whose database calls are traced
+// as children of the span in ctx.
+func (sm *SessionManager) WithContext(ctx context.Context) *SessionManager {
+	return &SessionManager{db.WithContext(sm.db, ctx)}
+}
+
 // CreateSession stores a new API session and registers it in the group and
 // author listings. Validation of group/author existence and expiry is the
 // caller's responsibility (the API layer mirrors the original's checks).
@@ -0,0 +1,110 @@
+package pad
+
+import (
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	dbModel "github.com/ether/etherpad-go/lib/models/db"
+	"github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChatPad(t *testing.T) (*db.MemoryDataStore, *pad.Pad) {
+	t.Helper()
+	store := db.NewMemoryDataStore()
+	for id, name := range map[string]string{"a.ann": "Ann", "a.annlee": "Ann Lee", "a.bob": "bob"} {
+		require.NoError(t, store.SaveAuthor(dbModel.AuthorDB{ID: id, Name: &name}))
+	}
+	createdHooks := hooks.NewHook()
+	manager := NewManager(store, &createdHooks)
+	text := "hello\n"
+	author := "a.ann"
+	retrievedPad, err := manager.GetPad("chat", &text, &author)
+	require.NoError(t, err)
+	// Mentions resolve against the authors of the pad.
+	for _, id := range []string{"a.annlee", "a.bob"} {
+		require.NoError(t, retrievedPad.SpliceText(0, 0, "x", &id))
+	}
+	return store, retrievedPad
+}
+
+func TestMentionedAuthors(t *testing.T) {
+	names := map[string]string{"a.ann": "Ann", "a.annlee": "Ann Lee", "a.bob": "bob", "a.noname": ""}
+	assert.Equal(t, []string{"a.annlee", "a.bob"}, pad.MentionedAuthors("@ann lee and @Bob, see this", names))
+	assert.Equal(t, []string{"a.ann"}, pad.MentionedAuthors("@Ann: @ann!", names))
+	assert.Equal(t, []string{"a.noname"}, pad.MentionedAuthors("ping @a.noname", names))
+	assert.Empty(t, pad.MentionedAuthors("mail ann@bob.org or @bobby or @", names))
+}
+
+func TestChatRepliesEditsAndMentions(t *testing.T) {
+	_, p := newChatPad(t)
+	ann, bob := "a.ann", "a.bob"
+
+	first, err := p.PostChatMessage(&ann, 1, "hi @bob and @Ann", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.bob"}, first.Mentions, "authors do not mention themselves")
+
+	reply, err := p.PostChatMessage(&bob, 2, "hi @Ann Lee", &first.Head)
+	require.NoError(t, err)
+	require.NotNil(t, reply.ReplyTo)
+	assert.Equal(t, first.Head, *reply.ReplyTo)
+	_, err = p.PostChatMessage(&bob, 3, "?", new(int))
+	require.NoError(t, err)
+	missing := 42
+	_, err = p.PostChatMessage(&bob, 3, "?", &missing)
+	assert.ErrorIs(t, err, pad.ErrChatMessageNotFound)
+
+	unread, err := p.UnreadChatMentions(bob)
+	require.NoError(t, err)
+	assert.Equal(t, 1, unread)
+
+	_, err = p.EditChatMessage(first.Head, bob, 4, "not mine")
+	assert.ErrorIs(t, err, pad.ErrChatNotAuthor)
+	_, err = p.EditChatMessage(first.Head, ann, 4, " ")
+	assert.ErrorIs(t, err, pad.ErrChatTextEmpty)
+	edited, err := p.EditChatMessage(first.Head, ann, 4, "hi everyone")
+	require.NoError(t, err)
+	assert.Equal(t, "hi everyone", edited.Message)
+	assert.Empty(t, edited.Mentions)
+	edits, err := p.GetChatMessageEdits(first.Head)
+	require.NoError(t, err)
+	require.Len(t, *edits, 1)
+	assert.Equal(t, "hi @bob and @Ann", (*edits)[0].Message)
+	unread, _ = p.UnreadChatMentions(bob)
+	assert.Equal(t, 0, unread, "the edit removed the mention")
+
+	require.NoError(t, p.MarkChatRead("a.annlee", 99))
+	unread, _ = p.UnreadChatMentions("a.annlee")
+	assert.Equal(t, 0, unread)
+}
+
+func TestChatDelete(t *testing.T) {
+	_, p := newChatPad(t)
+	ann, bob := "a.ann", "a.bob"
+	msg, err := p.PostChatMessage(&ann, 1, "oops", nil)
+	require.NoError(t, err)
+
+	_, err = p.DeleteChatMessage(msg.Head, &bob, false, 2)
+	assert.ErrorIs(t, err, pad.ErrChatNotAllowed)
+	deleted, err := p.DeleteChatMessage(msg.Head, &bob, true, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "", deleted.Message)
+	assert.Equal(t, &bob, deleted.DeletedBy)
+	_, err = p.DeleteChatMessage(msg.Head, &ann, false, 3)
+	assert.ErrorIs(t, err, pad.ErrChatMessageDeleted)
+	_, err = p.EditChatMessage(msg.Head, ann, 3, "back")
+	assert.ErrorIs(t, err, pad.ErrChatMessageDeleted)
+	_, err = p.PostChatMessage(&bob, 3, "reply", &msg.Head)
+	assert.ErrorIs(t, err, pad.ErrChatMessageDeleted)
+
+	own, err := p.PostChatMessage(&ann, 4, "mine", nil)
+	require.NoError(t, err)
+	_, err = p.DeleteChatMessage(own.Head, &ann, false, 5)
+	require.NoError(t, err)
+	api, err := p.PostChatMessage(&ann, 6, "by the api", nil)
+	require.NoError(t, err)
+	_, err = p.DeleteChatMessage(api.Head, nil, false, 7)
+	require.NoError(t, err)
+}
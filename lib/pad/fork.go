@@ -0,0 +1,412 @@
+package pad
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	db2 "github.com/ether/etherpad-go/lib/models/db"
+	"github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/paddiff"
+)
+
+var ErrNotAFork = errors.New("pad is not a fork")
+var ErrForkSourceMissing = errors.New("the pad the fork was made from does not exist")
+var ErrNothingToMerge = errors.New("the fork has no changes to merge")
+
+// Conflict is a region of the text at the fork base that both the source and
+// the fork changed. Start and End are character offsets into that text.
+type Conflict struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// MergeConflictError is returned by ApplyMerge when the source and the fork
+// changed the same regions and the merge is not forced.
+type MergeConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *MergeConflictError) Error() string {
+	return fmt.Sprintf("the fork conflicts with its source in %d places", len(e.Conflicts))
+}
+
+// Merge is a three-way merge of a fork into its source, prepared by
+// PrepareMerge.
+type Merge struct {
+	Source *pad.Pad
+	Fork   *pad.Pad
+	// Changeset applies the changes of the fork to the source head. Its
+	// attributes are in the pool of the source.
+	Changeset string
+	Conflicts []Conflict
+
+	forkChanges string
+	// sync applies the changes of the source to the fork head, so that both
+	// have the same text after the merge.
+	sync string
+}
+
+// MarkFork records that fork at its current head has the text of the pad
+// sourceId at sourceRev.
+func (m *Manager) MarkFork(fork *pad.Pad, sourceId string, sourceRev int) error {
+	fork.Fork = &db2.PadFork{Source: sourceId, SourceRev: sourceRev, Rev: fork.Head}
+	return fork.Save()
+}
+
+// ForkChain returns the id of p and of the pads it was forked from, nearest
+// first. It ends at a source that no longer exists.
+func (m *Manager) ForkChain(p *pad.Pad) ([]string, error) {
+	chain := []string{p.Id}
+	for p.Fork != nil && !slices.Contains(chain, p.Fork.Source) {
+		exists, err := m.DoesPadExist(p.Fork.Source)
+		if err != nil {
+			return nil, err
+		}
+		if !*exists {
+			break
+		}
+		if p, err = m.GetPad(p.Fork.Source, nil, nil); err != nil {
+			return nil, err
+		}
+		chain = append(chain, p.Id)
+	}
+	return chain, nil
+}
+
+// PrepareMerge computes the changes of the source and of the fork since the
+// revisions they last had the same text, and rebases the changes of the fork
+// onto the source head with Follow. Regions both changed are reported as
+// conflicts.
+func (m *Manager) PrepareMerge(fork *pad.Pad) (*Merge, error) {
+	if fork.Fork == nil {
+		return nil, ErrNotAFork
+	}
+	exists, err := m.DoesPadExist(fork.Fork.Source)
+	if err != nil {
+		return nil, err
+	}
+	if !*exists {
+		return nil, ErrForkSourceMissing
+	}
+	source, err := m.GetPad(fork.Fork.Source, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if fork.Fork.Merge != nil {
+		if err := resumeMerge(source, fork); err != nil {
+			return nil, err
+		}
+	}
+
+	base, sourceChanges, err := mergeBase(source, fork)
+	if err != nil {
+		return nil, err
+	}
+	forkChanges, err := composeRevisions(fork, fork.Fork.Rev, fork.Head, utf8.RuneCountInString(base))
+	if err != nil {
+		return nil, err
+	}
+	forkChanges = changeset.MoveOpsToNewPool(forkChanges, &fork.Pool, &source.Pool)
+
+	conflicts, err := findConflicts(sourceChanges, forkChanges, base)
+	if err != nil {
+		return nil, err
+	}
+	// As for USER_CHANGES, the source side wins ties on the server and the
+	// fork side is rebased the other way round, so both converge.
+	merged, err := changeset.Follow(sourceChanges, forkChanges, false, &source.Pool)
+	if err != nil {
+		return nil, fmt.Errorf("error rebasing %s onto %s: %w", fork.Id, source.Id, err)
+	}
+	sync, err := changeset.Follow(forkChanges, sourceChanges, true, &source.Pool)
+	if err != nil {
+		return nil, fmt.Errorf("error rebasing %s onto %s: %w", source.Id, fork.Id, err)
+	}
+
+	return &Merge{
+		Source:      source,
+		Fork:        fork,
+		Changeset:   *merged,
+		Conflicts:   conflicts,
+		forkChanges: forkChanges,
+		sync:        *sync,
+	}, nil
+}
+
+// Preview returns the diff between the source head and the merged text, as
+// paddiff builds it for diffHTML, and the authors of the diff.
+func (merge *Merge) Preview(authorId string) (*apool.AText, []string, error) {
+	preview := mergedPad{
+		Pad: merge.Source,
+		merge: db2.PadSingleRevision{
+			PadId:     merge.Source.Id,
+			RevNum:    merge.Source.Head + 1,
+			Changeset: merge.Changeset,
+			AuthorId:  &authorId,
+		},
+	}
+	return paddiff.CreateDiffAText(preview, &merge.Source.Pool, merge.Source.Head, merge.Source.Head+1)
+}
+
+// ApplyMerge appends the merge to the source as one revision by authorId.
+// The fork gets the changes of the source too, and its base moves to the new
+// heads, so a later merge only brings the changes made since. It refuses
+// conflicting merges unless force is set, and returns the new head of the
+// source.
+//
+// The merge is recorded on the fork before it is written, so if writing
+// fails halfway the next PrepareMerge sees what was applied and does not
+// apply it again.
+func (m *Manager) ApplyMerge(merge *Merge, authorId string, force bool) (int, error) {
+	if len(merge.Conflicts) > 0 && !force {
+		return 0, &MergeConflictError{Conflicts: merge.Conflicts}
+	}
+	if isIdentity(merge.forkChanges) {
+		return 0, ErrNothingToMerge
+	}
+
+	pending := *merge.Fork.Fork
+	pending.Merge = &db2.PadForkMerge{
+		SourceRev: merge.Source.Head,
+		Rev:       merge.Fork.Head,
+		Changeset: merge.Changeset,
+		Sync:      merge.sync,
+	}
+	merge.Fork.Fork = &pending
+	if err := merge.Fork.Save(); err != nil {
+		return 0, err
+	}
+
+	if _, err := merge.Source.AppendRevision(merge.Changeset, &authorId); err != nil {
+		return 0, err
+	}
+	sync := changeset.MoveOpsToNewPool(merge.sync, &merge.Source.Pool, &merge.Fork.Pool)
+	if _, err := merge.Fork.AppendRevision(sync, &authorId); err != nil {
+		return 0, err
+	}
+	if err := m.MarkFork(merge.Fork, merge.Source.Id, merge.Source.Head); err != nil {
+		return 0, err
+	}
+	return merge.Source.Head, nil
+}
+
+// resumeMerge records the outcome of a merge ApplyMerge did not finish. If
+// the source got the merge, the base moves past it so it is not applied
+// again. The fork gets the changes of the source unless it was edited since;
+// then they stay in Sync for the next merge.
+func resumeMerge(source *pad.Pad, fork *pad.Pad) error {
+	pending := fork.Fork.Merge
+	next := *fork.Fork
+	next.Merge = nil
+
+	sourceRev, applied, err := appendedAfter(source, pending.SourceRev, pending.Changeset)
+	if err != nil {
+		return err
+	}
+	if applied {
+		sync := changeset.MoveOpsToNewPool(pending.Sync, &source.Pool, &fork.Pool)
+		rev, synced, err := appendedAfter(fork, pending.Rev, sync)
+		if err != nil {
+			return err
+		}
+		if !synced && fork.Head == pending.Rev {
+			authorId, err := source.GetRevisionAuthor(sourceRev)
+			if err != nil {
+				return err
+			}
+			if _, err := fork.AppendRevision(sync, authorId); err != nil {
+				return err
+			}
+			rev, synced = fork.Head, true
+		}
+		next.SourceRev, next.Rev, next.Sync = sourceRev, pending.Rev, pending.Sync
+		if synced {
+			next.Rev, next.Sync = rev, ""
+		}
+	}
+	fork.Fork = &next
+	return fork.Save()
+}
+
+// appendedAfter reports whether cs is the revision of p after rev, and
+// returns the revision p has the changes of cs at. Identity changesets are
+// never appended, so they count as applied at rev.
+func appendedAfter(p *pad.Pad, rev int, cs string) (int, bool, error) {
+	if isIdentity(cs) {
+		return rev, true, nil
+	}
+	if p.Head <= rev {
+		return rev, false, nil
+	}
+	appended, err := p.GetRevisionChangeset(rev + 1)
+	if err != nil {
+		return rev, false, err
+	}
+	return rev + 1, *appended == cs, nil
+}
+
+// mergeBase returns the text the source and the fork last had in common and
+// the changes of the source since, as a changeset to that text.
+func mergeBase(source *pad.Pad, fork *pad.Pad) (string, string, error) {
+	base := source.GetInternalRevisionAText(fork.Fork.SourceRev)
+	if base == nil {
+		return "", "", fmt.Errorf("could not retrieve atext for revision %d of %s", fork.Fork.SourceRev, source.Id)
+	}
+	changes, err := composeRevisions(source, fork.Fork.SourceRev, source.Head, utf8.RuneCountInString(base.Text))
+	if err != nil {
+		return "", "", err
+	}
+	if fork.Fork.Sync == "" {
+		return base.Text, changes, nil
+	}
+
+	// The fork lacks the changes of the source in Sync, so the common text
+	// is the fork at Rev.
+	forkBase := fork.GetInternalRevisionAText(fork.Fork.Rev)
+	if forkBase == nil {
+		return "", "", fmt.Errorf("could not retrieve atext for revision %d of %s", fork.Fork.Rev, fork.Id)
+	}
+	composed, err := changeset.Compose(fork.Fork.Sync, changes, &source.Pool)
+	if err != nil {
+		return "", "", fmt.Errorf("error composing the changes %s lacks: %w", fork.Id, err)
+	}
+	return forkBase.Text, *composed, nil
+}
+
+// mergedPad is the source of a merge with the merge appended as the next
+// revision, for paddiff.
+type mergedPad struct {
+	*pad.Pad
+	merge db2.PadSingleRevision
+}
+
+func (p mergedPad) GetRevision(revNumber int) (*db2.PadSingleRevision, error) {
+	if revNumber == p.merge.RevNum {
+		return &p.merge, nil
+	}
+	return p.Pad.GetRevision(revNumber)
+}
+
+// composeRevisions composes the changesets of the revisions after from up to
+// to into one changeset relative to the text at from, which has length chars.
+func composeRevisions(p *pad.Pad, from int, to int, length int) (string, error) {
+	composed := changeset.Identity(length)
+	for rev := from + 1; rev <= to; rev++ {
+		cs, err := p.GetRevisionChangeset(rev)
+		if err != nil {
+			return "", err
+		}
+		next, err := changeset.Compose(composed, *cs, &p.Pool)
+		if err != nil {
+			return "", fmt.Errorf("error composing revision %d of %s: %w", rev, p.Id, err)
+		}
+		composed = *next
+	}
+	return composed, nil
+}
+
+func isIdentity(cs string) bool {
+	unpacked, err := changeset.Unpack(cs)
+	return err == nil && unpacked.OldLen == unpacked.NewLen && unpacked.Ops == ""
+}
+
+// span is a range of the old text a changeset changes. Insertions are empty
+// spans at their position.
+type span struct {
+	start int
+	end   int
+}
+
+func (a span) overlaps(b span) bool {
+	switch {
+	case a.start == a.end && b.start == b.end:
+		// Both insert at the same place, in no particular order.
+		return a.start == b.start
+	case a.start == a.end:
+		return b.start < a.start && a.start < b.end
+	case b.start == b.end:
+		return a.start < b.start && b.start < a.end
+	}
+	return a.start < b.end && b.start < a.end
+}
+
+// changedSpans returns the spans of the old text cs deletes, inserts into or
+// changes the attributes of, joining adjacent ones.
+func changedSpans(cs string) ([]span, error) {
+	unpacked, err := changeset.Unpack(cs)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := changeset.DeserializeOps(unpacked.Ops)
+	if err != nil {
+		return nil, err
+	}
+
+	var spans []span
+	add := func(start int, end int) {
+		if n := len(spans); n > 0 && spans[n-1].end >= start {
+			spans[n-1].end = max(spans[n-1].end, end)
+			return
+		}
+		spans = append(spans, span{start: start, end: end})
+	}
+	pos := 0
+	for _, op := range *ops {
+		switch op.OpCode {
+		case "=":
+			if op.Attribs != "" {
+				add(pos, pos+op.Chars)
+			}
+			pos += op.Chars
+		case "-":
+			add(pos, pos+op.Chars)
+			pos += op.Chars
+		case "+":
+			add(pos, pos)
+		}
+	}
+	return spans, nil
+}
+
+// findConflicts returns the regions of base that both changesets change.
+func findConflicts(sourceChanges string, forkChanges string, base string) ([]Conflict, error) {
+	sourceSpans, err := changedSpans(sourceChanges)
+	if err != nil {
+		return nil, err
+	}
+	forkSpans, err := changedSpans(forkChanges)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []span
+	for _, a := range sourceSpans {
+		for _, b := range forkSpans {
+			if a.overlaps(b) {
+				regions = append(regions, span{start: min(a.start, b.start), end: max(a.end, b.end)})
+			}
+		}
+	}
+	slices.SortFunc(regions, func(a, b span) int { return a.start - b.start })
+
+	runes := []rune(base)
+	conflicts := make([]Conflict, 0, len(regions))
+	for _, region := range regions {
+		if n := len(conflicts); n > 0 && conflicts[n-1].End >= region.start {
+			conflicts[n-1].End = max(conflicts[n-1].End, region.end)
+			conflicts[n-1].Text = string(runes[conflicts[n-1].Start:conflicts[n-1].End])
+			continue
+		}
+		conflicts = append(conflicts, Conflict{
+			Start: region.start,
+			End:   region.end,
+			Text:  string(runes[region.start:region.end]),
+		})
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,227 @@
+package pad
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	db2 "github.com/ether/etherpad-go/lib/models/db"
+	"github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mergeAuthor = "a.mergeAuthor"
+
+// newFork returns a source pad with text and a fork of it.
+func newFork(t *testing.T, text string) (*Manager, *pad.Pad, *pad.Pad) {
+	t.Helper()
+	return newForkIn(t, db.NewMemoryDataStore(), text)
+}
+
+func newForkIn(t *testing.T, store db.DataStore, text string) (*Manager, *pad.Pad, *pad.Pad) {
+	t.Helper()
+	createdHooks := hooks.NewHook()
+	manager := NewManager(store, &createdHooks)
+	author := undoAuthorA
+	source, err := manager.GetPad("main", &text, &author)
+	require.NoError(t, err)
+	fork, err := manager.GetPad("draft", &text, &author)
+	require.NoError(t, err)
+	require.NoError(t, manager.MarkFork(fork, source.Id, source.Head))
+	return manager, source, fork
+}
+
+func TestMerge_NonOverlapping(t *testing.T) {
+	manager, source, fork := newFork(t, "The quick fox jumps.")
+
+	splice(t, source, 0, 3, "A", undoAuthorA)        // A quick fox jumps.
+	splice(t, fork, 10, 3, "brown fox", undoAuthorB) // The quick brown fox jumps.
+	splice(t, fork, 25, 1, " high.", undoAuthorB)    // The quick brown fox jumps high.
+
+	merge, err := manager.PrepareMerge(fork)
+	require.NoError(t, err)
+	assert.Empty(t, merge.Conflicts)
+
+	rev, err := manager.ApplyMerge(merge, mergeAuthor, false)
+	require.NoError(t, err)
+	assert.Equal(t, source.Head, rev)
+	assert.Equal(t, "A quick brown fox jumps high.\n", source.Text())
+	assert.Equal(t, source.Text(), fork.Text(), "the fork gets the changes of the source")
+	author, err := source.GetRevisionAuthor(rev)
+	require.NoError(t, err)
+	assert.Equal(t, mergeAuthor, *author)
+	assert.Equal(t, undoAuthorB, attribOf(source, strings.Index(source.Text(), "brown"), "author"), "merged text keeps its author")
+
+	assert.Equal(t, "main", fork.Fork.Source)
+	assert.Equal(t, source.Head, fork.Fork.SourceRev)
+	assert.Equal(t, fork.Head, fork.Fork.Rev)
+
+	// A second merge only brings the changes made since.
+	splice(t, fork, 0, 1, "One", undoAuthorB)
+	splice(t, source, 29, 0, " Again", undoAuthorA)
+	merge, err = manager.PrepareMerge(fork)
+	require.NoError(t, err)
+	_, err = manager.ApplyMerge(merge, mergeAuthor, false)
+	require.NoError(t, err)
+	assert.Equal(t, "One quick brown fox jumps high. Again\n", source.Text())
+	assert.Equal(t, source.Text(), fork.Text())
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	manager, source, fork := newFork(t, "The quick fox jumps.")
+
+	splice(t, source, 4, 5, "slow", undoAuthorA)
+	splice(t, fork, 4, 5, "lazy", undoAuthorB)
+
+	merge, err := manager.PrepareMerge(fork)
+	require.NoError(t, err)
+	assert.Equal(t, []Conflict{{Start: 4, End: 9, Text: "quick"}}, merge.Conflicts)
+
+	head := source.Head
+	_, err = manager.ApplyMerge(merge, mergeAuthor, false)
+	var conflictErr *MergeConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	assert.Len(t, conflictErr.Conflicts, 1)
+	assert.Equal(t, head, source.Head, "nothing is applied")
+
+	_, err = manager.ApplyMerge(merge, mergeAuthor, true)
+	require.NoError(t, err)
+	assert.Contains(t, source.Text(), "slow")
+	assert.Contains(t, source.Text(), "lazy")
+	assert.Equal(t, source.Text(), fork.Text())
+}
+
+func TestMerge_Preview(t *testing.T) {
+	manager, source, fork := newFork(t, "The quick fox jumps.")
+
+	splice(t, fork, 4, 6, "", undoAuthorB)       // The fox jumps.
+	splice(t, fork, 14, 0, " Then", undoAuthorB) // The fox jumps. Then
+
+	merge, err := manager.PrepareMerge(fork)
+	require.NoError(t, err)
+	head := source.Head
+	preview, authors, err := merge.Preview(mergeAuthor)
+	require.NoError(t, err)
+	assert.Equal(t, "The quick fox jumps. Then\n", preview.Text, "deleted text is shown with the insertions")
+	assert.Equal(t, "true", attribOfAText(source, preview.Attribs, 4, "removed"))
+	assert.Equal(t, undoAuthorB, attribOfAText(source, preview.Attribs, 21, "author"))
+	assert.Equal(t, []string{mergeAuthor}, authors)
+	assert.Equal(t, head, source.Head, "the preview does not change the source")
+}
+
+func TestMerge_Errors(t *testing.T) {
+	manager, source, fork := newFork(t, "text")
+
+	_, err := manager.PrepareMerge(source)
+	assert.ErrorIs(t, err, ErrNotAFork)
+
+	merge, err := manager.PrepareMerge(fork)
+	require.NoError(t, err)
+	_, err = manager.ApplyMerge(merge, mergeAuthor, false)
+	assert.ErrorIs(t, err, ErrNothingToMerge)
+
+	require.NoError(t, manager.RemovePad(source.Id))
+	_, err = manager.PrepareMerge(fork)
+	assert.ErrorIs(t, err, ErrForkSourceMissing)
+}
+
+// frozenStore refuses to write the pad frozen past the revision head, like a
+// database that went away in the middle of a merge.
+type frozenStore struct {
+	*db.MemoryDataStore
+	frozen string
+	head   int
+}
+
+func (f *frozenStore) SaveRevision(padId string, rev int, changeset string, text db2.AText, pool db2.RevPool, authorId *string, timestamp int64) error {
+	if padId == f.frozen && rev > f.head {
+		return errors.New("database unavailable")
+	}
+	return f.MemoryDataStore.SaveRevision(padId, rev, changeset, text, pool, authorId, timestamp)
+}
+
+func (f *frozenStore) CreatePad(padID string, padDB db2.PadDB) error {
+	if padID == f.frozen && padDB.Head > f.head {
+		return errors.New("database unavailable")
+	}
+	return f.MemoryDataStore.CreatePad(padID, padDB)
+}
+
+func TestMerge_Interrupted(t *testing.T) {
+	testCases := []struct {
+		name     string
+		frozen   string
+		editFork bool
+	}{
+		{name: "source not written", frozen: "main"},
+		{name: "fork not written", frozen: "draft"},
+		{name: "fork not written and edited since", frozen: "draft", editFork: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &frozenStore{MemoryDataStore: db.NewMemoryDataStore()}
+			manager, source, fork := newForkIn(t, store, "The quick fox jumps.")
+			splice(t, source, 0, 3, "A", undoAuthorA)        // A quick fox jumps.
+			splice(t, fork, 10, 3, "brown fox", undoAuthorB) // The quick brown fox jumps.
+
+			merge, err := manager.PrepareMerge(fork)
+			require.NoError(t, err)
+			store.frozen, store.head = tc.frozen, fork.Head
+			if tc.frozen == source.Id {
+				store.head = source.Head
+			}
+			_, err = manager.ApplyMerge(merge, mergeAuthor, false)
+			require.Error(t, err)
+
+			// The pads are read again, as after a restart.
+			store.frozen = ""
+			manager.UnloadPad(source.Id)
+			manager.UnloadPad(fork.Id)
+			source, err = manager.GetPad("main", nil, nil)
+			require.NoError(t, err)
+			fork, err = manager.GetPad("draft", nil, nil)
+			require.NoError(t, err)
+			if tc.editFork {
+				splice(t, fork, 25, 1, " high.", undoAuthorB)
+			}
+
+			merge, err = manager.PrepareMerge(fork)
+			require.NoError(t, err)
+			assert.Nil(t, fork.Fork.Merge)
+			_, err = manager.ApplyMerge(merge, mergeAuthor, false)
+			if tc.frozen == fork.Id && !tc.editFork {
+				assert.ErrorIs(t, err, ErrNothingToMerge, "the fork got the changes of the source when the merge was resumed")
+			} else {
+				require.NoError(t, err)
+			}
+
+			want := "A quick brown fox jumps.\n"
+			if tc.editFork {
+				want = "A quick brown fox jumps high.\n"
+			}
+			assert.Equal(t, want, source.Text(), "the merge is applied once")
+			assert.Equal(t, source.Text(), fork.Text())
+			assert.Empty(t, fork.Fork.Sync)
+		})
+	}
+}
+
+func TestForkChain(t *testing.T) {
+	manager, source, fork := newFork(t, "text")
+	text, author := "text", undoAuthorA
+	second, err := manager.GetPad("second", &text, &author)
+	require.NoError(t, err)
+	require.NoError(t, manager.MarkFork(second, fork.Id, fork.Head))
+
+	chain, err := manager.ForkChain(second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second", "draft", "main"}, chain)
+
+	require.NoError(t, manager.RemovePad(source.Id))
+	chain, err = manager.ForkChain(second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second", "draft"}, chain, "the chain ends at a removed source")
+}
@@ -1,6 +1,7 @@
 package pad
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"sync"
@@ -96,6 +97,7 @@ type Manager struct {
 	author         *author.Manager
 	hook           *hooks.Hook
 	padList        List
+	undo           *undoHistory
 }
 
 func NewManager(db db.DataStore, hook *hooks.Hook) *Manager {
@@ -110,11 +112,18 @@ func NewManager(db db.DataStore, hook *hooks.Hook) *Manager {
 			mutex:    sync.RWMutex{},
 		},
 		padList: NewList(db),
+		undo:    &undoHistory{},
 	}
 }
 
 func (m *Manager) DoesPadExist(padID string) (*bool, error) {
-	return m.store.DoesPadExist(padID)
+	return m.DoesPadExistContext(context.Background(), padID)
+}
+
+// DoesPadExistContext is DoesPadExist with the database call traced as a
+// child of the span in ctx.
+func (m *Manager) DoesPadExistContext(ctx context.Context, padID string) (*bool, error) {
+	return db.WithContext(m.store, ctx).DoesPadExist(padID)
 }
 
 func (m *Manager) IsValidPadId(padID string) bool {
@@ -129,16 +138,23 @@ func (m *Manager) SanitizePadId(padID string) (*string, error) {
 }
 
 func (m *Manager) RemovePad(padID string) error {
+	return m.RemovePadContext(context.Background(), padID)
+}
+
+// RemovePadContext is RemovePad with the database call traced as a child of
+// the span in ctx.
+func (m *Manager) RemovePadContext(ctx context.Context, padID string) error {
 	// Capture the loaded pad (if any) before deletion so the padRemove hook can
 	// hand listeners the pad context, mirroring the original Etherpad which
 	// fires padRemove from Pad.remove() with `this`.
 	removedPad := m.globalPadCache.GetPad(padID)
 
-	if err := m.store.RemovePad(padID); err != nil {
+	if err := db.WithContext(m.store, ctx).RemovePad(padID); err != nil {
 		return err
 	}
 	m.globalPadCache.DeletePad(padID)
 	m.padList.RemovePad(padID)
+	m.undo.remove(padID)
 
 	m.hook.ExecutePadRemoveHooks(&events.PadRemoveContext{
 		Pad:   removedPad,
@@ -149,6 +165,13 @@ func (m *Manager) RemovePad(padID string) error {
 }
 
 func (m *Manager) GetPad(padID string, text *string, authorId *string) (*pad.Pad, error) {
+	return m.GetPadContext(context.Background(), padID, text, authorId)
+}
+
+// GetPadContext is GetPad with the database calls of loading or creating the
+// pad traced as children of the span in ctx. The cached pad keeps the
+// untraced store.
+func (m *Manager) GetPadContext(ctx context.Context, padID string, text *string, authorId *string) (*pad.Pad, error) {
 	if !m.IsValidPadId(padID) {
 		return nil, errors.New("invalid pad id")
 	}
@@ -170,7 +193,7 @@ func (m *Manager) GetPad(padID string, text *string, authorId *string) (*pad.Pad
 
 	// initialize the pad
 
-	newPad.Init(text, authorId, m.author)
+	newPad.InitContext(ctx, text, authorId, m.author)
 	m.globalPadCache.SetPad(padID, &newPad)
 
 	return &newPad, nil
@@ -202,4 +225,5 @@ func (m *Manager) GetTypedPad(padID string, documentType string, authorId *strin
 func (m *Manager) UnloadPad(id string) {
 	m.globalPadCache.DeletePad(id)
 	m.padList.RemovePad(id)
+	m.undo.remove(id)
 }
@@ -1,6 +1,7 @@
 package pad
 
 import (
+	"context"
 	"errors"
 	"strings"
 
@@ -24,6 +25,12 @@ func NewReadOnlyManager(db db.DataStore) *ReadOnlyManager {
 	}
 }
 
+// WithContext returns a view of the manager whose database calls are traced
+// as children of the span in ctx.
+func (r *ReadOnlyManager) WithContext(ctx context.Context) *ReadOnlyManager {
+	return &ReadOnlyManager{Store: db.WithContext(r.Store, ctx)}
+}
+
 func (r *ReadOnlyManager) IsReadOnlyID(id *string) bool {
 	return strings.HasPrefix(*id, "r.")
 }
@@ -0,0 +1,61 @@
+package pad
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	db2 "github.com/ether/etherpad-go/lib/models/db"
+	"github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// datedStore dates every revision a minute after the previous one, starting
+// at start.
+type datedStore struct {
+	*db.MemoryDataStore
+	start time.Time
+}
+
+func (d datedStore) SaveRevision(padId string, rev int, changeset string, text db2.AText, pool db2.RevPool, authorId *string, _ int64) error {
+	timestamp := d.start.Add(time.Duration(rev) * time.Minute).UnixMilli()
+	return d.MemoryDataStore.SaveRevision(padId, rev, changeset, text, pool, authorId, timestamp)
+}
+
+func TestGetRevisionAt(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	createdHooks := hooks.NewHook()
+	manager := NewManager(datedStore{MemoryDataStore: db.NewMemoryDataStore(), start: start}, &createdHooks)
+	text := "hello"
+	author := undoAuthorA
+	p, err := manager.GetPad("dated", &text, &author)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		splice(t, p, 0, 0, "x", undoAuthorA)
+	}
+	require.Equal(t, 10, p.Head)
+
+	testCases := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{"at the first revision", start, 0},
+		{"at a revision", start.Add(4 * time.Minute), 4},
+		{"between revisions", start.Add(4*time.Minute + 30*time.Second), 4},
+		{"in another zone", start.Add(7 * time.Minute).In(time.FixedZone("CET", 3600)), 7},
+		{"after the head", start.Add(time.Hour), 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rev, err := p.GetRevisionAt(tc.at)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, rev)
+		})
+	}
+
+	_, err = p.GetRevisionAt(start.Add(-time.Second))
+	assert.ErrorIs(t, err, pad.ErrNoRevisionAt)
+}
@@ -0,0 +1,43 @@
+package pad
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/hooks/events"
+	"github.com/ether/etherpad-go/lib/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCheckAccessContextTracesHooksBelowTheRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	createdHooks := hooks.NewHook()
+	createdHooks.EnqueueOnAccessCheckHook(func(ctx *events.OnAccessCheckContext) { ctx.Deny() })
+	store := db.NewMemoryDataStore()
+	securityManager := NewSecurityManager(store, &createdHooks, NewManager(store, &createdHooks))
+	padId := "traced"
+
+	_, err := securityManager.CheckAccess(&padId, nil, nil, nil)
+	require.Error(t, err)
+	assert.Empty(t, recorder.Ended(), "a check without a request span started a trace")
+
+	ctx, request := tracing.Start(context.Background(), "request")
+	_, err = securityManager.CheckAccessContext(ctx, &padId, nil, nil, nil)
+	request.End()
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "hook "+hooks.OnAccessCheckString, spans[0].Name())
+	assert.Equal(t, request.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
@@ -0,0 +1,228 @@
+package pad
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/models/pad"
+)
+
+// undoWindow is how many revisions back Undo looks for an edit of the author.
+const undoWindow = 500
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// authorHistory is the undo state of one author on one pad.
+type authorHistory struct {
+	// undone are revisions of the author that were undone.
+	undone map[int]bool
+	// undos and redos are revisions written by Undo and Redo.
+	undos map[int]bool
+	redos map[int]bool
+	// redo holds the revisions written by Undo that Redo reverts, the most
+	// recent last.
+	redo []int
+}
+
+// undoHistory keeps the authorHistory of every author by pad. It lives in
+// memory, so after a restart or an unload of the pad Undo treats earlier
+// undos like any other edit.
+type undoHistory struct {
+	mu   sync.Mutex
+	pads map[string]*padHistory
+}
+
+// padHistory is the undo state of one pad. Its mutex serialises Undo and Redo
+// on the pad.
+type padHistory struct {
+	mu      sync.Mutex
+	authors map[string]*authorHistory
+}
+
+// lock returns the history of padId, locked and pruned to the undo window at
+// head.
+func (u *undoHistory) lock(padId string, head int) *padHistory {
+	u.mu.Lock()
+	if u.pads == nil {
+		u.pads = make(map[string]*padHistory)
+	}
+	history, ok := u.pads[padId]
+	if !ok {
+		history = &padHistory{authors: make(map[string]*authorHistory)}
+		u.pads[padId] = history
+	}
+	u.mu.Unlock()
+	history.mu.Lock()
+	history.prune(head)
+	return history
+}
+
+func (u *undoHistory) remove(padId string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.pads, padId)
+}
+
+func (h *padHistory) get(authorId string) *authorHistory {
+	history, ok := h.authors[authorId]
+	if !ok {
+		history = &authorHistory{
+			undone: make(map[int]bool),
+			undos:  make(map[int]bool),
+			redos:  make(map[int]bool),
+		}
+		h.authors[authorId] = history
+	}
+	return history
+}
+
+// unlock prunes h to the undo window at head and unlocks it.
+func (h *padHistory) unlock(head int) {
+	h.prune(head)
+	h.mu.Unlock()
+}
+
+// prune drops the revisions that are out of the undo window at head, and the
+// authors left without any.
+func (h *padHistory) prune(head int) {
+	oldest := head - undoWindow
+	for authorId, history := range h.authors {
+		for _, revs := range []map[int]bool{history.undone, history.undos, history.redos} {
+			for rev := range revs {
+				if rev <= oldest {
+					delete(revs, rev)
+				}
+			}
+		}
+		history.redo = slices.DeleteFunc(history.redo, func(rev int) bool { return rev <= oldest })
+		if len(history.undone) == 0 && len(history.undos) == 0 && len(history.redos) == 0 && len(history.redo) == 0 {
+			delete(h.authors, authorId)
+		}
+	}
+}
+
+// Undo reverts the most recent edit of authorId on p that is not undone yet.
+// The inverse of that revision is rebased over the edits made since, so
+// changes of other authors are kept. Edits whose effect others already
+// removed are skipped. It returns the new head revision.
+func (m *Manager) Undo(p *pad.Pad, authorId string) (int, error) {
+	padHistory := m.undo.lock(p.Id, p.Head)
+	defer func() { padHistory.unlock(p.Head) }()
+	history := padHistory.get(authorId)
+
+	for rev := p.Head; rev > 0 && rev > p.Head-undoWindow; rev-- {
+		if history.undone[rev] || history.undos[rev] {
+			continue
+		}
+		revision, err := p.GetRevision(rev)
+		if err != nil {
+			return 0, err
+		}
+		if revision.AuthorId == nil || *revision.AuthorId != authorId {
+			continue
+		}
+		newRev, err := revert(p, rev, authorId)
+		if err != nil {
+			return 0, err
+		}
+		history.undone[rev] = true
+		if newRev == nil {
+			continue
+		}
+		history.undos[*newRev] = true
+		history.redo = append(history.redo, *newRev)
+		return *newRev, nil
+	}
+	return 0, ErrNothingToUndo
+}
+
+// Redo reverts the most recent Undo of authorId on p. A new edit of the
+// author since that undo ends the redo history, as does the undo leaving the
+// undo window. It returns the new head revision.
+func (m *Manager) Redo(p *pad.Pad, authorId string) (int, error) {
+	padHistory := m.undo.lock(p.Id, p.Head)
+	defer func() { padHistory.unlock(p.Head) }()
+	history := padHistory.get(authorId)
+
+	for len(history.redo) > 0 {
+		undoRev := history.redo[len(history.redo)-1]
+		for rev := undoRev + 1; rev <= p.Head; rev++ {
+			if history.undos[rev] || history.redos[rev] {
+				continue
+			}
+			revision, err := p.GetRevision(rev)
+			if err != nil {
+				return 0, err
+			}
+			if revision.AuthorId != nil && *revision.AuthorId == authorId {
+				history.redo = nil
+				return 0, ErrNothingToRedo
+			}
+		}
+
+		newRev, err := revert(p, undoRev, authorId)
+		if err != nil {
+			return 0, err
+		}
+		history.redo = history.redo[:len(history.redo)-1]
+		if newRev == nil {
+			continue
+		}
+		history.redos[*newRev] = true
+		return *newRev, nil
+	}
+	return 0, ErrNothingToRedo
+}
+
+// revert appends a revision by authorId that inverts revision rev, rebased
+// over the revisions after it. Deleted text gets its attributes back and
+// attribute changes are restored to the values before rev. It returns nil
+// when nothing of rev is left to revert.
+func revert(p *pad.Pad, rev int, authorId string) (*int, error) {
+	cs, err := p.GetRevisionChangeset(rev)
+	if err != nil {
+		return nil, err
+	}
+	before := p.GetInternalRevisionAText(rev - 1)
+	if before == nil {
+		return nil, fmt.Errorf("could not retrieve atext for revision %d", rev-1)
+	}
+	alines, err := changeset.SplitAttributionLines(before.Attribs, before.Text)
+	if err != nil {
+		return nil, err
+	}
+	inverse, err := changeset.Inverse(*cs, changeset.SplitTextLines(before.Text), alines, &p.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like USER_CHANGES, the inverse is relative to rev and is followed
+	// through every later revision up to the head.
+	rebased := *inverse
+	for r := rev + 1; r <= p.Head; r++ {
+		later, err := p.GetRevisionChangeset(r)
+		if err != nil {
+			return nil, err
+		}
+		followed, err := changeset.Follow(*later, rebased, false, &p.Pool)
+		if err != nil {
+			return nil, fmt.Errorf("error rebasing inverse of revision %d over %d: %w", rev, r, err)
+		}
+		rebased = *followed
+	}
+
+	head := p.Head
+	newRev, err := p.AppendRevision(rebased, &authorId)
+	if err != nil {
+		return nil, err
+	}
+	if *newRev == head {
+		return nil, nil
+	}
+	appended := *newRev
+	return &appended, nil
+}
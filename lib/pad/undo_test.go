@@ -0,0 +1,188 @@
+package pad
+
+import (
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/apool"
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	undoAuthorA = "a.undoAuthorA"
+	undoAuthorB = "a.undoAuthorB"
+)
+
+func newUndoPad(t *testing.T, text string) (*Manager, *pad.Pad) {
+	t.Helper()
+	createdHooks := hooks.NewHook()
+	manager := NewManager(db.NewMemoryDataStore(), &createdHooks)
+	author := undoAuthorB
+	retrievedPad, err := manager.GetPad("undo", &text, &author)
+	require.NoError(t, err)
+	return manager, retrievedPad
+}
+
+func splice(t *testing.T, p *pad.Pad, start int, ndel int, ins string, authorId string) {
+	t.Helper()
+	require.NoError(t, p.SpliceText(start, ndel, ins, &authorId))
+}
+
+// setAttrib sets key to value on the chars [start, start+length) of the first
+// line of p.
+func setAttrib(t *testing.T, p *pad.Pad, start int, length int, key string, value string, authorId string) {
+	t.Helper()
+	num := p.Pool.PutAttrib(apool.Attribute{Key: key, Value: value}, nil)
+	textLen := len(p.Text())
+	cs := changeset.Pack(textLen, textLen, "="+utils.NumToString(start)+"*"+utils.NumToString(num)+"="+utils.NumToString(length), "")
+	_, err := p.AppendRevision(cs, &authorId)
+	require.NoError(t, err)
+}
+
+func attribOf(p *pad.Pad, pos int, key string) string {
+	return attribOfAText(p, p.AText.Attribs, pos, key)
+}
+
+// attribOfAText returns the value of key at pos in attribs, which uses the
+// pool of p.
+func attribOfAText(p *pad.Pad, attribs string, pos int, key string) string {
+	ops, _ := changeset.DeserializeOps(attribs)
+	for _, op := range *ops {
+		if pos < op.Chars {
+			if value := changeset.FromString(op.Attribs, &p.Pool).Get(key); value != nil {
+				return *value
+			}
+			return ""
+		}
+		pos -= op.Chars
+	}
+	return ""
+}
+
+func TestUndo_InterleavedAuthors(t *testing.T) {
+	manager, p := newUndoPad(t, "hello world")
+
+	splice(t, p, 6, 0, "big ", undoAuthorA)   // hello big world
+	splice(t, p, 0, 0, "Oh, ", undoAuthorB)   // Oh, hello big world
+	splice(t, p, 19, 0, "!", undoAuthorA)     // Oh, hello big world!
+	splice(t, p, 10, 0, "very ", undoAuthorB) // Oh, hello very big world!
+	require.Equal(t, "Oh, hello very big world!\n", p.Text())
+
+	_, err := manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "Oh, hello very big world\n", p.Text())
+
+	rev, err := manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, p.Head, rev)
+	assert.Equal(t, "Oh, hello very world\n", p.Text(), "the edits of B inside the undone range are kept")
+	author, err := p.GetRevisionAuthor(rev)
+	require.NoError(t, err)
+	assert.Equal(t, undoAuthorA, *author)
+
+	_, err = manager.Undo(p, undoAuthorA)
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	_, err = manager.Undo(p, undoAuthorB)
+	require.NoError(t, err)
+	assert.Equal(t, "Oh, hello world\n", p.Text(), "B undoes their own edits only")
+
+	_, err = manager.Redo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "Oh, hello big world\n", p.Text())
+	_, err = manager.Redo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "Oh, hello big world!\n", p.Text())
+	_, err = manager.Redo(p, undoAuthorA)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+
+	// A redone edit can be undone again.
+	_, err = manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "Oh, hello big world\n", p.Text())
+}
+
+func TestUndo_RestoresAttributes(t *testing.T) {
+	manager, p := newUndoPad(t, "hello world")
+
+	splice(t, p, 0, 11, "hello world", undoAuthorB)
+	splice(t, p, 0, 6, "", undoAuthorA) // world
+	splice(t, p, 5, 0, "!", undoAuthorB)
+	_, err := manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", p.Text())
+	assert.Equal(t, undoAuthorB, attribOf(p, 0, "author"), "restored text keeps its author")
+
+	setAttrib(t, p, 0, 5, "bold", "true", undoAuthorA)
+	splice(t, p, 12, 0, " again", undoAuthorB)
+	require.Equal(t, "true", attribOf(p, 2, "bold"))
+
+	_, err = manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world! again\n", p.Text())
+	assert.Equal(t, "", attribOf(p, 2, "bold"))
+	assert.Equal(t, undoAuthorB, attribOf(p, 2, "author"))
+
+	_, err = manager.Redo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "true", attribOf(p, 2, "bold"))
+}
+
+func TestUndo_SkipsEditsRemovedByOthers(t *testing.T) {
+	manager, p := newUndoPad(t, "hello world")
+
+	splice(t, p, 5, 0, " big", undoAuthorA)
+	splice(t, p, 15, 0, "!", undoAuthorA)
+	splice(t, p, 0, 15, "bye", undoAuthorB) // bye!
+	require.Equal(t, "bye!\n", p.Text())
+
+	head := p.Head
+	_, err := manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	assert.Equal(t, "bye\n", p.Text())
+	assert.Equal(t, head+1, p.Head)
+
+	_, err = manager.Undo(p, undoAuthorA)
+	assert.ErrorIs(t, err, ErrNothingToUndo, "the text A added first is gone already")
+	assert.Equal(t, head+1, p.Head)
+}
+
+func TestRedo_EndsWithNewEdit(t *testing.T) {
+	manager, p := newUndoPad(t, "hello world")
+
+	splice(t, p, 5, 0, " big", undoAuthorA)
+	_, err := manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	splice(t, p, 0, 0, "B: ", undoAuthorB)
+	splice(t, p, 0, 0, "A: ", undoAuthorA)
+
+	_, err = manager.Redo(p, undoAuthorA)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+	assert.Equal(t, "A: B: hello world\n", p.Text())
+}
+
+func TestUndo_HistoryLeavesWithTheWindow(t *testing.T) {
+	manager, p := newUndoPad(t, "hello world")
+
+	splice(t, p, 5, 0, " big", undoAuthorA)
+	_, err := manager.Undo(p, undoAuthorA)
+	require.NoError(t, err)
+	require.Contains(t, manager.undo.pads[p.Id].authors, undoAuthorA)
+
+	for i := 0; i < undoWindow; i++ {
+		splice(t, p, 0, 0, "b", undoAuthorB)
+	}
+	_, err = manager.Redo(p, undoAuthorA)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+	assert.Empty(t, manager.undo.pads[p.Id].authors)
+
+	_, err = manager.Undo(p, undoAuthorB)
+	require.NoError(t, err)
+	manager.UnloadPad(p.Id)
+	assert.NotContains(t, manager.undo.pads, p.Id)
+}
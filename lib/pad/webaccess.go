@@ -81,13 +81,13 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 
 	if hookSystem != nil {
 		preAuthorizeCtx := &events.PreAuthorizeContext{Path: ctx.Path(), RequireAdmin: requireAdmin}
-		hookSystem.ExecutePreAuthorizeHooks(preAuthorizeCtx)
+		hookSystem.ExecuteHooksContext(ctx.Context(), hooks.PreAuthorizeString, preAuthorizeCtx)
 		switch preAuthorizeCtx.Decision() {
 		case events.PreAuthorizePermit:
 			return ctx.Next()
 		case events.PreAuthorizeDeny:
 			preAuthzFailureCtx := &events.PreAuthzFailureContext{Path: ctx.Path(), RequireAdmin: requireAdmin}
-			hookSystem.ExecutePreAuthzFailureHooks(preAuthzFailureCtx)
+			hookSystem.ExecuteHooksContext(ctx.Context(), hooks.PreAuthzFailureString, preAuthzFailureCtx)
 			if preAuthzFailureCtx.Handled() {
 				for key, value := range preAuthzFailureCtx.Headers() {
 					ctx.Set(key, value)
@@ -201,7 +201,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 				RequireAdmin: requireAdmin,
 				User:         sessionReq,
 			}
-			hookSystem.ExecuteAuthorizeHooks(authorizeCtx)
+			hookSystem.ExecuteHooksContext(ctx.Context(), hooks.AuthorizeString, authorizeCtx)
 			switch authorizeCtx.Decision() {
 			case events.AuthorizeGrant:
 				return grant(authorizeCtx.Level())
@@ -224,15 +224,15 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 		return ctx.Next()
 	}
 
-	if retrievedSettings.Users == nil {
-		var newUsers = make(map[string]settings.User)
-		retrievedSettings.Users = newUsers
+	var users = retrievedSettings.UserAccounts()
+	if users == nil {
+		users = make(map[string]settings.User)
 	}
 
 	var user, ok = ctx.Locals(clientVars.WebAccessStore).(*webaccess.SocketClientRequest)
 
 	var webAccessCtx = webaccess.WebAccessType{
-		Users: settings.Displayed.Users,
+		Users: users,
 		Next:  ctx.Next,
 	}
 
@@ -261,7 +261,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 		logger.Infof("failed authentication from IP %s", ctx.IP())
 		if hookSystem != nil {
 			failCtx := &events.AuthnFailureContext{Path: ctx.Path(), RequireAdmin: requireAdmin}
-			hookSystem.ExecuteAuthnFailureHooks(failCtx)
+			hookSystem.ExecuteHooksContext(ctx.Context(), hooks.AuthnFailureString, failCtx)
 			if failCtx.Handled() {
 				for k, v := range failCtx.Headers() {
 					ctx.Set(k, v)
@@ -293,7 +293,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 			RequireAdmin:  requireAdmin,
 			GetHeader:     func(k string) string { return ctx.Get(k) },
 		}
-		hookSystem.ExecuteAuthenticateHooks(authCtx)
+		hookSystem.ExecuteHooksContext(ctx.Context(), hooks.AuthenticateString, authCtx)
 		if authCtx.Answered() {
 			if authCtx.Rejected() {
 				return sendAuthnFailure()
@@ -302,7 +302,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 			// settings.Users for admin status when the username is known there.
 			username := authCtx.Username()
 			var isAdmin bool
-			if u, ok := retrievedSettings.Users[username]; ok {
+			if u, ok := users[username]; ok {
 				isAdmin = u.IsAdmin != nil && *u.IsAdmin
 			}
 			unameCopy := username
@@ -315,7 +315,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 	}
 
 	if !pluginAuthenticated {
-		var foundUsers = retrievedSettings.Users
+		var foundUsers = users
 
 		var password *string
 
@@ -330,7 +330,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 		if !httpBasicAuth || webAccessCtx.Username == nil || password == nil || *password != *webAccessCtx.Password {
 			return sendAuthnFailure()
 		}
-		var retrievedUserFromMap = retrievedSettings.Users[*webAccessCtx.Username]
+		var retrievedUserFromMap = users[*webAccessCtx.Username]
 		// Make a shallow copy so that the password property can be deleted (to prevent it from
 		// appearing in logs or in the database) without breaking future authentication attempts.
 		ctx.Locals(clientVars.WebAccessStore, &webaccess.SocketClientRequest{
@@ -360,7 +360,7 @@ func CheckAccessWithHooks(ctx fiber.Ctx, logger *zap.SugaredLogger, retrievedSet
 	sendAuthzFailure := func() error {
 		if hookSystem != nil {
 			failCtx := &events.AuthzFailureContext{Path: ctx.Path(), RequireAdmin: requireAdmin}
-			hookSystem.ExecuteAuthzFailureHooks(failCtx)
+			hookSystem.ExecuteHooksContext(ctx.Context(), hooks.AuthzFailureString, failCtx)
 			if failCtx.Handled() {
 				for k, v := range failCtx.Headers() {
 					ctx.Set(k, v)
@@ -4,6 +4,8 @@ import (
 	"embed"
 
 	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/io"
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
 	"github.com/ether/etherpad-go/lib/pad"
 	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/gofiber/fiber/v3"
@@ -17,4 +19,16 @@ type EpPluginStore struct {
 	PadManager        *pad.Manager
 	App               *fiber.App
 	RetrievedSettings *settings.Settings
+	// PadClientUpdater pushes pad changes made by plugins to the connected
+	// clients.
+	PadClientUpdater PadClientUpdater
+	// Formats is where plugins register export and import formats. The
+	// built-in formats are registered before the plugins are initialized.
+	Formats *io.FormatRegistry
+}
+
+// PadClientUpdater sends pad changes made outside a pad connection to the
+// connected clients; *ws.PadMessageHandler implements it.
+type PadClientUpdater interface {
+	UpdatePadClients(pad *padModel.Pad)
 }
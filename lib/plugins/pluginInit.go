@@ -3,6 +3,7 @@ package plugins
 import (
 	"slices"
 
+	"github.com/ether/etherpad-go/lib/hooks/events"
 	"github.com/ether/etherpad-go/lib/plugins/ep_align"
 	"github.com/ether/etherpad-go/lib/plugins/ep_author_hover"
 	"github.com/ether/etherpad-go/lib/plugins/ep_chat_log_join_leave"
@@ -18,6 +19,7 @@ import (
 	"github.com/ether/etherpad-go/lib/plugins/ep_spellcheck"
 	"github.com/ether/etherpad-go/lib/plugins/ep_table_of_contents"
 	"github.com/ether/etherpad-go/lib/plugins/interfaces"
+	"github.com/ether/etherpad-go/lib/plugins/wasm"
 )
 
 var RegisteredPlugins = []interfaces.EpPlugin{
@@ -37,6 +39,10 @@ var RegisteredPlugins = []interfaces.EpPlugin{
 	&ep_table_of_contents.EpTableOfContentsPlugin{},
 }
 
+// ExternalPlugins runs the WebAssembly plugins. It is nil unless
+// wasmPlugins.enabled is set.
+var ExternalPlugins *wasm.Runtime
+
 func InitPlugins(store *interfaces.EpPluginStore) {
 	var ts = store.RetrievedSettings.GetAllPlugins()
 	enabledPlugins := make([]string, 0)
@@ -52,4 +58,24 @@ func InitPlugins(store *interfaces.EpPluginStore) {
 			plugin.SetEnabled(true)
 		}
 	}
+
+	if store.RetrievedSettings.WasmPlugins.Enabled {
+		initExternalPlugins(store)
+	}
+}
+
+func initExternalPlugins(store *interfaces.EpPluginStore) {
+	reserved := make([]string, 0, len(RegisteredPlugins))
+	for _, plugin := range RegisteredPlugins {
+		reserved = append(reserved, plugin.Name())
+	}
+	runtime := wasm.NewRuntime(store.RetrievedSettings, store.HookSystem, store.PadManager, store.PadClientUpdater, store.Logger, reserved)
+	if err := runtime.Load(); err != nil {
+		store.Logger.Errorf("Error loading WebAssembly plugins: %v", err)
+	}
+	runtime.RegisterRoutes(store.App)
+	store.HookSystem.EnqueueShutdownHook(func(ctx *events.ShutdownContext) {
+		runtime.Close()
+	})
+	ExternalPlugins = runtime
 }
@@ -0,0 +1,298 @@
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ether/etherpad-go/lib/changeset"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/hooks/events"
+)
+
+// binding translates between the context of a hook and the JSON exchanged
+// with ep_hook.
+type binding struct {
+	// async hooks are informational: they are queued and the plugin's answer
+	// is ignored.
+	async bool
+	// payload returns the value sent to the plugin, nil to skip the call.
+	payload func(ctx any) any
+	// apply applies the plugin's answer to the context.
+	apply func(ctx any, result []byte) error
+}
+
+type padEventPayload struct {
+	PadId     string `json:"padId"`
+	AuthorId  string `json:"authorId,omitempty"`
+	Revision  int    `json:"revision,omitempty"`
+	Changeset string `json:"changeset,omitempty"`
+}
+
+type chatMessagePayload struct {
+	PadId    string `json:"padId"`
+	AuthorId string `json:"authorId"`
+	Text     string `json:"text"`
+}
+
+type chatMessageResult struct {
+	Text *string `json:"text"`
+	Drop bool    `json:"drop"`
+}
+
+type handleMessagePayload struct {
+	PadId    string          `json:"padId"`
+	AuthorId string          `json:"authorId"`
+	Message  json.RawMessage `json:"message"`
+}
+
+type dropResult struct {
+	Drop bool `json:"drop"`
+}
+
+type lineHTMLPayload struct {
+	PadId          string            `json:"padId"`
+	Text           string            `json:"text"`
+	LineContent    string            `json:"lineContent"`
+	LineAttributes map[string]string `json:"lineAttributes"`
+}
+
+type lineHTMLResult struct {
+	LineContent *string `json:"lineContent"`
+}
+
+type exportFileNamePayload struct {
+	PadId      string `json:"padId"`
+	ReadOnlyId string `json:"readOnlyId"`
+	ExportType string `json:"exportType"`
+}
+
+type exportHTMLPayload struct {
+	PadId string `json:"padId"`
+	HTML  string `json:"html,omitempty"`
+}
+
+type exportResult struct {
+	FileName string  `json:"fileName"`
+	CSS      string  `json:"css"`
+	HTML     *string `json:"html"`
+}
+
+// bindings lists the hooks a plugin may register.
+var bindings = map[string]*binding{
+	hooks.PadCreateString: {
+		async: true,
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.PadCreateContext); ok {
+				return padEventPayload{PadId: c.PadId, AuthorId: c.AuthorId}
+			}
+			return nil
+		},
+	},
+	hooks.PadUpdateString: {
+		async: true,
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.PadUpdateContext); ok {
+				return padEventPayload{PadId: c.PadId, AuthorId: c.AuthorId, Revision: c.Revs, Changeset: c.Changeset}
+			}
+			return nil
+		},
+	},
+	hooks.PadRemoveString: {
+		async: true,
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.PadRemoveContext); ok {
+				return padEventPayload{PadId: c.PadId}
+			}
+			return nil
+		},
+	},
+	hooks.ChatNewMessageString: {
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.ChatNewMessageContext); ok && c.Text != nil {
+				return chatMessagePayload{PadId: c.PadId, AuthorId: c.AuthorId, Text: *c.Text}
+			}
+			return nil
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer chatMessageResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			c := ctx.(*events.ChatNewMessageContext)
+			if answer.Drop {
+				c.DropMessage()
+			} else if answer.Text != nil {
+				*c.Text = *answer.Text
+			}
+			return nil
+		},
+	},
+	hooks.HandleMessageString: {
+		payload: func(ctx any) any {
+			c, ok := ctx.(*events.HandleMessageContext)
+			if !ok {
+				return nil
+			}
+			message, err := json.Marshal(c.Message)
+			if err != nil {
+				message = []byte("null")
+			}
+			return handleMessagePayload{PadId: c.PadId, AuthorId: c.AuthorId, Message: message}
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer dropResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			if answer.Drop {
+				ctx.(*events.HandleMessageContext).DropMessage()
+			}
+			return nil
+		},
+	},
+	"getLineHTMLForExport": {
+		payload: func(ctx any) any {
+			c, ok := ctx.(*events.LineHtmlForExportContext)
+			if !ok || c.LineContent == nil {
+				return nil
+			}
+			payload := lineHTMLPayload{LineContent: *c.LineContent, LineAttributes: map[string]string{}}
+			if c.PadId != nil {
+				payload.PadId = *c.PadId
+			}
+			if c.Text != nil {
+				payload.Text = *c.Text
+			}
+			// Line attributes such as headings sit on the first character.
+			if c.AttribLine != nil && c.Apool != nil {
+				if ops, err := changeset.DeserializeOps(*c.AttribLine); err == nil && len(*ops) > 0 {
+					payload.LineAttributes = changeset.FromString((*ops)[0].Attribs, c.Apool).Iter()
+				}
+			}
+			return payload
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer lineHTMLResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			if answer.LineContent != nil {
+				ctx.(*events.LineHtmlForExportContext).LineContent = answer.LineContent
+			}
+			return nil
+		},
+	},
+	hooks.ExportFileNameString: {
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.ExportFileNameContext); ok {
+				return exportFileNamePayload{PadId: c.PadId, ReadOnlyId: c.ReadOnlyId, ExportType: c.ExportType}
+			}
+			return nil
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer exportResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			if answer.FileName != "" {
+				ctx.(*events.ExportFileNameContext).SetFileName(answer.FileName)
+			}
+			return nil
+		},
+	},
+	hooks.StylesForExportString: {
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.StylesForExportContext); ok {
+				return exportHTMLPayload{PadId: c.PadId}
+			}
+			return nil
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer exportResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			ctx.(*events.StylesForExportContext).AddStyle(answer.CSS)
+			return nil
+		},
+	},
+	hooks.ExportHTMLAdditionalContentString: {
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.ExportHTMLAdditionalContentContext); ok {
+				return exportHTMLPayload{PadId: c.PadId}
+			}
+			return nil
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer exportResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			if answer.HTML != nil {
+				ctx.(*events.ExportHTMLAdditionalContentContext).Add(*answer.HTML)
+			}
+			return nil
+		},
+	},
+	hooks.ExportHTMLSendString: {
+		payload: func(ctx any) any {
+			if c, ok := ctx.(*events.ExportHTMLSendContext); ok && c.HTML != nil {
+				return exportHTMLPayload{PadId: c.PadId, HTML: *c.HTML}
+			}
+			return nil
+		},
+		apply: func(ctx any, result []byte) error {
+			var answer exportResult
+			if err := json.Unmarshal(result, &answer); err != nil {
+				return err
+			}
+			if answer.HTML != nil {
+				*ctx.(*events.ExportHTMLSendContext).HTML = *answer.HTML
+			}
+			return nil
+		},
+	},
+}
+
+// bindHooks registers the hooks the plugin asked for in ep_init.
+func (p *Plugin) bindHooks() {
+	p.hookIDs = map[string]string{}
+	for _, name := range p.registered {
+		if _, ok := p.hookIDs[name]; ok {
+			continue
+		}
+		hookBinding := bindings[name]
+		p.hookIDs[name] = p.runtime.hooks.EnqueueHook(name, func(ctx any) {
+			p.dispatch(name, hookBinding, ctx)
+		})
+	}
+}
+
+func (p *Plugin) dispatch(name string, hookBinding *binding, ctx any) {
+	payload := hookBinding.payload(ctx)
+	if payload == nil {
+		return
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		p.runtime.logger.Warnf("Could not encode %s for WebAssembly plugin %s: %v", name, p.name, err)
+		return
+	}
+	if hookBinding.async {
+		p.enqueue(name, encoded)
+		return
+	}
+	result, err := p.call(name, encoded)
+	if err != nil {
+		if !errors.Is(err, errNotLoaded) {
+			p.fail(name, err)
+		}
+		return
+	}
+	if len(result) == 0 || hookBinding.apply == nil {
+		return
+	}
+	if err := hookBinding.apply(ctx, result); err != nil {
+		p.runtime.logger.Warnf("WebAssembly plugin %s returned an invalid %s result: %v", p.name, name, err)
+	}
+}
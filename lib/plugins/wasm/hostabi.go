@@ -0,0 +1,192 @@
+package wasm
+
+import (
+	"context"
+	"strings"
+
+	padModel "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// hostModuleName is the import module of the host functions.
+const hostModuleName = "etherpad"
+
+// Status codes returned by the host functions. Functions returning a length
+// return the negated code instead.
+const (
+	statusOK               = 0
+	statusPermissionDenied = 1
+	statusNotFound         = 2
+	statusInvalidArgument  = 3
+	statusInternalError    = 4
+	statusUnknownHook      = 5
+)
+
+const (
+	logLevelDebug = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+// hostModule builds the host functions for p. Functions returning data write
+// it to a buffer the module passes in and return the full length, so a
+// module whose buffer was too small can call again with a larger one. The
+// host never calls into the module from a host function.
+func (p *Plugin) hostModule(rt wazero.Runtime) wazero.HostModuleBuilder {
+	i32 := api.ValueTypeI32
+	i64 := api.ValueTypeI64
+	return rt.NewHostModuleBuilder(hostModuleName).
+		// log(level, msg_ptr, msg_len)
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(p.hostLog), []api.ValueType{i32, i32, i32}, nil).
+		Export("log").
+		// register_hook(name_ptr, name_len) -> status
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(p.hostRegisterHook), []api.ValueType{i32, i32}, []api.ValueType{i32}).
+		Export("register_hook").
+		// get_settings(buf_ptr, buf_len) -> length
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(p.hostGetSettings), []api.ValueType{i32, i32}, []api.ValueType{i64}).
+		Export("get_settings").
+		// pad_get_text(id_ptr, id_len, buf_ptr, buf_len) -> length or -status
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(p.hostPadGetText), []api.ValueType{i32, i32, i32, i32}, []api.ValueType{i64}).
+		Export("pad_get_text").
+		// pad_set_text(id_ptr, id_len, text_ptr, text_len) -> status
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(p.hostPadSetText(false)), []api.ValueType{i32, i32, i32, i32}, []api.ValueType{i32}).
+		Export("pad_set_text").
+		// pad_append_text(id_ptr, id_len, text_ptr, text_len) -> status
+		NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(p.hostPadSetText(true)), []api.ValueType{i32, i32, i32, i32}, []api.ValueType{i32}).
+		Export("pad_append_text")
+}
+
+// readString reads a string from the module's memory.
+func readString(mod api.Module, ptr, size uint64) (string, bool) {
+	data, ok := mod.Memory().Read(uint32(ptr), uint32(size))
+	if !ok {
+		return "", false
+	}
+	return string(data), true
+}
+
+// writeBuffer writes data to the module's buffer if it fits and returns the
+// length of data.
+func writeBuffer(mod api.Module, ptr, size uint64, data []byte) int64 {
+	if uint64(len(data)) <= size && !mod.Memory().Write(uint32(ptr), data) {
+		return -statusInvalidArgument
+	}
+	return int64(len(data))
+}
+
+func (p *Plugin) hostLog(_ context.Context, mod api.Module, stack []uint64) {
+	message, ok := readString(mod, stack[1], stack[2])
+	if !ok {
+		return
+	}
+	logger := p.runtime.logger
+	prefix := "[" + p.name + "] "
+	switch api.DecodeI32(stack[0]) {
+	case logLevelDebug:
+		logger.Debug(prefix + message)
+	case logLevelWarn:
+		logger.Warn(prefix + message)
+	case logLevelError:
+		logger.Error(prefix + message)
+	default:
+		logger.Info(prefix + message)
+	}
+}
+
+func (p *Plugin) hostRegisterHook(_ context.Context, mod api.Module, stack []uint64) {
+	name, ok := readString(mod, stack[0], stack[1])
+	switch {
+	case !ok:
+		stack[0] = api.EncodeI32(statusInvalidArgument)
+	case !p.initializing:
+		stack[0] = api.EncodeI32(statusPermissionDenied)
+	case bindings[name] == nil:
+		stack[0] = api.EncodeI32(statusUnknownHook)
+	default:
+		p.registered = append(p.registered, name)
+		stack[0] = api.EncodeI32(statusOK)
+	}
+}
+
+func (p *Plugin) hostGetSettings(_ context.Context, mod api.Module, stack []uint64) {
+	stack[0] = api.EncodeI64(writeBuffer(mod, stack[0], stack[1], p.runtime.pluginSettings(p.name)))
+}
+
+func (p *Plugin) hostPadGetText(_ context.Context, mod api.Module, stack []uint64) {
+	if !p.hasPermission(PermissionPadRead) {
+		stack[0] = api.EncodeI64(-statusPermissionDenied)
+		return
+	}
+	padId, ok := readString(mod, stack[0], stack[1])
+	if !ok {
+		stack[0] = api.EncodeI64(-statusInvalidArgument)
+		return
+	}
+	retrievedPad, status := p.existingPad(padId)
+	if status != statusOK {
+		stack[0] = api.EncodeI64(-int64(status))
+		return
+	}
+	stack[0] = api.EncodeI64(writeBuffer(mod, stack[2], stack[3], []byte(retrievedPad.Text())))
+}
+
+func (p *Plugin) hostPadSetText(appendText bool) api.GoModuleFunc {
+	return func(_ context.Context, mod api.Module, stack []uint64) {
+		// Edits fire padUpdate, which must not reach a plugin still in ep_init.
+		if !p.hasPermission(PermissionPadWrite) || p.initializing {
+			stack[0] = api.EncodeI32(statusPermissionDenied)
+			return
+		}
+		padId, ok := readString(mod, stack[0], stack[1])
+		text, textOk := readString(mod, stack[2], stack[3])
+		if !ok || !textOk {
+			stack[0] = api.EncodeI32(statusInvalidArgument)
+			return
+		}
+		retrievedPad, status := p.existingPad(padId)
+		if status != statusOK {
+			stack[0] = api.EncodeI32(int32(status))
+			return
+		}
+		if appendText {
+			text = strings.TrimSuffix(retrievedPad.Text(), "\n") + text
+		}
+		if err := retrievedPad.SetText(text, nil); err != nil {
+			p.runtime.logger.Warnf("WebAssembly plugin %s could not edit pad %s: %v", p.name, padId, err)
+			stack[0] = api.EncodeI32(statusInternalError)
+			return
+		}
+		if p.runtime.updater != nil {
+			p.runtime.updater.UpdatePadClients(retrievedPad)
+		}
+		stack[0] = api.EncodeI32(statusOK)
+	}
+}
+
+// existingPad returns the pad padId without creating it.
+func (p *Plugin) existingPad(padId string) (*padModel.Pad, int) {
+	padManager := p.runtime.padManager
+	if !padManager.IsValidPadId(padId) {
+		return nil, statusInvalidArgument
+	}
+	exists, err := padManager.DoesPadExist(padId)
+	if err != nil {
+		return nil, statusInternalError
+	}
+	if !*exists {
+		return nil, statusNotFound
+	}
+	retrievedPad, err := padManager.GetPad(padId, nil, nil)
+	if err != nil {
+		return nil, statusInternalError
+	}
+	return retrievedPad, statusOK
+}
@@ -0,0 +1,335 @@
+package wasm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+var errNotLoaded = errors.New("plugin is not loaded")
+
+// asyncQueueSize bounds the informational hook calls waiting for a plugin.
+const asyncQueueSize = 256
+
+type asyncCall struct {
+	hook    string
+	payload []byte
+}
+
+// Plugin is one WebAssembly plugin. A module instance is not safe for
+// concurrent use, so calls into it are serialised by callMu.
+type Plugin struct {
+	name     string
+	dir      string
+	runtime  *Runtime
+	manifest Manifest
+
+	// mu guards the fields below it.
+	mu      sync.Mutex
+	wazero  wazero.Runtime
+	module  api.Module
+	hookIDs map[string]string
+	async   chan asyncCall
+	done    chan struct{}
+	lastErr error
+	// initializing is set while ep_init runs, the only time hooks may be
+	// registered.
+	initializing bool
+	registered   []string
+
+	callMu sync.Mutex
+}
+
+func (p *Plugin) readManifest() error {
+	content, err := os.ReadFile(filepath.Join(p.dir, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &p.manifest); err != nil {
+		return fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	for _, permission := range p.manifest.Permissions {
+		if permission != PermissionPadRead && permission != PermissionPadWrite {
+			return fmt.Errorf("unknown permission %q", permission)
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) info() PluginInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info := PluginInfo{Name: p.name, Manifest: p.manifest, Dir: p.dir, Enabled: p.module != nil}
+	if p.lastErr != nil {
+		info.Error = p.lastErr.Error()
+	}
+	return info
+}
+
+func (p *Plugin) loaded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.module != nil
+}
+
+func (p *Plugin) hasPermission(permission string) bool {
+	return slices.Contains(p.manifest.Permissions, permission)
+}
+
+// load compiles and instantiates the module, runs ep_init and binds the
+// hooks it registered. Loading a loaded plugin does nothing.
+func (p *Plugin) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.module != nil {
+		return nil
+	}
+	p.lastErr = nil
+	err := p.instantiate()
+	if err != nil {
+		p.lastErr = err
+	}
+	return err
+}
+
+func (p *Plugin) instantiate() error {
+	// Reading the manifest again picks up permission changes on re-enable.
+	if err := p.readManifest(); err != nil {
+		return err
+	}
+	binary, err := os.ReadFile(filepath.Join(p.dir, ModuleFile))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	config := wazero.NewRuntimeConfig().
+		WithCompilationCache(p.runtime.cache).
+		WithMemoryLimitPages(p.runtime.maxPages).
+		WithCloseOnContextDone(true)
+	rt := wazero.NewRuntimeWithConfig(ctx, config)
+	cleanup := func(err error) error {
+		_ = rt.Close(ctx)
+		return err
+	}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		return cleanup(err)
+	}
+	if _, err := p.hostModule(rt).Instantiate(ctx); err != nil {
+		return cleanup(err)
+	}
+	compiled, err := rt.CompileModule(ctx, binary)
+	if err != nil {
+		return cleanup(fmt.Errorf("compiling module: %w", err))
+	}
+	exports := compiled.ExportedFunctions()
+	for _, required := range []string{"ep_alloc", "ep_hook"} {
+		if _, ok := exports[required]; !ok {
+			return cleanup(fmt.Errorf("module does not export %s", required))
+		}
+	}
+
+	// No file system, environment or arguments: the module only reaches the
+	// server through the etherpad host module.
+	moduleConfig := wazero.NewModuleConfig().
+		WithName(p.name).
+		WithStartFunctions("_initialize").
+		WithStdout(&logWriter{plugin: p}).
+		WithStderr(&logWriter{plugin: p}).
+		WithRandSource(rand.Reader).
+		WithSysWalltime().
+		WithSysNanotime()
+	initCtx, cancel := context.WithTimeout(ctx, p.runtime.timeout)
+	defer cancel()
+	module, err := rt.InstantiateModule(initCtx, compiled, moduleConfig)
+	if err != nil {
+		return cleanup(fmt.Errorf("instantiating module: %w", err))
+	}
+	if module.Memory() == nil {
+		return cleanup(errors.New("module does not export its memory"))
+	}
+
+	if version := module.ExportedFunction("ep_abi_version"); version != nil {
+		result, err := version.Call(initCtx)
+		if err != nil {
+			return cleanup(err)
+		}
+		if len(result) != 1 || result[0] != ABIVersion {
+			return cleanup(fmt.Errorf("module uses ABI version %v, the server supports %d", result, ABIVersion))
+		}
+	}
+
+	p.registered = nil
+	if initFn := module.ExportedFunction("ep_init"); initFn != nil {
+		p.initializing = true
+		_, err := initFn.Call(initCtx)
+		p.initializing = false
+		if err != nil {
+			return cleanup(fmt.Errorf("ep_init: %w", err))
+		}
+	}
+
+	p.wazero = rt
+	p.module = module
+	p.async = make(chan asyncCall, asyncQueueSize)
+	p.done = make(chan struct{})
+	go p.runAsync(p.async, p.done)
+	p.bindHooks()
+	return nil
+}
+
+// unload removes the hooks and closes the module. cause is recorded as the
+// plugin's error if set.
+func (p *Plugin) unload(cause error) {
+	// Waiting for a running call keeps it from failing on a closed module.
+	p.callMu.Lock()
+	defer p.callMu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cause != nil {
+		p.lastErr = cause
+	}
+	if p.module == nil {
+		return
+	}
+	for hook, id := range p.hookIDs {
+		p.runtime.hooks.DequeueHook(hook, id)
+	}
+	p.hookIDs = nil
+	close(p.done)
+	_ = p.wazero.Close(context.Background())
+	p.module, p.wazero, p.async, p.done = nil, nil, nil, nil
+}
+
+// fail unloads the plugin after a trap or timeout, as the module state can no
+// longer be trusted.
+func (p *Plugin) fail(hook string, err error) {
+	p.runtime.logger.Errorf("WebAssembly plugin %s failed in hook %s and was disabled: %v", p.name, hook, err)
+	go p.unload(fmt.Errorf("failed in hook %s: %w", hook, err))
+}
+
+// runAsync delivers informational hooks, so that a slow plugin does not hold
+// up the caller and a plugin editing pads from padUpdate does not deadlock.
+func (p *Plugin) runAsync(calls <-chan asyncCall, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case call := <-calls:
+			if _, err := p.call(call.hook, call.payload); err != nil && !errors.Is(err, errNotLoaded) {
+				p.fail(call.hook, err)
+			}
+		}
+	}
+}
+
+func (p *Plugin) enqueue(hook string, payload []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.async == nil {
+		return
+	}
+	select {
+	case p.async <- asyncCall{hook: hook, payload: payload}:
+	default:
+		p.runtime.logger.Warnf("WebAssembly plugin %s is too slow, dropping %s event", p.name, hook)
+	}
+}
+
+// call runs ep_hook(name, payload) and returns the JSON the plugin answered
+// with, nil if it returned nothing.
+func (p *Plugin) call(hook string, payload []byte) ([]byte, error) {
+	p.callMu.Lock()
+	defer p.callMu.Unlock()
+	p.mu.Lock()
+	module := p.module
+	p.mu.Unlock()
+	if module == nil {
+		return nil, errNotLoaded
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.runtime.timeout)
+	defer cancel()
+	namePtr, err := p.write(ctx, module, []byte(hook))
+	if err != nil {
+		return nil, err
+	}
+	payloadPtr, err := p.write(ctx, module, payload)
+	if err != nil {
+		return nil, err
+	}
+	result, err := module.ExportedFunction("ep_hook").Call(ctx,
+		uint64(namePtr), uint64(len(hook)), uint64(payloadPtr), uint64(len(payload)))
+	p.free(ctx, module, namePtr)
+	p.free(ctx, module, payloadPtr)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != 1 || result[0] == 0 {
+		return nil, nil
+	}
+	ptr, size := uint32(result[0]>>32), uint32(result[0])
+	out, ok := module.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("result out of memory bounds")
+	}
+	out = slices.Clone(out)
+	p.free(ctx, module, ptr)
+	return out, nil
+}
+
+// write copies data into memory allocated with ep_alloc.
+func (p *Plugin) write(ctx context.Context, module api.Module, data []byte) (uint32, error) {
+	result, err := module.ExportedFunction("ep_alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	if len(result) != 1 {
+		return 0, errors.New("ep_alloc returned no pointer")
+	}
+	ptr := uint32(result[0])
+	if !module.Memory().Write(ptr, data) {
+		return 0, errors.New("ep_alloc returned memory out of bounds")
+	}
+	return ptr, nil
+}
+
+func (p *Plugin) free(ctx context.Context, module api.Module, ptr uint32) {
+	if free := module.ExportedFunction("ep_free"); free != nil {
+		_, _ = free.Call(ctx, uint64(ptr))
+	}
+}
+
+// logWriter logs what a module writes to stdout or stderr line by line.
+type logWriter struct {
+	plugin  *Plugin
+	pending string
+}
+
+func (w *logWriter) Write(data []byte) (int, error) {
+	w.pending += string(data)
+	for {
+		idx := strings.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		w.plugin.runtime.logger.Infof("[%s] %s", w.plugin.name, w.pending[:idx])
+		w.pending = w.pending[idx+1:]
+	}
+	return len(data), nil
+}
@@ -0,0 +1,232 @@
+// Package wasm runs external plugins compiled to WebAssembly. Each plugin is
+// a directory below settings.WasmPlugins.Dir holding plugin.wasm, an
+// optional plugin.json manifest and an optional static/ directory served at
+// /static/wasm/<name>/. Plugins are enabled through settings.Plugins like the
+// built-in ones, but can be enabled and disabled at runtime.
+//
+// Modules run in wazero with WASI but without file system, environment or
+// network access. They talk to the server through the ABI described in
+// doc/wasm_plugins.md: JSON payloads for hooks and a few host functions for
+// logging, settings and, when the manifest grants it, pad access.
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/pad"
+	"github.com/ether/etherpad-go/lib/plugins/interfaces"
+	"github.com/ether/etherpad-go/lib/settings"
+	"github.com/gofiber/fiber/v3"
+	"github.com/tetratelabs/wazero"
+	"go.uber.org/zap"
+)
+
+const (
+	ModuleFile   = "plugin.wasm"
+	ManifestFile = "plugin.json"
+	StaticDir    = "static"
+
+	// ABIVersion is the version of the host ABI. Modules exporting
+	// ep_abi_version must return it.
+	ABIVersion = 1
+
+	PermissionPadRead  = "pad:read"
+	PermissionPadWrite = "pad:write"
+)
+
+var pluginNameRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Manifest is the plugin.json file of a plugin.
+type Manifest struct {
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	// Permissions grants access to host functions, see PermissionPadRead and
+	// PermissionPadWrite.
+	Permissions []string `json:"permissions"`
+}
+
+// PluginInfo describes a discovered plugin.
+type PluginInfo struct {
+	Name     string
+	Manifest Manifest
+	Dir      string
+	Enabled  bool
+	// Error is why the plugin failed to load or was unloaded.
+	Error string
+}
+
+// Runtime discovers, loads and unloads the WebAssembly plugins.
+type Runtime struct {
+	mu         sync.Mutex
+	dir        string
+	hooks      *hooks.Hook
+	padManager *pad.Manager
+	updater    interfaces.PadClientUpdater
+	settings   *settings.Settings
+	logger     *zap.SugaredLogger
+	timeout    time.Duration
+	maxPages   uint32
+	cache      wazero.CompilationCache
+	plugins    map[string]*Plugin
+	// reserved are names that plugins cannot take, the built-in plugins.
+	reserved []string
+}
+
+// NewRuntime creates the runtime for the plugins in s.WasmPlugins.Dir.
+// Names in reserved, those of the built-in plugins, are skipped.
+func NewRuntime(s *settings.Settings, h *hooks.Hook, padManager *pad.Manager, updater interfaces.PadClientUpdater, logger *zap.SugaredLogger, reserved []string) *Runtime {
+	timeout := time.Duration(s.WasmPlugins.HookTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	maxMemoryMB := s.WasmPlugins.MaxMemoryMB
+	if maxMemoryMB <= 0 {
+		maxMemoryMB = 64
+	}
+	return &Runtime{
+		dir:        s.WasmPlugins.Dir,
+		hooks:      h,
+		padManager: padManager,
+		updater:    updater,
+		settings:   s,
+		logger:     logger,
+		timeout:    timeout,
+		// A WebAssembly page is 64 KiB.
+		maxPages: uint32(maxMemoryMB * 16),
+		cache:    wazero.NewCompilationCache(),
+		plugins:  map[string]*Plugin{},
+		reserved: reserved,
+	}
+}
+
+// Load discovers the plugins and loads those enabled in the settings. A
+// plugin failing to load is logged and skipped.
+func (r *Runtime) Load() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if _, ok := r.plugins[name]; ok {
+			continue
+		}
+		dir := filepath.Join(r.dir, name)
+		if _, err := os.Stat(filepath.Join(dir, ModuleFile)); err != nil {
+			continue
+		}
+		if !pluginNameRegex.MatchString(name) || slices.Contains(r.reserved, name) {
+			r.logger.Warnf("Skipping WebAssembly plugin %q: invalid or reserved name", name)
+			continue
+		}
+		plugin := &Plugin{name: name, dir: dir, runtime: r}
+		if err := plugin.readManifest(); err != nil {
+			r.logger.Warnf("Skipping WebAssembly plugin %s: %v", name, err)
+			continue
+		}
+		r.plugins[name] = plugin
+		if r.settings.IsPluginEnabled(name) {
+			if err := plugin.load(); err != nil {
+				r.logger.Errorf("Error loading WebAssembly plugin %s: %v", name, err)
+				continue
+			}
+			r.logger.Infof("Loaded WebAssembly plugin: %s", name)
+		}
+	}
+	return nil
+}
+
+// Plugins lists the discovered plugins sorted by name.
+func (r *Runtime) Plugins() []PluginInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]PluginInfo, 0, len(r.plugins))
+	for _, plugin := range r.plugins {
+		infos = append(infos, plugin.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// SetEnabled loads or unloads a plugin and records the state in the
+// settings. The settings file is not written.
+func (r *Runtime) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plugin, ok := r.plugins[name]
+	if !ok {
+		return fmt.Errorf("unknown WebAssembly plugin %q", name)
+	}
+	if enabled {
+		if err := plugin.load(); err != nil {
+			return err
+		}
+	} else {
+		plugin.unload(nil)
+	}
+	r.settings.SetPluginEnabled(name, enabled)
+	r.logger.Infof("WebAssembly plugin %s enabled: %t", name, enabled)
+	return nil
+}
+
+// Close unloads all plugins.
+func (r *Runtime) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, plugin := range r.plugins {
+		plugin.unload(nil)
+	}
+	_ = r.cache.Close(context.Background())
+}
+
+// RegisterRoutes serves the static assets of enabled plugins.
+func (r *Runtime) RegisterRoutes(app *fiber.App) {
+	app.Get("/static/wasm/:plugin/*", func(c fiber.Ctx) error {
+		r.mu.Lock()
+		plugin, ok := r.plugins[c.Params("plugin")]
+		enabled := ok && plugin.loaded()
+		r.mu.Unlock()
+		if !enabled {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		// Cleaning the rooted path drops any ".." leaving the static dir.
+		file := filepath.Join(plugin.dir, StaticDir, filepath.FromSlash(path.Clean("/"+c.Params("*"))))
+		if stat, err := os.Stat(file); err != nil || stat.IsDir() {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		return c.SendFile(file)
+	})
+}
+
+// pluginSettings returns the settings of the plugin as JSON.
+func (r *Runtime) pluginSettings(name string) []byte {
+	values := r.settings.PluginConfig(name).Settings
+	if values == nil {
+		values = map[string]any{}
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return []byte("{}")
+	}
+	return encoded
+}
@@ -0,0 +1,222 @@
+package wasm
+
+import (
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/hooks/events"
+	"github.com/ether/etherpad-go/lib/pad"
+	"github.com/ether/etherpad-go/lib/settings"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var (
+	guestOnce   sync.Once
+	guestBinary []byte
+	guestErr    error
+)
+
+// buildGuest compiles testdata/guest once per test run.
+func buildGuest(t *testing.T) []byte {
+	t.Helper()
+	guestOnce.Do(func() {
+		out := filepath.Join(os.TempDir(), "etherpad-wasm-guest-test.wasm")
+		cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, "./testdata/guest")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			guestErr = err
+			guestBinary = output
+			return
+		}
+		guestBinary, guestErr = os.ReadFile(out)
+	})
+	if guestErr != nil {
+		t.Skipf("cannot build the test guest: %v %s", guestErr, guestBinary)
+	}
+	return guestBinary
+}
+
+type testEnv struct {
+	runtime    *Runtime
+	hooks      *hooks.Hook
+	padManager *pad.Manager
+	settings   *settings.Settings
+}
+
+func writePlugin(t *testing.T, dir, name, manifest string) {
+	t.Helper()
+	pluginDir := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Join(pluginDir, StaticDir), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(pluginDir, ModuleFile), buildGuest(t), 0o644))
+	if manifest != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(pluginDir, ManifestFile), []byte(manifest), 0o644))
+	}
+}
+
+func newTestEnv(t *testing.T, dir string, enabled ...string) *testEnv {
+	t.Helper()
+	hook := hooks.NewHook()
+	padManager := pad.NewManager(db.NewMemoryDataStore(), &hook)
+	s := &settings.Settings{
+		WasmPlugins: settings.WasmPlugins{Enabled: true, Dir: dir, MaxMemoryMB: 256, HookTimeout: 5000},
+		Plugins:     map[string]settings.PluginSettings{},
+	}
+	for _, name := range enabled {
+		s.Plugins[name] = settings.PluginSettings{Enabled: true, Settings: map[string]any{"prefix": "> "}}
+	}
+	runtime := NewRuntime(s, &hook, padManager, nil, zap.NewNop().Sugar(), []string{"ep_markdown"})
+	t.Cleanup(runtime.Close)
+	require.NoError(t, runtime.Load())
+	return &testEnv{runtime: runtime, hooks: &hook, padManager: padManager, settings: s}
+}
+
+func chat(env *testEnv, text string) *events.ChatNewMessageContext {
+	ctx := &events.ChatNewMessageContext{Text: &text, PadId: "test", AuthorId: "a.1"}
+	env.hooks.ExecuteChatNewMessageHooks(ctx)
+	return ctx
+}
+
+func TestRuntime_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "guest", `{"description":"Test plugin","version":"1.0.0"}`)
+	env := newTestEnv(t, dir, "guest")
+
+	infos := env.runtime.Plugins()
+	require.Len(t, infos, 1)
+	assert.Equal(t, "guest", infos[0].Name)
+	assert.True(t, infos[0].Enabled)
+	assert.Equal(t, "1.0.0", infos[0].Manifest.Version)
+	assert.Empty(t, infos[0].Error)
+
+	ctx := chat(env, "hello")
+	assert.Equal(t, "> HELLO", *ctx.Text)
+	assert.False(t, ctx.Dropped())
+	assert.True(t, chat(env, "buy spam").Dropped())
+
+	fileName := &events.ExportFileNameContext{PadId: "notes", ExportType: "txt"}
+	env.hooks.ExecuteExportFileNameHooks(fileName)
+	assert.Equal(t, "custom-notes", fileName.FileName())
+}
+
+func TestRuntime_Permissions(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "reader", `{"permissions":["pad:read","pad:write"]}`)
+	writePlugin(t, dir, "restricted", "")
+
+	t.Run("granted", func(t *testing.T) {
+		env := newTestEnv(t, dir, "reader")
+		text := "hello"
+		_, err := env.padManager.GetPad("notes", &text, nil)
+		require.NoError(t, err)
+
+		styles := &events.StylesForExportContext{PadId: "notes"}
+		env.hooks.ExecuteStylesForExportHooks(styles)
+		assert.Equal(t, "/* 6 chars */", styles.Styles())
+
+		styles = &events.StylesForExportContext{PadId: "missing"}
+		env.hooks.ExecuteStylesForExportHooks(styles)
+		assert.Equal(t, "/* error 2 */", styles.Styles())
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		env := newTestEnv(t, dir, "restricted")
+		text := "hello\n"
+		_, err := env.padManager.GetPad("notes", &text, nil)
+		require.NoError(t, err)
+
+		styles := &events.StylesForExportContext{PadId: "notes"}
+		env.hooks.ExecuteStylesForExportHooks(styles)
+		assert.Equal(t, "/* error 1 */", styles.Styles())
+	})
+}
+
+func TestRuntime_AsyncPadUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "auditor", `{"permissions":["pad:write"]}`)
+	env := newTestEnv(t, dir, "auditor")
+
+	empty := "\n"
+	audit, err := env.padManager.GetPad("audit", &empty, nil)
+	require.NoError(t, err)
+	text := "first\n"
+	notes, err := env.padManager.GetPad("notes", &text, nil)
+	require.NoError(t, err)
+	require.NoError(t, notes.SetText("second\n", nil))
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(audit.Text(), "notes@1")
+	}, 10*time.Second, 20*time.Millisecond)
+}
+
+func TestRuntime_SetEnabled(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "guest", "")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "guest", StaticDir, "plugin.js"), []byte("ok"), 0o644))
+	env := newTestEnv(t, dir)
+	app := fiber.New()
+	env.runtime.RegisterRoutes(app)
+
+	get := func(target string) int {
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.False(t, env.runtime.Plugins()[0].Enabled)
+	assert.Equal(t, "hello", *chat(env, "hello").Text)
+	assert.Equal(t, fiber.StatusNotFound, get("/static/wasm/guest/plugin.js"))
+
+	require.NoError(t, env.runtime.SetEnabled("guest", true))
+	assert.True(t, env.settings.IsPluginEnabled("guest"))
+	assert.Equal(t, "HELLO", *chat(env, "hello").Text)
+	assert.Equal(t, fiber.StatusOK, get("/static/wasm/guest/plugin.js"))
+	assert.Equal(t, fiber.StatusNotFound, get("/static/wasm/guest/..%2Fplugin.wasm"))
+
+	require.NoError(t, env.runtime.SetEnabled("guest", false))
+	assert.False(t, env.settings.IsPluginEnabled("guest"))
+	assert.Equal(t, "hello", *chat(env, "hello").Text)
+	assert.Error(t, env.runtime.SetEnabled("unknown", true))
+}
+
+func TestRuntime_SkipsInvalidPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "ep_markdown", "")
+	writePlugin(t, dir, "Invalid Name", "")
+	writePlugin(t, dir, "bad_manifest", `{"permissions":["fs:write"]}`)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "broken"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken", ModuleFile), []byte("not wasm"), 0o644))
+
+	env := newTestEnv(t, dir, "ep_markdown", "broken")
+	infos := env.runtime.Plugins()
+	require.Len(t, infos, 1)
+	assert.Equal(t, "broken", infos[0].Name)
+	assert.False(t, infos[0].Enabled)
+	assert.Contains(t, infos[0].Error, "compiling module")
+}
+
+func TestRuntime_TimeoutUnloadsPlugin(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "guest", "")
+	env := newTestEnv(t, dir, "guest")
+	env.runtime.timeout = 200 * time.Millisecond
+
+	plugin := env.runtime.plugins["guest"]
+	_, err := plugin.call("loop", []byte("{}"))
+	require.Error(t, err)
+	plugin.fail("loop", err)
+
+	require.Eventually(t, func() bool { return !plugin.loaded() }, 5*time.Second, 20*time.Millisecond)
+	assert.Contains(t, env.runtime.Plugins()[0].Error, "failed in hook loop")
+	assert.Equal(t, "hello", *chat(env, "hello").Text)
+}
@@ -0,0 +1,158 @@
+//go:build wasip1
+
+// Command guest is the WebAssembly plugin used by the runtime tests. Build it
+// with GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared.
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+//go:wasmimport etherpad log
+func hostLog(level, ptr, size uint32)
+
+//go:wasmimport etherpad register_hook
+func registerHook(ptr, size uint32) uint32
+
+//go:wasmimport etherpad get_settings
+func getSettings(ptr, size uint32) int64
+
+//go:wasmimport etherpad pad_get_text
+func padGetText(idPtr, idLen, ptr, size uint32) int64
+
+//go:wasmimport etherpad pad_append_text
+func padAppendText(idPtr, idLen, textPtr, textLen uint32) uint32
+
+// allocations keeps the buffers handed to the host alive until ep_free.
+var allocations = map[uint32][]byte{}
+
+func pointer(data []byte) uint32 {
+	if len(data) == 0 {
+		return 0
+	}
+	return uint32(uintptr(unsafe.Pointer(&data[0])))
+}
+
+func bytesOf(s string) (uint32, uint32) {
+	data := []byte(s)
+	ptr := pointer(data)
+	allocations[ptr] = data
+	return ptr, uint32(len(data))
+}
+
+func read(ptr, size uint32) []byte {
+	if size == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size)
+}
+
+// fetch calls a host function filling a buffer, growing it once if needed.
+func fetch(call func(ptr, size uint32) int64) (string, int64) {
+	buf := make([]byte, 64)
+	n := call(pointer(buf), uint32(len(buf)))
+	if n > int64(len(buf)) {
+		buf = make([]byte, n)
+		n = call(pointer(buf), uint32(len(buf)))
+	}
+	if n < 0 {
+		return "", n
+	}
+	return string(buf[:n]), n
+}
+
+func logInfo(message string) {
+	ptr, size := bytesOf(message)
+	hostLog(1, ptr, size)
+	delete(allocations, ptr)
+}
+
+//go:wasmexport ep_abi_version
+func abiVersion() uint32 { return 1 }
+
+//go:wasmexport ep_alloc
+func alloc(size uint32) uint32 {
+	data := make([]byte, size+1)
+	ptr := pointer(data)
+	allocations[ptr] = data
+	return ptr
+}
+
+//go:wasmexport ep_free
+func free(ptr uint32) { delete(allocations, ptr) }
+
+//go:wasmexport ep_init
+func initPlugin() {
+	for _, hook := range []string{"chatNewMessage", "padUpdate", "getLineHTMLForExport", "exportFileName", "stylesForExport", "unknownHook"} {
+		ptr, size := bytesOf(hook)
+		if status := registerHook(ptr, size); status != 0 {
+			logInfo("could not register " + hook + ": " + strconv.Itoa(int(status)))
+		}
+		delete(allocations, ptr)
+	}
+}
+
+//go:wasmexport ep_hook
+func hook(namePtr, nameLen, payloadPtr, payloadLen uint32) uint64 {
+	name := string(read(namePtr, nameLen))
+	var payload map[string]any
+	_ = json.Unmarshal(read(payloadPtr, payloadLen), &payload)
+
+	var result any
+	switch name {
+	case "chatNewMessage":
+		text := payload["text"].(string)
+		if strings.Contains(text, "spam") {
+			result = map[string]any{"drop": true}
+			break
+		}
+		settings, _ := fetch(getSettings)
+		var values map[string]string
+		_ = json.Unmarshal([]byte(settings), &values)
+		result = map[string]any{"text": values["prefix"] + strings.ToUpper(text)}
+	case "padUpdate":
+		padId := payload["padId"].(string)
+		if padId == "audit" {
+			return 0
+		}
+		idPtr, idLen := bytesOf("audit")
+		textPtr, textLen := bytesOf(padId + "@" + strconv.Itoa(int(payload["revision"].(float64))) + "\n")
+		padAppendText(idPtr, idLen, textPtr, textLen)
+		delete(allocations, idPtr)
+		delete(allocations, textPtr)
+	case "getLineHTMLForExport":
+		content := payload["lineContent"].(string)
+		attributes := payload["lineAttributes"].(map[string]any)
+		if heading, ok := attributes["heading"].(string); ok {
+			content = "<" + heading + ">" + content + "</" + heading + ">"
+		}
+		result = map[string]any{"lineContent": "<mark>" + content + "</mark>"}
+	case "exportFileName":
+		result = map[string]any{"fileName": "custom-" + payload["padId"].(string)}
+	case "stylesForExport":
+		padId := payload["padId"].(string)
+		idPtr, idLen := bytesOf(padId)
+		text, n := fetch(func(ptr, size uint32) int64 { return padGetText(idPtr, idLen, ptr, size) })
+		delete(allocations, idPtr)
+		if n < 0 {
+			result = map[string]any{"css": "/* error " + strconv.Itoa(int(-n)) + " */"}
+		} else {
+			result = map[string]any{"css": "/* " + strconv.Itoa(len(text)) + " chars */"}
+		}
+	case "loop":
+		for {
+		}
+	}
+	if result == nil {
+		return 0
+	}
+	encoded, _ := json.Marshal(result)
+	ptr := pointer(encoded)
+	allocations[ptr] = encoded
+	return uint64(ptr)<<32 | uint64(len(encoded))
+}
+
+func main() {}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"net/http"
@@ -21,6 +22,7 @@ import (
 	"github.com/ether/etherpad-go/lib/plugins/interfaces"
 	epsession "github.com/ether/etherpad-go/lib/session"
 	settings2 "github.com/ether/etherpad-go/lib/settings"
+	"github.com/ether/etherpad-go/lib/tracing"
 	"github.com/ether/etherpad-go/lib/utils"
 	"github.com/ether/etherpad-go/lib/ws"
 	"github.com/go-playground/validator/v10"
@@ -58,6 +60,12 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 	setupLogger.Info("Your Etherpad Go version is " + gitVersion)
 	settings.GitVersion = gitVersion
 
+	shutdownTracing, err := tracing.Init(settings.Tracing, gitVersion, setupLogger)
+	if err != nil {
+		setupLogger.Fatal("Error initializing tracing: " + err.Error())
+		return
+	}
+
 	dataStore, err := utils.GetDB(settings, setupLogger)
 	if err != nil {
 		setupLogger.Fatal("Error connecting to database: " + err.Error())
@@ -67,6 +75,9 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 	readOnlyManager := pad.NewReadOnlyManager(dataStore)
 
 	app := fiber.New(fiber.Config{})
+	if settings.Tracing.Enabled {
+		app.Use(tracing.Middleware())
+	}
 	app.Use(compress.New(compress.Config{
 		Level: compress.LevelBestSpeed,
 	}))
@@ -78,6 +89,11 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 	padManager := pad.NewManager(dataStore, &retrievedHooks)
 	authorManager := author.NewManager(dataStore)
 	importer := io.NewImporter(padManager, authorManager, dataStore, setupLogger, &retrievedHooks)
+	// Built-in formats are registered first so that plugins can add to and
+	// override them.
+	formats := io.NewFormatRegistry()
+	io.NewExportEtherpad(&retrievedHooks, padManager, dataStore, setupLogger, uiAssets).RegisterFormats(formats)
+	importer.RegisterFormats(formats)
 	globalHub := ws.NewHub()
 	// Started after the hub so a drain can warn connected clients of the
 	// imminent restart via a sticky shout.
@@ -86,6 +102,7 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 	})
 	sessionStore := ws.NewSessionStore()
 	padMessageHandler := ws.NewPadMessageHandler(dataStore, &retrievedHooks, padManager, &sessionStore, globalHub, setupLogger, uiAssets)
+	padMessageHandler.SetFormats(formats)
 	adminMessageHandler := ws.NewAdminMessageHandler(dataStore, &retrievedHooks, padManager, padMessageHandler, setupLogger, globalHub, app, upd)
 	securityManager := pad.NewSecurityManager(dataStore, &retrievedHooks, padManager)
 
@@ -96,6 +113,8 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 		PadManager:        padManager,
 		App:               app,
 		RetrievedSettings: &settings,
+		PadClientUpdater:  padMessageHandler,
+		Formats:           formats,
 	}
 
 	// init plugins
@@ -129,8 +148,32 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 		Store:             dataStore,
 		ReadOnlyManager:   readOnlyManager,
 		Importer:          importer,
+		Formats:           formats,
 	})
 
+	// Changes to the settings file are applied while the server runs where
+	// possible; the rest is reported as requiring a restart.
+	reloader := settings2.NewReloader(&settings, settings2.ConfigFile(), setupLogger)
+	reloader.TogglePlugin = func(name string, enabled bool) bool {
+		if plugins.ExternalPlugins == nil {
+			return false
+		}
+		if err := plugins.ExternalPlugins.SetEnabled(name, enabled); err != nil {
+			setupLogger.Warnf("Plugin %s cannot be switched without a restart: %v", name, err)
+			return false
+		}
+		return true
+	}
+	reloader.OnReload = func(s *settings2.Settings) {
+		authenticator.ReloadUsers(s.UserAccounts())
+		retrievedHooks.ExecuteLoadSettingsHooks(&events.LoadSettingsContext{Settings: s})
+	}
+	adminMessageHandler.Reloader = reloader
+	stopWatching, err := reloader.Watch()
+	if err != nil {
+		setupLogger.Warnf("Not watching the settings file for changes: %v", err)
+	}
+
 	// Pre-upgrade data is stored in a sync.Map keyed by a unique connection token,
 	// because gofiber/contrib/v3/websocket does not propagate Locals from the
 	// Fiber context to the websocket Conn.
@@ -260,9 +303,17 @@ func InitServer(setupLogger *zap.SugaredLogger, uiAssets embed.FS, pluginAssets
 	<-sigCh
 	setupLogger.Info("Shutting down Etherpad Go...")
 	retrievedHooks.ExecuteShutdownHooks(&events.ShutdownContext{})
+	if stopWatching != nil {
+		stopWatching()
+	}
 	upd.Stop()
 	authenticator.Stop()
 	if err := app.ShutdownWithTimeout(3 * time.Second); err != nil {
 		setupLogger.Warn("Error during shutdown: " + err.Error())
 	}
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		setupLogger.Warn("Error flushing traces: " + err.Error())
+	}
 }
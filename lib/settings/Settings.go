@@ -6,6 +6,7 @@ import (
 	"regexp"
 
 	clientVars2 "github.com/ether/etherpad-go/lib/models/clientVars"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
@@ -184,6 +185,19 @@ type CommitRateLimiting struct {
 	LoadTest bool `json:"loadTest" mapstructure:"loadTest"`
 }
 
+// Tracing configures OpenTelemetry tracing (see lib/tracing). Endpoint is
+// the host:port of an OTLP/HTTP collector; the standard OTEL_EXPORTER_OTLP_*
+// environment variables apply as well.
+type Tracing struct {
+	Enabled     bool              `json:"enabled" mapstructure:"enabled"`
+	Exporter    string            `json:"exporter" mapstructure:"exporter"`
+	Endpoint    string            `json:"endpoint" mapstructure:"endpoint"`
+	Insecure    bool              `json:"insecure" mapstructure:"insecure"`
+	Headers     map[string]string `json:"headers" mapstructure:"headers"`
+	SampleRatio float64           `json:"sampleRatio" mapstructure:"sampleRatio"`
+	ServiceName string            `json:"serviceName" mapstructure:"serviceName"`
+}
+
 type SSLSettings struct {
 	Key  string   `json:"key" mapstructure:"key"`
 	Cert string   `json:"cert" mapstructure:"cert"`
@@ -193,6 +207,19 @@ type SSLSettings struct {
 // PluginSettings definiert die Einstellungen für einzelne Plugins
 type PluginSettings struct {
 	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Settings is handed to WebAssembly plugins as JSON (see lib/plugins/wasm).
+	Settings map[string]any `json:"settings,omitempty" mapstructure:"settings"`
+}
+
+// WasmPlugins configures the runtime for external WebAssembly plugins (see
+// lib/plugins/wasm). Dir holds one directory per plugin; MaxMemoryMB limits
+// the linear memory of each module and HookTimeout (milliseconds) the time a
+// plugin may spend in one hook call.
+type WasmPlugins struct {
+	Enabled     bool   `json:"enabled" mapstructure:"enabled"`
+	Dir         string `json:"dir" mapstructure:"dir"`
+	MaxMemoryMB int    `json:"maxMemoryMB" mapstructure:"maxMemoryMB"`
+	HookTimeout int    `json:"hookTimeout" mapstructure:"hookTimeout"`
 }
 
 type Settings struct {
@@ -239,6 +266,8 @@ type Settings struct {
 
 	EnableMetrics bool `json:"enableMetrics" mapstructure:"enableMetrics"`
 
+	Tracing Tracing `json:"tracing" mapstructure:"tracing"`
+
 	RequireSession bool `json:"requireSession" mapstructure:"requireSession"`
 	EditOnly       bool `json:"editOnly" mapstructure:"editOnly"`
 	MaxAge         int  `json:"maxAge" mapstructure:"maxAge"`
@@ -294,7 +323,8 @@ type Settings struct {
 	AvailableExports     []string `json:"availableExports" mapstructure:"availableExports"`
 	IndentationOnNewLine bool     `json:"indentationOnNewLine" mapstructure:"indentationOnNewLine"`
 
-	Plugins map[string]PluginSettings `json:"plugins" mapstructure:"plugins"`
+	Plugins     map[string]PluginSettings `json:"plugins" mapstructure:"plugins"`
+	WasmPlugins WasmPlugins               `json:"wasmPlugins" mapstructure:"wasmPlugins"`
 
 	// Untracked fields
 	Root                string `json:"-"`
@@ -303,6 +333,8 @@ type Settings struct {
 }
 
 func (s *Settings) IsPluginEnabled(pluginName string) bool {
+	hotMu.RLock()
+	defer hotMu.RUnlock()
 	if s.Plugins == nil {
 		return false
 	}
@@ -318,6 +350,8 @@ type PluginPublicSettings struct {
 }
 
 func (s *Settings) GetAllPlugins() []PluginPublicSettings {
+	hotMu.RLock()
+	defer hotMu.RUnlock()
 	var plugins = make([]PluginPublicSettings, 0)
 	for name, plugin := range s.Plugins {
 		plugins = append(plugins, PluginPublicSettings{
@@ -328,7 +362,42 @@ func (s *Settings) GetAllPlugins() []PluginPublicSettings {
 	return plugins
 }
 
+// PluginConfig returns the settings of the plugin pluginName.
+func (s *Settings) PluginConfig(pluginName string) PluginSettings {
+	hotMu.RLock()
+	defer hotMu.RUnlock()
+	return s.Plugins[pluginName]
+}
+
+// SetPluginEnabled records whether the plugin pluginName is enabled.
+func (s *Settings) SetPluginEnabled(pluginName string, enabled bool) {
+	hotMu.Lock()
+	defer hotMu.Unlock()
+	plugin := s.Plugins[pluginName]
+	plugin.Enabled = enabled
+	s.Plugins = withPlugins(s.Plugins, map[string]PluginSettings{pluginName: plugin})
+}
+
+// UserAccounts returns the users that can log in. Reloads replace the map,
+// so it must not be modified.
+func (s *Settings) UserAccounts() map[string]User {
+	hotMu.RLock()
+	defer hotMu.RUnlock()
+	return s.Users
+}
+
+// CommitRateLimit returns the limit for commits, disabled in load test mode.
+func (s *Settings) CommitRateLimit() CommitRateLimiting {
+	hotMu.RLock()
+	defer hotMu.RUnlock()
+	limit := s.CommitRateLimiting
+	limit.LoadTest = s.LoadTest
+	return limit
+}
+
 func (s *Settings) GetPublicSettings() PublicSettings {
+	hotMu.RLock()
+	defer hotMu.RUnlock()
 	return PublicSettings{
 		GitVersion:          s.GitVersion,
 		Toolbar:             s.Toolbar,
@@ -383,6 +452,20 @@ func InitSettings(logger *zap.SugaredLogger) {
 		return
 	}
 
+	// Problems are reported but do not stop the server, which starts with the
+	// defaults for settings it cannot read.
+	if file := viper.ConfigFileUsed(); file != "" {
+		if content, err := os.ReadFile(file); err == nil {
+			for _, problem := range CheckConfig(content) {
+				if problem.Warning {
+					logger.Warnf("%s: %s", file, problem)
+				} else {
+					logger.Errorf("%s: %s", file, problem)
+				}
+			}
+		}
+	}
+
 	if setting.DBSettings != nil && setting.DBSettings.Filename != "" {
 		dbDir := filepath.Dir(setting.DBSettings.Filename)
 		if dbDir != "" && dbDir != "." {
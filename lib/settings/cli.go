@@ -28,6 +28,12 @@ func HandleConfigCommand(logger *zap.SugaredLogger) {
 		configGet(os.Args[3:])
 	case "init":
 		configInit()
+	case "validate":
+		if !configValidate(os.Args[3:]) {
+			os.Exit(1)
+		}
+	case "schema":
+		configSchema()
 	default:
 		fmt.Println("Unknown config command:", os.Args[2])
 		printConfigHelp()
@@ -116,11 +122,56 @@ func configInit() {
 	fmt.Println(string(b))
 }
 
+// configValidate checks the settings file given in args, or settings.json,
+// and reports whether it has no errors.
+func configValidate(args []string) bool {
+	if len(args) > 1 {
+		fmt.Println("Usage: etherpad config validate [file]")
+		return false
+	}
+	file := "settings.json"
+	if len(args) == 1 {
+		file = args[0]
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return false
+	}
+
+	problems := CheckConfig(content)
+	for _, problem := range problems {
+		level := "error"
+		if problem.Warning {
+			level = "warning"
+		}
+		fmt.Printf("%s: %s\n", level, problem)
+	}
+	if errs := len(Errors(problems)); errs > 0 {
+		fmt.Printf("%s: %d error(s)\n", file, errs)
+		return false
+	}
+	fmt.Printf("%s is valid\n", file)
+	return true
+}
+
+func configSchema() {
+	b, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	fmt.Println(string(b))
+}
+
 func printConfigHelp() {
 	fmt.Println(`Usage:
   etherpad config show
   etherpad config dump
   etherpad config env
   etherpad config get <json-key>
-  etherpad config init`)
+  etherpad config init
+  etherpad config validate [file]
+  etherpad config schema`)
 }
@@ -7,6 +7,7 @@ import (
 
 	apool2 "github.com/ether/etherpad-go/lib/apool"
 	author2 "github.com/ether/etherpad-go/lib/author"
+	"github.com/ether/etherpad-go/lib/io"
 	"github.com/ether/etherpad-go/lib/models/clientVars"
 	"github.com/ether/etherpad-go/lib/models/pad"
 	"github.com/ether/etherpad-go/lib/models/ws"
@@ -20,6 +21,9 @@ type Factory struct {
 	ReadOnlyManager *pad2.ReadOnlyManager
 	AuthorManager   *author2.Manager
 	UiAssets        embed.FS
+	// Formats, when set, adds the export formats of plugins to
+	// availableExports.
+	Formats *io.FormatRegistry
 }
 
 func (f *Factory) NewClientVars(pad pad.Pad, sessionInfo *ws.Session, apool apool2.APool, translatedAttribs string, historicalAuthorData map[string]author2.Author, retrievedSettings *settings.Settings, numConnectedUsers int) (*clientVars.ClientVars, error) {
@@ -138,6 +142,18 @@ func (f *Factory) NewClientVars(pad pad.Pad, sessionInfo *ws.Session, apool apoo
 		}
 	}
 
+	availableExports := retrievedSettings.AvailableExports
+	exportFormats := make([]clientVars.ExportFormat, 0)
+	if f.Formats != nil {
+		availableExports = make([]string, 0, len(retrievedSettings.AvailableExports))
+		for _, format := range f.Formats.AvailableExports(retrievedSettings.AvailableExports) {
+			availableExports = append(availableExports, format.Name)
+			if format.Label != "" {
+				exportFormats = append(exportFormats, clientVars.ExportFormat{Name: format.Name, Label: format.Label, Icon: format.Icon})
+			}
+		}
+	}
+
 	return &clientVars.ClientVars{
 		SkinName:            retrievedSettings.SkinName,
 		SkinVariants:        retrievedSettings.SkinVariants,
@@ -183,7 +199,8 @@ func (f *Factory) NewClientVars(pad pad.Pad, sessionInfo *ws.Session, apool apoo
 		SessionRefreshInterval:             86400000,
 		UserName:                           currentAuthor.Name,
 		UserId:                             sessionInfo.Author,
-		AvailableExports:                   retrievedSettings.AvailableExports,
+		AvailableExports:                   availableExports,
+		ExportFormats:                      exportFormats,
 		IndentationOnNewLine:               retrievedSettings.IndentationOnNewLine,
 		ScrollWhenFocusLineIsOutOfViewport: retrievedSettings.ScrollWhenFocusLineIsOutOfViewport,
 		Plugins:                            rootPlugin,
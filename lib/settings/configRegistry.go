@@ -122,6 +122,12 @@ var Registry = []ConfigKey{
 	// Misc / runtime
 	// ---------------------------------------------------------------------
 	{Key: EnableMetrics, Default: true, Description: "Enable metrics"},
+	{Key: TracingEnabled, Default: false, Description: "Enable OpenTelemetry tracing"},
+	{Key: TracingExporter, Default: "otlp", Description: "Tracing exporter (otlp or stdout)"},
+	{Key: TracingEndpoint, Default: "localhost:4318", Description: "OTLP/HTTP collector endpoint"},
+	{Key: TracingInsecure, Default: true, Description: "Send traces over plain HTTP"},
+	{Key: TracingSampleRatio, Default: 1.0, Description: "Share of traces sampled (0-1)"},
+	{Key: TracingServiceName, Default: "etherpad", Description: "Service name reported in traces"},
 	{Key: CleanupExpr, Default: true, Description: "Enable cleanup expressions"},
 	{Key: RequireSession, Default: false, Description: "Require session"},
 	{Key: EditOnly, Default: false, Description: "Edit-only mode"},
@@ -293,6 +299,10 @@ var Registry = []ConfigKey{
 	// Plugins
 	// ---------------------------------------------------------------------
 	{Key: EpAlignEnabled, Default: false, Description: "Enable ep_align plugin"},
+	{Key: WasmPluginsEnabled, Default: false, Description: "Load external WebAssembly plugins"},
+	{Key: WasmPluginsDir, Default: "wasm_plugins", Description: "Directory of the WebAssembly plugins"},
+	{Key: WasmPluginsMaxMemoryMB, Default: 64, Description: "Memory limit of a WebAssembly plugin in MB"},
+	{Key: WasmPluginsHookTimeout, Default: 1000, Description: "Time limit of a WebAssembly plugin hook call in ms"},
 	{
 		Key:         EpSpellcheckEnabled,
 		Default:     false,
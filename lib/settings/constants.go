@@ -10,6 +10,16 @@ const (
 	Port                                = "port"
 	ShowSettingsInAdminPage             = "showSettingsInAdminPage"
 	EnableMetrics                       = "enableMetrics"
+	TracingEnabled                      = "tracing.enabled"
+	TracingExporter                     = "tracing.exporter"
+	TracingEndpoint                     = "tracing.endpoint"
+	TracingInsecure                     = "tracing.insecure"
+	TracingSampleRatio                  = "tracing.sampleRatio"
+	TracingServiceName                  = "tracing.serviceName"
+	WasmPluginsEnabled                  = "wasmPlugins.enabled"
+	WasmPluginsDir                      = "wasmPlugins.dir"
+	WasmPluginsMaxMemoryMB              = "wasmPlugins.maxMemoryMB"
+	WasmPluginsHookTimeout              = "wasmPlugins.hookTimeout"
 	CleanupExpr                         = "cleanup"
 	CleanupEnabled                      = "cleanup.enabled"
 	CleanupKeepRevisions                = "cleanup.keepRevisions"
@@ -0,0 +1,361 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// hotReloadable are the top-level settings that take effect without a
+// restart, mapped to the function copying them into the running settings.
+// The plugins section is handled separately as only some plugins can be
+// switched at runtime.
+var hotReloadable = map[string]func(live, next *Settings){
+	"toolbar":             func(live, next *Settings) { live.Toolbar = next.Toolbar },
+	"padOptions":          func(live, next *Settings) { live.PadOptions = next.PadOptions },
+	"users":               func(live, next *Settings) { live.Users = next.Users },
+	"customLocaleStrings": func(live, next *Settings) { live.CustomLocaleStrings = next.CustomLocaleStrings },
+	"commitRateLimiting":  func(live, next *Settings) { live.CommitRateLimiting = next.CommitRateLimiting },
+}
+
+// hotMu guards the hot-reloadable sections and the plugins of the running
+// settings, which change while requests read them. Code outside this
+// package reads them through the accessors of Settings.
+var hotMu sync.RWMutex
+
+// watchDelay is how long the watcher waits for further writes before it
+// reloads, as editors often save a file in several steps.
+const watchDelay = 500 * time.Millisecond
+
+// ConfigFile returns the path of the settings file read at startup, or
+// settings.json in the working directory when there was none.
+func ConfigFile() string {
+	if file := viper.ConfigFileUsed(); file != "" {
+		return file
+	}
+	return "settings.json"
+}
+
+// ReloadResult describes the outcome of a reload. The entries are top-level
+// settings keys, or plugins.<name> for single plugins.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// PluginToggler switches the plugin name on or off at runtime. It returns
+// false when the plugin cannot change without a restart.
+type PluginToggler func(name string, enabled bool) bool
+
+// Reloader applies changes of the settings file to the running server.
+type Reloader struct {
+	mu     sync.Mutex
+	live   *Settings
+	path   string
+	logger *zap.SugaredLogger
+	// fileMu serialises writes of the settings file.
+	fileMu sync.Mutex
+	// TogglePlugin switches plugins whose enabled flag changed. Without it
+	// every plugin change requires a restart.
+	TogglePlugin PluginToggler
+	// OnReload is called with the running settings after changes were
+	// applied.
+	OnReload func(s *Settings)
+}
+
+// NewReloader creates a Reloader for the settings live, read from the file at
+// path.
+func NewReloader(live *Settings, path string, logger *zap.SugaredLogger) *Reloader {
+	return &Reloader{live: live, path: path, logger: logger}
+}
+
+// Reload reads the settings file again and applies it.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	next, err := parseChecked(content)
+	if err != nil {
+		return nil, err
+	}
+	return r.Apply(next)
+}
+
+// Write replaces the settings file with content and applies it. The file is
+// left untouched when content is not valid.
+func (r *Reloader) Write(content []byte) (*ReloadResult, error) {
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+	return r.write(content)
+}
+
+// SetPluginEnabled writes the enabled flag of the plugin name to the settings
+// file and applies it like Write. The file is created when there is none.
+func (r *Reloader) SetPluginEnabled(name string, enabled bool) (*ReloadResult, error) {
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+	content, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		content = []byte("{}")
+	} else if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	plugins, _ := raw["plugins"].(map[string]any)
+	if plugins == nil {
+		plugins = map[string]any{}
+		raw["plugins"] = plugins
+	}
+	plugin, _ := plugins[name].(map[string]any)
+	if plugin == nil {
+		plugin = map[string]any{}
+		plugins[name] = plugin
+	}
+	plugin["enabled"] = enabled
+	updated, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return r.write(updated)
+}
+
+func (r *Reloader) write(content []byte) (*ReloadResult, error) {
+	next, err := parseChecked(content)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".settings-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return nil, err
+	}
+	return r.Apply(next)
+}
+
+// parseChecked decodes the content of a settings file after CheckConfig found
+// no errors in it.
+func parseChecked(content []byte) (*Settings, error) {
+	if problems := Errors(CheckConfig(content)); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return ParseConfig(content)
+}
+
+// Apply validates next, compares it to the running settings and copies the
+// hot-reloadable sections that changed. Nothing is applied when next is
+// invalid. The other changes are listed as requiring a restart.
+func (r *Reloader) Apply(next *Settings) (*ReloadResult, error) {
+	if err := Validate(next); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next.Root = r.live.Root
+	next.GitVersion = r.live.GitVersion
+	next.RandomVersionString = r.live.RandomVersionString
+
+	hotMu.RLock()
+	changed, err := changedKeys(r.live, next)
+	plugins := r.live.Plugins
+	hotMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	var switched map[string]PluginSettings
+	for _, key := range changed {
+		if _, ok := hotReloadable[key]; ok {
+			result.Applied = append(result.Applied, key)
+		} else if key == "plugins" {
+			switched = r.togglePlugins(plugins, next, result)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	// Readers see either the old or the new sections, never a mix.
+	hotMu.Lock()
+	for _, s := range r.targets() {
+		for _, key := range result.Applied {
+			if apply, ok := hotReloadable[key]; ok {
+				apply(s, next)
+			}
+		}
+		if len(switched) > 0 {
+			s.Plugins = withPlugins(s.Plugins, switched)
+		}
+	}
+	hotMu.Unlock()
+	slices.Sort(result.Applied)
+	slices.Sort(result.RestartRequired)
+
+	if len(result.Applied) > 0 && r.OnReload != nil {
+		r.OnReload(r.live)
+	}
+	return result, nil
+}
+
+// targets returns the settings a reload is copied into.
+func (r *Reloader) targets() []*Settings {
+	if r.live == &Displayed {
+		return []*Settings{r.live}
+	}
+	return []*Settings{r.live, &Displayed}
+}
+
+// togglePlugins switches the plugins whose enabled flag changed between
+// current and next, and returns them. Changes to their settings, and
+// plugins the toggler refuses, need a restart.
+func (r *Reloader) togglePlugins(current map[string]PluginSettings, next *Settings, result *ReloadResult) map[string]PluginSettings {
+	names := slices.Sorted(maps.Keys(current))
+	for name := range next.Plugins {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	switched := map[string]PluginSettings{}
+	for _, name := range names {
+		before, after := current[name], next.Plugins[name]
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		key := "plugins." + name
+		if !reflect.DeepEqual(before.Settings, after.Settings) || r.TogglePlugin == nil || !r.TogglePlugin(name, after.Enabled) {
+			result.RestartRequired = append(result.RestartRequired, key)
+			continue
+		}
+		switched[name] = after
+		result.Applied = append(result.Applied, key)
+	}
+	return switched
+}
+
+// withPlugins returns a copy of plugins with the entries of changes. The
+// map of the running settings is replaced rather than changed, so a map read
+// under hotMu stays valid after the lock is released.
+func withPlugins(plugins, changes map[string]PluginSettings) map[string]PluginSettings {
+	copied := make(map[string]PluginSettings, len(plugins)+len(changes))
+	maps.Copy(copied, plugins)
+	maps.Copy(copied, changes)
+	return copied
+}
+
+// changedKeys returns the top-level keys, sorted, whose JSON differs between
+// a and b.
+func changedKeys(a, b *Settings) ([]string, error) {
+	before, err := toKeys(a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := toKeys(b)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+func toKeys(s *Settings) (map[string]any, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]any
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Watch reloads the settings whenever the settings file is written until
+// stop is called. The directory is watched as editors replace files on save.
+func (r *Reloader) Watch() (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(r.path)
+	if err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDelay, r.reloadFromWatch)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.Warnf("Error watching %s: %v", path, err)
+			}
+		}
+	}()
+	return func() { _ = watcher.Close() }, nil
+}
+
+func (r *Reloader) reloadFromWatch() {
+	result, err := r.Reload()
+	if err != nil {
+		r.logger.Errorf("Not reloading %s: %v", r.path, err)
+		return
+	}
+	r.Log(result)
+}
+
+// Log reports the outcome of a reload.
+func (r *Reloader) Log(result *ReloadResult) {
+	if len(result.Applied) > 0 {
+		r.logger.Infof("Applied settings: %v", result.Applied)
+	}
+	if len(result.RestartRequired) > 0 {
+		r.logger.Warnf("Changed settings that take effect after a restart: %v", result.RestartRequired)
+	}
+}
@@ -0,0 +1,167 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestReloader(t *testing.T, content string) (*Reloader, *Settings, string) {
+	t.Helper()
+	displayed := Displayed
+	t.Cleanup(func() { Displayed = displayed })
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	live, err := ParseConfig([]byte(content))
+	require.NoError(t, err)
+	live.Root = "/srv/etherpad"
+	return NewReloader(live, path, zap.NewNop().Sugar()), live, path
+}
+
+func TestReloader_Apply(t *testing.T) {
+	r, live, path := newTestReloader(t, `{"port": "9001", "toolbar": {"left": [["bold"]]}}`)
+	var reloaded *Settings
+	r.OnReload = func(s *Settings) { reloaded = s }
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"port": "9002", "toolbar": {"left": [["italic"]]}, "commitRateLimiting": {"points": 5}, "importExportRateLimiting": {"max": 3}}`), 0644))
+	result, err := r.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"commitRateLimiting", "toolbar"}, result.Applied)
+	assert.Equal(t, []string{"importExportRateLimiting", "port"}, result.RestartRequired)
+
+	assert.Equal(t, [][]string{{"italic"}}, live.Toolbar.Left)
+	assert.Equal(t, 5, live.CommitRateLimiting.Points)
+	assert.Equal(t, [][]string{{"italic"}}, Displayed.Toolbar.Left)
+	assert.Equal(t, "9001", live.Port, "settings requiring a restart are left alone")
+	assert.Equal(t, "/srv/etherpad", live.Root)
+	assert.Same(t, live, reloaded)
+
+	reloaded = nil
+	result, err = r.Reload()
+	require.NoError(t, err)
+	assert.Empty(t, result.Applied)
+	assert.Nil(t, reloaded, "the hook only fires when something was applied")
+}
+
+func TestReloader_ApplyRejectsInvalidSettings(t *testing.T) {
+	r, live, path := newTestReloader(t, `{"toolbar": {"left": [["bold"]]}}`)
+
+	invalid := `{"toolbar": {"left": [["italic"]]}, "cookie": {"prefix": "a;b"}}`
+	_, err := r.Write([]byte(invalid))
+	require.Error(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"toolbar": {"left": [["bold"]]}}`, string(content))
+	assert.Equal(t, [][]string{{"bold"}}, live.Toolbar.Left)
+
+	_, err = r.Write([]byte(`{"toolbar": `))
+	assert.Error(t, err)
+}
+
+func TestReloader_Plugins(t *testing.T) {
+	r, live, _ := newTestReloader(t, `{"plugins": {"wasm_counter": {"enabled": true}, "ep_align": {"enabled": true}}}`)
+	toggled := map[string]bool{}
+	r.TogglePlugin = func(name string, enabled bool) bool {
+		if name == "ep_align" {
+			return false
+		}
+		toggled[name] = enabled
+		return true
+	}
+	previous := live.Plugins
+
+	result, err := r.Write([]byte(`{"plugins": {"wasm_counter": {"enabled": false}, "ep_align": {"enabled": false}, "wasm_new": {"enabled": true, "settings": {"a": 1}}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plugins.wasm_counter"}, result.Applied)
+	assert.Equal(t, []string{"plugins.ep_align", "plugins.wasm_new"}, result.RestartRequired)
+	assert.Equal(t, map[string]bool{"wasm_counter": false}, toggled)
+	assert.False(t, live.Plugins["wasm_counter"].Enabled)
+	assert.True(t, live.Plugins["ep_align"].Enabled)
+	assert.True(t, previous["wasm_counter"].Enabled, "the plugins map is replaced, not changed")
+}
+
+func TestReloader_ApplyWhileReading(t *testing.T) {
+	r, live, _ := newTestReloader(t, `{"plugins": {"wasm_counter": {"enabled": true}}, "commitRateLimiting": {"points": 1}}`)
+	r.TogglePlugin = func(string, bool) bool { return true }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			_, err := r.Write([]byte(fmt.Sprintf(`{"plugins": {"wasm_counter": {"enabled": %t}}, "commitRateLimiting": {"points": %d}}`, i%2 == 0, i)))
+			assert.NoError(t, err)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			assert.Equal(t, 49, live.CommitRateLimit().Points)
+			assert.False(t, live.IsPluginEnabled("wasm_counter"))
+			return
+		default:
+			live.IsPluginEnabled("wasm_counter")
+			Displayed.GetAllPlugins()
+			live.CommitRateLimit()
+		}
+	}
+}
+
+func TestReloader_Watch(t *testing.T) {
+	r, live, path := newTestReloader(t, `{"users": {}}`)
+	stop, err := r.Watch()
+	require.NoError(t, err)
+	defer stop()
+
+	password := "secret"
+	require.NoError(t, os.WriteFile(path, []byte(`{"users": {"admin": {"password": "`+password+`"}}}`), 0644))
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		user, ok := live.Users["admin"]
+		return ok && user.Password != nil && *user.Password == password
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestReloader_WriteRejectsUnknownKeys(t *testing.T) {
+	r, live, _ := newTestReloader(t, `{"toolbar": {"left": [["bold"]]}}`)
+
+	_, err := r.Write([]byte(`{"toolbar": {"left": [["italic"]]}, "tolbar": {}}`))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "tolbar", validationErr.Problems[0].Key)
+	assert.Equal(t, [][]string{{"bold"}}, live.Toolbar.Left)
+}
+
+func TestReloader_SetPluginEnabled(t *testing.T) {
+	r, live, path := newTestReloader(t, `{"title": "Pads", "plugins": {"wasm_counter": {"enabled": false, "settings": {"step": 2}}}}`)
+	toggled := map[string]bool{}
+	r.TogglePlugin = func(name string, enabled bool) bool {
+		toggled[name] = enabled
+		return true
+	}
+
+	result, err := r.SetPluginEnabled("wasm_counter", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plugins.wasm_counter"}, result.Applied)
+	result, err = r.SetPluginEnabled("wasm_new", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plugins.wasm_new"}, result.Applied)
+
+	assert.Equal(t, map[string]bool{"wasm_counter": true, "wasm_new": true}, toggled)
+	assert.True(t, live.IsPluginEnabled("wasm_counter"))
+	assert.True(t, Displayed.IsPluginEnabled("wasm_new"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	saved, err := ParseConfig(content)
+	require.NoError(t, err)
+	assert.Equal(t, "Pads", saved.Title)
+	assert.Equal(t, PluginSettings{Enabled: true, Settings: map[string]any{"step": float64(2)}}, saved.Plugins["wasm_counter"])
+	assert.True(t, saved.Plugins["wasm_new"].Enabled)
+}
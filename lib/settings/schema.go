@@ -0,0 +1,329 @@
+package settings
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unusedKeys are settings of Etherpad that Etherpad Go accepts but does not
+// use. They are marked deprecated in the schema.
+var unusedKeys = []string{
+	"socketTransportProtocols",
+	"dbSettings.collection",
+	"dbSettings.url",
+}
+
+// Schema returns the JSON schema of settings.json. It is generated from the
+// Settings struct, with the descriptions and defaults of the Registry.
+func Schema() map[string]any {
+	schema := schemaOf(reflect.TypeFor[Settings]())
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Etherpad Go settings"
+	schema["properties"].(map[string]any)["dbType"].(map[string]any)["enum"] = []any{
+		string(SQLITE), string(MEMORY), string(POSTGRES), string(MYSQL),
+	}
+	for _, c := range Registry {
+		addRegistryKey(schema, strings.Split(c.Key, "."), c)
+	}
+	for _, key := range unusedKeys {
+		addRegistryKey(schema, strings.Split(key, "."), ConfigKey{Key: key, Description: "Not used by Etherpad Go"})
+		property(schema, strings.Split(key, "."))["deprecated"] = true
+	}
+	return schema
+}
+
+func schemaOf(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOf(t.Elem())
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []any{typ, "null"}
+		}
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if name := fieldName(field); name != "" {
+				properties[name] = schemaOf(field.Type)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	default:
+		return map[string]any{}
+	}
+}
+
+// fieldName returns the settings key of field as mapstructure decodes it, or
+// "" for fields that are not read from the settings file.
+func fieldName(field reflect.StructField) string {
+	if !field.IsExported() || field.Tag.Get("json") == "-" {
+		return ""
+	}
+	for _, tag := range []string{"mapstructure", "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name == "-" {
+			return ""
+		} else if name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name[:1]) + field.Name[1:]
+}
+
+// addRegistryKey describes the property at path with c, adding it when the
+// Settings struct does not have it. Keys inside maps are left alone.
+func addRegistryKey(schema map[string]any, path []string, c ConfigKey) {
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return
+	}
+	name, child := lookupProperty(properties, path[0])
+	if child == nil {
+		name = path[0]
+		child = map[string]any{}
+		if len(path) > 1 {
+			child = map[string]any{"type": "object", "properties": map[string]any{}}
+		} else if typ := jsonType(c.Default); typ != "" {
+			child["type"] = typ
+		}
+		properties[name] = child
+	}
+	if len(path) > 1 {
+		addRegistryKey(child, path[1:], c)
+		return
+	}
+	if c.Description != "" {
+		child["description"] = c.Description
+	}
+	if c.Default != nil && jsonType(c.Default) == primaryType(child) {
+		child["default"] = c.Default
+	}
+}
+
+func property(schema map[string]any, path []string) map[string]any {
+	for _, name := range path {
+		properties, _ := schema["properties"].(map[string]any)
+		_, schema = lookupProperty(properties, name)
+	}
+	return schema
+}
+
+// lookupProperty finds name in properties ignoring case, as viper does.
+func lookupProperty(properties map[string]any, name string) (string, map[string]any) {
+	if child, ok := properties[name].(map[string]any); ok {
+		return name, child
+	}
+	for key, child := range properties {
+		if strings.EqualFold(key, name) {
+			return key, child.(map[string]any)
+		}
+	}
+	return "", nil
+}
+
+// jsonType returns the schema type of the Go value v.
+func jsonType(v any) string {
+	if v == nil {
+		return ""
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return ""
+}
+
+func primaryType(schema map[string]any) string {
+	switch typ := schema["type"].(type) {
+	case string:
+		return typ
+	case []any:
+		return typ[0].(string)
+	}
+	return ""
+}
+
+// checkSchema compares the decoded JSON value against schema. Values are
+// accepted as loosely as viper decodes them, e.g. "8080" for a number.
+func checkSchema(value any, schema map[string]any, key string, problems *[]Problem) {
+	if value == nil {
+		return
+	}
+	if schema["deprecated"] == true {
+		*problems = append(*problems, Problem{Key: key, Message: "is not used by Etherpad Go", Warning: true})
+		return
+	}
+	switch primaryType(schema) {
+	case "string":
+		if !isScalar(value) {
+			*problems = append(*problems, Problem{Key: key, Message: "must be a string"})
+		} else if enum, ok := schema["enum"].([]any); ok && !enumContains(enum, value) {
+			*problems = append(*problems, Problem{Key: key, Message: fmt.Sprintf("must be one of %s", enumList(enum))})
+		}
+	case "boolean":
+		ok := isScalar(value)
+		if s, isString := value.(string); isString && s != "" {
+			_, err := strconv.ParseBool(s)
+			ok = err == nil
+		}
+		if !ok {
+			*problems = append(*problems, Problem{Key: key, Message: "must be true or false"})
+		}
+	case "integer", "number":
+		number, ok := toNumber(value)
+		if !ok {
+			*problems = append(*problems, Problem{Key: key, Message: "must be a number"})
+		} else if primaryType(schema) == "integer" && number != float64(int64(number)) {
+			*problems = append(*problems, Problem{Key: key, Message: "must be a whole number"})
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			*problems = append(*problems, Problem{Key: key, Message: "must be a list"})
+			return
+		}
+		itemSchema, _ := schema["items"].(map[string]any)
+		for i, item := range items {
+			checkSchema(item, itemSchema, fmt.Sprintf("%s[%d]", key, i), problems)
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			*problems = append(*problems, Problem{Key: key, Message: "must be an object"})
+			return
+		}
+		checkObject(object, schema, key, problems)
+	}
+}
+
+func checkObject(object map[string]any, schema map[string]any, key string, problems *[]Problem) {
+	properties, _ := schema["properties"].(map[string]any)
+	additional, _ := schema["additionalProperties"].(map[string]any)
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childKey := name
+		if key != "" {
+			childKey = key + "." + name
+		}
+		if _, child := lookupProperty(properties, name); child != nil {
+			checkSchema(object[name], child, childKey, problems)
+		} else if additional != nil {
+			checkSchema(object[name], additional, childKey, problems)
+		} else {
+			message := "is not a known setting"
+			if suggestion := suggest(name, properties); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q?", suggestion)
+			}
+			*problems = append(*problems, Problem{Key: childKey, Message: message})
+		}
+	}
+}
+
+func isScalar(value any) bool {
+	switch value.(type) {
+	case string, bool, float64:
+		return true
+	}
+	return false
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		return 0, true
+	case string:
+		if v == "" {
+			return 0, true
+		}
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+func enumContains(enum []any, value any) bool {
+	for _, v := range enum {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []any) string {
+	values := make([]string, len(enum))
+	for i, v := range enum {
+		values[i] = fmt.Sprint(v)
+	}
+	return strings.Join(values, ", ")
+}
+
+// suggest returns the property closest to the unknown key name, or "" when
+// none is close enough to be a typo.
+func suggest(name string, properties map[string]any) string {
+	best, bestDistance := "", -1
+	for property := range properties {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(property))
+		if bestDistance == -1 || distance < bestDistance || (distance == bestDistance && property < best) {
+			best, bestDistance = property, distance
+		}
+	}
+	if bestDistance == -1 || bestDistance > max(2, len(name)/3) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
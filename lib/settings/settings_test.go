@@ -25,3 +25,18 @@ func TestEnvOverride(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "9999", cfg.Port)
 }
+
+func TestSetPluginEnabled(t *testing.T) {
+	s := &Settings{Plugins: map[string]PluginSettings{
+		"guest": {Enabled: false, Settings: map[string]any{"a": 1}},
+	}}
+	previous := s.Plugins
+
+	s.SetPluginEnabled("guest", true)
+	s.SetPluginEnabled("other", true)
+
+	require.True(t, s.IsPluginEnabled("guest"))
+	require.True(t, s.IsPluginEnabled("other"))
+	require.Equal(t, map[string]any{"a": 1}, s.PluginConfig("guest").Settings)
+	require.False(t, previous["guest"].Enabled, "the plugins map is replaced, not changed")
+}
@@ -0,0 +1,170 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ether/etherpad-go/lib/updater"
+)
+
+// Problem is something wrong with a setting. Warnings do not keep the
+// settings from being used.
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (p Problem) String() string {
+	if p.Key == "" {
+		return p.Message
+	}
+	return p.Key + " " + p.Message
+}
+
+// ValidationError lists the problems that make settings unusable.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.String()
+	}
+	return "invalid settings: " + strings.Join(messages, "; ")
+}
+
+// Errors returns the problems that are not warnings.
+func Errors(problems []Problem) []Problem {
+	return slices.DeleteFunc(slices.Clone(problems), func(p Problem) bool { return p.Warning })
+}
+
+// CheckConfig validates the content of a settings file: unknown keys and
+// values of the wrong type against Schema, then the checks of Validate on
+// the decoded settings.
+func CheckConfig(content []byte) []Problem {
+	var raw map[string]any
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return []Problem{{Message: "is not valid JSON: " + err.Error()}}
+	}
+	var problems []Problem
+	checkObject(raw, Schema(), "", &problems)
+
+	s, err := ParseConfig(content)
+	if err != nil {
+		// The schema problems explain why decoding failed.
+		if len(Errors(problems)) == 0 {
+			problems = append(problems, Problem{Message: err.Error()})
+		}
+		return problems
+	}
+	for _, problem := range checkSettings(s) {
+		// A value of the wrong type is reported once.
+		if !slices.ContainsFunc(problems, func(p Problem) bool { return p.Key == problem.Key && !p.Warning }) {
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+// Validate checks settings before they are used.
+func Validate(s *Settings) error {
+	if problems := Errors(checkSettings(s)); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func checkSettings(s *Settings) []Problem {
+	var problems []Problem
+	add := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Cookie.Prefix != "" && !cookiePrefixPattern.MatchString(s.Cookie.Prefix) {
+		add("cookie.prefix", "%q contains invalid characters, only [a-zA-Z0-9_-] are allowed", s.Cookie.Prefix)
+	}
+	if s.Port == "" {
+		add("port", "must not be empty")
+	}
+	if s.CommitRateLimiting.Duration < 0 || s.CommitRateLimiting.Points < 0 {
+		add("commitRateLimiting", "duration and points must not be negative")
+	}
+	if s.ImportExportRateLimiting.WindowMS < 0 || s.ImportExportRateLimiting.Max < 0 {
+		add("importExportRateLimiting", "windowMs and max must not be negative")
+	}
+
+	// TLS needs both the key and the certificate.
+	if (s.SSL.Key == "") != (s.SSL.Cert == "") {
+		add("ssl", "needs both key and cert")
+	}
+	for key, file := range map[string]string{"ssl.key": s.SSL.Key, "ssl.cert": s.SSL.Cert} {
+		if file != "" {
+			if _, err := os.Stat(file); err != nil {
+				add(key, "cannot be read: %v", err)
+			}
+		}
+	}
+
+	if s.SSO != nil {
+		seen := map[string]bool{}
+		for i, client := range s.SSO.Clients {
+			key := fmt.Sprintf("sso.clients[%d]", i)
+			if client.ClientId == "" {
+				add(key+".client_id", "must not be empty")
+			} else if seen[client.ClientId] {
+				add(key+".client_id", "%q is used by another client", client.ClientId)
+			}
+			seen[client.ClientId] = true
+			// Clients that log users in are redirected back to them.
+			if !slices.Equal(client.GrantTypes, []string{"client_credentials"}) && len(client.RedirectUris) == 0 {
+				add(key+".redirect_uris", "must list where users are sent after logging in")
+			}
+			for j, uri := range client.RedirectUris {
+				if parsed, err := url.Parse(uri); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+					add(fmt.Sprintf("%s.redirect_uris[%d]", key, j), "%q is not an absolute URL", uri)
+				}
+			}
+		}
+	}
+
+	db := s.DBSettings
+	if db == nil {
+		db = &DBSettings{}
+	}
+	switch s.DBType {
+	case MEMORY:
+	case SQLITE:
+		if db.Filename == "" {
+			add("dbSettings.filename", "is required for dbType %s", s.DBType)
+		}
+	case POSTGRES, MYSQL:
+		for key, value := range map[string]string{"host": db.Host, "database": db.Database, "user": db.User} {
+			if value == "" {
+				add("dbSettings."+key, "is required for dbType %s", s.DBType)
+			}
+		}
+		if _, err := strconv.Atoi(db.Port); err != nil {
+			add("dbSettings.port", "must be a port number for dbType %s", s.DBType)
+		}
+	default:
+		add("dbType", "must be one of %s, %s, %s, %s", SQLITE, MEMORY, POSTGRES, MYSQL)
+	}
+
+	window := s.Updates.MaintenanceWindow
+	if window.Start != "" || window.End != "" {
+		tz := strings.ToLower(strings.TrimSpace(window.TZ))
+		if _, ok := updater.ParseWindow(window.Start, window.End, tz); !ok {
+			add("updates.maintenanceWindow", "needs a start and an end in HH:MM that differ, and a tz of local or utc")
+		}
+	}
+
+	slices.SortStableFunc(problems, func(a, b Problem) int { return strings.Compare(a.Key, b.Key) })
+	return problems
+}
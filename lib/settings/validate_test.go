@@ -0,0 +1,132 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func problemKeys(problems []Problem) []string {
+	keys := make([]string, len(problems))
+	for i, problem := range problems {
+		keys[i] = problem.Key
+	}
+	return keys
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+
+	port := property(schema, []string{"port"})
+	assert.Equal(t, "string", port["type"])
+	assert.Equal(t, "9001", port["default"])
+	assert.Equal(t, "HTTP server port", port["description"])
+
+	// Keys come from the mapstructure tags, which viper decodes.
+	assert.NotNil(t, property(schema, []string{"importExportRateLimiting", "windowMs"}))
+	assert.NotNil(t, property(schema, []string{"padOptions", "noColors"}))
+	assert.Equal(t, []any{"boolean", "null"}, property(schema, []string{"padOptions", "userName"})["type"])
+	assert.Nil(t, property(schema, []string{"root"}), "untracked fields are not settings")
+
+	// Registry keys missing from the struct are added.
+	assert.Equal(t, "Abiword path", property(schema, []string{"abiword"})["description"])
+	assert.Equal(t, true, property(schema, []string{"socketTransportProtocols"})["deprecated"])
+
+	_, err := json.Marshal(schema)
+	require.NoError(t, err)
+}
+
+func TestCheckConfig_Template(t *testing.T) {
+	content, err := os.ReadFile("../../settings.template.json")
+	require.NoError(t, err)
+	problems := CheckConfig(content)
+	assert.Empty(t, Errors(problems))
+	assert.Equal(t, []Problem{{Key: "socketTransportProtocols", Message: "is not used by Etherpad Go", Warning: true}}, problems)
+}
+
+func TestCheckConfig_AdminRoundTrip(t *testing.T) {
+	// The admin UI edits the settings as the server marshals them.
+	s, err := ParseConfig([]byte(`{"dbType": "memory"}`))
+	require.NoError(t, err)
+	content, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.Empty(t, CheckConfig(content))
+}
+
+func TestCheckConfig_UnknownKeys(t *testing.T) {
+	problems := CheckConfig([]byte(`{"dbType": "memory", "dbTyp": "sqlite", "padOptions": {"showChatt": true}, "colour": "red", "DBTYPE": "memory"}`))
+	assert.Equal(t, []Problem{
+		{Key: "colour", Message: "is not a known setting"},
+		{Key: "dbTyp", Message: `is not a known setting, did you mean "dbType"?`},
+		{Key: "padOptions.showChatt", Message: `is not a known setting, did you mean "showChat"?`},
+	}, problems)
+
+	// Maps accept any key.
+	assert.Empty(t, CheckConfig([]byte(`{"dbType": "memory", "users": {"anyone": {"password": "x"}}, "plugins": {"ep_x": {"enabled": true, "settings": {"a": [1]}}}}`)))
+}
+
+func TestCheckConfig_Types(t *testing.T) {
+	// Values are accepted like viper decodes them.
+	assert.Empty(t, CheckConfig([]byte(`{"dbType": "memory", "port": 9001, "maxAge": "600", "minify": "false", "favicon": null}`)))
+
+	problems := CheckConfig([]byte(`{"dbType": "mongo", "port": {"a": 1}, "maxAge": "soon", "minify": "maybe", "cleanup": true, "availableExports": "txt", "importMaxFileSize": 1.5}`))
+	assert.Equal(t, []string{"availableExports", "cleanup", "dbType", "importMaxFileSize", "maxAge", "minify", "port"}, problemKeys(problems))
+
+	problems = CheckConfig([]byte(`{"dbType": `))
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "is not valid JSON")
+}
+
+func TestValidate_CrossField(t *testing.T) {
+	cert := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(cert, []byte("cert"), 0600))
+
+	tests := []struct {
+		name     string
+		settings string
+		problems []string
+	}{
+		{"valid", `{"dbType": "sqlite", "dbSettings": {"filename": "var/etherpad.db"}}`, nil},
+		{"tls without key", `{"dbType": "memory", "ssl": {"cert": "` + cert + `"}}`, []string{"ssl"}},
+		{"tls files missing", `{"dbType": "memory", "ssl": {"key": "/missing/key.pem", "cert": "` + cert + `"}}`, []string{"ssl.key"}},
+		{"sqlite without file", `{"dbType": "sqlite", "dbSettings": {"filename": ""}}`, []string{"dbSettings.filename"}},
+		{"postgres", `{"dbType": "postgres", "dbSettings": {"host": "db", "port": "5432", "database": "etherpad", "user": "etherpad"}}`, nil},
+		{"postgres incomplete", `{"dbType": "postgres", "dbSettings": {"host": "db", "port": "x"}}`, []string{"dbSettings.database", "dbSettings.port", "dbSettings.user"}},
+		{"sso client without redirect", `{"dbType": "memory", "sso": {"clients": [{"client_id": "app", "grant_types": ["authorization_code"]}]}}`, []string{"sso.clients[0].redirect_uris"}},
+		{"sso service client", `{"dbType": "memory", "sso": {"clients": [{"client_id": "svc", "grant_types": ["client_credentials"]}]}}`, nil},
+		{"sso relative redirect", `{"dbType": "memory", "sso": {"clients": [{"client_id": "app", "redirect_uris": ["/admin"]}, {"client_id": "app", "redirect_uris": ["https://pad.example.com/"]}]}}`, []string{"sso.clients[0].redirect_uris[0]", "sso.clients[1].client_id"}},
+		{"maintenance window", `{"dbType": "memory", "updates": {"maintenanceWindow": {"start": "22:00", "end": "04:00", "tz": "UTC"}}}`, nil},
+		{"maintenance window format", `{"dbType": "memory", "updates": {"maintenanceWindow": {"start": "10pm", "end": "04:00"}}}`, []string{"updates.maintenanceWindow"}},
+		{"maintenance window timezone", `{"dbType": "memory", "updates": {"maintenanceWindow": {"start": "22:00", "end": "04:00", "tz": "Europe/Berlin"}}}`, []string{"updates.maintenanceWindow"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseConfig([]byte(tt.settings))
+			require.NoError(t, err)
+			err = Validate(s)
+			if tt.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.problems, problemKeys(validationErr.Problems))
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"dbType": "memory"}`), 0644))
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"dbType": "memory", "prot": "9001"}`), 0644))
+
+	assert.True(t, configValidate([]string{valid}))
+	assert.False(t, configValidate([]string{invalid}))
+	assert.False(t, configValidate([]string{filepath.Join(dir, "missing.json")}))
+}
@@ -1,6 +1,7 @@
 package settings
 
 import (
+	"bytes"
 	"errors"
 	"strings"
 
@@ -10,10 +11,7 @@ import (
 func ReadConfig() (*Settings, error) {
 	viper.Reset()
 
-	viper.SetEnvPrefix("etherpad")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
-
+	configure(viper.GetViper())
 	ApplyRegistryDefaults()
 
 	viper.SetConfigName("settings")
@@ -35,3 +33,30 @@ func ReadConfig() (*Settings, error) {
 
 	return &s, nil
 }
+
+// ParseConfig decodes the content of a settings file like ReadConfig, with
+// the defaults and environment overrides applied, without touching the global
+// viper instance.
+func ParseConfig(content []byte) (*Settings, error) {
+	v := viper.New()
+	configure(v)
+	for _, c := range Registry {
+		v.SetDefault(c.Key, c.Default)
+	}
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+
+	var s Settings
+	if err := v.Unmarshal(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func configure(v *viper.Viper) {
+	v.SetEnvPrefix("etherpad")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+}
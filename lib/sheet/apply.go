@@ -28,13 +28,21 @@ func (w *Workbook) Apply(op Op) error {
 		for i, s := range w.Sheets {
 			if s.Id == op.Sheet {
 				w.Sheets = slices.Delete(w.Sheets, i, i+1)
+				w.dropRules(s.Id)
+				w.rewriteFormulas(func(_ *Sheet, raw string) string {
+					return dropSheetRefs(raw, s.Name)
+				})
 				return nil
 			}
 		}
 		return nil
 	case OpRenameSheet:
-		if s := w.SheetByID(op.Sheet); s != nil {
+		if s := w.SheetByID(op.Sheet); s != nil && s.Name != op.Name {
+			from := s.Name
 			s.Name = op.Name
+			w.rewriteFormulas(func(_ *Sheet, raw string) string {
+				return renameRefs(raw, from, op.Name)
+			})
 		}
 		return nil
 	case OpMoveSheet:
@@ -88,6 +96,18 @@ func (w *Workbook) Apply(op Op) error {
 				delete(s.Cells, ref)
 			}
 		}
+	case OpSortRange:
+		s.sortRange(op)
+	case OpMoveRange:
+		w.moveRange(s, op)
+	case OpCopyRange:
+		s.copyRange(op)
+	case OpFillSeries:
+		s.fillSeries(op)
+	case OpSetValidation, OpSetCondFormat, OpSetProtection:
+		return w.setRule(op)
+	case OpDeleteRule:
+		w.deleteRule(op.Rule.Id)
 	case OpSetDimension:
 		if op.Axis == "col" {
 			s.ColWidths[op.Index] = op.Size
@@ -116,47 +136,29 @@ func (w *Workbook) Apply(op Op) error {
 			}
 		}
 	case OpInsertRows:
-		s.remap(func(r CellRef) (CellRef, bool) {
-			if r.Row >= op.Index {
-				return CellRef{r.Row + op.Count, r.Col}, true
-			}
-			return r, true
-		})
+		s.remap(refRemap(op))
 		s.RowHeights = shiftDims(s.RowHeights, op.Index, op.Count)
 		s.Merges = shiftMerges(s.Merges, "row", op.Index, op.Count)
+		w.shiftFormulas(s, "row", op.Index, op.Count)
+		w.shiftRules(s.Id, "row", op.Index, op.Count)
 	case OpDeleteRows:
-		s.remap(func(r CellRef) (CellRef, bool) {
-			if r.Row >= op.Index && r.Row < op.Index+op.Count {
-				return r, false // drop
-			}
-			if r.Row >= op.Index+op.Count {
-				return CellRef{r.Row - op.Count, r.Col}, true
-			}
-			return r, true
-		})
+		s.remap(refRemap(op))
 		s.RowHeights = shiftDims(s.RowHeights, op.Index, -op.Count)
 		s.Merges = shiftMerges(s.Merges, "row", op.Index, -op.Count)
+		w.shiftFormulas(s, "row", op.Index, -op.Count)
+		w.shiftRules(s.Id, "row", op.Index, -op.Count)
 	case OpInsertCols:
-		s.remap(func(r CellRef) (CellRef, bool) {
-			if r.Col >= op.Index {
-				return CellRef{r.Row, r.Col + op.Count}, true
-			}
-			return r, true
-		})
+		s.remap(refRemap(op))
 		s.ColWidths = shiftDims(s.ColWidths, op.Index, op.Count)
 		s.Merges = shiftMerges(s.Merges, "col", op.Index, op.Count)
+		w.shiftFormulas(s, "col", op.Index, op.Count)
+		w.shiftRules(s.Id, "col", op.Index, op.Count)
 	case OpDeleteCols:
-		s.remap(func(r CellRef) (CellRef, bool) {
-			if r.Col >= op.Index && r.Col < op.Index+op.Count {
-				return r, false
-			}
-			if r.Col >= op.Index+op.Count {
-				return CellRef{r.Row, r.Col - op.Count}, true
-			}
-			return r, true
-		})
+		s.remap(refRemap(op))
 		s.ColWidths = shiftDims(s.ColWidths, op.Index, -op.Count)
 		s.Merges = shiftMerges(s.Merges, "col", op.Index, -op.Count)
+		w.shiftFormulas(s, "col", op.Index, -op.Count)
+		w.shiftRules(s.Id, "col", op.Index, -op.Count)
 	default:
 		return fmt.Errorf("apply: unhandled op type %q", op.Type)
 	}
@@ -3,8 +3,8 @@ package sheet
 // CellRef is a zero-based (row, col) address within a single sheet.
 // It is a comparable struct so it can be used directly as a map key.
 type CellRef struct {
-	Row int
-	Col int
+	Row int `json:"row"`
+	Col int `json:"col"`
 }
 
 // CellKind classifies a cell's raw content.
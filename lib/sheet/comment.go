@@ -0,0 +1,109 @@
+package sheet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits for comment threads.
+const (
+	MaxCommentLength  = 10000 // runes
+	MaxThreadComments = 500
+)
+
+var (
+	ErrInvalidComment = errors.New("invalid sheet comment")
+	// ErrAnchorDeleted is returned for a comment composed on a cell that a
+	// concurrent row/col delete removed.
+	ErrAnchorDeleted = errors.New("sheet comment cell was deleted")
+)
+
+// Comment is one message of a thread. Author is the display name when it was
+// written (the note author for an imported xlsx note, which has no AuthorId).
+type Comment struct {
+	Id        string `json:"id"`
+	AuthorId  string `json:"authorId,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// CommentThread is a discussion anchored to one cell. Threads are not part of
+// the Workbook and its op-log; they are stored beside it and their anchors
+// follow the ops applied to the workbook (see MoveAnchor).
+type CommentThread struct {
+	Id       string    `json:"id"`
+	Sheet    string    `json:"sheet"`
+	Cell     CellRef   `json:"cell"`
+	Resolved bool      `json:"resolved,omitempty"`
+	Comments []Comment `json:"comments"`
+}
+
+// ValidateComment checks the text of a new comment.
+func ValidateComment(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("%w: empty text", ErrInvalidComment)
+	}
+	if utf8.RuneCountInString(text) > MaxCommentLength {
+		return fmt.Errorf("%w: text longer than %d characters", ErrInvalidComment, MaxCommentLength)
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the thread.
+func (t CommentThread) Clone() CommentThread {
+	t.Comments = append([]Comment(nil), t.Comments...)
+	return t
+}
+
+// MoveAnchor returns where a comment anchored at ref on sheetId lands once op
+// is applied: structural ops remap it like the cells, a moveRange carries it
+// along with its cell. false means the cell is gone: in a deleted row/col, or
+// overwritten as a moveRange destination. Sheet deletes are left to the
+// caller, which knows whether the sheet is really gone (the last sheet never
+// is).
+func MoveAnchor(sheetId string, ref CellRef, op Op) (CellRef, bool) {
+	if op.Sheet != sheetId {
+		return ref, true
+	}
+	if op.isStructural() {
+		return refRemap(op)(ref)
+	}
+	if op.Type != OpMoveRange {
+		return ref, true
+	}
+	dRow, dCol := op.DestRow-op.Row, op.DestCol-op.Col
+	if dRow == 0 && dCol == 0 {
+		return ref, true
+	}
+	if inRange(ref, op.Row, op.Col, op.EndRow, op.EndCol) {
+		return CellRef{ref.Row + dRow, ref.Col + dCol}, true
+	}
+	if inRange(ref, op.DestRow, op.DestCol, op.DestRow+op.EndRow-op.Row, op.DestCol+op.EndCol-op.Col) {
+		return ref, false
+	}
+	return ref, true
+}
+
+// RebaseAnchor moves a cell composed against baseRev past every op applied
+// since then, the way Rebase moves an op.
+func (d *Document) RebaseAnchor(sheetId string, ref CellRef, baseRev int) (CellRef, error) {
+	if baseRev < 0 || baseRev > d.head {
+		return CellRef{}, fmt.Errorf("comment: baseRev %d out of range (head %d)", baseRev, d.head)
+	}
+	if ref.Row < 0 || ref.Col < 0 {
+		return CellRef{}, fmt.Errorf("%w: negative cell", ErrInvalidComment)
+	}
+	for i := baseRev; i < d.head; i++ {
+		var ok bool
+		if ref, ok = MoveAnchor(sheetId, ref, d.log[i]); !ok {
+			return CellRef{}, ErrAnchorDeleted
+		}
+	}
+	if d.wb.SheetByID(sheetId) == nil {
+		return CellRef{}, fmt.Errorf("%w: unknown sheet %q", ErrInvalidComment, sheetId)
+	}
+	return ref, nil
+}
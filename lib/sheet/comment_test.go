@@ -0,0 +1,72 @@
+package sheet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMoveAnchor(t *testing.T) {
+	at := CellRef{Row: 5, Col: 3}
+	cases := []struct {
+		name string
+		op   Op
+		want CellRef
+		ok   bool
+	}{
+		{"insert rows above", Op{Type: OpInsertRows, Sheet: "s1", Index: 2, Count: 3}, CellRef{8, 3}, true},
+		{"insert rows below", Op{Type: OpInsertRows, Sheet: "s1", Index: 6, Count: 3}, at, true},
+		{"delete rows above", Op{Type: OpDeleteRows, Sheet: "s1", Index: 0, Count: 2}, CellRef{3, 3}, true},
+		{"delete own row", Op{Type: OpDeleteRows, Sheet: "s1", Index: 4, Count: 2}, at, false},
+		{"insert cols", Op{Type: OpInsertCols, Sheet: "s1", Index: 3, Count: 1}, CellRef{5, 4}, true},
+		{"delete own col", Op{Type: OpDeleteCols, Sheet: "s1", Index: 3, Count: 1}, at, false},
+		{"other sheet", Op{Type: OpDeleteRows, Sheet: "s2", Index: 5, Count: 1}, at, true},
+		{"move source", Op{Type: OpMoveRange, Sheet: "s1", Row: 5, Col: 3, EndRow: 6, EndCol: 3, DestRow: 0, DestCol: 0}, CellRef{0, 0}, true},
+		{"move destination", Op{Type: OpMoveRange, Sheet: "s1", Row: 0, Col: 0, EndRow: 0, EndCol: 0, DestRow: 5, DestCol: 3}, at, false},
+		{"set cell", Op{Type: OpSetCell, Sheet: "s1", Row: 5, Col: 3}, at, true},
+	}
+	for _, c := range cases {
+		got, ok := MoveAnchor("s1", at, c.op)
+		if ok != c.ok || (ok && got != c.want) {
+			t.Fatalf("%s: got %v %v, want %v %v", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestRebaseAnchor(t *testing.T) {
+	wb := NewWorkbook()
+	wb.AddSheet("s1", "Sheet1")
+	d := NewDocument(wb)
+	for _, op := range []Op{
+		{Type: OpInsertRows, Sheet: "s1", Index: 0, Count: 2},
+		{Type: OpDeleteCols, Sheet: "s1", Index: 1, Count: 1, BaseRev: 1},
+	} {
+		if _, err := d.Submit(op); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	got, err := d.RebaseAnchor("s1", CellRef{Row: 1, Col: 4}, 0)
+	if err != nil || got != (CellRef{Row: 3, Col: 3}) {
+		t.Fatalf("RebaseAnchor: got %v %v", got, err)
+	}
+	if _, err := d.RebaseAnchor("s1", CellRef{Row: 1, Col: 1}, 1); !errors.Is(err, ErrAnchorDeleted) {
+		t.Fatalf("expected ErrAnchorDeleted, got %v", err)
+	}
+	if _, err := d.RebaseAnchor("s1", CellRef{}, 3); err == nil {
+		t.Fatal("expected an error for a baseRev past the head")
+	}
+	if _, err := d.RebaseAnchor("nope", CellRef{}, 2); !errors.Is(err, ErrInvalidComment) {
+		t.Fatalf("expected ErrInvalidComment for an unknown sheet, got %v", err)
+	}
+}
+
+func TestValidateComment(t *testing.T) {
+	if err := ValidateComment("looks good"); err != nil {
+		t.Fatalf("ValidateComment: %v", err)
+	}
+	for _, text := range []string{"", "  \n", strings.Repeat("x", MaxCommentLength+1)} {
+		if err := ValidateComment(text); !errors.Is(err, ErrInvalidComment) {
+			t.Fatalf("ValidateComment(%d chars): expected ErrInvalidComment, got %v", len(text), err)
+		}
+	}
+}
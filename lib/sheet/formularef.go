@@ -0,0 +1,528 @@
+package sheet
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// refError replaces a reference whose cells or sheet no longer exist.
+const refError = "#REF!"
+
+// referenceRe matches an A1 reference with an optional sheet prefix: a cell
+// (A1), a cell range (A1:B2), a column range (A:C) or a row range (1:3), each
+// part optionally absolute ($A$1). Group 1 is a quoted sheet name, group 2 an
+// unquoted one, group 3 the reference itself. ui/src/js/sheet/formulaRefs.ts
+// uses the same pattern.
+var referenceRe = regexp.MustCompile(`(?:'((?:[^']|'')+)'!|([\p{L}_][\p{L}\p{N}_.]*)!)?(\$?[A-Za-z]{1,3}\$?[0-9]+(?::\$?[A-Za-z]{1,3}\$?[0-9]+)?|\$?[A-Za-z]{1,3}:\$?[A-Za-z]{1,3}|\$?[0-9]+:\$?[0-9]+)`)
+
+var (
+	refPartRe   = regexp.MustCompile(`^(\$?)([A-Za-z]*)(\$?)([0-9]*)$`)
+	plainNameRe = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_.]*$`)
+	cellLikeRe  = regexp.MustCompile(`^[A-Za-z]{1,3}[0-9]+$`)
+)
+
+// refPart is one end of a reference. Row and Col are zero-based; a column
+// range has no rows and a row range no columns (-1).
+type refPart struct {
+	Row    int
+	Col    int
+	AbsRow bool
+	AbsCol bool
+}
+
+// formulaRef is a parsed reference. Single cells have Start == End.
+type formulaRef struct {
+	Sheet string // unquoted sheet name, "" when unqualified
+	Start refPart
+	End   refPart
+	Range bool
+}
+
+// mayPrecedeRef and mayFollowRef report whether r may directly precede or
+// follow a reference, i.e. the match is not part of a name, number, error
+// value, function call or longer reference.
+func mayPrecedeRef(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_.$'#!", r)
+}
+
+func mayFollowRef(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_.$'!(:", r)
+}
+
+// rewriteRefs replaces every reference in the formula raw by the result of
+// fn, or by #REF! when fn reports it gone. String literals are left alone, as
+// are references fn returns unchanged. Values that are no formula are
+// returned as they are.
+func rewriteRefs(raw string, fn func(ref formulaRef) (formulaRef, bool)) string {
+	if !strings.HasPrefix(raw, "=") {
+		return raw
+	}
+	var out strings.Builder
+	out.WriteByte('=')
+	// Outside string literals every other segment; "" escapes inside a
+	// literal just produce an empty segment in between.
+	for i, segment := range strings.Split(raw[1:], `"`) {
+		if i > 0 {
+			out.WriteByte('"')
+		}
+		if i%2 == 1 {
+			out.WriteString(segment)
+			continue
+		}
+		out.WriteString(rewriteSegment(segment, fn))
+	}
+	return out.String()
+}
+
+func rewriteSegment(segment string, fn func(ref formulaRef) (formulaRef, bool)) string {
+	return mapSegment(segment, func(m []int) (string, bool) {
+		ref, ok := parseRef(segment, m)
+		if !ok {
+			return "", false
+		}
+		next, keep := fn(ref)
+		switch {
+		case !keep:
+			return refError, true
+		case next == ref:
+			return "", false
+		}
+		return next.String(), true
+	})
+}
+
+// mapSegment replaces the references in segment, a part of a formula outside
+// string literals, by what fn returns for their referenceRe match, unless fn
+// reports false.
+func mapSegment(segment string, fn func(m []int) (string, bool)) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range referenceRe.FindAllStringSubmatchIndex(segment, -1) {
+		start, end := m[0], m[1]
+		if start > 0 {
+			if !mayPrecedeRef(lastRune(segment[:start])) {
+				continue
+			}
+		}
+		if end < len(segment) {
+			if !mayFollowRef(firstRune(segment[end:])) {
+				continue
+			}
+		}
+		replacement, ok := fn(m)
+		if !ok {
+			continue
+		}
+		out.WriteString(segment[last:start])
+		out.WriteString(replacement)
+		last = end
+	}
+	out.WriteString(segment[last:])
+	return out.String()
+}
+
+// MapFormulaRefs replaces every reference in the formula raw by fn(sheet,
+// ref), where sheet is the unquoted sheet name ("" when unqualified) and ref
+// the reference as written (A1, $A$1:B2, A:C). File format converters use it
+// to translate reference syntax. String literals are left alone.
+func MapFormulaRefs(raw string, fn func(sheet, ref string) string) string {
+	if !strings.HasPrefix(raw, "=") {
+		return raw
+	}
+	segments := strings.Split(raw[1:], `"`)
+	for i := 0; i < len(segments); i += 2 {
+		segment := segments[i]
+		segments[i] = mapSegment(segment, func(m []int) (string, bool) {
+			sheet := ""
+			switch {
+			case m[2] >= 0:
+				sheet = strings.ReplaceAll(segment[m[2]:m[3]], "''", "'")
+			case m[4] >= 0:
+				sheet = segment[m[4]:m[5]]
+			}
+			return fn(sheet, segment[m[6]:m[7]]), true
+		})
+	}
+	return "=" + strings.Join(segments, `"`)
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+func lastRune(s string) rune {
+	runes := []rune(s)
+	return runes[len(runes)-1]
+}
+
+// parseRef builds the formulaRef of a referenceRe match in s.
+func parseRef(s string, m []int) (formulaRef, bool) {
+	var ref formulaRef
+	switch {
+	case m[2] >= 0:
+		ref.Sheet = strings.ReplaceAll(s[m[2]:m[3]], "''", "'")
+	case m[4] >= 0:
+		ref.Sheet = s[m[4]:m[5]]
+	}
+	parts := strings.Split(s[m[6]:m[7]], ":")
+	start, ok := parseRefPart(parts[0])
+	if !ok {
+		return formulaRef{}, false
+	}
+	ref.Start, ref.End = start, start
+	if len(parts) == 2 {
+		end, ok := parseRefPart(parts[1])
+		if !ok {
+			return formulaRef{}, false
+		}
+		ref.End, ref.Range = end, true
+	}
+	return ref, true
+}
+
+func parseRefPart(s string) (refPart, bool) {
+	m := refPartRe.FindStringSubmatch(s)
+	if m == nil {
+		return refPart{}, false
+	}
+	part := refPart{Row: -1, Col: -1, AbsCol: m[1] == "$", AbsRow: m[3] == "$"}
+	if m[2] != "" {
+		part.Col = colIndex(m[2])
+	} else if m[1] == "$" {
+		// "$1" is an absolute row: the only marker belongs to the row.
+		part.AbsCol, part.AbsRow = false, true
+	}
+	if m[4] != "" {
+		row, err := strconv.Atoi(m[4])
+		if err != nil || row < 1 {
+			return refPart{}, false
+		}
+		part.Row = row - 1
+	}
+	return part, true
+}
+
+// colIndex converts column letters to a zero-based index (A = 0, AA = 26).
+func colIndex(letters string) int {
+	col := 0
+	for _, c := range strings.ToUpper(letters) {
+		col = col*26 + int(c-'A'+1)
+	}
+	return col - 1
+}
+
+// ParseRangeName parses the A1 name of a cell ("B2") or of a rectangle
+// ("A1:D20", "$A$1:B2", corners in any order) into its zero-based bounds, the
+// inverse of rangeName. Whole rows or columns, and cells beyond the xlsx grid,
+// are not accepted.
+func ParseRangeName(name string) (r0, c0, r1, c1 int, ok bool) {
+	parts := strings.Split(name, ":")
+	if len(parts) > 2 {
+		return 0, 0, 0, 0, false
+	}
+	corners := make([]refPart, len(parts))
+	for i, s := range parts {
+		p, ok := parseRefPart(s)
+		if !ok || p.Row < 0 || p.Col < 0 || p.Row > maxRefRow || p.Col > maxRefCol || !cellLikeRe.MatchString(strings.ReplaceAll(s, "$", "")) {
+			return 0, 0, 0, 0, false
+		}
+		corners[i] = p
+	}
+	first, last := corners[0], corners[len(corners)-1]
+	return min(first.Row, last.Row), min(first.Col, last.Col), max(first.Row, last.Row), max(first.Col, last.Col), true
+}
+
+// RangeExceeds reports whether the rectangle r0,c0 to r1,c1 holds more than
+// limit cells. Rows and columns are checked on their own first so the product
+// cannot overflow.
+func RangeExceeds(r0, c0, r1, c1, limit int) bool {
+	rows, cols := r1-r0+1, c1-c0+1
+	if rows > limit || cols > limit {
+		return true
+	}
+	return rows*cols > limit
+}
+
+// colName converts a zero-based column index to its letters.
+func colName(col int) string {
+	var name []byte
+	for n := col + 1; n > 0; n = (n - 1) / 26 {
+		name = append([]byte{byte('A' + (n-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+func (p refPart) String() string {
+	var b strings.Builder
+	if p.Col >= 0 {
+		if p.AbsCol {
+			b.WriteByte('$')
+		}
+		b.WriteString(colName(p.Col))
+	}
+	if p.Row >= 0 {
+		if p.AbsRow {
+			b.WriteByte('$')
+		}
+		b.WriteString(strconv.Itoa(p.Row + 1))
+	}
+	return b.String()
+}
+
+func (r formulaRef) String() string {
+	var b strings.Builder
+	if r.Sheet != "" {
+		b.WriteString(QuoteSheetName(r.Sheet))
+		b.WriteByte('!')
+	}
+	b.WriteString(r.Start.String())
+	if r.Range {
+		b.WriteByte(':')
+		b.WriteString(r.End.String())
+	}
+	return b.String()
+}
+
+// QuoteSheetName quotes a sheet name for use in a reference when it is not a
+// plain identifier or could be mistaken for a cell.
+func QuoteSheetName(name string) string {
+	if plainNameRe.MatchString(name) && !cellLikeRe.MatchString(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// sameSheetName compares sheet names like a spreadsheet does: ignoring the
+// case of ASCII letters.
+func sameSheetName(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// shiftRefs rewrites the references the target func selects for a row (axis
+// "row") or col insert (delta > 0) or delete of -delta indices at index.
+// target gets the sheet name of a reference, "" when unqualified. Inserts at
+// or before a range end grow the range; deletes shrink it, and references
+// whose cells are all deleted become #REF!. Absolute markers are kept.
+func shiftRefs(raw string, target func(sheet string) bool, axis string, index, delta int) string {
+	return rewriteRefs(raw, func(ref formulaRef) (formulaRef, bool) {
+		if !target(ref.Sheet) {
+			return ref, true
+		}
+		start, end := &ref.Start.Row, &ref.End.Row
+		if axis == "col" {
+			start, end = &ref.Start.Col, &ref.End.Col
+		}
+		if *start < 0 {
+			return ref, true // a column range on a row op, or the other way round
+		}
+		lo, hi := min(*start, *end), max(*start, *end)
+		if delta >= 0 {
+			lo, hi = shiftCoord(lo, index, delta), shiftCoord(hi, index, delta)
+		} else {
+			band := -delta
+			if lo >= index && hi < index+band {
+				return ref, false
+			}
+			lo = shiftCoord(lo, index, delta)
+			switch {
+			case hi >= index+band:
+				hi -= band
+			case hi >= index:
+				hi = index - 1
+			}
+		}
+		if *start <= *end {
+			*start, *end = lo, hi
+		} else {
+			*start, *end = hi, lo
+		}
+		return ref, true
+	})
+}
+
+// renameRefs points the references to the sheet named from at the name to.
+func renameRefs(raw string, from, to string) string {
+	return rewriteRefs(raw, func(ref formulaRef) (formulaRef, bool) {
+		if ref.Sheet != "" && sameSheetName(ref.Sheet, from) {
+			ref.Sheet = to
+		}
+		return ref, true
+	})
+}
+
+// dropSheetRefs turns the references to the sheet named name into #REF!.
+func dropSheetRefs(raw string, name string) string {
+	return rewriteRefs(raw, func(ref formulaRef) (formulaRef, bool) {
+		return ref, ref.Sheet == "" || !sameSheetName(ref.Sheet, name)
+	})
+}
+
+// Largest row and column a reference may address (the xlsx grid).
+const (
+	maxRefRow = 1<<20 - 1
+	maxRefCol = 1<<14 - 1
+)
+
+// offsetRefs moves the relative parts of every reference by dRow rows and
+// dCol columns, like copying the formula to another cell does. Absolute
+// parts stay; references pushed off the grid become #REF!.
+func offsetRefs(raw string, dRow, dCol int) string {
+	if dRow == 0 && dCol == 0 {
+		return raw
+	}
+	return rewriteRefs(raw, func(ref formulaRef) (formulaRef, bool) {
+		for _, p := range []*refPart{&ref.Start, &ref.End} {
+			if p.Row >= 0 && !p.AbsRow {
+				if p.Row += dRow; p.Row < 0 || p.Row > maxRefRow {
+					return ref, false
+				}
+			}
+			if p.Col >= 0 && !p.AbsCol {
+				if p.Col += dCol; p.Col < 0 || p.Col > maxRefCol {
+					return ref, false
+				}
+			}
+		}
+		return ref, true
+	})
+}
+
+// moveRefs follows cells moved from the rectangle [r0..r1] x [c0..c1] by dRow
+// rows and dCol columns: references the target func selects that lie wholly
+// inside the rectangle move along, absolute or not. Column and row ranges
+// and references reaching outside the rectangle stay.
+func moveRefs(raw string, target func(sheet string) bool, r0, c0, r1, c1, dRow, dCol int) string {
+	inside := func(p refPart) bool {
+		return p.Row >= r0 && p.Row <= r1 && p.Col >= c0 && p.Col <= c1
+	}
+	return rewriteRefs(raw, func(ref formulaRef) (formulaRef, bool) {
+		if !target(ref.Sheet) || !inside(ref.Start) || !inside(ref.End) {
+			return ref, true
+		}
+		ref.Start.Row += dRow
+		ref.Start.Col += dCol
+		ref.End.Row += dRow
+		ref.End.Col += dCol
+		return ref, true
+	})
+}
+
+// rewriteFormulas replaces the raw of every formula cell by fn(sheet, raw).
+// Rewritten cells drop their cached value like a setCell of the raw would.
+func (w *Workbook) rewriteFormulas(fn func(s *Sheet, raw string) string) {
+	for _, s := range w.Sheets {
+		for ref, c := range s.Cells {
+			if c.Kind() != KindFormula {
+				continue
+			}
+			if raw := fn(s, c.Raw); raw != c.Raw {
+				c.Raw, c.Value, c.ValueType = raw, "", ""
+				s.Cells[ref] = c
+			}
+		}
+	}
+}
+
+// shiftFormulas rewrites the references of every formula in the workbook to
+// the sheet s for a structural op on it.
+func (w *Workbook) shiftFormulas(s *Sheet, axis string, index, delta int) {
+	w.rewriteFormulas(func(in *Sheet, raw string) string {
+		return shiftRefs(raw, func(sheet string) bool {
+			if sheet == "" {
+				return in == s
+			}
+			return sameSheetName(sheet, s.Name)
+		}, axis, index, delta)
+	})
+}
+
+// sheetNameBefore returns the name the sheet of op has before it is applied,
+// for the ops that rewrite sheet-qualified references. Submit records it in
+// the logged op (Op.SheetName) so Transform can rewrite formulas in ops
+// rebased past it without the workbook. A deleteSheet of the last sheet is a
+// no-op and gets no name.
+func (w *Workbook) sheetNameBefore(op Op) string {
+	switch op.Type {
+	case OpInsertRows, OpDeleteRows, OpInsertCols, OpDeleteCols, OpRenameSheet, OpMoveRange:
+	case OpDeleteSheet:
+		if len(w.Sheets) <= 1 {
+			return ""
+		}
+	default:
+		return ""
+	}
+	if s := w.SheetByID(op.Sheet); s != nil {
+		return s.Name
+	}
+	return ""
+}
+
+// transformFormula rewrites the formula of a setCell in past applied, like
+// Apply rewrites the formulas already in the workbook for structural ops,
+// moveRange, sheet renames and deletes. Sheet-qualified
+// references need applied.SheetName; ops logged without it only move the
+// unqualified references of their own sheet.
+func transformFormula(in, applied Op) Op {
+	if in.Type != OpSetCell || in.Raw == nil || !strings.HasPrefix(*in.Raw, "=") {
+		return in
+	}
+	var raw string
+	switch applied.Type {
+	case OpInsertRows, OpDeleteRows, OpInsertCols, OpDeleteCols:
+		axis, delta := "row", applied.Count
+		if applied.Type == OpInsertCols || applied.Type == OpDeleteCols {
+			axis = "col"
+		}
+		if applied.Type == OpDeleteRows || applied.Type == OpDeleteCols {
+			delta = -delta
+		}
+		raw = shiftRefs(*in.Raw, func(sheet string) bool {
+			if sheet == "" {
+				return in.Sheet == applied.Sheet
+			}
+			return applied.SheetName != "" && sameSheetName(sheet, applied.SheetName)
+		}, axis, applied.Index, delta)
+	case OpMoveRange:
+		raw = moveRefs(*in.Raw, func(sheet string) bool {
+			if sheet == "" {
+				return in.Sheet == applied.Sheet
+			}
+			return applied.SheetName != "" && sameSheetName(sheet, applied.SheetName)
+		}, applied.Row, applied.Col, applied.EndRow, applied.EndCol,
+			applied.DestRow-applied.Row, applied.DestCol-applied.Col)
+	case OpRenameSheet:
+		if applied.SheetName == "" {
+			return in
+		}
+		raw = renameRefs(*in.Raw, applied.SheetName, applied.Name)
+	case OpDeleteSheet:
+		if applied.SheetName == "" {
+			return in
+		}
+		raw = dropSheetRefs(*in.Raw, applied.SheetName)
+	default:
+		return in
+	}
+	in.Raw = &raw
+	return in
+}
@@ -0,0 +1,218 @@
+package sheet
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestShiftRefsRows(t *testing.T) {
+	own := func(sheet string) bool { return sheet == "" || sameSheetName(sheet, "Sheet1") }
+	cases := []struct {
+		name  string
+		raw   string
+		index int
+		delta int
+		want  string
+	}{
+		{"insert above moves the range", "=SUM(A2:A10)", 0, 2, "=SUM(A4:A12)"},
+		{"insert inside grows the range", "=SUM(A1:A10)", 4, 1, "=SUM(A1:A11)"},
+		{"insert below the range", "=SUM(A1:A10)", 10, 1, "=SUM(A1:A10)"},
+		{"absolute markers are kept", "=$A$5+B$5+$C5", 0, 1, "=$A$6+B$6+$C6"},
+		{"deleted cell", "=A3*2", 2, -1, "=#REF!*2"},
+		{"delete shrinks the range", "=SUM(A2:A10)", 0, -3, "=SUM(A1:A7)"},
+		{"delete at the end of the range", "=SUM(A2:A10)", 8, -5, "=SUM(A2:A8)"},
+		{"whole range deleted", "=SUM(A3:B4)", 1, -5, "=SUM(#REF!)"},
+		{"row range", "=SUM(2:4)", 0, 1, "=SUM(3:5)"},
+		{"column range is unaffected", "=SUM(A:C)", 0, 1, "=SUM(A:C)"},
+		{"qualified with own name", "=sheet1!B3+'Sheet1'!B3", 0, 1, "=sheet1!B4+Sheet1!B4"},
+		{"other sheet is unaffected", "=Sheet2!B3+B3", 0, 1, "=Sheet2!B3+B4"},
+		{"string literals are left alone", `=A5&"A5"&"say ""A5"""&A5`, 0, 1, `=A6&"A5"&"say ""A5"""&A6`},
+		{"function names are no references", "=LOG10(A5)+ATAN2(A5,1)", 0, 1, "=LOG10(A6)+ATAN2(A6,1)"},
+		{"lowercase refs", "=sum(a5:b6)", 0, 1, "=sum(A6:B7)"},
+		{"values are no formulas", "A5", 0, 1, "A5"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := shiftRefs(tc.raw, own, "row", tc.index, tc.delta); got != tc.want {
+				t.Fatalf("shiftRefs(%q) = %q, want %q", tc.raw, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestShiftRefsCols(t *testing.T) {
+	own := func(sheet string) bool { return sheet == "" }
+	cases := []struct {
+		raw   string
+		index int
+		delta int
+		want  string
+	}{
+		{"=SUM(B1:D1)", 1, 1, "=SUM(C1:E1)"},
+		{"=SUM(A:C)", 1, 2, "=SUM(A:E)"},
+		{"=Z1+AA1", 25, 1, "=AA1+AB1"},
+		{"=SUM(B:B)", 1, -1, "=SUM(#REF!)"},
+		{"=SUM(1:3)", 0, 1, "=SUM(1:3)"},
+	}
+	for _, tc := range cases {
+		if got := shiftRefs(tc.raw, own, "col", tc.index, tc.delta); got != tc.want {
+			t.Fatalf("shiftRefs(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestRenameAndDropSheetRefs(t *testing.T) {
+	if got := renameRefs("=Data!A1+'data'!B2+A1", "Data", "Q1 Numbers"); got != "='Q1 Numbers'!A1+'Q1 Numbers'!B2+A1" {
+		t.Fatalf("rename: got %q", got)
+	}
+	if got := renameRefs("='It''s'!A1", "It's", "B1"); got != "='B1'!A1" {
+		t.Fatalf("rename to a cell-like name must quote: got %q", got)
+	}
+	if got := dropSheetRefs("=Data!A1:B2+Other!A1+A1", "data"); got != "=#REF!+Other!A1+A1" {
+		t.Fatalf("drop: got %q", got)
+	}
+}
+
+func TestApplyRewritesFormulas(t *testing.T) {
+	w := wb2()
+	s1, s2 := w.SheetByID("s1"), w.SheetByID("s2")
+	s1.SetCell(CellRef{0, 0}, Cell{Raw: "=SUM(A2:A5)", Value: "10"})
+	s1.SetCell(CellRef{0, 1}, Cell{Raw: "=Sheet2!B3"})
+	s2.SetCell(CellRef{0, 0}, Cell{Raw: "=Sheet1!A3+A3"})
+
+	if err := w.Apply(Op{Type: OpInsertRows, Sheet: "s1", Index: 2, Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s1.GetCell(CellRef{0, 0}); got.Raw != "=SUM(A2:A6)" || got.Value != "" {
+		t.Fatalf("own formula: got %+v", got)
+	}
+	if got := s2.GetCell(CellRef{0, 0}).Raw; got != "=Sheet1!A4+A3" {
+		t.Fatalf("cross-sheet formula: got %q", got)
+	}
+
+	if err := w.Apply(Op{Type: OpRenameSheet, Sheet: "s2", Name: "Data"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s1.GetCell(CellRef{0, 1}).Raw; got != "=Data!B3" {
+		t.Fatalf("rename: got %q", got)
+	}
+
+	if err := w.Apply(Op{Type: OpDeleteSheet, Sheet: "s2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s1.GetCell(CellRef{0, 1}).Raw; got != "=#REF!" {
+		t.Fatalf("delete: got %q", got)
+	}
+}
+
+func TestTransformRewritesFormula(t *testing.T) {
+	in := Op{Type: OpSetCell, Sheet: "s2", Row: 0, Col: 0, Raw: ptr("=SUM(Sheet1!A1:A3)+A2")}
+	applied := Op{Type: OpDeleteRows, Sheet: "s1", Index: 0, Count: 1, SheetName: "Sheet1"}
+	if got := *Transform(in, applied).Raw; got != "=SUM(Sheet1!A1:A2)+A2" {
+		t.Fatalf("got %q", got)
+	}
+	if *in.Raw != "=SUM(Sheet1!A1:A3)+A2" {
+		t.Fatal("Transform must not change the raw of its input")
+	}
+
+	// Logged before SheetName existed: only unqualified refs of the own sheet.
+	legacy := Op{Type: OpInsertRows, Sheet: "s2", Index: 0, Count: 1}
+	if got := *Transform(in, legacy).Raw; got != "=SUM(Sheet1!A1:A3)+A3" {
+		t.Fatalf("legacy: got %q", got)
+	}
+
+	rename := Op{Type: OpRenameSheet, Sheet: "s1", Name: "Data", SheetName: "Sheet1"}
+	if got := *Transform(in, rename).Raw; got != "=SUM(Data!A1:A3)+A2" {
+		t.Fatalf("rename: got %q", got)
+	}
+	del := Op{Type: OpDeleteSheet, Sheet: "s1", SheetName: "Sheet1"}
+	if got := *Transform(in, del).Raw; got != "=SUM(#REF!)+A2" {
+		t.Fatalf("delete: got %q", got)
+	}
+}
+
+// TestSubmitRebasesFormula composes a formula against a stale revision: the
+// server rewrites it past the concurrent insert exactly like the formulas
+// that were already in the workbook, so both stay consistent.
+func TestSubmitRebasesFormula(t *testing.T) {
+	w := wb2()
+	w.SheetByID("s1").SetCell(CellRef{0, 0}, Cell{Raw: "=Sheet2!A1"})
+	d := NewDocument(w)
+	if _, err := d.Submit(Op{Type: OpInsertRows, Sheet: "s2", Index: 0, Count: 2, BaseRev: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Log()[0].SheetName; got != "Sheet2" {
+		t.Fatalf("logged op must carry the sheet name: got %q", got)
+	}
+	if _, err := d.Submit(Op{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 1, Raw: ptr("=Sheet2!A1"), BaseRev: 0}); err != nil {
+		t.Fatal(err)
+	}
+	s1 := d.Workbook().SheetByID("s1")
+	if a, b := s1.GetCell(CellRef{0, 0}).Raw, s1.GetCell(CellRef{0, 1}).Raw; a != "=Sheet2!A3" || b != a {
+		t.Fatalf("existing %q and rebased %q must agree on =Sheet2!A3", a, b)
+	}
+}
+
+func TestMapFormulaRefs(t *testing.T) {
+	var seen []string
+	got := MapFormulaRefs(`=SUM('It''s'!A1:B2,Data!$C3,"A1",D:D)`, func(sheet, ref string) string {
+		seen = append(seen, sheet+"|"+ref)
+		return "<" + ref + ">"
+	})
+	if got != `=SUM(<A1:B2>,<$C3>,"A1",<D:D>)` {
+		t.Fatalf("got %q", got)
+	}
+	if want := "It's|A1:B2 Data|$C3 |D:D"; strings.Join(seen, " ") != want {
+		t.Fatalf("seen %q, want %q", seen, want)
+	}
+	if got := MapFormulaRefs("A1", func(string, string) string { return "x" }); got != "A1" {
+		t.Fatalf("values are no formulas: got %q", got)
+	}
+}
+
+func TestParseRangeName(t *testing.T) {
+	cases := []struct {
+		name           string
+		r0, c0, r1, c1 int
+		ok             bool
+	}{
+		{"A1", 0, 0, 0, 0, true},
+		{"B2:D20", 1, 1, 19, 3, true},
+		{"d20:b2", 1, 1, 19, 3, true},
+		{"$A$1:AA3", 0, 0, 2, 26, true},
+		{"A:C", 0, 0, 0, 0, false},
+		{"1:3", 0, 0, 0, 0, false},
+		{"A0", 0, 0, 0, 0, false},
+		{"ABCD1", 0, 0, 0, 0, false},
+		{"A1:B2:C3", 0, 0, 0, 0, false},
+		{"", 0, 0, 0, 0, false},
+		{"A1:XFD1048576", 0, 0, 1048575, 16383, true},
+		{"XFE1", 0, 0, 0, 0, false},
+		{"A1048577", 0, 0, 0, 0, false},
+		{"A1:ZZZ600000000000000", 0, 0, 0, 0, false},
+	}
+	for _, c := range cases {
+		r0, c0, r1, c1, ok := ParseRangeName(c.name)
+		if ok != c.ok || (ok && (r0 != c.r0 || c0 != c.c0 || r1 != c.r1 || c1 != c.c1)) {
+			t.Fatalf("ParseRangeName(%q) = %d %d %d %d %v", c.name, r0, c0, r1, c1, ok)
+		}
+	}
+}
+
+func TestRangeExceeds(t *testing.T) {
+	cases := []struct {
+		r0, c0, r1, c1 int
+		exceeds        bool
+	}{
+		{0, 0, 9, 9, false},
+		{0, 0, 9, 10, true},
+		{0, 0, 100, 0, true},
+		{0, 0, math.MaxInt - 1, math.MaxInt - 1, true},
+	}
+	for _, c := range cases {
+		if got := RangeExceeds(c.r0, c.c0, c.r1, c.c1, 100); got != c.exceeds {
+			t.Fatalf("RangeExceeds(%d, %d, %d, %d) = %v", c.r0, c.c0, c.r1, c.c1, got)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package sheet
+
+import (
+	"maps"
+	"slices"
+	"sort"
+)
+
+// Default view sizes (DEFAULT_COL_WIDTH / DEFAULT_ROW_HEIGHT in the client).
+// setDimension cannot unset an override, so RestoreOps writes these instead.
+const (
+	DefaultColWidth  = 80
+	DefaultRowHeight = 22
+)
+
+// Sheet-level change kinds reported by DiffWorkbooks.
+const (
+	SheetAdded    = "added"
+	SheetRemoved  = "removed"
+	SheetModified = "modified"
+)
+
+// CellState is the content of a cell as seen by a reader: the raw input, the
+// cached computed value and the resolved style properties.
+type CellState struct {
+	Raw   string            `json:"raw"`
+	Value string            `json:"value,omitempty"`
+	Props map[string]string `json:"props,omitempty"`
+}
+
+// CellDiff is one cell whose raw input or style differs between two
+// workbooks. Cached values alone never make a difference: clients recompute
+// them.
+type CellDiff struct {
+	Row    int       `json:"row"`
+	Col    int       `json:"col"`
+	Before CellState `json:"before"`
+	After  CellState `json:"after"`
+}
+
+// SheetDiff lists the changes of one sheet (matched by id). OldName is set
+// when the sheet was renamed.
+type SheetDiff struct {
+	Id      string     `json:"id"`
+	Name    string     `json:"name"`
+	OldName string     `json:"oldName,omitempty"`
+	Change  string     `json:"change"`
+	Cells   []CellDiff `json:"cells"`
+}
+
+// DiffWorkbooks compares two states of the same workbook cell by cell. Sheets
+// without changes are left out; added and removed sheets list all their
+// cells. Sheets are reported in the order of to, removed ones last.
+func DiffWorkbooks(from, to *Workbook) []SheetDiff {
+	out := []SheetDiff{}
+	for _, ts := range to.Sheets {
+		fs := from.SheetByID(ts.Id)
+		d := SheetDiff{Id: ts.Id, Name: ts.Name, Change: SheetModified}
+		if fs == nil {
+			d.Change = SheetAdded
+			fs = NewSheet(ts.Id, ts.Name)
+		} else if fs.Name != ts.Name {
+			d.OldName = fs.Name
+		}
+		d.Cells = diffCells(fs, from.Styles, ts, to.Styles)
+		if d.Change == SheetAdded || d.OldName != "" || len(d.Cells) > 0 {
+			out = append(out, d)
+		}
+	}
+	for _, fs := range from.Sheets {
+		if to.SheetByID(fs.Id) == nil {
+			out = append(out, SheetDiff{
+				Id:     fs.Id,
+				Name:   fs.Name,
+				Change: SheetRemoved,
+				Cells:  diffCells(fs, from.Styles, NewSheet(fs.Id, fs.Name), to.Styles),
+			})
+		}
+	}
+	return out
+}
+
+func diffCells(from *Sheet, fromStyles *StylePool, to *Sheet, toStyles *StylePool) []CellDiff {
+	out := []CellDiff{}
+	for _, ref := range unionRefs(from.Cells, to.Cells) {
+		before := cellState(from.GetCell(ref), fromStyles)
+		after := cellState(to.GetCell(ref), toStyles)
+		if before.Raw == after.Raw && maps.Equal(before.Props, after.Props) {
+			continue
+		}
+		out = append(out, CellDiff{Row: ref.Row, Col: ref.Col, Before: before, After: after})
+	}
+	return out
+}
+
+func cellState(c Cell, styles *StylePool) CellState {
+	st, _ := styles.Get(c.StyleId)
+	state := CellState{Raw: c.Raw, Value: c.Value}
+	if len(st.Props) > 0 {
+		state.Props = st.Props
+	}
+	return state
+}
+
+// unionRefs returns the refs populated in either map in (row, col) order.
+func unionRefs(a, b map[CellRef]Cell) []CellRef {
+	seen := make(map[CellRef]bool, len(a)+len(b))
+	refs := make([]CellRef, 0, len(a)+len(b))
+	for _, m := range []map[CellRef]Cell{a, b} {
+		for ref := range m {
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Row != refs[j].Row {
+			return refs[i].Row < refs[j].Row
+		}
+		return refs[i].Col < refs[j].Col
+	})
+	return refs
+}
+
+// RestoreOps returns the ops that turn cur into target when applied in order.
+// Sheet-list ops come first (so the cell ops that follow find their sheets),
+// then cells, merges, dimensions, freeze panes and the rule sets. The ops
+// carry no BaseRev; the caller submits them against the head one by one.
+func RestoreOps(cur, target *Workbook) []Op {
+	work := cur.Clone()
+	var ops []Op
+	emit := func(op Op) {
+		_ = work.Apply(op)
+		ops = append(ops, op)
+	}
+
+	// Add before deleting: the last sheet of a workbook can not be deleted.
+	for i, ts := range target.Sheets {
+		if work.SheetByID(ts.Id) == nil {
+			emit(Op{Type: OpAddSheet, Sheet: ts.Id, Name: ts.Name, Index: i})
+		}
+	}
+	for _, ws := range slices.Clone(work.Sheets) {
+		if target.SheetByID(ws.Id) == nil {
+			emit(Op{Type: OpDeleteSheet, Sheet: ws.Id})
+		}
+	}
+	for i, ts := range target.Sheets {
+		if ws := work.SheetByID(ts.Id); ws.Name != ts.Name {
+			emit(Op{Type: OpRenameSheet, Sheet: ts.Id, Name: ts.Name})
+		}
+		if work.Sheets[i].Id != ts.Id {
+			emit(Op{Type: OpMoveSheet, Sheet: ts.Id, ToIndex: i})
+		}
+	}
+
+	for _, ts := range target.Sheets {
+		ws := work.SheetByID(ts.Id)
+		for _, ref := range unionRefs(ws.Cells, ts.Cells) {
+			have, want := ws.GetCell(ref), ts.GetCell(ref)
+			wantStyle, _ := target.Styles.Get(want.StyleId)
+			haveStyle, _ := work.Styles.Get(have.StyleId)
+			if have.Raw == want.Raw && have.Value == want.Value && have.ValueType == want.ValueType &&
+				maps.Equal(haveStyle.Props, wantStyle.Props) {
+				continue
+			}
+			ops = append(ops, restoreCellOp(ts.Id, ref, want, target.Styles))
+		}
+
+		for _, a := range sortedAnchors(ws.Merges) {
+			if sp := ws.Merges[a]; ts.Merges[a] != sp {
+				ops = append(ops, Op{Type: OpUnmergeCells, Sheet: ts.Id, Row: a.Row, Col: a.Col,
+					EndRow: a.Row + sp.Rows - 1, EndCol: a.Col + sp.Cols - 1})
+			}
+		}
+		for _, a := range sortedAnchors(ts.Merges) {
+			if sp := ts.Merges[a]; ws.Merges[a] != sp {
+				ops = append(ops, Op{Type: OpMergeCells, Sheet: ts.Id, Row: a.Row, Col: a.Col,
+					EndRow: a.Row + sp.Rows - 1, EndCol: a.Col + sp.Cols - 1})
+			}
+		}
+
+		ops = append(ops, dimensionOps(ts.Id, "col", ws.ColWidths, ts.ColWidths, DefaultColWidth)...)
+		ops = append(ops, dimensionOps(ts.Id, "row", ws.RowHeights, ts.RowHeights, DefaultRowHeight)...)
+
+		if ws.FrozenRows != ts.FrozenRows || ws.FrozenCols != ts.FrozenCols {
+			ops = append(ops, Op{Type: OpSetFreeze, Sheet: ts.Id, FrozenRows: ts.FrozenRows, FrozenCols: ts.FrozenCols})
+		}
+	}
+	return append(ops, ruleOps(work, target)...)
+}
+
+// ruleOps turns the rule sets of cur into those of target. Deletes come
+// first: rule ids are unique across kinds, so a rule may only be set again
+// once a rule of another kind with its id is gone.
+func ruleOps(cur, target *Workbook) []Op {
+	var ops []Op
+	have, want := cur.ruleLists(), target.ruleLists()
+	for i, k := range have {
+		for _, r := range *k.rules {
+			if !slices.ContainsFunc(*want[i].rules, func(o Rule) bool { return o.Id == r.Id }) {
+				ops = append(ops, Op{Type: OpDeleteRule, Sheet: r.Sheet, Rule: &Rule{Id: r.Id}})
+			}
+		}
+	}
+	for i, k := range want {
+		for _, r := range *k.rules {
+			j := slices.IndexFunc(*have[i].rules, func(o Rule) bool { return o.Id == r.Id })
+			if j < 0 || !(*have[i].rules)[j].equal(r) {
+				ops = append(ops, r.setOp(k.t))
+			}
+		}
+	}
+	return ops
+}
+
+// restoreCellOp is the setCell that writes c (with its style props) at ref,
+// emptying the cell when c is the zero Cell.
+func restoreCellOp(sheetId string, ref CellRef, c Cell, styles *StylePool) Op {
+	op := Op{Type: OpSetCell, Sheet: sheetId, Row: ref.Row, Col: ref.Col,
+		Raw: &c.Raw, Value: &c.Value, ValueType: &c.ValueType}
+	if style, _ := styles.Get(c.StyleId); len(style.Props) > 0 {
+		op.Props = style.Props
+	} else {
+		zero := 0
+		op.StyleId = &zero
+	}
+	return op
+}
+
+func sortedAnchors(m map[CellRef]Span) []CellRef {
+	anchors := slices.Collect(maps.Keys(m))
+	sort.Slice(anchors, func(i, j int) bool {
+		if anchors[i].Row != anchors[j].Row {
+			return anchors[i].Row < anchors[j].Row
+		}
+		return anchors[i].Col < anchors[j].Col
+	})
+	return anchors
+}
+
+func dimensionOps(sheetId, axis string, have, want map[int]int, def int) []Op {
+	var ops []Op
+	for _, i := range slices.Sorted(maps.Keys(have)) {
+		if _, ok := want[i]; !ok && have[i] != def {
+			ops = append(ops, Op{Type: OpSetDimension, Sheet: sheetId, Axis: axis, Index: i, Size: def})
+		}
+	}
+	for _, i := range slices.Sorted(maps.Keys(want)) {
+		if have[i] != want[i] {
+			ops = append(ops, Op{Type: OpSetDimension, Sheet: sheetId, Axis: axis, Index: i, Size: want[i]})
+		}
+	}
+	return ops
+}
@@ -0,0 +1,113 @@
+package sheet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffWorkbooks(t *testing.T) {
+	from := mkWB(t)
+	from.AddSheet("s2", "Gone")
+	for _, op := range []Op{
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 0, Raw: ptr("a")},
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 1, Raw: ptr("same")},
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 2, Raw: ptr("=1+1"), Value: ptr("2")},
+		{Type: OpSetCell, Sheet: "s2", Row: 1, Col: 1, Raw: ptr("x")},
+	} {
+		if err := from.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	to := from.Clone()
+	for _, op := range []Op{
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 0, Raw: ptr("b")},
+		{Type: OpSetStyle, Sheet: "s1", Row: 1, Col: 0, Props: map[string]string{"bold": "1"}},
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 2, Raw: ptr("=1+1"), Value: ptr("3")}, // value only
+		{Type: OpRenameSheet, Sheet: "s1", Name: "Main"},
+		{Type: OpDeleteSheet, Sheet: "s2"},
+		{Type: OpAddSheet, Sheet: "s3", Name: "New", Index: 0},
+	} {
+		if err := to.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff := DiffWorkbooks(from, to)
+	if len(diff) != 3 {
+		t.Fatalf("diff = %+v", diff)
+	}
+	if d := diff[0]; d.Id != "s3" || d.Change != SheetAdded || len(d.Cells) != 0 {
+		t.Fatalf("added = %+v", d)
+	}
+	d := diff[1]
+	if d.Id != "s1" || d.Change != SheetModified || d.Name != "Main" || d.OldName != "Sheet1" {
+		t.Fatalf("modified = %+v", d)
+	}
+	want := []CellDiff{
+		{Row: 0, Col: 0, Before: CellState{Raw: "a"}, After: CellState{Raw: "b"}},
+		{Row: 1, Col: 0, After: CellState{Props: map[string]string{"bold": "1"}}},
+	}
+	if !reflect.DeepEqual(d.Cells, want) {
+		t.Fatalf("cells = %+v", d.Cells)
+	}
+	if d := diff[2]; d.Id != "s2" || d.Change != SheetRemoved || len(d.Cells) != 1 || d.Cells[0].Before.Raw != "x" {
+		t.Fatalf("removed = %+v", d)
+	}
+	if diff := DiffWorkbooks(from, from.Clone()); len(diff) != 0 {
+		t.Fatalf("self diff = %+v", diff)
+	}
+}
+
+func TestRestoreOps(t *testing.T) {
+	target := mkWB(t)
+	target.AddSheet("s2", "Second")
+	for _, op := range []Op{
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 0, Raw: ptr("keep")},
+		{Type: OpSetCell, Sheet: "s1", Row: 1, Col: 0, Raw: ptr("=Second!A1"), Value: ptr("7")},
+		{Type: OpSetCell, Sheet: "s1", Row: 2, Col: 0, Raw: ptr("styled"), Props: map[string]string{"italic": "1"}},
+		{Type: OpSetCell, Sheet: "s2", Row: 0, Col: 0, Raw: ptr("7")},
+		mergeOp("s1", 4, 0, 5, 1),
+		{Type: OpSetDimension, Sheet: "s1", Axis: "col", Index: 2, Size: 120},
+		{Type: OpSetFreeze, Sheet: "s2", FrozenRows: 1},
+	} {
+		if err := target.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cur := target.Clone()
+	for _, op := range []Op{
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 0, Raw: ptr("changed")},
+		{Type: OpSetStyle, Sheet: "s1", Row: 2, Col: 0, Props: map[string]string{"bold": "1"}},
+		{Type: OpSetCell, Sheet: "s1", Row: 3, Col: 3, Raw: ptr("new")},
+		{Type: OpRenameSheet, Sheet: "s2", Name: "Renamed"},
+		{Type: OpAddSheet, Sheet: "s3", Name: "Third", Index: 0},
+		{Type: OpMoveSheet, Sheet: "s2", ToIndex: 0},
+		{Type: OpDeleteSheet, Sheet: "s1"},
+		{Type: OpSetDimension, Sheet: "s2", Axis: "row", Index: 0, Size: 50},
+		{Type: OpSetFreeze, Sheet: "s2", FrozenRows: 0, FrozenCols: 1},
+	} {
+		if err := cur.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ops := RestoreOps(cur, target)
+	for _, op := range ops {
+		if err := cur.Apply(op); err != nil {
+			t.Fatalf("apply %+v: %v", op, err)
+		}
+	}
+	got, want := cur.Snapshot().Sheets, target.Snapshot().Sheets
+	// The row override can only be reset to the default size.
+	if got[1].RowHeights[0] != DefaultRowHeight {
+		t.Fatalf("row heights = %v", got[1].RowHeights)
+	}
+	got[1].RowHeights = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("restored = %+v\nwant %+v", got, want)
+	}
+	if again := RestoreOps(cur, target); len(again) != 0 {
+		t.Fatalf("second restore = %+v", again)
+	}
+}
@@ -0,0 +1,159 @@
+package sheet
+
+import "slices"
+
+// Inverse returns the ops that undo op when applied in order right after it.
+// It must be called on the workbook op is about to be applied to, with op
+// already rebased to it (see Document.Rebase): the inverse captures what op
+// removes or overwrites there, i.e. cell contents and styles, dimensions,
+// merges, rules and whole sheets. An op that changes nothing has no inverse.
+//
+// Structural ops are undone by their counterpart (a delete by an insert and
+// so on) followed by the ops restoring what the round trip loses, so that
+// ops of other authors applied in between still transform past the inverse.
+func (w *Workbook) Inverse(op Op) []Op {
+	switch op.Type {
+	case OpAddSheet:
+		if w.SheetByID(op.Sheet) != nil {
+			return nil
+		}
+		return []Op{{Type: OpDeleteSheet, Sheet: op.Sheet}}
+	case OpDeleteSheet:
+		if len(w.Sheets) <= 1 || w.SheetByID(op.Sheet) == nil {
+			return nil
+		}
+		return w.simulateInverse(op, nil)
+	case OpRenameSheet:
+		s := w.SheetByID(op.Sheet)
+		if s == nil || s.Name == op.Name {
+			return nil
+		}
+		return w.simulateInverse(op, &Op{Type: OpRenameSheet, Sheet: op.Sheet, Name: s.Name})
+	case OpMoveSheet:
+		i := slices.IndexFunc(w.Sheets, func(s *Sheet) bool { return s.Id == op.Sheet })
+		if i < 0 || min(op.ToIndex, len(w.Sheets)-1) == i {
+			return nil
+		}
+		return []Op{{Type: OpMoveSheet, Sheet: op.Sheet, ToIndex: i}}
+	case OpSetValidation, OpSetCondFormat, OpSetProtection, OpDeleteRule:
+		return w.ruleInverse(op)
+	}
+
+	s := w.SheetByID(op.Sheet)
+	if s == nil {
+		return nil
+	}
+	switch op.Type {
+	case OpSetCell, OpSetStyle:
+		ref := CellRef{op.Row, op.Col}
+		return []Op{restoreCellOp(s.Id, ref, s.GetCell(ref), w.Styles)}
+	case OpClearRange:
+		var ops []Op
+		for _, ref := range unionRefs(s.Cells, nil) {
+			if inRange(ref, op.Row, op.Col, op.EndRow, op.EndCol) {
+				ops = append(ops, restoreCellOp(s.Id, ref, s.GetCell(ref), w.Styles))
+			}
+		}
+		return ops
+	case OpSortRange, OpCopyRange, OpFillSeries:
+		after := s.clone()
+		switch op.Type {
+		case OpSortRange:
+			after.sortRange(op)
+		case OpCopyRange:
+			after.copyRange(op)
+		default:
+			after.fillSeries(op)
+		}
+		var ops []Op
+		for _, ref := range unionRefs(s.Cells, after.Cells) {
+			if c := s.GetCell(ref); c != after.GetCell(ref) {
+				ops = append(ops, restoreCellOp(s.Id, ref, c, w.Styles))
+			}
+		}
+		return ops
+	case OpMoveRange:
+		if op.DestRow == op.Row && op.DestCol == op.Col {
+			return nil
+		}
+		return w.simulateInverse(op, nil)
+	case OpInsertRows, OpInsertCols, OpDeleteRows, OpDeleteCols:
+		if op.Count <= 0 {
+			return nil
+		}
+		counterpart := map[OpType]OpType{
+			OpInsertRows: OpDeleteRows, OpDeleteRows: OpInsertRows,
+			OpInsertCols: OpDeleteCols, OpDeleteCols: OpInsertCols,
+		}[op.Type]
+		return w.simulateInverse(op, &Op{Type: counterpart, Sheet: op.Sheet, Index: op.Index, Count: op.Count})
+	case OpSetDimension:
+		dims, def := s.RowHeights, DefaultRowHeight
+		if op.Axis == "col" {
+			dims, def = s.ColWidths, DefaultColWidth
+		}
+		size, ok := dims[op.Index]
+		if !ok {
+			size = def
+		}
+		return []Op{{Type: OpSetDimension, Sheet: s.Id, Axis: op.Axis, Index: op.Index, Size: size}}
+	case OpSetFreeze:
+		return []Op{{Type: OpSetFreeze, Sheet: s.Id, FrozenRows: s.FrozenRows, FrozenCols: s.FrozenCols}}
+	case OpMergeCells:
+		if op.EndRow == op.Row && op.EndCol == op.Col {
+			return nil
+		}
+		ops := []Op{{Type: OpUnmergeCells, Sheet: s.Id, Row: op.Row, Col: op.Col, EndRow: op.EndRow, EndCol: op.EndCol}}
+		return append(ops, s.mergeOps(op)...)
+	case OpUnmergeCells:
+		return s.mergeOps(op)
+	}
+	return nil
+}
+
+// simulateInverse undoes op by applying it to a copy of the workbook followed
+// by base, when set, and restoring whatever differs from w after that.
+func (w *Workbook) simulateInverse(op Op, base *Op) []Op {
+	sim := w.Clone()
+	if err := sim.Apply(op); err != nil {
+		return nil
+	}
+	var ops []Op
+	if base != nil {
+		if err := sim.Apply(*base); err != nil {
+			return nil
+		}
+		ops = append(ops, *base)
+	}
+	return append(ops, RestoreOps(sim, w)...)
+}
+
+// mergeOps re-creates the merges of s that touch the rectangle of op.
+func (s *Sheet) mergeOps(op Op) []Op {
+	var ops []Op
+	for _, a := range sortedAnchors(s.Merges) {
+		if sp := s.Merges[a]; intersects(a, sp, op.Row, op.Col, op.EndRow, op.EndCol) {
+			ops = append(ops, Op{Type: OpMergeCells, Sheet: s.Id, Row: a.Row, Col: a.Col,
+				EndRow: a.Row + sp.Rows - 1, EndCol: a.Col + sp.Cols - 1})
+		}
+	}
+	return ops
+}
+
+// ruleInverse restores the rule a rule op replaces or removes, or deletes the
+// one it adds.
+func (w *Workbook) ruleInverse(op Op) []Op {
+	for _, k := range w.ruleLists() {
+		i := slices.IndexFunc(*k.rules, func(r Rule) bool { return r.Id == op.Rule.Id })
+		if i < 0 {
+			continue
+		}
+		if op.Type != OpDeleteRule && op.Type != k.t {
+			return nil // the set op fails: the id is in use by another kind
+		}
+		return []Op{(*k.rules)[i].setOp(k.t)}
+	}
+	if op.Type == OpDeleteRule {
+		return nil
+	}
+	return []Op{{Type: OpDeleteRule, Sheet: op.Sheet, Rule: &Rule{Id: op.Rule.Id}}}
+}
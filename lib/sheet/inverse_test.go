@@ -0,0 +1,112 @@
+package sheet
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+)
+
+// inverseWB is a workbook with something for every op to remove or change.
+func inverseWB(t *testing.T) *Workbook {
+	t.Helper()
+	w := mkWB(t)
+	w.AddSheet("s2", "Data")
+	for _, op := range []Op{
+		{Type: OpSetCell, Sheet: "s1", Row: 0, Col: 0, Raw: ptr("3")},
+		{Type: OpSetCell, Sheet: "s1", Row: 1, Col: 0, Raw: ptr("1"), Props: map[string]string{"bold": "1"}},
+		{Type: OpSetCell, Sheet: "s1", Row: 2, Col: 0, Raw: ptr("2")},
+		{Type: OpSetCell, Sheet: "s1", Row: 1, Col: 1, Raw: ptr("=A2+A3"), Value: ptr("3")},
+		{Type: OpSetCell, Sheet: "s1", Row: 5, Col: 0, Raw: ptr("=SUM(A1:A3)+Data!A1"), Value: ptr("13")},
+		{Type: OpSetCell, Sheet: "s2", Row: 0, Col: 0, Raw: ptr("7")},
+		{Type: OpSetDimension, Sheet: "s1", Axis: "row", Index: 1, Size: 40},
+		{Type: OpSetDimension, Sheet: "s1", Axis: "col", Index: 1, Size: 120},
+		mergeOp("s1", 1, 2, 2, 3),
+		{Type: OpSetFreeze, Sheet: "s1", FrozenRows: 1},
+		ruleOp(OpSetValidation, 0, 0, 2, 0, listRule("v1", "1", "2", "3")),
+		ruleOp(OpSetProtection, 1, 0, 1, 3, protectRule("p1", "a.1")),
+	} {
+		if err := w.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+func TestInverseRestoresWorkbook(t *testing.T) {
+	ops := []Op{
+		{Type: OpSetCell, Sheet: "s1", Row: 1, Col: 0, Raw: ptr("x")},
+		{Type: OpSetCell, Sheet: "s1", Row: 9, Col: 9, Raw: ptr("new")},
+		{Type: OpSetStyle, Sheet: "s1", Row: 1, Col: 0, Props: map[string]string{"italic": "1"}},
+		{Type: OpClearRange, Sheet: "s1", Row: 0, Col: 0, EndRow: 2, EndCol: 1},
+		{Type: OpInsertRows, Sheet: "s1", Index: 1, Count: 2},
+		{Type: OpDeleteRows, Sheet: "s1", Index: 1, Count: 1},
+		{Type: OpDeleteRows, Sheet: "s1", Index: 0, Count: 3},
+		{Type: OpInsertCols, Sheet: "s1", Index: 0, Count: 1},
+		{Type: OpDeleteCols, Sheet: "s1", Index: 0, Count: 2},
+		{Type: OpAddSheet, Sheet: "s3", Name: "New", Index: 0},
+		{Type: OpRenameSheet, Sheet: "s2", Name: "Renamed"},
+		{Type: OpDeleteSheet, Sheet: "s2"},
+		{Type: OpMoveSheet, Sheet: "s2", ToIndex: 0},
+		{Type: OpSetDimension, Sheet: "s1", Axis: "row", Index: 1, Size: 60},
+		{Type: OpSetDimension, Sheet: "s1", Axis: "col", Index: 4, Size: 60},
+		{Type: OpSetFreeze, Sheet: "s1", FrozenCols: 1},
+		mergeOp("s1", 0, 1, 3, 4),
+		{Type: OpUnmergeCells, Sheet: "s1", Row: 0, Col: 0, EndRow: 5, EndCol: 5},
+		{Type: OpSortRange, Sheet: "s1", Row: 0, Col: 0, EndRow: 2, EndCol: 1, SortKeys: []SortKey{{Col: 0}}},
+		{Type: OpMoveRange, Sheet: "s1", Row: 0, Col: 0, EndRow: 2, EndCol: 0, DestRow: 1, DestCol: 0},
+		{Type: OpCopyRange, Sheet: "s1", Row: 0, Col: 0, EndRow: 2, EndCol: 1, DestRow: 1, DestCol: 1},
+		{Type: OpFillSeries, Sheet: "s1", Row: 0, Col: 0, EndRow: 6, EndCol: 0, Axis: "row", Count: 2},
+		ruleOp(OpSetValidation, 0, 0, 4, 0, listRule("v1", "a")),
+		ruleOp(OpSetCondFormat, 0, 0, 4, 0, Rule{Id: "c1", Type: CondFormatCellIs, Operator: "greaterThan", Value1: "1", Props: map[string]string{"bold": "1"}}),
+		{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "p1"}},
+	}
+	for _, op := range ops {
+		w := inverseWB(t)
+		before := w.Clone()
+		inverse := w.Inverse(op)
+		if err := w.Apply(op); err != nil {
+			t.Fatalf("%s: apply: %v", op.Type, err)
+		}
+		for _, inv := range inverse {
+			if err := w.Apply(inv); err != nil {
+				t.Fatalf("%s: apply inverse %+v: %v", op.Type, inv, err)
+			}
+		}
+		// A new size override can only be reset to the default size.
+		for _, s := range w.Sheets {
+			maps.DeleteFunc(s.ColWidths, func(_, size int) bool { return size == DefaultColWidth })
+			maps.DeleteFunc(s.RowHeights, func(_, size int) bool { return size == DefaultRowHeight })
+		}
+		if got, want := w.Snapshot().Sheets, before.Snapshot().Sheets; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: undone = %+v\nwant %+v", op.Type, got, want)
+		}
+		if rest := RestoreOps(w, before); len(rest) != 0 {
+			t.Fatalf("%s: left over %+v", op.Type, rest)
+		}
+	}
+}
+
+func TestInverseStructuralStartsWithCounterpart(t *testing.T) {
+	w := inverseWB(t)
+	inverse := w.Inverse(Op{Type: OpDeleteRows, Sheet: "s1", Index: 1, Count: 2})
+	if len(inverse) < 2 || inverse[0].Type != OpInsertRows || inverse[0].Index != 1 || inverse[0].Count != 2 {
+		t.Fatalf("inverse = %+v", inverse)
+	}
+}
+
+func TestInverseOfNoOp(t *testing.T) {
+	w := inverseWB(t)
+	for _, op := range []Op{
+		{Type: OpAddSheet, Sheet: "s2", Name: "Again"},
+		{Type: OpRenameSheet, Sheet: "s2", Name: "Data"},
+		{Type: OpMoveSheet, Sheet: "s1", ToIndex: 0},
+		{Type: OpSetCell, Sheet: "gone", Row: 0, Col: 0, Raw: ptr("x")},
+		{Type: OpUnmergeCells, Sheet: "s1", Row: 8, Col: 8, EndRow: 9, EndCol: 9},
+		{Type: OpClearRange, Sheet: "s1", Row: 8, Col: 8, EndRow: 9, EndCol: 9},
+		{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "none"}},
+	} {
+		if inverse := w.Inverse(op); len(inverse) != 0 {
+			t.Fatalf("%s: inverse = %+v", op.Type, inverse)
+		}
+	}
+}
@@ -27,8 +27,34 @@ const (
 	// Merged cells: both carry the Row/Col..EndRow/EndCol rectangle.
 	OpMergeCells   OpType = "mergeCells"
 	OpUnmergeCells OpType = "unmergeCells"
+	// Range ops. sortRange, moveRange and copyRange carry the source
+	// rectangle, fillSeries the whole target including the seed.
+	OpSortRange  OpType = "sortRange"
+	OpMoveRange  OpType = "moveRange"
+	OpCopyRange  OpType = "copyRange"
+	OpFillSeries OpType = "fillSeries"
+	// Workbook-level rules. The set ops add or replace (by Rule.Id) a data
+	// validation, conditional format or protected range over the op's
+	// Row/Col..EndRow/EndCol on Sheet; deleteRule removes any kind by id.
+	OpSetValidation OpType = "setValidation"
+	OpSetCondFormat OpType = "setCondFormat"
+	OpSetProtection OpType = "setProtection"
+	OpDeleteRule    OpType = "deleteRule"
 )
 
+// Limits for range ops. fillSeries writes every cell of its target, so the
+// target size is bounded; the others only touch populated cells.
+const (
+	MaxSortKeys  = 8
+	MaxFillCells = 100000
+)
+
+// SortKey is one sortRange key: an absolute column inside the range.
+type SortKey struct {
+	Col  int  `json:"col"`
+	Desc bool `json:"desc,omitempty"`
+}
+
 // Op is one cell-based operation. BaseRev is the workbook revision the client
 // composed it against (used by the server to rebase stale ops). Payload fields
 // are optional per type.
@@ -40,7 +66,7 @@ type Op struct {
 	// Cell ops (setCell, setStyle) and the top-left of a range (clearRange).
 	Row int `json:"row,omitempty"`
 	Col int `json:"col,omitempty"`
-	// Range end (inclusive) for clearRange.
+	// Range end (inclusive) for clearRange, merges and the range ops.
 	EndRow int `json:"endRow,omitempty"`
 	EndCol int `json:"endCol,omitempty"`
 
@@ -63,13 +89,29 @@ type Op struct {
 	Name    string `json:"name,omitempty"`    // addSheet, renameSheet
 	ToIndex int    `json:"toIndex,omitempty"` // moveSheet
 
-	// setDimension.
+	// setDimension; fillSeries fills down ("row") or right ("col") from a
+	// seed of Count rows/cols.
 	Axis string `json:"axis,omitempty"` // "col" or "row"
 	Size int    `json:"size,omitempty"` // px
 
+	// sortRange keys, most significant first.
+	SortKeys []SortKey `json:"sortKeys,omitempty"`
+	// moveRange, copyRange: top-left of the destination.
+	DestRow int `json:"destRow,omitempty"`
+	DestCol int `json:"destCol,omitempty"`
+
+	// Rule ops. The rule's sheet and range are the op's Sheet and
+	// Row/Col..EndRow/EndCol, so Transform moves them like any other range.
+	Rule *Rule `json:"rule,omitempty"`
+
 	// setFreeze. 0 or 1 each (freeze first row / first col only for now).
 	FrozenRows int `json:"frozenRows,omitempty"`
 	FrozenCols int `json:"frozenCols,omitempty"`
+
+	// Set by the server on logged structural, renameSheet and deleteSheet ops:
+	// the name of the sheet before the op. Transform needs it to rewrite
+	// sheet-qualified formula references; clients never set it.
+	SheetName string `json:"sheetName,omitempty"`
 }
 
 func (o Op) isStructural() bool {
@@ -80,6 +122,11 @@ func (o Op) isStructural() bool {
 	return false
 }
 
+// validRange reports whether Row/Col..EndRow/EndCol is a valid rectangle.
+func (o Op) validRange() bool {
+	return o.Row >= 0 && o.Col >= 0 && o.EndRow >= o.Row && o.EndCol >= o.Col
+}
+
 // Validate checks structural invariants independent of any workbook state.
 func (o Op) Validate() error {
 	if o.Sheet == "" {
@@ -109,9 +156,59 @@ func (o Op) Validate() error {
 	// mergeCells allows a degenerate 1x1 rectangle (Apply no-ops it): rebasing
 	// past a concurrent row/col delete can collapse a valid merge to one cell.
 	case OpClearRange, OpMergeCells, OpUnmergeCells:
-		if o.Row < 0 || o.Col < 0 || o.EndRow < o.Row || o.EndCol < o.Col {
+		if !o.validRange() {
 			return fmt.Errorf("%s invalid bounds", o.Type)
 		}
+	case OpSortRange:
+		if !o.validRange() {
+			return fmt.Errorf("sortRange invalid bounds")
+		}
+		if len(o.SortKeys) == 0 || len(o.SortKeys) > MaxSortKeys {
+			return fmt.Errorf("sortRange needs 1 to %d sort keys", MaxSortKeys)
+		}
+		for _, k := range o.SortKeys {
+			if k.Col < o.Col || k.Col > o.EndCol {
+				return fmt.Errorf("sortRange key column outside the range")
+			}
+		}
+	case OpMoveRange, OpCopyRange:
+		if !o.validRange() {
+			return fmt.Errorf("%s invalid bounds", o.Type)
+		}
+		if o.DestRow < 0 || o.DestCol < 0 {
+			return fmt.Errorf("%s negative destination", o.Type)
+		}
+	case OpFillSeries:
+		if !o.validRange() {
+			return fmt.Errorf("fillSeries invalid bounds")
+		}
+		rows, cols := o.EndRow-o.Row+1, o.EndCol-o.Col+1
+		if rows > MaxFillCells || cols > MaxFillCells || rows*cols > MaxFillCells {
+			return fmt.Errorf("fillSeries range too large")
+		}
+		length := rows
+		if o.Axis == "col" {
+			length = cols
+		} else if o.Axis != "row" {
+			return fmt.Errorf("fillSeries axis must be col or row")
+		}
+		if o.Count < 1 || o.Count > length {
+			return fmt.Errorf("fillSeries seed count out of range")
+		}
+	case OpSetValidation, OpSetCondFormat, OpSetProtection:
+		if !o.validRange() {
+			return fmt.Errorf("%s invalid bounds", o.Type)
+		}
+		if o.Rule == nil {
+			return fmt.Errorf("%s needs a rule", o.Type)
+		}
+		if err := o.Rule.validate(o.Type); err != nil {
+			return err
+		}
+	case OpDeleteRule:
+		if o.Rule == nil || !validRuleId(o.Rule.Id) {
+			return fmt.Errorf("deleteRule needs a rule id")
+		}
 	case OpInsertRows, OpDeleteRows, OpInsertCols, OpDeleteCols:
 		if o.Index < 0 {
 			return fmt.Errorf("%s negative index", o.Type)
@@ -0,0 +1,349 @@
+package sheet
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort order of value types: numbers, text, booleans, errors, then blanks.
+// Blanks sort last in both directions (Excel behavior).
+const (
+	sortNumber = iota
+	sortText
+	sortBool
+	sortError
+	sortBlank
+)
+
+var (
+	// sortNumberRe is what sorts as a number; ui/src/js/sheet/rangeOps.ts
+	// uses the same pattern so Number() and ParseFloat agree on the value.
+	sortNumberRe = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+	// Series seeds: plain decimals, and text ending in a number ("Item 9").
+	seriesNumberRe = regexp.MustCompile(`^[+-]?\d+(\.\d+)?$`)
+	seriesTextRe   = regexp.MustCompile(`(?s)^(.*\D)(\d{1,9})$`)
+)
+
+var errorValues = map[string]bool{
+	"#NULL!": true, "#DIV/0!": true, "#VALUE!": true, "#REF!": true,
+	"#NAME?": true, "#NUM!": true, "#N/A": true,
+}
+
+// sortValue is the sort key of one cell.
+type sortValue struct {
+	kind int
+	num  float64
+	text string
+}
+
+// sortValueOf classifies a cell for sortRange. Formulas sort by their cached
+// value (clients report it before sorting); without one they sort as blanks.
+// Text compares case-insensitively for ASCII letters, then by code point.
+func sortValueOf(c Cell) sortValue {
+	v := c.Raw
+	if c.Kind() == KindFormula {
+		v = c.Value
+	}
+	switch lower := lowerASCIIString(v); {
+	case v == "":
+		return sortValue{kind: sortBlank}
+	case sortNumberRe.MatchString(v):
+		f, _ := strconv.ParseFloat(v, 64)
+		return sortValue{kind: sortNumber, num: f}
+	case lower == "true":
+		return sortValue{kind: sortBool, num: 1}
+	case lower == "false":
+		return sortValue{kind: sortBool}
+	case errorValues[v]:
+		return sortValue{kind: sortError, text: v}
+	default:
+		return sortValue{kind: sortText, text: lower}
+	}
+}
+
+// compareSortValues orders a before b (< 0) or after it (> 0).
+func compareSortValues(a, b sortValue, desc bool) int {
+	if a.kind == sortBlank || b.kind == sortBlank {
+		return cmp.Compare(a.kind, b.kind)
+	}
+	c := cmp.Compare(a.kind, b.kind)
+	if c == 0 {
+		switch a.kind {
+		case sortNumber, sortBool:
+			c = cmp.Compare(a.num, b.num)
+		default:
+			c = strings.Compare(a.text, b.text)
+		}
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+func lowerASCIIString(s string) string {
+	b := []byte(s)
+	for i := range b {
+		b[i] = lowerASCII(b[i])
+	}
+	return string(b)
+}
+
+// inRange reports whether ref lies in the inclusive rectangle [r0..r1] x [c0..c1].
+func inRange(ref CellRef, r0, c0, r1, c1 int) bool {
+	return ref.Row >= r0 && ref.Row <= r1 && ref.Col >= c0 && ref.Col <= c1
+}
+
+// takeRange removes the cells of the inclusive rectangle and returns them.
+func (s *Sheet) takeRange(r0, c0, r1, c1 int) map[CellRef]Cell {
+	out := map[CellRef]Cell{}
+	for ref, c := range s.Cells {
+		if inRange(ref, r0, c0, r1, c1) {
+			out[ref] = c
+			delete(s.Cells, ref)
+		}
+	}
+	return out
+}
+
+// offsetCell returns c as copied dRow rows and dCol columns away: relative
+// references of a formula move along and the cached value is dropped.
+func offsetCell(c Cell, dRow, dCol int) Cell {
+	if c.Kind() != KindFormula {
+		return c
+	}
+	if raw := offsetRefs(c.Raw, dRow, dCol); raw != c.Raw {
+		c.Raw, c.Value, c.ValueType = raw, "", ""
+	}
+	return c
+}
+
+// sortRange reorders whole rows of the range (within its columns) by the sort
+// keys. The sort is stable. Rows whose keys are all blank keep their order
+// after the others; moved formulas shift their relative row references like
+// a copy does. Merges stay where they are.
+func (s *Sheet) sortRange(op Op) {
+	moved := s.takeRange(op.Row, op.Col, op.EndRow, op.EndCol)
+	rows := map[int]map[int]Cell{}
+	for ref, c := range moved {
+		if rows[ref.Row] == nil {
+			rows[ref.Row] = map[int]Cell{}
+		}
+		rows[ref.Row][ref.Col] = c
+	}
+
+	type keyedRow struct {
+		row  int
+		keys []sortValue
+	}
+	var keyed []keyedRow
+	var blank []int
+	for r, cells := range rows {
+		keys := make([]sortValue, len(op.SortKeys))
+		allBlank := true
+		for i, k := range op.SortKeys {
+			keys[i] = sortValueOf(cells[k.Col])
+			allBlank = allBlank && keys[i].kind == sortBlank
+		}
+		if allBlank {
+			blank = append(blank, r)
+		} else {
+			keyed = append(keyed, keyedRow{r, keys})
+		}
+	}
+	slices.SortFunc(keyed, func(a, b keyedRow) int {
+		for i, k := range op.SortKeys {
+			if c := compareSortValues(a.keys[i], b.keys[i], k.Desc); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.row, b.row)
+	})
+
+	dest := make(map[int]int, len(rows))
+	keyedRows := make([]int, len(keyed))
+	for i, k := range keyed {
+		dest[k.row] = op.Row + i
+		keyedRows[i] = k.row
+	}
+	// The all-blank rows, populated or not, fill the rest in their order.
+	slices.Sort(keyedRows)
+	for _, r := range blank {
+		before := sort.SearchInts(keyedRows, r)
+		dest[r] = op.Row + len(keyed) + (r - op.Row - before)
+	}
+
+	for r, cells := range rows {
+		for col, c := range cells {
+			s.Cells[CellRef{dest[r], col}] = offsetCell(c, dest[r]-r, 0)
+		}
+	}
+}
+
+// moveRange moves the cells of the range to the destination like a cut and
+// paste: the destination is overwritten, and references to the moved cells
+// anywhere in the workbook follow them (see moveRefs).
+func (w *Workbook) moveRange(s *Sheet, op Op) {
+	dRow, dCol := op.DestRow-op.Row, op.DestCol-op.Col
+	if dRow == 0 && dCol == 0 {
+		return
+	}
+	target := func(in *Sheet) func(string) bool {
+		return func(sheet string) bool {
+			if sheet == "" {
+				return in == s
+			}
+			return sameSheetName(sheet, s.Name)
+		}
+	}
+	moved := s.takeRange(op.Row, op.Col, op.EndRow, op.EndCol)
+	w.rewriteFormulas(func(in *Sheet, raw string) string {
+		return moveRefs(raw, target(in), op.Row, op.Col, op.EndRow, op.EndCol, dRow, dCol)
+	})
+	s.takeRange(op.DestRow, op.DestCol, op.DestRow+op.EndRow-op.Row, op.DestCol+op.EndCol-op.Col)
+	for ref, c := range moved {
+		if c.Kind() == KindFormula {
+			if raw := moveRefs(c.Raw, target(s), op.Row, op.Col, op.EndRow, op.EndCol, dRow, dCol); raw != c.Raw {
+				c.Raw, c.Value, c.ValueType = raw, "", ""
+			}
+		}
+		s.Cells[CellRef{ref.Row + dRow, ref.Col + dCol}] = c
+	}
+}
+
+// copyRange copies the cells of the range to the destination, overwriting
+// it. Relative references of copied formulas move along (see offsetRefs).
+func (s *Sheet) copyRange(op Op) {
+	dRow, dCol := op.DestRow-op.Row, op.DestCol-op.Col
+	src := map[CellRef]Cell{}
+	for ref, c := range s.Cells {
+		if inRange(ref, op.Row, op.Col, op.EndRow, op.EndCol) {
+			src[ref] = c
+		}
+	}
+	s.takeRange(op.DestRow, op.DestCol, op.DestRow+op.EndRow-op.Row, op.DestCol+op.EndCol-op.Col)
+	for ref, c := range src {
+		s.Cells[CellRef{ref.Row + dRow, ref.Col + dCol}] = offsetCell(c, dRow, dCol)
+	}
+}
+
+// fillSeries extends the first Count cells of every column (Axis "row") or
+// row (Axis "col") of the range over the rest of it; see series.
+func (s *Sheet) fillSeries(op Op) {
+	lines, length := op.EndCol-op.Col+1, op.EndRow-op.Row+1
+	at := func(line, pos int) CellRef { return CellRef{op.Row + pos, op.Col + line} }
+	if op.Axis == "col" {
+		lines, length = length, lines
+		at = func(line, pos int) CellRef { return CellRef{op.Row + line, op.Col + pos} }
+	}
+	for line := 0; line < lines; line++ {
+		seed := make([]Cell, op.Count)
+		for i := range seed {
+			seed[i] = s.GetCell(at(line, i))
+		}
+		next := series(seed, op.Axis)
+		for pos := op.Count; pos < length; pos++ {
+			s.SetCell(at(line, pos), next(pos))
+		}
+	}
+}
+
+// series returns the cell at position pos of the series the seed starts:
+//   - two or more plain numbers continue with their average step, rounded to
+//     the most decimals among them;
+//   - text ending in a number ("Item 1") counts on, one by one from a single
+//     seed or by the common step of several;
+//   - anything else repeats the seed, formulas moved like a copy.
+//
+// Styles always repeat the seed's.
+func series(seed []Cell, axis string) func(pos int) Cell {
+	n := len(seed)
+	style := func(pos int) int { return seed[pos%n].StyleId }
+	if first, step, decimals, ok := numberSeries(seed); ok {
+		return func(pos int) Cell {
+			return Cell{Raw: formatSeriesNumber(first+step*float64(pos), decimals), StyleId: style(pos)}
+		}
+	}
+	if prefix, width, first, step, ok := textSeries(seed); ok {
+		return func(pos int) Cell {
+			v := first + step*pos
+			if v < 0 {
+				v = -v
+			}
+			return Cell{Raw: fmt.Sprintf("%s%0*d", prefix, width, v), StyleId: style(pos)}
+		}
+	}
+	return func(pos int) Cell {
+		k := pos % n
+		if axis == "col" {
+			return offsetCell(seed[k], 0, pos-k)
+		}
+		return offsetCell(seed[k], pos-k, 0)
+	}
+}
+
+func numberSeries(seed []Cell) (first, step float64, decimals int, ok bool) {
+	if len(seed) < 2 {
+		return 0, 0, 0, false
+	}
+	nums := make([]float64, len(seed))
+	for i, c := range seed {
+		if !seriesNumberRe.MatchString(c.Raw) {
+			return 0, 0, 0, false
+		}
+		nums[i], _ = strconv.ParseFloat(c.Raw, 64)
+		if math.Abs(nums[i]) >= 1e15 {
+			return 0, 0, 0, false
+		}
+		if dot := strings.IndexByte(c.Raw, '.'); dot >= 0 {
+			decimals = max(decimals, len(c.Raw)-dot-1)
+		}
+	}
+	if decimals > 10 {
+		return 0, 0, 0, false
+	}
+	return nums[0], (nums[len(nums)-1] - nums[0]) / float64(len(nums)-1), decimals, true
+}
+
+// formatSeriesNumber rounds v half up to decimals places and prints it
+// without trailing zeros.
+func formatSeriesNumber(v float64, decimals int) string {
+	p := 1.0
+	for range decimals {
+		p *= 10
+	}
+	r := math.Floor(v*p+0.5) / p
+	if r == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(r, 'f', -1, 64)
+}
+
+func textSeries(seed []Cell) (prefix string, width, first, step int, ok bool) {
+	nums := make([]int, len(seed))
+	for i, c := range seed {
+		m := seriesTextRe.FindStringSubmatch(c.Raw)
+		if m == nil || c.Kind() == KindFormula || seriesNumberRe.MatchString(c.Raw) || (i > 0 && m[1] != prefix) {
+			return "", 0, 0, 0, false
+		}
+		if i == 0 {
+			prefix, width = m[1], len(m[2])
+		}
+		nums[i], _ = strconv.Atoi(m[2])
+	}
+	step = 1
+	if n := len(nums); n > 1 {
+		diff := nums[n-1] - nums[0]
+		if diff%(n-1) != 0 {
+			return "", 0, 0, 0, false
+		}
+		step = diff / (n - 1)
+	}
+	return prefix, width, nums[0], step, true
+}
@@ -0,0 +1,313 @@
+package sheet
+
+import (
+	"reflect"
+	"testing"
+)
+
+// grid returns the raw values of the first rows x cols cells of sheet s1.
+func grid(w *Workbook, rows, cols int) [][]string {
+	s := w.SheetByID("s1")
+	out := make([][]string, rows)
+	for r := range out {
+		out[r] = make([]string, cols)
+		for c := range out[r] {
+			out[r][c] = s.GetCell(CellRef{r, c}).Raw
+		}
+	}
+	return out
+}
+
+func fillGrid(t *testing.T, w *Workbook, rows [][]string) {
+	t.Helper()
+	for r, cells := range rows {
+		for c, raw := range cells {
+			if raw == "" {
+				continue
+			}
+			if err := w.Apply(Op{Type: OpSetCell, Sheet: "s1", Row: r, Col: c, Raw: ptr(raw)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestRangeOpsValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		op   Op
+		ok   bool
+	}{
+		{"sort", Op{Type: OpSortRange, Sheet: "s1", EndRow: 3, EndCol: 1, SortKeys: []SortKey{{Col: 1}}}, true},
+		{"sort without keys", Op{Type: OpSortRange, Sheet: "s1", EndRow: 3, EndCol: 1}, false},
+		{"sort key outside", Op{Type: OpSortRange, Sheet: "s1", EndRow: 3, EndCol: 1, SortKeys: []SortKey{{Col: 2}}}, false},
+		{"move", Op{Type: OpMoveRange, Sheet: "s1", EndRow: 1, EndCol: 1, DestRow: 5}, true},
+		{"copy negative dest", Op{Type: OpCopyRange, Sheet: "s1", EndRow: 1, EndCol: 1, DestCol: -1}, false},
+		{"copy bad bounds", Op{Type: OpCopyRange, Sheet: "s1", Row: 2, EndRow: 1}, false},
+		{"fill", Op{Type: OpFillSeries, Sheet: "s1", EndRow: 9, EndCol: 2, Axis: "row", Count: 2}, true},
+		{"fill seed too long", Op{Type: OpFillSeries, Sheet: "s1", EndRow: 9, Axis: "col", Count: 2}, false},
+		{"fill without axis", Op{Type: OpFillSeries, Sheet: "s1", EndRow: 9, Count: 1}, false},
+		{"fill too large", Op{Type: OpFillSeries, Sheet: "s1", EndRow: 1 << 20, EndCol: 1, Axis: "row", Count: 1}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.op.Validate(); (err == nil) != tc.ok {
+			t.Fatalf("%s: Validate() = %v", tc.name, err)
+		}
+	}
+}
+
+func TestSortRange(t *testing.T) {
+	w := mkWB(t)
+	fillGrid(t, w, [][]string{
+		{"Name", "Score"},
+		{"bob", "10"},
+		{"", ""},
+		{"Alice", "9"},
+		{"carol", "x"},
+		{"dave", ""},
+		{"alice", "TRUE"},
+		{"erin", "=B2*2"},
+	})
+	// Rows 1..7 by score descending, then name.
+	op := Op{Type: OpSortRange, Sheet: "s1", Row: 1, EndRow: 7, EndCol: 1,
+		SortKeys: []SortKey{{Col: 1, Desc: true}, {Col: 0}}}
+	if err := w.Apply(op); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Name", "Score"},
+		{"alice", "TRUE"},
+		{"carol", "x"},
+		{"bob", "10"},
+		{"Alice", "9"},
+		{"dave", ""},
+		{"erin", "=B1*2"},
+		{"", ""},
+	}
+	// erin's formula has no cached value, so its score sorts as a blank;
+	// the row without any key goes last.
+	if got := grid(w, 8, 2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sorted = %v", got)
+	}
+	if err := w.Apply(Op{Type: OpSetCell, Sheet: "s1", Row: 6, Col: 1, Raw: ptr("=B1*2"), Value: ptr("20")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Apply(Op{Type: OpSortRange, Sheet: "s1", Row: 1, EndRow: 7, EndCol: 1, SortKeys: []SortKey{{Col: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{
+		{"Name", "Score"},
+		{"Alice", "9"},
+		{"bob", "10"},
+		{"erin", "=#REF!*2"}, // B1 moved up three rows
+		{"carol", "x"},
+		{"alice", "TRUE"},
+		{"dave", ""},
+		{"", ""},
+	}
+	if got := grid(w, 8, 2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sorted ascending = %v", got)
+	}
+}
+
+func TestMoveRange(t *testing.T) {
+	w := mkWB(t)
+	w.AddSheet("s2", "Other")
+	fillGrid(t, w, [][]string{
+		{"1", "=A1+1", "=$A$1"},
+		{"=SUM(A1:B1)", "=A:A", "gone"},
+	})
+	if err := w.Apply(Op{Type: OpSetCell, Sheet: "s2", Row: 0, Col: 0, Raw: ptr("=Sheet1!A1+Sheet1!C1")}); err != nil {
+		t.Fatal(err)
+	}
+	// Move A1:B1 onto B2:C2.
+	if err := w.Apply(Op{Type: OpMoveRange, Sheet: "s1", EndCol: 1, DestRow: 1, DestCol: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"", "", "=$B$2"},
+		{"=SUM(B2:C2)", "1", "=B2+1"},
+	}
+	if got := grid(w, 2, 3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("moved = %v", got)
+	}
+	if got := w.SheetByID("s2").GetCell(CellRef{0, 0}).Raw; got != "=Sheet1!B2+Sheet1!C1" {
+		t.Fatalf("other sheet = %q", got)
+	}
+}
+
+func TestCopyRange(t *testing.T) {
+	w := mkWB(t)
+	fillGrid(t, w, [][]string{
+		{"1", "=A1*$A$1"},
+		{"2", "=A2*$A$1"},
+		{"", "", "old"},
+	})
+	if err := w.Apply(Op{Type: OpCopyRange, Sheet: "s1", EndRow: 1, EndCol: 1, DestRow: 1, DestCol: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"1", "=A1*$A$1", ""},
+		{"2", "1", "=B2*$A$1"},
+		{"", "2", "=B3*$A$1"},
+	}
+	if got := grid(w, 3, 3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("copied = %v", got)
+	}
+	// References pushed off the grid break.
+	if err := w.Apply(Op{Type: OpCopyRange, Sheet: "s1", Row: 1, Col: 2, EndRow: 1, EndCol: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.SheetByID("s1").GetCell(CellRef{0, 0}).Raw; got != "=#REF!*$A$1" {
+		t.Fatalf("A1 = %q", got)
+	}
+}
+
+func TestFillSeries(t *testing.T) {
+	w := mkWB(t)
+	bold := map[string]string{"bold": "1"}
+	fillGrid(t, w, [][]string{
+		{"1", "Item 09", "0.1", "x", "=A1*2", "5", "1.5"},
+		{"3", "", "0.25", "y", "", "", ""},
+	})
+	if err := w.Apply(Op{Type: OpSetStyle, Sheet: "s1", Row: 1, Col: 3, Props: bold}); err != nil {
+		t.Fatal(err)
+	}
+	fill := func(col, seed int) {
+		t.Helper()
+		if err := w.Apply(Op{Type: OpFillSeries, Sheet: "s1", Col: col, EndRow: 4, EndCol: col, Axis: "row", Count: seed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fill(0, 2)
+	fill(1, 1)
+	fill(2, 2)
+	fill(3, 2)
+	fill(4, 1)
+	fill(5, 1)
+	fill(6, 1)
+	want := [][]string{
+		{"1", "Item 09", "0.1", "x", "=A1*2", "5", "1.5"},
+		{"3", "Item 10", "0.25", "y", "=A2*2", "5", "1.5"},
+		{"5", "Item 11", "0.4", "x", "=A3*2", "5", "1.5"},
+		{"7", "Item 12", "0.55", "y", "=A4*2", "5", "1.5"},
+		{"9", "Item 13", "0.7", "x", "=A5*2", "5", "1.5"},
+	}
+	if got := grid(w, 5, 7); !reflect.DeepEqual(got, want) {
+		t.Fatalf("filled = %v", got)
+	}
+	s := w.SheetByID("s1")
+	if s.GetCell(CellRef{3, 3}).StyleId == 0 || s.GetCell(CellRef{2, 3}).StyleId != 0 {
+		t.Fatal("styles must repeat with the seed")
+	}
+
+	// Right, counting down.
+	fillGrid(t, w, [][]string{{}, {}, {}, {}, {}, {"Q3", "Q2"}})
+	if err := w.Apply(Op{Type: OpFillSeries, Sheet: "s1", Row: 5, EndRow: 5, EndCol: 4, Axis: "col", Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := grid(w, 6, 5)[5]; !reflect.DeepEqual(got, []string{"Q3", "Q2", "Q1", "Q0", "Q1"}) {
+		t.Fatalf("row = %v", got)
+	}
+}
+
+func TestOffsetAndMoveRefs(t *testing.T) {
+	if got := offsetRefs("=A1+$B$2+C$3+$D4+SUM(A:B)+Other!E5", 2, 1); got != "=B3+$B$2+D$3+$D6+SUM(B:C)+Other!F7" {
+		t.Fatalf("offsetRefs = %q", got)
+	}
+	if got := offsetRefs("=A2+SUM(1:2)", -2, 0); got != "=#REF!+SUM(#REF!)" {
+		t.Fatalf("offsetRefs off grid = %q", got)
+	}
+	own := func(sheet string) bool { return sheet == "" }
+	if got := moveRefs("=A1+$B$2+SUM(A1:B2)+SUM(A1:C3)+A:A+C1", own, 0, 0, 1, 1, 5, 0); got != "=A6+$B$7+SUM(A6:B7)+SUM(A1:C3)+A:A+C1" {
+		t.Fatalf("moveRefs = %q", got)
+	}
+}
+
+func TestTransformRangeOps(t *testing.T) {
+	insert := Op{Type: OpInsertRows, Sheet: "s1", Index: 2, Count: 2}
+	deleteCols := Op{Type: OpDeleteCols, Sheet: "s1", Index: 1, Count: 1}
+
+	sort := Op{Type: OpSortRange, Sheet: "s1", Row: 1, EndRow: 4, Col: 0, EndCol: 3, SortKeys: []SortKey{{Col: 2, Desc: true}}}
+	if out := Transform(sort, insert); out.Row != 1 || out.EndRow != 6 {
+		t.Fatalf("sort under insert = %+v", out)
+	}
+	out := Transform(sort, deleteCols)
+	if out.EndCol != 2 || out.SortKeys[0] != (SortKey{Col: 1, Desc: true}) || sort.SortKeys[0].Col != 2 {
+		t.Fatalf("sort under delete = %+v", out)
+	}
+
+	move := Op{Type: OpMoveRange, Sheet: "s1", Row: 0, EndRow: 1, Col: 2, EndCol: 3, DestRow: 5, DestCol: 4}
+	if out := Transform(move, insert); out.EndRow != 1 || out.DestRow != 7 {
+		t.Fatalf("move under insert = %+v", out)
+	}
+	if out := Transform(move, deleteCols); out.Col != 1 || out.EndCol != 2 || out.DestCol != 3 {
+		t.Fatalf("move under delete = %+v", out)
+	}
+
+	fill := Op{Type: OpFillSeries, Sheet: "s1", Row: 1, EndRow: 6, EndCol: 0, Axis: "row", Count: 2}
+	if out := Transform(fill, insert); out.Count != 4 || out.EndRow != 8 {
+		t.Fatalf("fill under insert inside the seed = %+v", out)
+	}
+	if out := Transform(fill, Op{Type: OpDeleteRows, Sheet: "s1", Index: 0, Count: 3}); out.Row != 0 || out.Count != 1 || out.EndRow != 3 {
+		t.Fatalf("fill under delete of the seed = %+v", out)
+	}
+	if out := Transform(fill, Op{Type: OpInsertRows, Sheet: "s1", Index: 3, Count: 1}); out.Count != 2 {
+		t.Fatalf("fill under insert after the seed = %+v", out)
+	}
+
+	// A formula written concurrently follows a move.
+	set := Op{Type: OpSetCell, Sheet: "s1", Row: 9, Raw: ptr("=C1+Sheet1!D2+E1")}
+	move.SheetName = "Sheet1"
+	if out := Transform(set, move); *out.Raw != "=E6+Sheet1!F7+E1" {
+		t.Fatalf("formula past move = %q", *out.Raw)
+	}
+}
+
+// TestConvergenceRangeOps mixes range ops into randomized concurrent edits
+// and checks the op-log replays to the server state.
+func TestConvergenceRangeOps(t *testing.T) {
+	for trial := 0; trial < 100; trial++ {
+		r := &lcg{state: uint64(trial)*40503 + 7}
+		d := NewDocument(mkWB(t))
+		seen := 0
+		for step := 0; step < 40; step++ {
+			base := seen + r.intn(d.Head()-seen+1)
+			row, col := r.intn(6), r.intn(6)
+			op := randomOp(r, base)
+			switch r.intn(6) {
+			case 0:
+				op = Op{Type: OpSortRange, Sheet: "s1", Row: row, Col: col, EndRow: row + r.intn(4), EndCol: col + 2,
+					SortKeys: []SortKey{{Col: col + r.intn(3), Desc: r.intn(2) == 0}}, BaseRev: base}
+			case 1:
+				op = Op{Type: OpMoveRange, Sheet: "s1", Row: row, Col: col, EndRow: row + 1, EndCol: col + 1,
+					DestRow: r.intn(8), DestCol: r.intn(8), BaseRev: base}
+			case 2:
+				op = Op{Type: OpCopyRange, Sheet: "s1", Row: row, Col: col, EndRow: row + 1, EndCol: col + 1,
+					DestRow: r.intn(8), DestCol: r.intn(8), BaseRev: base}
+			case 3:
+				op = Op{Type: OpFillSeries, Sheet: "s1", Row: row, Col: col, EndRow: row + 4, EndCol: col,
+					Axis: "row", Count: 1 + r.intn(2), BaseRev: base}
+			}
+			if op.Type == OpSetCell {
+				raw := []string{"1", "2", "b", "=A1+B2", "a3"}[r.intn(5)]
+				op.Raw = &raw
+			}
+			if _, err := d.Submit(op); err != nil {
+				t.Fatalf("trial %d step %d submit %+v: %v", trial, step, op, err)
+			}
+			if r.intn(3) == 0 {
+				seen = d.Head()
+			}
+		}
+		replay := mkWB(t)
+		for i, op := range d.Log() {
+			if err := replay.Apply(op); err != nil {
+				t.Fatalf("trial %d replay op %d: %v", trial, i, err)
+			}
+		}
+		if !workbooksEqual(replay, d.Workbook()) {
+			t.Fatalf("trial %d: replay diverged from server state", trial)
+		}
+	}
+}
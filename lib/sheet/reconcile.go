@@ -34,21 +34,33 @@ func (d *Document) Log() []Op           { return d.log }
 // then, applies it, appends the rebased op to the log, and returns the new
 // head revision. The rebased op (not the original) is logged so replay is exact.
 func (d *Document) Submit(op Op) (int, error) {
-	if err := op.Validate(); err != nil {
+	rebased, err := d.Rebase(op)
+	if err != nil {
 		return 0, err
 	}
+	if err := d.wb.Apply(rebased); err != nil {
+		return 0, err
+	}
+	d.log = append(d.log, rebased)
+	d.head++
+	return d.head, nil
+}
+
+// Rebase validates op and transforms it past every op applied since its
+// BaseRev, without applying it: the result is what Submit would apply, so
+// callers can check it against the current workbook first.
+func (d *Document) Rebase(op Op) (Op, error) {
+	if err := op.Validate(); err != nil {
+		return Op{}, err
+	}
 	if op.BaseRev < 0 || op.BaseRev > d.head {
-		return 0, fmt.Errorf("submit: baseRev %d out of range (head %d)", op.BaseRev, d.head)
+		return Op{}, fmt.Errorf("submit: baseRev %d out of range (head %d)", op.BaseRev, d.head)
 	}
 	rebased := op
 	for i := op.BaseRev; i < d.head; i++ {
 		rebased = Transform(rebased, d.log[i])
 	}
 	rebased.BaseRev = d.head
-	if err := d.wb.Apply(rebased); err != nil {
-		return 0, err
-	}
-	d.log = append(d.log, rebased)
-	d.head++
-	return d.head, nil
+	rebased.SheetName = d.wb.sheetNameBefore(rebased)
+	return rebased, nil
 }
@@ -0,0 +1,434 @@
+package sheet
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Data validation types (the xlsx names).
+const (
+	ValidationList       = "list"
+	ValidationDecimal    = "decimal"
+	ValidationWhole      = "whole"
+	ValidationTextLength = "textLength"
+)
+
+// Conditional format types: cellIs compares the cell value, text looks for a
+// substring.
+const (
+	CondFormatCellIs = "cellIs"
+	CondFormatText   = "text"
+)
+
+// Limits for rules. An inline xlsx list is at most 255 characters.
+const (
+	MaxRules       = 500 // per kind and workbook
+	MaxRuleAuthors = 100
+	maxListLength  = 255
+	maxRuleValue   = 255
+)
+
+var (
+	// ErrProtected rejects an op that changes a protected range its author
+	// may not edit.
+	ErrProtected = errors.New("sheet range is protected")
+	// ErrInvalidValue rejects a setCell whose value fails a data validation.
+	ErrInvalidValue = errors.New("sheet value fails data validation")
+)
+
+// Comparison operators (the xlsx names) of validations and cellIs formats.
+var compareOperators = map[string]bool{
+	"between": true, "notBetween": true, "equal": true, "notEqual": true,
+	"greaterThan": true, "lessThan": true, "greaterThanOrEqual": true, "lessThanOrEqual": true,
+}
+
+var textOperators = map[string]bool{
+	"containsText": true, "notContains": true, "beginsWith": true, "endsWith": true,
+}
+
+// Conditional formats only change how a cell looks, not its layout.
+var condFormatProps = map[string]bool{
+	"bold": true, "italic": true, "underline": true, "strike": true, "color": true, "bg": true,
+}
+
+// Rule is a workbook-level rule over a rectangle of one sheet: a data
+// validation, a conditional format or a protected range, depending on the
+// Workbook list it is in. Fields a kind does not use stay empty.
+type Rule struct {
+	Id     string `json:"id"`
+	Sheet  string `json:"sheet,omitempty"`
+	Row    int    `json:"row,omitempty"`
+	Col    int    `json:"col,omitempty"`
+	EndRow int    `json:"endRow,omitempty"`
+	EndCol int    `json:"endCol,omitempty"`
+
+	// Validations and conditional formats: the condition. Comparisons use
+	// Operator against Value1 (and Value2 for between/notBetween); text
+	// formats use containsText, notContains, beginsWith or endsWith.
+	Type     string   `json:"type,omitempty"`
+	Operator string   `json:"operator,omitempty"`
+	Value1   string   `json:"value1,omitempty"`
+	Value2   string   `json:"value2,omitempty"`
+	List     []string `json:"list,omitempty"` // validation type "list"
+
+	// Conditional formats: style props shown while the condition holds.
+	Props map[string]string `json:"props,omitempty"`
+
+	// Protected ranges: the authors who may edit the cells and the rule.
+	// Without any the cells are locked for everyone, but anyone may change or
+	// remove the rule (like an Excel sheet protection without a password).
+	Authors []string `json:"authors,omitempty"`
+}
+
+func validRuleId(id string) bool {
+	return id != "" && len(id) <= 64
+}
+
+// validate checks the rule payload of a set op of type t.
+func (r *Rule) validate(t OpType) error {
+	if !validRuleId(r.Id) {
+		return fmt.Errorf("%s needs a rule id of at most 64 bytes", t)
+	}
+	switch t {
+	case OpSetValidation:
+		switch r.Type {
+		case ValidationList:
+			if len(r.List) == 0 {
+				return fmt.Errorf("list validation needs values")
+			}
+			n := len(r.List) - 1
+			for _, v := range r.List {
+				if v == "" || strings.ContainsAny(v, `,"`) {
+					return fmt.Errorf("list validation values must be non-empty, without commas or quotes")
+				}
+				n += len(v)
+			}
+			if n > maxListLength {
+				return fmt.Errorf("list validation values too long")
+			}
+			return nil
+		case ValidationDecimal, ValidationWhole, ValidationTextLength:
+			return r.validateCompare(true)
+		}
+		return fmt.Errorf("unknown validation type %q", r.Type)
+	case OpSetCondFormat:
+		if len(r.Props) == 0 {
+			return fmt.Errorf("conditional format needs props")
+		}
+		for k := range r.Props {
+			if !condFormatProps[k] {
+				return fmt.Errorf("conditional format can not set %q", k)
+			}
+		}
+		if err := ValidateProps(r.Props); err != nil {
+			return err
+		}
+		switch r.Type {
+		case CondFormatCellIs:
+			return r.validateCompare(false)
+		case CondFormatText:
+			if !textOperators[r.Operator] {
+				return fmt.Errorf("unknown text operator %q", r.Operator)
+			}
+			if r.Value1 == "" || len(r.Value1) > maxRuleValue {
+				return fmt.Errorf("text format needs a value of at most %d bytes", maxRuleValue)
+			}
+			return nil
+		}
+		return fmt.Errorf("unknown conditional format type %q", r.Type)
+	case OpSetProtection:
+		if len(r.Authors) > MaxRuleAuthors {
+			return fmt.Errorf("protection allows at most %d authors", MaxRuleAuthors)
+		}
+		if slices.Contains(r.Authors, "") {
+			return fmt.Errorf("protection author must not be empty")
+		}
+	}
+	return nil
+}
+
+// validateCompare checks a comparison condition; numeric ones (validations)
+// compare against numbers only.
+func (r *Rule) validateCompare(numeric bool) error {
+	if !compareOperators[r.Operator] {
+		return fmt.Errorf("unknown operator %q", r.Operator)
+	}
+	values := []string{r.Value1}
+	if r.Operator == "between" || r.Operator == "notBetween" {
+		values = append(values, r.Value2)
+	}
+	for _, v := range values {
+		if v == "" || len(v) > maxRuleValue {
+			return fmt.Errorf("%s needs values of at most %d bytes", r.Operator, maxRuleValue)
+		}
+		if numeric && !sortNumberRe.MatchString(v) {
+			return fmt.Errorf("%s value %q is not a number", r.Type, v)
+		}
+	}
+	return nil
+}
+
+// compareRuleValues orders two values: numerically when both are numbers,
+// else as text, case-insensitively for ASCII letters.
+func compareRuleValues(a, b string) int {
+	if sortNumberRe.MatchString(a) && sortNumberRe.MatchString(b) {
+		fa, _ := strconv.ParseFloat(a, 64)
+		fb, _ := strconv.ParseFloat(b, 64)
+		return cmp.Compare(fa, fb)
+	}
+	return strings.Compare(lowerASCIIString(a), lowerASCIIString(b))
+}
+
+// compareHolds reports whether v satisfies the rule's comparison.
+func (r Rule) compareHolds(v string) bool {
+	c := compareRuleValues(v, r.Value1)
+	switch r.Operator {
+	case "between":
+		return c >= 0 && compareRuleValues(v, r.Value2) <= 0
+	case "notBetween":
+		return c < 0 || compareRuleValues(v, r.Value2) > 0
+	case "equal":
+		return c == 0
+	case "notEqual":
+		return c != 0
+	case "greaterThan":
+		return c > 0
+	case "lessThan":
+		return c < 0
+	case "greaterThanOrEqual":
+		return c >= 0
+	case "lessThanOrEqual":
+		return c <= 0
+	}
+	return false
+}
+
+// Accepts reports whether raw passes the data validation. Blanks always do,
+// and so do formulas: their value is only computed by the clients.
+func (r Rule) Accepts(raw string) bool {
+	if raw == "" || strings.HasPrefix(raw, "=") {
+		return true
+	}
+	switch r.Type {
+	case ValidationList:
+		return slices.Contains(r.List, raw)
+	case ValidationDecimal, ValidationWhole:
+		if !sortNumberRe.MatchString(raw) {
+			return false
+		}
+		if r.Type == ValidationWhole {
+			if f, _ := strconv.ParseFloat(raw, 64); f != math.Trunc(f) {
+				return false
+			}
+		}
+		return r.compareHolds(raw)
+	case ValidationTextLength:
+		return r.compareHolds(strconv.Itoa(utf8.RuneCountInString(raw)))
+	}
+	return true
+}
+
+func (r Rule) contains(row, col int) bool {
+	return inRange(CellRef{row, col}, r.Row, r.Col, r.EndRow, r.EndCol)
+}
+
+func (r Rule) overlaps(r0, c0, r1, c1 int) bool {
+	return r.Row <= r1 && r.EndRow >= r0 && r.Col <= c1 && r.EndCol >= c0
+}
+
+// allows reports whether author may edit the cells of a protected range.
+func (r Rule) allows(author string) bool {
+	return slices.Contains(r.Authors, author)
+}
+
+func (r Rule) clone() Rule {
+	r.List = slices.Clone(r.List)
+	r.Props = maps.Clone(r.Props)
+	r.Authors = slices.Clone(r.Authors)
+	return r
+}
+
+func (r Rule) equal(o Rule) bool {
+	return r.Id == o.Id && r.Sheet == o.Sheet && r.Row == o.Row && r.Col == o.Col &&
+		r.EndRow == o.EndRow && r.EndCol == o.EndCol && r.Type == o.Type &&
+		r.Operator == o.Operator && r.Value1 == o.Value1 && r.Value2 == o.Value2 &&
+		slices.Equal(r.List, o.List) && maps.Equal(r.Props, o.Props) && slices.Equal(r.Authors, o.Authors)
+}
+
+// setOp is the op that recreates the rule, given its kind's set op type.
+func (r Rule) setOp(t OpType) Op {
+	payload := r.clone()
+	payload.Sheet, payload.Row, payload.Col, payload.EndRow, payload.EndCol = "", 0, 0, 0, 0
+	return Op{Type: t, Sheet: r.Sheet, Row: r.Row, Col: r.Col, EndRow: r.EndRow, EndCol: r.EndCol, Rule: &payload}
+}
+
+func cloneRules(rules []Rule) []Rule {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]Rule, len(rules))
+	for i, r := range rules {
+		out[i] = r.clone()
+	}
+	return out
+}
+
+// ruleList is one of the workbook's rule lists with the set op of its kind.
+type ruleList struct {
+	t     OpType
+	rules *[]Rule
+}
+
+func (w *Workbook) ruleLists() []ruleList {
+	return []ruleList{
+		{OpSetValidation, &w.Validations},
+		{OpSetCondFormat, &w.CondFormats},
+		{OpSetProtection, &w.Protections},
+	}
+}
+
+// RuleById returns the rule with id, of any kind.
+func (w *Workbook) RuleById(id string) (Rule, bool) {
+	for _, k := range w.ruleLists() {
+		if i := slices.IndexFunc(*k.rules, func(r Rule) bool { return r.Id == id }); i >= 0 {
+			return (*k.rules)[i], true
+		}
+	}
+	return Rule{}, false
+}
+
+// setRule adds the rule of a set op to its kind's list, or replaces the rule
+// with the same id there. Ids are unique across kinds.
+func (w *Workbook) setRule(op Op) error {
+	r := op.Rule.clone()
+	r.Sheet, r.Row, r.Col, r.EndRow, r.EndCol = op.Sheet, op.Row, op.Col, op.EndRow, op.EndCol
+	var own *[]Rule
+	for _, k := range w.ruleLists() {
+		i := slices.IndexFunc(*k.rules, func(o Rule) bool { return o.Id == r.Id })
+		switch {
+		case k.t == op.Type && i >= 0:
+			(*k.rules)[i] = r
+			return nil
+		case k.t == op.Type:
+			own = k.rules
+		case i >= 0:
+			return fmt.Errorf("%s: rule id %q is in use", op.Type, r.Id)
+		}
+	}
+	if len(*own) >= MaxRules {
+		return fmt.Errorf("%s: at most %d rules", op.Type, MaxRules)
+	}
+	*own = append(*own, r)
+	return nil
+}
+
+// deleteRule removes the rule with id, of any kind.
+func (w *Workbook) deleteRule(id string) {
+	for _, k := range w.ruleLists() {
+		*k.rules = slices.DeleteFunc(*k.rules, func(r Rule) bool { return r.Id == id })
+	}
+}
+
+// dropRules removes the rules of a deleted sheet.
+func (w *Workbook) dropRules(sheet string) {
+	for _, k := range w.ruleLists() {
+		*k.rules = slices.DeleteFunc(*k.rules, func(r Rule) bool { return r.Sheet == sheet })
+	}
+}
+
+// shiftRules moves the ranges of the rules on sheet after a row (axis "row")
+// or col insert (delta > 0) / delete of -delta indices at index, the way
+// shiftMerges moves merges. Rules whose range is deleted entirely are dropped.
+func (w *Workbook) shiftRules(sheet, axis string, index, delta int) {
+	for _, k := range w.ruleLists() {
+		next := make([]Rule, 0, len(*k.rules))
+		for _, r := range *k.rules {
+			if r.Sheet == sheet {
+				lo, hi := &r.Row, &r.EndRow
+				if axis == "col" {
+					lo, hi = &r.Col, &r.EndCol
+				}
+				nlo := shiftCoord(*lo, index, delta)
+				nhi := shiftEnd(*hi+1, index, delta) - 1
+				if nhi < nlo {
+					continue
+				}
+				*lo, *hi = nlo, nhi
+			}
+			next = append(next, r)
+		}
+		*k.rules = next
+	}
+}
+
+// Check enforces the protected ranges and data validations of the workbook
+// on op, which must already be rebased to the current revision, as submitted
+// by author. Violations wrap ErrProtected or ErrInvalidValue.
+func (w *Workbook) Check(op Op, author string) error {
+	if op.Type == OpSetProtection && len(op.Rule.Authors) > 0 && !slices.Contains(op.Rule.Authors, author) {
+		return fmt.Errorf("%w: a protected range must allow the author who sets it", ErrProtected)
+	}
+	for _, p := range w.Protections {
+		if w.blockedBy(p, op, author) {
+			return fmt.Errorf("%w: %s", ErrProtected, RangeName(p.Row, p.Col, p.EndRow, p.EndCol))
+		}
+	}
+	if op.Type == OpSetCell && op.Raw != nil {
+		for _, v := range w.Validations {
+			if v.Sheet == op.Sheet && v.contains(op.Row, op.Col) && !v.Accepts(*op.Raw) {
+				return fmt.Errorf("%w: %s", ErrInvalidValue, RangeName(op.Row, op.Col, op.Row, op.Col))
+			}
+		}
+	}
+	return nil
+}
+
+// blockedBy reports whether the protected range p forbids op to author.
+func (w *Workbook) blockedBy(p Rule, op Op, author string) bool {
+	if (op.Type == OpSetProtection || op.Type == OpDeleteRule) && op.Rule.Id == p.Id {
+		return len(p.Authors) > 0 && !p.allows(author)
+	}
+	if p.allows(author) {
+		return false
+	}
+	if op.Type == OpDeleteSheet {
+		return op.Sheet == p.Sheet
+	}
+	if op.Type == OpDeleteRule {
+		r, ok := w.RuleById(op.Rule.Id)
+		return ok && r.Sheet == p.Sheet && p.overlaps(r.Row, r.Col, r.EndRow, r.EndCol)
+	}
+	if op.Sheet != p.Sheet {
+		return false
+	}
+	switch op.Type {
+	case OpSetCell, OpSetStyle:
+		return p.contains(op.Row, op.Col)
+	case OpClearRange, OpSortRange, OpFillSeries, OpMergeCells, OpUnmergeCells, OpSetValidation, OpSetCondFormat:
+		return p.overlaps(op.Row, op.Col, op.EndRow, op.EndCol)
+	case OpMoveRange, OpCopyRange:
+		dest := p.overlaps(op.DestRow, op.DestCol, op.DestRow+op.EndRow-op.Row, op.DestCol+op.EndCol-op.Col)
+		return dest || (op.Type == OpMoveRange && p.overlaps(op.Row, op.Col, op.EndRow, op.EndCol))
+	case OpDeleteRows:
+		return p.Row < op.Index+op.Count && p.EndRow >= op.Index
+	case OpDeleteCols:
+		return p.Col < op.Index+op.Count && p.EndCol >= op.Index
+	}
+	return false
+}
+
+// RangeName is the A1 name of a rectangle ("B2", or "A1:C3").
+func RangeName(r0, c0, r1, c1 int) string {
+	name := colName(c0) + strconv.Itoa(r0+1)
+	if r1 != r0 || c1 != c0 {
+		name += ":" + colName(c1) + strconv.Itoa(r1+1)
+	}
+	return name
+}
@@ -0,0 +1,285 @@
+package sheet
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func ruleOp(t OpType, r0, c0, r1, c1 int, r Rule) Op {
+	return Op{Type: t, Sheet: "s1", Row: r0, Col: c0, EndRow: r1, EndCol: c1, Rule: &r}
+}
+
+func listRule(id string, values ...string) Rule {
+	return Rule{Id: id, Type: ValidationList, List: values}
+}
+
+func protectRule(id string, authors ...string) Rule {
+	return Rule{Id: id, Authors: authors}
+}
+
+func TestRuleOpsValidate(t *testing.T) {
+	bold := map[string]string{"bold": "1"}
+	cases := []struct {
+		name string
+		op   Op
+		ok   bool
+	}{
+		{"list", ruleOp(OpSetValidation, 0, 0, 9, 0, listRule("v", "a", "b")), true},
+		{"list without values", ruleOp(OpSetValidation, 0, 0, 9, 0, listRule("v")), false},
+		{"list value with comma", ruleOp(OpSetValidation, 0, 0, 9, 0, listRule("v", "a,b")), false},
+		{"no rule", Op{Type: OpSetValidation, Sheet: "s1"}, false},
+		{"no id", ruleOp(OpSetValidation, 0, 0, 0, 0, listRule("", "a")), false},
+		{"whole between", ruleOp(OpSetValidation, 0, 0, 0, 0, Rule{Id: "v", Type: ValidationWhole, Operator: "between", Value1: "1", Value2: "9"}), true},
+		{"whole between one value", ruleOp(OpSetValidation, 0, 0, 0, 0, Rule{Id: "v", Type: ValidationWhole, Operator: "between", Value1: "1"}), false},
+		{"decimal not a number", ruleOp(OpSetValidation, 0, 0, 0, 0, Rule{Id: "v", Type: ValidationDecimal, Operator: "lessThan", Value1: "x"}), false},
+		{"unknown validation", ruleOp(OpSetValidation, 0, 0, 0, 0, Rule{Id: "v", Type: "date"}), false},
+		{"cellIs text value", ruleOp(OpSetCondFormat, 0, 0, 0, 0, Rule{Id: "f", Type: CondFormatCellIs, Operator: "equal", Value1: "done", Props: bold}), true},
+		{"format without props", ruleOp(OpSetCondFormat, 0, 0, 0, 0, Rule{Id: "f", Type: CondFormatCellIs, Operator: "equal", Value1: "done"}), false},
+		{"format layout prop", ruleOp(OpSetCondFormat, 0, 0, 0, 0, Rule{Id: "f", Type: CondFormatText, Operator: "containsText", Value1: "x", Props: map[string]string{"fontSize": "20"}}), false},
+		{"text operator", ruleOp(OpSetCondFormat, 0, 0, 0, 0, Rule{Id: "f", Type: CondFormatText, Operator: "greaterThan", Value1: "x", Props: bold}), false},
+		{"protection", ruleOp(OpSetProtection, 0, 0, 5, 5, protectRule("p", "a.1")), true},
+		{"protection empty author", ruleOp(OpSetProtection, 0, 0, 5, 5, protectRule("p", "")), false},
+		{"protection bad range", ruleOp(OpSetProtection, 5, 0, 0, 5, protectRule("p")), false},
+		{"delete", Op{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "p"}}, true},
+		{"delete without id", Op{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{}}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.op.Validate(); (err == nil) != tc.ok {
+			t.Fatalf("%s: Validate() = %v", tc.name, err)
+		}
+	}
+}
+
+func TestRuleAccepts(t *testing.T) {
+	cases := []struct {
+		rule Rule
+		raw  string
+		ok   bool
+	}{
+		{listRule("v", "yes", "no"), "no", true},
+		{listRule("v", "yes", "no"), "No", false},
+		{listRule("v", "yes", "no"), "", true},
+		{listRule("v", "yes", "no"), "=A1", true},
+		{Rule{Type: ValidationWhole, Operator: "between", Value1: "1", Value2: "10"}, "10", true},
+		{Rule{Type: ValidationWhole, Operator: "between", Value1: "1", Value2: "10"}, "2.5", false},
+		{Rule{Type: ValidationWhole, Operator: "between", Value1: "1", Value2: "10"}, "11", false},
+		{Rule{Type: ValidationDecimal, Operator: "greaterThan", Value1: "0"}, "0.5", true},
+		{Rule{Type: ValidationDecimal, Operator: "greaterThan", Value1: "0"}, "abc", false},
+		{Rule{Type: ValidationDecimal, Operator: "notBetween", Value1: "1", Value2: "2"}, "1.5", false},
+		{Rule{Type: ValidationTextLength, Operator: "lessThanOrEqual", Value1: "3"}, "äöü", true},
+		{Rule{Type: ValidationTextLength, Operator: "lessThanOrEqual", Value1: "3"}, "four", false},
+	}
+	for _, tc := range cases {
+		if got := tc.rule.Accepts(tc.raw); got != tc.ok {
+			t.Fatalf("%+v Accepts(%q) = %v", tc.rule, tc.raw, got)
+		}
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	w := mkWB(t)
+	w.AddSheet("s2", "Second")
+	for _, op := range []Op{
+		ruleOp(OpSetValidation, 1, 1, 4, 1, listRule("v", "a")),
+		ruleOp(OpSetProtection, 0, 0, 0, 3, protectRule("p")),
+		ruleOp(OpSetValidation, 2, 2, 2, 2, listRule("v", "b")),
+		{Type: OpSetCondFormat, Sheet: "s2", EndRow: 3, Rule: &Rule{Id: "f", Type: CondFormatText, Operator: "containsText", Value1: "x", Props: map[string]string{"bold": "1"}}},
+	} {
+		if err := w.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(w.Validations) != 1 || w.Validations[0].Row != 2 || w.Validations[0].List[0] != "b" || w.Validations[0].Sheet != "s1" {
+		t.Fatalf("validations = %+v", w.Validations)
+	}
+	if err := w.Apply(ruleOp(OpSetCondFormat, 0, 0, 0, 0, Rule{Id: "p", Type: CondFormatText, Operator: "containsText", Value1: "x", Props: map[string]string{"bold": "1"}})); err == nil {
+		t.Fatal("rule id of another kind was reused")
+	}
+	if err := w.Apply(Op{Type: OpSetProtection, Sheet: "nope", Rule: &Rule{Id: "q"}}); err != nil || len(w.Protections) != 1 {
+		t.Fatalf("rule on a missing sheet: %v, %+v", err, w.Protections)
+	}
+	if err := w.Apply(Op{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "v"}}); err != nil || len(w.Validations) != 0 {
+		t.Fatalf("delete: %v, %+v", err, w.Validations)
+	}
+	if err := w.Apply(Op{Type: OpDeleteSheet, Sheet: "s2"}); err != nil || len(w.CondFormats) != 0 {
+		t.Fatalf("delete sheet: %v, %+v", err, w.CondFormats)
+	}
+	if r, ok := w.RuleById("p"); !ok || r.EndCol != 3 {
+		t.Fatalf("RuleById(p) = %+v, %v", r, ok)
+	}
+}
+
+func TestRulesShiftUnderStructuralOps(t *testing.T) {
+	w := mkWB(t)
+	if err := w.Apply(ruleOp(OpSetProtection, 2, 1, 5, 3, protectRule("p"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Apply(ruleOp(OpSetValidation, 8, 0, 8, 0, listRule("v", "a"))); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		op   Op
+		want [4]int
+	}{
+		{Op{Type: OpInsertRows, Sheet: "s1", Index: 3, Count: 2}, [4]int{2, 1, 7, 3}},
+		{Op{Type: OpInsertCols, Sheet: "s1", Index: 0, Count: 1}, [4]int{2, 2, 7, 4}},
+		{Op{Type: OpDeleteRows, Sheet: "s1", Index: 0, Count: 3}, [4]int{0, 2, 4, 4}},
+		{Op{Type: OpDeleteCols, Sheet: "s1", Index: 3, Count: 5}, [4]int{0, 2, 4, 2}},
+	}
+	for _, s := range steps {
+		if err := w.Apply(s.op); err != nil {
+			t.Fatal(err)
+		}
+		p := w.Protections[0]
+		if got := [4]int{p.Row, p.Col, p.EndRow, p.EndCol}; got != s.want {
+			t.Fatalf("after %s: protection = %v, want %v", s.op.Type, got, s.want)
+		}
+	}
+	// The validation at A9 moved to A8, then row 6 of it went away.
+	if v := w.Validations; len(v) != 1 || v[0].Row != 7 || v[0].Col != 1 {
+		t.Fatalf("validations = %+v", v)
+	}
+	if err := w.Apply(Op{Type: OpDeleteRows, Sheet: "s1", Index: 6, Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Validations) != 0 {
+		t.Fatalf("deleted range kept its rule: %+v", w.Validations)
+	}
+}
+
+func TestCheckProtection(t *testing.T) {
+	w := mkWB(t)
+	w.AddSheet("s2", "Second")
+	if err := w.Apply(ruleOp(OpSetProtection, 0, 0, 1, 1, protectRule("p", "owner"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Apply(ruleOp(OpSetValidation, 5, 5, 5, 5, listRule("v", "a"))); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		op     Op
+		author string
+		ok     bool
+	}{
+		{"owner edits", Op{Type: OpSetCell, Sheet: "s1", Row: 1, Col: 1, Raw: ptr("x")}, "owner", true},
+		{"other edits", Op{Type: OpSetCell, Sheet: "s1", Row: 1, Col: 1, Raw: ptr("x")}, "other", false},
+		{"other edits outside", Op{Type: OpSetCell, Sheet: "s1", Row: 2, Col: 1, Raw: ptr("x")}, "other", true},
+		{"other edits other sheet", Op{Type: OpSetCell, Sheet: "s2", Row: 0, Col: 0, Raw: ptr("x")}, "other", true},
+		{"other styles", Op{Type: OpSetStyle, Sheet: "s1", Props: map[string]string{"bold": "1"}}, "other", false},
+		{"other clears overlap", Op{Type: OpClearRange, Sheet: "s1", Row: 1, Col: 1, EndRow: 3, EndCol: 3}, "other", false},
+		{"other moves into", Op{Type: OpMoveRange, Sheet: "s1", Row: 4, Col: 4, EndRow: 4, EndCol: 4, DestRow: 0, DestCol: 0}, "other", false},
+		{"other copies out", Op{Type: OpCopyRange, Sheet: "s1", EndRow: 1, EndCol: 1, DestRow: 4, DestCol: 4}, "other", true},
+		{"other moves out", Op{Type: OpMoveRange, Sheet: "s1", EndRow: 1, EndCol: 1, DestRow: 4, DestCol: 4}, "other", false},
+		{"other inserts rows", Op{Type: OpInsertRows, Sheet: "s1", Index: 1, Count: 1}, "other", true},
+		{"other deletes rows", Op{Type: OpDeleteRows, Sheet: "s1", Index: 1, Count: 1}, "other", false},
+		{"other deletes cols after", Op{Type: OpDeleteCols, Sheet: "s1", Index: 2, Count: 1}, "other", true},
+		{"other deletes sheet", Op{Type: OpDeleteSheet, Sheet: "s1"}, "other", false},
+		{"other lifts protection", Op{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "p"}}, "other", false},
+		{"owner lifts protection", Op{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "p"}}, "owner", true},
+		{"owner locks self out", ruleOp(OpSetProtection, 0, 0, 1, 1, protectRule("p", "other")), "owner", false},
+		{"other validates inside", ruleOp(OpSetValidation, 0, 0, 0, 0, listRule("w", "a")), "other", false},
+		{"invalid value", Op{Type: OpSetCell, Sheet: "s1", Row: 5, Col: 5, Raw: ptr("b")}, "other", false},
+		{"valid value", Op{Type: OpSetCell, Sheet: "s1", Row: 5, Col: 5, Raw: ptr("a")}, "other", true},
+		{"style only", Op{Type: OpSetCell, Sheet: "s1", Row: 5, Col: 5, Props: map[string]string{"bold": "1"}}, "other", true},
+	}
+	for _, tc := range cases {
+		if err := w.Check(tc.op, tc.author); (err == nil) != tc.ok {
+			t.Fatalf("%s: Check() = %v", tc.name, err)
+		}
+	}
+	err := w.Check(Op{Type: OpSetCell, Sheet: "s1", Raw: ptr("x")}, "other")
+	if !errors.Is(err, ErrProtected) || err.Error() != "sheet range is protected: A1:B2" {
+		t.Fatalf("Check error = %v", err)
+	}
+	if err := w.Check(Op{Type: OpSetCell, Sheet: "s1", Row: 5, Col: 5, Raw: ptr("b")}, "x"); !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Check error = %v", err)
+	}
+
+	// Without authors the cells are locked for everyone, the rule is not.
+	open := mkWB(t)
+	if err := open.Apply(ruleOp(OpSetProtection, 0, 0, 0, 0, protectRule("p"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := open.Check(Op{Type: OpSetCell, Sheet: "s1", Raw: ptr("x")}, "any"); !errors.Is(err, ErrProtected) {
+		t.Fatalf("locked cell: %v", err)
+	}
+	if err := open.Check(Op{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "p"}}, "any"); err != nil {
+		t.Fatalf("lift open protection: %v", err)
+	}
+}
+
+func TestTransformRuleOps(t *testing.T) {
+	op := ruleOp(OpSetProtection, 2, 2, 4, 4, protectRule("p"))
+	got := Transform(op, Op{Type: OpInsertRows, Sheet: "s1", Index: 3, Count: 2})
+	if got.Row != 2 || got.EndRow != 6 {
+		t.Fatalf("insert rows: %+v", got)
+	}
+	got = Transform(op, Op{Type: OpDeleteCols, Sheet: "s1", Index: 0, Count: 3})
+	if got.Col != 0 || got.EndCol != 1 {
+		t.Fatalf("delete cols: %+v", got)
+	}
+}
+
+func TestRulesSnapshotRoundTrip(t *testing.T) {
+	w := mkWB(t)
+	for _, op := range []Op{
+		ruleOp(OpSetValidation, 0, 0, 3, 0, listRule("v", "a", "b")),
+		ruleOp(OpSetCondFormat, 0, 1, 3, 1, Rule{Id: "f", Type: CondFormatCellIs, Operator: "greaterThan", Value1: "3", Props: map[string]string{"bg": "#ff0000"}}),
+		ruleOp(OpSetProtection, 0, 2, 0, 2, protectRule("p", "a.1")),
+	} {
+		if err := w.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := json.Marshal(w.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap WorkbookSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	got := WorkbookFromSnapshot(snap)
+	if !reflect.DeepEqual(got.Validations, w.Validations) || !reflect.DeepEqual(got.CondFormats, w.CondFormats) ||
+		!reflect.DeepEqual(got.Protections, w.Protections) {
+		t.Fatalf("round trip = %+v %+v %+v", got.Validations, got.CondFormats, got.Protections)
+	}
+	clone := w.Clone()
+	clone.Validations[0].List[0] = "changed"
+	if w.Validations[0].List[0] != "a" {
+		t.Fatal("Clone shares rule lists")
+	}
+}
+
+func TestRestoreOpsRules(t *testing.T) {
+	target := mkWB(t)
+	for _, op := range []Op{
+		ruleOp(OpSetValidation, 0, 0, 3, 0, listRule("v", "a")),
+		ruleOp(OpSetProtection, 0, 2, 0, 2, protectRule("p")),
+	} {
+		if err := target.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cur := target.Clone()
+	for _, op := range []Op{
+		{Type: OpDeleteRule, Sheet: "s1", Rule: &Rule{Id: "v"}},
+		ruleOp(OpSetProtection, 0, 2, 5, 2, protectRule("p")),
+		ruleOp(OpSetCondFormat, 0, 0, 0, 0, Rule{Id: "f", Type: CondFormatText, Operator: "endsWith", Value1: "!", Props: map[string]string{"italic": "1"}}),
+	} {
+		if err := cur.Apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, op := range RestoreOps(cur, target) {
+		if err := cur.Apply(op); err != nil {
+			t.Fatalf("apply %+v: %v", op, err)
+		}
+	}
+	if !reflect.DeepEqual(cur.Validations, target.Validations) || !reflect.DeepEqual(cur.Protections, target.Protections) || len(cur.CondFormats) != 0 {
+		t.Fatalf("restored = %+v %+v %+v", cur.Validations, cur.Protections, cur.CondFormats)
+	}
+}
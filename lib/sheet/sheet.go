@@ -137,10 +137,46 @@ func (s *Sheet) remap(fn func(CellRef) (CellRef, bool)) {
 	s.Cells = next
 }
 
-// Workbook is the full document: ordered sheets plus the shared StylePool.
+// refRemap is the cell move of a structural row/col op for remap: a ref in a
+// deleted band is dropped, refs at/after the index shift by the count.
+func refRemap(op Op) func(CellRef) (CellRef, bool) {
+	return func(r CellRef) (CellRef, bool) {
+		switch op.Type {
+		case OpInsertRows:
+			if r.Row >= op.Index {
+				return CellRef{r.Row + op.Count, r.Col}, true
+			}
+		case OpDeleteRows:
+			if r.Row >= op.Index && r.Row < op.Index+op.Count {
+				return r, false // drop
+			}
+			if r.Row >= op.Index+op.Count {
+				return CellRef{r.Row - op.Count, r.Col}, true
+			}
+		case OpInsertCols:
+			if r.Col >= op.Index {
+				return CellRef{r.Row, r.Col + op.Count}, true
+			}
+		case OpDeleteCols:
+			if r.Col >= op.Index && r.Col < op.Index+op.Count {
+				return r, false
+			}
+			if r.Col >= op.Index+op.Count {
+				return CellRef{r.Row, r.Col - op.Count}, true
+			}
+		}
+		return r, true
+	}
+}
+
+// Workbook is the full document: ordered sheets plus the shared StylePool and
+// the workbook-level rule sets (see Rule).
 type Workbook struct {
-	Sheets []*Sheet   `json:"sheets"`
-	Styles *StylePool `json:"styles"`
+	Sheets      []*Sheet   `json:"sheets"`
+	Styles      *StylePool `json:"styles"`
+	Validations []Rule     `json:"validations,omitempty"`
+	CondFormats []Rule     `json:"condFormats,omitempty"`
+	Protections []Rule     `json:"protections,omitempty"`
 }
 
 func NewWorkbook() *Workbook {
@@ -165,8 +201,11 @@ func (w *Workbook) SheetByID(id string) *Sheet {
 // Clone returns a deep copy so callers can simulate clients independently.
 func (w *Workbook) Clone() *Workbook {
 	cp := &Workbook{
-		Sheets: make([]*Sheet, len(w.Sheets)),
-		Styles: w.Styles.clone(),
+		Sheets:      make([]*Sheet, len(w.Sheets)),
+		Styles:      w.Styles.clone(),
+		Validations: cloneRules(w.Validations),
+		CondFormats: cloneRules(w.CondFormats),
+		Protections: cloneRules(w.Protections),
 	}
 	for i, s := range w.Sheets {
 		cp.Sheets[i] = s.clone()
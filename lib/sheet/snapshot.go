@@ -38,14 +38,20 @@ type SheetSnapshot struct {
 
 // WorkbookSnapshot is the JSON-serializable form of a Workbook for persistence.
 type WorkbookSnapshot struct {
-	Sheets []SheetSnapshot `json:"sheets"`
-	Styles *StylePool      `json:"styles"`
+	Sheets      []SheetSnapshot `json:"sheets"`
+	Styles      *StylePool      `json:"styles"`
+	Validations []Rule          `json:"validations,omitempty"`
+	CondFormats []Rule          `json:"condFormats,omitempty"`
+	Protections []Rule          `json:"protections,omitempty"`
 }
 
 // Snapshot converts the workbook to its serializable form. Cells are emitted in
 // (row, col) order for deterministic output.
 func (w *Workbook) Snapshot() WorkbookSnapshot {
-	out := WorkbookSnapshot{Sheets: make([]SheetSnapshot, len(w.Sheets)), Styles: w.Styles}
+	out := WorkbookSnapshot{
+		Sheets: make([]SheetSnapshot, len(w.Sheets)), Styles: w.Styles,
+		Validations: cloneRules(w.Validations), CondFormats: cloneRules(w.CondFormats), Protections: cloneRules(w.Protections),
+	}
 	for i, s := range w.Sheets {
 		cells := make([]CellSnapshot, 0, len(s.Cells))
 		for ref, c := range s.Cells {
@@ -83,7 +89,12 @@ func (w *Workbook) Snapshot() WorkbookSnapshot {
 // WorkbookFromSnapshot rebuilds a Workbook (and its StylePool dedup index) from
 // a deserialized snapshot.
 func WorkbookFromSnapshot(snap WorkbookSnapshot) *Workbook {
-	w := &Workbook{Sheets: make([]*Sheet, len(snap.Sheets))}
+	w := &Workbook{
+		Sheets:      make([]*Sheet, len(snap.Sheets)),
+		Validations: cloneRules(snap.Validations),
+		CondFormats: cloneRules(snap.CondFormats),
+		Protections: cloneRules(snap.Protections),
+	}
 	if snap.Styles == nil {
 		w.Styles = NewStylePool()
 	} else {
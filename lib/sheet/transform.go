@@ -3,8 +3,11 @@ package sheet
 // Transform adjusts `in` so it applies cleanly after `applied`, where both were
 // originally composed against the same base revision and `applied` was ordered
 // first by the server. Only structural ops (row/col insert/delete) on the same
-// sheet and axis move coordinates; everything else is returned unchanged.
+// sheet and axis move coordinates. The formula of a setCell is rewritten like
+// Apply rewrites the workbook's formulas for structural ops, moveRange, sheet
+// renames and deletes; everything else is returned unchanged.
 func Transform(in, applied Op) Op {
+	in = transformFormula(in, applied)
 	if in.Sheet != applied.Sheet || !applied.isStructural() {
 		return in
 	}
@@ -25,13 +28,18 @@ func Transform(in, applied Op) Op {
 // delta > 0 is an insert; delta < 0 is a delete (band [index, index-delta)).
 // hasRange reports whether the op carries an EndRow/EndCol rectangle.
 func hasRange(t OpType) bool {
-	return t == OpClearRange || t == OpMergeCells || t == OpUnmergeCells
+	switch t {
+	case OpClearRange, OpMergeCells, OpUnmergeCells, OpSortRange, OpMoveRange, OpCopyRange, OpFillSeries,
+		OpSetValidation, OpSetCondFormat, OpSetProtection:
+		return true
+	}
+	return false
 }
 
 func shiftRows(in Op, index, delta int) Op {
-	in.Row = shiftCoord(in.Row, index, delta)
-	if hasRange(in.Type) {
-		in.EndRow = shiftCoord(in.EndRow, index, delta)
+	in.Row, in.EndRow, in.Count = shiftRange(in, in.Row, in.EndRow, "row", index, delta)
+	if in.Type == OpMoveRange || in.Type == OpCopyRange {
+		in.DestRow = shiftCoord(in.DestRow, index, delta)
 	}
 	if in.Type == OpInsertRows || in.Type == OpDeleteRows {
 		in.Index = shiftCoord(in.Index, index, delta)
@@ -43,9 +51,16 @@ func shiftRows(in Op, index, delta int) Op {
 }
 
 func shiftCols(in Op, index, delta int) Op {
-	in.Col = shiftCoord(in.Col, index, delta)
-	if hasRange(in.Type) {
-		in.EndCol = shiftCoord(in.EndCol, index, delta)
+	in.Col, in.EndCol, in.Count = shiftRange(in, in.Col, in.EndCol, "col", index, delta)
+	if in.Type == OpMoveRange || in.Type == OpCopyRange {
+		in.DestCol = shiftCoord(in.DestCol, index, delta)
+	}
+	if in.Type == OpSortRange {
+		keys := make([]SortKey, len(in.SortKeys))
+		for i, k := range in.SortKeys {
+			keys[i] = SortKey{Col: shiftCoord(k.Col, index, delta), Desc: k.Desc}
+		}
+		in.SortKeys = keys
 	}
 	if in.Type == OpInsertCols || in.Type == OpDeleteCols {
 		in.Index = shiftCoord(in.Index, index, delta)
@@ -56,6 +71,25 @@ func shiftCols(in Op, index, delta int) Op {
 	return in
 }
 
+// shiftRange shifts the start and, for ops with a range, the end coordinate
+// of in along axis. The seed of a fillSeries along that axis shifts like a
+// merge does (see shiftMerges); a seed deleted entirely leaves one index to
+// repeat. Count is returned unchanged for every other op.
+func shiftRange(in Op, start, end int, axis string, index, delta int) (int, int, int) {
+	count := in.Count
+	if in.Type == OpFillSeries && in.Axis == axis {
+		count = max(shiftEnd(start+count, index, delta)-shiftCoord(start, index, delta), 1)
+	}
+	start = shiftCoord(start, index, delta)
+	if hasRange(in.Type) {
+		end = shiftCoord(end, index, delta)
+	}
+	if in.Type == OpFillSeries && in.Axis == axis {
+		count = min(count, end-start+1)
+	}
+	return start, end, count
+}
+
 // shiftCoord shifts a single coordinate. For inserts (delta>0) coords at/after
 // index move right/down. For deletes (delta<0) coords after the band move back;
 // coords inside the deleted band clamp to index.
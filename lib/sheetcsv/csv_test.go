@@ -0,0 +1,160 @@
+package sheetcsv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func TestDetectDelimiter(t *testing.T) {
+	cases := []struct {
+		text string
+		want rune
+	}{
+		{"a,b,c\n1,2,3\n", ','},
+		{"a;b;c\n1,5;2,5;3\n", ';'},
+		{"a\tb\n1\t2\n", '\t'},
+		{"a|b\n1|2\n", '|'},
+		{`"x;y",b` + "\n" + `"1;2",3` + "\n", ','},
+		{"single column\n", ','},
+	}
+	for _, tc := range cases {
+		if got := DetectDelimiter(tc.text); got != tc.want {
+			t.Fatalf("DetectDelimiter(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		name  string
+		data  []byte
+		label string
+		want  string
+	}{
+		{"utf-8", []byte("é"), "", "é"},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFé"), "", "é"},
+		{"utf-16le bom", []byte{0xFF, 0xFE, 0xE9, 0x00}, "", "é"},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0x00, 0xE9}, "", "é"},
+		{"windows-1252 fallback", []byte{0xE9, 0x80}, "", "é€"},
+		{"explicit label", []byte{0xE9}, "iso-8859-1", "é"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decode(tc.data, tc.label)
+			if err != nil || got != tc.want {
+				t.Fatalf("decode = %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+	if _, err := decode([]byte("x"), "no-such-encoding"); err == nil {
+		t.Fatal("unknown labels must be rejected")
+	}
+}
+
+func TestInfer(t *testing.T) {
+	cases := []struct{ in, raw, numFmt string }{
+		{"42", "42", ""},
+		{" -3.5 ", "-3.5", ""},
+		{"1e3", "1e3", ""},
+		{"1,234.50", "1234.50", "number:2"},
+		{"12%", "0.12", "percent:0"},
+		{"12.5%", "0.125", "percent:1"},
+		{"$1,234.50", "1234.50", "currency:2"},
+		{"-$5", "-5", "currency:0"},
+		{"2024-03-01", "45352", "date"},
+		{"3/1/2024", "45352", "date"},
+		{"2024-02-30", "2024-02-30", ""},
+		{"00501", "00501", ""},
+		{"1,23", "1,23", ""},
+		{"hello", "hello", ""},
+		{"=SUM(A1:A2)", "=SUM(A1:A2)", ""},
+		{"", "", ""},
+	}
+	for _, tc := range cases {
+		raw, numFmt := Infer(tc.in)
+		if raw != tc.raw || numFmt != tc.numFmt {
+			t.Fatalf("Infer(%q) = %q, %q; want %q, %q", tc.in, raw, numFmt, tc.raw, tc.numFmt)
+		}
+	}
+}
+
+func TestImportIntoNamedSheet(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	first := wb.AddSheet("s1", "Sheet1")
+	first.SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "keep"})
+	data := wb.AddSheet("s2", "Data")
+	data.SetCell(sheet.CellRef{Row: 9, Col: 9}, sheet.Cell{Raw: "stale"})
+	data.Merges[sheet.CellRef{Row: 0, Col: 0}] = sheet.Span{Rows: 2, Cols: 2}
+	data.ColWidths[0] = 150
+
+	sh, err := Import(strings.NewReader("name;amount\nfoo;12%\n"), wb, "Data", ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if sh != data {
+		t.Fatal("must import into the existing sheet")
+	}
+	if len(data.Cells) != 4 || len(data.Merges) != 0 || data.ColWidths[0] != 150 {
+		t.Fatalf("cells %v merges %v widths %v", data.Cells, data.Merges, data.ColWidths)
+	}
+	c := data.GetCell(sheet.CellRef{Row: 1, Col: 1})
+	if st, _ := wb.Styles.Get(c.StyleId); c.Raw != "0.12" || st.Props["numFmt"] != "percent:0" {
+		t.Fatalf("B2 = %+v (%v)", c, st)
+	}
+	if first.GetCell(sheet.CellRef{Row: 0, Col: 0}).Raw != "keep" {
+		t.Fatal("other sheets must be left alone")
+	}
+
+	created, err := Import(strings.NewReader("x\n"), wb, "s1", ImportOptions{Delimiter: '\t'})
+	if err != nil || created != first {
+		t.Fatalf("a sheet id must select the sheet too: %v", err)
+	}
+	added, err := Import(strings.NewReader("x\n"), wb, "New", ImportOptions{})
+	if err != nil || added.Name != "New" || len(wb.Sheets) != 3 {
+		t.Fatalf("unknown names add a sheet: %+v, %v", added, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	sh := wb.AddSheet("s1", "Sheet1")
+	date := wb.Styles.Put(sheet.Style{Props: map[string]string{"numFmt": "date"}})
+	sh.SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "a,b"})
+	sh.SetCell(sheet.CellRef{Row: 0, Col: 2}, sheet.Cell{Raw: "45352", StyleId: date})
+	sh.SetCell(sheet.CellRef{Row: 1, Col: 0}, sheet.Cell{Raw: "=1+1", Value: "2", ValueType: "number"})
+	sh.SetCell(sheet.CellRef{Row: 1, Col: 1}, sheet.Cell{Raw: "=A1"})
+
+	out, err := Export(sh, wb.Styles, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "\"a,b\",,2024-03-01\n2,=A1,\n"; string(out) != want {
+		t.Fatalf("values: got %q, want %q", out, want)
+	}
+
+	out, err = Export(sh, wb.Styles, ExportOptions{Delimiter: '\t', Formulas: true})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "a,b\t\t45352\n=1+1\t=A1\t\n"; string(out) != want {
+		t.Fatalf("formulas: got %q, want %q", out, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	in := "id,when,price,note\n1,2024-03-01,$9.99,\"multi\nline\"\n"
+	sh, err := Import(strings.NewReader(in), wb, "", ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	out, err := Export(sh, wb.Styles, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "id,when,price,note\n1,2024-03-01,9.99,\"multi\nline\"\n"; string(out) != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+}
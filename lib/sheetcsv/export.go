@@ -0,0 +1,88 @@
+package sheetcsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// ExportOptions controls how a sheet is written as delimited text.
+type ExportOptions struct {
+	// Delimiter separates fields; 0 means a comma.
+	Delimiter rune
+	// Formulas writes every raw as stored ("=SUM(A1:A3)", date serials)
+	// instead of what the grid shows.
+	Formulas bool
+}
+
+// Export writes one sheet as delimited text, one line per row up to the last
+// populated row and as many fields per line as the widest row. By default
+// formulas are replaced by their cached computed value (the raw when none was
+// reported yet) and date-formatted serials are written as yyyy-mm-dd.
+func Export(sh *sheet.Sheet, styles *sheet.StylePool, opts ExportOptions) ([]byte, error) {
+	delim := opts.Delimiter
+	if delim == 0 {
+		delim = ','
+	}
+	if !ValidDelimiter(delim) {
+		return nil, fmt.Errorf("invalid delimiter %q", delim)
+	}
+
+	rows, cols := 0, 0
+	for ref, c := range sh.Cells {
+		if c.Raw == "" && c.Value == "" {
+			continue
+		}
+		rows = max(rows, ref.Row+1)
+		cols = max(cols, ref.Col+1)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = delim
+	record := make([]string, cols)
+	for r := range rows {
+		for c := range cols {
+			record[c] = CellText(sh.GetCell(sheet.CellRef{Row: r, Col: c}), styles, opts.Formulas)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CellText is the text a cell exports as: its raw input when formulas is set,
+// otherwise the computed value of a formula and date serials as yyyy-mm-dd.
+func CellText(c sheet.Cell, styles *sheet.StylePool, formulas bool) string {
+	if formulas {
+		return c.Raw
+	}
+	text := c.Raw
+	if c.Kind() == sheet.KindFormula && (c.Value != "" || c.ValueType != "") {
+		text = c.Value
+	}
+	if st, ok := styles.Get(c.StyleId); ok && strings.HasPrefix(st.Props["numFmt"], "date") {
+		if serial, err := strconv.ParseFloat(text, 64); err == nil {
+			return serialDate(serial)
+		}
+	}
+	return text
+}
+
+// serialDate formats a spreadsheet date serial as yyyy-mm-dd, dropping the
+// time of day.
+func serialDate(serial float64) string {
+	days := int(math.Floor(serial))
+	return serialEpoch.Add(time.Duration(days) * 24 * time.Hour).Format(time.DateOnly)
+}
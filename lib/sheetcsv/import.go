@@ -0,0 +1,269 @@
+package sheetcsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ImportOptions controls how delimited text is read. The zero value detects
+// both the delimiter and the encoding.
+type ImportOptions struct {
+	// Delimiter separates fields; 0 picks the most consistent of , ; tab |
+	// over the first lines.
+	Delimiter rune
+	// Encoding is a WHATWG label ("utf-8", "windows-1252", "shift_jis", ...).
+	// Empty detects UTF-8 and UTF-16 by their BOM, falling back to
+	// Windows-1252 when the bytes are no valid UTF-8.
+	Encoding string
+}
+
+// candidates are the delimiters detection chooses from, in order of preference
+// on a tie.
+var candidates = []rune{',', ';', '\t', '|'}
+
+// sampleLines bounds delimiter detection.
+const sampleLines = 20
+
+// Import parses delimited text into the sheet named sheetName of wb (created
+// when missing), replacing its cells and merges; dimensions and frozen panes
+// are kept. Numbers, percentages, dollar amounts and dates (yyyy-mm-dd or
+// m/d/yyyy) become numeric raws with a matching numFmt, everything else is
+// imported as text. Returns the target sheet.
+func Import(r io.Reader, wb *sheet.Workbook, sheetName string, opts ImportOptions) (*sheet.Sheet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text, err := decode(data, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	delim := opts.Delimiter
+	if delim == 0 {
+		delim = DetectDelimiter(text)
+	}
+	if !ValidDelimiter(delim) {
+		return nil, fmt.Errorf("invalid delimiter %q", delim)
+	}
+
+	cr := csv.NewReader(strings.NewReader(text))
+	cr.Comma = delim
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	sh := targetSheet(wb, sheetName)
+	sh.Cells = map[sheet.CellRef]sheet.Cell{}
+	sh.Merges = map[sheet.CellRef]sheet.Span{}
+	for r, record := range records {
+		for c, field := range record {
+			raw, numFmt := Infer(field)
+			if raw == "" {
+				continue
+			}
+			cell := sheet.Cell{Raw: raw}
+			if numFmt != "" {
+				cell.StyleId = wb.Styles.Put(sheet.Style{Props: map[string]string{"numFmt": numFmt}})
+			}
+			sh.SetCell(sheet.CellRef{Row: r, Col: c}, cell)
+		}
+	}
+	return sh, nil
+}
+
+// targetSheet finds the sheet by name (or id) or appends a new one whose id is
+// the name, made unique like the xlsx importer's.
+func targetSheet(wb *sheet.Workbook, name string) *sheet.Sheet {
+	if name == "" {
+		if len(wb.Sheets) > 0 {
+			return wb.Sheets[0]
+		}
+		name = "Sheet1"
+	}
+	for _, s := range wb.Sheets {
+		if s.Name == name {
+			return s
+		}
+	}
+	if s := wb.SheetByID(name); s != nil {
+		return s
+	}
+	id := name
+	for n := 2; wb.SheetByID(id) != nil; n++ {
+		id = fmt.Sprintf("%s-%d", name, n)
+	}
+	return wb.AddSheet(id, name)
+}
+
+// ValidDelimiter reports whether r can separate fields.
+func ValidDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && r != utf8.RuneError && utf8.ValidRune(r)
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// decode converts data to a UTF-8 string, see ImportOptions.Encoding.
+func decode(data []byte, label string) (string, error) {
+	var enc encoding.Encoding
+	switch {
+	case label != "":
+		e, err := htmlindex.Get(label)
+		if err != nil {
+			return "", fmt.Errorf("unknown encoding %q", label)
+		}
+		enc = e
+	case bytes.HasPrefix(data, bomUTF8):
+		return string(data[len(bomUTF8):]), nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case utf8.Valid(data):
+		return string(data), nil
+	default:
+		enc = charmap.Windows1252
+	}
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode file: %w", err)
+	}
+	return strings.TrimPrefix(string(out), "\uFEFF"), nil
+}
+
+// DetectDelimiter counts each candidate outside quotes on the first lines and
+// prefers the one that appears the same number of times on every line, then
+// the one appearing most often. Defaults to a comma.
+func DetectDelimiter(text string) rune {
+	lines := strings.SplitN(text, "\n", sampleLines+1)
+	if len(lines) > sampleLines {
+		lines = lines[:sampleLines]
+	}
+	best, bestScore := ',', 0
+	for _, d := range candidates {
+		first, total, consistent := -1, 0, true
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := countOutsideQuotes(line, d)
+			if first < 0 {
+				first = n
+			} else if n != first {
+				consistent = false
+			}
+			total += n
+		}
+		score := total
+		if consistent && first > 0 {
+			// Any consistent split beats an inconsistent one.
+			score += 1 << 20
+		}
+		if score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	return best
+}
+
+func countOutsideQuotes(line string, d rune) int {
+	n, quoted := 0, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == d && !quoted:
+			n++
+		}
+	}
+	return n
+}
+
+var (
+	numberRe = regexp.MustCompile(`^([+-]?)(\$?)(\d+|\d{1,3}(?:,\d{3})+)(?:\.(\d+))?([eE][+-]?\d+)?(%?)$`)
+	isoRe    = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	usDateRe = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})$`)
+)
+
+// serialEpoch is day 0 of spreadsheet date serials, as in the client.
+var serialEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Infer returns the raw to store for a field plus the numFmt its notation
+// implies ("" keeps the default). Numbers with leading zeros (zip codes, ids)
+// stay text.
+func Infer(field string) (raw, numFmt string) {
+	s := strings.TrimSpace(field)
+	if s == "" {
+		return "", ""
+	}
+	if m := numberRe.FindStringSubmatch(s); m != nil {
+		sign, dollar, intPart, frac, exp, pct := m[1], m[2], m[3], m[4], m[5], m[6]
+		if len(intPart) > 1 && intPart[0] == '0' || dollar != "" && (exp != "" || pct != "") {
+			return field, ""
+		}
+		digits := strings.ReplaceAll(intPart, ",", "")
+		num := digits
+		if frac != "" {
+			num += "." + frac
+		}
+		num += exp
+		if sign == "-" {
+			num = "-" + num
+		}
+		switch {
+		case pct != "":
+			v, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return field, ""
+			}
+			return strconv.FormatFloat(v/100, 'f', -1, 64), fmt.Sprintf("percent:%d", min(len(frac), 99))
+		case dollar != "":
+			return num, fmt.Sprintf("currency:%d", min(len(frac), 99))
+		case digits != intPart:
+			return num, fmt.Sprintf("number:%d", min(len(frac), 99))
+		}
+		return num, ""
+	}
+	if m := isoRe.FindStringSubmatch(s); m != nil {
+		if serial, ok := dateSerial(m[1], m[2], m[3]); ok {
+			return serial, "date"
+		}
+	}
+	if m := usDateRe.FindStringSubmatch(s); m != nil {
+		if serial, ok := dateSerial(m[3], m[1], m[2]); ok {
+			return serial, "date"
+		}
+	}
+	return field, ""
+}
+
+func dateSerial(year, month, day string) (string, bool) {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	// time.Date normalizes 2/30 to March; reject instead of shifting.
+	if t.Year() != y || int(t.Month()) != m || t.Day() != d {
+		return "", false
+	}
+	return strconv.Itoa(int(t.Sub(serialEpoch).Hours() / 24)), true
+}
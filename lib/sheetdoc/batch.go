@@ -0,0 +1,64 @@
+package sheetdoc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// ErrInvalidOp is returned by SubmitBatch for an op that fails validation or
+// cannot be applied to the workbook.
+var ErrInvalidOp = errors.New("invalid sheet op")
+
+// SubmitBatch applies ops composed one after the other against baseRev, the
+// way a client's pending ops are: the ops are transformed past the ops applied
+// since baseRev, each past those moved beyond the batch's earlier ops (see
+// rebase), then they are submitted in order. The batch is checked (and
+// dry-run) as a whole first, so it is never applied halfway, and is undone as
+// one step. It returns the rebased ops and the head after the last one; the
+// op at index i produced revision head-len(ops)+i+1.
+func (m *Manager) SubmitBatch(padId string, ops []sheet.Op, baseRev int, authorId *string, tsMillis int64) ([]sheet.Op, int, error) {
+	for i, op := range ops {
+		if err := op.Validate(); err != nil {
+			return nil, 0, fmt.Errorf("%w: op %d: %v", ErrInvalidOp, i, err)
+		}
+	}
+	e, err := m.load(padId)
+	if err != nil {
+		return nil, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	head := e.doc.Head()
+	if baseRev < 0 || baseRev > head {
+		return nil, head, ErrRevisionOutOfRange
+	}
+
+	pending := rebase(ops, e.doc.Log()[baseRev:head], false)
+
+	dry := e.doc.Workbook().Clone()
+	for i, op := range pending {
+		if authorId != nil {
+			if err := dry.Check(op, *authorId); err != nil {
+				return nil, head, fmt.Errorf("op %d: %w", i, err)
+			}
+		}
+		if err := dry.Apply(op); err != nil {
+			return nil, head, fmt.Errorf("%w: op %d: %v", ErrInvalidOp, i, err)
+		}
+	}
+
+	out := make([]sheet.Op, 0, len(pending))
+	var step undoStep
+	defer func() { e.record(authorId, step, false) }()
+	for _, op := range pending {
+		op.BaseRev = e.doc.Head()
+		rebased, _, err := m.submitLocked(padId, e, op, authorId, tsMillis, &step)
+		if err != nil {
+			return out, e.doc.Head(), err
+		}
+		out = append(out, rebased)
+	}
+	return out, e.doc.Head(), nil
+}
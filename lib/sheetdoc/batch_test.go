@@ -0,0 +1,106 @@
+package sheetdoc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func TestManagerSubmitBatchRebasesPastEarlierBatchOps(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	fill := make([]sheet.Op, 10)
+	for row := range fill {
+		fill[row] = sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: row, Raw: strptr(fmt.Sprintf("r%d", row))}
+	}
+	_, base, err := m.SubmitBatch("p1", fill, 0, nil, 1)
+	if err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+	// A concurrent insert lands inside the rows the batch deletes.
+	if _, _, err := m.Submit("p1", sheet.Op{Type: sheet.OpInsertRows, Sheet: DefaultSheetID, Index: 3, Count: 1, BaseRev: base}, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// After deleting rows 0-4, row 1 is the row that was r6.
+	batch := []sheet.Op{
+		{Type: sheet.OpDeleteRows, Sheet: DefaultSheetID, Index: 0, Count: 5},
+		{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Col: 1, Raw: strptr("x")},
+	}
+	if _, _, err := m.SubmitBatch("p1", batch, base, nil, 3); err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	snap, _, err := m.Snapshot("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := sheet.WorkbookFromSnapshot(snap).SheetByID(DefaultSheetID)
+	for row := 0; row < 10; row++ {
+		if sh.GetCell(sheet.CellRef{Row: row, Col: 1}).Raw != "x" {
+			continue
+		}
+		if got := sh.GetCell(sheet.CellRef{Row: row}).Raw; got != "r6" {
+			t.Fatalf("batch wrote next to %q, want r6", got)
+		}
+		return
+	}
+	t.Fatal("batch cell missing")
+}
+
+func TestManagerSubmitBatchRebasesAndChecks(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	// A concurrent row insert lands after the batch's baseRev.
+	if _, _, err := m.Submit("p1", setCell(0, 0, "head"), nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Submit("p1", sheet.Op{Type: sheet.OpInsertRows, Sheet: DefaultSheetID, Index: 0, Count: 1, BaseRev: 1}, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := []sheet.Op{
+		{Type: sheet.OpAddSheet, Sheet: "s2", Name: "Data", Index: 1},
+		{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 2, Raw: strptr("a")},
+		{Type: sheet.OpSetCell, Sheet: "s2", Row: 0, Raw: strptr("b")},
+	}
+	author := "a.1"
+	ops, head, err := m.SubmitBatch("p1", batch, 1, &author, 3)
+	if err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	if head != 5 || len(ops) != 3 || ops[2].BaseRev != 4 {
+		t.Fatalf("head %d, ops %+v", head, ops)
+	}
+	snap, _, err := m.Snapshot("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb := sheet.WorkbookFromSnapshot(snap)
+	if got := wb.SheetByID(DefaultSheetID).GetCell(sheet.CellRef{Row: 3}).Raw; got != "a" {
+		t.Fatalf("batch cell not rebased past the insert: row 3 = %q", got)
+	}
+	if got := wb.SheetByID("s2").GetCell(sheet.CellRef{}).Raw; got != "b" {
+		t.Fatalf("cell on the added sheet = %q", got)
+	}
+
+	// One refused op refuses the whole batch.
+	protect := sheet.Op{Type: sheet.OpSetProtection, Sheet: "s2", Rule: &sheet.Rule{Id: "p", Authors: []string{author}}, BaseRev: head}
+	if _, _, err := m.Submit("p1", protect, &author, 4); err != nil {
+		t.Fatal(err)
+	}
+	other := "a.2"
+	refused := []sheet.Op{
+		{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 9, Raw: strptr("x")},
+		{Type: sheet.OpSetCell, Sheet: "s2", Row: 0, Raw: strptr("y")},
+	}
+	if _, got, err := m.SubmitBatch("p1", refused, head+1, &other, 5); !errors.Is(err, sheet.ErrProtected) || got != head+1 {
+		t.Fatalf("expected ErrProtected at head %d, got %v at %d", head+1, err, got)
+	}
+	if _, _, err := m.SubmitBatch("p1", []sheet.Op{{Type: sheet.OpSetCell, Raw: strptr("x")}}, 0, nil, 6); !errors.Is(err, ErrInvalidOp) {
+		t.Fatalf("expected ErrInvalidOp, got %v", err)
+	}
+	if _, _, err := m.SubmitBatch("p1", refused[:1], head+2, nil, 7); !errors.Is(err, ErrRevisionOutOfRange) {
+		t.Fatalf("expected ErrRevisionOutOfRange, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package sheetdoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/utils"
+)
+
+var (
+	ErrCommentNotFound = errors.New("sheet comment not found")
+	// ErrCommentForbidden is returned for deleting another author's comment.
+	ErrCommentForbidden = errors.New("sheet comment belongs to another author")
+)
+
+// loadThreads reads the persisted comment threads of padId.
+func (m *Manager) loadThreads(padId string) (map[string]sheet.CommentThread, error) {
+	rows, err := m.store.GetSheetComments(padId)
+	if err != nil {
+		return nil, err
+	}
+	threads := make(map[string]sheet.CommentThread, len(*rows))
+	for _, row := range *rows {
+		var t sheet.CommentThread
+		if err := json.Unmarshal([]byte(row.Thread), &t); err != nil {
+			return nil, err
+		}
+		threads[t.Id] = t
+	}
+	return threads, nil
+}
+
+// saveThread persists t and makes it the cached copy.
+func (m *Manager) saveThread(padId string, e *entry, t sheet.CommentThread) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := m.store.SaveSheetComment(padId, t.Id, string(b)); err != nil {
+		return err
+	}
+	e.threads[t.Id] = t
+	return nil
+}
+
+func (m *Manager) removeThread(padId string, e *entry, id string) error {
+	if err := m.store.RemoveSheetComment(padId, id); err != nil {
+		return err
+	}
+	delete(e.threads, id)
+	return nil
+}
+
+// moveThreads carries the comment anchors along with an applied op (see
+// sheet.MoveAnchor). Threads whose cell or sheet is gone are deleted.
+func (m *Manager) moveThreads(padId string, e *entry, op sheet.Op) error {
+	for _, id := range slices.Sorted(maps.Keys(e.threads)) {
+		t := e.threads[id]
+		if t.Sheet != op.Sheet {
+			continue
+		}
+		cell, ok := sheet.MoveAnchor(t.Sheet, t.Cell, op)
+		if !ok || e.doc.Workbook().SheetByID(t.Sheet) == nil {
+			if err := m.removeThread(padId, e, id); err != nil {
+				return err
+			}
+			continue
+		}
+		if cell != t.Cell {
+			t.Cell = cell
+			if err := m.saveThread(padId, e, t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedThreads returns copies of the cached threads ordered by id.
+func sortedThreads(threads map[string]sheet.CommentThread) []sheet.CommentThread {
+	out := make([]sheet.CommentThread, 0, len(threads))
+	for _, id := range slices.Sorted(maps.Keys(threads)) {
+		out = append(out, threads[id].Clone())
+	}
+	return out
+}
+
+// Comments returns the comment threads of a sheet document, their anchors as
+// of the returned head.
+func (m *Manager) Comments(padId string) ([]sheet.CommentThread, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return nil, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return sortedThreads(e.threads), e.doc.Head(), nil
+}
+
+// State returns the workbook snapshot together with the comment threads, both
+// as of the returned head (for the initial client state and xlsx export).
+func (m *Manager) State(padId string) (sheet.WorkbookSnapshot, []sheet.CommentThread, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return sheet.WorkbookSnapshot{}, nil, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.doc.Workbook().Snapshot(), sortedThreads(e.threads), e.doc.Head(), nil
+}
+
+// AddComment posts c to the thread threadId or, when threadId is empty, to
+// the thread on the cell (sheetId, ref as of baseRev), starting one if the
+// cell has none. A reply reopens a resolved thread. The comment gets a fresh
+// id; the updated thread and the head its anchor is valid at are returned.
+func (m *Manager) AddComment(padId, threadId, sheetId string, ref sheet.CellRef, baseRev int, c sheet.Comment) (sheet.CommentThread, int, error) {
+	if err := sheet.ValidateComment(c.Text); err != nil {
+		return sheet.CommentThread{}, 0, err
+	}
+	e, err := m.load(padId)
+	if err != nil {
+		return sheet.CommentThread{}, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var t sheet.CommentThread
+	if threadId != "" {
+		var ok bool
+		if t, ok = e.threads[threadId]; !ok {
+			return sheet.CommentThread{}, 0, ErrCommentNotFound
+		}
+	} else {
+		cell, err := e.doc.RebaseAnchor(sheetId, ref, baseRev)
+		if err != nil {
+			return sheet.CommentThread{}, 0, err
+		}
+		t = sheet.CommentThread{Id: "t." + utils.RandomString(12), Sheet: sheetId, Cell: cell}
+		for _, other := range e.threads {
+			if other.Sheet == sheetId && other.Cell == cell {
+				t = other // one thread per cell, like Excel
+				break
+			}
+		}
+	}
+	if len(t.Comments) >= sheet.MaxThreadComments {
+		return sheet.CommentThread{}, 0, fmt.Errorf("%w: more than %d comments in a thread", sheet.ErrInvalidComment, sheet.MaxThreadComments)
+	}
+	t = t.Clone()
+	c.Id = "c." + utils.RandomString(12)
+	t.Comments = append(t.Comments, c)
+	t.Resolved = false
+	if err := m.saveThread(padId, e, t); err != nil {
+		return sheet.CommentThread{}, 0, err
+	}
+	return t.Clone(), e.doc.Head(), nil
+}
+
+// ResolveThread marks a thread resolved, or open again.
+func (m *Manager) ResolveThread(padId, threadId string, resolved bool) (sheet.CommentThread, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return sheet.CommentThread{}, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t, ok := e.threads[threadId]
+	if !ok {
+		return sheet.CommentThread{}, 0, ErrCommentNotFound
+	}
+	if t.Resolved != resolved {
+		t.Resolved = resolved
+		if err := m.saveThread(padId, e, t); err != nil {
+			return sheet.CommentThread{}, 0, err
+		}
+	}
+	return t.Clone(), e.doc.Head(), nil
+}
+
+// DeleteComment removes one comment of a thread. Only its author may, unless
+// authorId is empty (the server itself). Deleting the last comment deletes
+// the thread, which removed reports.
+func (m *Manager) DeleteComment(padId, threadId, commentId, authorId string) (sheet.CommentThread, bool, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return sheet.CommentThread{}, false, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t, ok := e.threads[threadId]
+	if !ok {
+		return sheet.CommentThread{}, false, 0, ErrCommentNotFound
+	}
+	i := slices.IndexFunc(t.Comments, func(c sheet.Comment) bool { return c.Id == commentId })
+	if i < 0 {
+		return sheet.CommentThread{}, false, 0, ErrCommentNotFound
+	}
+	if authorId != "" && t.Comments[i].AuthorId != authorId {
+		return sheet.CommentThread{}, false, 0, ErrCommentForbidden
+	}
+	t = t.Clone()
+	t.Comments = slices.Delete(t.Comments, i, i+1)
+	if len(t.Comments) == 0 {
+		if err := m.removeThread(padId, e, threadId); err != nil {
+			return sheet.CommentThread{}, false, 0, err
+		}
+		return t, true, e.doc.Head(), nil
+	}
+	if err := m.saveThread(padId, e, t); err != nil {
+		return sheet.CommentThread{}, false, 0, err
+	}
+	return t.Clone(), false, e.doc.Head(), nil
+}
@@ -0,0 +1,121 @@
+package sheetdoc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func TestManagerCommentThreads(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	at := sheet.CellRef{Row: 2, Col: 1}
+	th, head, err := m.AddComment("p1", "", DefaultSheetID, at, 0, sheet.Comment{AuthorId: "a.1", Text: "check this"})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if head != 0 || th.Cell != at || len(th.Comments) != 1 || th.Comments[0].Id == "" {
+		t.Fatalf("unexpected thread: %+v (head %d)", th, head)
+	}
+
+	// A second comment on the same cell joins its thread.
+	if th, _, err = m.AddComment("p1", "", DefaultSheetID, at, 0, sheet.Comment{AuthorId: "a.2", Text: "done"}); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if len(th.Comments) != 2 {
+		t.Fatalf("expected a reply in the same thread, got %+v", th)
+	}
+
+	if th, _, err = m.ResolveThread("p1", th.Id, true); err != nil || !th.Resolved {
+		t.Fatalf("ResolveThread: %+v %v", th, err)
+	}
+	// Replying reopens it.
+	if th, _, err = m.AddComment("p1", th.Id, "", sheet.CellRef{}, 0, sheet.Comment{AuthorId: "a.1", Text: "not quite"}); err != nil || th.Resolved {
+		t.Fatalf("reply: %+v %v", th, err)
+	}
+
+	if _, _, _, err := m.DeleteComment("p1", th.Id, th.Comments[0].Id, "a.2"); !errors.Is(err, ErrCommentForbidden) {
+		t.Fatalf("expected ErrCommentForbidden, got %v", err)
+	}
+	for i, c := range th.Comments {
+		_, removed, _, err := m.DeleteComment("p1", th.Id, c.Id, c.AuthorId)
+		if err != nil {
+			t.Fatalf("DeleteComment: %v", err)
+		}
+		if removed != (i == len(th.Comments)-1) {
+			t.Fatalf("comment %d: removed = %v", i, removed)
+		}
+	}
+	if threads, _, _ := m.Comments("p1"); len(threads) != 0 {
+		t.Fatalf("expected no threads left, got %+v", threads)
+	}
+	if _, _, err := m.ResolveThread("p1", th.Id, true); !errors.Is(err, ErrCommentNotFound) {
+		t.Fatalf("expected ErrCommentNotFound, got %v", err)
+	}
+	if _, _, err := m.AddComment("p1", "", DefaultSheetID, at, 0, sheet.Comment{Text: " "}); !errors.Is(err, sheet.ErrInvalidComment) {
+		t.Fatalf("expected ErrInvalidComment, got %v", err)
+	}
+}
+
+func TestManagerCommentAnchorsFollowOps(t *testing.T) {
+	store := db.NewMemoryDataStore()
+	m := NewManager(store)
+	keep, _, err := m.AddComment("p1", "", DefaultSheetID, sheet.CellRef{Row: 3, Col: 0}, 0, sheet.Comment{Text: "moves"})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	gone, _, err := m.AddComment("p1", "", DefaultSheetID, sheet.CellRef{Row: 1, Col: 0}, 0, sheet.Comment{Text: "deleted"})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if _, _, err := m.Submit("p1", sheet.Op{Type: sheet.OpDeleteRows, Sheet: DefaultSheetID, Index: 1, Count: 1}, nil, 1); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	// A comment composed before the delete is rebased like an op.
+	late, _, err := m.AddComment("p1", "", DefaultSheetID, sheet.CellRef{Row: 5, Col: 0}, 0, sheet.Comment{Text: "late"})
+	if err != nil || late.Cell != (sheet.CellRef{Row: 4, Col: 0}) {
+		t.Fatalf("late comment: %+v %v", late, err)
+	}
+	if _, _, err := m.AddComment("p1", "", DefaultSheetID, sheet.CellRef{Row: 1, Col: 0}, 0, sheet.Comment{Text: "too late"}); !errors.Is(err, sheet.ErrAnchorDeleted) {
+		t.Fatalf("expected ErrAnchorDeleted, got %v", err)
+	}
+
+	// The moves are persisted: a fresh manager sees them.
+	threads, head, err := NewManager(store).Comments("p1")
+	if err != nil {
+		t.Fatalf("Comments: %v", err)
+	}
+	if head != 1 || len(threads) != 2 {
+		t.Fatalf("expected 2 threads at head 1, got %+v (head %d)", threads, head)
+	}
+	for _, th := range threads {
+		if th.Id == gone.Id {
+			t.Fatal("the thread of a deleted row must be deleted")
+		}
+		if th.Id == keep.Id && th.Cell != (sheet.CellRef{Row: 2, Col: 0}) {
+			t.Fatalf("thread not moved up: %+v", th.Cell)
+		}
+	}
+}
+
+func TestManagerSetWorkbookReplacesComments(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	if _, _, err := m.AddComment("p1", "", DefaultSheetID, sheet.CellRef{}, 0, sheet.Comment{Text: "old"}); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	wb := sheet.NewWorkbook()
+	wb.AddSheet("Data", "Data")
+	imported := []sheet.CommentThread{
+		{Id: "thread-1", Sheet: "Data", Cell: sheet.CellRef{Row: 1, Col: 1}, Comments: []sheet.Comment{{Id: "comment-1", Text: "note"}}},
+		{Id: "thread-2", Sheet: "Gone", Comments: []sheet.Comment{{Id: "comment-2", Text: "orphan"}}},
+	}
+	if err := m.SetWorkbook("p1", wb, imported); err != nil {
+		t.Fatalf("SetWorkbook: %v", err)
+	}
+	threads, _, _ := m.Comments("p1")
+	if len(threads) != 1 || threads[0].Id != "thread-1" {
+		t.Fatalf("expected only the imported thread on an existing sheet, got %+v", threads)
+	}
+}
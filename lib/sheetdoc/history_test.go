@@ -0,0 +1,129 @@
+package sheetdoc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func setCell(rev, row int, raw string) sheet.Op {
+	return sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: row, Raw: strptr(raw), BaseRev: rev}
+}
+
+func TestManagerWorkbookAtReplaysFromSnapshots(t *testing.T) {
+	store := db.NewMemoryDataStore()
+	m := NewManager(store)
+	author := "a.1"
+	total := SnapshotInterval + 5
+	for i := 0; i < total; i++ {
+		if _, _, err := m.Submit("p1", setCell(i, i, "v"), &author, int64(i)); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+	if snap, err := store.GetSheetSnapshotBefore("p1", total); err != nil || snap.Rev != SnapshotInterval {
+		t.Fatalf("expected a snapshot at %d, got %+v %v", SnapshotInterval, snap, err)
+	}
+	for _, rev := range []int{0, 1, SnapshotInterval - 1, SnapshotInterval, total} {
+		wb, err := m.WorkbookAt("p1", rev)
+		if err != nil {
+			t.Fatalf("WorkbookAt(%d): %v", rev, err)
+		}
+		if n := len(wb.SheetByID(DefaultSheetID).Cells); n != rev {
+			t.Fatalf("WorkbookAt(%d) has %d cells", rev, n)
+		}
+	}
+	if _, err := m.WorkbookAt("p1", total+1); !errors.Is(err, ErrRevisionOutOfRange) {
+		t.Fatalf("expected ErrRevisionOutOfRange, got %v", err)
+	}
+
+	revs, err := m.Revisions("p1", 0, 3)
+	if err != nil {
+		t.Fatalf("Revisions: %v", err)
+	}
+	if len(revs) != 3 || revs[0].Rev != 1 || revs[2].Timestamp != 2 || *revs[0].AuthorId != author || revs[0].Type != sheet.OpSetCell {
+		t.Fatalf("revisions = %+v", revs)
+	}
+}
+
+func TestManagerDiffAndRestore(t *testing.T) {
+	store := db.NewMemoryDataStore()
+	m := NewManager(store)
+	ops := []sheet.Op{
+		setCell(0, 0, "one"),
+		{Type: sheet.OpAddSheet, Sheet: "s2", Name: "Extra", Index: 1, BaseRev: 1},
+		setCell(2, 0, "two"),
+		{Type: sheet.OpSetFreeze, Sheet: DefaultSheetID, FrozenRows: 1, BaseRev: 3},
+	}
+	for _, op := range ops {
+		if _, _, err := m.Submit("p1", op, nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := m.Diff("p1", 1, 4)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diff) != 2 || diff[0].Id != DefaultSheetID || diff[0].Cells[0].Before.Raw != "one" || diff[0].Cells[0].After.Raw != "two" || diff[1].Change != sheet.SheetAdded {
+		t.Fatalf("diff = %+v", diff)
+	}
+
+	author := "a.admin"
+	restored, head, err := m.Restore("p1", 1, &author, 2)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if head != 4+len(restored) || len(restored) != 3 {
+		t.Fatalf("head %d after %d ops", head, len(restored))
+	}
+	snap, _, _ := m.Snapshot("p1")
+	wb := sheet.WorkbookFromSnapshot(snap)
+	s1 := wb.SheetByID(DefaultSheetID)
+	if len(wb.Sheets) != 1 || s1.GetCell(sheet.CellRef{}).Raw != "one" || s1.FrozenRows != 0 {
+		t.Fatalf("restored workbook = %+v", snap)
+	}
+	// Restoring keeps the history: the restored revisions are listed as well.
+	revs, _ := m.Revisions("p1", 5, head)
+	if len(revs) != 3 || *revs[0].AuthorId != author {
+		t.Fatalf("restore revisions = %+v", revs)
+	}
+	if diff, _ := m.Diff("p1", 1, head); len(diff) != 0 {
+		t.Fatalf("diff after restore = %+v", diff)
+	}
+}
+
+func TestManagerHistoryStartsAtHeadForOldSheets(t *testing.T) {
+	store := db.NewMemoryDataStore()
+	m := NewManager(store)
+	for i := 0; i < 3; i++ {
+		if _, _, err := m.Submit("p1", setCell(i, i, "v"), nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Simulate a sheet persisted before history snapshots existed.
+	if err := store.RemoveSheetSnapshots("p1"); err != nil {
+		t.Fatal(err)
+	}
+	m2 := NewManager(store)
+	if _, err := m2.WorkbookAt("p1", 1); !errors.Is(err, ErrHistoryUnavailable) {
+		t.Fatalf("expected ErrHistoryUnavailable, got %v", err)
+	}
+	if _, err := m2.WorkbookAt("p1", 3); err != nil {
+		t.Fatalf("WorkbookAt(head): %v", err)
+	}
+}
+
+func TestManagerExists(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	if ok, err := m.Exists("p1"); err != nil || ok {
+		t.Fatalf("Exists before load = %v %v", ok, err)
+	}
+	if _, _, err := m.Snapshot("p1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := m.Exists("p1"); !ok {
+		t.Fatal("Exists after load = false")
+	}
+}
@@ -2,6 +2,7 @@ package sheetdoc
 
 import (
 	"encoding/json"
+	"errors"
 	"sync"
 
 	"github.com/ether/etherpad-go/lib/db"
@@ -11,9 +12,26 @@ import (
 // DefaultSheetID is the id of the single sheet created for a brand-new workbook.
 const DefaultSheetID = "s1"
 
+// SnapshotInterval is the number of revisions between the persisted snapshots
+// that WorkbookAt replays the op-log from.
+const SnapshotInterval = 100
+
+var (
+	ErrRevisionOutOfRange = errors.New("sheet revision out of range")
+	// ErrHistoryUnavailable is returned for revisions older than the first
+	// persisted snapshot (sheets created before snapshots were kept).
+	ErrHistoryUnavailable = errors.New("sheet history unavailable for revision")
+)
+
 type entry struct {
 	mu  sync.Mutex
 	doc *sheet.Document
+	// threads are the document's comment threads by id, anchored as of the
+	// doc's head.
+	threads map[string]sheet.CommentThread
+	// undo holds the undo and redo stacks of each author (see Undo). They
+	// live in memory only, so a restart or SetWorkbook empties them.
+	undo map[string]*undoStacks
 }
 
 // Manager owns the in-memory sheet documents and serializes operations per
@@ -41,6 +59,7 @@ func (m *Manager) load(padId string) (*entry, error) {
 		return nil, err
 	}
 	var doc *sheet.Document
+	threads := map[string]sheet.CommentThread{}
 	if exists != nil && *exists {
 		sd, err := m.store.GetSheet(padId)
 		if err != nil {
@@ -64,6 +83,19 @@ func (m *Manager) load(padId string) (*entry, error) {
 			log = append(log, op)
 		}
 		doc = sheet.NewDocumentAt(wb, log)
+		// Sheets from before history snapshots were kept start their history
+		// at the current head.
+		if _, err := m.store.GetSheetSnapshotBefore(padId, sd.Head); err != nil {
+			if err.Error() != db.SheetSnapshotNotFoundError {
+				return nil, err
+			}
+			if err := m.store.SaveSheetSnapshot(padId, sd.Head, sd.Snapshot); err != nil {
+				return nil, err
+			}
+		}
+		if threads, err = m.loadThreads(padId); err != nil {
+			return nil, err
+		}
 	} else {
 		wb := sheet.NewWorkbook()
 		wb.AddSheet(DefaultSheetID, "Sheet1")
@@ -75,27 +107,63 @@ func (m *Manager) load(padId string) (*entry, error) {
 		if err := m.store.SaveSheet(padId, 0, string(snapBytes)); err != nil {
 			return nil, err
 		}
+		if err := m.store.SaveSheetSnapshot(padId, 0, string(snapBytes)); err != nil {
+			return nil, err
+		}
 	}
-	e := &entry{doc: doc}
+	e := &entry{doc: doc, threads: threads}
 	m.docs[padId] = e
 	return e, nil
 }
 
 // Submit rebases, applies, and persists one op, returning the rebased op (for
-// broadcast) and the new head revision.
+// broadcast) and the new head revision. Ops with an author are checked against
+// the workbook's protected ranges and data validations first (see
+// sheet.Workbook.Check); a nil author is the server itself. Comment anchors
+// move along with the op, and an author's op becomes a step it can Undo.
 func (m *Manager) Submit(padId string, op sheet.Op, authorId *string, tsMillis int64) (sheet.Op, int, error) {
+	return m.SubmitStep(padId, op, authorId, tsMillis, false)
+}
+
+// SubmitStep is Submit for the ops of one user action sent one by one (a
+// paste, a fill): with join the op is added to the author's last undo step
+// instead of starting a new one, so Undo reverts the action as a whole.
+func (m *Manager) SubmitStep(padId string, op sheet.Op, authorId *string, tsMillis int64, join bool) (sheet.Op, int, error) {
 	e, err := m.load(padId)
 	if err != nil {
 		return sheet.Op{}, 0, err
 	}
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	var step undoStep
+	rebased, rev, err := m.submitLocked(padId, e, op, authorId, tsMillis, &step)
+	if err == nil {
+		e.record(authorId, step, join)
+	}
+	return rebased, rev, err
+}
 
-	rev, err := e.doc.Submit(op)
+// submitLocked is Submit for a caller holding e.mu. With an author and a
+// step, the inverse of the op is added to the front of the step.
+func (m *Manager) submitLocked(padId string, e *entry, op sheet.Op, authorId *string, tsMillis int64, step *undoStep) (sheet.Op, int, error) {
+	rebased, err := e.doc.Rebase(op)
+	if err != nil {
+		return sheet.Op{}, 0, err
+	}
+	var inverse []sheet.Op
+	if authorId != nil {
+		if err := e.doc.Workbook().Check(rebased, *authorId); err != nil {
+			return sheet.Op{}, 0, err
+		}
+		if step != nil {
+			inverse = e.doc.Workbook().Inverse(rebased)
+		}
+	}
+	rev, err := e.doc.Submit(rebased)
 	if err != nil {
 		return sheet.Op{}, 0, err
 	}
-	rebased := e.doc.Log()[rev-1]
+	rebased = e.doc.Log()[rev-1]
 
 	opBytes, err := json.Marshal(rebased)
 	if err != nil {
@@ -111,13 +179,26 @@ func (m *Manager) Submit(padId string, op sheet.Op, authorId *string, tsMillis i
 	if err := m.store.SaveSheet(padId, rev, string(snapBytes)); err != nil {
 		return sheet.Op{}, 0, err
 	}
+	if rev%SnapshotInterval == 0 {
+		if err := m.store.SaveSheetSnapshot(padId, rev, string(snapBytes)); err != nil {
+			return sheet.Op{}, 0, err
+		}
+	}
+	if err := m.moveThreads(padId, e, rebased); err != nil {
+		return sheet.Op{}, 0, err
+	}
+	if step != nil {
+		step.add(inverse, rev)
+	}
 	return rebased, rev, nil
 }
 
 // SetWorkbook replaces the document's workbook (e.g. from an xlsx import),
-// resetting it to revision 0 with an empty op-log. Existing persisted ops are
-// cleared so the write-once sheet_op primary key does not block later edits.
-func (m *Manager) SetWorkbook(padId string, wb *sheet.Workbook) error {
+// resetting it to revision 0 with an empty op-log. Existing persisted ops and
+// history snapshots are cleared so the write-once sheet_op primary key does
+// not block later edits. The comment threads are replaced by threads, minus
+// those on a sheet wb does not have.
+func (m *Manager) SetWorkbook(padId string, wb *sheet.Workbook, threads []sheet.CommentThread) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	doc := sheet.NewDocument(wb)
@@ -128,10 +209,28 @@ func (m *Manager) SetWorkbook(padId string, wb *sheet.Workbook) error {
 	if err := m.store.RemoveSheetOps(padId); err != nil {
 		return err
 	}
+	if err := m.store.RemoveSheetSnapshots(padId); err != nil {
+		return err
+	}
 	if err := m.store.SaveSheet(padId, 0, string(snapBytes)); err != nil {
 		return err
 	}
-	m.docs[padId] = &entry{doc: doc}
+	if err := m.store.SaveSheetSnapshot(padId, 0, string(snapBytes)); err != nil {
+		return err
+	}
+	if err := m.store.RemoveSheetComments(padId); err != nil {
+		return err
+	}
+	e := &entry{doc: doc, threads: map[string]sheet.CommentThread{}}
+	for _, t := range threads {
+		if wb.SheetByID(t.Sheet) == nil {
+			continue
+		}
+		if err := m.saveThread(padId, e, t.Clone()); err != nil {
+			return err
+		}
+	}
+	m.docs[padId] = e
 	return nil
 }
 
@@ -166,3 +265,158 @@ func (m *Manager) OpsSince(padId string, sinceRev int) ([]sheet.Op, error) {
 	copy(out, log[sinceRev:])
 	return out, nil
 }
+
+// Exists reports whether a sheet document has been created for padId. Unlike
+// the other methods it never creates one.
+func (m *Manager) Exists(padId string) (bool, error) {
+	m.mu.Lock()
+	_, cached := m.docs[padId]
+	m.mu.Unlock()
+	if cached {
+		return true, nil
+	}
+	exists, err := m.store.DoesSheetExist(padId)
+	if err != nil {
+		return false, err
+	}
+	return exists != nil && *exists, nil
+}
+
+// Revision describes one persisted op of a sheet document.
+type Revision struct {
+	Rev       int          `json:"rev"`
+	AuthorId  *string      `json:"authorId"`
+	Timestamp int64        `json:"timestamp"`
+	Type      sheet.OpType `json:"type"`
+	Sheet     string       `json:"sheet"`
+}
+
+// Revisions lists the revisions from..to (inclusive, clamped to the head).
+func (m *Manager) Revisions(padId string, from, to int) ([]Revision, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	from, to = max(from, 1), min(to, e.doc.Head())
+	out := []Revision{}
+	if from > to {
+		return out, nil
+	}
+	opsDB, err := m.store.GetSheetOps(padId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range *opsDB {
+		var op sheet.Op
+		if err := json.Unmarshal([]byte(o.Op), &op); err != nil {
+			return nil, err
+		}
+		out = append(out, Revision{Rev: o.Rev, AuthorId: o.AuthorId, Timestamp: o.Timestamp, Type: op.Type, Sheet: op.Sheet})
+	}
+	return out, nil
+}
+
+// WorkbookAt materializes the workbook as it was at rev, replaying the op-log
+// from the nearest persisted snapshot at or before it.
+func (m *Manager) WorkbookAt(padId string, rev int) (*sheet.Workbook, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return m.workbookAt(padId, rev, e.doc.Head())
+}
+
+func (m *Manager) workbookAt(padId string, rev, head int) (*sheet.Workbook, error) {
+	if rev < 0 || rev > head {
+		return nil, ErrRevisionOutOfRange
+	}
+	sd, err := m.store.GetSheetSnapshotBefore(padId, rev)
+	if err != nil {
+		if err.Error() == db.SheetSnapshotNotFoundError {
+			return nil, ErrHistoryUnavailable
+		}
+		return nil, err
+	}
+	var snap sheet.WorkbookSnapshot
+	if err := json.Unmarshal([]byte(sd.Snapshot), &snap); err != nil {
+		return nil, err
+	}
+	wb := sheet.WorkbookFromSnapshot(snap)
+	if sd.Rev == rev {
+		return wb, nil
+	}
+	opsDB, err := m.store.GetSheetOps(padId, sd.Rev+1, rev)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range *opsDB {
+		var op sheet.Op
+		if err := json.Unmarshal([]byte(o.Op), &op); err != nil {
+			return nil, err
+		}
+		if err := wb.Apply(op); err != nil {
+			return nil, err
+		}
+	}
+	return wb, nil
+}
+
+// Diff compares the workbook at two revisions cell by cell.
+func (m *Manager) Diff(padId string, from, to int) ([]sheet.SheetDiff, error) {
+	fromWb, err := m.WorkbookAt(padId, from)
+	if err != nil {
+		return nil, err
+	}
+	toWb, err := m.WorkbookAt(padId, to)
+	if err != nil {
+		return nil, err
+	}
+	return sheet.DiffWorkbooks(fromWb, toWb), nil
+}
+
+// Restore brings the workbook back to its state at rev by submitting the
+// difference as new ops, so history is kept and connected clients can apply
+// them like any other edit. The author can Undo the restore as one step. It
+// returns the rebased ops and the head after the last one; the op at index i
+// produced revision head-len(ops)+i+1.
+func (m *Manager) Restore(padId string, rev int, authorId *string, tsMillis int64) ([]sheet.Op, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return nil, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	target, err := m.workbookAt(padId, rev, e.doc.Head())
+	if err != nil {
+		return nil, 0, err
+	}
+	ops := sheet.RestoreOps(e.doc.Workbook(), target)
+	// Check the whole restore up front so it is never applied halfway.
+	if authorId != nil {
+		dry := e.doc.Workbook().Clone()
+		for _, op := range ops {
+			if err := dry.Check(op, *authorId); err != nil {
+				return nil, e.doc.Head(), err
+			}
+			if err := dry.Apply(op); err != nil {
+				return nil, e.doc.Head(), err
+			}
+		}
+	}
+	out := make([]sheet.Op, 0, len(ops))
+	var step undoStep
+	defer func() { e.record(authorId, step, false) }()
+	for _, op := range ops {
+		op.BaseRev = e.doc.Head()
+		rebased, _, err := m.submitLocked(padId, e, op, authorId, tsMillis, &step)
+		if err != nil {
+			return out, e.doc.Head(), err
+		}
+		out = append(out, rebased)
+	}
+	return out, e.doc.Head(), nil
+}
@@ -1,6 +1,7 @@
 package sheetdoc
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/ether/etherpad-go/lib/db"
@@ -70,3 +71,27 @@ func TestManagerReloadRebasesStaleOp(t *testing.T) {
 		t.Fatalf("stale op not rebased after reload: %+v", wb.SheetByID(DefaultSheetID).Cells)
 	}
 }
+
+func TestManagerSubmitEnforcesRules(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	owner, other := strptr("a.owner"), strptr("a.other")
+	protect := sheet.Op{Type: sheet.OpSetProtection, Sheet: DefaultSheetID, EndRow: 1, EndCol: 1, Rule: &sheet.Rule{Id: "p", Authors: []string{*owner}}}
+	if _, _, err := m.Submit("p1", protect, owner, 1); err != nil {
+		t.Fatalf("protect: %v", err)
+	}
+	// A stale op is rebased before the check: the inserted row moves the
+	// protection down, so the other author's cell at row 0 is free again.
+	if _, _, err := m.Submit("p1", sheet.Op{Type: sheet.OpInsertRows, Sheet: DefaultSheetID, Index: 0, Count: 1, BaseRev: 1}, other, 2); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, _, err := m.Submit("p1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Raw: strptr("x"), BaseRev: 2}, other, 3); !errors.Is(err, sheet.ErrProtected) {
+		t.Fatalf("protected submit: %v", err)
+	}
+	if _, _, err := m.Submit("p1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Raw: strptr("x"), BaseRev: 2}, other, 4); err != nil {
+		t.Fatalf("free submit: %v", err)
+	}
+	// The server itself (no author) is not bound by protections.
+	if _, head, err := m.Submit("p1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Raw: strptr("y"), BaseRev: 3}, nil, 5); err != nil || head != 4 {
+		t.Fatalf("server submit: %v, head %d", err, head)
+	}
+}
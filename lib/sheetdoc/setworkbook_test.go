@@ -19,7 +19,7 @@ func TestSetWorkbookReplacesStateAndClearsOps(t *testing.T) {
 	// Import a fresh workbook.
 	wb := sheet.NewWorkbook()
 	wb.AddSheet("Imported", "Imported").SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "new"})
-	if err := m.SetWorkbook("p1", wb); err != nil {
+	if err := m.SetWorkbook("p1", wb, nil); err != nil {
 		t.Fatalf("SetWorkbook: %v", err)
 	}
 
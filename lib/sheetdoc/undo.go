@@ -0,0 +1,209 @@
+package sheetdoc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+// MaxUndoSteps is how many steps of an author the undo stack keeps.
+const MaxUndoSteps = 100
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// undoStep is what undoes one submit of an author: the inverse ops, composed
+// one after the other against rev. first is the revision of the submit's
+// first op.
+type undoStep struct {
+	ops   []sheet.Op
+	rev   int
+	first int
+}
+
+// add records the inverse of the op that produced rev. Later ops are undone
+// first, so their inverses go in front.
+func (s *undoStep) add(inverse []sheet.Op, rev int) {
+	s.ops = append(append([]sheet.Op{}, inverse...), s.ops...)
+	if s.first == 0 {
+		s.first = rev
+	}
+	s.rev = rev
+}
+
+// undoStacks are the undo and redo history of one author, most recent last.
+type undoStacks struct {
+	undo []undoStep
+	redo []undoStep
+}
+
+func (e *entry) stacks(authorId string) *undoStacks {
+	if e.undo == nil {
+		e.undo = map[string]*undoStacks{}
+	}
+	s, ok := e.undo[authorId]
+	if !ok {
+		s = &undoStacks{}
+		e.undo[authorId] = s
+	}
+	return s
+}
+
+// record pushes the step of a new edit of authorId, or with join adds it to
+// the author's last step. Either ends the redo history. Edits of the server
+// (nil author) are not undoable.
+func (e *entry) record(authorId *string, step undoStep, join bool) {
+	if authorId == nil || len(step.ops) == 0 {
+		return
+	}
+	s := e.stacks(*authorId)
+	s.redo = nil
+	if n := len(s.undo); join && n > 0 {
+		// The last step is composed against an older revision: bring it up to
+		// the one before this step, which its inverse returns to.
+		last := &s.undo[n-1]
+		last.ops = append(step.ops, rebaseStep(last.ops, e.doc.Log()[last.rev:step.first-1])...)
+		last.rev = step.rev
+		return
+	}
+	s.undo = push(s.undo, step)
+}
+
+func push(stack []undoStep, step undoStep) []undoStep {
+	stack = append(stack, step)
+	if len(stack) > MaxUndoSteps {
+		stack = stack[len(stack)-MaxUndoSteps:]
+	}
+	return stack
+}
+
+// Undo reverts the most recent submit (op, batch or restore) of authorId that
+// is not undone yet. Its inverse ops are transformed past every op applied
+// since, so the edits of other authors are kept; a cell another author
+// deleted the row or column of stays deleted. Steps nothing is left of are
+// skipped. The inverse is checked like the author's own ops and applied as a
+// whole or not at all. It returns the rebased ops and the head after the last
+// one, like SubmitBatch.
+func (m *Manager) Undo(padId, authorId string, tsMillis int64) ([]sheet.Op, int, error) {
+	return m.replay(padId, authorId, tsMillis, false)
+}
+
+// Redo reverts the most recent Undo of authorId. A new edit of the author
+// since that undo ends the redo history.
+func (m *Manager) Redo(padId, authorId string, tsMillis int64) ([]sheet.Op, int, error) {
+	return m.replay(padId, authorId, tsMillis, true)
+}
+
+// UndoDepth returns how many steps authorId can undo and redo.
+func (m *Manager) UndoDepth(padId, authorId string) (int, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return 0, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.stacks(authorId)
+	return len(s.undo), len(s.redo), nil
+}
+
+func (m *Manager) replay(padId, authorId string, tsMillis int64, redo bool) ([]sheet.Op, int, error) {
+	e, err := m.load(padId)
+	if err != nil {
+		return nil, 0, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.stacks(authorId)
+	from, nothing := &s.undo, ErrNothingToUndo
+	if redo {
+		from, nothing = &s.redo, ErrNothingToRedo
+	}
+
+	for len(*from) > 0 {
+		step := (*from)[len(*from)-1]
+		pending := rebaseStep(step.ops, e.doc.Log()[step.rev:e.doc.Head()])
+
+		dry := e.doc.Workbook().Clone()
+		for i, op := range pending {
+			if err := dry.Check(op, authorId); err != nil {
+				return nil, e.doc.Head(), fmt.Errorf("op %d: %w", i, err)
+			}
+			if err := dry.Apply(op); err != nil {
+				return nil, e.doc.Head(), fmt.Errorf("%w: op %d: %v", ErrInvalidOp, i, err)
+			}
+		}
+		*from = (*from)[:len(*from)-1]
+		if len(pending) == 0 {
+			continue
+		}
+
+		out := make([]sheet.Op, 0, len(pending))
+		var inverse undoStep
+		for _, op := range pending {
+			op.BaseRev = e.doc.Head()
+			rebased, _, err := m.submitLocked(padId, e, op, &authorId, tsMillis, &inverse)
+			if err != nil {
+				return out, e.doc.Head(), err
+			}
+			out = append(out, rebased)
+		}
+		if len(inverse.ops) > 0 {
+			if redo {
+				s.undo = push(s.undo, inverse)
+			} else {
+				s.redo = push(s.redo, inverse)
+			}
+		}
+		return out, e.doc.Head(), nil
+	}
+	return nil, e.doc.Head(), nothing
+}
+
+// rebaseStep transforms the ops of an undo step past the ops applied since,
+// see rebase. Cell writes into rows or columns deleted meanwhile are dropped
+// instead of clamped onto their neighbours.
+func rebaseStep(ops, applied []sheet.Op) []sheet.Op {
+	return rebase(ops, applied, true)
+}
+
+// rebase transforms ops, composed one after the other, past the ops applied
+// since. Each applied op is moved past the earlier ops too before it is
+// compared with the later ones, which are composed against those: the insert
+// that undoes a row delete makes room before the restored cells are compared
+// with later edits. With dropDeleted, cell writes into rows or columns an
+// applied op deleted are dropped.
+func rebase(ops, applied []sheet.Op, dropDeleted bool) []sheet.Op {
+	pending := append([]sheet.Op{}, ops...)
+	for _, a := range applied {
+		next := pending[:0]
+		for _, op := range pending {
+			if dropDeleted && deletedBy(op, a) {
+				continue
+			}
+			next = append(next, sheet.Transform(op, a))
+			a = sheet.Transform(a, op)
+		}
+		pending = next
+	}
+	return pending
+}
+
+// deletedBy reports whether applied deletes the cell a setCell or setStyle
+// writes.
+func deletedBy(op, applied sheet.Op) bool {
+	if op.Type != sheet.OpSetCell && op.Type != sheet.OpSetStyle || op.Sheet != applied.Sheet {
+		return false
+	}
+	switch applied.Type {
+	case sheet.OpDeleteRows:
+		return op.Row >= applied.Index && op.Row < applied.Index+applied.Count
+	case sheet.OpDeleteCols:
+		return op.Col >= applied.Index && op.Col < applied.Index+applied.Count
+	case sheet.OpDeleteSheet:
+		return true
+	}
+	return false
+}
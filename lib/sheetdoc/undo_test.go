@@ -0,0 +1,198 @@
+package sheetdoc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func cellAt(t *testing.T, m *Manager, row, col int) string {
+	t.Helper()
+	snap, _, err := m.Snapshot("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sheet.WorkbookFromSnapshot(snap).SheetByID(DefaultSheetID).GetCell(sheet.CellRef{Row: row, Col: col}).Raw
+}
+
+func submitAs(t *testing.T, m *Manager, author string, op sheet.Op) int {
+	t.Helper()
+	_, head, _ := m.Snapshot("p1")
+	op.BaseRev = head
+	_, rev, err := m.Submit("p1", op, &author, int64(head))
+	if err != nil {
+		t.Fatalf("submit %+v: %v", op, err)
+	}
+	return rev
+}
+
+func TestManagerUndoDeleteRowsAfterOtherEdits(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	for row, raw := range []string{"one", "two", "three"} {
+		if _, _, err := m.Submit("p1", setCell(row, row, raw), nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpSetDimension, Sheet: DefaultSheetID, Axis: "row", Index: 1, Size: 40})
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpDeleteRows, Sheet: DefaultSheetID, Index: 1, Count: 1})
+	// B edits the row that moved up and inserts a row above everything.
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Col: 1, Raw: strptr("b")})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpInsertRows, Sheet: DefaultSheetID, Index: 0, Count: 1})
+
+	ops, head, err := m.Undo("p1", "a.1", 9)
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if len(ops) == 0 || ops[0].Type != sheet.OpInsertRows || ops[0].Index != 2 || ops[len(ops)-1].BaseRev != head-1 {
+		t.Fatalf("undo ops %+v at head %d", ops, head)
+	}
+	if got := cellAt(t, m, 2, 0); got != "two" {
+		t.Fatalf("restored row = %q", got)
+	}
+	if cellAt(t, m, 1, 0) != "one" || cellAt(t, m, 3, 0) != "three" || cellAt(t, m, 3, 1) != "b" {
+		t.Fatalf("neighbours moved: %q %q %q", cellAt(t, m, 1, 0), cellAt(t, m, 3, 0), cellAt(t, m, 3, 1))
+	}
+	snap, _, _ := m.Snapshot("p1")
+	if h := sheet.WorkbookFromSnapshot(snap).SheetByID(DefaultSheetID).RowHeights[2]; h != 40 {
+		t.Fatalf("row height = %d", h)
+	}
+	if undo, redo, _ := m.UndoDepth("p1", "a.1"); undo != 1 || redo != 1 {
+		t.Fatalf("depth %d/%d", undo, redo)
+	}
+}
+
+func TestManagerUndoClearRangeKeepsOtherEdits(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Col: 0, Raw: strptr("x"), Props: map[string]string{"bold": "1"}})
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Col: 1, Raw: strptr("y")})
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpClearRange, Sheet: DefaultSheetID, Row: 0, Col: 0, EndRow: 1, EndCol: 1})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Col: 0, Raw: strptr("b")})
+
+	if _, _, err := m.Undo("p1", "a.1", 9); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if cellAt(t, m, 0, 0) != "x" || cellAt(t, m, 0, 1) != "y" || cellAt(t, m, 1, 0) != "b" {
+		t.Fatalf("after undo: %q %q %q", cellAt(t, m, 0, 0), cellAt(t, m, 0, 1), cellAt(t, m, 1, 0))
+	}
+	snap, _, _ := m.Snapshot("p1")
+	wb := sheet.WorkbookFromSnapshot(snap)
+	if style, _ := wb.Styles.Get(wb.SheetByID(DefaultSheetID).GetCell(sheet.CellRef{}).StyleId); style.Props["bold"] != "1" {
+		t.Fatalf("style not restored: %v", style.Props)
+	}
+}
+
+func TestManagerUndoSkipsCellsOthersDeleted(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	if _, _, err := m.Submit("p1", setCell(0, 3, "below"), nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 2, Col: 0, Raw: strptr("mine")})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpDeleteRows, Sheet: DefaultSheetID, Index: 2, Count: 1})
+
+	if _, _, err := m.Undo("p1", "a.1", 9); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("expected ErrNothingToUndo, got %v", err)
+	}
+	if got := cellAt(t, m, 2, 0); got != "below" {
+		t.Fatalf("undo wrote into the row below: %q", got)
+	}
+}
+
+func TestManagerUndoIsPerAuthor(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Col: 0, Raw: strptr("a")})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Col: 1, Raw: strptr("b")})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpAddSheet, Sheet: "s2", Name: "B", Index: 1})
+
+	if _, _, err := m.Undo("p1", "a.1", 9); err != nil {
+		t.Fatal(err)
+	}
+	if cellAt(t, m, 0, 0) != "" || cellAt(t, m, 0, 1) != "b" {
+		t.Fatalf("after undo of a.1: %q %q", cellAt(t, m, 0, 0), cellAt(t, m, 0, 1))
+	}
+	if _, _, err := m.Undo("p1", "a.1", 10); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("second undo: %v", err)
+	}
+	if _, _, err := m.Undo("p1", "a.3", 10); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("undo of an author without edits: %v", err)
+	}
+}
+
+func TestManagerRedo(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	author := "a.1"
+	batch := []sheet.Op{
+		{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Raw: strptr("1")},
+		{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Raw: strptr("2")},
+	}
+	if _, _, err := m.SubmitBatch("p1", batch, 0, &author, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Redo("p1", author, 2); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("redo before undo: %v", err)
+	}
+	// The batch is one step.
+	if _, _, err := m.Undo("p1", author, 2); err != nil {
+		t.Fatal(err)
+	}
+	if cellAt(t, m, 0, 0) != "" || cellAt(t, m, 1, 0) != "" {
+		t.Fatalf("batch not undone")
+	}
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpInsertRows, Sheet: DefaultSheetID, Index: 0, Count: 1})
+	if _, _, err := m.Redo("p1", author, 3); err != nil {
+		t.Fatal(err)
+	}
+	if cellAt(t, m, 1, 0) != "1" || cellAt(t, m, 2, 0) != "2" {
+		t.Fatalf("redo not rebased past the insert: %q %q", cellAt(t, m, 1, 0), cellAt(t, m, 2, 0))
+	}
+	if _, _, err := m.Undo("p1", author, 4); err != nil {
+		t.Fatal(err)
+	}
+	// A new edit ends the redo history.
+	submitAs(t, m, author, sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 5, Raw: strptr("new")})
+	if _, _, err := m.Redo("p1", author, 5); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("redo after a new edit: %v", err)
+	}
+}
+
+func TestManagerUndoChecksProtection(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	submitAs(t, m, "a.1", sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Col: 0, Raw: strptr("a")})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpSetProtection, Sheet: DefaultSheetID, Rule: &sheet.Rule{Id: "p", Authors: []string{"a.2"}}})
+
+	_, head, _ := m.Snapshot("p1")
+	if _, got, err := m.Undo("p1", "a.1", 9); !errors.Is(err, sheet.ErrProtected) || got != head {
+		t.Fatalf("expected ErrProtected at head %d, got %v at %d", head, err, got)
+	}
+	if cellAt(t, m, 0, 0) != "a" {
+		t.Fatalf("protected cell changed")
+	}
+}
+
+func TestManagerSubmitStepJoinsUndoSteps(t *testing.T) {
+	m := NewManager(db.NewMemoryDataStore())
+	author := "a.1"
+	submitAs(t, m, author, sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 0, Col: 0, Raw: strptr("first")})
+	// One paste sent op by op, with another author's row insert in between.
+	submitAs(t, m, author, sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 1, Col: 1, Raw: strptr("p1")})
+	submitAs(t, m, "a.2", sheet.Op{Type: sheet.OpInsertRows, Sheet: DefaultSheetID, Index: 0, Count: 1})
+	_, head, _ := m.Snapshot("p1")
+	op := sheet.Op{Type: sheet.OpSetCell, Sheet: DefaultSheetID, Row: 3, Col: 1, Raw: strptr("p2"), BaseRev: head}
+	if _, _, err := m.SubmitStep("p1", op, &author, 5, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := m.Undo("p1", author, 6); err != nil {
+		t.Fatal(err)
+	}
+	if cellAt(t, m, 2, 1) != "" || cellAt(t, m, 3, 1) != "" || cellAt(t, m, 1, 0) != "first" {
+		t.Fatalf("after undo: %q %q %q", cellAt(t, m, 2, 1), cellAt(t, m, 3, 1), cellAt(t, m, 1, 0))
+	}
+	if _, _, err := m.Undo("p1", author, 7); err != nil {
+		t.Fatal(err)
+	}
+	if cellAt(t, m, 1, 0) != "" {
+		t.Fatalf("first step not undone")
+	}
+}
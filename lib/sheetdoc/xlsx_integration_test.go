@@ -49,7 +49,7 @@ func TestXlsxImportExportThroughManager(t *testing.T) {
 		t.Fatalf("Import: %v", err)
 	}
 	m := NewManager(store)
-	if err := m.SetWorkbook("p1", sheet.WorkbookFromSnapshot(snap)); err != nil {
+	if err := m.SetWorkbook("p1", sheet.WorkbookFromSnapshot(snap), nil); err != nil {
 		t.Fatalf("SetWorkbook: %v", err)
 	}
 
@@ -0,0 +1,252 @@
+package sheettext
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrNoTable is returned by FromHTML for markup without a table.
+var ErrNoTable = errors.New("no table found")
+
+// maxSpan bounds colspan and rowspan like browsers do.
+const maxSpan = 1000
+
+var textAlignRe = regexp.MustCompile(`(?i)text-align\s*:\s*(left|center|right)`)
+
+// IsHTMLTable reports whether text looks like markup holding a table, as
+// opposed to delimited lines.
+func IsHTMLTable(text string) bool {
+	return strings.Contains(strings.ToLower(text), "<table")
+}
+
+// FromHTML reads every top-level table of an HTML document into a sheet of a
+// new workbook, named after the table caption or Sheet1, Sheet2, ... Header
+// cells and b/strong are bold, i/em italic, u underline and s/strike/del
+// struck through when they cover the whole cell text; the align attribute or
+// a text-align style of the cell or its row sets the alignment. colspan and
+// rowspan become merges.
+func FromHTML(src string) (*sheet.Workbook, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	var tables []*html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Table {
+			tables = append(tables, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if len(tables) == 0 {
+		return nil, ErrNoTable
+	}
+
+	wb := sheet.NewWorkbook()
+	names := map[string]bool{}
+	for n, table := range tables {
+		name := sheetName(table, n+1)
+		for k := 2; names[name]; k++ {
+			name = sheetName(table, n+1) + " (" + strconv.Itoa(k) + ")"
+		}
+		names[name] = true
+		readTable(wb, wb.AddSheet("s"+strconv.Itoa(n+1), name), table)
+	}
+	return wb, nil
+}
+
+// sheetName is the caption of a table, else "Sheet<n>".
+func sheetName(table *html.Node, n int) string {
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Caption {
+			if name := strings.Join(strings.Fields(textOf(c)), " "); name != "" {
+				return name
+			}
+		}
+	}
+	return "Sheet" + strconv.Itoa(n)
+}
+
+func readTable(wb *sheet.Workbook, sh *sheet.Sheet, table *html.Node) {
+	// taken marks the cells covered by a rowspan from a row above.
+	taken := map[sheet.CellRef]bool{}
+	r := 0
+	for _, tr := range rowsOf(table) {
+		rowAlign := alignOf(tr)
+		c := 0
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.Type != html.ElementNode || td.DataAtom != atom.Td && td.DataAtom != atom.Th {
+				continue
+			}
+			for taken[sheet.CellRef{Row: r, Col: c}] {
+				c++
+			}
+			ref := sheet.CellRef{Row: r, Col: c}
+			align := alignOf(td)
+			if align == "" {
+				align = rowAlign
+			}
+			var attrs map[string]string
+			if td.DataAtom == atom.Th {
+				attrs = map[string]string{"bold": "true"}
+			}
+			var f field
+			collect(td, &f, attrs, align)
+			setCell(wb, sh, ref, f)
+
+			rows, cols := span(td, "rowspan"), span(td, "colspan")
+			if rows > 1 || cols > 1 {
+				sh.Merges[ref] = sheet.Span{Rows: rows, Cols: cols}
+			}
+			for dr := 0; dr < rows; dr++ {
+				for dc := 0; dc < cols; dc++ {
+					taken[sheet.CellRef{Row: r + dr, Col: c + dc}] = true
+				}
+			}
+			c += cols
+		}
+		r++
+	}
+}
+
+// rowsOf returns the tr elements of table, in thead, tbody and tfoot or
+// directly below it, but not those of nested tables.
+func rowsOf(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Tr:
+			rows = append(rows, c)
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			for tr := c.FirstChild; tr != nil; tr = tr.NextSibling {
+				if tr.Type == html.ElementNode && tr.DataAtom == atom.Tr {
+					rows = append(rows, tr)
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// collect appends the text below n to f with the character attributes it is
+// shown with, collapsing white space like a browser. br and the end of a
+// block element start a new line.
+func collect(n *html.Node, f *field, attrs map[string]string, align string) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			for _, r := range c.Data {
+				if unicode.IsSpace(r) {
+					if len(f.runes) == 0 || unicode.IsSpace(f.runes[len(f.runes)-1].r) {
+						continue
+					}
+					r = ' '
+				}
+				f.runes = append(f.runes, styledRune{r: r, attrs: attrs, align: align})
+			}
+		case html.ElementNode:
+			switch c.DataAtom {
+			case atom.Br:
+				newLine(f, align)
+				continue
+			case atom.Script, atom.Style:
+				continue
+			}
+			inner := attrs
+			if key := inlineAttr[c.DataAtom]; key != "" {
+				inner = make(map[string]string, len(attrs)+1)
+				for k, v := range attrs {
+					inner[k] = v
+				}
+				inner[key] = "true"
+			}
+			collect(c, f, inner, align)
+			switch c.DataAtom {
+			case atom.P, atom.Div, atom.Li, atom.Table, atom.Tr:
+				newLine(f, align)
+			}
+		}
+	}
+}
+
+// newLine ends the current line of f unless it is empty.
+func newLine(f *field, align string) {
+	for len(f.runes) > 0 && f.runes[len(f.runes)-1].r == ' ' {
+		f.runes = f.runes[:len(f.runes)-1]
+	}
+	if len(f.runes) > 0 && f.runes[len(f.runes)-1].r != '\n' {
+		f.runes = append(f.runes, styledRune{r: '\n', align: align})
+	}
+}
+
+// inlineAttr maps formatting elements onto pad attributes.
+var inlineAttr = map[atom.Atom]string{
+	atom.B:      "bold",
+	atom.Strong: "bold",
+	atom.I:      "italic",
+	atom.Em:     "italic",
+	atom.U:      "underline",
+	atom.Ins:    "underline",
+	atom.S:      "strikethrough",
+	atom.Strike: "strikethrough",
+	atom.Del:    "strikethrough",
+}
+
+func attrOf(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// alignOf returns the alignment set on an element, or "".
+func alignOf(n *html.Node) string {
+	if m := textAlignRe.FindStringSubmatch(attrOf(n, "style")); m != nil {
+		return strings.ToLower(m[1])
+	}
+	switch a := strings.ToLower(strings.TrimSpace(attrOf(n, "align"))); a {
+	case "left", "center", "right":
+		return a
+	}
+	return ""
+}
+
+// span returns the rowspan or colspan of a cell, 1 when unset or invalid.
+func span(n *html.Node, key string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(attrOf(n, key)))
+	if err != nil || v < 1 {
+		return 1
+	}
+	return min(v, maxSpan)
+}
+
+func textOf(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
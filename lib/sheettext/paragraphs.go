@@ -0,0 +1,292 @@
+// Package sheettext converts between sheets and text pads: a sheet range
+// becomes one pad line per row with the cells separated by a delimiter, and
+// the delimited lines or HTML tables of a pad become a workbook. Bold,
+// italic, underline, strikethrough and alignment carry over between the pad
+// attribute pool and the sheet StylePool.
+package sheettext
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/io"
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/sheetcsv"
+)
+
+// DefaultDelimiter separates the cells of a row in a pad. Pads turn tabs into
+// spaces, so tab-separated lines would not survive a round trip.
+const DefaultDelimiter = '|'
+
+// ErrEmpty is returned when there are no cells to convert.
+var ErrEmpty = errors.New("nothing to convert")
+
+// Options controls the delimiter of the pad lines.
+type Options struct {
+	// Delimiter separates cells; 0 means DefaultDelimiter when writing and
+	// picks the most consistent of , ; tab | when reading.
+	Delimiter rune
+}
+
+// ParseDelimiter accepts a single character, or "tab" / "\t" for a tab.
+func ParseDelimiter(s string) (rune, error) {
+	if s == "tab" || s == `\t` {
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || !sheetcsv.ValidDelimiter(r) {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r, nil
+}
+
+// flags maps the on/off sheet style props onto their pad attributes.
+var flags = []struct{ prop, attr string }{
+	{"bold", "bold"},
+	{"italic", "italic"},
+	{"underline", "underline"},
+	{"strike", "strikethrough"},
+}
+
+// UsedRange returns the smallest range holding every non-empty cell of sh;
+// ok is false when the sheet has none.
+func UsedRange(sh *sheet.Sheet) (r0, c0, r1, c1 int, ok bool) {
+	for ref, c := range sh.Cells {
+		if c.Raw == "" && c.Value == "" {
+			continue
+		}
+		if !ok {
+			r0, c0, r1, c1, ok = ref.Row, ref.Col, ref.Row, ref.Col, true
+			continue
+		}
+		r0, c0 = min(r0, ref.Row), min(c0, ref.Col)
+		r1, c1 = max(r1, ref.Row), max(c1, ref.Col)
+	}
+	return r0, c0, r1, c1, ok
+}
+
+// ToParagraphs writes a range of sh as one paragraph per row. Cells show what
+// the grid shows (see sheetcsv.CellText) and are quoted like CSV fields when
+// they contain the delimiter, a quote or a line break. A cell's bold, italic,
+// underline and strike become run attributes; a row whose non-empty cells
+// share an alignment gets it as line alignment.
+func ToParagraphs(wb *sheet.Workbook, sh *sheet.Sheet, r0, c0, r1, c1 int, opts Options) ([]io.RichParagraph, error) {
+	delim := opts.Delimiter
+	if delim == 0 {
+		delim = DefaultDelimiter
+	}
+	if !sheetcsv.ValidDelimiter(delim) {
+		return nil, fmt.Errorf("invalid delimiter %q", delim)
+	}
+	sep := string(delim)
+	if delim == DefaultDelimiter {
+		sep = " | "
+	}
+
+	paragraphs := make([]io.RichParagraph, 0, r1-r0+1)
+	for r := r0; r <= r1; r++ {
+		var para io.RichParagraph
+		align, seen := "", false
+		for c := c0; c <= c1; c++ {
+			if c > c0 {
+				para.Runs = append(para.Runs, io.RichRun{Text: sep})
+			}
+			cell := sh.GetCell(sheet.CellRef{Row: r, Col: c})
+			text := sheetcsv.CellText(cell, wb.Styles, false)
+			if text == "" {
+				continue
+			}
+			if strings.ContainsRune(text, delim) || strings.ContainsAny(text, "\"\r\n") || strings.TrimSpace(text) != text {
+				text = `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+			}
+			style, _ := wb.Styles.Get(cell.StyleId)
+			var attrs map[string]string
+			for _, f := range flags {
+				if style.Props[f.prop] == "1" {
+					if attrs == nil {
+						attrs = make(map[string]string)
+					}
+					attrs[f.attr] = "true"
+				}
+			}
+			para.Runs = append(para.Runs, io.RichRun{Text: text, Attrs: attrs})
+
+			if a := style.Props["align"]; !seen {
+				align, seen = a, true
+			} else if a != align {
+				align = ""
+			}
+		}
+		para.Align = align
+		paragraphs = append(paragraphs, mergeRuns(para))
+	}
+	return paragraphs, nil
+}
+
+// mergeRuns joins neighbouring runs without attributes, so a row of plain
+// cells is a single run.
+func mergeRuns(para io.RichParagraph) io.RichParagraph {
+	runs := para.Runs[:0]
+	for _, run := range para.Runs {
+		if n := len(runs); n > 0 && len(run.Attrs) == 0 && len(runs[n-1].Attrs) == 0 {
+			runs[n-1].Text += run.Text
+			continue
+		}
+		runs = append(runs, run)
+	}
+	para.Runs = runs
+	return para
+}
+
+// styledRune is one character of the pad with the attributes it is shown
+// with.
+type styledRune struct {
+	r     rune
+	attrs map[string]string
+	align string
+}
+
+// field is one parsed cell: its characters and whether it was quoted.
+type field struct {
+	runes  []styledRune
+	quoted bool
+}
+
+// FromParagraphs reads the lines of a text pad as delimited text into a new
+// workbook with a single sheet, inferring numbers and dates like the CSV
+// import. Unquoted cells are trimmed. A cell is bold (italic, ...) when all
+// of its text is, and takes the alignment of its line.
+func FromParagraphs(paragraphs []io.RichParagraph, opts Options) (*sheet.Workbook, error) {
+	var text []styledRune
+	var plain strings.Builder
+	for n, para := range paragraphs {
+		if n > 0 {
+			text = append(text, styledRune{r: '\n'})
+			plain.WriteByte('\n')
+		}
+		for _, run := range para.Runs {
+			for _, r := range run.Text {
+				text = append(text, styledRune{r: r, attrs: run.Attrs, align: para.Align})
+			}
+			plain.WriteString(run.Text)
+		}
+	}
+	if strings.TrimSpace(plain.String()) == "" {
+		return nil, ErrEmpty
+	}
+	delim := opts.Delimiter
+	if delim == 0 {
+		delim = sheetcsv.DetectDelimiter(plain.String())
+	}
+	if !sheetcsv.ValidDelimiter(delim) {
+		return nil, fmt.Errorf("invalid delimiter %q", delim)
+	}
+
+	wb := sheet.NewWorkbook()
+	sh := wb.AddSheet("s1", "Sheet1")
+	for r, record := range splitRecords(text, delim) {
+		for c, f := range record {
+			setCell(wb, sh, sheet.CellRef{Row: r, Col: c}, f)
+		}
+	}
+	return wb, nil
+}
+
+// splitRecords splits text into lines of fields like encoding/csv with lazy
+// quotes: a field starting with a quote runs to the next lone quote and may
+// span lines, "" inside it is a literal quote.
+func splitRecords(text []styledRune, delim rune) [][]field {
+	var records [][]field
+	var record []field
+	var cur field
+	start, inQuotes := true, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case inQuotes && ch.r == '"':
+			if i+1 < len(text) && text[i+1].r == '"' {
+				cur.runes = append(cur.runes, ch)
+				i++
+			} else {
+				inQuotes = false
+			}
+		case inQuotes:
+			cur.runes = append(cur.runes, ch)
+		case ch.r == delim:
+			record = append(record, cur)
+			cur, start = field{}, true
+		case ch.r == '\n':
+			records = append(records, append(record, cur))
+			record, cur, start = nil, field{}, true
+		case start && ch.r == '"':
+			cur, inQuotes, start = field{quoted: true}, true, false
+		case (start || cur.quoted) && ch.r != '\r' && unicode.IsSpace(ch.r):
+			// Blanks around a quoted field are dropped with its quotes.
+			if !cur.quoted {
+				cur.runes = append(cur.runes, ch)
+			}
+		default:
+			cur.runes = append(cur.runes, ch)
+			start = false
+		}
+	}
+	return append(records, append(record, cur))
+}
+
+func setCell(wb *sheet.Workbook, sh *sheet.Sheet, ref sheet.CellRef, f field) {
+	runes := f.runes
+	if !f.quoted {
+		for len(runes) > 0 && unicode.IsSpace(runes[0].r) {
+			runes = runes[1:]
+		}
+		for len(runes) > 0 && unicode.IsSpace(runes[len(runes)-1].r) {
+			runes = runes[:len(runes)-1]
+		}
+	}
+	var sb strings.Builder
+	for _, ch := range runes {
+		if ch.r != '\r' {
+			sb.WriteRune(ch.r)
+		}
+	}
+	raw, numFmt := sheetcsv.Infer(sb.String())
+	if raw == "" {
+		return
+	}
+
+	props := map[string]string{}
+	if numFmt != "" {
+		props["numFmt"] = numFmt
+	}
+	for _, f := range flags {
+		if covers(runes, f.attr) {
+			props[f.prop] = "1"
+		}
+	}
+	if a := runes[0].align; a == "left" || a == "center" || a == "right" {
+		props["align"] = a
+	}
+	cell := sheet.Cell{Raw: raw}
+	if len(props) > 0 {
+		cell.StyleId = wb.Styles.Put(sheet.Style{Props: props})
+	}
+	sh.SetCell(ref, cell)
+}
+
+// covers reports whether every non-blank character has attribute attr set.
+func covers(runes []styledRune, attr string) bool {
+	seen := false
+	for _, ch := range runes {
+		if unicode.IsSpace(ch.r) {
+			continue
+		}
+		if ch.attrs[attr] != "true" {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
@@ -0,0 +1,175 @@
+package sheettext
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/io"
+	"github.com/ether/etherpad-go/lib/sheet"
+)
+
+func props(wb *sheet.Workbook, sh *sheet.Sheet, row, col int) map[string]string {
+	style, _ := wb.Styles.Get(sh.GetCell(sheet.CellRef{Row: row, Col: col}).StyleId)
+	return style.Props
+}
+
+func testWorkbook() (*sheet.Workbook, *sheet.Sheet) {
+	wb := sheet.NewWorkbook()
+	sh := wb.AddSheet("s1", "Sheet1")
+	bold := wb.Styles.Put(sheet.Style{Props: map[string]string{"bold": "1", "align": "center"}})
+	center := wb.Styles.Put(sheet.Style{Props: map[string]string{"align": "center"}})
+	sh.SetCell(sheet.CellRef{Row: 1, Col: 1}, sheet.Cell{Raw: "Name", StyleId: bold})
+	sh.SetCell(sheet.CellRef{Row: 1, Col: 2}, sheet.Cell{Raw: "Qty", StyleId: center})
+	sh.SetCell(sheet.CellRef{Row: 2, Col: 1}, sheet.Cell{Raw: "a|b"})
+	sh.SetCell(sheet.CellRef{Row: 2, Col: 2}, sheet.Cell{Raw: "=B3*2", Value: "84"})
+	sh.SetCell(sheet.CellRef{Row: 3, Col: 2}, sheet.Cell{Raw: "7"})
+	return wb, sh
+}
+
+func TestUsedRange(t *testing.T) {
+	_, sh := testWorkbook()
+	r0, c0, r1, c1, ok := UsedRange(sh)
+	if !ok || r0 != 1 || c0 != 1 || r1 != 3 || c1 != 2 {
+		t.Fatalf("got %d,%d:%d,%d %v", r0, c0, r1, c1, ok)
+	}
+	if _, _, _, _, ok := UsedRange(sheet.NewSheet("s2", "Empty")); ok {
+		t.Fatalf("empty sheet has a used range")
+	}
+}
+
+func TestToParagraphs(t *testing.T) {
+	wb, sh := testWorkbook()
+	got, err := ToParagraphs(wb, sh, 1, 1, 3, 2, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []io.RichParagraph{
+		{Align: "center", Runs: []io.RichRun{{Text: "Name", Attrs: map[string]string{"bold": "true"}}, {Text: " | Qty"}}},
+		{Runs: []io.RichRun{{Text: `"a|b" | 84`}}},
+		{Runs: []io.RichRun{{Text: " | 7"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if _, err := ToParagraphs(wb, sh, 1, 1, 3, 2, Options{Delimiter: '"'}); err == nil {
+		t.Fatalf("quote accepted as delimiter")
+	}
+}
+
+func TestParagraphsRoundTrip(t *testing.T) {
+	wb, sh := testWorkbook()
+	paragraphs, err := ToParagraphs(wb, sh, 1, 1, 3, 2, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromParagraphs(paragraphs, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := back.Sheets[0]
+	raws := map[sheet.CellRef]string{}
+	for ref, c := range out.Cells {
+		raws[ref] = c.Raw
+	}
+	wantRaws := map[sheet.CellRef]string{{Row: 0, Col: 0}: "Name", {Row: 0, Col: 1}: "Qty", {Row: 1, Col: 0}: "a|b", {Row: 1, Col: 1}: "84", {Row: 2, Col: 1}: "7"}
+	if !reflect.DeepEqual(raws, wantRaws) {
+		t.Fatalf("raws %v, want %v", raws, wantRaws)
+	}
+	if p := props(back, out, 0, 0); p["bold"] != "1" || p["align"] != "center" {
+		t.Fatalf("header props %v", p)
+	}
+	if p := props(back, out, 0, 1); p["bold"] != "" || p["align"] != "center" {
+		t.Fatalf("Qty props %v", p)
+	}
+}
+
+func TestFromParagraphs(t *testing.T) {
+	bold := map[string]string{"bold": "true"}
+	wb, err := FromParagraphs([]io.RichParagraph{
+		{Runs: []io.RichRun{{Text: "when, "}, {Text: "total", Attrs: bold}, {Text: " ,note"}}},
+		{Align: "right", Runs: []io.RichRun{{Text: "2024-03-01, $12.50, \"multi"}}},
+		{Runs: []io.RichRun{{Text: "line\", "}, {Text: "part", Attrs: bold}, {Text: "ly"}}},
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := wb.Sheets[0]
+	cells := map[string]string{}
+	for _, ref := range []sheet.CellRef{{Row: 0, Col: 0}, {Row: 0, Col: 1}, {Row: 1, Col: 0}, {Row: 1, Col: 2}, {Row: 1, Col: 3}} {
+		cells[sheet.RangeName(ref.Row, ref.Col, ref.Row, ref.Col)] = sh.GetCell(ref).Raw
+	}
+	want := map[string]string{"A1": "when", "B1": "total", "A2": "45352", "C2": "multi\nline", "D2": "partly"}
+	if !reflect.DeepEqual(cells, want) {
+		t.Fatalf("got %v, want %v", cells, want)
+	}
+	if p := props(wb, sh, 0, 1); p["bold"] != "1" {
+		t.Fatalf("B1 props %v", p)
+	}
+	if p := props(wb, sh, 1, 0); p["numFmt"] != "date" || p["align"] != "right" {
+		t.Fatalf("A2 props %v", p)
+	}
+	if p := props(wb, sh, 1, 3); p["bold"] != "" {
+		t.Fatalf("partly bold cell is bold: %v", p)
+	}
+
+	if _, err := FromParagraphs([]io.RichParagraph{{Runs: []io.RichRun{{Text: "  "}}}}, Options{}); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("blank pad: %v", err)
+	}
+}
+
+func TestFromHTML(t *testing.T) {
+	wb, err := FromHTML(`<p>intro</p>
+<table>
+  <caption>Sales</caption>
+  <thead><tr><th>Item</th><th colspan="2">Price</th></tr></thead>
+  <tbody>
+    <tr align="right"><td rowspan="2"><b>Tea</b></td><td>1.5</td><td style="text-align: center"><i>x</i> y</td></tr>
+    <tr><td>two<br>lines</td></tr>
+  </tbody>
+</table>
+<table><tr><td>other</td></tr></table>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wb.Sheets) != 2 || wb.Sheets[0].Name != "Sales" || wb.Sheets[1].Name != "Sheet2" {
+		t.Fatalf("sheets %+v", wb.Sheets)
+	}
+	sh := wb.Sheets[0]
+	raw := func(row, col int) string { return sh.GetCell(sheet.CellRef{Row: row, Col: col}).Raw }
+	if raw(0, 0) != "Item" || raw(0, 1) != "Price" || raw(1, 0) != "Tea" || raw(1, 1) != "1.5" || raw(1, 2) != "x y" || raw(2, 1) != "two\nlines" {
+		t.Fatalf("cells %v", sh.Cells)
+	}
+	if got := sh.Merges; !reflect.DeepEqual(got, map[sheet.CellRef]sheet.Span{{Row: 0, Col: 1}: {Rows: 1, Cols: 2}, {Row: 1, Col: 0}: {Rows: 2, Cols: 1}}) {
+		t.Fatalf("merges %v", got)
+	}
+	if p := props(wb, sh, 0, 0); p["bold"] != "1" {
+		t.Fatalf("th props %v", p)
+	}
+	if p := props(wb, sh, 1, 0); p["bold"] != "1" || p["align"] != "right" {
+		t.Fatalf("Tea props %v", p)
+	}
+	if p := props(wb, sh, 1, 2); p["italic"] != "" || p["align"] != "center" {
+		t.Fatalf("mixed cell props %v", p)
+	}
+
+	if _, err := FromHTML("<p>no table</p>"); !errors.Is(err, ErrNoTable) {
+		t.Fatalf("want ErrNoTable, got %v", err)
+	}
+	if !IsHTMLTable("x\n<TABLE>") || IsHTMLTable("a|b") {
+		t.Fatalf("IsHTMLTable")
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	for in, want := range map[string]rune{",": ',', "tab": '\t', `\t`: '\t', "|": '|'} {
+		if got, err := ParseDelimiter(in); err != nil || got != want {
+			t.Fatalf("ParseDelimiter(%q) = %q, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", `"`, ";;"} {
+		if _, err := ParseDelimiter(in); err == nil {
+			t.Fatalf("ParseDelimiter(%q) accepted", in)
+		}
+	}
+}
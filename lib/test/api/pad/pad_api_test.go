@@ -131,6 +131,18 @@ func TestPadAPI(t *testing.T) {
 			Name: "GetChatHistory returns messages",
 			Test: testGetChatHistory,
 		},
+		testutils.TestRunConfig{
+			Name: "EditChatMessage keeps the edit history",
+			Test: testEditChatMessage,
+		},
+		testutils.TestRunConfig{
+			Name: "DeleteChatMessage soft-deletes the message",
+			Test: testDeleteChatMessage,
+		},
+		testutils.TestRunConfig{
+			Name: "Chat mentions are counted until read",
+			Test: testChatMentions,
+		},
 		// Users
 		testutils.TestRunConfig{
 			Name: "GetPadUsers returns empty list",
@@ -184,6 +196,15 @@ func TestPadAPI(t *testing.T) {
 			Name: "MovePad destination exists without force returns 409",
 			Test: testMovePadDestinationExistsNoForce,
 		},
+		// Fork pad
+		testutils.TestRunConfig{
+			Name: "ForkPad records the source and its revision",
+			Test: testForkPadSuccess,
+		},
+		testutils.TestRunConfig{
+			Name: "ForkPad onto the source or its sources returns 400",
+			Test: testForkPadOntoSource,
+		},
 		// Public status
 		testutils.TestRunConfig{
 			Name: "GetPublicStatus defaults to false",
@@ -726,6 +747,129 @@ func testGetChatHistory(t *testing.T, tsStore testutils.TestDataStore) {
 	assert.NotNil(t, response.Messages)
 }
 
+func testEditChatMessage(t *testing.T, tsStore testutils.TestDataStore) {
+	initStore := tsStore.ToInitStore()
+	pad.Init(initStore)
+
+	text := "Chat edit test\n"
+	createTestPad(t, tsStore, "chateditpad", text)
+	retrievedPad, err := tsStore.PadManager.GetPad("chateditpad", nil, nil)
+	assert.NoError(t, err)
+	chatter, err := tsStore.AuthorManager.CreateAuthor(nil)
+	assert.NoError(t, err)
+	authorId := chatter.Id
+	_, err = retrievedPad.PostChatMessage(&authorId, 1, "hi", nil)
+	assert.NoError(t, err)
+
+	edit := func(authorId string) int {
+		body, _ := json.Marshal(pad.EditChatMessageRequest{Text: "hello", AuthorID: authorId})
+		req := httptest.NewRequest("PATCH", "/admin/api/pads/chateditpad/chat/0", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := initStore.C.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, 403, edit("a.other"))
+	assert.Equal(t, 200, edit(authorId))
+
+	req := httptest.NewRequest("GET", "/admin/api/pads/chateditpad/chat/0/edits", nil)
+	resp, err := initStore.C.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var response pad.ChatMessageEditsResponse
+	body, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(body, &response)
+
+	if assert.Len(t, response.Edits, 1) {
+		assert.Equal(t, "hi", response.Edits[0].Text)
+	}
+}
+
+func testDeleteChatMessage(t *testing.T, tsStore testutils.TestDataStore) {
+	initStore := tsStore.ToInitStore()
+	pad.Init(initStore)
+
+	text := "Chat delete test\n"
+	createTestPad(t, tsStore, "chatdeletepad", text)
+	retrievedPad, err := tsStore.PadManager.GetPad("chatdeletepad", nil, nil)
+	assert.NoError(t, err)
+	chatter, err := tsStore.AuthorManager.CreateAuthor(nil)
+	assert.NoError(t, err)
+	authorId := chatter.Id
+	_, err = retrievedPad.PostChatMessage(&authorId, 1, "hi", nil)
+	assert.NoError(t, err)
+	replyTo := 0
+	_, err = retrievedPad.PostChatMessage(&authorId, 2, "anyone?", &replyTo)
+	assert.NoError(t, err)
+
+	for _, status := range []int{200, 409} {
+		req := httptest.NewRequest("DELETE", "/admin/api/pads/chatdeletepad/chat/0", nil)
+		resp, err := initStore.C.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+	}
+	req := httptest.NewRequest("DELETE", "/admin/api/pads/chatdeletepad/chat/5", nil)
+	resp, err := initStore.C.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	req = httptest.NewRequest("GET", "/admin/api/pads/chatdeletepad/chatHistory", nil)
+	resp, err = initStore.C.Test(req)
+	assert.NoError(t, err)
+
+	var response pad.ChatHistoryResponse
+	body, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(body, &response)
+
+	if assert.Len(t, response.Messages, 2) {
+		assert.True(t, response.Messages[0].Deleted)
+		assert.Empty(t, response.Messages[0].Text)
+		assert.Equal(t, &replyTo, response.Messages[1].ReplyTo)
+	}
+}
+
+func testChatMentions(t *testing.T, tsStore testutils.TestDataStore) {
+	initStore := tsStore.ToInitStore()
+	pad.Init(initStore)
+
+	text := "Chat mention test\n"
+	createTestPad(t, tsStore, "chatmentionpad", text)
+	mentioned, err := tsStore.AuthorManager.CreateAuthor(nil)
+	assert.NoError(t, err)
+	chatter, err := tsStore.AuthorManager.CreateAuthor(nil)
+	assert.NoError(t, err)
+	retrievedPad, err := tsStore.PadManager.GetPad("chatmentionpad", nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, retrievedPad.SpliceText(0, 0, "x", &mentioned.Id))
+
+	body, _ := json.Marshal(pad.AppendChatMessageRequest{Text: "look @" + mentioned.Id, AuthorID: chatter.Id})
+	req := httptest.NewRequest("POST", "/admin/api/pads/chatmentionpad/chat", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := initStore.C.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	unread := func() int {
+		req := httptest.NewRequest("GET", "/admin/api/pads/chatmentionpad/chat/mentions?authorID="+mentioned.Id, nil)
+		resp, err := initStore.C.Test(req)
+		assert.NoError(t, err)
+		var response pad.ChatMentionsResponse
+		body, _ := io.ReadAll(resp.Body)
+		_ = json.Unmarshal(body, &response)
+		return response.Unread
+	}
+	assert.Equal(t, 1, unread())
+
+	body, _ = json.Marshal(pad.MarkChatReadRequest{AuthorID: mentioned.Id, Head: 0})
+	req = httptest.NewRequest("POST", "/admin/api/pads/chatmentionpad/chat/read", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = initStore.C.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, 0, unread())
+}
+
 // ========== Users ==========
 
 func testGetPadUsersEmpty(t *testing.T, tsStore testutils.TestDataStore) {
@@ -966,6 +1110,49 @@ func testMovePadDestinationExistsNoForce(t *testing.T, tsStore testutils.TestDat
 	assert.Contains(t, srcText, "Move source")
 }
 
+// ========== Fork Pad ==========
+
+func testForkPadSuccess(t *testing.T, tsStore testutils.TestDataStore) {
+	initStore := tsStore.ToInitStore()
+	pad.Init(initStore)
+
+	createTestPad(t, tsStore, "forksrc", "Fork source\n")
+	srcPad, err := tsStore.PadManager.GetPad("forksrc", nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, srcPad.SetText("Fork source v2", nil))
+
+	status, respBody := postPadOperation(t, tsStore, "/admin/api/pads/forksrc/fork", "forkdst", false)
+	assert.Equal(t, 200, status, "response body: %s", string(respBody))
+
+	fork, err := tsStore.PadManager.GetPad("forkdst", nil, nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, fork.Fork) {
+		assert.Equal(t, "forksrc", fork.Fork.Source)
+		assert.Equal(t, srcPad.Head, fork.Fork.SourceRev)
+		assert.Equal(t, fork.Head, fork.Fork.Rev)
+	}
+	assert.Equal(t, srcPad.Text(), fork.Text())
+}
+
+func testForkPadOntoSource(t *testing.T, tsStore testutils.TestDataStore) {
+	initStore := tsStore.ToInitStore()
+	pad.Init(initStore)
+
+	createTestPad(t, tsStore, "forkorig", "Original\n")
+	status, respBody := postPadOperation(t, tsStore, "/admin/api/pads/forkorig/fork", "forkchild", false)
+	assert.Equal(t, 200, status, "response body: %s", string(respBody))
+
+	status, _ = postPadOperation(t, tsStore, "/admin/api/pads/forkorig/fork", "forkorig", true)
+	assert.Equal(t, 400, status)
+	status, _ = postPadOperation(t, tsStore, "/admin/api/pads/forkchild/fork", "forkorig", true)
+	assert.Equal(t, 400, status, "forking onto its source would delete it")
+
+	orig, err := tsStore.PadManager.GetPad("forkorig", nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, orig.Fork, "the source is still no fork")
+	assert.Contains(t, orig.Text(), "Original")
+}
+
 // ========== Public Status ==========
 
 func testGetPublicStatusDefault(t *testing.T, tsStore testutils.TestDataStore) {
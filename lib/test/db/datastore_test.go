@@ -53,6 +53,10 @@ func runAllDataStoreTests(testHandler *testutils.TestDBHandler) {
 			Name: "GetRevisionsOnNonexistentPad",
 			Test: testGetRevisionsOnNonexistentPad,
 		},
+		testutils.TestRunConfig{
+			Name: "GetRevisionAtTimestamp",
+			Test: testGetRevisionAtTimestamp,
+		},
 		testutils.TestRunConfig{
 			Name: "SaveRevisionsOnNonexistentPad",
 			Test: testSaveRevisionsOnNonexistentPad,
@@ -426,6 +430,48 @@ func testSaveAndGetRevisionAndMetaData(t *testing.T, ds testutils.TestDataStore)
 	}
 }
 
+func testGetRevisionAtTimestamp(t *testing.T, ds testutils.TestDataStore) {
+	pad := modeldb.PadDB{
+		Head:           -1,
+		SavedRevisions: make([]modeldb.SavedRevision, 0),
+	}
+	if err := ds.DS.CreatePad("dated", pad); err != nil {
+		t.Fatalf("CreatePad failed: %v", err)
+	}
+	text := apool.AText{}
+	pool := apool.NewAPool()
+	for rev, timestamp := range []int64{1000, 2000, 2000, 3000} {
+		if err := ds.DS.SaveRevision("dated", rev, "changeset", text.ToDBAText(), pool.ToRevDB(), nil, timestamp); err != nil {
+			t.Fatalf("SaveRevision failed: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		timestamp int64
+		want      int
+	}{
+		{1000, 0},
+		{1999, 0},
+		{2000, 2},
+		{2500, 2},
+		{9000, 3},
+	}
+	for _, tc := range testCases {
+		rev, err := ds.DS.GetRevisionAtTimestamp("dated", tc.timestamp)
+		if err != nil {
+			t.Fatalf("GetRevisionAtTimestamp(%d) failed: %v", tc.timestamp, err)
+		}
+		if rev == nil || *rev != tc.want {
+			t.Fatalf("GetRevisionAtTimestamp(%d) = %v, want %d", tc.timestamp, rev, tc.want)
+		}
+	}
+
+	rev, err := ds.DS.GetRevisionAtTimestamp("dated", 999)
+	if err != nil || rev != nil {
+		t.Fatalf("GetRevisionAtTimestamp before the first revision = %v, %v", rev, err)
+	}
+}
+
 func testGetPadOnNonExistingPad(t *testing.T, ds testutils.TestDataStore) {
 	pad, err := ds.DS.GetPad("nonexistentPad")
 	if pad != nil && err == nil || err.Error() != db.PadDoesNotExistError {
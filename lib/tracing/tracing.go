@@ -0,0 +1,119 @@
+// Package tracing wires OpenTelemetry into the server. Init installs the
+// global tracer provider from the tracing settings; until then (or when
+// tracing is disabled) every span is a no-op, so instrumented code can call
+// Start unconditionally.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ether/etherpad-go/lib/settings"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const instrumentationName = "github.com/ether/etherpad-go"
+
+// Exporters supported by the tracing settings.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// StdoutWriter receives the spans of the stdout exporter. Tests may replace
+// it before calling Init.
+var StdoutWriter io.Writer = os.Stdout
+
+// Init configures the global tracer provider and W3C trace context
+// propagation. The returned function flushes and stops the exporter; it is a
+// no-op when tracing is disabled.
+func Init(cfg settings.Tracing, version string, logger *zap.SugaredLogger) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if !cfg.Enabled {
+		return noop, nil
+	}
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return noop, err
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "etherpad"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return noop, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warnf("tracing: %v", err)
+	}))
+	logger.Infof("Tracing enabled: %s exporter, sample ratio %.2f", cfg.Exporter, cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+func newExporter(cfg settings.Tracing) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP, "":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(StdoutWriter))
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+}
+
+// Tracer returns the tracer of the server.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span as child of the span in ctx. A nil ctx starts a root
+// span.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
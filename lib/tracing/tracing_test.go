@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		wantName    string
+		wantRoute   string
+		wantStatus  int64
+		wantError   bool
+	}{
+		{name: "route name", path: "/p/test", wantName: "GET /p/:pad", wantRoute: "/p/:pad", wantStatus: 200},
+		{name: "propagated parent", path: "/p/test", traceparent: "00-" + traceID + "-00f067aa0ba902b7-01", wantName: "GET /p/:pad", wantRoute: "/p/:pad", wantStatus: 200},
+		{name: "server error", path: "/fail", wantName: "GET /fail", wantRoute: "/fail", wantStatus: 500, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := useRecorder(t)
+			app := fiber.New()
+			app.Use(Middleware())
+			app.Get("/p/:pad", func(c fiber.Ctx) error {
+				_, child := Start(c.Context(), "handler")
+				child.End()
+				return c.SendString("ok")
+			})
+			app.Get("/fail", func(c fiber.Ctx) error {
+				return fiber.ErrInternalServerError
+			})
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("request: %v", err)
+			}
+
+			var server sdktrace.ReadOnlySpan
+			for _, s := range recorder.Ended() {
+				if s.Name() == tt.wantName {
+					server = s
+				}
+			}
+			if server == nil {
+				t.Fatalf("no span %q among %d spans", tt.wantName, len(recorder.Ended()))
+			}
+			if got := attr(server, "http.route").AsString(); got != tt.wantRoute {
+				t.Errorf("http.route = %q, want %q", got, tt.wantRoute)
+			}
+			if got := attr(server, "http.response.status_code").AsInt64(); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if got := server.Status().Code == codes.Error; got != tt.wantError {
+				t.Errorf("error status = %v, want %v", got, tt.wantError)
+			}
+			if tt.traceparent != "" && server.SpanContext().TraceID().String() != traceID {
+				t.Errorf("trace id = %s, want %s", server.SpanContext().TraceID(), traceID)
+			}
+			for _, s := range recorder.Ended() {
+				if s.Name() == "handler" && s.Parent().SpanID() != server.SpanContext().SpanID() {
+					t.Errorf("handler span is not a child of the server span")
+				}
+			}
+		})
+	}
+}
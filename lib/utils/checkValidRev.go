@@ -3,6 +3,7 @@ package utils
 import (
 	"errors"
 	"strconv"
+	"time"
 )
 
 func CheckValidRev(rev string) (*int, error) {
@@ -15,3 +16,13 @@ func CheckValidRev(rev string) (*int, error) {
 	}
 	return &revNum, nil
 }
+
+// ParseRevisionTime parses the at parameter of point-in-time requests, an
+// RFC 3339 timestamp.
+func ParseRevisionTime(at string) (*time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return nil, errors.New("at is not an RFC 3339 timestamp")
+	}
+	return &parsed, nil
+}
@@ -76,7 +76,17 @@ var ColorPalette = []string{
 	"#b3b3e6",
 }
 
+// GetDB opens the configured DataStore. With tracing or metrics enabled every
+// call is recorded as a span and in the DB latency histogram.
 func GetDB(retrievedSettings settings.Settings, setupLogger *zap.SugaredLogger) (db.DataStore, error) {
+	store, err := openDB(retrievedSettings, setupLogger)
+	if err != nil || !(retrievedSettings.Tracing.Enabled || retrievedSettings.EnableMetrics) {
+		return store, err
+	}
+	return db.NewTracedDataStore(store, string(retrievedSettings.DBType)), nil
+}
+
+func openDB(retrievedSettings settings.Settings, setupLogger *zap.SugaredLogger) (db.DataStore, error) {
 	if retrievedSettings.DBType == settings.SQLITE {
 		setupLogger.Infof("Using SQLite database at %s", retrievedSettings.DBSettings.Filename)
 		return db.NewSQLiteDB(retrievedSettings.DBSettings.Filename)
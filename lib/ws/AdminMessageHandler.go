@@ -3,6 +3,7 @@ package ws
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"runtime"
 	"slices"
@@ -18,6 +19,7 @@ import (
 	"github.com/ether/etherpad-go/lib/models/ws/admin"
 	"github.com/ether/etherpad-go/lib/pad"
 	"github.com/ether/etherpad-go/lib/plugins"
+	"github.com/ether/etherpad-go/lib/plugins/wasm"
 	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/ether/etherpad-go/lib/updater"
 	libutils "github.com/ether/etherpad-go/lib/utils"
@@ -34,6 +36,9 @@ type AdminMessageHandler struct {
 	Logger            *zap.SugaredLogger
 	App               *fiber.App
 	updater           *updater.Updater
+	// Reloader applies settings changes without a restart. applySettings
+	// is refused when it is nil.
+	Reloader *settings.Reloader
 }
 
 func NewAdminMessageHandler(store db.DataStore, h *hooks.Hook, m *pad.Manager, padMessHandler *PadMessageHandler, logger *zap.SugaredLogger, hub *Hub, app *fiber.App, upd *updater.Updater) AdminMessageHandler {
@@ -246,46 +251,21 @@ func (h AdminMessageHandler) HandleMessage(message admin.EventMessage, retrieved
 			c.SafeSend(responseBytes)
 		}
 	case "getInstalled":
+		h.sendInstalledPlugins(retrievedSettings, c)
+	case "setPluginEnabled":
 		{
-
-			var epPlugin = []admin.InstalledPluginDefinition{
-				{
-					Name:         "etherpad",
-					Description:  "The core Etherpad application",
-					Version:      retrievedSettings.GitVersion,
-					FrontendPath: "/plugins/etherpad",
-					BackendPath:  "/lib/plugins/etherpad",
-
-					Enabled: true,
-				},
-			}
-
-			for _, plugin := range plugins.RegisteredPlugins {
-				epPlugin = append(epPlugin, admin.InstalledPluginDefinition{
-					Name:         plugin.Name(),
-					Description:  plugin.Description(),
-					Version:      retrievedSettings.GitVersion,
-					Enabled:      plugin.IsEnabled(),
-					FrontendPath: "/plugins/" + plugin.Name(),
-					BackendPath:  "/lib/plugins/" + plugin.Name(),
-				})
+			var toggle struct {
+				Name    string `json:"name"`
+				Enabled bool   `json:"enabled"`
 			}
-
-			slices.SortFunc(epPlugin, func(a, b admin.InstalledPluginDefinition) int {
-				return strings.Compare(a.Name, b.Name)
-			})
-			resp := make([]interface{}, 2)
-			resp[0] = "results:installed"
-			resp[1] = map[string]interface{}{
-				"installed": epPlugin,
-			}
-
-			responseBytes, err := json.Marshal(resp)
-			if err != nil {
-				h.Logger.Warn("Error marshalling response:", err.Error())
+			if err := json.Unmarshal(message.Data, &toggle); err != nil {
+				h.Logger.Warn("Error unmarshalling setPluginEnabled data:", err.Error())
 				return
 			}
+			result := h.setPluginEnabled(toggle.Name, toggle.Enabled)
+			responseBytes, _ := json.Marshal([]interface{}{"results:setPluginEnabled", result})
 			c.SafeSend(responseBytes)
+			h.sendInstalledPlugins(retrievedSettings, c)
 		}
 	case "shout":
 		{
@@ -429,10 +409,16 @@ func (h AdminMessageHandler) HandleMessage(message admin.EventMessage, retrieved
 			if err := json.Unmarshal(message.Data, &settingsJSON); err != nil {
 				settingsJSON = string(message.Data)
 			}
-			settingsPath := "settings.json"
-			if retrievedSettings.Root != "" {
-				settingsPath = retrievedSettings.Root + "/settings.json"
+			if problems := settings.Errors(settings.CheckConfig([]byte(settingsJSON))); len(problems) > 0 {
+				err := &settings.ValidationError{Problems: problems}
+				h.Logger.Warnf("Not saving settings: %v", err)
+				responseBytes, _ := json.Marshal([]interface{}{"results:saveSettings", map[string]interface{}{"error": err.Error(), "problems": problems}})
+				c.SafeSend(responseBytes)
+				return
 			}
+			// The file read at startup, which the reloader watches, so the
+			// saved settings are applied and survive a restart.
+			settingsPath := settings.ConfigFile()
 			if err := os.WriteFile(settingsPath, []byte(settingsJSON), 0644); err != nil {
 				h.Logger.Errorf("Error saving settings: %v", err)
 				resp := make([]interface{}, 2)
@@ -449,6 +435,38 @@ func (h AdminMessageHandler) HandleMessage(message admin.EventMessage, retrieved
 			responseBytes, _ := json.Marshal(resp)
 			c.SafeSend(responseBytes)
 		}
+	case "applySettings":
+		{
+			// Without the settings as a JSON string, the settings file is read
+			// again.
+			var settingsJSON string
+			_ = json.Unmarshal(message.Data, &settingsJSON)
+			var response interface{}
+			if h.Reloader == nil {
+				response = map[string]interface{}{"error": "settings cannot be applied without a restart"}
+			} else {
+				var result *settings.ReloadResult
+				var err error
+				if settingsJSON == "" {
+					result, err = h.Reloader.Reload()
+				} else {
+					result, err = h.Reloader.Write([]byte(settingsJSON))
+				}
+				var validationErr *settings.ValidationError
+				if errors.As(err, &validationErr) {
+					h.Logger.Warnf("Not applying settings: %v", err)
+					response = map[string]interface{}{"error": err.Error(), "problems": validationErr.Problems}
+				} else if err != nil {
+					h.Logger.Warnf("Error applying settings: %v", err)
+					response = map[string]interface{}{"error": err.Error()}
+				} else {
+					h.Reloader.Log(result)
+					response = result
+				}
+			}
+			responseBytes, _ := json.Marshal([]interface{}{"results:applySettings", response})
+			c.SafeSend(responseBytes)
+		}
 	case "restartServer":
 		{
 			h.Logger.Info("Restart requested via admin UI")
@@ -533,6 +551,7 @@ func (h AdminMessageHandler) HandleMessage(message admin.EventMessage, retrieved
 			h.hub.ClientsRWMutex.RUnlock()
 			for _, client := range toKick {
 				// Send disconnect message in the wire format ["message", {...}] and close
+				countDisconnectRequest("kicked")
 				kickMsg, _ := json.Marshal([]interface{}{"message", map[string]string{"disconnect": "kicked"}})
 				client.SafeSend(kickMsg)
 				client.Conn.Close()
@@ -661,11 +680,145 @@ func (h AdminMessageHandler) HandleMessage(message admin.EventMessage, retrieved
 			responseBytes, _ := json.Marshal(resp)
 			c.SafeSend(responseBytes)
 		}
+	case "sheetToPad":
+		{
+			var data admin.SheetToPadData
+			if err := json.Unmarshal(message.Data, &data); err != nil {
+				h.Logger.Warn("Error unmarshalling sheetToPad data:", err.Error())
+				return
+			}
+			var result interface{}
+			opts := SheetToPadOptions{Worksheet: data.Sheet, Range: data.Range}
+			delim, err := optionalDelimiter(data.Delimiter)
+			if err == nil {
+				opts.Delimiter = delim
+				_, err = h.padMessageHandler.SheetToPad(data.PadName, data.TargetPadName, opts, "")
+			}
+			if err != nil {
+				h.Logger.Warnf("Error converting sheet %s to pad %s: %s", data.PadName, data.TargetPadName, err.Error())
+				result = admin.ErrorMessage{Error: err.Error()}
+			} else {
+				h.Logger.Infof("Sheet %s copied to pad %s via admin interface", data.PadName, data.TargetPadName)
+				result = admin.SuccessMessage{Success: "Sheet " + data.PadName + " copied to pad " + data.TargetPadName}
+			}
+			responseBytes, err := json.Marshal([]interface{}{"results:sheetToPad", result})
+			if err != nil {
+				h.Logger.Warn("Error marshalling response:", err.Error())
+				return
+			}
+			c.SafeSend(responseBytes)
+		}
+	case "padToSheet":
+		{
+			var data admin.PadToSheetData
+			if err := json.Unmarshal(message.Data, &data); err != nil {
+				h.Logger.Warn("Error unmarshalling padToSheet data:", err.Error())
+				return
+			}
+			var result interface{}
+			delim, err := optionalDelimiter(data.Delimiter)
+			if err == nil {
+				_, err = h.padMessageHandler.PadToSheet(data.PadName, data.TargetPadName, delim, "")
+			}
+			if err != nil {
+				h.Logger.Warnf("Error converting pad %s to sheet %s: %s", data.PadName, data.TargetPadName, err.Error())
+				result = admin.ErrorMessage{Error: err.Error()}
+			} else {
+				h.Logger.Infof("Pad %s converted to sheet %s via admin interface", data.PadName, data.TargetPadName)
+				result = admin.SuccessMessage{Success: "Sheet " + data.TargetPadName + " created from pad " + data.PadName}
+			}
+			responseBytes, err := json.Marshal([]interface{}{"results:padToSheet", result})
+			if err != nil {
+				h.Logger.Warn("Error marshalling response:", err.Error())
+				return
+			}
+			c.SafeSend(responseBytes)
+		}
 	default:
 		h.Logger.Warn("Unknown admin event:", message.Event)
 	}
 }
 
+// setPluginEnabled switches a WebAssembly plugin through the Reloader, so the
+// settings file, the running settings and settings.Displayed agree.
+func (h AdminMessageHandler) setPluginEnabled(name string, enabled bool) map[string]interface{} {
+	if plugins.ExternalPlugins == nil {
+		return map[string]interface{}{"error": "WebAssembly plugins are disabled"}
+	}
+	if !slices.ContainsFunc(plugins.ExternalPlugins.Plugins(), func(p wasm.PluginInfo) bool { return p.Name == name }) {
+		return map[string]interface{}{"error": fmt.Sprintf("unknown WebAssembly plugin %q", name)}
+	}
+	if h.Reloader == nil {
+		return map[string]interface{}{"error": "plugins cannot be switched without a restart"}
+	}
+	result, err := h.Reloader.SetPluginEnabled(name, enabled)
+	if err != nil {
+		h.Logger.Warnf("Error toggling plugin %s: %v", name, err)
+		return map[string]interface{}{"error": err.Error()}
+	}
+	h.Reloader.Log(result)
+	if slices.Contains(result.RestartRequired, "plugins."+name) {
+		return map[string]interface{}{"error": fmt.Sprintf("plugin %s is switched after a restart", name)}
+	}
+	return map[string]interface{}{"success": true}
+}
+
+func (h AdminMessageHandler) sendInstalledPlugins(retrievedSettings *settings.Settings, c *Client) {
+	var epPlugin = []admin.InstalledPluginDefinition{
+		{
+			Name:         "etherpad",
+			Description:  "The core Etherpad application",
+			Version:      retrievedSettings.GitVersion,
+			FrontendPath: "/plugins/etherpad",
+			BackendPath:  "/lib/plugins/etherpad",
+
+			Enabled: true,
+		},
+	}
+
+	for _, plugin := range plugins.RegisteredPlugins {
+		epPlugin = append(epPlugin, admin.InstalledPluginDefinition{
+			Name:         plugin.Name(),
+			Description:  plugin.Description(),
+			Version:      retrievedSettings.GitVersion,
+			Enabled:      plugin.IsEnabled(),
+			FrontendPath: "/plugins/" + plugin.Name(),
+			BackendPath:  "/lib/plugins/" + plugin.Name(),
+		})
+	}
+
+	if plugins.ExternalPlugins != nil {
+		for _, plugin := range plugins.ExternalPlugins.Plugins() {
+			epPlugin = append(epPlugin, admin.InstalledPluginDefinition{
+				Name:         plugin.Name,
+				Description:  plugin.Manifest.Description,
+				Version:      plugin.Manifest.Version,
+				Enabled:      plugin.Enabled,
+				FrontendPath: "/static/wasm/" + plugin.Name + "/",
+				BackendPath:  plugin.Dir,
+				Runtime:      "wasm",
+				Error:        plugin.Error,
+			})
+		}
+	}
+
+	slices.SortFunc(epPlugin, func(a, b admin.InstalledPluginDefinition) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	resp := make([]interface{}, 2)
+	resp[0] = "results:installed"
+	resp[1] = map[string]interface{}{
+		"installed": epPlugin,
+	}
+
+	responseBytes, err := json.Marshal(resp)
+	if err != nil {
+		h.Logger.Warn("Error marshalling response:", err.Error())
+		return
+	}
+	c.SafeSend(responseBytes)
+}
+
 func (h AdminMessageHandler) DeleteRevisions(padId string, keepRevisions int) error {
 	h.Logger.Debugf("Starting deletion of revisions for pad %s, keeping last %d revisions", padId, keepRevisions)
 
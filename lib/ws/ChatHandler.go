@@ -0,0 +1,159 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/models/db"
+	pad2 "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/models/ws"
+)
+
+// HandleChatAction applies a CHAT_EDIT, CHAT_DELETE or CHAT_READ from client.
+// Edits and deletions are broadcast to the whole room as CHAT_MESSAGE_UPDATE;
+// a refused one is answered with REJECT_CHAT.
+func (p *PadMessageHandler) HandleChatAction(client *Client, session *ws.Session, action ws.ChatAction) {
+	p.handleChatAction(context.Background(), client, session, action)
+}
+
+// handleChatAction is HandleChatAction with the database calls traced as
+// children of the span in ctx.
+func (p *PadMessageHandler) handleChatAction(ctx context.Context, client *Client, session *ws.Session, action ws.ChatAction) {
+	retrievedPad, err := p.padManager.GetPadContext(ctx, session.PadId, nil, nil)
+	if err != nil {
+		p.Logger.Warn("Error retrieving pad for chat action", err)
+		return
+	}
+	head := action.Data.Data.Head
+	switch action.Data.Data.Type {
+	case "CHAT_EDIT":
+		// Changes of a message run in the pad queue, so an edit and a
+		// deletion of the same message do not interleave.
+		p.runInPadQueue(session.PadId, func() {
+			before, err := retrievedPad.GetChatMessageContext(ctx, head)
+			if err != nil {
+				p.sendRejectChat(client, head, err)
+				return
+			}
+			msg, err := retrievedPad.EditChatMessageContext(ctx, head, session.Author, time.Now().UnixMilli(), action.Data.Data.Text)
+			if err != nil {
+				p.sendRejectChat(client, head, err)
+				return
+			}
+			p.BroadcastChatMessageUpdate(session.PadId, msg)
+			p.SendChatMentions(retrievedPad, append(slices.Clone(before.Mentions), msg.Mentions...))
+		})
+	case "CHAT_DELETE":
+		p.runInPadQueue(session.PadId, func() {
+			before, err := retrievedPad.GetChatMessageContext(ctx, head)
+			if err != nil {
+				p.sendRejectChat(client, head, err)
+				return
+			}
+			// Like pad deletion, moderating the chat is up to the pad's creator.
+			firstContributor, err := retrievedPad.GetRevisionAuthor(0)
+			moderator := err == nil && *firstContributor == session.Author
+			msg, err := retrievedPad.DeleteChatMessageContext(ctx, head, &session.Author, moderator, time.Now().UnixMilli())
+			if err != nil {
+				p.sendRejectChat(client, head, err)
+				return
+			}
+			p.BroadcastChatMessageUpdate(session.PadId, msg)
+			p.SendChatMentions(retrievedPad, before.Mentions)
+		})
+	case "CHAT_READ":
+		if err := retrievedPad.MarkChatReadContext(ctx, session.Author, head); err != nil {
+			p.Logger.Warn("Error saving chat read state", err)
+			return
+		}
+		p.SendChatMentions(retrievedPad, []string{session.Author})
+	default:
+		p.Logger.Warnf("Unknown chat action %q", action.Data.Data.Type)
+	}
+}
+
+// BroadcastChatMessageUpdate sends an edited or deleted chat message to all
+// clients of the pad.
+func (p *PadMessageHandler) BroadcastChatMessageUpdate(padId string, msg *db.ChatMessageDBWithDisplayName) {
+	update := ws.ChatMessageUpdate{Type: "COLLABROOM"}
+	update.Data.Type = "CHAT_MESSAGE_UPDATE"
+	update.Data.Message = ChatMessageSendData(*msg)
+	encoded, err := json.Marshal([]any{"message", update})
+	if err != nil {
+		p.Logger.Warn("marshal CHAT_MESSAGE_UPDATE: ", err)
+		return
+	}
+	for _, socket := range p.GetRoomSockets(padId) {
+		socket.SafeSend(encoded)
+	}
+}
+
+// SendChatMentions sends each of authorIds connected to the pad its count of
+// unread mentions.
+func (p *PadMessageHandler) SendChatMentions(retrievedPad *pad2.Pad, authorIds []string) {
+	if len(authorIds) == 0 {
+		return
+	}
+	unread := make(map[string][]byte)
+	for _, socket := range p.GetRoomSockets(retrievedPad.Id) {
+		session := p.SessionStore.getSession(socket.SessionId)
+		if session == nil || !slices.Contains(authorIds, session.Author) {
+			continue
+		}
+		encoded, ok := unread[session.Author]
+		if !ok {
+			count, err := retrievedPad.UnreadChatMentions(session.Author)
+			if err != nil {
+				p.Logger.Warn("Error counting unread chat mentions", err)
+				continue
+			}
+			msg := ws.ChatMentions{Type: "COLLABROOM"}
+			msg.Data.Type = "CHAT_MENTIONS"
+			msg.Data.Unread = count
+			encoded, err = json.Marshal([]any{"message", msg})
+			if err != nil {
+				p.Logger.Warn("marshal CHAT_MENTIONS: ", err)
+				return
+			}
+			unread[session.Author] = encoded
+		}
+		socket.SafeSend(encoded)
+	}
+}
+
+// sendRejectChat tells the sender its edit or deletion of the message at
+// head was refused.
+func (p *PadMessageHandler) sendRejectChat(client *Client, head int, cause error) {
+	msg := ws.RejectChat{Type: "COLLABROOM"}
+	msg.Data.Type = "REJECT_CHAT"
+	msg.Data.Head = head
+	msg.Data.Reason = cause.Error()
+	encoded, err := json.Marshal([]any{"message", msg})
+	if err != nil {
+		p.Logger.Warn("marshal REJECT_CHAT: ", err)
+		return
+	}
+	client.SafeSend(encoded)
+}
+
+// ChatMessageSendData converts a stored chat message for the clients.
+func ChatMessageSendData(msg db.ChatMessageDBWithDisplayName) ws.ChatMessageSendData {
+	head, replyTo := msg.Head, msg.ReplyTo
+	data := ws.ChatMessageSendData{
+		Text:      msg.Message,
+		Time:      msg.Time,
+		UserId:    msg.AuthorId,
+		Head:      &head,
+		ReplyTo:   replyTo,
+		Mentions:  msg.Mentions,
+		EditedAt:  msg.EditedAt,
+		Deleted:   msg.DeletedAt != nil,
+		DeletedBy: msg.DeletedBy,
+	}
+	if msg.DisplayName != nil && *msg.DisplayName != "" {
+		data.UserName = msg.DisplayName
+	}
+	return data
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"errors"
@@ -20,6 +21,8 @@ import (
 	db2 "github.com/ether/etherpad-go/lib/db"
 	"github.com/ether/etherpad-go/lib/hooks"
 	"github.com/ether/etherpad-go/lib/hooks/events"
+	"github.com/ether/etherpad-go/lib/io"
+	"github.com/ether/etherpad-go/lib/metrics"
 	"github.com/ether/etherpad-go/lib/models/db"
 	pad2 "github.com/ether/etherpad-go/lib/models/pad"
 	"github.com/ether/etherpad-go/lib/models/webaccess"
@@ -28,8 +31,12 @@ import (
 	"github.com/ether/etherpad-go/lib/settings"
 	"github.com/ether/etherpad-go/lib/settings/clientVars"
 	"github.com/ether/etherpad-go/lib/sheetdoc"
+	"github.com/ether/etherpad-go/lib/tracing"
 	"github.com/ether/etherpad-go/lib/utils"
 	"github.com/ether/etherpad-go/lib/ws/constants"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -54,21 +61,34 @@ func init() {
 type Task struct {
 	socket  *Client
 	message ws.UserChange
+	// ctx carries the HandleMessage span; enqueued is when the task entered
+	// the pad queue.
+	ctx      context.Context
+	enqueued time.Time
+	// run replaces handleUserChanges for other edits that must not race
+	// with USER_CHANGES, like AUTHOR_UNDO.
+	run func()
 }
 
 type ChannelOperator struct {
 	channels map[string]chan Task
 	handler  *PadMessageHandler
 	mu       sync.Mutex
+	depth    queueDepth
 }
 
 func NewChannelOperator(p *PadMessageHandler) ChannelOperator {
 	return ChannelOperator{
 		channels: make(map[string]chan Task),
 		handler:  p,
+		depth:    newQueueDepth(metrics.QueueText),
 	}
 }
 
+// AddToQueue runs t on the queue of the pad ch. Each pad has its own worker,
+// so the edits of one pad are applied in order without waiting for the
+// others. A worker exits once its queue is drained and the next task starts
+// a new one, so pads nobody edits hold no goroutine.
 func (c *ChannelOperator) AddToQueue(ch string, t Task) {
 	c.mu.Lock()
 	chChan, ok := c.channels[ch]
@@ -76,17 +96,52 @@ func (c *ChannelOperator) AddToQueue(ch string, t Task) {
 		// small buffer to decouple producer from goroutine scheduling
 		chChan = make(chan Task, 1)
 		c.channels[ch] = chChan
-		go func(localCh chan Task) {
+		c.depth.worker()
+		go func(localCh chan Task, padId string) {
 			for incomingTask := range localCh {
-				c.handler.handleUserChanges(incomingTask)
+				if incomingTask.run != nil {
+					incomingTask.run()
+				} else {
+					c.handler.handleUserChanges(incomingTask)
+				}
+				if c.finished(padId) {
+					return
+				}
 			}
-		}(chChan)
+		}(chChan, ch)
 	}
+	// Counted under the lock, so the worker cannot retire before it
+	// receives t.
+	c.depth.enqueue(ch)
 	c.mu.Unlock()
 
 	chChan <- t
 }
 
+// finished marks a task of padId done and retires the pad's worker when its
+// queue is empty.
+func (c *ChannelOperator) finished(padId string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.depth.done(padId) {
+		return false
+	}
+	delete(c.channels, padId)
+	c.depth.retire()
+	return true
+}
+
+// runInPadQueue runs fn on the queue of the pad padId, after the edits
+// waiting there, and waits for it to finish.
+func (p *PadMessageHandler) runInPadQueue(padId string, fn func()) {
+	done := make(chan struct{})
+	p.padChannels.AddToQueue(padId, Task{run: func() {
+		defer close(done)
+		fn()
+	}})
+	<-done
+}
+
 type PadMessageHandler struct {
 	padManager      *pad.Manager
 	readOnlyManager *pad.ReadOnlyManager
@@ -124,15 +179,42 @@ func NewPadMessageHandler(db db2.DataStore, hooks *hooks.Hook, padManager *pad.M
 	return &padMessageHandler
 }
 
+// SetFormats makes CLIENT_VARS offer the export formats in formats instead of
+// the availableExports setting alone.
+func (p *PadMessageHandler) SetFormats(formats *io.FormatRegistry) {
+	p.factory.Formats = formats
+}
+
 // sendDisconnectMessage tells the client to disconnect for the given reason
 // (e.g. "badChangeset"), mirroring the original's
 // socket.emit('message', {disconnect: reason}).
 func sendDisconnectMessage(client *Client, reason string) {
+	countDisconnectRequest(reason)
 	msg, _ := json.Marshal([]interface{}{"message", map[string]string{"disconnect": reason}})
 	client.SafeSend(msg)
 }
 
+// rejectChangeset disconnects the client with "badChangeset", recording
+// reason on the span and in the reject counter.
+func rejectChangeset(span trace.Span, client *Client, reason string) {
+	metrics.ChangesetsRejected.WithLabelValues(reason).Inc()
+	span.SetStatus(codes.Error, "badChangeset: "+reason)
+	sendDisconnectMessage(client, "badChangeset")
+}
+
 func (p *PadMessageHandler) handleUserChanges(task Task) {
+	started := time.Now()
+	ctx, span := tracing.Start(task.ctx, "handleUserChanges")
+	defer span.End()
+	if !task.enqueued.IsZero() {
+		metrics.ChangesetQueueWait.Observe(started.Sub(task.enqueued).Seconds())
+		_, queued := tracing.Tracer().Start(ctx, "handleUserChanges.queue", trace.WithTimestamp(task.enqueued))
+		queued.End()
+	}
+	phases := spanPhases{ctx: ctx, prefix: "handleUserChanges"}
+	defer phases.end()
+	phases.start("validate")
+
 	var wireApool = apool.NewAPool()
 	var newAPool = apool.NewAPool()
 	newAPool.NextNum = task.message.Data.Data.Apool.NextNum
@@ -147,14 +229,14 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 	var retrievedPad, err = p.padManager.GetPad(session.PadId, nil, &session.Author)
 	if err != nil {
 		p.Logger.Warnf("Error retrieving pad %s: %v", session.PadId, err)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectStorage)
 		return
 	}
 	checkedRep, err := changeset.CheckRep(task.message.Data.Data.Changeset)
 
 	if err != nil {
 		p.Logger.Warnf("Error checking rep of changeset %s: %v", task.message.Data.Data.Changeset, err)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectMalformed)
 		return
 	}
 
@@ -162,14 +244,14 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 
 	if err != nil {
 		p.Logger.Warnf("Error unpacking changeset %s: %v", *checkedRep, err)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectMalformed)
 		return
 	}
 	deserializedOps, errWhenDeserializing := changeset.DeserializeOps(unpackedChangeset.Ops)
 
 	if errWhenDeserializing != nil {
 		p.Logger.Warnf("Error deserializing ops of changeset %s: %v", *checkedRep, errWhenDeserializing)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectMalformed)
 		return
 	}
 
@@ -199,12 +281,12 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 				}, &dontAdd) != -1
 				if !known {
 					p.Logger.Warnf("Author %s tried to set unknown author %s on existing text", session.Author, *opAuthorId)
-					sendDisconnectMessage(task.socket, "badChangeset")
+					rejectChangeset(span, task.socket, rejectForeignAuthor)
 					return
 				}
 			} else {
 				p.Logger.Warnf("Author %s tried to submit changes as author %s (op %s)", session.Author, *opAuthorId, op.OpCode)
-				sendDisconnectMessage(task.socket, "badChangeset")
+				rejectChangeset(span, task.socket, rejectForeignAuthor)
 				return
 			}
 		}
@@ -218,7 +300,7 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 		if op.OpCode == "+" && (opAuthorId == nil || *opAuthorId == "") {
 			p.Logger.Warnf("Author %s submitted an insert without an author attribute in changeset %s",
 				session.Author, task.message.Data.Data.Changeset)
-			sendDisconnectMessage(task.socket, "badChangeset")
+			rejectChangeset(span, task.socket, rejectUnattributedInsert)
 			return
 		}
 		// Upstream #7773: defense-in-depth — reject any wire-borne `*N`
@@ -231,7 +313,7 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 		if opAuthorId != nil && *opAuthorId == pad2.SystemAuthorId {
 			p.Logger.Warnf("Author %s attempted to submit changes as the reserved system author %s in changeset %s",
 				session.Author, *opAuthorId, task.message.Data.Data.Changeset)
-			sendDisconnectMessage(task.socket, "badChangeset")
+			rejectChangeset(span, task.socket, rejectSystemAuthor)
 			return
 		}
 	}
@@ -247,6 +329,12 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 
 	var r = task.message.Data.Data.BaseRev
 	headRev := retrievedPad.Head
+	span.SetAttributes(
+		attribute.String("etherpad.pad_id", retrievedPad.Id),
+		attribute.Int("etherpad.base_rev", r),
+		attribute.Int("etherpad.head_rev", headRev),
+	)
+	rebaseCtx := phases.start("rebase")
 
 	p.Logger.Debugf("Processing USER_CHANGES: baseRev=%d, headRev=%d, changeset=%s", r, headRev, task.message.Data.Data.Changeset)
 
@@ -255,10 +343,10 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 	// Update the changeset so that it can be applied to the latest revision.
 	for r < retrievedPad.Head {
 		r++
-		var revisionPad, err = retrievedPad.GetRevision(r)
+		var revisionPad, err = retrievedPad.GetRevisionContext(rebaseCtx, r)
 		if err != nil {
 			p.Logger.Warnf("Error retrieving revision %d: %v", r, err)
-			sendDisconnectMessage(task.socket, "badChangeset")
+			rejectChangeset(span, task.socket, rejectStorage)
 			return
 		}
 
@@ -267,7 +355,7 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 			unpackedChangeset, err = changeset.Unpack(canonicalCs)
 			if err != nil {
 				p.Logger.Warnf("Error unpacking changeset: %v", err)
-				sendDisconnectMessage(task.socket, "badChangeset")
+				rejectChangeset(span, task.socket, rejectMalformed)
 				return
 			}
 			rebasedChangeset = changeset.Identity(unpackedChangeset.OldLen)
@@ -279,27 +367,28 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 		optRebasedChangeset, err := changeset.Follow(revisionPad.Changeset, rebasedChangeset, false, &retrievedPad.Pool)
 		if err != nil {
 			p.Logger.Warnf("Error rebasing changeset at rev %d: %v for %s", r, err, retrievedPad.Id)
-			sendDisconnectMessage(task.socket, "badChangeset")
+			rejectChangeset(span, task.socket, rejectRebase)
 			return
 		}
 		rebasedChangeset = *optRebasedChangeset
 	}
 
 	p.Logger.Debugf("After rebasing: rebasedChangeset=%s", rebasedChangeset)
+	applyCtx := phases.start("apply")
 
 	prevText := retrievedPad.Text()
 	oldLen, err := changeset.OldLen(rebasedChangeset)
 
 	if err != nil {
 		p.Logger.Warnf("Error retrieving old len from changeset: %v", err)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectRebase)
 		return
 	}
 
 	if *oldLen != utf8.RuneCountInString(prevText) {
 		p.Logger.Warnf("Can't apply changeset to pad text: oldLen=%d, prevTextLen=%d, baseRev=%d, headRev=%d",
 			*oldLen, utf8.RuneCountInString(prevText), r, retrievedPad.Head)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectLengthMismatch)
 		return
 	}
 
@@ -314,7 +403,7 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 	projectedText, projectErr := changeset.ApplyToText(rebasedChangeset, prevText)
 	if projectErr != nil {
 		p.Logger.Warnf("Error projecting changeset application: %v", projectErr)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectTrailingNewline)
 		return
 	}
 	if projectedText == nil || !strings.HasSuffix(*projectedText, "\n") {
@@ -323,14 +412,14 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 			projLen = utf8.RuneCountInString(*projectedText)
 		}
 		p.Logger.Warnf("Rejected USER_CHANGES whose application would leave the pad without a trailing '\\n' (length %d). Every USER_CHANGES must preserve the \"doc ends with \\n\" invariant.", projLen)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectTrailingNewline)
 		return
 	}
 
-	newRev, err := retrievedPad.AppendRevision(rebasedChangeset, &session.Author)
+	newRev, err := retrievedPad.AppendRevisionContext(applyCtx, rebasedChangeset, &session.Author)
 	if err != nil {
 		p.Logger.Errorf("Error appending revision: %v", err)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectStorage)
 		return
 	}
 	// The head revision will either stay the same or increase by 1 depending on whether the
@@ -341,7 +430,7 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 
 	if !slices.Contains(rangeForRevs, *newRev) {
 		p.Logger.Warnf("Head revision after appending changeset is unexpected. Expected: %v, Got: %d", rangeForRevs, *newRev)
-		sendDisconnectMessage(task.socket, "badChangeset")
+		rejectChangeset(span, task.socket, rejectUnexpectedHead)
 		return
 	}
 	finalRev := *newRev
@@ -352,11 +441,12 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 	// is broadcast via UpdatePadClients below; ACCEPT_COMMIT still carries
 	// the user's own revision number.
 	if correction := p.correctMarkersInPad(retrievedPad.AText, retrievedPad.Pool); correction != nil {
-		if _, err := retrievedPad.AppendRevision(*correction, &session.Author); err != nil {
+		if _, err := retrievedPad.AppendRevisionContext(applyCtx, *correction, &session.Author); err != nil {
 			p.Logger.Errorf("Error appending marker-correction revision: %v", err)
 		}
 	}
 
+	phases.start("broadcast")
 	// The client assumes that ACCEPT_COMMIT and NEW_CHANGES messages arrive in order. Make sure we
 	// have already sent any previous ACCEPT_COMMIT and NEW_CHANGES messages.
 	var arr = make([]interface{}, 2)
@@ -370,6 +460,8 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 	}
 	var bytes, _ = json.Marshal(arr)
 	task.socket.SafeSend(bytes)
+	metrics.ChangesetsAccepted.Inc()
+	metrics.Since(metrics.ChangesetApplyDuration, started)
 
 	session.Revision = finalRev
 
@@ -377,7 +469,7 @@ func (p *PadMessageHandler) handleUserChanges(task Task) {
 		optTime, err := retrievedPad.GetRevisionDate(finalRev)
 		if err != nil {
 			p.Logger.Warnf("Error retrieving revision date: %v", err)
-			sendDisconnectMessage(task.socket, "badChangeset")
+			rejectChangeset(span, task.socket, rejectStorage)
 			return
 		}
 		session.Time = *optTime
@@ -415,7 +507,41 @@ func (p *PadMessageHandler) ComposePadChangesets(retrievedPad *pad2.Pad, startNu
 	return startChangeset, nil
 }
 
+// messageTypeName names message for traces, bounded to the known types.
+func messageTypeName(message any) string {
+	switch message.(type) {
+	case ws.ClientReady:
+		return "CLIENT_READY"
+	case ws.ChangesetReq:
+		return "CHANGESET_REQ"
+	case ws.ChatMessage:
+		return "CHAT_MESSAGE"
+	case ws.UserChange:
+		return "USER_CHANGES"
+	case SavedRevision:
+		return "SAVE_REVISION"
+	case ws.ClientMessage:
+		return "CLIENT_MESSAGE"
+	case ws.GetChatMessages:
+		return "GET_CHAT_MESSAGES"
+	case UserInfoUpdate:
+		return "USERINFO_UPDATE"
+	case PadDelete:
+		return "PAD_DELETE"
+	case AuthorUndo:
+		return message.(AuthorUndo).Data.Data.Type
+	case ws.ChatAction:
+		return message.(ws.ChatAction).Data.Data.Type
+	default:
+		return "unknown"
+	}
+}
+
 func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrievedSettings *settings.Settings, logger *zap.SugaredLogger) {
+	ctx, span := tracing.Start(context.Background(), "ws.HandleMessage",
+		attribute.String("etherpad.message.type", messageTypeName(message)))
+	defer span.End()
+
 	var isSessionInfo = p.SessionStore.hasSession(client.SessionId)
 
 	if !isSessionInfo {
@@ -444,7 +570,7 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 		}
 		thisSession = p.SessionStore.addHandleClientInformation(client.SessionId, castedMessage.Data.PadID, castedMessage.Data.Token)
 		thisSession.Auth.IntegratorSessionID = resolvedSessionID
-		exists, err := p.padManager.DoesPadExist(thisSession.Auth.PadId)
+		exists, err := p.padManager.DoesPadExistContext(ctx, thisSession.Auth.PadId)
 
 		if err != nil {
 			p.Logger.Warnf("Error checking if pad exists: %v", err)
@@ -461,7 +587,7 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 			thisSession.PadId = *padId
 		}
 
-		padIds, err := p.readOnlyManager.GetIds(&thisSession.Auth.PadId)
+		padIds, err := p.readOnlyManager.WithContext(ctx).GetIds(&thisSession.Auth.PadId)
 		if err != nil {
 			p.Logger.Warnf("Error retrieving read-only pad IDs: %v", err)
 			return
@@ -503,7 +629,7 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 	if auth.IntegratorSessionID != "" {
 		sessionCookie = &auth.IntegratorSessionID
 	}
-	var grantedAccess, err = p.securityManager.CheckAccess(&auth.PadId, sessionCookie, &auth.Token, user)
+	var grantedAccess, err = p.securityManager.CheckAccessContext(ctx, &auth.PadId, sessionCookie, &auth.Token, user)
 
 	if err != nil {
 		var arr = make([]interface{}, 2)
@@ -548,7 +674,8 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 		PadId:    thisSessionNewRetrieved.PadId,
 		AuthorId: thisSessionNewRetrieved.Author,
 	}
-	p.hooks.ExecuteHandleMessageHooks(hmCtx)
+	span.SetAttributes(attribute.String("etherpad.pad_id", thisSessionNewRetrieved.PadId))
+	p.hooks.ExecuteHooksContext(ctx, hooks.HandleMessageString, hmCtx)
 	if hmCtx.Dropped() {
 		return
 	}
@@ -556,7 +683,7 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 	switch expectedType := message.(type) {
 	case ws.ClientReady:
 		{
-			p.HandleClientReadyMessage(expectedType, client, thisSessionNewRetrieved, retrievedSettings, logger)
+			p.handleClientReady(ctx, expectedType, client, thisSessionNewRetrieved, retrievedSettings, logger)
 			return
 		}
 	case ws.ChangesetReq:
@@ -569,7 +696,11 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 			var currMillis = time.Now().UnixMilli()
 			chatMessage.Time = &currMillis
 			chatMessage.AuthorId = &thisSession.Author
-			p.SendChatMessageToPadClients(thisSession, chatMessage)
+			p.sendChatMessageToPadClients(ctx, thisSession, chatMessage)
+		}
+	case ws.ChatAction:
+		{
+			p.handleChatAction(ctx, client, thisSession, expectedType)
 		}
 	case ws.UserChange:
 		{
@@ -579,16 +710,18 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 					PadId:    thisSessionNewRetrieved.PadId,
 					AuthorId: thisSessionNewRetrieved.Author,
 				}
-				p.hooks.ExecuteHandleMessageSecurityHooks(secCtx)
+				p.hooks.ExecuteHooksContext(ctx, hooks.HandleMessageSecurityString, secCtx)
 				if !secCtx.WriteAccessGranted() {
 					p.Logger.Warn("write attempt on read-only pad")
 					return
 				}
 			}
 
-			p.padChannels.AddToQueue(client.Room, Task{
-				message: expectedType,
-				socket:  client,
+			p.padChannels.AddToQueue(thisSessionNewRetrieved.PadId, Task{
+				message:  expectedType,
+				socket:   client,
+				ctx:      ctx,
+				enqueued: time.Now(),
 			})
 		}
 	case SavedRevision:
@@ -598,7 +731,7 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 				p.Logger.Errorf("Session not found for saved revision")
 				return
 			}
-			foundPad, err := p.padManager.GetPad(sess.PadId, nil, nil)
+			foundPad, err := p.padManager.GetPadContext(ctx, sess.PadId, nil, nil)
 			if err != nil {
 				p.Logger.Errorf("Error retrieving pad for saved revision: %v", err)
 				return
@@ -627,12 +760,12 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 				return
 			}
 
-			retrievedPad, err := p.padManager.GetPad(thisSession.PadId, nil, &thisSession.Author)
+			retrievedPad, err := p.padManager.GetPadContext(ctx, thisSession.PadId, nil, &thisSession.Author)
 			if err != nil {
 				p.Logger.Warn("Error retrieving pad for chat messages", err)
 				return
 			}
-			chatMessages, err := retrievedPad.GetChatMessages(expectedType.Data.Data.Start, expectedType.Data.Data.End)
+			chatMessages, err := retrievedPad.GetChatMessagesContext(ctx, expectedType.Data.Data.Start, expectedType.Data.Data.End)
 			if err != nil {
 				p.Logger.Warn("Error retrieving chat messages", err)
 				return
@@ -640,15 +773,7 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 
 			var convertedMessages = make([]ws.ChatMessageSendData, 0, len(*chatMessages))
 			for _, msg := range *chatMessages {
-				convertedMessages = append(convertedMessages, ws.ChatMessageSendData{
-					Time:     msg.Time,
-					Text:     msg.Message,
-					UserId:   msg.AuthorId,
-					UserName: nil,
-				})
-				if msg.DisplayName != nil && *msg.DisplayName != "" {
-					convertedMessages[len(convertedMessages)-1].UserName = msg.DisplayName
-				}
+				convertedMessages = append(convertedMessages, ChatMessageSendData(msg))
 			}
 
 			var arr = make([]interface{}, 2)
@@ -671,11 +796,135 @@ func (p *PadMessageHandler) HandleMessage(message any, client *Client, retrieved
 		{
 			p.HandlePadDelete(client, expectedType)
 		}
+	case AuthorUndo:
+		{
+			if readonly {
+				p.Logger.Warn("undo attempt on read-only pad")
+				return
+			}
+			p.padChannels.AddToQueue(thisSessionNewRetrieved.PadId, Task{
+				socket:   client,
+				ctx:      ctx,
+				enqueued: time.Now(),
+				run: func() {
+					p.HandleAuthorUndo(client, thisSessionNewRetrieved, expectedType.Data.Data.Type == "AUTHOR_REDO")
+				},
+			})
+		}
 	default:
 		p.Logger.Warn("Unknown message type received")
 	}
 }
 
+// HandleAuthorUndo undoes or redoes the last edit of the session's author,
+// broadcasts the new revision and answers the client with an
+// AUTHOR_UNDO_RESULT.
+func (p *PadMessageHandler) HandleAuthorUndo(client *Client, session *ws.Session, redo bool) {
+	retrievedPad, err := p.padManager.GetPad(session.PadId, nil, &session.Author)
+	if err != nil {
+		p.Logger.Warnf("Error retrieving pad %s for undo: %v", session.PadId, err)
+		return
+	}
+
+	var newRev int
+	if redo {
+		newRev, err = p.padManager.Redo(retrievedPad, session.Author)
+	} else {
+		newRev, err = p.padManager.Undo(retrievedPad, session.Author)
+	}
+	result := AuthorUndoResultData{Type: "AUTHOR_UNDO_RESULT", NewRev: newRev}
+	if err != nil {
+		if !errors.Is(err, pad.ErrNothingToUndo) && !errors.Is(err, pad.ErrNothingToRedo) {
+			p.Logger.Errorf("Error undoing edit of %s on pad %s: %v", session.Author, session.PadId, err)
+		}
+		result.Error = err.Error()
+	}
+
+	var arr = make([]interface{}, 2)
+	arr[0] = "message"
+	arr[1] = AuthorUndoResultMessage{
+		Type: "COLLABROOM",
+		Data: result,
+	}
+	var bytes, _ = json.Marshal(arr)
+	client.SafeSend(bytes)
+
+	if err == nil {
+		p.UpdatePadClients(retrievedPad)
+	}
+}
+
+// UndoAuthorEdit undoes or redoes the last edit of authorId on retrievedPad
+// for a server-side caller, on the pad's queue like AUTHOR_UNDO, and updates
+// the connected clients. Returns the new revision.
+func (p *PadMessageHandler) UndoAuthorEdit(retrievedPad *pad2.Pad, authorId string, redo bool) (int, error) {
+	var newRev int
+	var err error
+	p.runInPadQueue(retrievedPad.Id, func() {
+		if redo {
+			newRev, err = p.padManager.Redo(retrievedPad, authorId)
+		} else {
+			newRev, err = p.padManager.Undo(retrievedPad, authorId)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	p.UpdatePadClients(retrievedPad)
+	return newRev, nil
+}
+
+// MergeFork merges fork into its source (see pad.Manager.ApplyMerge) and
+// updates the connected clients of both. The merge is prepared and applied
+// on the queues of both pads, so no edit lands on either pad between reading
+// and appending. Returns the merge and the new head of the source.
+func (p *PadMessageHandler) MergeFork(fork *pad2.Pad, authorId string, force bool) (*pad.Merge, int, error) {
+	if fork.Fork == nil {
+		return nil, 0, pad.ErrNotAFork
+	}
+	var merge *pad.Merge
+	var rev int
+	var err error
+	// The queues are taken in the order of the pad ids, so two merges
+	// between the same pads do not wait for each other.
+	first, second := fork.Fork.Source, fork.Id
+	if second < first {
+		first, second = second, first
+	}
+	p.runInPadQueue(first, func() {
+		p.runInPadQueue(second, func() {
+			if merge, err = p.padManager.PrepareMerge(fork); err != nil {
+				return
+			}
+			rev, err = p.padManager.ApplyMerge(merge, authorId, force)
+		})
+	})
+	if err != nil {
+		return merge, 0, err
+	}
+	p.UpdatePadClients(merge.Source)
+	p.UpdatePadClients(merge.Fork)
+	return merge, rev, nil
+}
+
+// ForkPad runs copyPad, which copies the pad sourceId to destinationId, on
+// the queue of the source and marks the copy as a fork of the source at the
+// revision it was copied at.
+func (p *PadMessageHandler) ForkPad(sourceId string, destinationId string, copyPad func() error) error {
+	var err error
+	p.runInPadQueue(sourceId, func() {
+		if err = copyPad(); err != nil {
+			return
+		}
+		var fork *pad2.Pad
+		if fork, err = p.padManager.GetPad(destinationId, nil, nil); err != nil {
+			return
+		}
+		err = p.padManager.MarkFork(fork, sourceId, fork.Head)
+	})
+	return err
+}
+
 func (p *PadMessageHandler) HandleChangesetRequest(socket *Client, message ws.ChangesetReq) {
 	if (message.Data.Data.Granularity <= 0) || (message.Data.Data.Start < 0) {
 		p.Logger.Warn("Invalid changeset request parameters")
@@ -933,6 +1182,12 @@ func (p *PadMessageHandler) getChangesetInfo(retrievedPad pad2.Pad, startNum int
 }
 
 func (p *PadMessageHandler) SendChatMessageToPadClients(session *ws.Session, chatMessage ws.ChatMessageData) {
+	p.sendChatMessageToPadClients(context.Background(), session, chatMessage)
+}
+
+// sendChatMessageToPadClients is SendChatMessageToPadClients with the
+// chatNewMessage hook traced as a child of the span in ctx.
+func (p *PadMessageHandler) sendChatMessageToPadClients(ctx context.Context, session *ws.Session, chatMessage ws.ChatMessageData) {
 	var chatAuthorId string
 	if chatMessage.AuthorId != nil {
 		chatAuthorId = *chatMessage.AuthorId
@@ -944,7 +1199,7 @@ func (p *PadMessageHandler) SendChatMessageToPadClients(session *ws.Session, cha
 		PadId:    session.PadId,
 		AuthorId: chatAuthorId,
 	}
-	p.hooks.ExecuteChatNewMessageHooks(cmCtx)
+	p.hooks.ExecuteHooksContext(ctx, hooks.ChatNewMessageString, cmCtx)
 	if cmCtx.Dropped() {
 		return
 	}
@@ -956,19 +1211,19 @@ func (p *PadMessageHandler) SendChatMessageToPadClients(session *ws.Session, cha
 		p.Logger.Warn("chatNewMessage hook set Text to nil; keeping original chat text")
 	}
 
-	var retrievedPad, err = p.padManager.GetPad(session.PadId, nil, chatMessage.AuthorId)
+	var retrievedPad, err = p.padManager.GetPadContext(ctx, session.PadId, nil, chatMessage.AuthorId)
 	if err != nil {
 		p.Logger.Warn("Error retrieving pad for chat message", err)
 		return
 	}
 	// pad.appendChatMessage() ignores the displayName property so we don't need to wait for
 	// authorManager.getAuthorName() to resolve before saving the message to the database.
-	_, err = retrievedPad.AppendChatMessage(chatMessage.AuthorId, *chatMessage.Time, chatMessage.Text)
+	posted, err := retrievedPad.PostChatMessageContext(ctx, chatMessage.AuthorId, *chatMessage.Time, chatMessage.Text, chatMessage.ReplyTo)
 	if err != nil {
 		p.Logger.Warn("Error appending chat message to pad", err)
 		return
 	}
-	authorName, err := p.authorManager.GetAuthorName(*chatMessage.AuthorId)
+	authorName, err := p.authorManager.WithContext(ctx).GetAuthorName(*chatMessage.AuthorId)
 	if err != nil {
 		p.Logger.Warn("Error retrieving author name for chat message", err)
 	}
@@ -988,6 +1243,9 @@ func (p *PadMessageHandler) SendChatMessageToPadClients(session *ws.Session, cha
 				Text:     chatMessage.Text,
 				UserId:   chatMessage.AuthorId,
 				UserName: chatMessage.DisplayName,
+				Head:     &posted.Head,
+				ReplyTo:  posted.ReplyTo,
+				Mentions: posted.Mentions,
 			},
 			},
 		}
@@ -996,6 +1254,7 @@ func (p *PadMessageHandler) SendChatMessageToPadClients(session *ws.Session, cha
 
 		socket.SafeSend(marshalledMessage)
 	}
+	p.SendChatMentions(retrievedPad, posted.Mentions)
 }
 
 // BroadcastSystemChatToRoom sends a chat message to all clients in a pad room without saving to the database.
@@ -1255,17 +1514,24 @@ func (p *PadMessageHandler) HandleDisconnectOfPadClient(client *Client, settings
 }
 
 func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, client *Client, thisSession *ws.Session, retrievedSettings *settings.Settings, logger *zap.SugaredLogger) {
+	p.handleClientReady(context.Background(), ready, client, thisSession, retrievedSettings, logger)
+}
+
+// handleClientReady is HandleClientReadyMessage with the clientVars, userJoin
+// and clientReady hooks traced as children of the span in ctx.
+func (p *PadMessageHandler) handleClientReady(ctx context.Context, ready ws.ClientReady, client *Client, thisSession *ws.Session, retrievedSettings *settings.Settings, logger *zap.SugaredLogger) {
+	authorManager := p.authorManager.WithContext(ctx)
 	if ready.Data.UserInfo.ColorId != nil && !colorRegEx.MatchString(*ready.Data.UserInfo.ColorId) {
 		p.Logger.Warn("Invalid color id")
 		ready.Data.UserInfo.ColorId = nil
 	}
 
 	if ready.Data.UserInfo.Name != nil {
-		p.authorManager.SetAuthorName(thisSession.Author, *ready.Data.UserInfo.Name)
+		authorManager.SetAuthorName(thisSession.Author, *ready.Data.UserInfo.Name)
 	}
 
 	if ready.Data.UserInfo.ColorId != nil {
-		p.authorManager.SetAuthorColor(thisSession.Author, *ready.Data.UserInfo.ColorId)
+		authorManager.SetAuthorColor(thisSession.Author, *ready.Data.UserInfo.ColorId)
 	}
 
 	// Spreadsheet documents take a separate path: SHEET_VARS instead of
@@ -1276,7 +1542,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 		return
 	}
 
-	var retrievedPad, err = p.padManager.GetPad(thisSession.PadId, nil, &thisSession.Author)
+	var retrievedPad, err = p.padManager.GetPadContext(ctx, thisSession.PadId, nil, &thisSession.Author)
 
 	if err != nil {
 		p.Logger.Warn("Error getting pad")
@@ -1300,7 +1566,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 
 	logger.Infof(loggerStr, argsForLogger...)
 
-	var foundAuthor, errAuth = p.authorManager.GetAuthor(thisSession.Author)
+	var foundAuthor, errAuth = authorManager.GetAuthor(thisSession.Author)
 
 	if errAuth != nil {
 		p.Logger.Warn("Error retrieving author")
@@ -1334,7 +1600,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 		authorIds = append(authorIds, authorId)
 	}
 
-	allAuthors, err := p.authorManager.GetAuthors(authorIds)
+	allAuthors, err := authorManager.GetAuthors(authorIds)
 	if err != nil {
 		p.Logger.Errorf("Error retrieving authors: %v", err)
 		return
@@ -1497,7 +1763,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 			PadId:      thisSession.PadId,
 			AuthorId:   thisSession.Author,
 		}
-		p.hooks.ExecuteClientVarsHooks(cvCtx)
+		p.hooks.ExecuteHooksContext(ctx, hooks.ClientVarsString, cvCtx)
 
 		// Initialize session time before broadcasting so timeDelta calculations
 		// in UpdatePadClients don't produce nonsense values. Upstream #7480.
@@ -1549,7 +1815,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 		}
 	}
 
-	retrievedAuthor, err := p.authorManager.GetAuthor(thisSession.Author)
+	retrievedAuthor, err := authorManager.GetAuthor(thisSession.Author)
 	if err != nil {
 		p.Logger.Warn("Error retrieving author for USER_NEWINFO broadcast")
 		return
@@ -1590,7 +1856,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 		if sinfo == nil {
 			continue
 		}
-		otherAuthor, err := p.authorManager.GetAuthor(sinfo.Author)
+		otherAuthor, err := authorManager.GetAuthor(sinfo.Author)
 		if err != nil {
 			p.Logger.Warn("Error retrieving author for USER_NEWINFO send to new client")
 			continue
@@ -1619,7 +1885,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 	}
 
 	// Fire userJoin hooks
-	p.hooks.ExecuteUserJoinHooks(&events.UserJoinLeaveContext{
+	p.hooks.ExecuteHooksContext(ctx, hooks.UserJoinString, &events.UserJoinLeaveContext{
 		PadId:    thisSession.PadId,
 		AuthorId: thisSession.Author,
 		BroadcastChat: func(message map[string]any) {
@@ -1632,7 +1898,7 @@ func (p *PadMessageHandler) HandleClientReadyMessage(ready ws.ClientReady, clien
 	if thisSession.Auth != nil {
 		clientReadyToken = thisSession.Auth.Token
 	}
-	p.hooks.ExecuteClientReadyHooks(&events.ClientReadyContext{
+	p.hooks.ExecuteHooksContext(ctx, hooks.ClientReadyString, &events.ClientReadyContext{
 		PadId:    thisSession.PadId,
 		AuthorId: thisSession.Author,
 		Token:    clientReadyToken,
@@ -2,9 +2,12 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/ether/etherpad-go/lib/metrics"
 	"github.com/ether/etherpad-go/lib/models/ws"
 	"github.com/ether/etherpad-go/lib/sheet"
 	"github.com/ether/etherpad-go/lib/sheetdoc"
@@ -14,6 +17,9 @@ import (
 type SheetTask struct {
 	socket  *Client
 	message ws.SheetOpIncoming
+	// run, when set, replaces the SHEET_OP handling (server-side edits that
+	// must be ordered with the clients' ops, e.g. a revision restore).
+	run func()
 }
 
 // SheetChannelOperator serializes SHEET_OPs per sheet document via one goroutine
@@ -22,31 +28,68 @@ type SheetChannelOperator struct {
 	channels map[string]chan SheetTask
 	handler  *PadMessageHandler
 	mu       sync.Mutex
+	depth    queueDepth
 }
 
 func NewSheetChannelOperator(p *PadMessageHandler) SheetChannelOperator {
 	return SheetChannelOperator{
 		channels: make(map[string]chan SheetTask),
 		handler:  p,
+		depth:    newQueueDepth(metrics.QueueSheet),
 	}
 }
 
+// AddToQueue runs t on the queue of the sheet ch. Like the text pad queues,
+// a worker exits once its queue is drained.
 func (c *SheetChannelOperator) AddToQueue(ch string, t SheetTask) {
 	c.mu.Lock()
 	chChan, ok := c.channels[ch]
 	if !ok {
 		chChan = make(chan SheetTask, 1)
 		c.channels[ch] = chChan
-		go func(localCh chan SheetTask) {
+		c.depth.worker()
+		go func(localCh chan SheetTask, padId string) {
 			for incomingTask := range localCh {
-				c.handler.handleSheetOp(incomingTask)
+				if incomingTask.run != nil {
+					incomingTask.run()
+				} else {
+					c.handler.handleSheetOp(incomingTask)
+				}
+				if c.finished(padId) {
+					return
+				}
 			}
-		}(chChan)
+		}(chChan, ch)
 	}
+	c.depth.enqueue(ch)
 	c.mu.Unlock()
 	chChan <- t
 }
 
+// finished marks a task of padId done and retires the sheet's worker when
+// its queue is empty.
+func (c *SheetChannelOperator) finished(padId string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.depth.done(padId) {
+		return false
+	}
+	delete(c.channels, padId)
+	c.depth.retire()
+	return true
+}
+
+// runInSheetQueue runs fn on the queue of the sheet padId, after the ops
+// waiting there, and waits for it to finish.
+func (p *PadMessageHandler) runInSheetQueue(padId string, fn func()) {
+	done := make(chan struct{})
+	p.sheetChannels.AddToQueue(padId, SheetTask{run: func() {
+		defer close(done)
+		fn()
+	}})
+	<-done
+}
+
 // SheetManager exposes the shared sheet document manager so HTTP handlers
 // (xlsx import/export) operate on the same live state as the websocket clients.
 func (p *PadMessageHandler) SheetManager() *sheetdoc.Manager {
@@ -68,6 +111,81 @@ func (p *PadMessageHandler) BroadcastSheetReload(padId string) {
 	}
 }
 
+// RestoreSheet brings a sheet back to its state at rev. It runs on the
+// document's serialization goroutine so the restore ops are broadcast in
+// revision order with the clients' own ops. Returns the new head.
+func (p *PadMessageHandler) RestoreSheet(padId string, rev int, authorId string) (int, error) {
+	type result struct {
+		head int
+		err  error
+	}
+	done := make(chan result, 1)
+	p.sheetChannels.AddToQueue(padId, SheetTask{run: func() {
+		var author *string
+		if authorId != "" {
+			author = &authorId
+		}
+		ops, head, err := p.sheetManager.Restore(padId, rev, author, time.Now().UnixMilli())
+		for i, op := range ops {
+			p.broadcastNewSheetOp(padId, "", op, head-len(ops)+i+1, authorId)
+		}
+		done <- result{head: head, err: err}
+	}})
+	res := <-done
+	return res.head, res.err
+}
+
+// ReplaceSheet replaces the workbook and comments of the sheet padId by what
+// replace returns for the current ones, and tells the clients to reload. It
+// runs on the document's serialization goroutine like RestoreSheet, so no op
+// is lost between reading and replacing the sheet.
+func (p *PadMessageHandler) ReplaceSheet(padId string, replace func(wb *sheet.Workbook, threads []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error)) error {
+	done := make(chan error, 1)
+	p.sheetChannels.AddToQueue(padId, SheetTask{run: func() {
+		snap, threads, _, err := p.sheetManager.State(padId)
+		if err != nil {
+			done <- err
+			return
+		}
+		wb, threads, err := replace(sheet.WorkbookFromSnapshot(snap), threads)
+		if err == nil {
+			err = p.sheetManager.SetWorkbook(padId, wb, threads)
+		}
+		if err == nil {
+			p.BroadcastSheetReload(padId)
+		}
+		done <- err
+	}})
+	return <-done
+}
+
+// SubmitSheetOps applies a batch of ops composed against baseRev (see
+// sheetdoc.Manager.SubmitBatch) on the document's serialization goroutine and
+// broadcasts them to every client, like RestoreSheet. An empty authorId is the
+// server itself. Returns the rebased ops and the new head.
+func (p *PadMessageHandler) SubmitSheetOps(padId string, ops []sheet.Op, baseRev int, authorId string) ([]sheet.Op, int, error) {
+	type result struct {
+		ops  []sheet.Op
+		head int
+		err  error
+	}
+	done := make(chan result, 1)
+	p.sheetChannels.AddToQueue(padId, SheetTask{run: func() {
+		var author *string
+		if authorId != "" {
+			author = &authorId
+		}
+		applied, head, err := p.sheetManager.SubmitBatch(padId, ops, baseRev, author, time.Now().UnixMilli())
+		for i, op := range applied {
+			metrics.SheetOps.WithLabelValues(string(op.Type)).Inc()
+			p.broadcastNewSheetOp(padId, "", op, head-len(applied)+i+1, authorId)
+		}
+		done <- result{ops: applied, head: head, err: err}
+	}})
+	res := <-done
+	return res.ops, res.head, res.err
+}
+
 // EnqueueSheetOp routes a SHEET_OP to the per-document serialization goroutine.
 // Keyed by the session's pad id so each document keeps a total order.
 func (p *PadMessageHandler) EnqueueSheetOp(client *Client, msg ws.SheetOpIncoming) {
@@ -82,30 +200,45 @@ func (p *PadMessageHandler) EnqueueSheetOp(client *Client, msg ws.SheetOpIncomin
 // handleSheetOp applies one op via the sheet document manager, acks the sender,
 // and broadcasts the rebased op to the other clients of the document.
 func (p *PadMessageHandler) handleSheetOp(task SheetTask) {
+	started := time.Now()
 	session := p.SessionStore.getSession(task.socket.SessionId)
 	if session == nil || session.PadId == "" {
 		return
 	}
 	if session.ReadOnly {
 		p.Logger.Warn("write attempt on read-only sheet")
+		metrics.SheetOpsRejected.WithLabelValues(sheetRejectReadOnly).Inc()
 		return
 	}
 
 	var op sheet.Op
 	if err := json.Unmarshal(task.message.Data.Data.Op, &op); err != nil {
 		p.Logger.Warn("bad sheet op: ", err)
+		metrics.SheetOpsRejected.WithLabelValues(sheetRejectMalformed).Inc()
 		return
 	}
 	op.BaseRev = task.message.Data.Data.BaseRev
 
 	author := session.Author
-	rebased, newRev, err := p.sheetManager.Submit(session.PadId, op, &author, time.Now().UnixMilli())
+	rebased, newRev, err := p.sheetManager.SubmitStep(session.PadId, op, &author, time.Now().UnixMilli(), task.message.Data.Data.Join)
 	if err != nil {
-		p.Logger.Warn("sheet submit failed: ", err)
+		reason := sheetRejectSubmit
+		switch {
+		case errors.Is(err, sheet.ErrProtected):
+			reason = sheetRejectProtected
+		case errors.Is(err, sheet.ErrInvalidValue):
+			reason = sheetRejectInvalid
+		default:
+			p.Logger.Warn("sheet submit failed: ", err)
+		}
+		metrics.SheetOpsRejected.WithLabelValues(reason).Inc()
+		p.sendRejectSheetOp(task.socket, err)
 		return
 	}
 
 	p.sendAcceptSheetOp(task.socket, newRev)
+	metrics.SheetOps.WithLabelValues(string(op.Type)).Inc()
+	metrics.Since(metrics.SheetOpApplyDuration, started)
 	p.broadcastNewSheetOp(session.PadId, task.socket.SessionId, rebased, newRev, author)
 }
 
@@ -121,6 +254,20 @@ func (p *PadMessageHandler) sendAcceptSheetOp(client *Client, newRev int) {
 	client.SafeSend(encoded)
 }
 
+// sendRejectSheetOp tells the sender its in-flight op was refused; without it
+// the client would wait for an ACCEPT forever.
+func (p *PadMessageHandler) sendRejectSheetOp(client *Client, cause error) {
+	msg := ws.RejectSheetOp{Type: "COLLABROOM"}
+	msg.Data.Type = "REJECT_SHEET_OP"
+	msg.Data.Reason = cause.Error()
+	encoded, err := json.Marshal([]any{"message", msg})
+	if err != nil {
+		p.Logger.Warn("marshal REJECT_SHEET_OP: ", err)
+		return
+	}
+	client.SafeSend(encoded)
+}
+
 func (p *PadMessageHandler) broadcastNewSheetOp(padId string, senderSessionId string, rebased sheet.Op, newRev int, author string) {
 	opBytes, err := json.Marshal(rebased)
 	if err != nil {
@@ -145,6 +292,164 @@ func (p *PadMessageHandler) broadcastNewSheetOp(padId string, senderSessionId st
 	}
 }
 
+// EnqueueSheetUndo routes a SHEET_UNDO or SHEET_REDO to the per-document
+// serialization goroutine, so the inverse ops are rebased against the same op
+// order the clients see.
+func (p *PadMessageHandler) EnqueueSheetUndo(client *Client, msg ws.SheetUndoIncoming) {
+	session := p.SessionStore.getSession(client.SessionId)
+	if session == nil || session.PadId == "" {
+		p.Logger.Warn("SHEET_UNDO before session ready")
+		return
+	}
+	if t := msg.Data.Data.Type; t != "SHEET_UNDO" && t != "SHEET_REDO" {
+		p.Logger.Warn("unexpected sheet undo type ", t)
+		return
+	}
+	p.sheetChannels.AddToQueue(session.PadId, SheetTask{run: func() {
+		p.handleSheetUndo(client, msg.Data.Data.Type == "SHEET_REDO")
+	}})
+}
+
+// handleSheetUndo undoes or redoes the last action of the session's author,
+// broadcasts the resulting ops to every client of the document, the sender
+// included, and answers the sender with a SHEET_UNDO_RESULT.
+func (p *PadMessageHandler) handleSheetUndo(client *Client, redo bool) {
+	session := p.SessionStore.getSession(client.SessionId)
+	if session == nil || session.PadId == "" {
+		return
+	}
+	result := ws.SheetUndoResultData{Type: "SHEET_UNDO_RESULT"}
+	if session.ReadOnly {
+		p.Logger.Warn("undo attempt on read-only sheet")
+		result.Error = "read-only sheet"
+		p.sendSheetUndoResult(client, result)
+		return
+	}
+
+	var (
+		ops  []sheet.Op
+		head int
+		err  error
+	)
+	if redo {
+		ops, head, err = p.sheetManager.Redo(session.PadId, session.Author, time.Now().UnixMilli())
+	} else {
+		ops, head, err = p.sheetManager.Undo(session.PadId, session.Author, time.Now().UnixMilli())
+	}
+	for i, op := range ops {
+		metrics.SheetOps.WithLabelValues(string(op.Type)).Inc()
+		p.broadcastNewSheetOp(session.PadId, "", op, head-len(ops)+i+1, session.Author)
+	}
+	if err != nil {
+		if !errors.Is(err, sheetdoc.ErrNothingToUndo) && !errors.Is(err, sheetdoc.ErrNothingToRedo) &&
+			!errors.Is(err, sheet.ErrProtected) && !errors.Is(err, sheet.ErrInvalidValue) {
+			p.Logger.Warn("sheet undo failed: ", err)
+		}
+		result.Error = err.Error()
+	}
+	if result.Undo, result.Redo, err = p.sheetManager.UndoDepth(session.PadId, session.Author); err != nil {
+		p.Logger.Warn("sheet undo depth: ", err)
+	}
+	p.sendSheetUndoResult(client, result)
+}
+
+func (p *PadMessageHandler) sendSheetUndoResult(client *Client, result ws.SheetUndoResultData) {
+	encoded, err := json.Marshal([]any{"message", ws.SheetUndoResult{Type: "COLLABROOM", Data: result}})
+	if err != nil {
+		p.Logger.Warn("marshal SHEET_UNDO_RESULT: ", err)
+		return
+	}
+	client.SafeSend(encoded)
+}
+
+// EnqueueSheetComment routes a SHEET_COMMENT to the per-document
+// serialization goroutine, so a new thread's anchor is rebased against the
+// same op order the clients see.
+func (p *PadMessageHandler) EnqueueSheetComment(client *Client, msg ws.SheetCommentIncoming) {
+	session := p.SessionStore.getSession(client.SessionId)
+	if session == nil || session.PadId == "" {
+		p.Logger.Warn("SHEET_COMMENT before session ready")
+		return
+	}
+	p.sheetChannels.AddToQueue(session.PadId, SheetTask{run: func() {
+		p.handleSheetComment(client, msg)
+	}})
+}
+
+// handleSheetComment applies one comment action and broadcasts the thread's
+// new state to every client of the document, the sender included.
+func (p *PadMessageHandler) handleSheetComment(client *Client, msg ws.SheetCommentIncoming) {
+	session := p.SessionStore.getSession(client.SessionId)
+	if session == nil || session.PadId == "" {
+		return
+	}
+	if session.ReadOnly {
+		p.sendRejectSheetComment(client, errors.New("read-only sheet"))
+		return
+	}
+
+	in := msg.Data.Data
+	var (
+		thread  sheet.CommentThread
+		deleted bool
+		head    int
+		err     error
+	)
+	switch in.Action {
+	case "add":
+		c := sheet.Comment{AuthorId: session.Author, Text: in.Text, Timestamp: time.Now().UnixMilli()}
+		if a, aerr := p.authorManager.GetAuthor(session.Author); aerr == nil && a != nil && a.Name != nil {
+			c.Author = *a.Name
+		}
+		thread, head, err = p.sheetManager.AddComment(session.PadId, in.ThreadId, in.Sheet,
+			sheet.CellRef{Row: in.Row, Col: in.Col}, in.BaseRev, c)
+	case "resolve", "reopen":
+		thread, head, err = p.sheetManager.ResolveThread(session.PadId, in.ThreadId, in.Action == "resolve")
+	case "delete":
+		thread, deleted, head, err = p.sheetManager.DeleteComment(session.PadId, in.ThreadId, in.CommentId, session.Author)
+	default:
+		err = fmt.Errorf("unknown comment action %q", in.Action)
+	}
+	if err != nil {
+		p.sendRejectSheetComment(client, err)
+		return
+	}
+	p.broadcastSheetComment(session.PadId, thread, deleted, head)
+}
+
+func (p *PadMessageHandler) sendRejectSheetComment(client *Client, cause error) {
+	msg := ws.RejectSheetComment{Type: "COLLABROOM"}
+	msg.Data.Type = "REJECT_SHEET_COMMENT"
+	msg.Data.Reason = cause.Error()
+	encoded, err := json.Marshal([]any{"message", msg})
+	if err != nil {
+		p.Logger.Warn("marshal REJECT_SHEET_COMMENT: ", err)
+		return
+	}
+	client.SafeSend(encoded)
+}
+
+func (p *PadMessageHandler) broadcastSheetComment(padId string, thread sheet.CommentThread, deleted bool, rev int) {
+	threadBytes, err := json.Marshal(thread)
+	if err != nil {
+		p.Logger.Warn("marshal comment thread: ", err)
+		return
+	}
+	msg := ws.SheetComment{Type: "COLLABROOM"}
+	msg.Data.Type = "SHEET_COMMENT"
+	msg.Data.Thread = threadBytes
+	msg.Data.Deleted = deleted
+	msg.Data.Rev = rev
+	encoded, err := json.Marshal([]any{"message", msg})
+	if err != nil {
+		p.Logger.Warn("marshal SHEET_COMMENT: ", err)
+		return
+	}
+	for _, socket := range p.GetRoomSockets(padId) {
+		socket.SafeSend(encoded)
+	}
+}
+
 // HandlePresence relays an ephemeral cursor / live-edit frame to the other
 // clients of the sheet. It is NOT persisted and NOT ordered through the per-doc
 // goroutine. Identity is stamped server-side from the session author (no client
@@ -223,6 +528,7 @@ func (p *PadMessageHandler) HandleSheetClientReady(ready ws.ClientReady, client
 		for i, op := range ops {
 			p.sendReconnectSheetOp(client, op, clientRev+i+1)
 		}
+		p.sendSheetComments(client, session)
 	} else {
 		p.sendSheetVars(client, session)
 	}
@@ -231,7 +537,7 @@ func (p *PadMessageHandler) HandleSheetClientReady(ready ws.ClientReady, client
 }
 
 func (p *PadMessageHandler) sendSheetVars(client *Client, session *ws.Session) {
-	snap, head, err := p.sheetManager.Snapshot(session.PadId)
+	snap, threads, head, err := p.sheetManager.State(session.PadId)
 	if err != nil {
 		p.Logger.Warn("sheet snapshot failed: ", err)
 		return
@@ -241,6 +547,11 @@ func (p *PadMessageHandler) sendSheetVars(client *Client, session *ws.Session) {
 		p.Logger.Warn("marshal snapshot: ", err)
 		return
 	}
+	threadBytes, err := json.Marshal(threads)
+	if err != nil {
+		p.Logger.Warn("marshal comment threads: ", err)
+		return
+	}
 
 	var color string
 	if a, err := p.authorManager.GetAuthor(session.Author); err == nil && a != nil {
@@ -253,6 +564,7 @@ func (p *PadMessageHandler) sendSheetVars(client *Client, session *ws.Session) {
 		UserId:    session.Author,
 		UserColor: color,
 		ReadOnly:  session.ReadOnly,
+		Comments:  threadBytes,
 	}}
 	encoded, err := json.Marshal([]any{"message", sv})
 	if err != nil {
@@ -278,6 +590,29 @@ func (p *PadMessageHandler) sendReconnectSheetOp(client *Client, op sheet.Op, re
 	client.SafeSend(encoded)
 }
 
+// sendSheetComments replaces a reconnecting client's comment threads; the
+// anchors of the ones it had may have moved with the ops it missed.
+func (p *PadMessageHandler) sendSheetComments(client *Client, session *ws.Session) {
+	threads, head, err := p.sheetManager.Comments(session.PadId)
+	if err != nil {
+		p.Logger.Warn("sheet comments failed: ", err)
+		return
+	}
+	threadBytes, err := json.Marshal(threads)
+	if err != nil {
+		return
+	}
+	msg := ws.SheetComments{Type: "COLLABROOM"}
+	msg.Data.Type = "SHEET_COMMENTS"
+	msg.Data.Threads = threadBytes
+	msg.Data.Rev = head
+	encoded, err := json.Marshal([]any{"message", msg})
+	if err != nil {
+		return
+	}
+	client.SafeSend(encoded)
+}
+
 // announceSheetPresence broadcasts the joining user's info to the other clients
 // of the document, reusing the text pad's USER_NEWINFO frame.
 func (p *PadMessageHandler) announceSheetPresence(client *Client, session *ws.Session) {
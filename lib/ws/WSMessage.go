@@ -1,10 +1,40 @@
 package ws
 
 import (
+	"encoding/json"
+
 	"github.com/ether/etherpad-go/lib/apool"
 	clientVars2 "github.com/ether/etherpad-go/lib/models/clientVars"
 )
 
+// incomingEnvelope is the part of an incoming message that names its type.
+// Messages of the collaboration room carry it one level deeper.
+type incomingEnvelope struct {
+	Data struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	} `json:"data"`
+}
+
+// messageType returns the type of an incoming message, or "" when it has
+// none.
+func messageType(message []byte) string {
+	var envelope incomingEnvelope
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		return ""
+	}
+	if envelope.Data.Type != "COLLABROOM" {
+		return envelope.Data.Type
+	}
+	var inner struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(envelope.Data.Data, &inner); err != nil {
+		return ""
+	}
+	return inner.Type
+}
+
 type Message struct {
 	Data clientVars2.ClientVars `json:"data"`
 	Type string                 `json:"type"`
@@ -99,6 +129,36 @@ type SavedRevisionDataData struct {
 	Type string `json:"type"`
 }
 
+// AuthorUndo asks to undo (AUTHOR_UNDO) or redo (AUTHOR_REDO) the last edit
+// of the session's author.
+type AuthorUndo struct {
+	Event string         `json:"event"`
+	Data  AuthorUndoData `json:"data"`
+}
+
+type AuthorUndoData struct {
+	Type      string             `json:"type"`
+	Component string             `json:"component"`
+	Data      AuthorUndoDataData `json:"data"`
+}
+
+type AuthorUndoDataData struct {
+	Type string `json:"type"`
+}
+
+// AuthorUndoResultData answers an AuthorUndo with the revision it wrote, or
+// with an error when there was nothing to undo or redo.
+type AuthorUndoResultData struct {
+	Type   string `json:"type"`
+	NewRev int    `json:"newRev,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type AuthorUndoResultMessage struct {
+	Type string               `json:"type"`
+	Data AuthorUndoResultData `json:"data"`
+}
+
 type PadDeleteMessage struct {
 	Disconnect string `json:"disconnect"`
 }
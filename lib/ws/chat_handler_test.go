@@ -0,0 +1,200 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/hooks"
+	modelws "github.com/ether/etherpad-go/lib/models/ws"
+)
+
+func buildChatAction(action string, head int, text string) modelws.ChatAction {
+	var m modelws.ChatAction
+	m.Event = "message"
+	m.Data.Component = "pad"
+	m.Data.Type = "COLLABROOM"
+	m.Data.Data.Type = action
+	m.Data.Data.Head = head
+	m.Data.Data.Text = text
+	return m
+}
+
+// drainFrames returns the frames queued for client.
+func drainFrames(client *Client) []string {
+	var frames []string
+	for {
+		select {
+		case frame := <-client.Send:
+			frames = append(frames, string(frame))
+		default:
+			return frames
+		}
+	}
+}
+
+func findFrame(t *testing.T, frames []string, msgType string, into any) {
+	t.Helper()
+	for _, frame := range frames {
+		if !strings.Contains(frame, `"type":"`+msgType+`"`) {
+			continue
+		}
+		var arr []json.RawMessage
+		if err := json.Unmarshal([]byte(frame), &arr); err != nil || len(arr) != 2 {
+			t.Fatalf("bad frame %s", frame)
+		}
+		if err := json.Unmarshal(arr[1], into); err != nil {
+			t.Fatalf("decode %s: %v", msgType, err)
+		}
+		return
+	}
+	t.Fatalf("no %s frame in %v", msgType, frames)
+}
+
+func TestChatEditDeleteAndMentions(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	hook := hooks.NewHook()
+	h.hooks = &hook
+	clients := map[string]*Client{}
+	for _, author := range []string{"a.owner", "a.ann", "a.bob"} {
+		sid := "sess-" + author
+		ss.InitSessionForTest(sid)
+		ss.SetPadIdForTest(sid, "p1")
+		ss.SetAuthorForTest(sid, author)
+		clients[author] = &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+		hub.Clients[clients[author]] = true
+	}
+	owner := "a.owner"
+	text := "hello\n"
+	retrievedPad, err := h.padManager.GetPad("p1", &text, &owner)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	ann := "a.ann"
+	if err := retrievedPad.SpliceText(0, 0, "x", &ann); err != nil {
+		t.Fatalf("SpliceText: %v", err)
+	}
+
+	// bob mentions ann, who is told about the unread mention.
+	bob := "a.bob"
+	now := int64(1000)
+	h.SendChatMessageToPadClients(ss.getSession("sess-a.bob"), modelws.ChatMessageData{Text: "hi @a.ann", Time: &now, AuthorId: &bob})
+	var mentions modelws.ChatMentions
+	findFrame(t, drainFrames(clients[ann]), "CHAT_MENTIONS", &mentions)
+	if mentions.Data.Unread != 1 {
+		t.Fatalf("expected 1 unread mention, got %d", mentions.Data.Unread)
+	}
+	drainFrames(clients[owner])
+	drainFrames(clients[bob])
+
+	// ann cannot edit bob's message.
+	h.HandleChatAction(clients[ann], ss.getSession("sess-a.ann"), buildChatAction("CHAT_EDIT", 0, "mine now"))
+	var reject modelws.RejectChat
+	findFrame(t, drainFrames(clients[ann]), "REJECT_CHAT", &reject)
+	if reject.Data.Head != 0 || reject.Data.Reason == "" {
+		t.Fatalf("unexpected reject %+v", reject.Data)
+	}
+
+	// bob can, and everyone sees the edit.
+	h.HandleChatAction(clients[bob], ss.getSession("sess-a.bob"), buildChatAction("CHAT_EDIT", 0, "hi all"))
+	var update modelws.ChatMessageUpdate
+	findFrame(t, drainFrames(clients[owner]), "CHAT_MESSAGE_UPDATE", &update)
+	if update.Data.Message.Text != "hi all" || update.Data.Message.EditedAt == nil {
+		t.Fatalf("unexpected update %+v", update.Data.Message)
+	}
+	// The edit dropped the mention.
+	findFrame(t, drainFrames(clients[ann]), "CHAT_MENTIONS", &mentions)
+	if mentions.Data.Unread != 0 {
+		t.Fatalf("expected no unread mentions, got %d", mentions.Data.Unread)
+	}
+
+	drainFrames(clients[bob])
+
+	// ann cannot delete it either, but the pad's creator can.
+	h.HandleChatAction(clients[ann], ss.getSession("sess-a.ann"), buildChatAction("CHAT_DELETE", 0, ""))
+	findFrame(t, drainFrames(clients[ann]), "REJECT_CHAT", &reject)
+	h.HandleChatAction(clients[owner], ss.getSession("sess-a.owner"), buildChatAction("CHAT_DELETE", 0, ""))
+	findFrame(t, drainFrames(clients[bob]), "CHAT_MESSAGE_UPDATE", &update)
+	if !update.Data.Message.Deleted || update.Data.Message.Text != "" ||
+		update.Data.Message.DeletedBy == nil || *update.Data.Message.DeletedBy != owner {
+		t.Fatalf("unexpected deletion %+v", update.Data.Message)
+	}
+}
+
+func TestChatReadClearsMentions(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	ss.InitSessionForTest("sess-ann")
+	ss.SetPadIdForTest("sess-ann", "p1")
+	ss.SetAuthorForTest("sess-ann", "a.ann")
+	client := &Client{SessionId: "sess-ann", Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	ann, bob := "a.ann", "a.bob"
+	text := "hello\n"
+	retrievedPad, err := h.padManager.GetPad("p1", &text, &ann)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := retrievedPad.PostChatMessage(&bob, int64(i), "@a.ann look", nil); err != nil {
+			t.Fatalf("PostChatMessage: %v", err)
+		}
+	}
+
+	var mentions modelws.ChatMentions
+	h.HandleChatAction(client, ss.getSession("sess-ann"), buildChatAction("CHAT_READ", 0, ""))
+	findFrame(t, drainFrames(client), "CHAT_MENTIONS", &mentions)
+	if mentions.Data.Unread != 1 {
+		t.Fatalf("expected 1 unread mention, got %d", mentions.Data.Unread)
+	}
+	h.HandleChatAction(client, ss.getSession("sess-ann"), buildChatAction("CHAT_READ", 99, ""))
+	findFrame(t, drainFrames(client), "CHAT_MENTIONS", &mentions)
+	if mentions.Data.Unread != 0 {
+		t.Fatalf("expected no unread mentions, got %d", mentions.Data.Unread)
+	}
+}
+
+func TestChatEditWaitsForPadQueue(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	ss.InitSessionForTest("sess-bob")
+	ss.SetPadIdForTest("sess-bob", "p1")
+	ss.SetAuthorForTest("sess-bob", "a.bob")
+	client := &Client{SessionId: "sess-bob", Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	bob := "a.bob"
+	text := "hello\n"
+	retrievedPad, err := h.padManager.GetPad("p1", &text, &bob)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	if _, err := retrievedPad.PostChatMessage(&bob, 1, "hi", nil); err != nil {
+		t.Fatalf("PostChatMessage: %v", err)
+	}
+
+	// An edit of the pad holds its queue.
+	busy, release := make(chan struct{}), make(chan struct{})
+	go h.runInPadQueue("p1", func() {
+		close(busy)
+		<-release
+	})
+	<-busy
+	edited := make(chan struct{})
+	go func() {
+		defer close(edited)
+		h.HandleChatAction(client, ss.getSession("sess-bob"), buildChatAction("CHAT_EDIT", 0, "hi all"))
+	}()
+	select {
+	case <-edited:
+		t.Fatal("chat edit ran while the pad queue was busy")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-edited
+	var update modelws.ChatMessageUpdate
+	findFrame(t, drainFrames(client), "CHAT_MESSAGE_UPDATE", &update)
+	if update.Data.Message.Text != "hi all" {
+		t.Fatalf("unexpected update %+v", update.Data.Message)
+	}
+}
@@ -8,9 +8,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"log"
-	"strings"
 	"time"
 
+	"github.com/ether/etherpad-go/lib/metrics"
 	"github.com/ether/etherpad-go/lib/models/ws"
 	"github.com/ether/etherpad-go/lib/models/ws/admin"
 	"github.com/ether/etherpad-go/lib/settings"
@@ -126,25 +126,25 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
 			}
+			metrics.WSDisconnects.WithLabelValues(closeReason(err)).Inc()
 			c.Handler.HandleDisconnectOfPadClient(c, retrievedSettings, logger)
 			break
 		}
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		decodedMessage := string(message[:])
-
-		// CommitRateLimiting only covers commits (USER_CHANGES / SHEET_OP), as
-		// in etherpad-lite. Ephemeral traffic like SHEET_PRESENCE arrives per
-		// keystroke and must not burn the commit budget — a drained budget
-		// silently drops the commit itself and edits are lost.
-		if strings.Contains(decodedMessage, "USER_CHANGES") || strings.Contains(decodedMessage, "SHEET_OP") {
-			retrievedSettings.CommitRateLimiting.LoadTest = retrievedSettings.LoadTest
-			if err := ratelimiter.CheckRateLimit(ratelimiter.IPAddress(c.ClientIP), retrievedSettings.CommitRateLimiting); err != nil {
+		// The text of a message, such as a chat line, may name another
+		// type, so dispatch on the type field itself.
+		msgType := messageType(message)
+		metrics.WSMessages.WithLabelValues(wireMessageType(msgType)).Inc()
+
+		if isCommit(msgType) {
+			if err := ratelimiter.CheckRateLimit(ratelimiter.IPAddress(c.ClientIP), retrievedSettings.CommitRateLimit()); err != nil {
 				logger.Warn("Rate limit exceeded:", err.Error())
 				continue
 			}
 		}
 
-		if strings.Contains(decodedMessage, "CLIENT_READY") {
+		switch msgType {
+		case "CLIENT_READY":
 			var clientReady ws.ClientReady
 			err := json.Unmarshal(message, &clientReady)
 			if err != nil {
@@ -152,7 +152,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			}
 
 			c.Handler.HandleMessage(clientReady, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "PAD_DELETE") {
+		case "PAD_DELETE":
 			var padDelete PadDelete
 			err := json.Unmarshal(message, &padDelete)
 			if err != nil {
@@ -160,7 +160,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 				continue
 			}
 			c.Handler.HandleMessage(padDelete, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "SAVE_REVISION") {
+		case "SAVE_REVISION":
 			var saveRevision SavedRevision
 			err := json.Unmarshal(message, &saveRevision)
 			if err != nil {
@@ -169,8 +169,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			}
 
 			c.Handler.HandleMessage(saveRevision, c, retrievedSettings, logger)
-
-		} else if strings.Contains(decodedMessage, "USER_CHANGES") {
+		case "USER_CHANGES":
 			var userchange ws.UserChange
 			err := json.Unmarshal(message, &userchange)
 
@@ -180,7 +179,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			}
 
 			c.Handler.HandleMessage(userchange, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "SHEET_OP") {
+		case "SHEET_OP":
 			var sheetOp ws.SheetOpIncoming
 			err := json.Unmarshal(message, &sheetOp)
 			if err != nil {
@@ -188,14 +187,28 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 				continue
 			}
 			c.Handler.EnqueueSheetOp(c, sheetOp)
-		} else if strings.Contains(decodedMessage, "SHEET_PRESENCE") {
+		case "SHEET_PRESENCE":
 			var presence ws.SheetPresenceIncoming
 			if err := json.Unmarshal(message, &presence); err != nil {
 				logger.Error("Error unmarshalling SHEET_PRESENCE: ", err)
 				continue
 			}
 			c.Handler.HandlePresence(c, presence)
-		} else if strings.Contains(decodedMessage, "USERINFO_UPDATE") {
+		case "SHEET_COMMENT":
+			var comment ws.SheetCommentIncoming
+			if err := json.Unmarshal(message, &comment); err != nil {
+				logger.Error("Error unmarshalling SHEET_COMMENT: ", err)
+				continue
+			}
+			c.Handler.EnqueueSheetComment(c, comment)
+		case "SHEET_UNDO", "SHEET_REDO":
+			var undo ws.SheetUndoIncoming
+			if err := json.Unmarshal(message, &undo); err != nil {
+				logger.Error("Error unmarshalling SHEET_UNDO: ", err)
+				continue
+			}
+			c.Handler.EnqueueSheetUndo(c, undo)
+		case "USERINFO_UPDATE":
 			var userInfoChange UserInfoUpdateWrapper
 			errorUserInfoChange := json.Unmarshal(message, &userInfoChange)
 
@@ -205,7 +218,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			}
 
 			c.Handler.HandleMessage(userInfoChange.Data, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "GET_CHAT_MESSAGES") {
+		case "GET_CHAT_MESSAGES":
 			var getChatMessages ws.GetChatMessages
 			err := json.Unmarshal(message, &getChatMessages)
 
@@ -215,7 +228,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			}
 
 			c.Handler.HandleMessage(getChatMessages, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "CHANGESET_REQ") {
+		case "CHANGESET_REQ":
 			var changesetReq ws.ChangesetReq
 			err := json.Unmarshal(message, &changesetReq)
 			if err != nil {
@@ -224,7 +237,7 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 			}
 
 			c.Handler.HandleMessage(changesetReq, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "CHAT_MESSAGE") {
+		case "CHAT_MESSAGE":
 			var chatMessage ws.ChatMessage
 			err := json.Unmarshal(message, &chatMessage)
 
@@ -233,7 +246,14 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 				continue
 			}
 			c.Handler.HandleMessage(chatMessage, c, retrievedSettings, logger)
-		} else if strings.Contains(decodedMessage, "CLIENT_MESSAGE") {
+		case "CHAT_EDIT", "CHAT_DELETE", "CHAT_READ":
+			var chatAction ws.ChatAction
+			if err := json.Unmarshal(message, &chatAction); err != nil {
+				logger.Error("Error unmarshalling ", msgType, ": ", err)
+				continue
+			}
+			c.Handler.HandleMessage(chatAction, c, retrievedSettings, logger)
+		case "CLIENT_MESSAGE":
 			var clientMessage ws.ClientMessage
 			err := json.Unmarshal(message, &clientMessage)
 
@@ -242,10 +262,34 @@ func (c *Client) readPump(retrievedSettings *settings.Settings, logger *zap.Suga
 				continue
 			}
 			c.Handler.HandleMessage(clientMessage, c, retrievedSettings, logger)
+		case "AUTHOR_UNDO", "AUTHOR_REDO":
+			var authorUndo AuthorUndo
+			err := json.Unmarshal(message, &authorUndo)
+
+			if err != nil {
+				logger.Error("Error unmarshalling AUTHOR_UNDO: ", err)
+				continue
+			}
+			c.Handler.HandleMessage(authorUndo, c, retrievedSettings, logger)
 		}
 	}
 }
 
+// isCommit reports whether messages of msgType count against
+// CommitRateLimiting. As in etherpad-lite it only covers commits:
+// USER_CHANGES and SHEET_OP, plus AUTHOR_UNDO / AUTHOR_REDO and SHEET_UNDO /
+// SHEET_REDO which write revisions too, and SHEET_COMMENT which persists a
+// comment. Ephemeral traffic like SHEET_PRESENCE arrives per keystroke and
+// must not burn the commit budget — a drained budget silently drops the
+// commit itself and edits are lost.
+func isCommit(msgType string) bool {
+	switch msgType {
+	case "USER_CHANGES", "SHEET_OP", "AUTHOR_UNDO", "AUTHOR_REDO", "SHEET_UNDO", "SHEET_REDO", "SHEET_COMMENT":
+		return true
+	}
+	return false
+}
+
 func (c *Client) Leave() {
 	c.Hub.Unregister <- c
 }
@@ -266,11 +310,13 @@ func (c *Client) SafeSend(message []byte) (sent bool) {
 }
 
 func (c *Client) SendUserDupMessage() {
+	countDisconnectRequest("userdup")
 	msg, _ := json.Marshal([]interface{}{"message", map[string]string{"disconnect": "userdup"}})
 	c.SafeSend(msg)
 }
 
 func (c *Client) SendPadDelete() {
+	countDisconnectRequest("deleted")
 	msg, _ := json.Marshal([]interface{}{"message", map[string]string{"disconnect": "deleted"}})
 	c.SafeSend(msg)
 }
@@ -281,6 +327,7 @@ func ServeWs(conn *websocket.Conn, sessionID string, integratorSessionID string,
 	logger *zap.SugaredLogger, handler *PadMessageHandler) {
 	client := &Client{Hub: handler.hub, Conn: conn, Send: make(chan []byte, 256), SessionId: sessionID, IntegratorSessionID: integratorSessionID, ClientIP: clientIP, WebAccessUser: webAccessUser, Handler: handler}
 	handler.SessionStore.initSession(sessionID)
+	metrics.WSConnects.Inc()
 	client.Hub.Register <- client
 	go client.writePump()
 	client.readPump(configSettings, logger)
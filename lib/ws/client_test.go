@@ -0,0 +1,171 @@
+package ws
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/settings"
+	"github.com/gofiber/contrib/v3/websocket"
+)
+
+// collabroom wraps data like collab_client's sendMessage does.
+func collabroom(component string, data string) string {
+	return `{"event":"message","data":{"type":"COLLABROOM","component":"` + component + `","data":` + data + `}}`
+}
+
+func TestMessageType(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{`{"event":"message","data":{"component":"pad","type":"CLIENT_READY","padId":"x","token":"USER_CHANGES"}}`, "CLIENT_READY"},
+		{`{"event":"message","data":{"component":"pad","type":"CHANGESET_REQ","data":{"start":0,"requestID":"PAD_DELETE"}}}`, "CHANGESET_REQ"},
+		{collabroom("pad", `{"type":"PAD_DELETE","padId":"CLIENT_READY"}`), "PAD_DELETE"},
+		{collabroom("pad", `{"type":"SAVE_REVISION"}`), "SAVE_REVISION"},
+		{collabroom("pad", `{"type":"USER_CHANGES","baseRev":1,"changeset":"Z:1>5*0+5$CHAT_MESSAGE"}`), "USER_CHANGES"},
+		{collabroom("pad", `{"type":"USERINFO_UPDATE","userInfo":{"name":"SAVE_REVISION"}}`), "USERINFO_UPDATE"},
+		{collabroom("pad", `{"type":"GET_CHAT_MESSAGES","start":0,"end":10}`), "GET_CHAT_MESSAGES"},
+		{collabroom("pad", `{"type":"CHAT_MESSAGE","message":{"text":"please USER_CHANGES"}}`), "CHAT_MESSAGE"},
+		{collabroom("pad", `{"type":"CHAT_EDIT","head":3,"text":"PAD_DELETE"}`), "CHAT_EDIT"},
+		{collabroom("pad", `{"type":"CHAT_DELETE","head":3}`), "CHAT_DELETE"},
+		{collabroom("pad", `{"type":"CHAT_READ","head":3}`), "CHAT_READ"},
+		{collabroom("pad", `{"type":"CLIENT_MESSAGE","payload":{"type":"AUTHOR_UNDO"}}`), "CLIENT_MESSAGE"},
+		{collabroom("pad", `{"type":"AUTHOR_UNDO"}`), "AUTHOR_UNDO"},
+		{collabroom("pad", `{"type":"AUTHOR_REDO"}`), "AUTHOR_REDO"},
+		{collabroom("sheet", `{"type":"SHEET_OP","baseRev":2,"op":{"value":"SHEET_COMMENT"}}`), "SHEET_OP"},
+		{collabroom("sheet", `{"type":"SHEET_PRESENCE","sheet":"s1","raw":"SHEET_OP"}`), "SHEET_PRESENCE"},
+		// Text naming another type does not change the type.
+		{collabroom("sheet", `{"type":"SHEET_COMMENT","text":"see USER_CHANGES and CLIENT_READY"}`), "SHEET_COMMENT"},
+		{collabroom("sheet", `{"type":"SHEET_UNDO"}`), "SHEET_UNDO"},
+		{collabroom("sheet", `{"type":"SHEET_REDO"}`), "SHEET_REDO"},
+		{collabroom("pad", `"USER_CHANGES"`), ""},
+		{`{"event":"message","data":{"type":"COLLABROOM"}}`, ""},
+		{`not json USER_CHANGES`, ""},
+	}
+	for _, tt := range tests {
+		if got := messageType([]byte(tt.message)); got != tt.want {
+			t.Errorf("messageType(%s) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestIsCommit(t *testing.T) {
+	tests := map[string]bool{
+		"USER_CHANGES":      true,
+		"SHEET_OP":          true,
+		"AUTHOR_UNDO":       true,
+		"AUTHOR_REDO":       true,
+		"SHEET_UNDO":        true,
+		"SHEET_REDO":        true,
+		"SHEET_COMMENT":     true,
+		"CLIENT_READY":      false,
+		"CHANGESET_REQ":     false,
+		"PAD_DELETE":        false,
+		"SAVE_REVISION":     false,
+		"USERINFO_UPDATE":   false,
+		"GET_CHAT_MESSAGES": false,
+		"CHAT_MESSAGE":      false,
+		"CHAT_EDIT":         false,
+		"CHAT_DELETE":       false,
+		"CHAT_READ":         false,
+		"CLIENT_MESSAGE":    false,
+		"SHEET_PRESENCE":    false,
+		"":                  false,
+	}
+	for msgType, want := range tests {
+		if got := isCommit(msgType); got != want {
+			t.Errorf("isCommit(%q) = %v, want %v", msgType, got, want)
+		}
+	}
+}
+
+// scriptedConn is a connection that reads messages, then blocks until done
+// is closed and reports the connection as closed. The messages after the
+// first are only read once first is closed.
+type scriptedConn struct {
+	*MockWebSocketConn
+	messages []string
+	first    chan struct{}
+	read     int
+	done     chan struct{}
+}
+
+func (s *scriptedConn) ReadMessage() (int, []byte, error) {
+	if len(s.messages) == 0 {
+		<-s.done
+		return 0, nil, io.EOF
+	}
+	if s.read > 0 {
+		<-s.first
+	}
+	s.read++
+	message := s.messages[0]
+	s.messages = s.messages[1:]
+	return websocket.TextMessage, []byte(message), nil
+}
+
+func TestReadPumpRoutesCollabroomAndChatMessages(t *testing.T) {
+	h, ss, hub, _ := newPadTestHandler(t)
+	client, authorId := joinPadForTest(t, h, ss, hub, "p1")
+	conn := &scriptedConn{MockWebSocketConn: NewActualMockWebSocketconn(), first: make(chan struct{}), done: make(chan struct{})}
+	client.Conn = conn
+	change, err := json.Marshal(insertX(authorId))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	conn.messages = []string{
+		string(change),
+		collabroom("pad", `{"type":"CHAT_MESSAGE","message":{"text":"USER_CHANGES and CLIENT_READY"}}`),
+		collabroom("pad", `{"type":"GET_CHAT_MESSAGES","start":0,"end":1}`),
+	}
+	go func() {
+		<-hub.Register
+		<-hub.Unregister
+	}()
+	stopped := make(chan struct{})
+	retrievedSettings := settings.Displayed
+	retrievedSettings.CommitRateLimiting = settings.CommitRateLimiting{Duration: 1, Points: 10}
+	go func() {
+		client.readPump(&retrievedSettings, h.Logger)
+		close(stopped)
+	}()
+
+	want := map[string]bool{"ACCEPT_COMMIT": false, "CHAT_MESSAGE": false, "CHAT_MESSAGES": false}
+	timeout := time.After(time.Second)
+	for seen := 0; seen < len(want); {
+		select {
+		case frame := <-client.Send:
+			for msgType, found := range want {
+				if !found && strings.Contains(string(frame), `"type":"`+msgType+`"`) {
+					want[msgType] = true
+					seen++
+					// The chat messages wait until the queued change is
+					// done, as the memory store is not safe for
+					// concurrent use.
+					if msgType == "ACCEPT_COMMIT" {
+						h.runInPadQueue("p1", func() {})
+						close(conn.first)
+					}
+				}
+			}
+		case <-timeout:
+			t.Fatalf("routed messages: %v", want)
+		}
+	}
+	close(conn.done)
+	<-stopped
+
+	retrievedPad, err := h.padManager.GetPad("p1", nil, nil)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	if retrievedPad.Text() != "x\n\n" {
+		t.Fatalf("pad text %q", retrievedPad.Text())
+	}
+	if retrievedPad.ChatHead != 0 {
+		t.Fatalf("chat head %d, want the one chat message", retrievedPad.ChatHead)
+	}
+}
@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMergeForkRacingSourceEdits(t *testing.T) {
+	h, _, _ := newSheetTestHandler(t)
+	text, ann, bob := "hello world", "a.ann", "a.bob"
+	source, err := h.padManager.GetPad("main", &text, &ann)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	fork, err := h.padManager.GetPad("draft", &text, &ann)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	if err := h.padManager.MarkFork(fork, source.Id, source.Head); err != nil {
+		t.Fatalf("MarkFork: %v", err)
+	}
+	if err := fork.SpliceText(11, 0, " again", &bob); err != nil {
+		t.Fatalf("SpliceText: %v", err)
+	}
+
+	// Edits of connected clients land on the source's queue while the
+	// merge runs.
+	const edits = 20
+	var wg sync.WaitGroup
+	for range edits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.runInPadQueue(source.Id, func() {
+				if err := source.SpliceText(0, 0, "x", &ann); err != nil {
+					t.Errorf("SpliceText: %v", err)
+				}
+			})
+		}()
+	}
+	_, rev, err := h.MergeFork(fork, bob, false)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("MergeFork: %v", err)
+	}
+	if rev > source.Head {
+		t.Fatalf("merge rev %d beyond head %d", rev, source.Head)
+	}
+	want := strings.Repeat("x", edits) + "hello world again\n"
+	if got := source.Text(); got != want {
+		t.Fatalf("source text %q, want %q", got, want)
+	}
+	if !strings.HasSuffix(fork.Text(), "hello world again\n") {
+		t.Fatalf("fork text %q", fork.Text())
+	}
+}
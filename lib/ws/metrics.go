@@ -0,0 +1,129 @@
+package ws
+
+import (
+	"sync"
+
+	"github.com/ether/etherpad-go/lib/metrics"
+	"github.com/gofiber/contrib/v3/websocket"
+)
+
+// Reasons for rejecting USER_CHANGES, reported as the reason label of
+// etherpad_changesets_rejected_total. Clients always see "badChangeset".
+const (
+	rejectStorage            = "storage"
+	rejectMalformed          = "malformed"
+	rejectForeignAuthor      = "foreignAuthor"
+	rejectUnattributedInsert = "unattributedInsert"
+	rejectSystemAuthor       = "systemAuthor"
+	rejectRebase             = "rebase"
+	rejectLengthMismatch     = "lengthMismatch"
+	rejectTrailingNewline    = "trailingNewline"
+	rejectUnexpectedHead     = "unexpectedHead"
+)
+
+// Reasons for dropping a SHEET_OP.
+const (
+	sheetRejectReadOnly  = "readOnly"
+	sheetRejectMalformed = "malformed"
+	sheetRejectSubmit    = "submit"
+	sheetRejectProtected = "protected"
+	sheetRejectInvalid   = "invalidValue"
+)
+
+// wsMessageTypes are the message types counted by name, the ones readPump
+// dispatches on; anything else is counted as "other".
+var wsMessageTypes = []string{
+	"CLIENT_READY",
+	"PAD_DELETE",
+	"SAVE_REVISION",
+	"USER_CHANGES",
+	"SHEET_OP",
+	"SHEET_PRESENCE",
+	"SHEET_COMMENT",
+	"SHEET_UNDO",
+	"SHEET_REDO",
+	"USERINFO_UPDATE",
+	"GET_CHAT_MESSAGES",
+	"CHANGESET_REQ",
+	"CHAT_MESSAGE",
+	"CHAT_EDIT",
+	"CHAT_DELETE",
+	"CHAT_READ",
+	"CLIENT_MESSAGE",
+	"AUTHOR_UNDO",
+	"AUTHOR_REDO",
+}
+
+// wireMessageType returns the label a message of type msgType, as read by
+// messageType, is counted under.
+func wireMessageType(msgType string) string {
+	return metrics.Bounded(msgType, wsMessageTypes...)
+}
+
+// closeReason maps the read error that ended a connection to a label.
+func closeReason(err error) string {
+	switch {
+	case websocket.IsCloseError(err, websocket.CloseNormalClosure):
+		return "normal"
+	case websocket.IsCloseError(err, websocket.CloseGoingAway):
+		return "goingAway"
+	case websocket.IsCloseError(err, websocket.CloseAbnormalClosure):
+		return "abnormal"
+	case websocket.IsCloseError(err, websocket.CloseMessageTooBig):
+		return "messageTooBig"
+	case websocket.IsUnexpectedCloseError(err):
+		return "closeCode"
+	default:
+		return "readError"
+	}
+}
+
+// disconnectReasons are the disconnect messages the server sends.
+var disconnectReasons = []string{"badChangeset", "userdup", "deleted", "kicked"}
+
+func countDisconnectRequest(reason string) {
+	metrics.WSDisconnectRequests.WithLabelValues(metrics.Bounded(reason, disconnectReasons...)).Inc()
+}
+
+// queueDepth tracks the tasks pending per pad of a ChannelOperator so the
+// depth a new task sees can be reported without a per-pad label.
+type queueDepth struct {
+	queue   string
+	mu      sync.Mutex
+	pending map[string]int
+}
+
+func newQueueDepth(queue string) queueDepth {
+	return queueDepth{queue: queue, pending: make(map[string]int)}
+}
+
+func (q *queueDepth) worker() {
+	metrics.QueueWorkers.WithLabelValues(q.queue).Inc()
+}
+
+func (q *queueDepth) enqueue(padId string) {
+	q.mu.Lock()
+	ahead := q.pending[padId]
+	q.pending[padId] = ahead + 1
+	q.mu.Unlock()
+	metrics.QueueDepth.WithLabelValues(q.queue).Observe(float64(ahead))
+	metrics.QueuePending.WithLabelValues(q.queue).Inc()
+}
+
+// done marks a task of padId finished and reports whether none is left.
+func (q *queueDepth) done(padId string) bool {
+	q.mu.Lock()
+	idle := q.pending[padId] <= 1
+	if idle {
+		delete(q.pending, padId)
+	} else {
+		q.pending[padId]--
+	}
+	q.mu.Unlock()
+	metrics.QueuePending.WithLabelValues(q.queue).Dec()
+	return idle
+}
+
+func (q *queueDepth) retire() {
+	metrics.QueueWorkers.WithLabelValues(q.queue).Dec()
+}
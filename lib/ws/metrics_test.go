@@ -0,0 +1,60 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWireMessageType(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"USER_CHANGES"}}}`, "USER_CHANGES"},
+		{`{"event":"message","data":{"type":"CLIENT_READY","padId":"x"}}`, "CLIENT_READY"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"SHEET_PRESENCE"}}}`, "SHEET_PRESENCE"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"SHEET_COMMENT","action":"add"}}}`, "SHEET_COMMENT"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"SHEET_UNDO"}}}`, "SHEET_UNDO"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"GET_CHAT_MESSAGES"}}}`, "GET_CHAT_MESSAGES"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"CHAT_MESSAGE"}}}`, "CHAT_MESSAGE"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"CHAT_EDIT","head":3,"text":"hi"}}}`, "CHAT_EDIT"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"AUTHOR_REDO"}}}`, "AUTHOR_REDO"},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"type":"CHAT_MESSAGE","text":"USER_CHANGES"}}}`, "CHAT_MESSAGE"},
+		{`{"event":"message","data":{"type":"something-else"}}`, metrics.Other},
+		{`{"event":"message","data":{"type":"COLLABROOM","data":{"text":"USER_CHANGES"}}}`, metrics.Other},
+	}
+	for _, tt := range tests {
+		if got := wireMessageType(messageType([]byte(tt.message))); got != tt.want {
+			t.Errorf("wireMessageType(%s) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestQueueDepth(t *testing.T) {
+	q := newQueueDepth("test")
+	pending := metrics.QueuePending.WithLabelValues("test")
+
+	q.enqueue("a")
+	q.enqueue("a")
+	q.enqueue("b")
+	if got := testutil.ToFloat64(pending); got != 3 {
+		t.Fatalf("pending = %v, want 3", got)
+	}
+	if q.pending["a"] != 2 || q.pending["b"] != 1 {
+		t.Fatalf("unexpected per-pad depth %v", q.pending)
+	}
+	q.done("a")
+	q.done("b")
+	if _, ok := q.pending["b"]; ok {
+		t.Error("drained pad should be forgotten")
+	}
+	if got := testutil.ToFloat64(pending); got != 1 {
+		t.Fatalf("pending = %v, want 1", got)
+	}
+	q.done("a")
+	if got := testutil.ToFloat64(pending); got != 0 {
+		t.Fatalf("pending = %v, want 0", got)
+	}
+}
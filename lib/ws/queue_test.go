@@ -0,0 +1,187 @@
+package ws
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ether/etherpad-go/lib/author"
+	db2 "github.com/ether/etherpad-go/lib/db"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/hooks/events"
+	modelws "github.com/ether/etherpad-go/lib/models/ws"
+	"github.com/ether/etherpad-go/lib/pad"
+	"github.com/ether/etherpad-go/lib/settings"
+)
+
+func TestChannelOperatorQueuesPerPad(t *testing.T) {
+	c := NewChannelOperator(nil)
+	release := make(chan struct{})
+	c.AddToQueue("p1", Task{run: func() { <-release }})
+	defer close(release)
+
+	// p1 is stuck, but p2 has its own worker.
+	done := make(chan struct{})
+	c.AddToQueue("p2", Task{run: func() { close(done) }})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a task of p2 waited on the queue of p1")
+	}
+}
+
+func TestChannelOperatorRetiresIdleWorkers(t *testing.T) {
+	c := NewChannelOperator(nil)
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		c.AddToQueue("p1", Task{run: func() {
+			defer wg.Done()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		}})
+	}
+	wg.Add(1)
+	c.AddToQueue("p2", Task{run: wg.Done})
+	wg.Wait()
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("tasks of one pad ran out of order: %v", order)
+		}
+	}
+
+	waitForNoWorkers(t, func() int {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.channels)
+	})
+
+	// A drained pad gets a new worker for its next task.
+	done := make(chan struct{})
+	c.AddToQueue("p1", Task{run: func() { close(done) }})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task after retirement never ran")
+	}
+}
+
+func TestSheetChannelOperatorRetiresIdleWorkers(t *testing.T) {
+	c := NewSheetChannelOperator(nil)
+	var wg sync.WaitGroup
+	for _, sheet := range []string{"s1", "s2", "s1"} {
+		wg.Add(1)
+		c.AddToQueue(sheet, SheetTask{run: wg.Done})
+	}
+	wg.Wait()
+
+	waitForNoWorkers(t, func() int {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.channels)
+	})
+}
+
+// waitForNoWorkers waits until workers reports no queue worker left.
+func waitForNoWorkers(t *testing.T, workers func() int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		left := workers()
+		if left == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d idle workers left", left)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newPadTestHandler returns a handler that checks access like the server,
+// with pads, authors and security on one store.
+func newPadTestHandler(t *testing.T) (*PadMessageHandler, *SessionStore, *Hub, *hooks.Hook) {
+	t.Helper()
+	h, ss, hub := newSheetTestHandler(t)
+	store := db2.NewMemoryDataStore()
+	hook := hooks.NewHook()
+	h.hooks = &hook
+	h.padManager = pad.NewManager(store, &hook)
+	h.authorManager = author.NewManager(store)
+	h.securityManager = pad.NewSecurityManager(store, &hook, h.padManager)
+	h.padChannels = NewChannelOperator(h)
+	return h, ss, hub, &hook
+}
+
+// joinPadForTest creates padId with an empty line and a client whose
+// session passed CLIENT_READY on it. It returns the client and its author.
+func joinPadForTest(t *testing.T, h *PadMessageHandler, ss *SessionStore, hub *Hub, padId string) (*Client, string) {
+	t.Helper()
+	token := "t.token" + padId
+	editor, err := h.authorManager.GetAuthorId(token)
+	if err != nil {
+		t.Fatalf("GetAuthorId: %v", err)
+	}
+	text := "\n"
+	if _, err := h.padManager.GetPad(padId, &text, &editor.Id); err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	sid := "sess-" + padId
+	ss.InitSessionForTest(sid)
+	ss.AddHandleClientInformationForTest(sid, padId, token)
+	ss.SetPadIdForTest(sid, padId)
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub, Handler: h}
+	hub.Clients[client] = true
+	return client, editor.Id
+}
+
+// insertX is a USER_CHANGES message of authorId inserting "x" into a pad
+// holding an empty line.
+func insertX(authorId string) modelws.UserChange {
+	var change modelws.UserChange
+	change.Event = "message"
+	change.Data.Type = "COLLABROOM"
+	change.Data.Component = "pad"
+	change.Data.Data.Type = "USER_CHANGES"
+	change.Data.Data.Changeset = "Z:2>1*0+1$x"
+	change.Data.Data.Apool.NumToAttrib = map[int][]string{0: {"author", authorId}}
+	change.Data.Data.Apool.NextNum = 1
+	return change
+}
+
+func TestUserChangesOfTwoPadsRunConcurrently(t *testing.T) {
+	h, ss, hub, hook := newPadTestHandler(t)
+
+	// Edits of p1 block in padUpdate until released.
+	entered, release := make(chan struct{}), make(chan struct{})
+	hook.EnqueuePadUpdateHook(func(ctx *events.PadUpdateContext) {
+		if ctx.PadId == "p1" {
+			close(entered)
+			<-release
+		}
+	})
+	defer close(release)
+
+	p1, author1 := joinPadForTest(t, h, ss, hub, "p1")
+	p2, author2 := joinPadForTest(t, h, ss, hub, "p2")
+	go h.HandleMessage(insertX(author1), p1, &settings.Displayed, h.Logger)
+	<-entered
+	go h.HandleMessage(insertX(author2), p2, &settings.Displayed, h.Logger)
+
+	// p1 is stuck in its edit, but the edit of p2 is accepted meanwhile.
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case frame := <-p2.Send:
+			if strings.Contains(string(frame), "ACCEPT_COMMIT") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the edit of p2 waited on the edit of p1")
+		}
+	}
+}
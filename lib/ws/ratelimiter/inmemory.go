@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ether/etherpad-go/lib/metrics"
 	"github.com/ether/etherpad-go/lib/settings"
 )
 
@@ -64,6 +65,7 @@ func CheckRateLimit(ip IPAddress, limiting settings.CommitRateLimiting) error {
 	defer rateLimiter.Mu.Unlock()
 	rateLimiter.RateLimiter[ip] = filteredEvents
 	if len(rateLimiter.RateLimiter[ip]) > limiting.Points {
+		metrics.RateLimitHits.Inc()
 		return ErrRateLimitExceeded{}
 	}
 	return nil
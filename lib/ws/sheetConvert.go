@@ -0,0 +1,219 @@
+package ws
+
+import (
+	"errors"
+
+	"github.com/ether/etherpad-go/lib/io"
+	pad2 "github.com/ether/etherpad-go/lib/models/pad"
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/ether/etherpad-go/lib/sheettext"
+)
+
+// MaxConvertCells caps the number of cells one sheet to pad conversion reads.
+const MaxConvertCells = 100000
+
+var (
+	// ErrInvalidPadId is returned for a conversion target that is no valid
+	// pad id.
+	ErrInvalidPadId = errors.New("invalid pad id")
+	// ErrPadNotFound is returned when the source pad of a conversion is missing.
+	ErrPadNotFound = errors.New("pad not found")
+	// ErrWrongDocumentType is returned when a conversion source or target is
+	// a sheet where a text pad is expected, or the other way round.
+	ErrWrongDocumentType = errors.New("wrong document type")
+	// ErrPadExists is returned when the sheet a pad is converted into exists.
+	ErrPadExists = errors.New("pad already exists")
+	// ErrWorksheetNotFound is returned for an unknown worksheet id or name.
+	ErrWorksheetNotFound = errors.New("worksheet not found")
+	// ErrInvalidRange is returned for a range that is not in A1 notation.
+	ErrInvalidRange = errors.New("invalid range")
+	// ErrRangeTooLarge is returned for a range over MaxConvertCells cells.
+	ErrRangeTooLarge = errors.New("range has too many cells")
+)
+
+// SheetToPadOptions selects what SheetToPad converts.
+type SheetToPadOptions struct {
+	// Worksheet is the id or name of the worksheet; empty means the first.
+	Worksheet string
+	// Range is an A1 range such as A1:D20; empty means every used cell.
+	Range string
+	// Delimiter separates the cells of a row, see sheettext.Options.
+	Delimiter rune
+}
+
+// SheetToPad writes a range of the sheet padId into the text pad targetId as
+// one line per row, replacing its content in a single revision. The target is
+// created when missing; its connected clients update live. An empty authorId
+// is the server itself.
+func (p *PadMessageHandler) SheetToPad(padId, targetId string, opts SheetToPadOptions, authorId string) (*pad2.Pad, error) {
+	source, err := p.existingPad(padId)
+	if err != nil {
+		return nil, err
+	}
+	if source.DocumentType != "sheet" {
+		return nil, ErrWrongDocumentType
+	}
+	var snap sheet.WorkbookSnapshot
+	p.runInSheetQueue(padId, func() {
+		snap, _, err = p.sheetManager.Snapshot(padId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	wb := sheet.WorkbookFromSnapshot(snap)
+	sh := convertWorksheet(wb, opts.Worksheet)
+	if sh == nil {
+		return nil, ErrWorksheetNotFound
+	}
+
+	var r0, c0, r1, c1 int
+	if opts.Range == "" {
+		var ok bool
+		if r0, c0, r1, c1, ok = sheettext.UsedRange(sh); !ok {
+			return nil, sheettext.ErrEmpty
+		}
+	} else {
+		var ok bool
+		if r0, c0, r1, c1, ok = sheet.ParseRangeName(opts.Range); !ok {
+			return nil, ErrInvalidRange
+		}
+	}
+	if sheet.RangeExceeds(r0, c0, r1, c1, MaxConvertCells) {
+		return nil, ErrRangeTooLarge
+	}
+	paragraphs, err := sheettext.ToParagraphs(wb, sh, r0, c0, r1, c1, sheettext.Options{Delimiter: opts.Delimiter})
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.padManager.IsValidPadId(targetId) {
+		return nil, ErrInvalidPadId
+	}
+	var author *string
+	if authorId != "" {
+		author = &authorId
+	}
+	// The target is looked up and written on its queue, so the new revision
+	// cannot race with the edits of connected clients.
+	var target *pad2.Pad
+	p.runInPadQueue(targetId, func() {
+		var exists *bool
+		if exists, err = p.padManager.DoesPadExist(targetId); err != nil {
+			return
+		}
+		if target, err = p.padManager.GetPad(targetId, nil, author); err != nil {
+			return
+		}
+		if *exists && target.DocumentType != "text" {
+			err = ErrWrongDocumentType
+			return
+		}
+		err = io.SetPadRich(target, paragraphs, authorId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.UpdatePadClients(target)
+	return target, nil
+}
+
+// PadToSheet reads the text pad padId into the new sheet targetId. HTML
+// tables become one worksheet each, other text is read as delimited lines
+// (delimiter 0 detects it). Returns the workbook of the new sheet.
+func (p *PadMessageHandler) PadToSheet(padId, targetId string, delimiter rune, authorId string) (*sheet.Workbook, error) {
+	if !p.padManager.IsValidPadId(targetId) {
+		return nil, ErrInvalidPadId
+	}
+
+	// The source is read on its queue, between two edits.
+	var wb *sheet.Workbook
+	var err error
+	p.runInPadQueue(padId, func() {
+		var source *pad2.Pad
+		if source, err = p.existingPad(padId); err != nil {
+			return
+		}
+		if source.DocumentType != "text" {
+			err = ErrWrongDocumentType
+			return
+		}
+		if text := source.Text(); sheettext.IsHTMLTable(text) {
+			wb, err = sheettext.FromHTML(text)
+			return
+		}
+		var paragraphs []io.RichParagraph
+		if paragraphs, err = io.ReadPadRich(source.AText, &source.Pool); err == nil {
+			wb, err = sheettext.FromParagraphs(paragraphs, sheettext.Options{Delimiter: delimiter})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var author *string
+	if authorId != "" {
+		author = &authorId
+	}
+	// The target is created on its sheet queue, so two conversions into the
+	// same id cannot both find it missing.
+	p.runInSheetQueue(targetId, func() {
+		var exists *bool
+		if exists, err = p.padManager.DoesPadExist(targetId); err != nil {
+			return
+		}
+		if *exists {
+			err = ErrPadExists
+			return
+		}
+		if _, err = p.padManager.GetTypedPad(targetId, "sheet", author); err != nil {
+			return
+		}
+		err = p.sheetManager.SetWorkbook(targetId, wb, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wb, nil
+}
+
+// optionalDelimiter parses a delimiter option, 0 when it is empty.
+func optionalDelimiter(s string) (rune, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return sheettext.ParseDelimiter(s)
+}
+
+// existingPad loads a pad without creating it.
+func (p *PadMessageHandler) existingPad(padId string) (*pad2.Pad, error) {
+	if !p.padManager.IsValidPadId(padId) {
+		return nil, ErrPadNotFound
+	}
+	exists, err := p.padManager.DoesPadExist(padId)
+	if err != nil {
+		return nil, err
+	}
+	if !*exists {
+		return nil, ErrPadNotFound
+	}
+	return p.padManager.GetPad(padId, nil, nil)
+}
+
+// convertWorksheet finds a worksheet by id, else by name; empty is the first.
+func convertWorksheet(wb *sheet.Workbook, idOrName string) *sheet.Sheet {
+	if idOrName == "" {
+		if len(wb.Sheets) == 0 {
+			return nil
+		}
+		return wb.Sheets[0]
+	}
+	if s := wb.SheetByID(idOrName); s != nil {
+		return s
+	}
+	for _, s := range wb.Sheets {
+		if s.Name == idOrName {
+			return s
+		}
+	}
+	return nil
+}
@@ -2,12 +2,16 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
+	"strconv"
 	"strings"
 	"testing"
 
 	db2 "github.com/ether/etherpad-go/lib/db"
 	modelws "github.com/ether/etherpad-go/lib/models/ws"
 	"github.com/ether/etherpad-go/lib/author"
+	"github.com/ether/etherpad-go/lib/hooks"
+	"github.com/ether/etherpad-go/lib/pad"
 	"github.com/ether/etherpad-go/lib/sheet"
 	"github.com/ether/etherpad-go/lib/sheetdoc"
 	"go.uber.org/zap"
@@ -18,13 +22,17 @@ func newSheetTestHandler(t *testing.T) (*PadMessageHandler, *SessionStore, *Hub)
 	store := db2.NewMemoryDataStore()
 	ss := NewSessionStore()
 	hub := NewHub()
+	hook := hooks.NewHook()
 	h := &PadMessageHandler{
 		SessionStore:  &ss,
 		hub:           hub,
 		Logger:        zap.NewNop().Sugar(),
+		padManager:    pad.NewManager(store, &hook),
 		sheetManager:  sheetdoc.NewManager(store),
 		authorManager: author.NewManager(store),
 	}
+	h.padChannels = NewChannelOperator(h)
+	h.sheetChannels = NewSheetChannelOperator(h)
 	return h, &ss, hub
 }
 
@@ -131,6 +139,37 @@ func TestHandleSheetOpReadOnlyRejected(t *testing.T) {
 	}
 }
 
+func TestHandleSheetOpProtectedRejected(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	const sid = "sess-p"
+	ss.InitSessionForTest(sid)
+	ss.SetPadIdForTest(sid, "p1")
+	ss.SetAuthorForTest(sid, "a.other")
+
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	protect := sheet.Op{Type: sheet.OpSetProtection, Sheet: sheetdoc.DefaultSheetID, Rule: &sheet.Rule{Id: "p", Authors: []string{"a.owner"}}}
+	if _, _, err := h.sheetManager.Submit("p1", protect, nil, 1); err != nil {
+		t.Fatalf("protect: %v", err)
+	}
+	raw := "no"
+	msg := buildSheetOpMsg(t, sheet.Op{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Row: 0, Col: 0, Raw: &raw}, 1)
+	h.handleSheetOp(SheetTask{socket: client, message: msg})
+
+	if _, head, _ := h.sheetManager.Snapshot("p1"); head != 1 {
+		t.Fatalf("protected op must not advance head, got %d", head)
+	}
+	select {
+	case frame := <-client.Send:
+		if !strings.Contains(string(frame), "REJECT_SHEET_OP") || !strings.Contains(string(frame), "protected") {
+			t.Fatalf("expected REJECT_SHEET_OP, got %s", string(frame))
+		}
+	default:
+		t.Fatal("sender did not receive a REJECT frame")
+	}
+}
+
 func buildPresenceMsg(sheet string, row, col int, editing bool, raw string) modelws.SheetPresenceIncoming {
 	var m modelws.SheetPresenceIncoming
 	m.Event = "message"
@@ -274,3 +313,379 @@ func TestHandlePresenceReadOnlyStripsLiveEdit(t *testing.T) {
 		t.Fatal("read-only cursor was not relayed")
 	}
 }
+
+func TestRestoreSheetBroadcastsRestoreOps(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	h.sheetChannels = NewSheetChannelOperator(h)
+	const sid = "sess-r"
+	ss.InitSessionForTest(sid)
+	ss.SetPadIdForTest(sid, "p1")
+	ss.SetAuthorForTest(sid, "a.1")
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	for i, raw := range []string{"old", "new"} {
+		if _, _, err := h.sheetManager.Submit("p1", sheet.Op{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Raw: &raw, BaseRev: i}, nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head, err := h.RestoreSheet("p1", 1, "a.admin")
+	if err != nil {
+		t.Fatalf("RestoreSheet: %v", err)
+	}
+	if head != 3 {
+		t.Fatalf("expected head 3, got %d", head)
+	}
+	select {
+	case frame := <-client.Send:
+		if !strings.Contains(string(frame), "NEW_SHEET_OP") || !strings.Contains(string(frame), `"newRev":3`) {
+			t.Fatalf("expected NEW_SHEET_OP for rev 3, got %s", string(frame))
+		}
+	default:
+		t.Fatal("client did not receive the restore op")
+	}
+}
+
+func TestReplaceSheetKeepsEarlierOpsAndReloads(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	h.sheetChannels = NewSheetChannelOperator(h)
+	const sid = "sess-i"
+	ss.InitSessionForTest(sid)
+	ss.SetPadIdForTest(sid, "p1")
+	ss.SetAuthorForTest(sid, "a.1")
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	raw := "kept"
+	h.EnqueueSheetOp(client, buildSheetOpMsg(t, sheet.Op{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Row: 0, Col: 0, Raw: &raw}, 0))
+	err := h.ReplaceSheet("p1", func(wb *sheet.Workbook, threads []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error) {
+		// The op queued before is applied by now.
+		if got := wb.SheetByID(sheetdoc.DefaultSheetID).GetCell(sheet.CellRef{Row: 0, Col: 0}).Raw; got != "kept" {
+			t.Errorf("replace saw A1 = %q", got)
+		}
+		wb.SheetByID(sheetdoc.DefaultSheetID).SetCell(sheet.CellRef{Row: 1, Col: 0}, sheet.Cell{Raw: "added"})
+		return wb, threads, nil
+	})
+	if err != nil {
+		t.Fatalf("ReplaceSheet: %v", err)
+	}
+	snap, _, err := h.sheetManager.Snapshot("p1")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if got := sheet.WorkbookFromSnapshot(snap).SheetByID(sheetdoc.DefaultSheetID).GetCell(sheet.CellRef{Row: 1, Col: 0}).Raw; got != "added" {
+		t.Fatalf("A2 = %q", got)
+	}
+	if frames := drainFrames(client); len(frames) == 0 || !strings.Contains(frames[len(frames)-1], "SHEET_RELOAD") {
+		t.Fatalf("expected SHEET_RELOAD, got %v", frames)
+	}
+
+	refused := errors.New("bad file")
+	if err := h.ReplaceSheet("p1", func(*sheet.Workbook, []sheet.CommentThread) (*sheet.Workbook, []sheet.CommentThread, error) {
+		return nil, nil, refused
+	}); !errors.Is(err, refused) {
+		t.Fatalf("expected the replace error, got %v", err)
+	}
+}
+
+func buildSheetCommentMsg(action, threadId string, row, col int, text string) modelws.SheetCommentIncoming {
+	var m modelws.SheetCommentIncoming
+	m.Event = "message"
+	m.Data.Component = "sheet"
+	m.Data.Type = "COLLABROOM"
+	m.Data.Data.Type = "SHEET_COMMENT"
+	m.Data.Data.Action = action
+	m.Data.Data.ThreadId = threadId
+	m.Data.Data.Sheet = sheetdoc.DefaultSheetID
+	m.Data.Data.Row = row
+	m.Data.Data.Col = col
+	m.Data.Data.Text = text
+	return m
+}
+
+func TestHandleSheetCommentBroadcastsToEveryone(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	const sidA, sidB = "sess-a", "sess-b"
+	for _, sid := range []string{sidA, sidB} {
+		ss.InitSessionForTest(sid)
+		ss.SetPadIdForTest(sid, "p1")
+		ss.SetAuthorForTest(sid, "a."+sid)
+	}
+	a := &Client{SessionId: sidA, Send: make(chan []byte, 256), Hub: hub}
+	b := &Client{SessionId: sidB, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[a] = true
+	hub.Clients[b] = true
+
+	h.handleSheetComment(a, buildSheetCommentMsg("add", "", 1, 2, "check this"))
+
+	for name, c := range map[string]*Client{"sender": a, "other": b} {
+		select {
+		case frame := <-c.Send:
+			var out []json.RawMessage
+			if err := json.Unmarshal(frame, &out); err != nil || len(out) != 2 {
+				t.Fatalf("%s: bad frame %s", name, frame)
+			}
+			var msg modelws.SheetComment
+			if err := json.Unmarshal(out[1], &msg); err != nil || msg.Data.Type != "SHEET_COMMENT" {
+				t.Fatalf("%s: expected SHEET_COMMENT, got %s", name, frame)
+			}
+			var th sheet.CommentThread
+			if err := json.Unmarshal(msg.Data.Thread, &th); err != nil {
+				t.Fatalf("%s: thread: %v", name, err)
+			}
+			if th.Cell != (sheet.CellRef{Row: 1, Col: 2}) || len(th.Comments) != 1 || th.Comments[0].AuthorId != "a."+sidA {
+				t.Fatalf("%s: unexpected thread %+v", name, th)
+			}
+		default:
+			t.Fatalf("%s did not receive SHEET_COMMENT", name)
+		}
+	}
+}
+
+func TestHandleSheetCommentRejected(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	const sid, sidRO = "sess-1", "sess-ro"
+	for _, s := range []string{sid, sidRO} {
+		ss.InitSessionForTest(s)
+		ss.SetPadIdForTest(s, "p1")
+		ss.SetAuthorForTest(s, "a."+s)
+	}
+	ss.SetReadOnlyForTest(sidRO, true)
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+	ro := &Client{SessionId: sidRO, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+	hub.Clients[ro] = true
+
+	h.handleSheetComment(ro, buildSheetCommentMsg("add", "", 0, 0, "nope"))
+	h.handleSheetComment(client, buildSheetCommentMsg("resolve", "t.missing", 0, 0, ""))
+	for _, c := range []*Client{ro, client} {
+		select {
+		case frame := <-c.Send:
+			if !strings.Contains(string(frame), "REJECT_SHEET_COMMENT") {
+				t.Fatalf("expected REJECT_SHEET_COMMENT, got %s", frame)
+			}
+		default:
+			t.Fatal("no REJECT_SHEET_COMMENT frame")
+		}
+	}
+	if threads, _, _ := h.sheetManager.Comments("p1"); len(threads) != 0 {
+		t.Fatalf("a rejected comment must not be stored, got %+v", threads)
+	}
+}
+
+func TestSubmitSheetOpsBroadcastsBatch(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	h.sheetChannels = NewSheetChannelOperator(h)
+	const sid = "sess-b"
+	ss.InitSessionForTest(sid)
+	ss.SetPadIdForTest(sid, "p1")
+	ss.SetAuthorForTest(sid, "a.1")
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	a, b := "a", "b"
+	ops := []sheet.Op{
+		{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Raw: &a},
+		{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Row: 1, Raw: &b},
+	}
+	applied, head, err := h.SubmitSheetOps("p1", ops, 0, "")
+	if err != nil {
+		t.Fatalf("SubmitSheetOps: %v", err)
+	}
+	if head != 2 || len(applied) != 2 {
+		t.Fatalf("head %d, applied %+v", head, applied)
+	}
+	for rev := 1; rev <= 2; rev++ {
+		select {
+		case frame := <-client.Send:
+			if !strings.Contains(string(frame), "NEW_SHEET_OP") || !strings.Contains(string(frame), `"newRev":`+strconv.Itoa(rev)) {
+				t.Fatalf("expected NEW_SHEET_OP for rev %d, got %s", rev, string(frame))
+			}
+		default:
+			t.Fatalf("client did not receive rev %d", rev)
+		}
+	}
+}
+
+func TestSheetToPadAndBack(t *testing.T) {
+	h, _, _ := newSheetTestHandler(t)
+	if _, err := h.padManager.GetTypedPad("grid", "sheet", nil); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	wb := sheet.NewWorkbook()
+	sh := wb.AddSheet(sheetdoc.DefaultSheetID, "Data")
+	bold := wb.Styles.Put(sheet.Style{Props: map[string]string{"bold": "1"}})
+	sh.SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "Item", StyleId: bold})
+	sh.SetCell(sheet.CellRef{Row: 0, Col: 1}, sheet.Cell{Raw: "Qty", StyleId: bold})
+	sh.SetCell(sheet.CellRef{Row: 1, Col: 0}, sheet.Cell{Raw: "Tea"})
+	sh.SetCell(sheet.CellRef{Row: 1, Col: 1}, sheet.Cell{Raw: "3"})
+	if err := h.sheetManager.SetWorkbook("grid", wb, nil); err != nil {
+		t.Fatalf("SetWorkbook: %v", err)
+	}
+
+	text, err := h.SheetToPad("grid", "notes", SheetToPadOptions{Worksheet: "Data"}, "")
+	if err != nil {
+		t.Fatalf("SheetToPad: %v", err)
+	}
+	if got := text.Text(); got != "Item | Qty\nTea | 3\n" {
+		t.Fatalf("pad text %q", got)
+	}
+	if _, err := h.SheetToPad("notes", "x", SheetToPadOptions{}, ""); !errors.Is(err, ErrWrongDocumentType) {
+		t.Fatalf("text pad as source: %v", err)
+	}
+	if _, err := h.SheetToPad("grid", "grid", SheetToPadOptions{}, ""); !errors.Is(err, ErrWrongDocumentType) {
+		t.Fatalf("sheet as target: %v", err)
+	}
+	if _, err := h.SheetToPad("grid", "notes", SheetToPadOptions{Range: "A1:"}, ""); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("bad range: %v", err)
+	}
+	if _, err := h.SheetToPad("grid", "notes", SheetToPadOptions{Range: "A1:ZZZ600000000000000"}, ""); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("range beyond the grid: %v", err)
+	}
+	if _, err := h.SheetToPad("grid", "notes", SheetToPadOptions{Range: "A1:XFD1048576"}, ""); !errors.Is(err, ErrRangeTooLarge) {
+		t.Fatalf("huge range: %v", err)
+	}
+
+	back, err := h.PadToSheet("notes", "grid2", 0, "")
+	if err != nil {
+		t.Fatalf("PadToSheet: %v", err)
+	}
+	out := back.Sheets[0]
+	if out.GetCell(sheet.CellRef{Row: 1, Col: 1}).Raw != "3" {
+		t.Fatalf("cells %v", out.Cells)
+	}
+	if style, _ := back.Styles.Get(out.GetCell(sheet.CellRef{Row: 0, Col: 1}).StyleId); style.Props["bold"] != "1" {
+		t.Fatalf("Qty lost bold: %v", style.Props)
+	}
+	created, err := h.padManager.GetPad("grid2", nil, nil)
+	if err != nil || created.DocumentType != "sheet" {
+		t.Fatalf("grid2: %v %+v", err, created)
+	}
+	if _, head, err := h.sheetManager.Snapshot("grid2"); err != nil || head != 0 {
+		t.Fatalf("grid2 sheet: head %d, %v", head, err)
+	}
+	if _, err := h.PadToSheet("notes", "grid", 0, ""); !errors.Is(err, ErrPadExists) {
+		t.Fatalf("existing target: %v", err)
+	}
+}
+
+func TestPadToSheetCreatesTheTargetOnce(t *testing.T) {
+	h, _, _ := newSheetTestHandler(t)
+	text := "a,b\n1,2"
+	if _, err := h.padManager.GetPad("notes", &text, nil); err != nil {
+		t.Fatalf("create pad: %v", err)
+	}
+
+	const runs = 8
+	errs := make(chan error, runs)
+	for range runs {
+		go func() {
+			_, err := h.PadToSheet("notes", "grid", 0, "")
+			errs <- err
+		}()
+	}
+	created := 0
+	for range runs {
+		switch err := <-errs; {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrPadExists):
+			t.Fatalf("PadToSheet: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d conversions created the sheet, want 1", created)
+	}
+}
+
+func TestHandleSheetUndoBroadcastsInverse(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	const sidA, sidB = "sess-a", "sess-b"
+	for _, sid := range []string{sidA, sidB} {
+		ss.InitSessionForTest(sid)
+		ss.SetPadIdForTest(sid, "p1")
+		ss.SetAuthorForTest(sid, "a."+sid)
+	}
+	a := &Client{SessionId: sidA, Send: make(chan []byte, 256), Hub: hub}
+	b := &Client{SessionId: sidB, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[a] = true
+	hub.Clients[b] = true
+
+	raw := "gone"
+	h.handleSheetOp(SheetTask{socket: a, message: buildSheetOpMsg(t, sheet.Op{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Row: 1, Col: 0, Raw: &raw}, 0)})
+	h.handleSheetOp(SheetTask{socket: a, message: buildSheetOpMsg(t, sheet.Op{Type: sheet.OpDeleteRows, Sheet: sheetdoc.DefaultSheetID, Index: 1, Count: 1}, 1)})
+	// b edits concurrently, against the revision before the delete.
+	other := "b"
+	h.handleSheetOp(SheetTask{socket: b, message: buildSheetOpMsg(t, sheet.Op{Type: sheet.OpSetCell, Sheet: sheetdoc.DefaultSheetID, Row: 2, Col: 1, Raw: &other}, 1)})
+	for _, c := range []*Client{a, b} {
+		for len(c.Send) > 0 {
+			<-c.Send
+		}
+	}
+
+	h.handleSheetUndo(a, false)
+
+	snap, _, err := h.sheetManager.Snapshot("p1")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	s := sheet.WorkbookFromSnapshot(snap).SheetByID(sheetdoc.DefaultSheetID)
+	if s.GetCell(sheet.CellRef{Row: 1, Col: 0}).Raw != "gone" || s.GetCell(sheet.CellRef{Row: 2, Col: 1}).Raw != "b" {
+		t.Fatalf("undo did not restore the row around b's edit: %+v", s.Cells)
+	}
+	for name, c := range map[string]*Client{"sender": a, "other": b} {
+		select {
+		case frame := <-c.Send:
+			if !strings.Contains(string(frame), "NEW_SHEET_OP") || !strings.Contains(string(frame), `"insertRows"`) {
+				t.Fatalf("%s: expected the insertRows broadcast, got %s", name, frame)
+			}
+		default:
+			t.Fatalf("%s did not receive the undo ops", name)
+		}
+	}
+	var result modelws.SheetUndoResultData
+	for len(a.Send) > 0 {
+		var out []json.RawMessage
+		if err := json.Unmarshal(<-a.Send, &out); err != nil || len(out) != 2 {
+			t.Fatal("bad frame")
+		}
+		var msg modelws.SheetUndoResult
+		if json.Unmarshal(out[1], &msg) == nil && msg.Data.Type == "SHEET_UNDO_RESULT" {
+			result = msg.Data
+		}
+	}
+	if result.Type == "" || result.Error != "" || result.Undo != 1 || result.Redo != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	h.handleSheetUndo(a, false)
+	h.handleSheetUndo(a, false)
+	for len(a.Send) > 1 {
+		<-a.Send
+	}
+	if frame := <-a.Send; !strings.Contains(string(frame), sheetdoc.ErrNothingToUndo.Error()) {
+		t.Fatalf("expected nothing to undo, got %s", frame)
+	}
+}
+
+func TestHandleSheetUndoReadOnly(t *testing.T) {
+	h, ss, hub := newSheetTestHandler(t)
+	const sid = "sess-ro"
+	ss.InitSessionForTest(sid)
+	ss.SetPadIdForTest(sid, "p1")
+	ss.SetAuthorForTest(sid, "a.ro")
+	ss.SetReadOnlyForTest(sid, true)
+	client := &Client{SessionId: sid, Send: make(chan []byte, 256), Hub: hub}
+	hub.Clients[client] = true
+
+	h.handleSheetUndo(client, true)
+	select {
+	case frame := <-client.Send:
+		if !strings.Contains(string(frame), "SHEET_UNDO_RESULT") || !strings.Contains(string(frame), "read-only") {
+			t.Fatalf("expected a read-only result, got %s", frame)
+		}
+	default:
+		t.Fatal("no SHEET_UNDO_RESULT frame")
+	}
+}
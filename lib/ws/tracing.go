@@ -0,0 +1,31 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/ether/etherpad-go/lib/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanPhases traces consecutive phases of one operation as sibling child
+// spans named <prefix>.<phase>; starting a phase ends the previous one.
+type spanPhases struct {
+	ctx     context.Context
+	prefix  string
+	current trace.Span
+}
+
+// start begins the next phase and returns its context for nested spans.
+func (s *spanPhases) start(name string) context.Context {
+	s.end()
+	ctx, span := tracing.Start(s.ctx, s.prefix+"."+name)
+	s.current = span
+	return ctx
+}
+
+func (s *spanPhases) end() {
+	if s.current != nil {
+		s.current.End()
+		s.current = nil
+	}
+}
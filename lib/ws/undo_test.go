@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ether/etherpad-go/lib/pad"
+)
+
+func TestUndoAuthorEditRunsOnPadQueue(t *testing.T) {
+	h, _, _ := newSheetTestHandler(t)
+	text, ann := "hello", "a.ann"
+	retrievedPad, err := h.padManager.GetPad("p1", &text, &ann)
+	if err != nil {
+		t.Fatalf("GetPad: %v", err)
+	}
+	if err := retrievedPad.SpliceText(5, 0, " world", &ann); err != nil {
+		t.Fatalf("SpliceText: %v", err)
+	}
+
+	rev, err := h.UndoAuthorEdit(retrievedPad, ann, false)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if rev != retrievedPad.Head || retrievedPad.Text() != "hello\n" {
+		t.Fatalf("after undo: rev %d of head %d, text %q", rev, retrievedPad.Head, retrievedPad.Text())
+	}
+	if _, err := h.UndoAuthorEdit(retrievedPad, ann, true); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if retrievedPad.Text() != "hello world\n" {
+		t.Fatalf("after redo: %q", retrievedPad.Text())
+	}
+	if _, err := h.UndoAuthorEdit(retrievedPad, "a.bob", false); !errors.Is(err, pad.ErrNothingToUndo) {
+		t.Fatalf("undo without edits: %v", err)
+	}
+}
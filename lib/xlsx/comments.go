@@ -0,0 +1,86 @@
+package xlsx
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportComments writes the comment threads of a sheet as notes, one per
+// thread, each comment as a bold "Author:" line over its text. Notes have no
+// resolved state, so resolved threads are written like open ones.
+func exportComments(f *excelize.File, name string, threads []sheet.CommentThread) error {
+	for _, t := range threads {
+		if len(t.Comments) == 0 {
+			continue
+		}
+		axis, err := excelize.CoordinatesToCellName(t.Cell.Col+1, t.Cell.Row+1)
+		if err != nil {
+			return err
+		}
+		var runs []excelize.RichTextRun
+		for i, c := range t.Comments {
+			if i > 0 {
+				runs = append(runs, excelize.RichTextRun{Text: "\n\n"})
+			}
+			if c.Author != "" {
+				runs = append(runs, excelize.RichTextRun{Text: c.Author + ":\n", Font: &excelize.Font{Bold: true}})
+			}
+			runs = append(runs, excelize.RichTextRun{Text: c.Text})
+		}
+		if err := f.AddComment(name, excelize.Comment{Cell: axis, Author: t.Comments[0].Author, Paragraph: runs}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importComments reads the notes of a sheet as single-comment threads; the
+// "Author:" line Excel starts a note with is dropped from the text. Notes
+// are all legacy comments excelize reads; threaded comments of newer Excel
+// files carry such a note as their fallback.
+func importComments(f *excelize.File, name string, ids ruleIds) []sheet.CommentThread {
+	notes, err := f.GetComments(name)
+	if err != nil {
+		return nil
+	}
+	var out []sheet.CommentThread
+	for _, n := range notes {
+		col, row, err := excelize.CellNameToCoordinates(n.Cell)
+		if err != nil {
+			continue
+		}
+		// Rich notes (all of Excel's) come as runs rather than Text.
+		text := n.Text
+		for _, run := range n.Paragraph {
+			text += run.Text
+		}
+		if n.Author != "" {
+			text = strings.TrimPrefix(text, n.Author+":\n")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > sheet.MaxCommentLength {
+			text = string([]rune(text)[:sheet.MaxCommentLength])
+		}
+		out = append(out, sheet.CommentThread{
+			Id: ids.next("thread"), Sheet: name, Cell: sheet.CellRef{Row: row - 1, Col: col - 1},
+			Comments: []sheet.Comment{{Id: ids.next("comment"), Author: n.Author, Text: text}},
+		})
+	}
+	return out
+}
+
+// sheetThreads returns the threads anchored on sheetId.
+func sheetThreads(threads []sheet.CommentThread, sheetId string) []sheet.CommentThread {
+	var out []sheet.CommentThread
+	for _, t := range threads {
+		if t.Sheet == sheetId {
+			out = append(out, t)
+		}
+	}
+	return out
+}
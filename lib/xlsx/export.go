@@ -10,30 +10,48 @@ import (
 
 // Export renders a workbook to .xlsx bytes. Cells whose raw starts with '='
 // become formulas; numeric-looking raw is written as a number, the rest as a
-// string. Cell styles, column widths / row heights, merged ranges and freeze
-// panes are carried over.
+// string. Cell styles, column widths / row heights, merged ranges, freeze
+// panes, data validations and conditional formats are carried over; protected
+// ranges become locked cells of a protected sheet.
 func Export(wb *sheet.Workbook) ([]byte, error) {
+	return ExportWithComments(wb, nil)
+}
+
+// ExportWithComments is Export plus the comment threads, written as notes
+// (see exportComments).
+func ExportWithComments(wb *sheet.Workbook, threads []sheet.CommentThread) ([]byte, error) {
 	f := excelize.NewFile()
 	defer f.Close()
 
 	const defaultSheet = "Sheet1"
 
 	// Lazily translate pool style ids to excelize style ids (shared per file).
-	styleIds := map[int]int{}
-	styleFor := func(id int) (int, error) {
-		if xid, ok := styleIds[id]; ok {
+	// On a protected sheet every cell style also carries its lock state.
+	type styleKey struct {
+		id   int
+		lock int // 0: sheet not protected, 1: unlocked, 2: locked
+	}
+	styleIds := map[styleKey]int{}
+	styleFor := func(id, lock int) (int, error) {
+		key := styleKey{id, lock}
+		if xid, ok := styleIds[key]; ok {
 			return xid, nil
 		}
-		st, ok := wb.Styles.Get(id)
-		if !ok || len(st.Props) == 0 {
-			styleIds[id] = 0
+		style := &excelize.Style{}
+		if st, ok := wb.Styles.Get(id); ok && len(st.Props) > 0 {
+			style = propsToStyle(st.Props)
+		} else if lock == 0 {
+			styleIds[key] = 0
 			return 0, nil
 		}
-		xid, err := f.NewStyle(propsToStyle(st.Props))
+		if lock != 0 {
+			style.Protection = &excelize.Protection{Locked: lock == 2}
+		}
+		xid, err := f.NewStyle(style)
 		if err != nil {
 			return 0, err
 		}
-		styleIds[id] = xid
+		styleIds[key] = xid
 		return xid, nil
 	}
 
@@ -54,6 +72,44 @@ func Export(wb *sheet.Workbook) ([]byte, error) {
 			}
 		}
 
+		// Protected ranges lock their cells; everything else is unlocked by
+		// the column style. Empty cells get the lock too, as far as the grid.
+		protections := sheetRules(wb.Protections, s.Id)
+		lockAt := func(ref sheet.CellRef) int {
+			if len(protections) == 0 {
+				return 0
+			}
+			for _, p := range protections {
+				if ref.Row >= p.Row && ref.Row <= p.EndRow && ref.Col >= p.Col && ref.Col <= p.EndCol {
+					return 2
+				}
+			}
+			return 1
+		}
+		if len(protections) > 0 {
+			unlocked, err := styleFor(0, 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := protectSheet(f, name, unlocked); err != nil {
+				return nil, err
+			}
+			locked, err := styleFor(0, 2)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range protections {
+				if p.Row >= maxRows || p.Col >= maxCols {
+					continue
+				}
+				start, _ := excelize.CoordinatesToCellName(p.Col+1, p.Row+1)
+				end, _ := excelize.CoordinatesToCellName(min(p.EndCol, maxCols-1)+1, min(p.EndRow, maxRows-1)+1)
+				if err := f.SetCellStyle(name, start, end, locked); err != nil {
+					return nil, err
+				}
+			}
+		}
+
 		for ref, cell := range s.Cells {
 			axis, err := excelize.CoordinatesToCellName(ref.Col+1, ref.Row+1)
 			if err != nil {
@@ -72,8 +128,8 @@ func Export(wb *sheet.Workbook) ([]byte, error) {
 					return nil, err
 				}
 			}
-			if cell.StyleId != 0 {
-				xid, err := styleFor(cell.StyleId)
+			if lock := lockAt(ref); cell.StyleId != 0 || lock != 0 {
+				xid, err := styleFor(cell.StyleId, lock)
 				if err != nil {
 					return nil, err
 				}
@@ -128,6 +184,22 @@ func Export(wb *sheet.Workbook) ([]byte, error) {
 		}
 	}
 
+	for _, s := range wb.Sheets {
+		name := s.Name
+		if name == "" {
+			name = s.Id
+		}
+		if err := exportValidations(f, name, sheetRules(wb.Validations, s.Id)); err != nil {
+			return nil, err
+		}
+		if err := exportCondFormats(f, name, sheetRules(wb.CondFormats, s.Id)); err != nil {
+			return nil, err
+		}
+		if err := exportComments(f, name, sheetThreads(threads, s.Id)); err != nil {
+			return nil, err
+		}
+	}
+
 	buf, err := f.WriteToBuffer()
 	if err != nil {
 		return nil, err
@@ -16,26 +16,46 @@ const (
 
 // Import parses an .xlsx into a WorkbookSnapshot. Sheet id == sheet name. Cells
 // carry their raw value, or "=<formula>" when a formula is present. Cell styles
-// (allowlisted props only), column widths / row heights, merged ranges and
-// freeze panes are imported.
+// (allowlisted props only), column widths / row heights, merged ranges, freeze
+// panes, data validations and cell-value/text conditional formats are
+// imported; the locked cells of a protected sheet become protected ranges.
 func Import(r io.Reader) (sheet.WorkbookSnapshot, error) {
+	snap, _, err := ImportWithComments(r)
+	return snap, err
+}
+
+// ImportWithComments is Import plus the notes of the file as comment threads
+// (see importComments).
+func ImportWithComments(r io.Reader) (sheet.WorkbookSnapshot, []sheet.CommentThread, error) {
 	f, err := excelize.OpenReader(r)
 	if err != nil {
-		return sheet.WorkbookSnapshot{}, err
+		return sheet.WorkbookSnapshot{}, nil, err
 	}
 	defer f.Close()
 
 	wb := sheet.NewWorkbook()
 	// excelize style idx -> pool id (0 = nothing representable), shared across
-	// sheets since styles are file-global.
+	// sheets since styles are file-global. Props equal to the default style's
+	// (its font, typically) are left out, so a style that only carries a cell
+	// lock maps to nothing.
 	poolIds := map[int]int{}
+	defaults := map[string]string{}
+	if st, err := f.GetStyle(0); err == nil && st != nil {
+		defaults = styleToProps(st)
+	}
 	poolIdFor := func(xid int) int {
 		if id, ok := poolIds[xid]; ok {
 			return id
 		}
 		id := 0
 		if st, err := f.GetStyle(xid); err == nil && st != nil {
-			if props := styleToProps(st); len(props) > 0 {
+			props := styleToProps(st)
+			for k, v := range defaults {
+				if props[k] == v {
+					delete(props, k)
+				}
+			}
+			if len(props) > 0 {
 				id = wb.Styles.Put(sheet.Style{Props: props})
 			}
 		}
@@ -43,17 +63,19 @@ func Import(r io.Reader) (sheet.WorkbookSnapshot, error) {
 		return id
 	}
 
+	ids := ruleIds{}
+	var threads []sheet.CommentThread
 	for _, name := range f.GetSheetList() {
 		sh := wb.AddSheet(name, name)
 		rows, err := f.GetRows(name)
 		if err != nil {
-			return sheet.WorkbookSnapshot{}, err
+			return sheet.WorkbookSnapshot{}, nil, err
 		}
 		for rIdx, row := range rows {
 			for cIdx, val := range row {
 				axis, err := excelize.CoordinatesToCellName(cIdx+1, rIdx+1)
 				if err != nil {
-					return sheet.WorkbookSnapshot{}, err
+					return sheet.WorkbookSnapshot{}, nil, err
 				}
 				raw := val
 				if formula, ferr := f.GetCellFormula(name, axis); ferr == nil && formula != "" {
@@ -126,6 +148,11 @@ func Import(r io.Reader) (sheet.WorkbookSnapshot, error) {
 				sh.FrozenCols = 1
 			}
 		}
+
+		importValidations(f, wb, name, ids)
+		importCondFormats(f, wb, name, ids)
+		importProtection(f, wb, name, ids)
+		threads = append(threads, importComments(f, name, ids)...)
 	}
-	return wb.Snapshot(), nil
+	return wb.Snapshot(), threads, nil
 }
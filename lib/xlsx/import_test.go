@@ -157,3 +157,165 @@ func TestNumFmtCodeMapping(t *testing.T) {
 		}
 	}
 }
+
+// TestRoundTripAfterRangeOps exports a sheet shaped by sort, move, copy and
+// fill ops and checks every cell survives the round trip.
+func TestRoundTripAfterRangeOps(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	wb.AddSheet("s1", "Data")
+	raw := func(s string) *string { return &s }
+	ops := []sheet.Op{
+		{Type: sheet.OpSetCell, Sheet: "s1", Row: 0, Col: 0, Raw: raw("3"), Props: map[string]string{"bold": "1"}},
+		{Type: sheet.OpSetCell, Sheet: "s1", Row: 1, Col: 0, Raw: raw("1")},
+		{Type: sheet.OpSetCell, Sheet: "s1", Row: 2, Col: 0, Raw: raw("2")},
+		{Type: sheet.OpSetCell, Sheet: "s1", Row: 0, Col: 1, Raw: raw("=A1*10")},
+		{Type: sheet.OpSortRange, Sheet: "s1", EndRow: 2, EndCol: 1, SortKeys: []sheet.SortKey{{Col: 0}}},
+		{Type: sheet.OpCopyRange, Sheet: "s1", EndRow: 2, EndCol: 1, DestCol: 3},
+		{Type: sheet.OpMoveRange, Sheet: "s1", Row: 0, Col: 3, EndRow: 2, EndCol: 4, DestRow: 4, DestCol: 3},
+		{Type: sheet.OpSetCell, Sheet: "s1", Row: 0, Col: 6, Raw: raw("Week 1")},
+		{Type: sheet.OpFillSeries, Sheet: "s1", Col: 6, EndRow: 5, EndCol: 6, Axis: "row", Count: 1},
+	}
+	for _, op := range ops {
+		if err := wb.Apply(op); err != nil {
+			t.Fatalf("apply %s: %v", op.Type, err)
+		}
+	}
+
+	data, err := Export(wb)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	snap, err := Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	got := sheet.WorkbookFromSnapshot(snap)
+	want, have := wb.Sheets[0], got.Sheets[0]
+	if len(have.Cells) != len(want.Cells) {
+		t.Fatalf("cells = %d, want %d", len(have.Cells), len(want.Cells))
+	}
+	for ref, c := range want.Cells {
+		h := have.GetCell(ref)
+		wantStyle, _ := wb.Styles.Get(c.StyleId)
+		haveStyle, _ := got.Styles.Get(h.StyleId)
+		if h.Raw != c.Raw || len(haveStyle.Props) != len(wantStyle.Props) {
+			t.Fatalf("%+v = %+v, want %+v", ref, h, c)
+		}
+	}
+	if c := have.GetCell(sheet.CellRef{Row: 6, Col: 4}); c.Raw != "=D7*10" {
+		t.Fatalf("moved formula = %q", c.Raw)
+	}
+	if c := have.GetCell(sheet.CellRef{Row: 5, Col: 6}); c.Raw != "Week 6" {
+		t.Fatalf("filled series = %q", c.Raw)
+	}
+}
+
+func TestRoundTripRules(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	s := wb.AddSheet("Data", "Data")
+	s.SetCell(sheet.CellRef{Row: 0, Col: 0}, sheet.Cell{Raw: "locked"})
+	s.SetCell(sheet.CellRef{Row: 5, Col: 5}, sheet.Cell{Raw: "free"})
+	wb.Validations = []sheet.Rule{
+		{Id: "v1", Sheet: "Data", Row: 1, Col: 1, EndRow: 9, EndCol: 1, Type: sheet.ValidationList, List: []string{"yes", "no"}},
+		{Id: "v2", Sheet: "Data", Row: 1, Col: 2, EndRow: 9, EndCol: 2, Type: sheet.ValidationWhole, Operator: "between", Value1: "1", Value2: "10"},
+	}
+	wb.CondFormats = []sheet.Rule{
+		{Id: "f1", Sheet: "Data", Row: 0, Col: 3, EndRow: 9, EndCol: 3, Type: sheet.CondFormatCellIs, Operator: "greaterThan", Value1: "5", Props: map[string]string{"bold": "1", "bg": "#ff0000"}},
+		{Id: "f2", Sheet: "Data", Row: 0, Col: 4, EndRow: 0, EndCol: 4, Type: sheet.CondFormatCellIs, Operator: "equal", Value1: `say "hi"`, Props: map[string]string{"italic": "1"}},
+		{Id: "f3", Sheet: "Data", Row: 0, Col: 4, EndRow: 9, EndCol: 4, Type: sheet.CondFormatText, Operator: "beginsWith", Value1: "ab", Props: map[string]string{"color": "#0000ff"}},
+	}
+	wb.Protections = []sheet.Rule{
+		{Id: "p1", Sheet: "Data", Row: 0, Col: 0, EndRow: 2, EndCol: 1, Authors: []string{"a.1"}},
+	}
+
+	data, err := Export(wb)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	snap, err := Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	got := sheet.WorkbookFromSnapshot(snap)
+
+	if len(got.Validations) != 2 {
+		t.Fatalf("validations = %+v", got.Validations)
+	}
+	if v := got.Validations[0]; v.Type != sheet.ValidationList || len(v.List) != 2 || v.List[1] != "no" || v.Row != 1 || v.EndRow != 9 || v.Col != 1 {
+		t.Fatalf("list validation = %+v", v)
+	}
+	if v := got.Validations[1]; v.Type != sheet.ValidationWhole || v.Operator != "between" || v.Value1 != "1" || v.Value2 != "10" {
+		t.Fatalf("whole validation = %+v", v)
+	}
+
+	if len(got.CondFormats) != 3 {
+		t.Fatalf("conditional formats = %+v", got.CondFormats)
+	}
+	byCol := map[int][]sheet.Rule{}
+	for _, r := range got.CondFormats {
+		byCol[r.Col] = append(byCol[r.Col], r)
+	}
+	if r := byCol[3][0]; r.Operator != "greaterThan" || r.Value1 != "5" || r.Props["bold"] != "1" || r.Props["bg"] != "#ff0000" {
+		t.Fatalf("cellIs format = %+v", r)
+	}
+	for _, r := range byCol[4] {
+		switch r.Type {
+		case sheet.CondFormatCellIs:
+			if r.Value1 != `say "hi"` || r.Props["italic"] != "1" {
+				t.Fatalf("quoted cellIs format = %+v", r)
+			}
+		case sheet.CondFormatText:
+			if r.Operator != "beginsWith" || r.Value1 != "ab" || r.Props["color"] != "#0000ff" {
+				t.Fatalf("text format = %+v", r)
+			}
+		}
+	}
+
+	// Authors do not survive xlsx: the range comes back locked for everyone.
+	if len(got.Protections) != 1 {
+		t.Fatalf("protections = %+v", got.Protections)
+	}
+	if p := got.Protections[0]; p.Row != 0 || p.Col != 0 || p.EndRow != 2 || p.EndCol != 1 || len(p.Authors) != 0 {
+		t.Fatalf("protection = %+v", p)
+	}
+	sh := got.SheetByID("Data")
+	if sh.GetCell(sheet.CellRef{Row: 0, Col: 0}).Raw != "locked" || sh.GetCell(sheet.CellRef{Row: 0, Col: 0}).StyleId != 0 {
+		t.Fatalf("A1 = %+v", sh.GetCell(sheet.CellRef{Row: 0, Col: 0}))
+	}
+}
+
+func TestRoundTripComments(t *testing.T) {
+	wb := sheet.NewWorkbook()
+	wb.AddSheet("Data", "Data")
+	wb.AddSheet("Other", "Other")
+	threads := []sheet.CommentThread{
+		{Id: "t.1", Sheet: "Data", Cell: sheet.CellRef{Row: 1, Col: 2}, Comments: []sheet.Comment{{Id: "c.1", Author: "Alice", Text: "check the total"}}},
+		{Id: "t.2", Sheet: "Other", Cell: sheet.CellRef{Row: 0, Col: 0}, Resolved: true, Comments: []sheet.Comment{
+			{Id: "c.2", Author: "Alice", Text: "why?"},
+			{Id: "c.3", Author: "Bob", Text: "fixed"},
+		}},
+	}
+	data, err := ExportWithComments(wb, threads)
+	if err != nil {
+		t.Fatalf("ExportWithComments: %v", err)
+	}
+	_, got, err := ImportWithComments(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportWithComments: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("threads = %+v", got)
+	}
+	first := got[0]
+	if first.Sheet != "Data" || first.Cell != (sheet.CellRef{Row: 1, Col: 2}) || len(first.Comments) != 1 ||
+		first.Comments[0].Author != "Alice" || first.Comments[0].Text != "check the total" {
+		t.Fatalf("single-comment thread = %+v", first)
+	}
+	// A thread comes back as one note, its later comments still labelled.
+	if c := got[1].Comments[0]; got[1].Sheet != "Other" || c.Text != "why?\n\nBob:\nfixed" {
+		t.Fatalf("thread = %+v", got[1])
+	}
+	if first.Id == got[1].Id || first.Comments[0].Id == got[1].Comments[0].Id {
+		t.Fatal("imported ids must be unique")
+	}
+}
@@ -0,0 +1,349 @@
+package xlsx
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ether/etherpad-go/lib/sheet"
+	"github.com/xuri/excelize/v2"
+)
+
+// Mapping between the workbook rule sets (sheet.Rule) and their xlsx
+// counterparts: data validations, conditional formats, and sheet protection
+// with locked cells. Authors of protected ranges have no xlsx equivalent, so
+// an exported protection is one without a password and an imported one
+// allows nobody (anyone may lift it, like in Excel).
+
+// excelize spells the operators of conditional formats as criteria labels.
+var cellIsCriteria = map[string]string{
+	"between": "between", "notBetween": "not between",
+	"equal": "equal to", "notEqual": "not equal to",
+	"greaterThan": "greater than", "lessThan": "less than",
+	"greaterThanOrEqual": "greater than or equal to", "lessThanOrEqual": "less than or equal to",
+}
+
+var textCriteria = map[string]string{
+	"containsText": "containing", "notContains": "not containing",
+	"beginsWith": "begins with", "endsWith": "ends with",
+}
+
+// criteriaOperator inverts a criteria map (label -> operator).
+func criteriaOperator(m map[string]string, label string) string {
+	for op, l := range m {
+		if l == label {
+			return op
+		}
+	}
+	return ""
+}
+
+// rangeRef is the A1 reference of a rule's range ("B2:C5").
+func rangeRef(r sheet.Rule) (string, error) {
+	start, err := excelize.CoordinatesToCellName(r.Col+1, r.Row+1)
+	if err != nil {
+		return "", err
+	}
+	end, err := excelize.CoordinatesToCellName(r.EndCol+1, r.EndRow+1)
+	if err != nil {
+		return "", err
+	}
+	return start + ":" + end, nil
+}
+
+// parseSqref splits an xlsx sqref ("A1:B5 D1") into zero-based rectangles
+// {row, col, endRow, endCol}; unparsable parts are skipped.
+func parseSqref(sqref string) [][4]int {
+	var out [][4]int
+	for _, part := range strings.Fields(sqref) {
+		from, to, ok := strings.Cut(part, ":")
+		if !ok {
+			to = from
+		}
+		c0, r0, e0 := excelize.CellNameToCoordinates(from)
+		c1, r1, e1 := excelize.CellNameToCoordinates(to)
+		if e0 != nil || e1 != nil {
+			continue
+		}
+		out = append(out, [4]int{min(r0, r1) - 1, min(c0, c1) - 1, max(r0, r1) - 1, max(c0, c1) - 1})
+	}
+	return out
+}
+
+// cfValue quotes a conditional format value unless it is a number: xlsx
+// stores these operands as formulas.
+func cfValue(v string) string {
+	if isNumber(v) {
+		return v
+	}
+	return `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+}
+
+// cfLiteral reverses cfValue; formulas other than a literal are not
+// representable and yield false.
+func cfLiteral(f string) (string, bool) {
+	if isNumber(f) {
+		return f, true
+	}
+	if len(f) >= 2 && strings.HasPrefix(f, `"`) && strings.HasSuffix(f, `"`) {
+		inner := f[1 : len(f)-1]
+		if strings.Contains(strings.ReplaceAll(inner, `""`, ""), `"`) {
+			return "", false
+		}
+		return strings.ReplaceAll(inner, `""`, `"`), true
+	}
+	return "", false
+}
+
+// numberRe matches what the sheet model compares as a number.
+var numberRe = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+func isNumber(v string) bool {
+	return numberRe.MatchString(v)
+}
+
+// sheetRules returns the rules of rules that lie on sheetId.
+func sheetRules(rules []sheet.Rule, sheetId string) []sheet.Rule {
+	var out []sheet.Rule
+	for _, r := range rules {
+		if r.Sheet == sheetId {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// exportValidations writes the data validations of a sheet.
+func exportValidations(f *excelize.File, name string, rules []sheet.Rule) error {
+	for _, r := range rules {
+		ref, err := rangeRef(r)
+		if err != nil {
+			return err
+		}
+		dv := excelize.NewDataValidation(true)
+		dv.Sqref = ref
+		dv.ShowErrorMessage = true
+		if r.Type == sheet.ValidationList {
+			if err := dv.SetDropList(r.List); err != nil {
+				return err
+			}
+		} else {
+			dv.Type, dv.Operator = r.Type, r.Operator
+			dv.Formula1 = r.Value1
+			if r.Operator == "between" || r.Operator == "notBetween" {
+				dv.Formula2 = r.Value2
+			}
+		}
+		if err := f.AddDataValidation(name, dv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportCondFormats writes the conditional formats of a sheet.
+func exportCondFormats(f *excelize.File, name string, rules []sheet.Rule) error {
+	for _, r := range rules {
+		ref, err := rangeRef(r)
+		if err != nil {
+			return err
+		}
+		xid, err := f.NewConditionalStyle(propsToStyle(r.Props))
+		if err != nil {
+			return err
+		}
+		opt := excelize.ConditionalFormatOptions{Format: &xid}
+		if r.Type == sheet.CondFormatText {
+			opt.Type, opt.Criteria, opt.Value = "text", textCriteria[r.Operator], r.Value1
+		} else {
+			opt.Type, opt.Criteria = "cell", cellIsCriteria[r.Operator]
+			if r.Operator == "between" || r.Operator == "notBetween" {
+				opt.MinValue, opt.MaxValue = cfValue(r.Value1), cfValue(r.Value2)
+			} else {
+				opt.Value = cfValue(r.Value1)
+			}
+		}
+		if err := f.SetConditionalFormat(name, ref, []excelize.ConditionalFormatOptions{opt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protectSheet turns on sheet protection for a sheet with protected ranges.
+// Every column is unlocked by default; Export locks the cells of the ranges.
+// Selecting cells stays allowed, and so do row/col changes, which the sheet
+// model only refuses where they delete protected cells.
+func protectSheet(f *excelize.File, name string, unlocked int) error {
+	if err := f.SetColStyle(name, "A:XFD", unlocked); err != nil {
+		return err
+	}
+	return f.ProtectSheet(name, &excelize.SheetProtectionOptions{
+		SelectLockedCells: true, SelectUnlockedCells: true,
+		FormatColumns: true, FormatRows: true, InsertColumns: true, InsertRows: true,
+	})
+}
+
+// ruleIds numbers imported rules; ids must be unique across the workbook.
+type ruleIds map[string]int
+
+func (ids ruleIds) next(kind string) string {
+	ids[kind]++
+	return fmt.Sprintf("%s-%d", kind, ids[kind])
+}
+
+// addRule appends r to list if its set op would validate, so an imported
+// file can not carry rules a collaborator could not have set.
+func addRule(list *[]sheet.Rule, t sheet.OpType, r sheet.Rule) {
+	op := sheet.Op{Type: t, Sheet: r.Sheet, Row: r.Row, Col: r.Col, EndRow: r.EndRow, EndCol: r.EndCol, Rule: &r}
+	if op.Validate() == nil && len(*list) < sheet.MaxRules {
+		*list = append(*list, r)
+	}
+}
+
+func ruleAt(id, sheetId string, rect [4]int) sheet.Rule {
+	return sheet.Rule{Id: id, Sheet: sheetId, Row: rect[0], Col: rect[1], EndRow: rect[2], EndCol: rect[3]}
+}
+
+// importValidations reads the data validations of a sheet. Lists sourced
+// from cells instead of inline values are skipped.
+func importValidations(f *excelize.File, wb *sheet.Workbook, name string, ids ruleIds) {
+	dvs, err := f.GetDataValidations(name)
+	if err != nil {
+		return
+	}
+	for _, dv := range dvs {
+		r := sheet.Rule{Type: dv.Type, Operator: dv.Operator, Value1: dv.Formula1, Value2: dv.Formula2}
+		if dv.Type == sheet.ValidationList {
+			inner, ok := cfLiteral(dv.Formula1)
+			if !ok {
+				continue
+			}
+			r = sheet.Rule{Type: dv.Type, List: strings.Split(inner, ",")}
+		} else if r.Operator == "" {
+			r.Operator = "between" // the xlsx default
+		}
+		for _, rect := range parseSqref(dv.Sqref) {
+			rule := ruleAt(ids.next("validation"), name, rect)
+			rule.Type, rule.Operator, rule.Value1, rule.Value2, rule.List = r.Type, r.Operator, r.Value1, r.Value2, r.List
+			addRule(&wb.Validations, sheet.OpSetValidation, rule)
+		}
+	}
+}
+
+// importCondFormats reads the cell-value and text conditional formats of a
+// sheet; other kinds (color scales, data bars, ...) are skipped.
+func importCondFormats(f *excelize.File, wb *sheet.Workbook, name string, ids ruleIds) {
+	cfs, err := f.GetConditionalFormats(name)
+	if err != nil {
+		return
+	}
+	for _, sqref := range slices.Sorted(maps.Keys(cfs)) {
+		for _, opt := range cfs[sqref] {
+			r := sheet.Rule{}
+			switch opt.Type {
+			case "cell":
+				r.Type, r.Operator = sheet.CondFormatCellIs, criteriaOperator(cellIsCriteria, opt.Criteria)
+				values := []string{opt.Value}
+				if r.Operator == "between" || r.Operator == "notBetween" {
+					values = []string{opt.MinValue, opt.MaxValue}
+				}
+				ok := true
+				for i, v := range values {
+					values[i], ok = cfLiteral(v)
+					if !ok {
+						break
+					}
+				}
+				if !ok {
+					continue
+				}
+				r.Value1 = values[0]
+				if len(values) > 1 {
+					r.Value2 = values[1]
+				}
+			case "text":
+				r.Type, r.Operator, r.Value1 = sheet.CondFormatText, criteriaOperator(textCriteria, opt.Criteria), opt.Value
+			default:
+				continue
+			}
+			if opt.Format == nil {
+				continue
+			}
+			st, err := f.GetConditionalStyle(*opt.Format)
+			if err != nil || st == nil {
+				continue
+			}
+			r.Props = styleToProps(st)
+			for k := range r.Props {
+				if k != "bold" && k != "italic" && k != "underline" && k != "strike" && k != "color" && k != "bg" {
+					delete(r.Props, k)
+				}
+			}
+			for _, rect := range parseSqref(sqref) {
+				rule := ruleAt(ids.next("format"), name, rect)
+				rule.Type, rule.Operator, rule.Value1, rule.Value2, rule.Props = r.Type, r.Operator, r.Value1, r.Value2, r.Props
+				addRule(&wb.CondFormats, sheet.OpSetCondFormat, rule)
+			}
+		}
+	}
+}
+
+// importProtection turns the locked cells of a protected sheet into
+// protected ranges: runs of locked cells per row, stacked into rectangles
+// while consecutive rows have the same run. ponytail: scans the fixed grid
+// extent (52/200), like the style sweep of Import.
+func importProtection(f *excelize.File, wb *sheet.Workbook, name string, ids ruleIds) {
+	// excelize reports an unprotected sheet as the zero options; a protected
+	// one allows at least selecting cells unless its author forbade it too.
+	if opts, err := f.GetSheetProtection(name); err != nil || opts == (excelize.SheetProtectionOptions{}) {
+		return
+	}
+	// Cells are locked unless their style (or their row/col style) says
+	// otherwise; styles are file-global, so cache per style index.
+	lockedXf := map[int]bool{}
+	locked := func(r, c int) bool {
+		axis, _ := excelize.CoordinatesToCellName(c+1, r+1)
+		xid, err := f.GetCellStyle(name, axis)
+		if err != nil {
+			return false
+		}
+		l, ok := lockedXf[xid]
+		if !ok {
+			st, err := f.GetStyle(xid)
+			l = err == nil && (st == nil || st.Protection == nil || st.Protection.Locked)
+			lockedXf[xid] = l
+		}
+		return l
+	}
+	open := map[[2]int]*sheet.Rule{} // col span -> rectangle still growing
+	var rects []*sheet.Rule
+	for r := range maxRows {
+		next := map[[2]int]*sheet.Rule{}
+		for c := 0; c < maxCols; {
+			if !locked(r, c) {
+				c++
+				continue
+			}
+			start := c
+			for c < maxCols && locked(r, c) {
+				c++
+			}
+			span := [2]int{start, c - 1}
+			rect := open[span]
+			if rect == nil {
+				rect = &sheet.Rule{Sheet: name, Row: r, Col: start, EndCol: c - 1}
+				rects = append(rects, rect)
+			}
+			rect.EndRow = r
+			next[span] = rect
+		}
+		open = next
+	}
+	for _, rect := range rects {
+		rect.Id = ids.next("protection")
+		addRule(&wb.Protections, sheet.OpSetProtection, *rect)
+	}
+}
@@ -52,7 +52,7 @@ func main() {
 			migration.RunFromCLI(setupLogger, os.Args[2:])
 			return
 		case "cli":
-			cli.RunFromCLI(setupLogger, os.Args[2:])
+			cli.RunFromCLI(setupLogger, os.Args[2:], uiAssets)
 			return
 		case "loadtest":
 			loadtest.RunFromCLI(setupLogger, os.Args[2:])
@@ -70,8 +70,8 @@ func main() {
 		case "-h", "--help", "help":
 			fmt.Println("Usage: etherpad [command] [options]")
 			fmt.Println("Commands:")
-			fmt.Println("  cli        Interactive CLI for pads")
-			fmt.Println("  loadtest   Run a load test on a single pad")
+			fmt.Println("  cli        Pad commands (pad, author, group) and interactive pad client")
+			fmt.Println("  loadtest   Run a load test on a single pad (loadtest scenario <file> runs a scenario file)")
 			fmt.Println("  multiload  Run a multi-pad load test")
 			fmt.Println("  (none)     Start the Etherpad server")
 			fmt.Println("  prepare    Prepare the etherpad server including building frontend assets")
@@ -14,6 +14,12 @@ export class ChatMessage {
   public authorId: string|null
   displayName: string|null
   time: number|null
+  head?: number
+  replyTo?: number
+  mentions?: string[]
+  editedAt?: number
+  deleted?: boolean
+  deletedBy?: string
   static fromObject(obj: ChatMessage) {
     // The userId property was renamed to authorId, and userName was renamed to displayName. Accept
     // the old names in case the db record was written by an older version of Etherpad.
@@ -64,6 +70,15 @@ export class ChatMessage {
      * @type {?string}
      */
     this.displayName = null;
+
+    /**
+     * The number of the message this one replies to, if any. The message's own number (`head`),
+     * the authors its text mentions (`mentions`), when it was last edited (`editedAt`) and whether
+     * it was deleted (`deleted`, `deletedBy`) are set by the server.
+     *
+     * @type {number|undefined}
+     */
+    this.replyTo = undefined;
   }
 
   /**
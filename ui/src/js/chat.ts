@@ -182,6 +182,17 @@ const getRenderedElement = (rendered: unknown): HTMLElement | null => {
   return null;
 };
 
+/**
+ * The HTML shown for a message's text: a placeholder once it is deleted, and
+ * a marker once it was edited.
+ */
+const messageHtml = (message: ChatMessage): string => {
+  if (message.deleted) return escapeHtml(html10n.get('pad.chat.deleted'));
+  const text = escapeHtmlWithClickableLinks(message.text ?? '', '_blank');
+  if (message.editedAt == null) return text;
+  return `${text} <span class="chat-edited">${escapeHtml(html10n.get('pad.chat.edited'))}</span>`;
+};
+
 const authorClass = (authorId: string): string =>
   `author-${authorId.replace(/[^a-y0-9]/g, (c) => c === '.' ? '-' : `z${c.charCodeAt(0)}z`)}`;
 
@@ -195,6 +206,10 @@ class ChatController {
   private chatMentions = 0;
   private lastMessage: HTMLElement | null = null;
   private historyPointer = 0;
+  /** The number of the newest message seen, for CHAT_READ. */
+  private lastHead = -1;
+  /** The number of the message the next one sent replies to. */
+  private replyingTo: number | null = null;
   private pad!: PadLike;
 
   // --- DOM accessors -------------------------------------------------------
@@ -214,8 +229,7 @@ class ChatController {
     this.chatIcon?.classList.remove('visible');
     this.chatBox?.classList.add('visible');
     this.scrollDown(true);
-    this.chatMentions = 0;
-    titleBadge.setBubble(0);
+    this.markRead();
     for (const msg of document.querySelectorAll('.chat-gritter-msg[id]')) {
       const id = (msg as HTMLElement).id;
       if (id) notifications.remove(id);
@@ -312,6 +326,8 @@ class ChatController {
     if (text.replace(/\s+/, '').length === 0) return;
 
     const message = new ChatMessage(text);
+    if (this.replyingTo != null) message.replyTo = this.replyingTo;
+    this.replyingTo = null;
     // EventBus: emit chat:message:sending before the hook call
     editorBus.emit('chat:message:sending', {message});
     editorBus.emit('chat:message:send', {text, message});
@@ -319,6 +335,47 @@ class ChatController {
     editorBus.emit('chat:message:sent', { text });
   }
 
+  /** Makes the next message sent a reply to the message at head, or not with null. */
+  replyTo(head: number | null): void {
+    this.replyingTo = head;
+  }
+
+  /** Asks the server to replace the text of one of our messages. */
+  editMessage(head: number, text: string): void {
+    if (text.trim().length === 0) return;
+    this.pad.collabClient.sendMessage({type: 'CHAT_EDIT', head, text});
+  }
+
+  /** Asks the server to delete a message, ours or, as the pad's creator, anyone's. */
+  deleteMessage(head: number): void {
+    this.pad.collabClient.sendMessage({type: 'CHAT_DELETE', head});
+  }
+
+  /** Tells the server we have read the chat, which clears our unread mentions. */
+  markRead(): void {
+    this.chatMentions = 0;
+    titleBadge.setBubble(0);
+    if (this.lastHead >= 0) this.pad.collabClient.sendMessage({type: 'CHAT_READ', head: this.lastHead});
+  }
+
+  /** Shows the server's count of messages mentioning us that we have not read. */
+  setMentions(unread: number): void {
+    this.chatMentions = unread;
+    titleBadge.setBubble(unread);
+  }
+
+  /** Re-renders a message the server reports as edited or deleted. */
+  updateMessage(msg: unknown): void {
+    const message = ChatMessage.fromObject(msg as ChatMessage);
+    if (message.head == null) return;
+    const chatMsg = this.chatText?.querySelector(`ep-chat-message[data-head="${message.head}"]`);
+    if (chatMsg == null) return;
+    chatMsg.toggleAttribute('edited', message.editedAt != null);
+    chatMsg.toggleAttribute('deleted', message.deleted === true);
+    chatMsg.replaceChildren(parseHtmlFragment(messageHtml(message)));
+    html10n.translateElement(html10n.translations, chatMsg);
+  }
+
   async addMessage(msg: unknown, increment: boolean, isHistoryAdd: boolean): Promise<void> {
     const message = ChatMessage.fromObject(msg as ChatMessage);
     if (message.time == null) message.time = Date.now();
@@ -329,11 +386,12 @@ class ChatController {
       console.warn('Missing "authorId" in chat message from server. Replaced with "unknown".');
     }
     if (message.text == null) message.text = '';
+    if (message.head != null) this.lastHead = Math.max(this.lastHead, message.head);
 
     const ctx: ChatContext = {
       authorName: message.displayName ?? html10n.get('pad.userlist.unnamed'),
       author: message.authorId,
-      text: escapeHtmlWithClickableLinks(message.text, '_blank'),
+      text: messageHtml(message),
       message,
       rendered: null,
       sticky: false,
@@ -350,15 +408,23 @@ class ChatController {
     const alreadyFocused = document.activeElement === this.chatInput;
     const chatOpen = this.chatBox?.classList.contains('visible') === true;
 
-    const wasMentioned =
-      message.authorId !== String((window as any).clientVars?.userId ?? '') &&
-      ctx.authorName !== html10n.get('pad.userlist.unnamed') &&
-      normalize(ctx.text).includes(normalize(ctx.authorName));
-
-    if (wasMentioned && !alreadyFocused && !isHistoryAdd && !chatOpen) {
-      this.chatMentions++;
-      titleBadge.setBubble(this.chatMentions);
-      ctx.sticky = true;
+    // The server resolves @mentions of messages it numbers; older servers
+    // don't, so fall back to looking for the author's name.
+    const myUserId = String((window as any).clientVars?.userId ?? '');
+    const wasMentioned = message.head != null
+      ? (message.mentions ?? []).includes(myUserId)
+      : message.authorId !== myUserId &&
+        ctx.authorName !== html10n.get('pad.userlist.unnamed') &&
+        normalize(ctx.text).includes(normalize(ctx.authorName));
+
+    if (wasMentioned && !isHistoryAdd) {
+      if (!alreadyFocused && !chatOpen) {
+        this.chatMentions++;
+        titleBadge.setBubble(this.chatMentions);
+        ctx.sticky = true;
+      } else {
+        this.markRead();
+      }
     }
 
     // Notify via EventBus *before* the hook call so listeners can prepare
@@ -377,10 +443,13 @@ class ChatController {
     const chatMsg = rendered ?? document.createElement('ep-chat-message');
     if (rendered == null) {
       const cv: any = (window as any).clientVars ?? {};
-      const myUserId = String(cv.userId ?? '');
       chatMsg.setAttribute('data-authorId', ctx.author);
       chatMsg.setAttribute('author', ctx.authorName);
       chatMsg.setAttribute('time', ctx.timeStr);
+      if (message.head != null) chatMsg.setAttribute('data-head', `${message.head}`);
+      if (message.replyTo != null) chatMsg.setAttribute('data-reply-to', `${message.replyTo}`);
+      chatMsg.toggleAttribute('edited', message.editedAt != null);
+      chatMsg.toggleAttribute('deleted', message.deleted === true);
       if (ctx.author === myUserId) {
         chatMsg.setAttribute('own', '');
       }
@@ -470,8 +539,7 @@ class ChatController {
     });
 
     input.addEventListener('click', () => {
-      this.chatMentions = 0;
-      titleBadge.setBubble(0);
+      this.markRead();
     });
 
     document.body.addEventListener('keypress', (evt: KeyboardEvent) => {
@@ -284,6 +284,12 @@ export const getCollabClient = (ace2editor, serverVars, initialUserInfo, options
       callbacks.onClientMessage(msg.payload);
     } else if (msg.type === 'CHAT_MESSAGE') {
       chat.addMessage(msg.message, true, false);
+    } else if (msg.type === 'CHAT_MESSAGE_UPDATE') {
+      chat.updateMessage(msg.message);
+    } else if (msg.type === 'CHAT_MENTIONS') {
+      chat.setMentions(msg.unread);
+    } else if (msg.type === 'REJECT_CHAT') {
+      console.warn(`Chat message ${msg.head} was not changed: ${msg.reason}`);
     } else if (msg.type === 'CHAT_MESSAGES') {
       for (let i = msg.messages.length - 1; i >= 0; i--) {
         chat.addMessage(msg.messages[i], true, true);
@@ -8,6 +8,33 @@ type ImportResponse = {
   };
 };
 
+// An export format registered by a plugin; the pad template only has links
+// for the built-in formats.
+type ExportFormat = {
+  name: string;
+  label: string;
+  icon: string;
+};
+
+const addExportLink = (format: ExportFormat): HTMLAnchorElement | null => {
+  const exportColumn = document.getElementById('exportColumn');
+  if (exportColumn == null) return null;
+  const link = document.createElement('a');
+  link.id = `export${format.name}a`;
+  link.target = '_blank';
+  link.rel = 'noopener';
+  link.className = 'exportlink';
+  link.title = format.label;
+  link.setAttribute('aria-label', format.label);
+  const icon = document.createElement('span');
+  icon.className = `exporttype buttonicon ${format.icon || 'buttonicon-file'}`;
+  icon.setAttribute('aria-hidden', 'true');
+  icon.textContent = format.label;
+  link.append(icon);
+  exportColumn.append(link);
+  return link;
+};
+
 const visible = (el: Element | null): boolean => {
   if (!(el instanceof HTMLElement)) return false;
   return getComputedStyle(el).display !== 'none';
@@ -172,11 +199,16 @@ export const padimpexp = {
     });
 
     const availableExports = (clientVars.availableExports ?? []) as string[];
+    const exportFormats = (clientVars.exportFormats ?? []) as ExportFormat[];
     for (const exportOption of availableExports) {
-      const exportNode = document.getElementById(`export${exportOption}a`);
+      let exportNode = document.getElementById(`export${exportOption}a`);
+      if (exportNode == null) {
+        const format = exportFormats.find((f) => f.name === exportOption);
+        if (format != null) exportNode = addExportLink(format);
+      }
       if (!(exportNode instanceof HTMLAnchorElement)) continue;
       exportNode.style.display = '';
-      exportNode.href = `${padRootPath}/export/${exportOption}`;
+      exportNode.href = `${padRootPath}/export/${encodeURIComponent(exportOption)}`;
     }
 
     const importForm = document.querySelector<HTMLFormElement>('#importform');
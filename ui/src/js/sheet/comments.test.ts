@@ -0,0 +1,90 @@
+import { describe, it, expect } from 'vitest';
+import { CommentStore, moveAnchor, type CommentThread } from './comments';
+import { SheetCollabClient } from './sheetCollabClient';
+import type { Op } from './op';
+
+const op = (o: Partial<Op> & Pick<Op, 'type'>): Op => ({ sheet: 's1', baseRev: 0, ...o } as Op);
+
+const thread = (id: string, row: number, col: number, sheet = 's1'): CommentThread => ({
+  id, sheet, cell: { row, col }, comments: [{ id: `c-${id}`, text: 'hi', timestamp: 0 }],
+});
+
+describe('moveAnchor', () => {
+  // Same cases as Go TestMoveAnchor.
+  const at = { row: 4, col: 2 };
+  it.each([
+    ['insert rows above', op({ type: 'insertRows', index: 1, count: 2 }), { row: 6, col: 2 }],
+    ['insert rows below', op({ type: 'insertRows', index: 5, count: 2 }), at],
+    ['delete rows above', op({ type: 'deleteRows', index: 0, count: 3 }), { row: 1, col: 2 }],
+    ['delete its row', op({ type: 'deleteRows', index: 4, count: 1 }), null],
+    ['insert cols left', op({ type: 'insertCols', index: 2, count: 1 }), { row: 4, col: 3 }],
+    ['delete its col', op({ type: 'deleteCols', index: 1, count: 2 }), null],
+    ['delete cols left', op({ type: 'deleteCols', index: 0, count: 1 }), { row: 4, col: 1 }],
+    ['move its range', op({ type: 'moveRange', row: 3, col: 1, endRow: 5, endCol: 2, destRow: 10, destCol: 5 }), { row: 11, col: 6 }],
+    ['moved over', op({ type: 'moveRange', row: 0, col: 0, endRow: 0, endCol: 0, destRow: 4, destCol: 2 }), null],
+    ['other sheet', op({ type: 'deleteRows', sheet: 's2', index: 4, count: 1 }), at],
+    ['cell edit', op({ type: 'setCell', row: 4, col: 2, raw: 'x' }), at],
+  ])('%s', (_name, o, want) => {
+    expect(moveAnchor('s1', at, o)).toEqual(want);
+  });
+});
+
+describe('CommentStore', () => {
+  it('moves anchors past ops newer than the thread', () => {
+    const store = new CommentStore();
+    store.reset([thread('a', 2, 0), thread('b', 5, 0)], 3);
+    store.update(thread('c', 8, 0), false, 4);
+    // Rev 4 is already reflected in c's anchor, not in a's and b's.
+    store.onConfirmed(op({ type: 'insertRows', index: 0, count: 1 }), 4, () => true);
+    store.onConfirmed(op({ type: 'deleteRows', index: 3, count: 1 }), 5, () => true);
+    expect(store.get('a')).toBeUndefined();
+    expect(store.get('b')?.cell).toEqual({ row: 5, col: 0 });
+    expect(store.get('c')?.cell).toEqual({ row: 7, col: 0 });
+  });
+
+  it('drops threads of a deleted sheet', () => {
+    const store = new CommentStore();
+    store.reset([thread('a', 0, 0, 's2'), thread('b', 0, 0)], 0);
+    store.onConfirmed(op({ type: 'deleteSheet', sheet: 's2' }), 1, (id) => id !== 's2');
+    expect(store.get('a')).toBeUndefined();
+    expect(store.get('b')).toBeDefined();
+  });
+
+  it('replaces or deletes a thread on update', () => {
+    const store = new CommentStore();
+    store.reset([thread('a', 1, 1)], 0);
+    store.update({ ...thread('a', 1, 1), resolved: true }, false, 0);
+    expect(store.get('a')?.resolved).toBe(true);
+    store.update(thread('a', 1, 1), true, 0);
+    expect(store.list('s1')).toEqual([]);
+  });
+
+  it('shows threads through pending local ops', () => {
+    const store = new CommentStore();
+    store.reset([thread('a', 3, 1), thread('b', 1, 1)], 0);
+    const pending = [op({ type: 'insertRows', index: 2, count: 2 })];
+    expect(store.threadAt('s1', 5, 1, pending)?.id).toBe('a');
+    expect(store.threadAt('s1', 3, 1, pending)).toBeUndefined();
+    expect(store.list('s1', pending).map((t) => [t.id, t.cell.row])).toEqual([['b', 1], ['a', 5]]);
+    // The stored anchor stays in confirmed coordinates.
+    expect(store.get('a')?.cell).toEqual({ row: 3, col: 1 });
+  });
+
+  it('follows a collab client through accepted and remote ops', () => {
+    const sent: Op[] = [];
+    const client = new SheetCollabClient({ sheets: [{ id: 's1', name: 'Sheet1', cells: [] }] }, 0, {
+      send: (o) => sent.push(o),
+    });
+    const store = new CommentStore();
+    store.reset([thread('a', 4, 0)], 0);
+    client.onConfirmed = (o, rev) => store.onConfirmed(o, rev, (id) => client.confirmedState().sheetById(id) !== undefined);
+
+    client.applyLocal(op({ type: 'insertRows', index: 0, count: 1 }));
+    expect(store.threadAt('s1', 5, 0, client.pendingOps())?.id).toBe('a');
+    client.onAccept(1);
+    expect(store.get('a')?.cell).toEqual({ row: 5, col: 0 });
+    client.onRemote(op({ type: 'deleteRows', index: 0, count: 2, baseRev: 1 }), 2);
+    expect(store.get('a')?.cell).toEqual({ row: 3, col: 0 });
+    expect(sent).toHaveLength(1);
+  });
+});
@@ -0,0 +1,143 @@
+// comments ports the anchor handling of lib/sheet/comment.go. Comment threads
+// live beside the op-log: the server moves their anchors along with every op
+// it applies and broadcasts a thread whenever it changes, together with the
+// revision its anchor is valid at. CommentStore keeps the threads in confirmed
+// (server) coordinates; threadAt maps them through the pending local ops for
+// display.
+import { isStructural, type Op } from './op';
+
+// Mirrors Go MaxCommentLength (runes).
+export const MAX_COMMENT_LENGTH = 10000;
+
+export interface CellRef {
+  row: number;
+  col: number;
+}
+
+export interface Comment {
+  id: string;
+  authorId?: string;
+  author?: string;
+  text: string;
+  timestamp: number;
+}
+
+export interface CommentThread {
+  id: string;
+  sheet: string;
+  cell: CellRef;
+  resolved?: boolean;
+  comments: Comment[];
+}
+
+const inRange = (c: CellRef, r0: number, c0: number, r1: number, c1: number): boolean =>
+  c.row >= r0 && c.row <= r1 && c.col >= c0 && c.col <= c1;
+
+// moveAnchor MUST match Go MoveAnchor: where a comment on (sheet, cell) lands
+// once op is applied, or null when its cell is gone (a deleted row/col, or a
+// moveRange destination). Sheet deletes are left to the caller.
+export function moveAnchor(sheet: string, cell: CellRef, op: Op): CellRef | null {
+  if (op.sheet !== sheet) return cell;
+  if (isStructural(op)) {
+    const index = op.index ?? 0;
+    const count = op.count ?? 0;
+    const rows = op.type === 'insertRows' || op.type === 'deleteRows';
+    const at = rows ? cell.row : cell.col;
+    if (at < index) return cell;
+    let moved = at;
+    if (op.type === 'insertRows' || op.type === 'insertCols') moved = at + count;
+    else if (at < index + count) return null;
+    else moved = at - count;
+    return rows ? { row: moved, col: cell.col } : { row: cell.row, col: moved };
+  }
+  if (op.type !== 'moveRange') return cell;
+  const row = op.row ?? 0;
+  const col = op.col ?? 0;
+  const endRow = op.endRow ?? 0;
+  const endCol = op.endCol ?? 0;
+  const dRow = (op.destRow ?? 0) - row;
+  const dCol = (op.destCol ?? 0) - col;
+  if (dRow === 0 && dCol === 0) return cell;
+  if (inRange(cell, row, col, endRow, endCol)) return { row: cell.row + dRow, col: cell.col + dCol };
+  if (inRange(cell, row + dRow, col + dCol, endRow + dRow, endCol + dCol)) return null;
+  return cell;
+}
+
+// CommentStore holds the comment threads of a workbook. Each thread remembers
+// the revision its anchor is valid at, so an op the server already moved it
+// past is not applied twice.
+export class CommentStore {
+  onChange: () => void = () => {};
+
+  private threads = new Map<string, { thread: CommentThread; rev: number }>();
+
+  // reset replaces every thread (SHEET_VARS and SHEET_COMMENTS).
+  reset(threads: CommentThread[] | null | undefined, rev: number): void {
+    this.threads.clear();
+    for (const t of threads ?? []) this.threads.set(t.id, { thread: t, rev });
+    this.onChange();
+  }
+
+  // update applies one SHEET_COMMENT broadcast.
+  update(thread: CommentThread, deleted: boolean, rev: number): void {
+    if (deleted) this.threads.delete(thread.id);
+    else this.threads.set(thread.id, { thread, rev });
+    this.onChange();
+  }
+
+  // onConfirmed moves the anchors past an op that became revision rev;
+  // hasSheet tells whether a deleted sheet is really gone.
+  onConfirmed(op: Op, rev: number, hasSheet: (id: string) => boolean): void {
+    let changed = false;
+    for (const [id, e] of [...this.threads]) {
+      if (e.rev >= rev || e.thread.sheet !== op.sheet) continue;
+      e.rev = rev;
+      const cell = moveAnchor(e.thread.sheet, e.thread.cell, op);
+      if (cell === null || !hasSheet(e.thread.sheet)) {
+        this.threads.delete(id);
+        changed = true;
+      } else if (cell !== e.thread.cell) {
+        e.thread = { ...e.thread, cell };
+        changed = true;
+      }
+    }
+    if (changed) this.onChange();
+  }
+
+  get(id: string): CommentThread | undefined {
+    return this.threads.get(id)?.thread;
+  }
+
+  // list returns the threads of a sheet at their displayed cells (see
+  // threadAt), in reading order; threads whose cell a pending op removes are
+  // left out.
+  list(sheet: string, pending: readonly Op[] = []): CommentThread[] {
+    const out: CommentThread[] = [];
+    for (const { thread } of this.threads.values()) {
+      if (thread.sheet !== sheet) continue;
+      const cell = displayCell(thread, pending);
+      if (cell) out.push(cell === thread.cell ? thread : { ...thread, cell });
+    }
+    return out.sort((a, b) => a.cell.row - b.cell.row || a.cell.col - b.cell.col || (a.id < b.id ? -1 : 1));
+  }
+
+  // threadAt returns the thread shown on a cell of the display, which is the
+  // confirmed state plus the pending local ops.
+  threadAt(sheet: string, row: number, col: number, pending: readonly Op[] = []): CommentThread | undefined {
+    for (const { thread } of this.threads.values()) {
+      if (thread.sheet !== sheet) continue;
+      const cell = displayCell(thread, pending);
+      if (cell && cell.row === row && cell.col === col) return thread;
+    }
+    return undefined;
+  }
+}
+
+function displayCell(t: CommentThread, pending: readonly Op[]): CellRef | null {
+  let cell: CellRef | null = t.cell;
+  for (const op of pending) {
+    cell = moveAnchor(t.sheet, cell, op);
+    if (cell === null) return null;
+  }
+  return cell;
+}
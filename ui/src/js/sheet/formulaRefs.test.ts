@@ -0,0 +1,87 @@
+import { describe, it, expect } from 'vitest';
+import { dropSheetRefs, renameRefs, sameSheetName, shiftRefs } from './formulaRefs';
+import { transform } from './transform';
+import { WorkbookState } from './workbookState';
+import type { Op } from './op';
+
+// Same cases as Go formularef_test so both sides rewrite bit-for-bit alike.
+describe('shiftRefs (port of Go shiftRefs)', () => {
+  const own = (sheet: string): boolean => sheet === '' || sameSheetName(sheet, 'Sheet1');
+  const rows: [string, number, number, string][] = [
+    ['=SUM(A2:A10)', 0, 2, '=SUM(A4:A12)'],
+    ['=SUM(A1:A10)', 4, 1, '=SUM(A1:A11)'],
+    ['=SUM(A1:A10)', 10, 1, '=SUM(A1:A10)'],
+    ['=$A$5+B$5+$C5', 0, 1, '=$A$6+B$6+$C6'],
+    ['=A3*2', 2, -1, '=#REF!*2'],
+    ['=SUM(A2:A10)', 0, -3, '=SUM(A1:A7)'],
+    ['=SUM(A2:A10)', 8, -5, '=SUM(A2:A8)'],
+    ['=SUM(A3:B4)', 1, -5, '=SUM(#REF!)'],
+    ['=SUM(2:4)', 0, 1, '=SUM(3:5)'],
+    ['=SUM(A:C)', 0, 1, '=SUM(A:C)'],
+    ["=sheet1!B3+'Sheet1'!B3", 0, 1, '=sheet1!B4+Sheet1!B4'],
+    ['=Sheet2!B3+B3', 0, 1, '=Sheet2!B3+B4'],
+    ['=A5&"A5"&"say ""A5"""&A5', 0, 1, '=A6&"A5"&"say ""A5"""&A6'],
+    ['=LOG10(A5)+ATAN2(A5,1)', 0, 1, '=LOG10(A6)+ATAN2(A6,1)'],
+    ['=sum(a5:b6)', 0, 1, '=sum(A6:B7)'],
+    ['A5', 0, 1, 'A5'],
+  ];
+  it.each(rows)('rows: %s at %i by %i', (raw, index, delta, want) => {
+    expect(shiftRefs(raw, own, 'row', index, delta)).toBe(want);
+  });
+
+  const cols: [string, number, number, string][] = [
+    ['=SUM(B1:D1)', 1, 1, '=SUM(C1:E1)'],
+    ['=SUM(A:C)', 1, 2, '=SUM(A:E)'],
+    ['=Z1+AA1', 25, 1, '=AA1+AB1'],
+    ['=SUM(B:B)', 1, -1, '=SUM(#REF!)'],
+    ['=SUM(1:3)', 0, 1, '=SUM(1:3)'],
+  ];
+  it.each(cols)('cols: %s at %i by %i', (raw, index, delta, want) => {
+    expect(shiftRefs(raw, (sheet) => sheet === '', 'col', index, delta)).toBe(want);
+  });
+});
+
+describe('sheet rename and delete', () => {
+  it('renames and drops qualified references', () => {
+    expect(renameRefs("=Data!A1+'data'!B2+A1", 'Data', 'Q1 Numbers')).toBe("='Q1 Numbers'!A1+'Q1 Numbers'!B2+A1");
+    expect(renameRefs("='It''s'!A1", "It's", 'B1')).toBe("='B1'!A1");
+    expect(dropSheetRefs('=Data!A1:B2+Other!A1+A1', 'data')).toBe('=#REF!+Other!A1+A1');
+  });
+});
+
+describe('applyOp rewrites formulas (mirrors Go TestApplyRewritesFormulas)', () => {
+  it('insertRows, renameSheet, deleteSheet', () => {
+    const w = new WorkbookState();
+    w.addSheet('s1', 'Sheet1');
+    w.addSheet('s2', 'Sheet2');
+    w.applyOp({ type: 'setCell', sheet: 's1', baseRev: 0, row: 0, col: 0, raw: '=SUM(A2:A5)', value: '10' });
+    w.applyOp({ type: 'setCell', sheet: 's1', baseRev: 0, row: 0, col: 1, raw: '=Sheet2!B3' });
+    w.applyOp({ type: 'setCell', sheet: 's2', baseRev: 0, row: 0, col: 0, raw: '=Sheet1!A3+A3' });
+    const before = w.clone();
+
+    w.applyOp({ type: 'insertRows', sheet: 's1', baseRev: 0, index: 2, count: 1 });
+    expect(w.getCell('s1', 0, 0)).toMatchObject({ raw: '=SUM(A2:A6)', value: undefined });
+    expect(w.getCell('s2', 0, 0)?.raw).toBe('=Sheet1!A4+A3');
+    expect(before.getCell('s1', 0, 0)?.raw).toBe('=SUM(A2:A5)'); // clones share no rewritten cells
+
+    w.applyOp({ type: 'renameSheet', sheet: 's2', baseRev: 0, name: 'Data' });
+    expect(w.getCell('s1', 0, 1)?.raw).toBe('=Data!B3');
+    w.applyOp({ type: 'deleteSheet', sheet: 's2', baseRev: 0 });
+    expect(w.getCell('s1', 0, 1)?.raw).toBe('=#REF!');
+  });
+});
+
+describe('transform rewrites formulas (mirrors Go TestTransformRewritesFormula)', () => {
+  const inOp: Op = { type: 'setCell', sheet: 's2', baseRev: 0, row: 0, col: 0, raw: '=SUM(Sheet1!A1:A3)+A2' };
+  it('follows structural ops, renames and deletes', () => {
+    const del: Op = { type: 'deleteRows', sheet: 's1', baseRev: 0, index: 0, count: 1, sheetName: 'Sheet1' };
+    expect(transform(inOp, del).raw).toBe('=SUM(Sheet1!A1:A2)+A2');
+    expect(inOp.raw).toBe('=SUM(Sheet1!A1:A3)+A2');
+    const legacy: Op = { type: 'insertRows', sheet: 's2', baseRev: 0, index: 0, count: 1 };
+    expect(transform(inOp, legacy).raw).toBe('=SUM(Sheet1!A1:A3)+A3');
+    const rename: Op = { type: 'renameSheet', sheet: 's1', baseRev: 0, name: 'Data', sheetName: 'Sheet1' };
+    expect(transform(inOp, rename).raw).toBe('=SUM(Data!A1:A3)+A2');
+    const drop: Op = { type: 'deleteSheet', sheet: 's1', baseRev: 0, sheetName: 'Sheet1' };
+    expect(transform(inOp, drop).raw).toBe('=SUM(#REF!)+A2');
+  });
+});
@@ -0,0 +1,231 @@
+// formulaRefs ports lib/sheet/formularef.go exactly: reference-aware rewriting
+// of formulas for structural ops, sheet renames and sheet deletes. MUST match
+// the Go implementation bit-for-bit, since the server and every client apply
+// the same rewrite to their own copy of the workbook.
+
+const REF_ERROR = '#REF!';
+
+// Same pattern as Go referenceRe. Group 1 is a quoted sheet name, group 2 an
+// unquoted one, group 3 the reference itself.
+const referenceRe =
+  /(?:'((?:[^']|'')+)'!|([\p{L}_][\p{L}\p{N}_.]*)!)?(\$?[A-Za-z]{1,3}\$?[0-9]+(?::\$?[A-Za-z]{1,3}\$?[0-9]+)?|\$?[A-Za-z]{1,3}:\$?[A-Za-z]{1,3}|\$?[0-9]+:\$?[0-9]+)/gu;
+const refPartRe = /^(\$?)([A-Za-z]*)(\$?)([0-9]*)$/;
+const plainNameRe = /^[\p{L}_][\p{L}\p{N}_.]*$/u;
+const cellLikeRe = /^[A-Za-z]{1,3}[0-9]+$/;
+const letterOrDigitRe = /^[\p{L}\p{Nd}]$/u;
+
+// One end of a reference; row/col are zero-based, -1 when absent (a column
+// range has no rows, a row range no columns).
+export interface RefPart {
+  row: number;
+  col: number;
+  absRow: boolean;
+  absCol: boolean;
+}
+
+export interface FormulaRef {
+  sheet: string; // unquoted sheet name, '' when unqualified
+  start: RefPart;
+  end: RefPart;
+  range: boolean;
+}
+
+const mayPrecedeRef = (ch: string): boolean => !letterOrDigitRe.test(ch) && !"_.$'#!".includes(ch);
+const mayFollowRef = (ch: string): boolean => !letterOrDigitRe.test(ch) && !"_.$'!(:".includes(ch);
+
+const samePart = (a: RefPart, b: RefPart): boolean =>
+  a.row === b.row && a.col === b.col && a.absRow === b.absRow && a.absCol === b.absCol;
+const sameRef = (a: FormulaRef, b: FormulaRef): boolean =>
+  a.sheet === b.sheet && a.range === b.range && samePart(a.start, b.start) && samePart(a.end, b.end);
+
+// rewriteRefs replaces every reference in the formula raw by the result of fn,
+// or by #REF! when fn returns null. String literals are left alone, as are
+// references fn returns unchanged; values that are no formula are returned as is.
+export function rewriteRefs(raw: string, fn: (ref: FormulaRef) => FormulaRef | null): string {
+  if (!raw.startsWith('=')) return raw;
+  // Outside string literals every other segment ("" escapes give an empty one).
+  const segments = raw.slice(1).split('"');
+  return '=' + segments.map((seg, i) => (i % 2 === 1 ? seg : rewriteSegment(seg, fn))).join('"');
+}
+
+function rewriteSegment(segment: string, fn: (ref: FormulaRef) => FormulaRef | null): string {
+  let out = '';
+  let last = 0;
+  for (const m of segment.matchAll(referenceRe)) {
+    const start = m.index ?? 0;
+    const end = start + m[0].length;
+    if (start > 0 && !mayPrecedeRef(Array.from(segment.slice(0, start)).pop() ?? '')) continue;
+    if (end < segment.length && !mayFollowRef(String.fromCodePoint(segment.codePointAt(end) ?? 0))) continue;
+    const ref = parseRef(m);
+    if (!ref) continue;
+    const next = fn(ref);
+    if (next && sameRef(next, ref)) continue;
+    out += segment.slice(last, start) + (next ? refToString(next) : REF_ERROR);
+    last = end;
+  }
+  return out + segment.slice(last);
+}
+
+function parseRef(m: RegExpMatchArray): FormulaRef | null {
+  let sheet = '';
+  if (m[1] !== undefined) sheet = m[1].replace(/''/g, "'");
+  else if (m[2] !== undefined) sheet = m[2];
+  const parts = m[3].split(':');
+  const start = parseRefPart(parts[0]);
+  if (!start) return null;
+  if (parts.length === 2) {
+    const end = parseRefPart(parts[1]);
+    if (!end) return null;
+    return { sheet, start, end, range: true };
+  }
+  return { sheet, start, end: { ...start }, range: false };
+}
+
+function parseRefPart(s: string): RefPart | null {
+  const m = refPartRe.exec(s);
+  if (!m) return null;
+  const part: RefPart = { row: -1, col: -1, absCol: m[1] === '$', absRow: m[3] === '$' };
+  if (m[2] !== '') {
+    part.col = colIndex(m[2]);
+  } else if (m[1] === '$') {
+    // "$1" is an absolute row: the only marker belongs to the row.
+    part.absCol = false;
+    part.absRow = true;
+  }
+  if (m[4] !== '') {
+    const row = Number(m[4]);
+    if (row < 1) return null;
+    part.row = row - 1;
+  }
+  return part;
+}
+
+const colIndex = (letters: string): number => {
+  let col = 0;
+  for (const c of letters.toUpperCase()) col = col * 26 + (c.charCodeAt(0) - 64);
+  return col - 1;
+};
+
+const colLetters = (col: number): string => {
+  let s = '';
+  for (let n = col + 1; n > 0; n = Math.floor((n - 1) / 26)) s = String.fromCharCode(65 + ((n - 1) % 26)) + s;
+  return s;
+};
+
+const partToString = (p: RefPart): string =>
+  (p.col >= 0 ? (p.absCol ? '$' : '') + colLetters(p.col) : '') +
+  (p.row >= 0 ? (p.absRow ? '$' : '') + String(p.row + 1) : '');
+
+const refToString = (r: FormulaRef): string =>
+  (r.sheet !== '' ? quoteSheetName(r.sheet) + '!' : '') +
+  partToString(r.start) +
+  (r.range ? ':' + partToString(r.end) : '');
+
+// quoteSheetName quotes a name that is no plain identifier or looks like a cell.
+export const quoteSheetName = (name: string): string =>
+  plainNameRe.test(name) && !cellLikeRe.test(name) ? name : `'${name.replace(/'/g, "''")}'`;
+
+// sameSheetName ignores the case of ASCII letters only, like Go.
+export const sameSheetName = (a: string, b: string): boolean =>
+  a.replace(/[A-Z]/g, (c) => c.toLowerCase()) === b.replace(/[A-Z]/g, (c) => c.toLowerCase());
+
+// shiftRefs mirrors Go shiftRefs: rewrite the references target selects for a
+// row/col insert (delta > 0) or delete of -delta indices at index.
+export function shiftRefs(
+  raw: string,
+  target: (sheet: string) => boolean,
+  axis: 'row' | 'col',
+  index: number,
+  delta: number,
+): string {
+  return rewriteRefs(raw, (ref) => {
+    if (!target(ref.sheet)) return ref;
+    const s = axis === 'row' ? ref.start.row : ref.start.col;
+    const e = axis === 'row' ? ref.end.row : ref.end.col;
+    if (s < 0) return ref; // a column range on a row op, or the other way round
+    let lo = Math.min(s, e);
+    let hi = Math.max(s, e);
+    if (delta >= 0) {
+      lo = shiftCoord(lo, index, delta);
+      hi = shiftCoord(hi, index, delta);
+    } else {
+      const band = -delta;
+      if (lo >= index && hi < index + band) return null;
+      lo = shiftCoord(lo, index, delta);
+      if (hi >= index + band) hi -= band;
+      else if (hi >= index) hi = index - 1;
+    }
+    const [ns, ne] = s <= e ? [lo, hi] : [hi, lo];
+    const next: FormulaRef = { ...ref, start: { ...ref.start }, end: { ...ref.end } };
+    if (axis === 'row') {
+      next.start.row = ns;
+      next.end.row = ne;
+    } else {
+      next.start.col = ns;
+      next.end.col = ne;
+    }
+    return next;
+  });
+}
+
+export const renameRefs = (raw: string, from: string, to: string): string =>
+  rewriteRefs(raw, (ref) => (ref.sheet !== '' && sameSheetName(ref.sheet, from) ? { ...ref, sheet: to } : ref));
+
+export const dropSheetRefs = (raw: string, name: string): string =>
+  rewriteRefs(raw, (ref) => (ref.sheet === '' || !sameSheetName(ref.sheet, name) ? ref : null));
+
+// Largest row and column a reference may address (mirrors Go maxRefRow/maxRefCol).
+const MAX_REF_ROW = 2 ** 20 - 1;
+const MAX_REF_COL = 2 ** 14 - 1;
+
+// offsetRefs mirrors Go offsetRefs: move the relative parts of every reference
+// by dRow/dCol like copying the formula does; off-grid references become #REF!.
+export function offsetRefs(raw: string, dRow: number, dCol: number): string {
+  if (dRow === 0 && dCol === 0) return raw;
+  return rewriteRefs(raw, (ref) => {
+    const next: FormulaRef = { ...ref, start: { ...ref.start }, end: { ...ref.end } };
+    for (const p of [next.start, next.end]) {
+      if (p.row >= 0 && !p.absRow) {
+        p.row += dRow;
+        if (p.row < 0 || p.row > MAX_REF_ROW) return null;
+      }
+      if (p.col >= 0 && !p.absCol) {
+        p.col += dCol;
+        if (p.col < 0 || p.col > MAX_REF_COL) return null;
+      }
+    }
+    return next;
+  });
+}
+
+// moveRefs mirrors Go moveRefs: references target selects that lie wholly
+// inside [r0..r1] x [c0..c1] follow cells moved by dRow/dCol.
+export function moveRefs(
+  raw: string,
+  target: (sheet: string) => boolean,
+  r0: number,
+  c0: number,
+  r1: number,
+  c1: number,
+  dRow: number,
+  dCol: number,
+): string {
+  const inside = (p: RefPart): boolean => p.row >= r0 && p.row <= r1 && p.col >= c0 && p.col <= c1;
+  return rewriteRefs(raw, (ref) => {
+    if (!target(ref.sheet) || !inside(ref.start) || !inside(ref.end)) return ref;
+    return {
+      ...ref,
+      start: { ...ref.start, row: ref.start.row + dRow, col: ref.start.col + dCol },
+      end: { ...ref.end, row: ref.end.row + dRow, col: ref.end.col + dCol },
+    };
+  });
+}
+
+// shiftCoord mirrors Go shiftCoord.
+function shiftCoord(coord: number, index: number, delta: number): number {
+  if (delta >= 0) return coord >= index ? coord + delta : coord;
+  const band = -delta;
+  if (coord < index) return coord;
+  if (coord < index + band) return index;
+  return coord - band;
+}
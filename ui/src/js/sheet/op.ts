@@ -17,7 +17,47 @@ export type OpType =
   | 'setDimension'
   | 'setFreeze'
   | 'mergeCells'
-  | 'unmergeCells';
+  | 'unmergeCells'
+  | 'sortRange'
+  | 'moveRange'
+  | 'copyRange'
+  | 'fillSeries'
+  | 'setValidation'
+  | 'setCondFormat'
+  | 'setProtection'
+  | 'deleteRule';
+
+// Mirrors Go MaxSortKeys / MaxFillCells.
+export const MAX_SORT_KEYS = 8;
+export const MAX_FILL_CELLS = 100000;
+
+// SortKey is one sortRange key: an absolute column inside the range.
+export interface SortKey {
+  col: number;
+  desc?: boolean;
+}
+
+// Rule mirrors Go sheet.Rule: a data validation, conditional format or
+// protected range, depending on the workbook list it is in. In a set op the
+// rule's sheet and range are the op's; the payload only carries the rest.
+export interface Rule {
+  id: string;
+  sheet?: string;
+  row?: number;
+  col?: number;
+  endRow?: number;
+  endCol?: number;
+  // validations and conditional formats: the condition
+  type?: string;
+  operator?: string;
+  value1?: string;
+  value2?: string;
+  list?: string[]; // validation type 'list'
+  // conditional formats: style props shown while the condition holds
+  props?: Record<string, string>;
+  // protected ranges: the authors who may edit the cells and the rule
+  authors?: string[];
+}
 
 export interface Op {
   type: OpType;
@@ -26,7 +66,9 @@ export interface Op {
   // cell / range top-left
   row?: number;
   col?: number;
-  // range end (inclusive) for clearRange / mergeCells / unmergeCells
+  // range end (inclusive) for clearRange / mergeCells / unmergeCells and the
+  // range ops (sortRange / moveRange / copyRange: the source; fillSeries: the
+  // whole target including the seed)
   endRow?: number;
   endCol?: number;
   // setCell / setStyle payload
@@ -42,12 +84,24 @@ export interface Op {
   // sheet-list ops
   name?: string; // addSheet, renameSheet
   toIndex?: number; // moveSheet
-  // setDimension
+  // setDimension; fillSeries fills down ('row') or right ('col') from a seed
+  // of `count` rows/cols
   axis?: 'col' | 'row';
   size?: number; // px
+  // sortRange keys, most significant first
+  sortKeys?: SortKey[];
+  // moveRange / copyRange: top-left of the destination
+  destRow?: number;
+  destCol?: number;
   // setFreeze (0 or 1 each)
   frozenRows?: number;
   frozenCols?: number;
+  // setValidation / setCondFormat / setProtection / deleteRule (id only)
+  rule?: Rule;
+  // Set by the server on logged structural, renameSheet and deleteSheet ops:
+  // the sheet's name before the op, for rewriting sheet-qualified formula
+  // references in transform. Clients never set it.
+  sheetName?: string;
 }
 
 // serializeOp produces the JSON the Go server unmarshals into sheet.Op.
@@ -0,0 +1,160 @@
+import { describe, it, expect, beforeEach } from 'vitest';
+import { WorkbookState } from './workbookState';
+import { formatSeriesNumber } from './rangeOps';
+import { transform } from './transform';
+import type { Op } from './op';
+
+let wb: WorkbookState;
+beforeEach(() => {
+  wb = new WorkbookState();
+  wb.addSheet('s1', 'Sheet1');
+});
+
+const fill = (rows: string[][]): void => {
+  rows.forEach((cells, r) =>
+    cells.forEach((raw, c) => {
+      if (raw !== '') wb.applyOp({ type: 'setCell', sheet: 's1', baseRev: 0, row: r, col: c, raw });
+    }),
+  );
+};
+
+const grid = (rows: number, cols: number): string[][] =>
+  Array.from({ length: rows }, (_, r) => Array.from({ length: cols }, (_, c) => wb.getCell('s1', r, c)?.raw ?? ''));
+
+// Same cases as lib/sheet/rangeops_test.go: both sides must agree.
+describe('range ops (port of Go rangeops.go)', () => {
+  it('sortRange sorts by type, keeps blank-key rows last', () => {
+    fill([
+      ['Name', 'Score'],
+      ['bob', '10'],
+      ['', ''],
+      ['Alice', '9'],
+      ['carol', 'x'],
+      ['dave', ''],
+      ['alice', 'TRUE'],
+      ['erin', '=B2*2'],
+    ]);
+    wb.applyOp({
+      type: 'sortRange', sheet: 's1', baseRev: 0, row: 1, col: 0, endRow: 7, endCol: 1,
+      sortKeys: [{ col: 1, desc: true }, { col: 0 }],
+    });
+    expect(grid(8, 2)).toEqual([
+      ['Name', 'Score'],
+      ['alice', 'TRUE'],
+      ['carol', 'x'],
+      ['bob', '10'],
+      ['Alice', '9'],
+      ['dave', ''],
+      ['erin', '=B1*2'],
+      ['', ''],
+    ]);
+    wb.applyOp({ type: 'setCell', sheet: 's1', baseRev: 0, row: 6, col: 1, raw: '=B1*2', value: '20' });
+    wb.applyOp({ type: 'sortRange', sheet: 's1', baseRev: 0, row: 1, col: 0, endRow: 7, endCol: 1, sortKeys: [{ col: 1 }] });
+    expect(grid(8, 2)).toEqual([
+      ['Name', 'Score'],
+      ['Alice', '9'],
+      ['bob', '10'],
+      ['erin', '=#REF!*2'],
+      ['carol', 'x'],
+      ['alice', 'TRUE'],
+      ['dave', ''],
+      ['', ''],
+    ]);
+  });
+
+  it('moveRange moves cells and the references to them', () => {
+    wb.addSheet('s2', 'Other');
+    fill([
+      ['1', '=A1+1', '=$A$1'],
+      ['=SUM(A1:B1)', '=A:A', 'gone'],
+    ]);
+    wb.applyOp({ type: 'setCell', sheet: 's2', baseRev: 0, row: 0, col: 0, raw: '=Sheet1!A1+Sheet1!C1' });
+    wb.applyOp({ type: 'moveRange', sheet: 's1', baseRev: 0, row: 0, col: 0, endRow: 0, endCol: 1, destRow: 1, destCol: 1 });
+    expect(grid(2, 3)).toEqual([
+      ['', '', '=$B$2'],
+      ['=SUM(B2:C2)', '1', '=B2+1'],
+    ]);
+    expect(wb.getCell('s2', 0, 0)?.raw).toBe('=Sheet1!B2+Sheet1!C1');
+  });
+
+  it('copyRange offsets relative references', () => {
+    fill([
+      ['1', '=A1*$A$1'],
+      ['2', '=A2*$A$1'],
+      ['', '', 'old'],
+    ]);
+    wb.applyOp({ type: 'copyRange', sheet: 's1', baseRev: 0, row: 0, col: 0, endRow: 1, endCol: 1, destRow: 1, destCol: 1 });
+    expect(grid(3, 3)).toEqual([
+      ['1', '=A1*$A$1', ''],
+      ['2', '1', '=B2*$A$1'],
+      ['', '2', '=B3*$A$1'],
+    ]);
+    wb.applyOp({ type: 'copyRange', sheet: 's1', baseRev: 0, row: 1, col: 2, endRow: 1, endCol: 2, destRow: 0, destCol: 0 });
+    expect(wb.getCell('s1', 0, 0)?.raw).toBe('=#REF!*$A$1');
+  });
+
+  it('fillSeries extends numbers, numbered text, formulas and styles', () => {
+    fill([
+      ['1', 'Item 09', '0.1', 'x', '=A1*2', '5', '1.5'],
+      ['3', '', '0.25', 'y', '', '', ''],
+    ]);
+    wb.applyOp({ type: 'setStyle', sheet: 's1', baseRev: 0, row: 1, col: 3, props: { bold: '1' } });
+    for (const [col, count] of [[0, 2], [1, 1], [2, 2], [3, 2], [4, 1], [5, 1], [6, 1]]) {
+      wb.applyOp({ type: 'fillSeries', sheet: 's1', baseRev: 0, row: 0, col, endRow: 4, endCol: col, axis: 'row', count });
+    }
+    expect(grid(5, 7)).toEqual([
+      ['1', 'Item 09', '0.1', 'x', '=A1*2', '5', '1.5'],
+      ['3', 'Item 10', '0.25', 'y', '=A2*2', '5', '1.5'],
+      ['5', 'Item 11', '0.4', 'x', '=A3*2', '5', '1.5'],
+      ['7', 'Item 12', '0.55', 'y', '=A4*2', '5', '1.5'],
+      ['9', 'Item 13', '0.7', 'x', '=A5*2', '5', '1.5'],
+    ]);
+    expect(wb.getCell('s1', 3, 3)?.styleId).toBeTruthy();
+    expect(wb.getCell('s1', 2, 3)?.styleId ?? 0).toBe(0);
+
+    fill([[], [], [], [], [], ['Q3', 'Q2']]);
+    wb.applyOp({ type: 'fillSeries', sheet: 's1', baseRev: 0, row: 5, col: 0, endRow: 5, endCol: 4, axis: 'col', count: 2 });
+    expect(grid(6, 5)[5]).toEqual(['Q3', 'Q2', 'Q1', 'Q0', 'Q1']);
+  });
+
+  it('formatSeriesNumber prints like Go strconv', () => {
+    expect(formatSeriesNumber(0.30000000000000004, 1)).toBe('0.3');
+    expect(formatSeriesNumber(2.5, 0)).toBe('3');
+    expect(formatSeriesNumber(-2.5, 0)).toBe('-2');
+    expect(formatSeriesNumber(1e-7, 7)).toBe('0.0000001');
+    expect(formatSeriesNumber(-0.1, 0)).toBe('0');
+  });
+});
+
+describe('transform of range ops (port of Go Transform)', () => {
+  const insert: Op = { type: 'insertRows', sheet: 's1', baseRev: 0, index: 2, count: 2 };
+  const deleteCols: Op = { type: 'deleteCols', sheet: 's1', baseRev: 0, index: 1, count: 1 };
+
+  it('sortRange grows with rows and follows key columns', () => {
+    const sort: Op = {
+      type: 'sortRange', sheet: 's1', baseRev: 0, row: 1, col: 0, endRow: 4, endCol: 3, sortKeys: [{ col: 2, desc: true }],
+    };
+    expect(transform(sort, insert)).toMatchObject({ row: 1, endRow: 6 });
+    const out = transform(sort, deleteCols);
+    expect(out.endCol).toBe(2);
+    expect(out.sortKeys).toEqual([{ col: 1, desc: true }]);
+    expect(sort.sortKeys?.[0].col).toBe(2);
+  });
+
+  it('moveRange shifts its destination', () => {
+    const move: Op = { type: 'moveRange', sheet: 's1', baseRev: 0, row: 0, col: 2, endRow: 1, endCol: 3, destRow: 5, destCol: 4 };
+    expect(transform(move, insert)).toMatchObject({ endRow: 1, destRow: 7 });
+    expect(transform(move, deleteCols)).toMatchObject({ col: 1, endCol: 2, destCol: 3 });
+    const set: Op = { type: 'setCell', sheet: 's1', baseRev: 0, row: 9, col: 0, raw: '=C1+Sheet1!D2+E1' };
+    expect(transform(set, { ...move, sheetName: 'Sheet1' }).raw).toBe('=E6+Sheet1!F7+E1');
+  });
+
+  it('fillSeries seed shifts like a merge', () => {
+    const fillOp: Op = { type: 'fillSeries', sheet: 's1', baseRev: 0, row: 1, col: 0, endRow: 6, endCol: 0, axis: 'row', count: 2 };
+    expect(transform(fillOp, insert)).toMatchObject({ count: 4, endRow: 8 });
+    expect(transform(fillOp, { type: 'deleteRows', sheet: 's1', baseRev: 0, index: 0, count: 3 })).toMatchObject({
+      row: 0, count: 1, endRow: 3,
+    });
+    expect(transform(fillOp, { type: 'insertRows', sheet: 's1', baseRev: 0, index: 3, count: 1 }).count).toBe(2);
+  });
+});
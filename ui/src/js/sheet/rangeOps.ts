@@ -0,0 +1,154 @@
+// rangeOps ports the value logic of lib/sheet/rangeops.go: the sortRange
+// comparator and the fillSeries series. MUST match the Go implementation
+// bit-for-bit, since the server and every client apply range ops to their
+// own copy of the workbook.
+import { offsetRefs } from './formulaRefs';
+import type { Cell } from './workbookState';
+
+// Sort order of value types; blanks sort last in both directions.
+const SORT_NUMBER = 0;
+const SORT_TEXT = 1;
+const SORT_BOOL = 2;
+const SORT_ERROR = 3;
+const SORT_BLANK = 4;
+
+// Same patterns as Go sortNumberRe / seriesNumberRe / seriesTextRe.
+const sortNumberRe = /^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$/;
+const seriesNumberRe = /^[+-]?\d+(\.\d+)?$/;
+const seriesTextRe = /^(.*\D)(\d{1,9})$/s;
+
+const ERROR_VALUES = new Set(['#NULL!', '#DIV/0!', '#VALUE!', '#REF!', '#NAME?', '#NUM!', '#N/A']);
+
+export interface SortValue {
+  kind: number;
+  num: number;
+  text: string;
+}
+
+const isFormula = (c: Cell | undefined): boolean => c !== undefined && c.raw.startsWith('=');
+
+const lowerASCII = (s: string): string => s.replace(/[A-Z]/g, (c) => c.toLowerCase());
+
+// sortValueOf mirrors Go sortValueOf: formulas sort by their cached value,
+// text case-insensitively for ASCII letters.
+export function sortValueOf(c: Cell | undefined): SortValue {
+  const v = (isFormula(c) ? c?.value : c?.raw) ?? '';
+  const lower = lowerASCII(v);
+  if (v === '') return { kind: SORT_BLANK, num: 0, text: '' };
+  if (sortNumberRe.test(v)) return { kind: SORT_NUMBER, num: Number(v), text: '' };
+  if (lower === 'true') return { kind: SORT_BOOL, num: 1, text: '' };
+  if (lower === 'false') return { kind: SORT_BOOL, num: 0, text: '' };
+  if (ERROR_VALUES.has(v)) return { kind: SORT_ERROR, num: 0, text: v };
+  return { kind: SORT_TEXT, num: 0, text: lower };
+}
+
+export const isBlank = (v: SortValue): boolean => v.kind === SORT_BLANK;
+
+const cmp = (a: number, b: number): number => (a < b ? -1 : a > b ? 1 : 0);
+
+// compareCodePoints orders like Go string comparison (by code point), which
+// differs from JS < for characters outside the BMP.
+export function compareCodePoints(a: string, b: string): number {
+  let i = 0;
+  let j = 0;
+  while (i < a.length && j < b.length) {
+    const ca = a.codePointAt(i) ?? 0;
+    const cb = b.codePointAt(j) ?? 0;
+    if (ca !== cb) return cmp(ca, cb);
+    i += ca > 0xffff ? 2 : 1;
+    j += cb > 0xffff ? 2 : 1;
+  }
+  return cmp(a.length - i, b.length - j);
+}
+
+// compareSortValues mirrors Go compareSortValues.
+export function compareSortValues(a: SortValue, b: SortValue, desc: boolean): number {
+  if (a.kind === SORT_BLANK || b.kind === SORT_BLANK) return cmp(a.kind, b.kind);
+  let c = cmp(a.kind, b.kind);
+  if (c === 0) {
+    c = a.kind === SORT_NUMBER || a.kind === SORT_BOOL ? cmp(a.num, b.num) : compareCodePoints(a.text, b.text);
+  }
+  return desc ? -c : c;
+}
+
+// offsetCell mirrors Go offsetCell: a cell copied dRow/dCol away. Returns a
+// new object when the formula changes (cells are shared between clones).
+export function offsetCell(c: Cell, dRow: number, dCol: number): Cell {
+  if (!isFormula(c)) return c;
+  const raw = offsetRefs(c.raw, dRow, dCol);
+  return raw === c.raw ? c : { ...c, raw, value: undefined, valueType: undefined };
+}
+
+// series mirrors Go series: the cell at position pos of the series the seed
+// starts (numbers by their average step, "Item 1" counting on, anything else
+// repeated with formulas moved like a copy).
+export function series(seed: Cell[], axis: 'row' | 'col'): (pos: number) => Cell {
+  const n = seed.length;
+  const style = (pos: number): number | undefined => seed[pos % n].styleId;
+  const num = numberSeries(seed);
+  if (num) {
+    return (pos) => ({ raw: formatSeriesNumber(num.first + num.step * pos, num.decimals), styleId: style(pos) });
+  }
+  const text = textSeries(seed);
+  if (text) {
+    return (pos) => ({
+      raw: text.prefix + String(Math.abs(text.first + text.step * pos)).padStart(text.width, '0'),
+      styleId: style(pos),
+    });
+  }
+  return (pos) => {
+    const k = pos % n;
+    return axis === 'col' ? offsetCell(seed[k], 0, pos - k) : offsetCell(seed[k], pos - k, 0);
+  };
+}
+
+function numberSeries(seed: Cell[]): { first: number; step: number; decimals: number } | null {
+  if (seed.length < 2) return null;
+  let decimals = 0;
+  const nums: number[] = [];
+  for (const c of seed) {
+    if (!seriesNumberRe.test(c.raw)) return null;
+    const v = Number(c.raw);
+    if (Math.abs(v) >= 1e15) return null;
+    const dot = c.raw.indexOf('.');
+    if (dot >= 0) decimals = Math.max(decimals, c.raw.length - dot - 1);
+    nums.push(v);
+  }
+  if (decimals > 10) return null;
+  return { first: nums[0], step: (nums[nums.length - 1] - nums[0]) / (nums.length - 1), decimals };
+}
+
+// formatSeriesNumber mirrors Go: round half up, print without trailing zeros
+// (strconv 'f' never uses an exponent; String() does for tiny numbers).
+export function formatSeriesNumber(v: number, decimals: number): string {
+  let p = 1;
+  for (let i = 0; i < decimals; i++) p *= 10;
+  const r = Math.floor(v * p + 0.5) / p;
+  if (r === 0) return '0';
+  const s = String(r);
+  return s.includes('e') && decimals > 0 ? r.toFixed(decimals).replace(/\.?0+$/, '') : s;
+}
+
+function textSeries(seed: Cell[]): { prefix: string; width: number; first: number; step: number } | null {
+  let prefix = '';
+  let width = 0;
+  const nums: number[] = [];
+  for (let i = 0; i < seed.length; i++) {
+    const c = seed[i];
+    const m = seriesTextRe.exec(c.raw);
+    if (!m || isFormula(c) || seriesNumberRe.test(c.raw) || (i > 0 && m[1] !== prefix)) return null;
+    if (i === 0) {
+      prefix = m[1];
+      width = m[2].length;
+    }
+    nums.push(Number(m[2]));
+  }
+  let step = 1;
+  const n = nums.length;
+  if (n > 1) {
+    const diff = nums[n - 1] - nums[0];
+    if (diff % (n - 1) !== 0) return null;
+    step = diff / (n - 1);
+  }
+  return { prefix, width, first: nums[0], step };
+}
@@ -0,0 +1,110 @@
+import { describe, it, expect, beforeEach } from 'vitest';
+import { WorkbookState } from './workbookState';
+import { accepts, checkOp, deleteRuleOps, formatProps, ERR_PROTECTED } from './rules';
+import { transform } from './transform';
+import { SheetCollabClient } from './sheetCollabClient';
+import type { Op, Rule } from './op';
+
+let wb: WorkbookState;
+beforeEach(() => {
+  wb = new WorkbookState();
+  wb.addSheet('s1', 'Sheet1');
+});
+
+const ruleOp = (type: Op['type'], id: string, r0: number, c0: number, r1: number, c1: number, rule: Omit<Rule, 'id'> = {}): Op => ({
+  type, sheet: 's1', baseRev: 0, row: r0, col: c0, endRow: r1, endCol: c1, rule: { id, ...rule },
+});
+const setCell = (row: number, col: number, raw: string): Op => ({ type: 'setCell', sheet: 's1', baseRev: 0, row, col, raw });
+
+describe('rules', () => {
+  it('accepts values like Go Rule.Accepts', () => {
+    const list: Rule = { id: 'v', type: 'list', list: ['yes', 'no'] };
+    expect(accepts(list, 'yes')).toBe(true);
+    expect(accepts(list, 'maybe')).toBe(false);
+    expect(accepts(list, '')).toBe(true);
+    expect(accepts(list, '=A1')).toBe(true);
+    const whole: Rule = { id: 'v', type: 'whole', operator: 'between', value1: '1', value2: '10' };
+    expect(accepts(whole, '5')).toBe(true);
+    expect(accepts(whole, '5.5')).toBe(false);
+    expect(accepts(whole, '11')).toBe(false);
+    expect(accepts(whole, 'five')).toBe(false);
+    const length: Rule = { id: 'v', type: 'textLength', operator: 'lessThanOrEqual', value1: '3' };
+    expect(accepts(length, 'héé')).toBe(true);
+    expect(accepts(length, 'four')).toBe(false);
+  });
+
+  it('checks protections and validations with the server messages', () => {
+    wb.applyOp(ruleOp('setProtection', 'p1', 0, 0, 1, 1, { authors: ['a.alice'] }));
+    wb.applyOp(ruleOp('setValidation', 'v1', 5, 0, 5, 0, { type: 'list', list: ['x'] }));
+    expect(checkOp(wb, setCell(0, 0, 'hi'), 'a.alice')).toBeNull();
+    expect(checkOp(wb, setCell(0, 0, 'hi'), 'a.bob')).toBe(`${ERR_PROTECTED}: A1:B2`);
+    expect(checkOp(wb, setCell(2, 2, 'hi'), 'a.bob')).toBeNull();
+    expect(checkOp(wb, { type: 'deleteRows', sheet: 's1', baseRev: 0, index: 1, count: 1 }, 'a.bob')).not.toBeNull();
+    expect(checkOp(wb, { type: 'deleteRule', sheet: 's1', baseRev: 0, rule: { id: 'p1' } }, 'a.bob')).not.toBeNull();
+    expect(checkOp(wb, setCell(5, 0, 'y'), 'a.bob')).toBe('sheet value fails data validation: A6');
+    expect(checkOp(wb, setCell(5, 0, 'x'), 'a.bob')).toBeNull();
+    // Setting a protection that locks out its own author is refused.
+    expect(checkOp(wb, ruleOp('setProtection', 'p2', 8, 0, 8, 0, { authors: ['a.bob'] }), 'a.alice')).not.toBeNull();
+  });
+
+  it('lets anyone lift a range locked for everyone', () => {
+    wb.applyOp(ruleOp('setProtection', 'p1', 0, 0, 0, 0, { authors: [] }));
+    expect(checkOp(wb, setCell(0, 0, 'x'), 'a.alice')).not.toBeNull();
+    expect(checkOp(wb, { type: 'deleteRule', sheet: 's1', baseRev: 0, rule: { id: 'p1' } }, 'a.alice')).toBeNull();
+  });
+
+  it('merges matching conditional formats, first rule wins', () => {
+    wb.applyOp(ruleOp('setCondFormat', 'f1', 0, 0, 9, 0, { type: 'cellIs', operator: 'greaterThan', value1: '10', props: { bg: '#f00' } }));
+    wb.applyOp(ruleOp('setCondFormat', 'f2', 0, 0, 9, 0, { type: 'text', operator: 'containsText', value1: '1', props: { bg: '#0f0', bold: 'true' } }));
+    expect(formatProps(wb.condFormats, 's1', 0, 0, '15')).toEqual({ bg: '#f00', bold: 'true' });
+    expect(formatProps(wb.condFormats, 's1', 0, 0, '9')).toEqual({});
+    expect(formatProps(wb.condFormats, 's1', 0, 1, '15')).toEqual({});
+    expect(formatProps(wb.condFormats, 's1', 0, 0, '')).toEqual({});
+  });
+
+  it('shifts rules under structural ops and drops them with their sheet', () => {
+    wb.applyOp(ruleOp('setValidation', 'v1', 2, 2, 4, 4, { type: 'list', list: ['x'] }));
+    wb.applyOp({ type: 'insertRows', sheet: 's1', baseRev: 0, index: 0, count: 2 });
+    wb.applyOp({ type: 'deleteCols', sheet: 's1', baseRev: 0, index: 0, count: 1 });
+    expect(wb.ruleById('v1')).toMatchObject({ row: 4, endRow: 6, col: 1, endCol: 3 });
+    wb.addSheet('s2', 'Sheet2');
+    wb.applyOp({ type: 'deleteSheet', sheet: 's1', baseRev: 0 });
+    expect(wb.validations).toEqual([]);
+  });
+
+  it('transforms rule ranges against structural ops', () => {
+    const set = ruleOp('setProtection', 'p1', 3, 0, 5, 0, { authors: [] });
+    const t = transform(set, { type: 'insertRows', sheet: 's1', baseRev: 0, index: 1, count: 2 });
+    expect(t).toMatchObject({ row: 5, endRow: 7 });
+  });
+
+  it('builds delete ops for the rules a selection overlaps', () => {
+    wb.applyOp(ruleOp('setValidation', 'v1', 0, 0, 0, 0, { type: 'list', list: ['x'] }));
+    wb.applyOp(ruleOp('setValidation', 'v2', 9, 9, 9, 9, { type: 'list', list: ['x'] }));
+    expect(deleteRuleOps(wb.validations, 's1', 0, 0, 2, 2, 3)).toEqual([{ type: 'deleteRule', sheet: 's1', baseRev: 3, rule: { id: 'v1' } }]);
+  });
+});
+
+describe('SheetCollabClient rules', () => {
+  it('refuses a protected edit locally and drops a server-rejected one', () => {
+    const sent: Op[] = [];
+    const c = new SheetCollabClient({ sheets: [{ id: 's1', name: 'Sheet1', cells: [] }] }, 0, { send: (op) => sent.push(op) });
+    const reasons: string[] = [];
+    c.onRejected = (r) => reasons.push(r);
+    c.author = 'a.bob';
+    c.onRemote(ruleOp('setProtection', 'p1', 0, 0, 0, 0, { authors: ['a.alice'] }), 1);
+    expect(c.applyLocal({ ...setCell(0, 0, 'x'), baseRev: 1 })).toBe(false);
+    expect(sent).toHaveLength(0);
+    expect(reasons).toEqual([`${ERR_PROTECTED}: A1`]);
+
+    // The server may still reject what the stale local view allowed.
+    expect(c.applyLocal({ ...setCell(1, 0, 'y'), baseRev: 1 })).toBe(true);
+    expect(c.applyLocal({ ...setCell(2, 0, 'z'), baseRev: 1 })).toBe(true);
+    expect(sent).toHaveLength(1);
+    c.onReject(`${ERR_PROTECTED}: A2`);
+    expect(c.display.sheets[0].cells.get('1:0')).toBeUndefined();
+    expect(c.display.sheets[0].cells.get('2:0')?.raw).toBe('z');
+    expect(sent).toHaveLength(2);
+    expect(reasons).toHaveLength(2);
+  });
+});
@@ -0,0 +1,196 @@
+// rules ports lib/sheet/rules.go: data validations, conditional formats and
+// protected ranges. checkOp MUST match Go Workbook.Check, which the server
+// enforces on submit; clients check first only to refuse an edit before it is
+// sent. Conditional formats are evaluated by the clients alone.
+import { rangeRefA1 } from './a1';
+import type { Op, Rule } from './op';
+import { compareCodePoints } from './rangeOps';
+
+// Mirrors Go MaxRules (per kind and workbook).
+export const MAX_RULES = 500;
+
+// Mirror the messages of Go ErrProtected / ErrInvalidValue, which prefix the
+// reason of a REJECT_SHEET_OP.
+export const ERR_PROTECTED = 'sheet range is protected';
+export const ERR_INVALID_VALUE = 'sheet value fails data validation';
+
+export type RuleKind = 'setValidation' | 'setCondFormat' | 'setProtection';
+
+// Same pattern as Go sortNumberRe.
+const numberRe = /^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$/;
+
+const lowerASCII = (s: string): string => s.replace(/[A-Z]/g, (c) => c.toLowerCase());
+
+// compareRuleValues mirrors Go: numeric when both are numbers, else text,
+// case-insensitive for ASCII letters.
+export function compareRuleValues(a: string, b: string): number {
+  if (numberRe.test(a) && numberRe.test(b)) {
+    const [x, y] = [Number(a), Number(b)];
+    return x < y ? -1 : x > y ? 1 : 0;
+  }
+  return compareCodePoints(lowerASCII(a), lowerASCII(b));
+}
+
+// compareHolds mirrors Go Rule.compareHolds.
+export function compareHolds(r: Rule, v: string): boolean {
+  const c = compareRuleValues(v, r.value1 ?? '');
+  switch (r.operator) {
+    case 'between':
+      return c >= 0 && compareRuleValues(v, r.value2 ?? '') <= 0;
+    case 'notBetween':
+      return c < 0 || compareRuleValues(v, r.value2 ?? '') > 0;
+    case 'equal':
+      return c === 0;
+    case 'notEqual':
+      return c !== 0;
+    case 'greaterThan':
+      return c > 0;
+    case 'lessThan':
+      return c < 0;
+    case 'greaterThanOrEqual':
+      return c >= 0;
+    case 'lessThanOrEqual':
+      return c <= 0;
+  }
+  return false;
+}
+
+// accepts mirrors Go Rule.Accepts: blanks and formulas always pass.
+export function accepts(r: Rule, raw: string): boolean {
+  if (raw === '' || raw.startsWith('=')) return true;
+  switch (r.type) {
+    case 'list':
+      return (r.list ?? []).includes(raw);
+    case 'decimal':
+    case 'whole':
+      if (!numberRe.test(raw)) return false;
+      if (r.type === 'whole' && !Number.isInteger(Number(raw))) return false;
+      return compareHolds(r, raw);
+    case 'textLength':
+      return compareHolds(r, String([...raw].length));
+  }
+  return true;
+}
+
+// formatMatches reports whether a conditional format applies to a cell
+// showing value. Only the clients evaluate these; blanks never match, text
+// conditions ignore ASCII case like Excel.
+export function formatMatches(r: Rule, value: string): boolean {
+  if (value === '') return false;
+  if (r.type === 'cellIs') return compareHolds(r, value);
+  const v = lowerASCII(value);
+  const needle = lowerASCII(r.value1 ?? '');
+  switch (r.operator) {
+    case 'containsText':
+      return v.includes(needle);
+    case 'notContains':
+      return !v.includes(needle);
+    case 'beginsWith':
+      return v.startsWith(needle);
+    case 'endsWith':
+      return v.endsWith(needle);
+  }
+  return false;
+}
+
+export const ruleContains = (r: Rule, row: number, col: number): boolean =>
+  row >= (r.row ?? 0) && row <= (r.endRow ?? 0) && col >= (r.col ?? 0) && col <= (r.endCol ?? 0);
+
+const overlaps = (r: Rule, r0: number, c0: number, r1: number, c1: number): boolean =>
+  (r.row ?? 0) <= r1 && (r.endRow ?? 0) >= r0 && (r.col ?? 0) <= c1 && (r.endCol ?? 0) >= c0;
+
+const allows = (p: Rule, author: string): boolean => (p.authors ?? []).includes(author);
+
+// ruleOf is the rule a set op leaves in the workbook (mirrors Go setRule).
+export const ruleOf = (op: Op): Rule => ({
+  ...structuredClone(op.rule as Rule),
+  sheet: op.sheet, row: op.row ?? 0, col: op.col ?? 0, endRow: op.endRow ?? 0, endCol: op.endCol ?? 0,
+});
+
+export interface RuleSets {
+  validations: Rule[];
+  condFormats: Rule[];
+  protections: Rule[];
+  ruleById(id: string): Rule | undefined;
+}
+
+// blockedBy mirrors Go Workbook.blockedBy.
+function blockedBy(wb: RuleSets, p: Rule, op: Op, author: string): boolean {
+  if ((op.type === 'setProtection' || op.type === 'deleteRule') && op.rule?.id === p.id) {
+    return (p.authors ?? []).length > 0 && !allows(p, author);
+  }
+  if (allows(p, author)) return false;
+  if (op.type === 'deleteSheet') return op.sheet === p.sheet;
+  if (op.type === 'deleteRule') {
+    const r = wb.ruleById(op.rule?.id ?? '');
+    return r !== undefined && r.sheet === p.sheet && overlaps(p, r.row ?? 0, r.col ?? 0, r.endRow ?? 0, r.endCol ?? 0);
+  }
+  if (op.sheet !== p.sheet) return false;
+  const [row, col, endRow, endCol] = [op.row ?? 0, op.col ?? 0, op.endRow ?? 0, op.endCol ?? 0];
+  const index = op.index ?? 0;
+  const count = op.count ?? 0;
+  switch (op.type) {
+    case 'setCell':
+    case 'setStyle':
+      return ruleContains(p, row, col);
+    case 'clearRange':
+    case 'sortRange':
+    case 'fillSeries':
+    case 'mergeCells':
+    case 'unmergeCells':
+    case 'setValidation':
+    case 'setCondFormat':
+      return overlaps(p, row, col, endRow, endCol);
+    case 'moveRange':
+    case 'copyRange': {
+      const dr = op.destRow ?? 0;
+      const dc = op.destCol ?? 0;
+      const dest = overlaps(p, dr, dc, dr + endRow - row, dc + endCol - col);
+      return dest || (op.type === 'moveRange' && overlaps(p, row, col, endRow, endCol));
+    }
+    case 'deleteRows':
+      return (p.row ?? 0) < index + count && (p.endRow ?? 0) >= index;
+    case 'deleteCols':
+      return (p.col ?? 0) < index + count && (p.endCol ?? 0) >= index;
+  }
+  return false;
+}
+
+// checkOp mirrors Go Workbook.Check: null when author may submit op, else
+// the error the server would reject it with.
+export function checkOp(wb: RuleSets, op: Op, author: string): string | null {
+  if (op.type === 'setProtection' && (op.rule?.authors ?? []).length > 0 && !allows(op.rule as Rule, author)) {
+    return `${ERR_PROTECTED}: a protected range must allow the author who sets it`;
+  }
+  const p = wb.protections.find((p) => blockedBy(wb, p, op, author));
+  if (p) return `${ERR_PROTECTED}: ${rangeRefA1(p.row ?? 0, p.col ?? 0, p.endRow ?? 0, p.endCol ?? 0)}`;
+  if (op.type === 'setCell' && op.raw !== undefined) {
+    const row = op.row ?? 0;
+    const col = op.col ?? 0;
+    for (const v of wb.validations) {
+      if (v.sheet === op.sheet && ruleContains(v, row, col) && !accepts(v, op.raw)) {
+        return `${ERR_INVALID_VALUE}: ${rangeRefA1(row, col, row, col)}`;
+      }
+    }
+  }
+  return null;
+}
+
+// formatProps merges the props of the conditional formats on sheet that match
+// the cell showing value. Like Excel, the first matching rule wins a prop.
+export function formatProps(formats: Rule[], sheet: string, row: number, col: number, value: string): Record<string, string> {
+  const out: Record<string, string> = {};
+  for (const r of formats) {
+    if (r.sheet !== sheet || !ruleContains(r, row, col) || !formatMatches(r, value)) continue;
+    for (const [k, v] of Object.entries(r.props ?? {})) if (!(k in out)) out[k] = v;
+  }
+  return out;
+}
+
+// deleteRuleOps removes the rules on sheet that overlap the rectangle (the
+// ribbon's Clear Rules / Unprotect on a selection).
+export function deleteRuleOps(rules: Rule[], sheet: string, r0: number, c0: number, r1: number, c1: number, baseRev: number): Op[] {
+  return rules
+    .filter((r) => r.sheet === sheet && overlaps(r, r0, c0, r1, c1))
+    .map((r) => ({ type: 'deleteRule', sheet, baseRev, rule: { id: r.id } }));
+}
@@ -1,5 +1,7 @@
 import { describe, it, expect } from 'vitest';
-import { rangeToTSV, rangeToCSV, parseTSV, parseCSV, pasteOps, fillOps, adjustFormula } from './sheetClipboard';
+import {
+  rangeToTSV, rangeToCSV, parseTSV, parseCSV, pasteOps, fillOps, adjustFormula, fillSeriesOp, rangePasteOp,
+} from './sheetClipboard';
 
 const raw = (grid: Record<string, string>) => (r: number, c: number) => grid[`${r}:${c}`] ?? '';
 
@@ -104,3 +106,27 @@ describe('fillOps', () => {
     ]);
   });
 });
+
+describe('range op helpers', () => {
+  const sel = (r0: number, c0: number, r1: number, c1: number) => ({ anchor: { row: r0, col: c0 }, focus: { row: r1, col: c1 } });
+
+  it('fillSeriesOp extends down or right, leaves other fills to fillOps', () => {
+    expect(fillSeriesOp(sel(1, 0, 2, 1), sel(1, 0, 9, 1), 's1', 0)).toEqual({
+      type: 'fillSeries', sheet: 's1', baseRev: 0, row: 1, col: 0, endRow: 9, endCol: 1, axis: 'row', count: 2,
+    });
+    expect(fillSeriesOp(sel(0, 0, 0, 0), sel(0, 0, 0, 4), 's1', 0)).toMatchObject({ axis: 'col', count: 1, endCol: 4 });
+    expect(fillSeriesOp(sel(5, 0, 5, 0), sel(2, 0, 5, 0), 's1', 0)).toBeNull();
+  });
+
+  it('rangePasteOp copies or moves our own unchanged source', () => {
+    const g = raw({ '0:0': '1', '0:1': '=A1' });
+    const clip = { sheet: 's1', sel: sel(0, 0, 0, 1), text: '1\t=A1', cut: false };
+    expect(rangePasteOp(clip, '1\t=A1', { row: 3, col: 2 }, 's1', 0, g)).toEqual({
+      type: 'copyRange', sheet: 's1', baseRev: 0, row: 0, col: 0, endRow: 0, endCol: 1, destRow: 3, destCol: 2,
+    });
+    expect(rangePasteOp({ ...clip, cut: true }, '1\t=A1', { row: 3, col: 2 }, 's1', 0, g)?.type).toBe('moveRange');
+    expect(rangePasteOp(clip, 'other', { row: 3, col: 2 }, 's1', 0, g)).toBeNull();
+    expect(rangePasteOp(clip, '1\t=A1', { row: 3, col: 2 }, 's2', 0, g)).toBeNull();
+    expect(rangePasteOp(clip, '1\t=A1', { row: 3, col: 2 }, 's1', 0, raw({ '0:0': '2', '0:1': '=A1' }))).toBeNull();
+  });
+});
@@ -1,5 +1,6 @@
-// Pure clipboard + fill helpers. They build plain setCell op payloads; the
-// collab client assigns the real baseRev at flush time.
+// Pure clipboard + fill helpers. They build op payloads (setCell, or one range
+// op when the server can do the work); the collab client assigns the real
+// baseRev at flush time.
 
 import type { Op } from './op';
 import type { CellPos, Selection } from './sheetSelection';
@@ -129,3 +130,45 @@ export function fillOps(
   }
   return ops;
 }
+
+// fillSeriesOp is the fillSeries op for a fill that extends src down or right
+// over target (drag-fill), so numbers and "Item 1" continue as a
+// series. Fills running up or left return null and fall back to fillOps.
+export function fillSeriesOp(src: Selection, target: Selection, sheet: string, baseRev: number): Op | null {
+  const s = normalize(src);
+  const t = normalize(target);
+  if (s.r0 !== t.r0 || s.c0 !== t.c0) return null;
+  const rect = { sheet, baseRev, row: t.r0, col: t.c0, endRow: t.r1, endCol: t.c1 };
+  if (s.c1 === t.c1 && t.r1 > s.r1) return { type: 'fillSeries', ...rect, axis: 'row', count: s.r1 - s.r0 + 1 };
+  if (s.r1 === t.r1 && t.c1 > s.c1) return { type: 'fillSeries', ...rect, axis: 'col', count: s.c1 - s.c0 + 1 };
+  return null;
+}
+
+// ClipSource is the range this client last copied or cut, with the TSV it put
+// on the clipboard.
+export interface ClipSource {
+  sheet: string;
+  sel: Selection;
+  text: string;
+  cut: boolean;
+}
+
+// rangePasteOp turns a paste of our own copy/cut back into the same sheet into
+// a copyRange/moveRange op, so formulas adjust (and, for a cut, references to
+// the moved cells follow) and styles travel along. Null when the clipboard
+// holds anything else or the source changed since — use pasteOps then.
+export function rangePasteOp(
+  clip: ClipSource | null,
+  text: string,
+  dest: CellPos,
+  sheet: string,
+  baseRev: number,
+  rawAt: (r: number, c: number) => string,
+): Op | null {
+  if (!clip || clip.sheet !== sheet || clip.text !== text || rangeToTSV(clip.sel, rawAt) !== text) return null;
+  const { r0, c0, r1, c1 } = normalize(clip.sel);
+  return {
+    type: clip.cut ? 'moveRange' : 'copyRange', sheet, baseRev,
+    row: r0, col: c0, endRow: r1, endCol: c1, destRow: dest.row, destCol: dest.col,
+  };
+}
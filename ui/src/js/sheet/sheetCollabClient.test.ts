@@ -153,3 +153,94 @@ describe('SheetCollabClient randomized convergence', () => {
     }
   });
 });
+
+describe('SheetCollabClient server undo/redo', () => {
+  const flushTick = (): Promise<void> => new Promise((r) => queueMicrotask(() => r()));
+  const setCell = (row: number, raw: string): Op => ({ type: 'setCell', sheet: 's1', baseRev: 0, row, col: 0, raw });
+
+  const client = () => {
+    const sent: { op: Op; join: boolean }[] = [];
+    const history: string[] = [];
+    const c = new SheetCollabClient(emptySnap, 0, {
+      send: (op, join) => sent.push({ op, join }),
+      history: (which) => history.push(which),
+    });
+    return { c, sent, history };
+  };
+
+  it('joins the ops of one tick into one action', async () => {
+    const { c, sent } = client();
+    for (let i = 0; i < 3; i++) c.applyLocal(setCell(i, `v${i}`));
+    await flushTick();
+    c.applyLocal(setCell(5, 'next'));
+    for (let rev = 1; rev <= 4; rev++) c.onAccept(rev);
+    expect(sent.map((s) => s.join)).toEqual([false, true, true, false]);
+    expect(c.canUndo()).toBe(true);
+  });
+
+  it('sends undo only once the pending ops are confirmed', () => {
+    const { c, sent, history } = client();
+    c.applyLocal(setCell(0, 'a'));
+    c.onAccept(1);
+    c.applyLocal(setCell(1, 'b'));
+    c.undo();
+    expect(history).toEqual([]);
+    c.onAccept(2);
+    expect(sent).toHaveLength(2);
+    expect(history).toEqual(['undo']);
+  });
+
+  it('applies the undo ops as remote ops and takes the depths from the result', () => {
+    const { c, history } = client();
+    c.applyLocal(setCell(0, 'a'));
+    c.onAccept(1);
+    expect(c.canRedo()).toBe(false);
+    c.undo();
+    expect(history).toEqual(['undo']);
+    // The guess holds until the server answers.
+    expect(c.canUndo()).toBe(false);
+    expect(c.canRedo()).toBe(true);
+    c.onRemote({ type: 'setCell', sheet: 's1', baseRev: 1, row: 0, col: 0, raw: '' }, 2);
+    c.onHistoryResult(0, 1);
+    expect(c.display.getCell('s1', 0, 0)?.raw ?? '').toBe('');
+    c.redo();
+    expect(history).toEqual(['undo', 'redo']);
+    c.onHistoryResult(1, 0);
+    expect(c.canUndo()).toBe(true);
+    expect(c.canRedo()).toBe(false);
+  });
+
+  it('a new edit ends the redo history', async () => {
+    const { c } = client();
+    c.onHistoryResult(0, 2);
+    c.applyLocal(setCell(0, 'a'));
+    await flushTick();
+    c.onAccept(1);
+    expect(c.canRedo()).toBe(false);
+  });
+
+  it('reports refused undos', () => {
+    const { c } = client();
+    const errors: string[] = [];
+    c.onHistoryError = (e) => errors.push(e);
+    c.onHistoryResult(1, 0, 'protected');
+    expect(errors).toEqual(['protected']);
+  });
+
+  it('a rejected action start hands it to the next joined op', async () => {
+    const { c } = client();
+    c.applyLocal(setCell(0, 'a'));
+    c.applyLocal(setCell(1, 'b'));
+    await flushTick();
+    c.onReject('protected');
+    c.onAccept(1);
+    expect(c.canUndo()).toBe(true);
+  });
+
+  it('has no undo without a history transport', () => {
+    const c = new SheetCollabClient(emptySnap, 0, { send: () => {} });
+    c.applyLocal(setCell(0, 'a'));
+    c.onAccept(1);
+    expect(c.canUndo()).toBe(false);
+  });
+});
@@ -1,15 +1,18 @@
 import type { Op } from './op';
+import { checkOp } from './rules';
 import { transform } from './transform';
-import { invertOp } from './undo';
 import { WorkbookState, type WorkbookSnapshot } from './workbookState';
 
-// Bounded so a long session cannot grow the history without limit; Excel caps
-// its own undo list too.
+// The server's bound on an author's undo steps (sheetdoc.MaxUndoSteps).
 const MAX_HISTORY = 100;
 
 // CollabTransport is the outbound channel for ops (wraps the socket emit).
+// join marks an op as part of the same user action as the op before it, so
+// the server undoes both in one step. history asks the server to undo or redo
+// the author's last action; without it there is no undo.
 export interface CollabTransport {
-  send(op: Op): void;
+  send(op: Op, join: boolean): void;
+  history?(which: 'undo' | 'redo'): void;
 }
 
 // SheetCollabClient mirrors the text collab_client.ts reconcile model for the
@@ -26,20 +29,35 @@ export class SheetCollabClient {
   rev: number;
   display: WorkbookState;
   onChange: () => void = () => {};
+  // onRejected reports a local op that was refused, by the protection and
+  // validation check here or by the server, with the server's reason.
+  onRejected: (reason: string) => void = () => {};
+  // The local author, for checking ops against protected ranges before they
+  // are sent; null skips the check (the server enforces it anyway).
+  author: string | null = null;
+  // onConfirmed sees every op as it enters the confirmed state, with the
+  // revision it became (comment anchors follow these).
+  onConfirmed: (op: Op, rev: number) => void = () => {};
+
+  // onHistoryError reports an undo or redo the server refused or had nothing
+  // for.
+  onHistoryError: (reason: string) => void = () => {};
 
   private serverWb: WorkbookState;
   private pending: Op[] = [];
+  // joins[i] tells whether pending[i] continues the action of the op before it.
+  private joins: boolean[] = [];
   private committing = false;
   private transport: CollabTransport;
 
-  // Undo history. Each entry is the op list that reverts one user action; the
-  // ops are recorded per applyLocal but grouped per tick, so a multi-op action
-  // (paste, fill, styling a range) undoes in one step. Only local ops are ever
-  // recorded, so undo never touches another collaborator's work.
-  private undoStack: Op[][] = [];
-  private redoStack: Op[][] = [];
-  private group: Op[] | null = null;
-  private mode: 'edit' | 'undo' | 'redo' = 'edit';
+  // Undo history lives on the server, which transforms it past everybody's
+  // later edits; this only tracks its depth for the toolbar. Ops of one tick
+  // are one user action (paste, fill, styling a range) and are sent joined.
+  private tickOpen = false;
+  private undoDepth = 0;
+  private redoDepth = 0;
+  // Undo/redo requests wait for the pending ops, so they undo those too.
+  private queuedHistory: ('undo' | 'redo')[] = [];
 
   constructor(snap: WorkbookSnapshot, head: number, transport: CollabTransport) {
     this.rev = head;
@@ -54,77 +72,82 @@ export class SheetCollabClient {
     return this.serverWb;
   }
 
+  // pendingOps are the local ops not yet confirmed, in order: display is
+  // confirmedState() with these applied.
+  pendingOps(): readonly Op[] {
+    return this.pending;
+  }
+
   // applyLocal applies a local edit optimistically and schedules it for sending.
-  applyLocal(op: Op): void {
-    // Computed against the pre-op display state — that is what the inverse has
-    // to restore.
-    const inverse = invertOp(this.display, op);
+  // An op the server would reject is refused up front; returns whether the op
+  // was applied.
+  applyLocal(op: Op): boolean {
+    const refused = this.author === null ? null : checkOp(this.display, op, this.author);
+    if (refused !== null) {
+      this.onRejected(refused);
+      return false;
+    }
     this.pending.push(op);
+    this.joins.push(this.tickOpen);
+    if (!this.tickOpen) {
+      this.tickOpen = true;
+      queueMicrotask(() => (this.tickOpen = false));
+    }
     this.display.applyOp(op);
-    this.record(inverse);
     this.onChange();
     this.flush();
+    return true;
   }
 
   canUndo(): boolean {
-    return this.undoStack.length > 0;
+    return this.transport.history !== undefined && this.undoDepth > 0;
   }
 
   canRedo(): boolean {
-    return this.redoStack.length > 0;
+    return this.transport.history !== undefined && this.redoDepth > 0;
   }
 
-  // undo/redo replay a recorded entry as ordinary local ops, which is what makes
-  // them collaboration-safe: they are transformed, sent and acked like any edit.
+  // undo/redo ask the server to revert the author's last action. The ops that
+  // do it come back like any remote op; the depths are guessed until the
+  // server's result (onHistoryResult) arrives.
   undo(): void {
-    this.replay(this.undoStack.pop(), 'undo');
+    if (!this.canUndo()) return;
+    this.undoDepth--;
+    this.redoDepth++;
+    this.requestHistory('undo');
   }
 
   redo(): void {
-    this.replay(this.redoStack.pop(), 'redo');
+    if (!this.canRedo()) return;
+    this.redoDepth--;
+    this.undoDepth++;
+    this.requestHistory('redo');
   }
 
-  private replay(entry: Op[] | undefined, mode: 'undo' | 'redo'): void {
-    if (!entry) return;
-    this.mode = mode;
-    for (const op of entry) this.applyLocal({ ...op, baseRev: this.rev });
-    this.closeGroup(); // the entry is complete now; do not wait for the tick
+  // onHistoryResult takes the server's answer to an undo or redo.
+  onHistoryResult(undo: number, redo: number, error?: string): void {
+    this.undoDepth = undo;
+    this.redoDepth = redo;
+    this.onChange();
+    if (error) this.onHistoryError(error);
   }
 
-  // record collects the inverse ops of one tick into a single history entry.
-  // Prepending keeps them in reverse application order, so undoing a group
-  // reverts its last op first.
-  private record(inverse: Op[]): void {
-    if (inverse.length === 0) return;
-    if (this.group === null) {
-      this.group = [];
-      queueMicrotask(() => this.closeGroup());
-    }
-    this.group.unshift(...inverse);
+  private requestHistory(which: 'undo' | 'redo'): void {
+    this.queuedHistory.push(which);
+    this.onChange();
+    this.flush();
   }
 
-  private closeGroup(): void {
-    const entry = this.group;
-    this.group = null;
-    const mode = this.mode;
-    this.mode = 'edit';
-    if (!entry || entry.length === 0) return;
-    if (mode === 'undo') {
-      this.redoStack.push(entry);
+  private flush(): void {
+    if (this.committing) return;
+    if (this.pending.length === 0) {
+      for (const which of this.queuedHistory.splice(0)) this.transport.history?.(which);
       return;
     }
-    this.undoStack.push(entry);
-    if (this.undoStack.length > MAX_HISTORY) this.undoStack.shift();
-    // A fresh edit invalidates the redo branch; a redo keeps it.
-    if (mode === 'edit') this.redoStack.length = 0;
-  }
-
-  private flush(): void {
-    if (this.committing || this.pending.length === 0) return;
     this.committing = true;
     const inflight: Op = { ...this.pending[0], baseRev: this.rev };
     this.pending[0] = inflight;
-    this.transport.send(inflight);
+    this.transport.send(inflight, this.joins[0]);
   }
 
   // onAccept confirms the in-flight op. Its current (transformed) form equals the
@@ -132,11 +155,30 @@ export class SheetCollabClient {
   onAccept(newRev: number): void {
     if (this.pending.length === 0) return;
     const confirmed = this.pending.shift() as Op;
+    if (!this.joins.shift()) {
+      this.undoDepth = Math.min(this.undoDepth + 1, MAX_HISTORY);
+      this.redoDepth = 0;
+    }
     this.serverWb.applyOp(confirmed);
     this.rev = newRev;
     this.committing = false;
+    this.onConfirmed(confirmed, newRev);
+    this.rebuildDisplay();
+    this.onChange();
+    this.flush();
+  }
+
+  // onReject drops the in-flight op the server refused: it never made it into
+  // the log, so serverWb stays as it is and the display loses the op.
+  onReject(reason: string): void {
+    if (this.pending.length === 0) return;
+    this.pending.shift();
+    // A joined op after it starts the action now.
+    if (!this.joins.shift() && this.joins.length > 0) this.joins[0] = false;
+    this.committing = false;
     this.rebuildDisplay();
     this.onChange();
+    this.onRejected(reason);
     this.flush();
   }
 
@@ -145,12 +187,8 @@ export class SheetCollabClient {
   onRemote(remoteOp: Op, newRev: number): void {
     this.serverWb.applyOp(remoteOp);
     this.rev = newRev;
+    this.onConfirmed(remoteOp, newRev);
     this.pending = this.pending.map((p) => transform(p, remoteOp));
-    // The history holds ops against the old coordinate space too: a remote row
-    // insert must move a recorded undo the same way it moves a pending op.
-    const rebase = (stack: Op[][]): Op[][] => stack.map((entry) => entry.map((o) => transform(o, remoteOp)));
-    this.undoStack = rebase(this.undoStack);
-    this.redoStack = rebase(this.redoStack);
     this.rebuildDisplay();
     this.onChange();
   }
@@ -4,16 +4,20 @@ import { SheetCollabClient } from './sheetCollabClient';
 import { FormulaEngine } from './formulaEngine';
 import { DomSheetView } from './sheetView';
 import { SheetPresence, effectiveCells, type PresenceFrame } from './sheetPresence';
-import { rangeToTSV, rangeToCSV, parseTSV, parseCSV, pasteOps, fillOps } from './sheetClipboard';
+import {
+  rangeToTSV, rangeToCSV, parseTSV, parseCSV, pasteOps, fillOps, fillSeriesOp, rangePasteOp, type ClipSource,
+} from './sheetClipboard';
 import { normalize, selCells, selIsSingle, type Selection } from './sheetSelection';
-import { createToolbar, type ToolbarCallbacks, type ToolbarElement } from './sheetToolbar';
+import { createToolbar, type RuleInput, type ToolbarCallbacks, type ToolbarElement } from './sheetToolbar';
 import { createSheetTabs } from './sheetTabs';
-import { sortRangeOps, distinctValues, hiddenRowsForFilter } from './sheetSortFilter';
+import { sortOps, distinctValues, hiddenRowsForView, type FilterView } from './sheetSortFilter';
 import { createFormulaBar, type FormulaBarHandle } from './sheetFormulaBar';
 import { rangeRefA1 } from './a1';
 import { mergeProps } from './styleCss';
 import { formatValue } from './format';
-import type { Op } from './op';
+import { deleteRuleOps, formatProps } from './rules';
+import { CommentStore, type CommentThread } from './comments';
+import type { Op, Rule } from './op';
 import type { WorkbookSnapshot } from './workbookState';
 
 interface SheetVarsData {
@@ -22,6 +26,7 @@ interface SheetVarsData {
   userId: string;
   userColor: string;
   readonly: boolean;
+  comments: CommentThread[] | null;
 }
 
 // Editor-level chrome (title bar above the ribbon, status bar below the tabs).
@@ -45,6 +50,15 @@ body { margin: 0; overflow: hidden; }
 .sheet-title-name { font-weight: 600; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
 .sheet-statusbar { display: flex; align-items: center; justify-content: space-between; height: 22px; padding: 0 10px; background: #f5f6f7; border-top: 1px solid #d4d8dd; font: 12px system-ui, sans-serif; color: #444; }
 .sheet-stats { display: flex; gap: 16px; }
+.sheet-comments-pane { position: fixed; right: 0; top: 140px; bottom: 48px; z-index: 20; width: 280px; overflow-y: auto; background: #fff; border-left: 1px solid #d4d8dd; box-shadow: -2px 0 8px rgba(0,0,0,0.08); font: 13px system-ui, sans-serif; color: #333; }
+.sheet-comments-pane h3 { margin: 0; padding: 8px 12px; font-size: 13px; border-bottom: 1px solid #e3e6e9; }
+.sheet-comment-thread { margin: 8px; padding: 8px; border: 1px solid #e3e6e9; border-radius: 4px; }
+.sheet-comment-thread.resolved { opacity: 0.6; }
+.sheet-comment-ref { font-weight: 600; color: #107c41; }
+.sheet-comment { margin-top: 6px; white-space: pre-wrap; overflow-wrap: anywhere; }
+.sheet-comment b { display: block; font-size: 12px; }
+.sheet-comment-thread button { margin: 6px 6px 0 0; border: 1px solid #d4d8dd; border-radius: 3px; background: #fff; font: 12px system-ui, sans-serif; cursor: pointer; }
+.sheet-comment-thread button:hover { background: #e6f2ec; }
 `;
 const TITLE_ICON_SVG =
   '<svg width="18" height="18" viewBox="0 0 16 16" aria-hidden="true">' +
@@ -70,17 +84,30 @@ export function startSheetEditor(root: HTMLElement): void {
   let readOnly = false;
   const GRID_ROWS = 200;
   const GRID_COLS = 52;
-  // Client-local filter state (per active sheet, reset on switch — not collaborative).
+  // Client-local filter views, per sheet id — never sent, so each user filters
+  // without affecting anyone else. hiddenRows is the active sheet's result.
+  const filterViews = new Map<string, FilterView>();
   let hiddenRows = new Set<number>();
+  // The range this client last copied or cut, for pasting it back as a range op.
+  let clip: ClipSource | null = null;
   let tabs: { el: HTMLElement; refresh: () => void } | null = null;
   let toolbarEl: ToolbarElement | null = null;
+  // Comment threads, anchored in confirmed coordinates (see comments.ts).
+  const comments = new CommentStore();
+  let commentsPane: HTMLElement | null = null;
 
   const transport = {
-    send: (op: Op) =>
+    send: (op: Op, join: boolean) =>
       socket.emit('message', {
         type: 'COLLABROOM',
         component: 'sheet',
-        data: { type: 'SHEET_OP', op, baseRev: op.baseRev },
+        data: { type: 'SHEET_OP', op, baseRev: op.baseRev, join },
+      }),
+    history: (which: 'undo' | 'redo') =>
+      socket.emit('message', {
+        type: 'COLLABROOM',
+        component: 'sheet',
+        data: { type: which === 'undo' ? 'SHEET_UNDO' : 'SHEET_REDO' },
       }),
   };
 
@@ -130,9 +157,27 @@ export function startSheetEditor(root: HTMLElement): void {
     return out;
   };
 
+  // Server-side export download. Anchor click, not location.href: Firefox
+  // treats the href navigation as an unload and kills the websocket even
+  // though the response is a download — the session would silently stop
+  // receiving broadcasts.
+  const download = (suffix: string): void => {
+    const a = document.createElement('a');
+    a.href = location.pathname + suffix;
+    a.download = '';
+    document.body.appendChild(a);
+    a.click();
+    a.remove();
+  };
+
   const rawValue = (r: number, c: number): string =>
     collab?.display.getCell(activeSheetId, r, c)?.raw ?? '';
 
+  const refreshFilter = (): void => {
+    const fv = filterViews.get(activeSheetId);
+    hiddenRows = fv ? hiddenRowsForView(fv, GRID_ROWS, rawValue) : new Set();
+  };
+
   // Status-bar stats (Excel wording): Average/Sum over numeric values —
   // formula cells count with their COMPUTED value, like Excel. Count =
   // non-empty cells. Shown only for multi-cell selections with at least one
@@ -180,6 +225,150 @@ export function startSheetEditor(root: HTMLElement): void {
   const propsOf = (r: number, c: number): Record<string, string> =>
     collab ? collab.display.getStyleProps(activeSheetId, r, c) : {};
 
+  // The grid shows conditional formats over the cell's own style; they never
+  // reach propsOf, so styling a cell does not persist them.
+  const renderPropsOf = (r: number, c: number): Record<string, string> => {
+    const own = propsOf(r, c);
+    const formats = collab?.display.condFormats ?? [];
+    if (formats.length === 0) return own;
+    const raw = rawValue(r, c);
+    const value = raw.startsWith('=') ? engine.getValue(r, c).value : raw;
+    const matched = formatProps(formats, activeSheetId, r, c, value);
+    return Object.keys(matched).length === 0 ? own : { ...own, ...matched };
+  };
+
+  // Workbook rules of one kind over the selection: a new rule, or (rule null)
+  // deleting the kind's rules the selection overlaps.
+  const applyRule = (type: 'setValidation' | 'setCondFormat' | 'setProtection', rule: Omit<Rule, 'id'> | null): void => {
+    if (readOnly || !collab) return;
+    blurActiveCell();
+    const { r0, c0, r1, c1 } = normalize(selection);
+    if (rule === null) {
+      const rules = { setValidation: collab.display.validations, setCondFormat: collab.display.condFormats, setProtection: collab.display.protections }[type];
+      for (const op of deleteRuleOps(rules, activeSheetId, r0, c0, r1, c1, collab.rev)) collab.applyLocal(op);
+      return;
+    }
+    const id = `r-${Math.random().toString(36).slice(2, 10)}`;
+    collab.applyLocal({
+      type, sheet: activeSheetId, baseRev: collab.rev, row: r0, col: c0, endRow: r1, endCol: c1, rule: { id, ...rule },
+    });
+  };
+
+  // Refused edits (protected cells, values failing validation) show in the
+  // status bar for a while, like Excel's message box but without blocking.
+  let readyEl: HTMLElement | null = null;
+  let noticeTimer: ReturnType<typeof setTimeout> | null = null;
+  const showNotice = (text: string): void => {
+    if (!readyEl) return;
+    readyEl.textContent = text;
+    if (noticeTimer) clearTimeout(noticeTimer);
+    noticeTimer = setTimeout(() => {
+      if (readyEl) readyEl.textContent = 'Ready';
+    }, 4000);
+  };
+
+  const sendComment = (data: Record<string, unknown>): void =>
+    socket.emit('message', {
+      type: 'COLLABROOM',
+      component: 'sheet',
+      data: { type: 'SHEET_COMMENT', ...data },
+    });
+
+  // A new thread's cell is composed against the display, which is the
+  // confirmed state only while no local op is pending — so it waits for the
+  // in-flight ops to be accepted before it is sent with collab.rev.
+  let idleQueue: Array<() => void> = [];
+  const whenIdle = (run: () => void): void => {
+    if (collab && collab.pendingOps().length === 0) run();
+    else idleQueue.push(run);
+  };
+  const flushIdle = (): void => {
+    if (!collab || collab.pendingOps().length > 0 || idleQueue.length === 0) return;
+    const queued = idleQueue;
+    idleQueue = [];
+    for (const run of queued) run();
+  };
+
+  const threadAt = (r: number, c: number): CommentThread | undefined =>
+    collab ? comments.threadAt(activeSheetId, r, c, collab.pendingOps()) : undefined;
+
+  const commentText = (t: CommentThread): string =>
+    t.comments.map((c) => (c.author ? `${c.author}:\n${c.text}` : c.text)).join('\n\n');
+
+  // New Comment: replies to the focused cell's thread, or starts one there.
+  const newComment = (): void => {
+    if (readOnly || !collab) return;
+    const { row, col } = selection.focus;
+    const existing = threadAt(row, col);
+    const ref = rangeRefA1(row, col, row, col);
+    const text = prompt(existing ? `Reply to the comment on ${ref}` : `Comment on ${ref}`);
+    if (!text || text.trim() === '') return;
+    if (existing) {
+      sendComment({ action: 'add', threadId: existing.id, text });
+      return;
+    }
+    const sheet = activeSheetId;
+    whenIdle(() => {
+      if (collab) sendComment({ action: 'add', sheet, row, col, baseRev: collab.rev, text });
+    });
+  };
+
+  // The comments pane lists the active sheet's threads, open or resolved.
+  const renderComments = (): void => {
+    if (!commentsPane || !collab) return;
+    const pane = commentsPane;
+    pane.textContent = '';
+    const title = document.createElement('h3');
+    title.textContent = 'Comments';
+    pane.appendChild(title);
+    const button = (parent: HTMLElement, label: string, run: () => void): void => {
+      const b = document.createElement('button');
+      b.textContent = label;
+      b.addEventListener('click', run);
+      parent.appendChild(b);
+    };
+    for (const t of comments.list(activeSheetId, collab.pendingOps())) {
+      const card = document.createElement('div');
+      card.className = 'sheet-comment-thread';
+      card.classList.toggle('resolved', !!t.resolved);
+      const ref = document.createElement('span');
+      ref.className = 'sheet-comment-ref';
+      ref.textContent = rangeRefA1(t.cell.row, t.cell.col, t.cell.row, t.cell.col) + (t.resolved ? ' (resolved)' : '');
+      card.appendChild(ref);
+      for (const c of t.comments) {
+        const el = document.createElement('div');
+        el.className = 'sheet-comment';
+        const who = document.createElement('b');
+        who.textContent = c.author || 'Unknown';
+        el.append(who, c.text);
+        if (!readOnly && c.authorId && c.authorId === collab.author) {
+          button(el, 'Delete', () => sendComment({ action: 'delete', threadId: t.id, commentId: c.id }));
+        }
+        card.appendChild(el);
+      }
+      if (!readOnly) {
+        button(card, 'Reply', () => {
+          const text = prompt('Reply');
+          if (text && text.trim() !== '') sendComment({ action: 'add', threadId: t.id, text });
+        });
+        button(card, t.resolved ? 'Reopen' : 'Resolve', () =>
+          sendComment({ action: t.resolved ? 'reopen' : 'resolve', threadId: t.id }));
+      }
+      pane.appendChild(card);
+    }
+  };
+  const toggleComments = (): void => {
+    if (commentsPane) {
+      commentsPane.remove();
+      commentsPane = null;
+      return;
+    }
+    commentsPane = document.createElement('div');
+    commentsPane.className = 'sheet-comments-pane';
+    root.appendChild(commentsPane);
+    renderComments();
+  };
+
   const displayValue = (r: number, c: number): string => {
     const cell = collab?.display.getCell(activeSheetId, r, c);
     if (!cell || cell.raw === '') {
@@ -235,6 +424,8 @@ export function startSheetEditor(root: HTMLElement): void {
     view?.render();
     tabs?.refresh();
     toolbarEl?.refreshHistory();
+    renderComments();
+    flushIdle();
     if (formulaBar) {
       const { r0, c0, r1, c1 } = normalize(selection);
       formulaBar.setActive(rangeRefA1(r0, c0, r1, c1), rawValue(selection.focus.row, selection.focus.col));
@@ -249,8 +440,22 @@ export function startSheetEditor(root: HTMLElement): void {
     readOnly = data.readonly;
     collab = new SheetCollabClient(data.snapshot, data.head, transport);
     collab.onChange = onChange;
+    collab.author = data.userId;
+    collab.onRejected = (reason) => {
+      showNotice(reason);
+      onChange(); // repaint a cell whose refused edit is still on screen
+    };
+    collab.onHistoryError = showNotice;
     presence = new SheetPresence(data.userId);
     presence.onChange = onChange;
+    // A re-sent SHEET_VARS starts over: a queued comment is composed against
+    // the dropped display.
+    idleQueue = [];
+    comments.onChange = onChange;
+    comments.reset(data.comments, data.head);
+    collab.onConfirmed = (op, rev) =>
+      comments.onConfirmed(op, rev, (id) => collab?.confirmedState().sheetById(id) !== undefined);
+    commentsPane = null;
 
     // The view clears its container's innerHTML in its constructor, so the
     // toolbar gets its own sibling container (gridHost) rather than sharing
@@ -281,9 +486,10 @@ export function startSheetEditor(root: HTMLElement): void {
       sortSelection: (asc) => {
         if (readOnly || !collab || selIsSingle(selection)) return;
         blurActiveCell();
-        for (const op of sortRangeOps(selection, selection.focus.col, asc, activeSheetId, collab.rev, rawValue)) {
-          collab.applyLocal(op);
-        }
+        const cellAt = (r: number, c: number) => collab?.display.getCell(activeSheetId, r, c);
+        const computed = (r: number, c: number): string => engine.getValue(r, c).value;
+        const keys = [{ col: selection.focus.col, desc: !asc }];
+        for (const op of sortOps(selection, keys, activeSheetId, collab.rev, cellAt, computed)) collab.applyLocal(op);
       },
       toggleFreeze: (kind) => {
         if (readOnly || !collab) return;
@@ -297,8 +503,13 @@ export function startSheetEditor(root: HTMLElement): void {
         return { rows: s?.frozenRows ?? 0, cols: s?.frozenCols ?? 0 };
       },
       filterValues: () => distinctValues(selection.focus.col, GRID_ROWS, rawValue),
+      filterValue: () => filterViews.get(activeSheetId)?.get(selection.focus.col) ?? null,
       applyFilter: (value) => {
-        hiddenRows = value === null ? new Set() : hiddenRowsForFilter(selection.focus.col, value, GRID_ROWS, rawValue);
+        const fv = filterViews.get(activeSheetId) ?? new Map<number, string>();
+        if (value === null) fv.delete(selection.focus.col);
+        else fv.set(selection.focus.col, value);
+        filterViews.set(activeSheetId, fv);
+        refreshFilter();
         view?.render();
       },
       // Explicit param types: until the ToolbarCallbacks interface gains these
@@ -317,17 +528,15 @@ export function startSheetEditor(root: HTMLElement): void {
         };
         collab.applyLocal(ops[action]);
       },
-      exportXlsx: () => {
-        // Anchor click, not location.href: Firefox treats the href navigation
-        // as an unload and kills the websocket even though the response is a
-        // download — the session would silently stop receiving broadcasts.
-        const a = document.createElement('a');
-        a.href = location.pathname + '/export.xlsx';
-        a.download = '';
-        document.body.appendChild(a);
-        a.click();
-        a.remove();
-      },
+      setValidation: (rule: RuleInput | null) => applyRule('setValidation', rule),
+      // Excel's default highlight: light red fill with dark red text.
+      setCondFormat: (rule: RuleInput | null) =>
+        applyRule('setCondFormat', rule && { ...rule, props: { bg: '#ffc7ce', color: '#9c0006' } }),
+      newComment,
+      toggleComments,
+      protectRange: (who) => applyRule('setProtection', who === null ? null : { authors: who === 'me' ? [data.userId] : [] }),
+      exportXlsx: () => download('/export.xlsx'),
+      exportOds: () => download('/export.ods'),
       exportCsv: () => {
         // Client-side: serialize the active sheet's used range with computed
         // values (what the user sees), then download. No websocket-killing nav.
@@ -508,7 +717,7 @@ export function startSheetEditor(root: HTMLElement): void {
     const setActiveSheet = (id: string): void => {
       if (id === activeSheetId) return;
       activeSheetId = id;
-      hiddenRows = new Set(); // the filter is per-sheet and client-local
+      refreshFilter(); // each sheet keeps its own filter view
       onChange();
     };
     tabs = createSheetTabs({
@@ -541,7 +750,7 @@ export function startSheetEditor(root: HTMLElement): void {
 
     const statusbar = document.createElement('div');
     statusbar.className = 'sheet-statusbar';
-    const readyEl = document.createElement('span');
+    readyEl = document.createElement('span');
     readyEl.textContent = 'Ready';
     statsEl = document.createElement('span');
     statsEl.className = 'sheet-stats';
@@ -554,7 +763,7 @@ export function startSheetEditor(root: HTMLElement): void {
       rawValue,
       displayValue,
       readOnly: data.readonly,
-      styleOf: (r, c) => propsOf(r, c),
+      styleOf: (r, c) => renderPropsOf(r, c),
       colWidth: (c) => collab?.display.sheetById(activeSheetId)?.colWidths.get(c),
       rowHeight: (r) => collab?.display.sheetById(activeSheetId)?.rowHeights.get(r),
       frozen: () => {
@@ -577,6 +786,10 @@ export function startSheetEditor(root: HTMLElement): void {
       // ponytail: second engine.getValue per formula cell per render (displayValue
       // already does one). Cheap: HyperFormula caches, and the raw.startsWith('=')
       // gate skips non-formula cells. Fold into displayValue if the grid grows.
+      commentOf: (r, c) => {
+        const t = threadAt(r, c);
+        return t && !t.resolved ? commentText(t) : undefined;
+      },
       errorOf: (r, c) => {
         const cell = collab?.display.getCell(activeSheetId, r, c);
         // '' included: an array formula can spill an error into a blank cell.
@@ -603,9 +816,13 @@ export function startSheetEditor(root: HTMLElement): void {
         // NEW_SHEET_OP.author — sending editing:false here would flicker.
         if (!committed) sendPresence(r, c, false);
       },
+      // Drag-fill continues series down/right (1, 2 -> 3, 4) with one
+      // fillSeries op; dragging up or left copies the source.
       onFill: (src, target) => {
         if (readOnly || !collab) return;
-        for (const op of fillOps(src, target, activeSheetId, collab.rev, rawValue)) collab.applyLocal(op);
+        const series = fillSeriesOp(src, target, activeSheetId, collab.rev);
+        if (series) collab.applyLocal(series);
+        else for (const op of fillOps(src, target, activeSheetId, collab.rev, rawValue)) collab.applyLocal(op);
       },
     });
     onChange();
@@ -627,17 +844,21 @@ export function startSheetEditor(root: HTMLElement): void {
   };
   // doCopy/doCut/doPaste back both the Ctrl+C/X/V shortcuts and the ribbon's
   // clipboardAction buttons (same semantics: TSV, blur before ops, readOnly guards).
+  //
+  // Like Excel, a cut only takes effect on paste: pasting it back into the same
+  // sheet sends one moveRange op (references to the moved cells follow), and a
+  // copy pasted there sends copyRange. Other pastes are plain setCells.
   const doCopy = (): void => {
     // ponytail: async Clipboard API only (requires secure context); a
     // hidden-textarea fallback is the upgrade path for plain-HTTP deploys.
-    void navigator.clipboard.writeText(rangeToTSV(selection, rawValue));
+    const text = rangeToTSV(selection, rawValue);
+    clip = { sheet: activeSheetId, sel: selection, text, cut: false };
+    void navigator.clipboard.writeText(text);
   };
   const doCut = (): void => {
-    void navigator.clipboard.writeText(rangeToTSV(selection, rawValue));
-    if (readOnly || !collab) return;
-    blurActiveCell();
-    const { r0, c0, r1, c1 } = normalize(selection);
-    collab.applyLocal({ type: 'clearRange', sheet: activeSheetId, baseRev: collab.rev, row: r0, col: c0, endRow: r1, endCol: c1 });
+    const text = rangeToTSV(selection, rawValue);
+    void navigator.clipboard.writeText(text);
+    clip = readOnly ? null : { sheet: activeSheetId, sel: selection, text, cut: true };
   };
   const doPaste = (): void => {
     if (readOnly || !collab) return;
@@ -645,12 +866,17 @@ export function startSheetEditor(root: HTMLElement): void {
     void navigator.clipboard.readText().then((text) => {
       if (text === '') return;
       if (!collab) return;
-      const grid = parseTSV(text);
       const { r0, c0 } = normalize(selection);
-      for (const op of pasteOps(grid, { row: r0, col: c0 }, activeSheetId, collab.rev)) collab.applyLocal(op);
+      const range = rangePasteOp(clip, text, { row: r0, col: c0 }, activeSheetId, collab.rev, rawValue);
+      if (range) {
+        collab.applyLocal(range);
+        if (clip?.cut) clip = null; // a cut pastes once
+        return;
+      }
+      for (const op of pasteOps(parseTSV(text), { row: r0, col: c0 }, activeSheetId, collab.rev)) collab.applyLocal(op);
     });
   };
-  // Undo/redo this client's own edits. Blur first so a half-typed cell does not
+  // Undo/redo this author's own edits, on the server. Blur first so a half-typed cell does not
   // get committed over the restored value by the blur handler.
   const doHistory = (which: 'undo' | 'redo'): void => {
     if (readOnly || !collab) return;
@@ -660,7 +886,8 @@ export function startSheetEditor(root: HTMLElement): void {
   };
 
   // Fill the selection from its first row (down) or first column (right).
-  // fillOps adjusts relative references, so formulas fill like in Excel.
+  // fillOps adjusts relative references, so formulas fill like in Excel. Like
+  // Excel's Ctrl+D/R this copies; only drag-fill continues series.
   const doFill = (dir: 'down' | 'right'): void => {
     if (readOnly || !collab || selIsSingle(selection)) return;
     blurActiveCell();
@@ -771,10 +998,15 @@ export function startSheetEditor(root: HTMLElement): void {
     if (msg.type === 'COLLABROOM' && msg.data) {
       const d = msg.data;
       if (d.type === 'ACCEPT_SHEET_OP') collab?.onAccept(d.newRev);
+      else if (d.type === 'REJECT_SHEET_OP') collab?.onReject(String(d.reason ?? ''));
+      else if (d.type === 'SHEET_UNDO_RESULT') collab?.onHistoryResult(d.undo, d.redo, d.error);
       else if (d.type === 'NEW_SHEET_OP') {
         collab?.onRemote(d.op as Op, d.newRev);
         if (d.author) presence?.clearLiveEdit(d.author);
       } else if (d.type === 'SHEET_PRESENCE') presence?.applyPresence(d as PresenceFrame);
+      else if (d.type === 'SHEET_COMMENT') comments.update(d.thread as CommentThread, !!d.deleted, d.rev);
+      else if (d.type === 'SHEET_COMMENTS') comments.reset(d.threads as CommentThread[], d.rev);
+      else if (d.type === 'REJECT_SHEET_COMMENT') showNotice(String(d.reason ?? ''));
       else if (d.type === 'USER_LEAVE') presence?.drop(d.userInfo?.userId);
       else if (d.type === 'SHEET_RELOAD') location.reload();
     }
@@ -1,5 +1,6 @@
 import { describe, it, expect } from 'vitest';
-import { sortRangeOps, distinctValues, hiddenRowsForFilter, compareVals } from './sheetSortFilter';
+import { sortOps, distinctValues, hiddenRowsForFilter, hiddenRowsForView, compareVals } from './sheetSortFilter';
+import type { Cell } from './workbookState';
 import type { Selection } from './sheetSelection';
 
 const sel = (r0: number, c0: number, r1: number, c1: number): Selection => ({
@@ -21,39 +22,25 @@ describe('compareVals', () => {
   });
 });
 
-describe('sortRangeOps', () => {
-  it('sorts rows by the key column and moves whole rows', () => {
-    const grid = [
-      ['3', 'c'],
-      ['1', 'a'],
-      ['2', 'b'],
-    ];
-    const ops = sortRangeOps(sel(0, 0, 2, 1), 0, true, 's1', 0, gridRaw(grid));
-    // row1 (1,a) -> row0; row2 (2,b) -> row1; row0 (3,c) -> row2
-    const at = (r: number, c: number) => ops.find((o) => o.row === r && o.col === c)?.raw;
-    expect(at(0, 0)).toBe('1');
-    expect(at(0, 1)).toBe('a');
-    expect(at(2, 0)).toBe('3');
-    expect(at(2, 1)).toBe('c');
+describe('sortOps', () => {
+  it('emits one sortRange op over the selection', () => {
+    const ops = sortOps(sel(2, 3, 0, 0), [{ col: 1, desc: true }], 's1', 4, () => undefined, () => '');
+    expect(ops).toEqual([
+      { type: 'sortRange', sheet: 's1', baseRev: 4, row: 0, col: 0, endRow: 2, endCol: 3, sortKeys: [{ col: 1, desc: true }] },
+    ]);
   });
 
-  it('adjusts moved formula row refs like fill does', () => {
-    const grid = [
-      ['2', '=A1*2'],
-      ['1', '=A2*2'],
-    ];
-    const ops = sortRangeOps(sel(0, 0, 1, 1), 0, true, 's1', 0, gridRaw(grid));
-    const at = (r: number, c: number) => ops.find((o) => o.row === r && o.col === c)?.raw;
-    expect(at(0, 1)).toBe('=A1*2'); // was =A2*2 on row 1, moved up one row
-    expect(at(1, 1)).toBe('=A2*2');
-  });
-
-  it('emits nothing when already sorted', () => {
-    const grid = [
-      ['1', 'a'],
-      ['2', 'b'],
-    ];
-    expect(sortRangeOps(sel(0, 0, 1, 1), 0, true, 's1', 0, gridRaw(grid))).toEqual([]);
+  it('re-sends stale cached values of key-column formulas first', () => {
+    const cells: Record<string, Cell> = {
+      '0:0': { raw: '=B1*2', value: '4' },
+      '1:0': { raw: '=B2*2', value: '6' },
+      '2:0': { raw: '5' },
+      '0:1': { raw: '=A9' },
+    };
+    const computed: Record<string, string> = { '0:0': '4', '1:0': '8', '0:1': '0' };
+    const ops = sortOps(sel(0, 0, 2, 1), [{ col: 0 }], 's1', 0, (r, c) => cells[`${r}:${c}`], (r, c) => computed[`${r}:${c}`] ?? '');
+    expect(ops.map((o) => o.type)).toEqual(['setCell', 'sortRange']);
+    expect(ops[0]).toMatchObject({ row: 1, col: 0, raw: '=B2*2', value: '8' });
   });
 });
 
@@ -66,4 +53,9 @@ describe('filter helpers', () => {
     const hidden = hiddenRowsForFilter(0, 'x', 5, gridRaw(grid));
     expect([...hidden].sort()).toEqual([1, 4]);
   });
+  it('hiddenRowsForView combines columns with AND', () => {
+    const two = [['x', '1'], ['y', '1'], ['x', '2'], ['', '1'], ['x', '']];
+    const hidden = hiddenRowsForView(new Map([[0, 'x'], [1, '1']]), 5, gridRaw(two));
+    expect([...hidden].sort()).toEqual([1, 2]);
+  });
 });
@@ -1,11 +1,11 @@
-// Pure sort + filter helpers. Sort is collaborative (a batch of setCell ops:
-// data moves, the server only sees cell ops). Filter is client-local row
-// hiding — no ops, not shared (collaborative filter is the upgrade path).
+// Pure sort + filter helpers. Sort is collaborative (one sortRange op, applied
+// by the server and every client alike). Filters are client-local row hiding
+// — no ops, not shared: each user keeps their own filter view per sheet.
 
-import type { Op } from './op';
+import type { Op, SortKey } from './op';
 import type { Selection } from './sheetSelection';
 import { normalize } from './sheetSelection';
-import { adjustFormula } from './sheetClipboard';
+import type { Cell } from './workbookState';
 
 // compareVals: numbers numerically, everything else lexically; empty always
 // sorts last regardless of direction (Excel behavior).
@@ -20,41 +20,29 @@ export function compareVals(a: string, b: string, asc: boolean): number {
   return asc ? base : -base;
 }
 
-// sortRangeOps reorders the rows of the selected range by the given column and
-// emits one setCell per cell of the range (moved formulas shift row refs like
-// fill does). byCol must lie inside the selection.
-export function sortRangeOps(
+// sortOps emits the sortRange op for the selection. sortRange orders formula
+// cells by their cached value, so any key-column formula whose cached value
+// is stale is first re-sent with the value this client computed — otherwise
+// the server and each client could sort the rows differently.
+export function sortOps(
   sel: Selection,
-  byCol: number,
-  asc: boolean,
+  keys: SortKey[],
   sheet: string,
   baseRev: number,
-  rawAt: (r: number, c: number) => string,
+  cellAt: (r: number, c: number) => Cell | undefined,
+  computedAt: (r: number, c: number) => string,
 ): Op[] {
   const { r0, c0, r1, c1 } = normalize(sel);
-  const keyIdx = Math.min(Math.max(byCol, c0), c1) - c0;
-  const rows: string[][] = [];
-  for (let r = r0; r <= r1; r++) {
-    const cells: string[] = [];
-    for (let c = c0; c <= c1; c++) cells.push(rawAt(r, c));
-    rows.push(cells);
-  }
-  const order = rows
-    .map((cells, i) => ({ cells, srcRow: r0 + i }))
-    .sort((a, b) => compareVals(a.cells[keyIdx], b.cells[keyIdx], asc)); // stable
-
   const ops: Op[] = [];
-  for (let i = 0; i < order.length; i++) {
-    const destRow = r0 + i;
-    const { cells, srcRow } = order[i];
-    if (srcRow === destRow) continue; // row did not move
-    for (let c = c0; c <= c1; c++) {
-      ops.push({
-        type: 'setCell', sheet, baseRev, row: destRow, col: c,
-        raw: adjustFormula(cells[c - c0], destRow - srcRow, 0),
-      });
+  for (const { col } of keys) {
+    for (let r = r0; r <= r1; r++) {
+      const cell = cellAt(r, col);
+      if (!cell?.raw.startsWith('=')) continue;
+      const value = computedAt(r, col);
+      if (value !== (cell.value ?? '')) ops.push({ type: 'setCell', sheet, baseRev, row: r, col, raw: cell.raw, value });
     }
   }
+  ops.push({ type: 'sortRange', sheet, baseRev, row: r0, col: c0, endRow: r1, endCol: c1, sortKeys: keys });
   return ops;
 }
 
@@ -73,18 +61,36 @@ export function distinctValues(
   return [...seen].sort((a, b) => compareVals(a, b, true));
 }
 
-// hiddenRowsForFilter: rows whose column value is non-empty and differs from
-// `keep`. Blank rows stay visible (the empty grid must not collapse).
-export function hiddenRowsForFilter(
-  col: number,
-  keep: string,
+// FilterView is one user's filter on a sheet: column -> the value to keep.
+// Columns combine with AND.
+export type FilterView = Map<number, string>;
+
+// hiddenRowsForView: rows where any filtered column holds a non-empty value
+// other than the kept one. Blank cells pass (the empty grid must not collapse).
+export function hiddenRowsForView(
+  view: FilterView,
   rowCount: number,
   rawAt: (r: number, c: number) => string,
 ): Set<number> {
   const hidden = new Set<number>();
   for (let r = 0; r < rowCount; r++) {
-    const v = rawAt(r, col);
-    if (v !== '' && v !== keep) hidden.add(r);
+    for (const [col, keep] of view) {
+      const v = rawAt(r, col);
+      if (v !== '' && v !== keep) {
+        hidden.add(r);
+        break;
+      }
+    }
   }
   return hidden;
 }
+
+// hiddenRowsForFilter is hiddenRowsForView for a single column.
+export function hiddenRowsForFilter(
+  col: number,
+  keep: string,
+  rowCount: number,
+  rawAt: (r: number, c: number) => string,
+): Set<number> {
+  return hiddenRowsForView(new Map([[col, keep]]), rowCount, rawAt);
+}
@@ -16,11 +16,15 @@ export interface ToolbarCallbacks {
   // Filter on the focus column: values() fills the dropdown lazily, apply(null) clears.
   filterValues?: () => string[];
   applyFilter?: (value: string | null) => void;
+  // The focused column's value in this user's filter view, null when unfiltered.
+  filterValue?: () => string | null;
   // Ribbon: row/col structure relative to the selection.
   structural?: (action: 'insRowAbove' | 'insRowBelow' | 'insColLeft' | 'insColRight' | 'delRows' | 'delCols') => void;
-  // Ribbon: workbook import/export (server round-trip).
+  // Ribbon: workbook import/export (server round-trip). importXlsx also takes
+  // .ods files; the server picks the format from the file name.
   importXlsx?: (file: File) => void;
   exportXlsx?: () => void;
+  exportOds?: () => void;
   // Ribbon: client-side CSV export/import of the active sheet (no server round-trip).
   exportCsv?: () => void;
   importCsv?: (file: File) => void;
@@ -40,6 +44,25 @@ export interface ToolbarCallbacks {
   history?: () => { canUndo: boolean; canRedo: boolean };
   // Merge/unmerge the current selection (the editor decides which).
   mergeToggle?: () => void;
+  // Workbook rules on the selection; null clears the selection's rules of
+  // that kind. Protecting for 'me' lets only this user edit the range,
+  // 'everyone' locks it for all until somebody unprotects it.
+  setValidation?: (rule: RuleInput | null) => void;
+  setCondFormat?: (rule: RuleInput | null) => void;
+  protectRange?: (who: 'me' | 'everyone' | null) => void;
+  // Comment threads on cells: start one on (or reply to) the focused cell's
+  // thread, and toggle the comments pane of the active sheet.
+  newComment?: () => void;
+  toggleComments?: () => void;
+}
+
+// RuleInput is a rule condition as entered in the ribbon dialogs.
+export interface RuleInput {
+  type: string;
+  operator?: string;
+  value1?: string;
+  value2?: string;
+  list?: string[];
 }
 
 const CSS = `
@@ -74,7 +97,7 @@ const CSS = `
 .sheet-ribbon-row input[type=color]:hover { background: #e6f2ec; border-color: #bcd8c9; }
 `;
 
-type TabName = 'Home' | 'Data' | 'View';
+type TabName = 'Home' | 'Data' | 'Review' | 'View';
 
 // Inline 16x16 SVG icons (stroke = currentColor); scaled up for big buttons.
 const svg = (inner: string, size = 16): string =>
@@ -107,6 +130,35 @@ const IC = {
   clear: '<path d="M3 13h10"/><path d="m5.5 10.5 6-6a1.5 1.5 0 0 0-2-2l-6 6z"/><path d="M9 3.5 12.5 7"/>',
   undo: '<path d="M3 8h7a3.5 3.5 0 0 1 0 7H6"/><path d="M5.5 5 2.5 8l3 3"/>',
   redo: '<path d="M13 8H6a3.5 3.5 0 0 0 0 7h4"/><path d="M10.5 5l3 3-3 3"/>',
+  validation: '<rect x="2.5" y="2.5" width="11" height="11"/><path d="M5 8.5 7 10.5 11 5.5"/>',
+  condFormat: '<rect x="2.5" y="2.5" width="11" height="11"/><path d="M2.5 6.5h11M2.5 10h11"/><path d="M6 2.5v11" stroke-dasharray="1.5 1.5"/>',
+  comment: '<path d="M2.5 3h11v7.5H7L4 13v-2.5H2.5z"/>',
+  protect: '<rect x="3.5" y="7" width="9" height="7" rx="1"/><path d="M5.5 7V5a2.5 2.5 0 0 1 5 0v2"/>',
+};
+
+// Excel's comparison operators, as offered by the rule dialogs.
+const OPERATORS: [string, string][] = [
+  ['between', 'between'], ['notBetween', 'not between'], ['equal', 'equal to'], ['notEqual', 'not equal to'],
+  ['greaterThan', 'greater than'], ['lessThan', 'less than'],
+  ['greaterThanOrEqual', 'greater than or equal to'], ['lessThanOrEqual', 'less than or equal to'],
+];
+
+// askCompare prompts for a comparison: the operator by its number in the
+// list, then its one or two values. null when cancelled.
+const askCompare = (what: string, fixed?: string): Pick<RuleInput, 'operator' | 'value1' | 'value2'> | null => {
+  let operator = fixed;
+  if (!operator) {
+    const menu = OPERATORS.map(([, label], i) => `${i + 1}: ${label}`).join('\n');
+    const pick = Number(prompt(`${what}: allow values that are…\n${menu}`, '1'));
+    operator = OPERATORS[pick - 1]?.[0];
+    if (!operator) return null;
+  }
+  const two = operator === 'between' || operator === 'notBetween';
+  const value1 = prompt(two ? `${what}: minimum` : `${what}: value`);
+  if (!value1) return null;
+  if (!two) return { operator, value1 };
+  const value2 = prompt(`${what}: maximum`);
+  return value2 ? { operator, value1, value2 } : null;
 };
 
 // The returned element carries refreshHistory(): the editor calls it after every
@@ -133,7 +185,7 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
   // Shared hidden .xlsx file input (File menu + Data tab use the same one).
   const fileInput = document.createElement('input');
   fileInput.type = 'file';
-  fileInput.accept = '.xlsx';
+  fileInput.accept = '.xlsx,.ods';
   fileInput.style.display = 'none';
   fileInput.addEventListener('change', () => {
     const f = fileInput.files?.[0];
@@ -182,8 +234,9 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
     b.addEventListener('click', () => { closeMenu(); onClick(); });
     fileMenu.appendChild(b);
   };
-  if (cb.importXlsx) fileMenuItem('Import (.xlsx)', () => fileInput.click());
+  if (cb.importXlsx) fileMenuItem('Import (.xlsx, .ods)', () => fileInput.click());
   if (cb.exportXlsx) fileMenuItem('Export (.xlsx)', () => cb.exportXlsx?.());
+  if (cb.exportOds) fileMenuItem('Export (.ods)', () => cb.exportOds?.());
   if (cb.exportCsv) fileMenuItem('Export (.csv)', () => cb.exportCsv?.());
   if (cb.importCsv) fileMenuItem('Import (.csv)', () => csvInput.click());
   fileWrap.append(fileBtn, fileMenu);
@@ -195,7 +248,7 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
     for (const [n, b] of tabBtns) b.classList.toggle('on', n === name);
     for (const g of groups) g.el.style.display = g.tab === name ? '' : 'none';
   };
-  for (const name of ['Home', 'Data', 'View'] as TabName[]) {
+  for (const name of ['Home', 'Data', 'Review', 'View'] as TabName[]) {
     const b = document.createElement('button');
     b.textContent = name;
     b.addEventListener('click', () => selectTab(name));
@@ -477,6 +530,25 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
     }
   }
 
+  // --- Home: Styles (conditional formatting, Excel's Highlight Cells Rules) ---
+  if (cb.setCondFormat) {
+    const ask = (label: string, operator: string) => () => {
+      const cond = askCompare(`Format cells that are ${label}`, operator);
+      if (cond) cb.setCondFormat?.({ type: 'cellIs', ...cond });
+    };
+    menuBtn(group('Home', 'Styles'), { icon: IC.condFormat, text: 'Conditional Formatting' }, 'Highlight cells by their value', [
+      ['Greater Than…', ask('greater than', 'greaterThan')],
+      ['Less Than…', ask('less than', 'lessThan')],
+      ['Between…', ask('between', 'between')],
+      ['Equal To…', ask('equal to', 'equal')],
+      ['Text that Contains…', () => {
+        const value1 = prompt('Format cells that contain the text');
+        if (value1) cb.setCondFormat?.({ type: 'text', operator: 'containsText', value1 });
+      }],
+      ['Clear Rules from Selection', () => cb.setCondFormat?.(null)],
+    ]);
+  }
+
   // --- Data: Get & Transform ---
   if (cb.importXlsx || cb.exportXlsx) {
     const gt = group('Data', 'Get & Transform');
@@ -495,7 +567,7 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
   btn(sortRow, { text: 'Z→A' }, 'Sort selection descending by the focused column', () => cb.sortSelection?.(false));
   const filterRow = row(sf);
   const filter = document.createElement('select');
-  filter.title = 'Filter rows by the focused column';
+  filter.title = 'Filter rows by the focused column (filters on several columns combine; only you see them)';
   const fill = () => {
     filter.innerHTML = '';
     const all = document.createElement('option');
@@ -508,6 +580,7 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
       o.textContent = v;
       filter.appendChild(o);
     }
+    filter.value = cb.filterValue?.() ?? '';
   };
   fill();
   filter.addEventListener('mousedown', fill); // repopulate lazily on open
@@ -515,6 +588,41 @@ export function createToolbar(cb: ToolbarCallbacks): ToolbarElement {
   filter.addEventListener('change', () => cb.applyFilter?.(filter.value === '' ? null : filter.value));
   filterRow.appendChild(filter);
 
+  // --- Data: Data Tools (validation of the selection's values) ---
+  if (cb.setValidation) {
+    const ask = (type: string, label: string) => () => {
+      const cond = askCompare(label);
+      if (cond) cb.setValidation?.({ type, ...cond });
+    };
+    menuBtn(group('Data', 'Data Tools'), { icon: IC.validation, text: 'Data Validation' }, 'Restrict the values of the selection', [
+      ['List…', () => {
+        const list = prompt('Allowed values, separated by commas');
+        const values = list?.split(',').map((v) => v.trim()).filter((v) => v !== '') ?? [];
+        if (values.length > 0) cb.setValidation?.({ type: 'list', list: values });
+      }],
+      ['Whole Number…', ask('whole', 'Whole number')],
+      ['Decimal…', ask('decimal', 'Decimal')],
+      ['Text Length…', ask('textLength', 'Text length')],
+      ['Clear Validation', () => cb.setValidation?.(null)],
+    ]);
+  }
+
+  // --- Review: Comments (threads anchored to cells) ---
+  if (cb.newComment) {
+    const comments = group('Review', 'Comments');
+    bigBtn(comments, IC.comment, 'New Comment', 'Comment on the selected cell', () => cb.newComment?.());
+    btn(row(col(comments)), { text: 'Show Comments' }, 'Show or hide all comments of this sheet', () => cb.toggleComments?.());
+  }
+
+  // --- Review: Protect (ranges only some authors may edit) ---
+  if (cb.protectRange) {
+    const protect = group('Review', 'Protect');
+    bigBtn(protect, IC.protect, 'Protect Range', 'Only you can edit the selected cells', () => cb.protectRange?.('me'));
+    const small = col(protect);
+    btn(row(small), { text: 'Lock for everyone' }, 'Nobody can edit the selected cells until they are unprotected', () => cb.protectRange?.('everyone'));
+    btn(row(small), { text: 'Unprotect' }, 'Remove the protection of the selected cells', () => cb.protectRange?.(null));
+  }
+
   // --- View: Freeze panes ---
   const freeze = group('View', 'Freeze panes');
   const mkFreeze = (label: string, kind: 'row' | 'col', title: string) => {
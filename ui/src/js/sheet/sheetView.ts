@@ -32,6 +32,9 @@ export interface SheetViewOptions {
   readOnly?: boolean;
   styleOf?: (row: number, col: number) => Record<string, string>;
   errorOf?: (row: number, col: number) => string | undefined;
+  // Comment threads: an anchored cell gets Excel's red corner marker and the
+  // thread text as its tooltip (an error tooltip wins).
+  commentOf?: (row: number, col: number) => string | undefined;
   // M4: sparse dimension overrides (px), freeze state, and resize commits.
   colWidth?: (col: number) => number | undefined;
   rowHeight?: (row: number) => number | undefined;
@@ -76,6 +79,7 @@ const CSS = `
 .sheet-fill-handle { position: absolute; width: 8px; height: 8px; background: #107c41; border: 1px solid #fff; cursor: crosshair; z-index: 6; }
 .sheet-grid td.sheet-fill-target { box-shadow: inset 0 0 0 1px #107c41; }
 .sheet-grid td.sheet-cell-error { color: #c0392b; }
+.sheet-grid td.sheet-cell-comment::before { content: ''; position: absolute; top: 0; right: 0; border-style: solid; border-width: 0 6px 6px 0; border-color: transparent #c42b1c transparent transparent; pointer-events: none; }
 `;
 
 export class DomSheetView {
@@ -471,7 +475,10 @@ export class DomSheetView {
         }
         const err = this.opts.errorOf?.(r, c);
         td.classList.toggle('sheet-cell-error', !!err);
-        if (err) td.title = err; else td.removeAttribute('title');
+        const note = this.opts.commentOf?.(r, c);
+        td.classList.toggle('sheet-cell-comment', !!note);
+        const tip = err ?? note;
+        if (tip) td.title = tip; else td.removeAttribute('title');
         const deco: RemoteCursorDeco | undefined = live ?? this.cursorByKey.get(k);
         if (deco) {
           td.style.boxShadow = `inset 0 0 0 2px ${deco.color}`;
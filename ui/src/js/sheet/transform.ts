@@ -1,3 +1,4 @@
+import { dropSheetRefs, moveRefs, renameRefs, sameSheetName, shiftRefs } from './formulaRefs';
 import { isStructural, type Op } from './op';
 
 // transform ports lib/sheet/transform.go exactly. It adjusts `inOp` so it applies
@@ -6,6 +7,7 @@ import { isStructural, type Op } from './op';
 // bit-for-bit: the server transforms on Submit while the client transforms its
 // pending ops against incoming NEW_SHEET_OPs.
 export function transform(inOp: Op, applied: Op): Op {
+  inOp = transformFormula(inOp, applied);
   if (inOp.sheet !== applied.sheet || !isStructural(applied)) return inOp;
   const index = applied.index ?? 0;
   const count = applied.count ?? 0;
@@ -25,13 +27,22 @@ export function transform(inOp: Op, applied: Op): Op {
 
 // hasRange mirrors Go: ops that carry an endRow/endCol rectangle.
 const hasRange = (t: Op['type']): boolean =>
-  t === 'clearRange' || t === 'mergeCells' || t === 'unmergeCells';
+  t === 'clearRange' ||
+  t === 'mergeCells' ||
+  t === 'unmergeCells' ||
+  t === 'sortRange' ||
+  t === 'moveRange' ||
+  t === 'copyRange' ||
+  t === 'fillSeries' ||
+  t === 'setValidation' ||
+  t === 'setCondFormat' ||
+  t === 'setProtection';
 
 function shiftRows(inOp: Op, index: number, delta: number): Op {
   const out: Op = { ...inOp };
-  out.row = shiftCoord(out.row ?? 0, index, delta);
-  if (hasRange(out.type)) {
-    out.endRow = shiftCoord(out.endRow ?? 0, index, delta);
+  [out.row, out.endRow, out.count] = shiftRange(out, out.row ?? 0, out.endRow, 'row', index, delta);
+  if (out.type === 'moveRange' || out.type === 'copyRange') {
+    out.destRow = shiftCoord(out.destRow ?? 0, index, delta);
   }
   if (out.type === 'insertRows' || out.type === 'deleteRows') {
     out.index = shiftCoord(out.index ?? 0, index, delta);
@@ -44,9 +55,12 @@ function shiftRows(inOp: Op, index: number, delta: number): Op {
 
 function shiftCols(inOp: Op, index: number, delta: number): Op {
   const out: Op = { ...inOp };
-  out.col = shiftCoord(out.col ?? 0, index, delta);
-  if (hasRange(out.type)) {
-    out.endCol = shiftCoord(out.endCol ?? 0, index, delta);
+  [out.col, out.endCol, out.count] = shiftRange(out, out.col ?? 0, out.endCol, 'col', index, delta);
+  if (out.type === 'moveRange' || out.type === 'copyRange') {
+    out.destCol = shiftCoord(out.destCol ?? 0, index, delta);
+  }
+  if (out.type === 'sortRange') {
+    out.sortKeys = (out.sortKeys ?? []).map((k) => ({ ...k, col: shiftCoord(k.col, index, delta) }));
   }
   if (out.type === 'insertCols' || out.type === 'deleteCols') {
     out.index = shiftCoord(out.index ?? 0, index, delta);
@@ -57,6 +71,29 @@ function shiftCols(inOp: Op, index: number, delta: number): Op {
   return out;
 }
 
+// shiftRange mirrors Go shiftRange: shift the start and, for ops with a range,
+// the end along axis; a fillSeries seed on that axis shifts like a merge.
+function shiftRange(
+  op: Op,
+  start: number,
+  end: number | undefined,
+  axis: 'row' | 'col',
+  index: number,
+  delta: number,
+): [number, number | undefined, number | undefined] {
+  const fill = op.type === 'fillSeries' && op.axis === axis;
+  let count = op.count;
+  if (fill) count = Math.max(shiftEnd(start + (count ?? 0), index, delta) - shiftCoord(start, index, delta), 1);
+  start = shiftCoord(start, index, delta);
+  if (hasRange(op.type)) end = shiftCoord(end ?? 0, index, delta);
+  if (fill) count = Math.min(count ?? 0, (end ?? 0) - start + 1);
+  return [start, end, count];
+}
+
+// shiftEnd mirrors Go shiftEnd (an EXCLUSIVE bound).
+const shiftEnd = (coord: number, index: number, delta: number): number =>
+  delta >= 0 && coord === index ? coord : shiftCoord(coord, index, delta);
+
 // shiftCoord: for inserts (delta>0) coords at/after index move; for deletes
 // (delta<0) coords after the band move back, coords inside the band clamp to index.
 function shiftCoord(coord: number, index: number, delta: number): number {
@@ -68,3 +105,40 @@ function shiftCoord(coord: number, index: number, delta: number): number {
   if (coord < index + band) return index;
   return coord - band;
 }
+
+// transformFormula mirrors Go transformFormula: rewrite the formula of a
+// setCell past applied like applyOp rewrites the workbook's formulas.
+function transformFormula(inOp: Op, applied: Op): Op {
+  if (inOp.type !== 'setCell' || inOp.raw === undefined || !inOp.raw.startsWith('=')) return inOp;
+  const name = applied.sheetName ?? '';
+  switch (applied.type) {
+    case 'insertRows':
+    case 'deleteRows':
+    case 'insertCols':
+    case 'deleteCols': {
+      const axis = applied.type === 'insertCols' || applied.type === 'deleteCols' ? 'col' : 'row';
+      const count = applied.count ?? 0;
+      const delta = applied.type === 'deleteRows' || applied.type === 'deleteCols' ? -count : count;
+      const target = (sheet: string): boolean =>
+        sheet === '' ? inOp.sheet === applied.sheet : name !== '' && sameSheetName(sheet, name);
+      return { ...inOp, raw: shiftRefs(inOp.raw, target, axis, applied.index ?? 0, delta) };
+    }
+    case 'moveRange': {
+      const target = (sheet: string): boolean =>
+        sheet === '' ? inOp.sheet === applied.sheet : name !== '' && sameSheetName(sheet, name);
+      const r0 = applied.row ?? 0;
+      const c0 = applied.col ?? 0;
+      const raw = moveRefs(inOp.raw, target, r0, c0, applied.endRow ?? 0, applied.endCol ?? 0,
+        (applied.destRow ?? 0) - r0, (applied.destCol ?? 0) - c0);
+      return { ...inOp, raw };
+    }
+    case 'renameSheet':
+      if (name === '') return inOp;
+      return { ...inOp, raw: renameRefs(inOp.raw, name, applied.name ?? '') };
+    case 'deleteSheet':
+      if (name === '') return inOp;
+      return { ...inOp, raw: dropSheetRefs(inOp.raw, name) };
+    default:
+      return inOp;
+  }
+}
@@ -1,4 +1,7 @@
-import type { Op } from './op';
+import { dropSheetRefs, moveRefs, renameRefs, sameSheetName, shiftRefs } from './formulaRefs';
+import type { Op, Rule } from './op';
+import { compareSortValues, isBlank, offsetCell, series, sortValueOf, type SortValue } from './rangeOps';
+import { MAX_RULES, ruleOf } from './rules';
 import { StylePoolMirror, type StyleProps } from './stylePool';
 
 export interface Cell {
@@ -78,6 +81,19 @@ const shiftIdx2 = (coord: number, index: number, delta: number): number => {
   return coord - band;
 };
 
+// shiftRules mirrors Go Workbook.shiftRules: rule ranges move like merges; a
+// rule whose range is deleted entirely is dropped.
+const shiftRules = (rules: Rule[], sheet: string, axis: 'row' | 'col', index: number, delta: number): Rule[] =>
+  rules.flatMap((r) => {
+    if (r.sheet !== sheet) return [r];
+    const lo = (axis === 'row' ? r.row : r.col) ?? 0;
+    const hi = (axis === 'row' ? r.endRow : r.endCol) ?? 0;
+    const nlo = shiftIdx2(lo, index, delta);
+    const nhi = shiftEnd(hi + 1, index, delta) - 1;
+    if (nhi < nlo) return [];
+    return [axis === 'row' ? { ...r, row: nlo, endRow: nhi } : { ...r, col: nlo, endCol: nhi }];
+  });
+
 // shiftDims mirrors Go shiftDims: rebuild a sparse dimension map after an
 // insert (delta>0) / delete of -delta indices at index (in-band entries drop).
 const shiftDims = (m: Map<number, number>, index: number, delta: number): Map<number, number> => {
@@ -123,6 +139,9 @@ export interface SheetSnapshot {
 export interface WorkbookSnapshot {
   sheets: SheetSnapshot[];
   styles?: unknown;
+  validations?: Rule[];
+  condFormats?: Rule[];
+  protections?: Rule[];
 }
 
 // WorkbookState is the client mirror of the Go Workbook. applyOp ports
@@ -130,11 +149,20 @@ export interface WorkbookSnapshot {
 export class WorkbookState {
   sheets: SheetState[] = [];
   styles = new StylePoolMirror();
+  // Workbook-level rules (see rules.ts). Rules are replaced, never mutated,
+  // since clones share them.
+  validations: Rule[] = [];
+  condFormats: Rule[] = [];
+  protections: Rule[] = [];
 
   sheetById(id: string): SheetState | undefined {
     return this.sheets.find((s) => s.id === id);
   }
 
+  ruleById(id: string): Rule | undefined {
+    return [...this.validations, ...this.condFormats, ...this.protections].find((r) => r.id === id);
+  }
+
   addSheet(id: string, name: string): SheetState {
     const s = emptySheet(id, name);
     this.sheets.push(s);
@@ -154,6 +182,9 @@ export class WorkbookState {
       merges: new Map(s.merges),
     }));
     cp.styles = this.styles; // shared pool: interning is monotonic + content-deduped
+    cp.validations = [...this.validations];
+    cp.condFormats = [...this.condFormats];
+    cp.protections = [...this.protections];
     return cp;
   }
 
@@ -176,6 +207,9 @@ export class WorkbookState {
       return s;
     });
     this.styles.seed(snap.styles);
+    this.validations = structuredClone(snap.validations ?? []);
+    this.condFormats = structuredClone(snap.condFormats ?? []);
+    this.protections = structuredClone(snap.protections ?? []);
   }
 
   // Returns the LIVE pool object for the cell's style — never mutate it in
@@ -203,6 +237,175 @@ export class WorkbookState {
     sheet.cells = next;
   }
 
+  // rewriteFormulas mirrors Go: replace the raw of every formula cell by
+  // fn(sheet, raw), dropping the cached value of rewritten cells. Cells are
+  // replaced, never mutated, since clones share them.
+  private rewriteFormulas(fn: (sheet: SheetState, raw: string) => string): void {
+    for (const s of this.sheets) {
+      for (const [k, cell] of s.cells) {
+        if (!cell.raw.startsWith('=')) continue;
+        const raw = fn(s, cell.raw);
+        if (raw !== cell.raw) s.cells.set(k, { ...cell, raw, value: undefined, valueType: undefined });
+      }
+    }
+  }
+
+  private shiftFormulas(target: SheetState, axis: 'row' | 'col', index: number, delta: number): void {
+    this.rewriteFormulas((s, raw) =>
+      shiftRefs(raw, (sheet) => (sheet === '' ? s === target : sameSheetName(sheet, target.name)), axis, index, delta),
+    );
+  }
+
+  // takeRange removes the cells of the inclusive rectangle and returns them.
+  private takeRange(sheet: SheetState, r0: number, c0: number, r1: number, c1: number): Map<string, Cell> {
+    const out = new Map<string, Cell>();
+    for (const [k, cell] of [...sheet.cells]) {
+      const [r, c] = parseKey(k);
+      if (r >= r0 && r <= r1 && c >= c0 && c <= c1) {
+        out.set(k, cell);
+        sheet.cells.delete(k);
+      }
+    }
+    return out;
+  }
+
+  // sortRange mirrors Go Sheet.sortRange: a stable sort of the range's rows by
+  // the sort keys; rows with all keys blank keep their order after the rest.
+  private sortRange(sheet: SheetState, op: Op): void {
+    const row = op.row ?? 0;
+    const keys = op.sortKeys ?? [];
+    const moved = this.takeRange(sheet, row, op.col ?? 0, op.endRow ?? 0, op.endCol ?? 0);
+    const rows = new Map<number, Map<number, Cell>>();
+    for (const [k, cell] of moved) {
+      const [r, c] = parseKey(k);
+      if (!rows.has(r)) rows.set(r, new Map());
+      rows.get(r)?.set(c, cell);
+    }
+    const keyed: { row: number; values: SortValue[] }[] = [];
+    const blank: number[] = [];
+    for (const [r, cells] of rows) {
+      const values = keys.map((k) => sortValueOf(cells.get(k.col)));
+      if (values.every(isBlank)) blank.push(r);
+      else keyed.push({ row: r, values });
+    }
+    keyed.sort((a, b) => {
+      for (let i = 0; i < keys.length; i++) {
+        const c = compareSortValues(a.values[i], b.values[i], keys[i].desc ?? false);
+        if (c !== 0) return c;
+      }
+      return a.row - b.row;
+    });
+    const dest = new Map<number, number>();
+    keyed.forEach((k, i) => dest.set(k.row, row + i));
+    // The all-blank rows, populated or not, fill the rest in their order.
+    const keyedRows = keyed.map((k) => k.row).sort((a, b) => a - b);
+    for (const r of blank) {
+      const before = keyedRows.filter((k) => k < r).length;
+      dest.set(r, row + keyed.length + (r - row - before));
+    }
+    for (const [r, cells] of rows) {
+      const d = dest.get(r) ?? r;
+      for (const [c, cell] of cells) sheet.cells.set(key(d, c), offsetCell(cell, d - r, 0));
+    }
+  }
+
+  // moveRange mirrors Go Workbook.moveRange: cut and paste, with references
+  // to the moved cells anywhere in the workbook following them.
+  private moveRange(sheet: SheetState, op: Op): void {
+    const [r0, c0, r1, c1] = [op.row ?? 0, op.col ?? 0, op.endRow ?? 0, op.endCol ?? 0];
+    const destRow = op.destRow ?? 0;
+    const destCol = op.destCol ?? 0;
+    const dRow = destRow - r0;
+    const dCol = destCol - c0;
+    if (dRow === 0 && dCol === 0) return;
+    const target = (s: SheetState) => (name: string): boolean =>
+      name === '' ? s === sheet : sameSheetName(name, sheet.name);
+    const moved = this.takeRange(sheet, r0, c0, r1, c1);
+    this.rewriteFormulas((s, raw) => moveRefs(raw, target(s), r0, c0, r1, c1, dRow, dCol));
+    this.takeRange(sheet, destRow, destCol, destRow + r1 - r0, destCol + c1 - c0);
+    for (const [k, cell] of moved) {
+      const [r, c] = parseKey(k);
+      let next = cell;
+      if (cell.raw.startsWith('=')) {
+        const raw = moveRefs(cell.raw, target(sheet), r0, c0, r1, c1, dRow, dCol);
+        if (raw !== cell.raw) next = { ...cell, raw, value: undefined, valueType: undefined };
+      }
+      sheet.cells.set(key(r + dRow, c + dCol), next);
+    }
+  }
+
+  // copyRange mirrors Go Sheet.copyRange.
+  private copyRange(sheet: SheetState, op: Op): void {
+    const [r0, c0, r1, c1] = [op.row ?? 0, op.col ?? 0, op.endRow ?? 0, op.endCol ?? 0];
+    const destRow = op.destRow ?? 0;
+    const destCol = op.destCol ?? 0;
+    const dRow = destRow - r0;
+    const dCol = destCol - c0;
+    const src: [number, number, Cell][] = [];
+    for (const [k, cell] of sheet.cells) {
+      const [r, c] = parseKey(k);
+      if (r >= r0 && r <= r1 && c >= c0 && c <= c1) src.push([r, c, cell]);
+    }
+    this.takeRange(sheet, destRow, destCol, destRow + r1 - r0, destCol + c1 - c0);
+    for (const [r, c, cell] of src) sheet.cells.set(key(r + dRow, c + dCol), offsetCell(cell, dRow, dCol));
+  }
+
+  // fillSeries mirrors Go Sheet.fillSeries.
+  private fillSeries(sheet: SheetState, op: Op): void {
+    const row = op.row ?? 0;
+    const col = op.col ?? 0;
+    const count = op.count ?? 0;
+    const axis = op.axis === 'col' ? 'col' : 'row';
+    let lines = (op.endCol ?? 0) - col + 1;
+    let length = (op.endRow ?? 0) - row + 1;
+    let at = (line: number, pos: number): [number, number] => [row + pos, col + line];
+    if (axis === 'col') {
+      [lines, length] = [length, lines];
+      at = (line, pos) => [row + line, col + pos];
+    }
+    for (let line = 0; line < lines; line++) {
+      const seed: Cell[] = [];
+      for (let i = 0; i < count; i++) seed.push(sheet.cells.get(key(...at(line, i))) ?? { raw: '' });
+      const next = series(seed, axis);
+      for (let pos = count; pos < length; pos++) this.setCell(sheet, ...at(line, pos), next(pos));
+    }
+  }
+
+  private ruleLists(): ['setValidation' | 'setCondFormat' | 'setProtection', Rule[]][] {
+    return [['setValidation', this.validations], ['setCondFormat', this.condFormats], ['setProtection', this.protections]];
+  }
+
+  private setRuleList(t: Op['type'], rules: Rule[]): void {
+    if (t === 'setValidation') this.validations = rules;
+    else if (t === 'setCondFormat') this.condFormats = rules;
+    else this.protections = rules;
+  }
+
+  // setRule mirrors Go Workbook.setRule: add or replace by id within the op's
+  // kind. An id of another kind, or a full list, is refused by the server, so
+  // the op is a no-op here too.
+  private setRule(op: Op): void {
+    const r = ruleOf(op);
+    for (const [t, rules] of this.ruleLists()) {
+      const i = rules.findIndex((o) => o.id === r.id);
+      if (t === op.type && i >= 0) {
+        this.setRuleList(t, rules.map((o, j) => (j === i ? r : o)));
+        return;
+      }
+      if (t !== op.type && i >= 0) return;
+    }
+    const own = this.ruleLists().find(([t]) => t === op.type)?.[1] ?? [];
+    if (own.length < MAX_RULES) this.setRuleList(op.type, [...own, r]);
+  }
+
+  private filterRules(keep: (r: Rule) => boolean): void {
+    for (const [t, rules] of this.ruleLists()) this.setRuleList(t, rules.filter(keep));
+  }
+
+  private shiftAllRules(sheet: string, axis: 'row' | 'col', index: number, delta: number): void {
+    for (const [t, rules] of this.ruleLists()) this.setRuleList(t, shiftRules(rules, sheet, axis, index, delta));
+  }
+
   // applyOp mirrors Go Workbook.Apply. The op is assumed already rebased to the
   // current revision. Cell ops are last-writer-wins.
   applyOp(op: Op): void {
@@ -217,12 +420,21 @@ export class WorkbookState {
       case 'deleteSheet': {
         if (this.sheets.length <= 1) return; // never delete the last sheet
         const i = this.sheets.findIndex((s) => s.id === op.sheet);
-        if (i >= 0) this.sheets.splice(i, 1);
+        if (i >= 0) {
+          const [s] = this.sheets.splice(i, 1);
+          this.filterRules((r) => r.sheet !== s.id);
+          this.rewriteFormulas((_, raw) => dropSheetRefs(raw, s.name));
+        }
         return;
       }
       case 'renameSheet': {
         const s = this.sheetById(op.sheet);
-        if (s) s.name = op.name ?? s.name;
+        const name = op.name ?? s?.name;
+        if (s && name !== undefined && s.name !== name) {
+          const from = s.name;
+          s.name = name;
+          this.rewriteFormulas((_, raw) => renameRefs(raw, from, name));
+        }
         return;
       }
       case 'moveSheet': {
@@ -276,6 +488,26 @@ export class WorkbookState {
         }
         break;
       }
+      case 'sortRange':
+        this.sortRange(sheet, op);
+        break;
+      case 'moveRange':
+        this.moveRange(sheet, op);
+        break;
+      case 'copyRange':
+        this.copyRange(sheet, op);
+        break;
+      case 'fillSeries':
+        this.fillSeries(sheet, op);
+        break;
+      case 'setValidation':
+      case 'setCondFormat':
+      case 'setProtection':
+        if (op.rule) this.setRule(op);
+        break;
+      case 'deleteRule':
+        this.filterRules((r) => r.id !== op.rule?.id);
+        break;
       case 'setDimension': {
         // Mirror the Go server validation: axis col/row, size 1..4096.
         const size = op.size ?? 0;
@@ -313,6 +545,8 @@ export class WorkbookState {
         this.remap(sheet, (r, c) => (r >= index ? [r + count, c, true] : [r, c, true]));
         sheet.rowHeights = shiftDims(sheet.rowHeights, index, count);
         sheet.merges = shiftMerges(sheet.merges, 'row', index, count);
+        this.shiftAllRules(sheet.id, 'row', index, count);
+        this.shiftFormulas(sheet, 'row', index, count);
         break;
       case 'deleteRows':
         this.remap(sheet, (r, c) => {
@@ -322,11 +556,15 @@ export class WorkbookState {
         });
         sheet.rowHeights = shiftDims(sheet.rowHeights, index, -count);
         sheet.merges = shiftMerges(sheet.merges, 'row', index, -count);
+        this.shiftAllRules(sheet.id, 'row', index, -count);
+        this.shiftFormulas(sheet, 'row', index, -count);
         break;
       case 'insertCols':
         this.remap(sheet, (r, c) => (c >= index ? [r, c + count, true] : [r, c, true]));
         sheet.colWidths = shiftDims(sheet.colWidths, index, count);
         sheet.merges = shiftMerges(sheet.merges, 'col', index, count);
+        this.shiftAllRules(sheet.id, 'col', index, count);
+        this.shiftFormulas(sheet, 'col', index, count);
         break;
       case 'deleteCols':
         this.remap(sheet, (r, c) => {
@@ -336,6 +574,8 @@ export class WorkbookState {
         });
         sheet.colWidths = shiftDims(sheet.colWidths, index, -count);
         sheet.merges = shiftMerges(sheet.merges, 'col', index, -count);
+        this.shiftAllRules(sheet.id, 'col', index, -count);
+        this.shiftFormulas(sheet, 'col', index, -count);
         break;
       default:
         throw new Error(`applyOp: unhandled op type ${(op as Op).type}`);
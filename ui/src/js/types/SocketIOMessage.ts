@@ -88,6 +88,7 @@ export type ClientVarPayload = {
   skinName: string
   skinVariants: string,
   availableExports: string[]
+  exportFormats: {name: string, label: string, icon: string}[],
   savedRevisions: PadRevision[],
   initialRevisionList: number[],
   padShortcutEnabled: MapArrayType<boolean>,
@@ -174,6 +175,38 @@ export type ChatMessageMessages = {
   messages: string
 }
 
+export type ChatMessageUpdateMessage = {
+  type: 'CHAT_MESSAGE_UPDATE'
+  message: ChatMessage
+}
+
+export type ChatMentionsMessage = {
+  type: 'CHAT_MENTIONS'
+  unread: number
+}
+
+export type RejectChatMessage = {
+  type: 'REJECT_CHAT'
+  head: number
+  reason: string
+}
+
+export type ChatEditMessage = {
+  type: 'CHAT_EDIT'
+  head: number
+  text: string
+}
+
+export type ChatDeleteMessage = {
+  type: 'CHAT_DELETE'
+  head: number
+}
+
+export type ChatReadMessage = {
+  type: 'CHAT_READ'
+  head: number
+}
+
 export type ClientUserChangesMessage = {
   type: 'USER_CHANGES',
   baseRev: number,
@@ -183,7 +216,7 @@ export type ClientUserChangesMessage = {
 
 
 
-export type ClientSendMessages =  ClientUserChangesMessage |ClientReadyMessage| ClientSendUserInfoUpdate|ChatMessageMessage| ClientMessageMessage | GetChatMessageMessage |ClientSuggestUserName | NewRevisionListMessage | RevisionLabel | PadOptionsMessage| ClientSaveRevisionMessage
+export type ClientSendMessages =  ClientUserChangesMessage |ClientReadyMessage| ClientSendUserInfoUpdate|ChatMessageMessage| ClientMessageMessage | GetChatMessageMessage |ClientSuggestUserName | NewRevisionListMessage | RevisionLabel | PadOptionsMessage| ClientSaveRevisionMessage | ChatEditMessage | ChatDeleteMessage | ChatReadMessage
 
 export type ClientReadyMessage = {
   type: 'CLIENT_READY',
@@ -292,7 +325,7 @@ export type ChangesetRequestMessage = {
 
 export type CollabroomMessage = {
   type: 'COLLABROOM'
-  data: ClientSendUserInfoUpdate | ClientUserChangesMessage | ChatMessageMessage | GetChatMessageMessage | ClientSaveRevisionMessage | ClientMessageMessage | PadDeleteMessage
+  data: ClientSendUserInfoUpdate | ClientUserChangesMessage | ChatMessageMessage | GetChatMessageMessage | ClientSaveRevisionMessage | ClientMessageMessage | PadDeleteMessage | ChatEditMessage | ChatDeleteMessage | ChatReadMessage
 }
 
 export type ClientVarMessage =  | ClientVarData | ClientDisconnectedMessage | ClientReadyMessage| ChangesetRequestMessage | CollabroomMessage | CustomMessage